@@ -5,17 +5,27 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
 
+	"github.com/google/uuid"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
+// migrasiSelesai ditutup setelah migrateDB selesai, berhasil maupun gagal
+var (
+	migrasiSelesai     = make(chan struct{})
+	tutupMigrasiSekali sync.Once
+)
+
 func InitDB() {
 	// Ambil environment variables
 	user := os.Getenv("DB_USER")
@@ -77,17 +87,55 @@ func InitDB() {
 }
 
 func migrateDB(db *gorm.DB) {
+	defer tutupMigrasiSekali.Do(func() { close(migrasiSelesai) })
 	log.Printf("🔄 Starting database migration...")
 	
 	start := time.Now()
+	if err := services.SiapkanKolomStatusPemakaian(db); err != nil {
+		log.Printf("⚠️ Kolom status pemakaian warning: %v", err)
+	}
 	err := db.AutoMigrate(
 		&models.User{},
 		&models.Keluarga{},
 		&models.Santri{},
+		&models.Kelas{},
+		&models.JadwalKelas{},
+		&models.AnggotaKelas{},
+		&models.SesiAbsensi{},
+		&models.Absensi{},
+		&models.KoreksiAbsensi{},
+		&models.SetoranSantri{},
+		&models.Munaqosah{},
+		&models.Raport{},
+		&models.NilaiRaport{},
+		&models.Rekening{},
 		&models.Syahriah{},
+		&models.PembayaranSyahriah{},
+		&models.LaporanTagihanOtomatis{},
+		&models.Kwitansi{},
+		&models.NomorKwitansi{},
+		&models.ImporMutasiBank{},
+		&models.MutasiBank{},
+		&models.TagihanOnline{},
+		&models.ItemTagihanOnline{},
+		&models.RiwayatTagihanOnline{},
+		&models.PermintaanQRIS{},
+		&models.Donatur{},
+		&models.Kampanye{},
+		&models.Dana{},
 		&models.Donasi{},
+		&models.Anggaran{},
 		&models.PemakaianSaldo{},
+		&models.PemakaianDana{},
+		&models.RiwayatPemakaian{},
+		&models.AmbangPersetujuan{},
+		&models.LampiranPemakaian{},
 		&models.RekapSaldo{},
+		&models.RekapDana{},
+		&models.PerubahanRekap{},
+		&models.TutupBuku{},
+		&models.TarifSyahriah{},
+		&models.KeringananSyahriah{},
 		&models.Pengumuman{},
 		&models.Berita{},
 		&models.Fasilitas{},
@@ -96,6 +144,11 @@ func migrateDB(db *gorm.DB) {
 		&models.SosialMedia{},
 		&models.ProgramUnggulan{},
 		&models.LogAktivitas{},
+		&models.Akun{},
+		&models.Jurnal{},
+		&models.JurnalDetail{},
+		&models.TransferRekening{},
+		&models.OpnameKas{},
 	)
 	
 	if err != nil {
@@ -103,6 +156,72 @@ func migrateDB(db *gorm.DB) {
 		// Jangan fatal, biarkan aplikasi tetap running
 	} else {
 		log.Printf("✅ Migration completed in %v", time.Since(start))
+
+		// Seed akun standar untuk posting jurnal otomatis
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.DaftarAkunDefault).Error; err != nil {
+			log.Printf("⚠️ Seed akun warning: %v", err)
+		}
+		if err := services.SeedDanaDefault(db); err != nil {
+			log.Printf("⚠️ Seed dana warning: %v", err)
+		}
+		if jumlah, err := services.SeedRekeningUtama(db); err != nil {
+			log.Printf("⚠️ Seed rekening warning: %v", err)
+		} else if jumlah > 0 {
+			log.Printf("✅ %d baris jurnal kas lama ditandai ke rekening utama", jumlah)
+		}
+
+		// Tarif umum awal agar pembuatan syahriah tetap berjalan sebelum tarif diatur
+		var jumlahTarif int64
+		if err := db.Model(&models.TarifSyahriah{}).Count(&jumlahTarif).Error; err == nil && jumlahTarif == 0 {
+			tarifAwal := models.TarifSyahriah{
+				IDTarif:      uuid.New().String(),
+				NamaTarif:    "Tarif Umum",
+				BerlakuMulai: "2000-01",
+				Nominal:      110000,
+				Keterangan:   "Tarif awal otomatis, silakan sesuaikan",
+			}
+			if err := db.Create(&tarifAwal).Error; err != nil {
+				log.Printf("⚠️ Seed tarif warning: %v", err)
+			}
+		}
+
+		// Syahriah lunas lama dijadikan pembayaran penuh agar status dan rekap mengikuti pembayaran
+		if jumlah, err := services.NewKeuanganService(db).MigrasiPembayaranLama(); err != nil {
+			log.Printf("⚠️ Migrasi pembayaran syahriah warning: %v", err)
+		} else if jumlah > 0 {
+			log.Printf("✅ %d syahriah lunas dimigrasi ke pembayaran", jumlah)
+		}
+		if err := services.MigrasiIndeksKwitansi(db); err != nil {
+			log.Printf("⚠️ Indeks kwitansi warning: %v", err)
+		}
+		if jumlah, err := services.MigrasiKwitansiLama(db); err != nil {
+			log.Printf("⚠️ Migrasi kwitansi lama warning: %v", err)
+		} else if jumlah > 0 {
+			log.Printf("✅ %d pembayaran dan donasi lama diberi kwitansi", jumlah)
+		}
+		if ganda, err := services.PastikanIndeksSyahriah(db); err != nil {
+			log.Printf("⚠️ Indeks unik syahriah warning: %v", err)
+		} else if ganda > 0 {
+			log.Printf("⚠️ %d santri memiliki lebih dari satu syahriah di bulan yang sama, rapikan agar indeks unik bisa dibuat", ganda)
+		}
+		if jumlah, err := services.MigrasiStatusPemakaian(db); err != nil {
+			log.Printf("⚠️ Migrasi status pemakaian warning: %v", err)
+		} else if jumlah > 0 {
+			log.Printf("✅ %d pemakaian saldo lama ditandai dicairkan", jumlah)
+		}
+		if jumlah, err := services.MigrasiRincianPemakaian(db); err != nil {
+			log.Printf("⚠️ Migrasi rincian dana pemakaian warning: %v", err)
+		} else if jumlah > 0 {
+			log.Printf("✅ %d pemakaian saldo dimigrasi ke rincian dana", jumlah)
+		}
+
+		// Transaksi lama dijurnal sebelum rekap dihitung ulang dari jurnal; selama belum berhasil
+		// penghitungan ulang rekap ditolak agar angka historis tidak tertimpa
+		if jumlah, periodes, _, err := services.BackfillJurnal(db, "", "Backfill jurnal transaksi lama saat migrasi"); err != nil {
+			log.Printf("⚠️ Backfill jurnal warning: %v", err)
+		} else if jumlah > 0 {
+			log.Printf("✅ %d transaksi lama dijurnal, rekap %d periode dibangun ulang", jumlah, len(periodes))
+		}
 	}
 }
 
@@ -110,6 +229,12 @@ func GetDB() *gorm.DB {
 	return DB
 }
 
+// MigrasiSelesai mengembalikan channel yang ditutup setelah migrasi database selesai.
+// Pekerjaan latar yang bergantung pada skema dan backfill menunggu channel ini.
+func MigrasiSelesai() <-chan struct{} {
+	return migrasiSelesai
+}
+
 // Function untuk health check database
 func CheckDBHealth() bool {
 	if DB == nil {
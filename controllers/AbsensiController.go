@@ -0,0 +1,500 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AbsensiController struct {
+	db *gorm.DB
+}
+
+func NewAbsensiController(db *gorm.DB) *AbsensiController {
+	return &AbsensiController{db: db}
+}
+
+// Request structs
+type IsiAbsensiRequest struct {
+	IDSantri   string `json:"id_santri" binding:"required"`
+	Status     string `json:"status" binding:"required"` // hadir, izin, sakit, alpa
+	Keterangan string `json:"keterangan"`
+}
+
+type SimpanAbsensiRequest struct {
+	IDKelas       string              `json:"id_kelas" binding:"required"`
+	Tanggal       string              `json:"tanggal"` // format YYYY-MM-DD, kosong = hari ini
+	Catatan       *string             `json:"catatan"`
+	StatusDefault string              `json:"status_default"` // diisi untuk anggota kelas yang tidak disebut, misalnya "hadir"
+	Absensi       []IsiAbsensiRequest `json:"absensi"`
+	Alasan        string              `json:"alasan"` // alasan koreksi jika absensi yang sudah tersimpan berubah
+}
+
+type UbahAbsensiRequest struct {
+	Status     string  `json:"status" binding:"required"`
+	Keterangan *string `json:"keterangan"`
+	Alasan     string  `json:"alasan" binding:"required"`
+}
+
+// Helper function untuk check role admin
+func (ctrl *AbsensiController) isAdmin(c *gin.Context) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		return false
+	}
+	role := userRole.(string)
+	return role == "admin" || role == "super_admin"
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *AbsensiController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// bolehAksesKelas memastikan user adalah admin atau ustadz yang mengajar kelas tersebut
+func (ctrl *AbsensiController) bolehAksesKelas(c *gin.Context, idKelas string) (bool, error) {
+	if ctrl.isAdmin(c) {
+		return true, nil
+	}
+	userID, _ := ctrl.getUserID(c)
+	idKelasDiajar, err := services.KelasUstadz(ctrl.db, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range idKelasDiajar {
+		if id == idKelas {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cekAksesKelas menulis response error jika user tidak boleh mengakses kelas
+func (ctrl *AbsensiController) cekAksesKelas(c *gin.Context, idKelas string) bool {
+	boleh, err := ctrl.bolehAksesKelas(c, idKelas)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek akses kelas: " + err.Error()})
+		return false
+	}
+	if !boleh {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak mengajar di kelas ini"})
+		return false
+	}
+	return true
+}
+
+// parseTanggal membaca tanggal YYYY-MM-DD, kosong berarti hari ini
+func (ctrl *AbsensiController) parseTanggal(s string) (time.Time, error) {
+	if s == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local), nil
+	}
+	tanggal, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("Format tanggal tidak valid. Gunakan format YYYY-MM-DD")
+	}
+	if tanggal.After(time.Now()) {
+		return time.Time{}, errors.New("Tanggal absensi tidak boleh di masa depan")
+	}
+	return tanggal, nil
+}
+
+// rentangBulan membaca bulan YYYY-MM, kosong berarti bulan ini
+func (ctrl *AbsensiController) rentangBulan(bulan string) (time.Time, time.Time, error) {
+	if bulan == "" {
+		bulan = time.Now().Format("2006-01")
+	}
+	dari, err := time.ParseInLocation("2006-01", bulan, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Format bulan tidak valid. Gunakan format YYYY-MM")
+	}
+	return dari, dari.AddDate(0, 1, 0), nil
+}
+
+// GetSesiAbsensi mendapatkan absensi satu kelas pada satu tanggal beserta daftar anggota kelas
+// untuk formulir absensi. Query: id_kelas (wajib), tanggal
+func (ctrl *AbsensiController) GetSesiAbsensi(c *gin.Context) {
+	idKelas := c.Query("id_kelas")
+	if idKelas == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id_kelas wajib diisi"})
+		return
+	}
+	if !ctrl.cekAksesKelas(c, idKelas) {
+		return
+	}
+	tanggal, err := ctrl.parseTanggal(c.Query("tanggal"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	idSantri, err := services.AnggotaKelasPada(ctrl.db, idKelas, tanggal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil anggota kelas: " + err.Error()})
+		return
+	}
+	anggota := []models.Santri{}
+	if len(idSantri) > 0 {
+		if err := ctrl.db.Where("id_santri IN ?", idSantri).Order("nama_lengkap ASC").Find(&anggota).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil anggota kelas: " + err.Error()})
+			return
+		}
+	}
+
+	var sesi *models.SesiAbsensi
+	var ditemukan models.SesiAbsensi
+	err = ctrl.db.Preload("Absensi").Preload("Absensi.Santri").
+		Where("id_kelas = ? AND tanggal = ?", idKelas, tanggal).
+		First(&ditemukan).Error
+	if err == nil {
+		sesi = &ditemukan
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data absensi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    sesi,
+		"anggota": anggota,
+		"tanggal": tanggal.Format("2006-01-02"),
+	})
+}
+
+// GetAllSesiAbsensi mendapatkan daftar sesi satu kelas dalam satu bulan beserta jumlah tiap status.
+// Query: id_kelas (wajib), bulan
+func (ctrl *AbsensiController) GetAllSesiAbsensi(c *gin.Context) {
+	idKelas := c.Query("id_kelas")
+	if idKelas == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id_kelas wajib diisi"})
+		return
+	}
+	if !ctrl.cekAksesKelas(c, idKelas) {
+		return
+	}
+	dari, sampai, err := ctrl.rentangBulan(c.Query("bulan"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var sesi []models.SesiAbsensi
+	if err := ctrl.db.Preload("Absensi").
+		Where("id_kelas = ? AND tanggal >= ? AND tanggal < ?", idKelas, dari, sampai).
+		Order("tanggal ASC").
+		Find(&sesi).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data sesi: " + err.Error()})
+		return
+	}
+
+	data := make([]gin.H, len(sesi))
+	for i, s := range sesi {
+		jumlah := map[models.StatusAbsensi]int{}
+		for _, a := range s.Absensi {
+			jumlah[a.Status]++
+		}
+		data[i] = gin.H{
+			"id_sesi": s.IDSesi,
+			"tanggal": s.Tanggal,
+			"catatan": s.Catatan,
+			"hadir":   jumlah[models.AbsensiHadir],
+			"izin":    jumlah[models.AbsensiIzin],
+			"sakit":   jumlah[models.AbsensiSakit],
+			"alpa":    jumlah[models.AbsensiAlpa],
+			"total":   len(s.Absensi),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+	})
+}
+
+// SimpanAbsensi mengisi absensi satu kelas sekaligus. Perubahan atas absensi yang sudah tersimpan
+// dicatat sebagai koreksi.
+func (ctrl *AbsensiController) SimpanAbsensi(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req SimpanAbsensiRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !ctrl.cekAksesKelas(c, req.IDKelas) {
+		return
+	}
+
+	tanggal, err := ctrl.parseTanggal(req.Tanggal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	statusDefault := models.StatusAbsensi(req.StatusDefault)
+	if statusDefault != "" && !services.ValidStatusAbsensi(statusDefault) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status default tidak valid. Gunakan 'hadir', 'izin', 'sakit' atau 'alpa'"})
+		return
+	}
+	if len(req.Absensi) == 0 && statusDefault == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Isi absensi atau status_default"})
+		return
+	}
+	isi := make([]services.IsiAbsensi, len(req.Absensi))
+	for i, a := range req.Absensi {
+		status := models.StatusAbsensi(a.Status)
+		if !services.ValidStatusAbsensi(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status tidak valid. Gunakan 'hadir', 'izin', 'sakit' atau 'alpa'"})
+			return
+		}
+		isi[i] = services.IsiAbsensi{IDSantri: a.IDSantri, Status: status, Keterangan: a.Keterangan}
+	}
+
+	var kelas models.Kelas
+	if err := ctrl.db.Where("id_kelas = ?", req.IDKelas).First(&kelas).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kelas tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+
+	sesi, jumlahKoreksi, err := services.SimpanAbsensi(ctrl.db, kelas.IDKelas, tanggal, req.Catatan, isi, statusDefault, userID, req.Alasan)
+	if err != nil {
+		if errors.Is(err, services.ErrBukanAnggotaKelas) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan absensi: " + err.Error()})
+		return
+	}
+
+	ctrl.db.Preload("Absensi").Preload("Absensi.Santri").First(sesi, "id_sesi = ?", sesi.IDSesi)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Absensi berhasil disimpan",
+		"data":           sesi,
+		"jumlah_koreksi": jumlahKoreksi,
+	})
+}
+
+// UbahAbsensi mengoreksi absensi satu santri. Alasan koreksi wajib diisi.
+func (ctrl *AbsensiController) UbahAbsensi(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req UbahAbsensiRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	status := models.StatusAbsensi(req.Status)
+	if !services.ValidStatusAbsensi(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status tidak valid. Gunakan 'hadir', 'izin', 'sakit' atau 'alpa'"})
+		return
+	}
+
+	var absensi models.Absensi
+	if err := ctrl.db.Preload("Sesi").Where("id_absensi = ?", c.Param("id")).First(&absensi).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data absensi tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data absensi: " + err.Error()})
+		return
+	}
+	if !ctrl.cekAksesKelas(c, absensi.Sesi.IDKelas) {
+		return
+	}
+
+	keterangan := absensi.Keterangan
+	if req.Keterangan != nil {
+		keterangan = *req.Keterangan
+	}
+	berubah, err := services.UbahAbsensi(ctrl.db, &absensi, status, keterangan, req.Alasan, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengoreksi absensi: " + err.Error()})
+		return
+	}
+
+	pesan := "Absensi berhasil dikoreksi"
+	if !berubah {
+		pesan = "Tidak ada perubahan absensi"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": pesan,
+		"data":    absensi,
+	})
+}
+
+// GetKoreksiAbsensi mendapatkan riwayat koreksi satu absensi
+func (ctrl *AbsensiController) GetKoreksiAbsensi(c *gin.Context) {
+	var absensi models.Absensi
+	if err := ctrl.db.Preload("Sesi").Where("id_absensi = ?", c.Param("id")).First(&absensi).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data absensi tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data absensi: " + err.Error()})
+		return
+	}
+	if !ctrl.cekAksesKelas(c, absensi.Sesi.IDKelas) {
+		return
+	}
+
+	var koreksi []models.KoreksiAbsensi
+	if err := ctrl.db.Preload("Pengoreksi").
+		Where("id_absensi = ?", absensi.IDAbsensi).
+		Order("waktu_koreksi ASC").
+		Find(&koreksi).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil riwayat koreksi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    koreksi,
+		"absensi": absensi,
+	})
+}
+
+// GetRekapAbsensi mendapatkan rekap kehadiran bulanan setiap santri di satu kelas.
+// Query: id_kelas (wajib), bulan
+func (ctrl *AbsensiController) GetRekapAbsensi(c *gin.Context) {
+	idKelas := c.Query("id_kelas")
+	if idKelas == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id_kelas wajib diisi"})
+		return
+	}
+	if !ctrl.cekAksesKelas(c, idKelas) {
+		return
+	}
+	dari, sampai, err := ctrl.rentangBulan(c.Query("bulan"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Anggota kelas kapan pun dalam bulan tersebut
+	var idSantri []string
+	if err := ctrl.db.Model(&models.AnggotaKelas{}).
+		Where("id_kelas = ? AND tanggal_masuk < ? AND (tanggal_keluar IS NULL OR tanggal_keluar >= ?)", idKelas, sampai, dari).
+		Distinct().
+		Pluck("id_santri", &idSantri).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil anggota kelas: " + err.Error()})
+		return
+	}
+	var santri []models.Santri
+	if len(idSantri) > 0 {
+		if err := ctrl.db.Where("id_santri IN ?", idSantri).Order("nama_lengkap ASC").Find(&santri).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data santri: " + err.Error()})
+			return
+		}
+	}
+
+	var jumlahSesi int64
+	if err := ctrl.db.Model(&models.SesiAbsensi{}).
+		Where("id_kelas = ? AND tanggal >= ? AND tanggal < ?", idKelas, dari, sampai).
+		Count(&jumlahSesi).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data sesi: " + err.Error()})
+		return
+	}
+	// Rekap hanya menghitung sesi kelas ini
+	rekap, err := services.HitungRekapAbsensi(ctrl.db, idKelas, idSantri, dari, sampai)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung rekap absensi: " + err.Error()})
+		return
+	}
+
+	data := make([]gin.H, len(santri))
+	for i, s := range santri {
+		data[i] = gin.H{
+			"santri": s,
+			"rekap":  rekap[s.IDSantri],
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        data,
+		"bulan":       dari.Format("2006-01"),
+		"jumlah_sesi": jumlahSesi,
+	})
+}
+
+// GetRekapAbsensiSantri mendapatkan rekap kehadiran seorang santri per bulan dalam satu tahun. Query: tahun
+func (ctrl *AbsensiController) GetRekapAbsensiSantri(c *gin.Context) {
+	tahun := time.Now().Year()
+	if s := c.Query("tahun"); s != "" {
+		t, err := strconv.Atoi(s)
+		if err != nil || t < 2000 || t > 2100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tahun tidak valid"})
+			return
+		}
+		tahun = t
+	}
+
+	var santri models.Santri
+	if err := ctrl.db.Where("id_santri = ?", c.Param("id")).First(&santri).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Santri tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data santri: " + err.Error()})
+		return
+	}
+
+	rekap, err := services.RekapAbsensiBulanan(ctrl.db, santri.IDSantri, tahun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung rekap absensi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   rekap,
+		"santri": santri,
+		"tahun":  tahun,
+	})
+}
+
+// GetPeringatanAlpa mendapatkan santri aktif yang berulang kali alpa.
+// Query: hari (rentang hari ke belakang, default 30), ambang (jumlah alpa minimal, default 3)
+func (ctrl *AbsensiController) GetPeringatanAlpa(c *gin.Context) {
+	hari, _ := strconv.Atoi(c.DefaultQuery("hari", "30"))
+	ambang, _ := strconv.Atoi(c.DefaultQuery("ambang", "3"))
+	if hari < 1 || hari > 366 {
+		hari = 30
+	}
+	if ambang < 1 {
+		ambang = 3
+	}
+
+	now := time.Now()
+	sejak := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -hari)
+	peringatan, err := services.DaftarPeringatanAlpa(ctrl.db, sejak, ambang)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil peringatan alpa: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   peringatan,
+		"sejak":  sejak.Format("2006-01-02"),
+		"ambang": ambang,
+		"total":  len(peringatan),
+	})
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"tpq_asysyafii/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AmbangPersetujuanController struct {
+	db *gorm.DB
+}
+
+func NewAmbangPersetujuanController(db *gorm.DB) *AmbangPersetujuanController {
+	return &AmbangPersetujuanController{db: db}
+}
+
+// Request structs
+type AmbangPersetujuanRequest struct {
+	NominalMinimal *float64        `json:"nominal_minimal" binding:"required"`
+	PeranPenyetuju models.UserRole `json:"peran_penyetuju" binding:"required"`
+	Keterangan     string          `json:"keterangan"`
+}
+
+// validasiAmbang memeriksa isi request ambang persetujuan
+func (ctrl *AmbangPersetujuanController) validasiAmbang(req AmbangPersetujuanRequest) string {
+	if *req.NominalMinimal < 0 {
+		return "Nominal minimal tidak boleh negatif"
+	}
+	if req.PeranPenyetuju != models.RoleAdmin && req.PeranPenyetuju != models.RoleSuperAdmin {
+		return "Peran penyetuju tidak valid. Gunakan 'admin' atau 'super_admin'"
+	}
+	return ""
+}
+
+// cekNominalGanda memastikan belum ada ambang lain dengan nominal minimal yang sama
+func (ctrl *AmbangPersetujuanController) cekNominalGanda(ambang models.AmbangPersetujuan) bool {
+	var jumlah int64
+	ctrl.db.Model(&models.AmbangPersetujuan{}).
+		Where("nominal_minimal = ? AND id_ambang <> ?", ambang.NominalMinimal, ambang.IDAmbang).
+		Count(&jumlah)
+	return jumlah > 0
+}
+
+// GetAllAmbang mendapatkan semua ambang persetujuan, urut dari nominal terkecil
+func (ctrl *AmbangPersetujuanController) GetAllAmbang(c *gin.Context) {
+	var ambang []models.AmbangPersetujuan
+	if err := ctrl.db.Order("nominal_minimal ASC").Find(&ambang).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil ambang persetujuan: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": ambang,
+	})
+}
+
+// CreateAmbang menambah ambang persetujuan. Hanya berlaku untuk pengajuan berikutnya.
+func (ctrl *AmbangPersetujuanController) CreateAmbang(c *gin.Context) {
+	var req AmbangPersetujuanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if pesan := ctrl.validasiAmbang(req); pesan != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": pesan})
+		return
+	}
+
+	ambang := models.AmbangPersetujuan{
+		IDAmbang:       uuid.New().String(),
+		NominalMinimal: *req.NominalMinimal,
+		PeranPenyetuju: req.PeranPenyetuju,
+		Keterangan:     req.Keterangan,
+	}
+	if ctrl.cekNominalGanda(ambang) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ambang dengan nominal minimal yang sama sudah ada"})
+		return
+	}
+
+	if err := ctrl.db.Create(&ambang).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat ambang persetujuan: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Ambang persetujuan berhasil dibuat",
+		"data":    ambang,
+	})
+}
+
+// UpdateAmbang mengubah ambang persetujuan. Pengajuan yang sudah ada tetap memakai peran penyetuju saat diajukan.
+func (ctrl *AmbangPersetujuanController) UpdateAmbang(c *gin.Context) {
+	var req AmbangPersetujuanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if pesan := ctrl.validasiAmbang(req); pesan != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": pesan})
+		return
+	}
+
+	var ambang models.AmbangPersetujuan
+	if err := ctrl.db.Where("id_ambang = ?", c.Param("id")).First(&ambang).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ambang persetujuan tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil ambang persetujuan: " + err.Error()})
+		return
+	}
+
+	ambang.NominalMinimal = *req.NominalMinimal
+	ambang.PeranPenyetuju = req.PeranPenyetuju
+	ambang.Keterangan = req.Keterangan
+	if ctrl.cekNominalGanda(ambang) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ambang dengan nominal minimal yang sama sudah ada"})
+		return
+	}
+
+	if err := ctrl.db.Save(&ambang).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate ambang persetujuan: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ambang persetujuan berhasil diupdate",
+		"data":    ambang,
+	})
+}
+
+// DeleteAmbang menghapus ambang persetujuan
+func (ctrl *AmbangPersetujuanController) DeleteAmbang(c *gin.Context) {
+	result := ctrl.db.Where("id_ambang = ?", c.Param("id")).Delete(&models.AmbangPersetujuan{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus ambang persetujuan: " + result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ambang persetujuan tidak ditemukan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ambang persetujuan berhasil dihapus",
+	})
+}
@@ -0,0 +1,357 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AnggaranController struct {
+	db *gorm.DB
+}
+
+func NewAnggaranController(db *gorm.DB) *AnggaranController {
+	return &AnggaranController{db: db}
+}
+
+// Request structs
+type AnggaranRequest struct {
+	Tahun         int                  `json:"tahun" binding:"required"`
+	Bulan         int                  `json:"bulan"` // 1-12, 0 = anggaran tahunan
+	TipePemakaian models.TipePemakaian `json:"tipe_pemakaian" binding:"required"`
+	SubKategori   string               `json:"sub_kategori" binding:"required"`
+	Nominal       float64              `json:"nominal" binding:"required"`
+	Keterangan    string               `json:"keterangan"`
+}
+
+type SalinAnggaranRequest struct {
+	DariTahun int `json:"dari_tahun" binding:"required"`
+	KeTahun   int `json:"ke_tahun" binding:"required"`
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *AnggaranController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// validasiAnggaran memeriksa isi request anggaran
+func (ctrl *AnggaranController) validasiAnggaran(req AnggaranRequest) string {
+	if req.Tahun < 2000 || req.Tahun > 2100 {
+		return "Tahun tidak valid"
+	}
+	if req.Bulan < 0 || req.Bulan > 12 {
+		return "Bulan harus 1-12, atau 0 untuk anggaran tahunan"
+	}
+	switch req.TipePemakaian {
+	case models.PemakaianOperasional, models.PemakaianInvestasi, models.PemakaianLainnya:
+	default:
+		return "Tipe pemakaian tidak valid. Gunakan 'operasional', 'investasi', atau 'lainnya'"
+	}
+	if strings.TrimSpace(req.SubKategori) == "" {
+		return "Sub kategori wajib diisi"
+	}
+	if req.Nominal <= 0 {
+		return "Nominal harus lebih besar dari 0"
+	}
+	return ""
+}
+
+// cekPosGanda memastikan belum ada pos dengan periode, tipe dan sub kategori yang sama
+func (ctrl *AnggaranController) cekPosGanda(anggaran models.Anggaran) bool {
+	var jumlah int64
+	ctrl.db.Model(&models.Anggaran{}).
+		Where("tahun = ? AND bulan = ? AND tipe_pemakaian = ? AND sub_kategori = ? AND id_anggaran <> ?",
+			anggaran.Tahun, anggaran.Bulan, anggaran.TipePemakaian, anggaran.SubKategori, anggaran.IDAnggaran).
+		Count(&jumlah)
+	return jumlah > 0
+}
+
+// GetAllAnggaran mendapatkan pos anggaran beserta realisasinya. Query: tahun (default tahun ini), bulan, tipe_pemakaian.
+func (ctrl *AnggaranController) GetAllAnggaran(c *gin.Context) {
+	tahun, err := strconv.Atoi(c.DefaultQuery("tahun", strconv.Itoa(time.Now().Year())))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tahun tidak valid"})
+		return
+	}
+
+	query := ctrl.db.Where("tahun = ?", tahun)
+	if bulan := c.Query("bulan"); bulan != "" {
+		query = query.Where("bulan = ?", bulan)
+	}
+	if tipe := c.Query("tipe_pemakaian"); tipe != "" {
+		query = query.Where("tipe_pemakaian = ?", tipe)
+	}
+
+	var anggaran []models.Anggaran
+	if err := query.Order("tipe_pemakaian ASC, sub_kategori ASC, bulan ASC").Find(&anggaran).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data anggaran: " + err.Error()})
+		return
+	}
+
+	ids := make([]string, len(anggaran))
+	for i, a := range anggaran {
+		ids[i] = a.IDAnggaran
+	}
+	realisasi, err := services.RealisasiAnggaran(ctrl.db, ids, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung realisasi anggaran: " + err.Error()})
+		return
+	}
+
+	data := make([]gin.H, len(anggaran))
+	for i, a := range anggaran {
+		data[i] = gin.H{
+			"anggaran":  a,
+			"realisasi": realisasi[a.IDAnggaran],
+			"sisa":      a.Nominal - realisasi[a.IDAnggaran],
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+	})
+}
+
+// CreateAnggaran membuat pos anggaran baru
+func (ctrl *AnggaranController) CreateAnggaran(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req AnggaranRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if pesan := ctrl.validasiAnggaran(req); pesan != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": pesan})
+		return
+	}
+
+	anggaran := models.Anggaran{
+		IDAnggaran:    uuid.New().String(),
+		Tahun:         req.Tahun,
+		Bulan:         req.Bulan,
+		TipePemakaian: req.TipePemakaian,
+		SubKategori:   strings.TrimSpace(req.SubKategori),
+		Nominal:       req.Nominal,
+		Keterangan:    req.Keterangan,
+		DibuatOleh:    &adminID,
+	}
+	if ctrl.cekPosGanda(anggaran) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pos anggaran dengan periode, tipe dan sub kategori yang sama sudah ada"})
+		return
+	}
+
+	if err := ctrl.db.Create(&anggaran).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat anggaran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Anggaran berhasil dibuat",
+		"data":    anggaran,
+	})
+}
+
+// UpdateAnggaran mengubah pos anggaran. Periode dan tipe tidak bisa diubah jika pos sudah punya realisasi.
+func (ctrl *AnggaranController) UpdateAnggaran(c *gin.Context) {
+	var req AnggaranRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if pesan := ctrl.validasiAnggaran(req); pesan != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": pesan})
+		return
+	}
+
+	var anggaran models.Anggaran
+	if err := ctrl.db.Where("id_anggaran = ?", c.Param("id")).First(&anggaran).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Anggaran tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data anggaran: " + err.Error()})
+		return
+	}
+
+	if req.Tahun != anggaran.Tahun || req.Bulan != anggaran.Bulan || req.TipePemakaian != anggaran.TipePemakaian {
+		var terpakai int64
+		ctrl.db.Model(&models.PemakaianSaldo{}).Where("id_anggaran = ?", anggaran.IDAnggaran).Count(&terpakai)
+		if terpakai > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Periode dan tipe pos anggaran yang sudah memiliki pemakaian tidak dapat diubah"})
+			return
+		}
+	}
+
+	anggaran.Tahun = req.Tahun
+	anggaran.Bulan = req.Bulan
+	anggaran.TipePemakaian = req.TipePemakaian
+	anggaran.SubKategori = strings.TrimSpace(req.SubKategori)
+	anggaran.Nominal = req.Nominal
+	anggaran.Keterangan = req.Keterangan
+	if ctrl.cekPosGanda(anggaran) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pos anggaran dengan periode, tipe dan sub kategori yang sama sudah ada"})
+		return
+	}
+
+	if err := ctrl.db.Save(&anggaran).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate anggaran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Anggaran berhasil diupdate",
+		"data":    anggaran,
+	})
+}
+
+// DeleteAnggaran menghapus pos anggaran yang belum memiliki pemakaian
+func (ctrl *AnggaranController) DeleteAnggaran(c *gin.Context) {
+	id := c.Param("id")
+	var anggaran models.Anggaran
+	if err := ctrl.db.Where("id_anggaran = ?", id).First(&anggaran).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Anggaran tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data anggaran: " + err.Error()})
+		return
+	}
+
+	var terpakai int64
+	ctrl.db.Model(&models.PemakaianSaldo{}).Where("id_anggaran = ?", id).Count(&terpakai)
+	if terpakai > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pos anggaran sudah memiliki pemakaian dan tidak dapat dihapus"})
+		return
+	}
+
+	if err := ctrl.db.Where("id_anggaran = ?", id).Delete(&models.Anggaran{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus anggaran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Anggaran berhasil dihapus",
+	})
+}
+
+// SalinAnggaran menyalin seluruh pos anggaran satu tahun ke tahun lain sebagai draf RAPB.
+// Pos yang sudah ada di tahun tujuan dilewati.
+func (ctrl *AnggaranController) SalinAnggaran(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req SalinAnggaranRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DariTahun == req.KeTahun || req.KeTahun < 2000 || req.KeTahun > 2100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tahun tujuan tidak valid"})
+		return
+	}
+
+	var sumber []models.Anggaran
+	if err := ctrl.db.Where("tahun = ?", req.DariTahun).Find(&sumber).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data anggaran: " + err.Error()})
+		return
+	}
+
+	dibuat, dilewati := 0, 0
+	for _, a := range sumber {
+		baru := models.Anggaran{
+			IDAnggaran:    uuid.New().String(),
+			Tahun:         req.KeTahun,
+			Bulan:         a.Bulan,
+			TipePemakaian: a.TipePemakaian,
+			SubKategori:   a.SubKategori,
+			Nominal:       a.Nominal,
+			Keterangan:    a.Keterangan,
+			DibuatOleh:    &adminID,
+		}
+		if ctrl.cekPosGanda(baru) {
+			dilewati++
+			continue
+		}
+		if err := ctrl.db.Create(&baru).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyalin anggaran: " + err.Error()})
+			return
+		}
+		dibuat++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Anggaran berhasil disalin",
+		"dibuat":   dibuat,
+		"dilewati": dilewati,
+	})
+}
+
+// GetLaporanAnggaran mendapatkan laporan anggaran vs realisasi beserta selisihnya.
+// Query: tahun (default tahun ini), bulan (opsional, hanya pos bulanan bulan tersebut).
+func (ctrl *AnggaranController) GetLaporanAnggaran(c *gin.Context) {
+	tahun, err := strconv.Atoi(c.DefaultQuery("tahun", strconv.Itoa(time.Now().Year())))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tahun tidak valid"})
+		return
+	}
+	bulan, err := strconv.Atoi(c.DefaultQuery("bulan", "0"))
+	if err != nil || bulan < 0 || bulan > 12 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bulan tidak valid"})
+		return
+	}
+
+	laporan, err := services.HitungLaporanAnggaran(ctrl.db, tahun, bulan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung laporan anggaran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": laporan,
+	})
+}
+
+// CekAnggaran menampilkan posisi pos anggaran sebelum pemakaian diajukan.
+// Query: nominal, id_pemakaian (opsional, saat mengubah pemakaian yang sudah ada).
+func (ctrl *AnggaranController) CekAnggaran(c *gin.Context) {
+	nominal, err := strconv.ParseFloat(c.Query("nominal"), 64)
+	if err != nil || nominal < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nominal tidak valid"})
+		return
+	}
+
+	status, err := services.CekAnggaran(ctrl.db, c.Param("id"), nominal, c.Query("id_pemakaian"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Anggaran tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek anggaran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": status,
+	})
+}
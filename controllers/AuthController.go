@@ -7,12 +7,19 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 	"tpq_asysyafii/config" 
 	"tpq_asysyafii/models"
 	"tpq_asysyafii/utils"
 )
 
 func generateCustomID(role models.UserRole) (string, error) {
+	return generateCustomIDTx(config.DB, role)
+}
+
+// generateCustomIDTx sama dengan generateCustomID tetapi membaca ID terakhir lewat db/transaksi yang diberikan,
+// sehingga user yang baru dibuat dalam transaksi yang sama ikut terhitung
+func generateCustomIDTx(db *gorm.DB, role models.UserRole) (string, error) {
 	var prefix string
 	switch role {
 	case models.RoleAdmin:
@@ -21,13 +28,15 @@ func generateCustomID(role models.UserRole) (string, error) {
 		prefix = "SA"
 	case models.RoleWali:
 		prefix = "W"
+	case models.RoleUstadz:
+		prefix = "U"
 	default:
 		return "", fmt.Errorf("role tidak valid")
 	}
 
 	// Cari ID terakhir untuk role tersebut
 	var lastUser models.User
-	err := config.DB.Where("id_user LIKE ?", prefix + "%").Order("id_user DESC").First(&lastUser).Error
+	err := db.Where("id_user LIKE ?", prefix + "%").Order("id_user DESC").First(&lastUser).Error
 	
 	var nextNumber int
 	if err != nil {
@@ -53,6 +62,8 @@ func generateCustomID(role models.UserRole) (string, error) {
 		customID = fmt.Sprintf("A%03d", nextNumber)
 	case models.RoleWali:
 		customID = fmt.Sprintf("W%03d", nextNumber)
+	case models.RoleUstadz:
+		customID = fmt.Sprintf("U%03d", nextNumber)
 	}
 
 	return customID, nil
@@ -80,7 +91,7 @@ func RegisterUser(c *gin.Context) {
 
 	// Default role = wali
 	role := models.RoleWali
-	if input.Role == string(models.RoleAdmin) || input.Role == string(models.RoleSuperAdmin) {
+	if input.Role == string(models.RoleAdmin) || input.Role == string(models.RoleSuperAdmin) || input.Role == string(models.RoleUstadz) {
 		role = models.UserRole(input.Role)
 	}
 
@@ -277,4 +288,16 @@ func GetWali(c *gin.Context) {
 	}
 	
 	c.JSON(http.StatusOK, wali)
-}
\ No newline at end of file
+}
+
+// GetUstadz mendapatkan semua user dengan role ustadz untuk dipilih sebagai wali kelas atau pengajar
+func GetUstadz(c *gin.Context) {
+	var ustadz []models.User
+
+	if err := config.DB.Where("role = ?", models.RoleUstadz).Order("nama_lengkap ASC").Find(&ustadz).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal mengambil data ustadz"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ustadz)
+}
@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DanaController struct {
+	db *gorm.DB
+}
+
+func NewDanaController(db *gorm.DB) *DanaController {
+	return &DanaController{db: db}
+}
+
+// Request structs
+type CreateDanaRequest struct {
+	KodeDana           string                 `json:"kode_dana" binding:"required"`
+	NamaDana           string                 `json:"nama_dana" binding:"required"`
+	PemakaianDiizinkan []models.TipePemakaian `json:"pemakaian_diizinkan"` // kosong = semua tipe pemakaian
+	Keterangan         string                 `json:"keterangan"`
+	Urutan             int                    `json:"urutan"`
+}
+
+type UpdateDanaRequest struct {
+	NamaDana           *string                 `json:"nama_dana"`
+	PemakaianDiizinkan *[]models.TipePemakaian `json:"pemakaian_diizinkan"`
+	Keterangan         *string                 `json:"keterangan"`
+	Aktif              *bool                   `json:"aktif"`
+	Urutan             *int                    `json:"urutan"`
+}
+
+type DanaResponse struct {
+	models.Dana
+	PemakaianDiizinkan []models.TipePemakaian `json:"pemakaian_diizinkan"`
+	Saldo              float64                `json:"saldo"`
+}
+
+var polaKodeDana = regexp.MustCompile(`^[a-z][a-z0-9_]{1,29}$`)
+
+// gabungTipePemakaian memvalidasi tipe pemakaian lalu menggabungkannya untuk disimpan
+func (ctrl *DanaController) gabungTipePemakaian(tipe []models.TipePemakaian) (string, bool) {
+	teks := make([]string, 0, len(tipe))
+	for _, t := range tipe {
+		if t != models.PemakaianOperasional && t != models.PemakaianInvestasi && t != models.PemakaianLainnya {
+			return "", false
+		}
+		teks = append(teks, string(t))
+	}
+	return strings.Join(teks, ","), true
+}
+
+// daftarDanaDenganSaldo menyusun daftar dana beserta saldo kas berjalannya
+func (ctrl *DanaController) daftarDanaDenganSaldo(aktifSaja bool) ([]DanaResponse, error) {
+	dana, err := services.DaftarDana(ctrl.db, aktifSaja)
+	if err != nil {
+		return nil, err
+	}
+	saldo, err := services.SaldoDana(ctrl.db)
+	if err != nil {
+		return nil, err
+	}
+
+	hasil := make([]DanaResponse, len(dana))
+	for i, d := range dana {
+		hasil[i] = DanaResponse{
+			Dana:               d,
+			PemakaianDiizinkan: services.TipeDiizinkan(d),
+			Saldo:              saldo[d.KodeDana],
+		}
+	}
+	return hasil, nil
+}
+
+// GetAllDana mendapatkan semua dana beserta saldonya, termasuk dana nonaktif
+func (ctrl *DanaController) GetAllDana(c *gin.Context) {
+	dana, err := ctrl.daftarDanaDenganSaldo(c.Query("aktif") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data dana: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": dana,
+	})
+}
+
+// GetDanaPublic mendapatkan dana aktif beserta saldonya untuk public
+func (ctrl *DanaController) GetDanaPublic(c *gin.Context) {
+	dana, err := ctrl.daftarDanaDenganSaldo(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data dana"})
+		return
+	}
+
+	publicData := make([]gin.H, len(dana))
+	for i, d := range dana {
+		publicData[i] = gin.H{
+			"kode_dana":           d.KodeDana,
+			"nama_dana":           d.NamaDana,
+			"keterangan":          d.Keterangan,
+			"pemakaian_diizinkan": d.PemakaianDiizinkan,
+			"saldo":               d.Saldo,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": publicData,
+	})
+}
+
+// CreateDana menambah dana baru beserta akun kas dan akun pendapatannya
+func (ctrl *DanaController) CreateDana(c *gin.Context) {
+	var req CreateDanaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !polaKodeDana.MatchString(req.KodeDana) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode dana hanya boleh huruf kecil, angka dan garis bawah (2-30 karakter), diawali huruf"})
+		return
+	}
+	pemakaianDiizinkan, ok := ctrl.gabungTipePemakaian(req.PemakaianDiizinkan)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tipe pemakaian tidak valid. Gunakan 'operasional', 'investasi', atau 'lainnya'"})
+		return
+	}
+
+	var jumlah int64
+	if err := ctrl.db.Model(&models.Dana{}).Where("kode_dana = ?", req.KodeDana).Count(&jumlah).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek kode dana: " + err.Error()})
+		return
+	}
+	if jumlah > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kode dana sudah digunakan"})
+		return
+	}
+
+	dana := models.Dana{
+		KodeDana:           req.KodeDana,
+		NamaDana:           req.NamaDana,
+		PemakaianDiizinkan: pemakaianDiizinkan,
+		Keterangan:         req.Keterangan,
+		Aktif:              true,
+		Urutan:             req.Urutan,
+	}
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		return services.BuatDana(tx, &dana)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat dana: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Dana berhasil dibuat",
+		"data":    dana,
+	})
+}
+
+// UpdateDana mengubah nama, pembatasan pemakaian, keterangan, status aktif atau urutan dana.
+// Pembatasan hanya dicek untuk pengajuan dan pencairan berikutnya.
+func (ctrl *DanaController) UpdateDana(c *gin.Context) {
+	var req UpdateDanaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var dana models.Dana
+	if err := ctrl.db.Where("kode_dana = ?", c.Param("kode")).First(&dana).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dana tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data dana: " + err.Error()})
+		return
+	}
+
+	if req.NamaDana != nil {
+		if *req.NamaDana == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nama dana tidak boleh kosong"})
+			return
+		}
+		dana.NamaDana = *req.NamaDana
+	}
+	if req.PemakaianDiizinkan != nil {
+		pemakaianDiizinkan, ok := ctrl.gabungTipePemakaian(*req.PemakaianDiizinkan)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tipe pemakaian tidak valid. Gunakan 'operasional', 'investasi', atau 'lainnya'"})
+			return
+		}
+		dana.PemakaianDiizinkan = pemakaianDiizinkan
+	}
+	if req.Keterangan != nil {
+		dana.Keterangan = *req.Keterangan
+	}
+	if req.Aktif != nil {
+		// Syahriah dan donasi dipakai posting otomatis sehingga tidak boleh dinonaktifkan
+		if !*req.Aktif && (dana.KodeDana == models.KodeDanaSyahriah || dana.KodeDana == models.KodeDanaDonasi) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dana " + dana.NamaDana + " tidak dapat dinonaktifkan"})
+			return
+		}
+		dana.Aktif = *req.Aktif
+	}
+	if req.Urutan != nil {
+		dana.Urutan = *req.Urutan
+	}
+
+	if err := ctrl.db.Save(&dana).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate dana: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Dana berhasil diupdate",
+		"data":    dana,
+	})
+}
+
+// DeleteDana menghapus dana yang belum pernah dipakai. Dana yang sudah punya transaksi cukup dinonaktifkan.
+func (ctrl *DanaController) DeleteDana(c *gin.Context) {
+	var dana models.Dana
+	if err := ctrl.db.Where("kode_dana = ?", c.Param("kode")).First(&dana).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dana tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data dana: " + err.Error()})
+		return
+	}
+	if dana.KodeDana == models.KodeDanaSyahriah || dana.KodeDana == models.KodeDanaDonasi {
+		c.JSON(http.StatusConflict, gin.H{"error": "Dana " + dana.NamaDana + " tidak dapat dihapus"})
+		return
+	}
+
+	var dipakai int64
+	cek := []*gorm.DB{
+		ctrl.db.Model(&models.JurnalDetail{}).Where("kode_akun IN ?", []string{dana.KodeAkunKas, dana.KodeAkunPendapatan}),
+		ctrl.db.Model(&models.Donasi{}).Where("kode_dana = ?", dana.KodeDana),
+		ctrl.db.Model(&models.PemakaianDana{}).Where("kode_dana = ?", dana.KodeDana),
+	}
+	for _, query := range cek {
+		var jumlah int64
+		if err := query.Count(&jumlah).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek pemakaian dana: " + err.Error()})
+			return
+		}
+		dipakai += jumlah
+	}
+	if dipakai > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Dana sudah memiliki transaksi, nonaktifkan dana ini sebagai gantinya"})
+		return
+	}
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("kode_dana = ?", dana.KodeDana).Delete(&models.RekapDana{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&dana).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus dana: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Dana berhasil dihapus",
+	})
+}
@@ -1,13 +1,14 @@
 package controllers
 
 import (
+	"database/sql"
 	"errors"
-	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -27,12 +28,30 @@ type CreateDonasiRequest struct {
 	NamaDonatur string  `json:"nama_donatur"` // Remove required binding
 	NoTelp      string  `json:"no_telp"`
 	Nominal     float64 `json:"nominal" binding:"required,gt=0"`
+	IDDonatur   *string `json:"id_donatur"`  // kosong = dicari dari no_telp
+	IDKampanye  *string `json:"id_kampanye"` // donasi terikat kampanye
+	KodeDana    string  `json:"kode_dana"`   // kosong = dana donasi umum
+	IDRekening  *string `json:"id_rekening"` // rekening penerima, kosong = rekening utama
+	Anonim      bool    `json:"anonim"`
+}
+
+// QRISDonasiRequest adalah data donatur umum yang meminta QRIS donasi
+type QRISDonasiRequest struct {
+	NamaDonatur string  `json:"nama_donatur" binding:"max=100"`
+	NoTelp      string  `json:"no_telp" binding:"max=20"`
+	Nominal     float64 `json:"nominal" binding:"required,gt=0"`
+	IDKampanye  *string `json:"id_kampanye"`
 }
 
 type UpdateDonasiRequest struct {
 	NamaDonatur string  `json:"nama_donatur"`
 	NoTelp      string  `json:"no_telp"`
 	Nominal     float64 `json:"nominal" binding:"gt=0"`
+	IDDonatur   *string `json:"id_donatur"`  // string kosong = ditautkan ulang dari no_telp
+	IDKampanye  *string `json:"id_kampanye"` // string kosong = lepas dari kampanye
+	KodeDana    *string `json:"kode_dana"`
+	IDRekening  *string `json:"id_rekening"`
+	Anonim      *bool   `json:"anonim"`
 }
 
 type DonasiSummary struct {
@@ -79,13 +98,13 @@ func (ctrl *DonasiController) getUserID(c *gin.Context) (string, bool) {
 func (ctrl *DonasiController) formatDonasiPublic(donasi models.Donasi) DonasiPublicResponse {
 	// Format nama donatur
 	namaDonatur := donasi.NamaDonatur
-	if namaDonatur == "" {
-		namaDonatur = "Hamba Allah"
+	if namaDonatur == "" || donasi.Anonim {
+		namaDonatur = services.NamaAnonim
 	}
 
 	// Format nomor telepon
 	noTelp := donasi.NoTelp
-	if noTelp == "" {
+	if noTelp == "" || donasi.Anonim {
 		noTelp = "dirahasiakan"
 	}
 
@@ -102,8 +121,8 @@ func (ctrl *DonasiController) formatDonasiPublic(donasi models.Donasi) DonasiPub
 func (ctrl *DonasiController) formatDonasiTerbaruPublic(donasi models.Donasi) DonasiTerbaruPublicResponse {
 	// Format nama donatur
 	namaDonatur := donasi.NamaDonatur
-	if namaDonatur == "" {
-		namaDonatur = "Hamba Allah"
+	if namaDonatur == "" || donasi.Anonim {
+		namaDonatur = services.NamaAnonim
 	}
 
 	return DonasiTerbaruPublicResponse{
@@ -113,12 +132,24 @@ func (ctrl *DonasiController) formatDonasiTerbaruPublic(donasi models.Donasi) Do
 	}
 }
 
-func (ctrl *DonasiController) updateRekapOtomatis(transaksiTime time.Time) {
-	rekapController := NewRekapController(ctrl.db)
-	if err := rekapController.UpdateRekapOtomatis(transaksiTime); err != nil {
-		// Log error tapi jangan gagalkan operasi utama
-		fmt.Printf("Gagal update rekap: %v\n", err)
+// tautanDonasi memvalidasi donatur dan kampanye yang dipilih. String kosong berarti tanpa tautan.
+// Kampanye yang sudah selesai atau dibatalkan tidak bisa menerima donasi baru.
+func (ctrl *DonasiController) tautanDonasi(idDonatur, idKampanye *string) (*string, *string, error) {
+	var donatur, kampanye *string
+	if idDonatur != nil && *idDonatur != "" {
+		var d models.Donatur
+		if err := ctrl.db.Where("id_donatur = ?", *idDonatur).First(&d).Error; err != nil {
+			return nil, nil, errors.New("donatur tidak ditemukan")
+		}
+		donatur = &d.IDDonatur
+	}
+	if idKampanye != nil && *idKampanye != "" {
+		if err := services.CekKampanyeAktif(ctrl.db, *idKampanye); err != nil {
+			return nil, nil, err
+		}
+		kampanye = idKampanye
 	}
+	return donatur, kampanye, nil
 }
 
 func (ctrl *DonasiController) CreateDonasi(c *gin.Context) {
@@ -147,9 +178,22 @@ func (ctrl *DonasiController) CreateDonasi(c *gin.Context) {
 		return
 	}
 
+	idDonatur, idKampanye, err := ctrl.tautanDonasi(req.IDDonatur, req.IDKampanye)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Set nama donatur default jika kosong
 	if req.NamaDonatur == "" {
-		req.NamaDonatur = "Hamba Allah"
+		req.NamaDonatur = services.NamaAnonim
+	}
+	if req.KodeDana == "" {
+		req.KodeDana = models.KodeDanaDonasi
+	}
+	if err := services.CekDanaPenerimaan(ctrl.db, req.KodeDana); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Buat data donasi
@@ -158,24 +202,39 @@ func (ctrl *DonasiController) CreateDonasi(c *gin.Context) {
 		NamaDonatur: req.NamaDonatur,
 		NoTelp:      req.NoTelp,
 		Nominal:     req.Nominal,
+		IDDonatur:   idDonatur,
+		IDKampanye:  idKampanye,
+		KodeDana:    req.KodeDana,
+		IDRekening:  req.IDRekening,
+		Anonim:      req.Anonim,
 		DicatatOleh: userID,
 		WaktuCatat:  time.Now(),
 	}
 
-	// Simpan ke database
-	if err := ctrl.db.Create(&donasi).Error; err != nil {
+	// Simpan donasi, jurnal dan rekap dalam satu transaksi
+	if _, err := services.NewKeuanganService(ctrl.db).CreateDonasi(&donasi, userID); err != nil {
+		if errors.Is(err, services.ErrPeriodeDitutup) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrRekeningTidakValid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat donasi: " + err.Error()})
 		return
 	}
 
 	// Preload admin data untuk response
-	ctrl.db.Preload("Admin").First(&donasi, "id_donasi = ?", donasi.IDDonasi)
+	ctrl.db.Preload("Admin").Preload("Donatur").Preload("Kampanye").Preload("Rekening").First(&donasi, "id_donasi = ?", donasi.IDDonasi)
 
-	ctrl.updateRekapOtomatis(donasi.WaktuCatat)
+	var kwitansi models.Kwitansi
+	ctrl.db.Where("tipe_sumber = ? AND id_sumber = ?", services.TargetDonasi, donasi.IDDonasi).First(&kwitansi)
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Donasi berhasil dibuat",
-		"data":    donasi,
+		"message":  "Donasi berhasil dibuat",
+		"data":     donasi,
+		"kwitansi": kwitansi,
 	})
 }
 
@@ -194,7 +253,7 @@ func (ctrl *DonasiController) GetDonasiByID(c *gin.Context) {
 	}
 
 	var donasi models.Donasi
-	err := ctrl.db.Preload("Admin").Where("id_donasi = ?", id).First(&donasi).Error
+	err := ctrl.db.Preload("Admin").Preload("Donatur").Preload("Kampanye").Where("id_donasi = ?", id).First(&donasi).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Donasi tidak ditemukan"})
@@ -220,7 +279,6 @@ func (ctrl *DonasiController) GetAllDonasi(c *gin.Context) {
 	// Parse query parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	search := c.Query("search")
 
 	if page < 1 {
 		page = 1
@@ -233,12 +291,7 @@ func (ctrl *DonasiController) GetAllDonasi(c *gin.Context) {
 	var total int64
 
 	// Build query
-	query := ctrl.db.Preload("Admin")
-
-	if search != "" {
-		searchPattern := "%" + search + "%"
-		query = query.Where("nama_donatur LIKE ? OR no_telp LIKE ?", searchPattern, searchPattern)
-	}
+	query := ctrl.filterDonasi(c, ctrl.db.Preload("Admin").Preload("Donatur").Preload("Kampanye"))
 
 	// Hitung total records
 	if err := query.Model(&models.Donasi{}).Count(&total).Error; err != nil {
@@ -248,7 +301,7 @@ func (ctrl *DonasiController) GetAllDonasi(c *gin.Context) {
 
 	// Apply pagination
 	offset := (page - 1) * limit
-	err := query.Order("waktu_catat DESC").
+	err := query.Order("donasi.waktu_catat DESC").
 		Offset(offset).
 		Limit(limit).
 		Find(&donasi).Error
@@ -269,6 +322,59 @@ func (ctrl *DonasiController) GetAllDonasi(c *gin.Context) {
 	})
 }
 
+// filterDonasi menerapkan filter GetAllDonasi: search (nama donatur / no. telp), id_donatur, id_kampanye, kode_dana, id_rekening
+// dan rentang tanggal start_date - end_date (YYYY-MM-DD) seperti GetDonasiByDateRange
+func (ctrl *DonasiController) filterDonasi(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if idDonatur := c.Query("id_donatur"); idDonatur != "" {
+		query = query.Where("donasi.id_donatur = ?", idDonatur)
+	}
+	if idKampanye := c.Query("id_kampanye"); idKampanye != "" {
+		query = query.Where("donasi.id_kampanye = ?", idKampanye)
+	}
+	if kodeDana := c.Query("kode_dana"); kodeDana != "" {
+		query = query.Where("donasi.kode_dana = ?", kodeDana)
+	}
+	if idRekening := c.Query("id_rekening"); idRekening != "" {
+		query = query.Where("donasi.id_rekening = ?", idRekening)
+	}
+	if search := c.Query("search"); search != "" {
+		searchPattern := "%" + search + "%"
+		query = query.Where("donasi.nama_donatur LIKE ? OR donasi.no_telp LIKE ?", searchPattern, searchPattern)
+	}
+	if start, err := time.Parse("2006-01-02", c.Query("start_date")); err == nil {
+		query = query.Where("donasi.waktu_catat >= ?", start)
+	}
+	if end, err := time.Parse("2006-01-02", c.Query("end_date")); err == nil {
+		query = query.Where("donasi.waktu_catat < ?", end.Add(24*time.Hour))
+	}
+	return query
+}
+
+// ExportDonasi mengunduh data donasi sebagai XLSX atau CSV dengan filter yang sama seperti GetAllDonasi
+func (ctrl *DonasiController) ExportDonasi(c *gin.Context) {
+	type barisDonasi struct {
+		NamaDonatur string
+		NoTelp      string
+		Nominal     float64
+		WaktuCatat  time.Time
+		NamaAdmin   string
+	}
+
+	query := ctrl.db.Table("donasi").
+		Select("donasi.nama_donatur, donasi.no_telp, donasi.nominal, donasi.waktu_catat, users.nama_lengkap AS nama_admin").
+		Joins("LEFT JOIN users ON users.id_user = donasi.dicatat_oleh")
+	query = ctrl.filterDonasi(c, query).Order("donasi.waktu_catat DESC")
+
+	header := []interface{}{"Nama Donatur", "No. Telp", "Nominal", "Waktu Catat", "Dicatat Oleh"}
+	kirimEkspor(c, "donasi", header, query, func(rows *sql.Rows) ([]interface{}, error) {
+		var b barisDonasi
+		if err := ctrl.db.ScanRows(rows, &b); err != nil {
+			return nil, err
+		}
+		return []interface{}{b.NamaDonatur, b.NoTelp, b.Nominal, b.WaktuCatat, b.NamaAdmin}, nil
+	})
+}
+
 // UpdateDonasi mengupdate data donasi
 func (ctrl *DonasiController) UpdateDonasi(c *gin.Context) {
 	// Check role
@@ -283,6 +389,12 @@ func (ctrl *DonasiController) UpdateDonasi(c *gin.Context) {
 		return
 	}
 
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
 	var req UpdateDonasiRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -317,16 +429,51 @@ func (ctrl *DonasiController) UpdateDonasi(c *gin.Context) {
 		existingDonasi.Nominal = req.Nominal
 	}
 
-	// Simpan perubahan
-	if err := ctrl.db.Save(&existingDonasi).Error; err != nil {
+	if req.IDDonatur != nil || req.IDKampanye != nil {
+		idDonatur, idKampanye, err := ctrl.tautanDonasi(req.IDDonatur, req.IDKampanye)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.IDDonatur != nil {
+			existingDonasi.IDDonatur = idDonatur
+		}
+		// Donasi yang sudah tercatat di kampanye tetap boleh diubah walaupun kampanyenya sudah selesai
+		if req.IDKampanye != nil && !(existingDonasi.IDKampanye != nil && idKampanye != nil && *existingDonasi.IDKampanye == *idKampanye) {
+			existingDonasi.IDKampanye = idKampanye
+		}
+	}
+	if req.Anonim != nil {
+		existingDonasi.Anonim = *req.Anonim
+	}
+	if req.KodeDana != nil && *req.KodeDana != existingDonasi.KodeDana {
+		if err := services.CekDanaPenerimaan(ctrl.db, *req.KodeDana); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		existingDonasi.KodeDana = *req.KodeDana
+	}
+	if req.IDRekening != nil && *req.IDRekening != "" {
+		existingDonasi.IDRekening = req.IDRekening
+		existingDonasi.Rekening = nil
+	}
+
+	// Simpan perubahan, jurnal dan rekap dalam satu transaksi
+	if _, err := services.NewKeuanganService(ctrl.db).UpdateDonasi(&existingDonasi, userID); err != nil {
+		if errors.Is(err, services.ErrPeriodeDitutup) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrRekeningTidakValid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate donasi: " + err.Error()})
 		return
 	}
 
 	// Preload admin data untuk response
-	ctrl.db.Preload("Admin").First(&existingDonasi, "id_donasi = ?", existingDonasi.IDDonasi)
-
-	ctrl.updateRekapOtomatis(existingDonasi.WaktuCatat)
+	ctrl.db.Preload("Admin").Preload("Donatur").Preload("Kampanye").Preload("Rekening").First(&existingDonasi, "id_donasi = ?", existingDonasi.IDDonasi)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Donasi berhasil diupdate",
@@ -348,6 +495,12 @@ func (ctrl *DonasiController) DeleteDonasi(c *gin.Context) {
 		return
 	}
 
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
 	// Cek apakah donasi exists
 	var donasi models.Donasi
 	err := ctrl.db.Where("id_donasi = ?", id).First(&donasi).Error
@@ -360,16 +513,16 @@ func (ctrl *DonasiController) DeleteDonasi(c *gin.Context) {
 		return
 	}
 
-	waktuCatat := donasi.WaktuCatat
-
-	// Hapus donasi
-	if err := ctrl.db.Where("id_donasi = ?", id).Delete(&models.Donasi{}).Error; err != nil {
+	// Hapus donasi, balik jurnalnya dan update rekap dalam satu transaksi
+	if _, err := services.NewKeuanganService(ctrl.db).DeleteDonasi(id, userID); err != nil {
+		if errors.Is(err, services.ErrPeriodeDitutup) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus donasi: " + err.Error()})
 		return
 	}
 
-	ctrl.updateRekapOtomatis(waktuCatat)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Donasi berhasil dihapus",
 	})
@@ -522,7 +675,7 @@ func (ctrl *DonasiController) GetDonasiPublic(c *gin.Context) {
 	var total int64
 
 	// Build query untuk public - hanya field yang diperlukan
-	query := ctrl.db.Select("id_donasi, nama_donatur, no_telp, nominal, waktu_catat")
+	query := ctrl.db.Select("id_donasi, nama_donatur, no_telp, nominal, anonim, waktu_catat")
 
 	// Apply date filters jika ada
 	if startDate != "" {
@@ -632,7 +785,7 @@ func (ctrl *DonasiController) GetDonasiSummaryPublic(c *gin.Context) {
 
 	// Data terbaru (5 donasi terbaru untuk preview)
 	var donasiTerbaru []models.Donasi
-	ctrl.db.Select("nama_donatur, nominal, waktu_catat").
+	ctrl.db.Select("nama_donatur, nominal, anonim, waktu_catat").
 		Order("waktu_catat DESC").
 		Limit(5).
 		Find(&donasiTerbaru)
@@ -643,14 +796,54 @@ func (ctrl *DonasiController) GetDonasiSummaryPublic(c *gin.Context) {
 		donasiTerbaruPublic[i] = ctrl.formatDonasiTerbaruPublic(d)
 	}
 
+	_, errQRIS := services.NewQRISService(ctrl.db).QRISStatis()
+
 	summary := gin.H{
 		"total_nominal": totalNominal,
 		"total_donatur": totalDonatur,
 		"rata_rata":     rataRata,
 		"donasi_terbaru": donasiTerbaruPublic,
+		"qris_tersedia":  errQRIS == nil,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": summary,
 	})
-}
\ No newline at end of file
+}
+// CreateQRISDonasiPublic menerbitkan QRIS donasi untuk donatur umum (tanpa auth). Referensinya
+// dipakai untuk mencatat donasi atas nama donatur saat uangnya muncul di mutasi rekening.
+func (ctrl *DonasiController) CreateQRISDonasiPublic(c *gin.Context) {
+	var req QRISDonasiRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.IDKampanye != nil && *req.IDKampanye != "" {
+		if err := services.CekKampanyeAktif(ctrl.db, *req.IDKampanye); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		req.IDKampanye = nil
+	}
+
+	qris, err := services.NewQRISService(ctrl.db).UntukDonasi(req.Nominal, req.NamaDonatur, req.NoTelp, req.IDKampanye)
+	if err != nil {
+		if errors.Is(err, services.ErrQRISBelumDiatur) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal membuat QRIS: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"referensi":        qris.Referensi,
+			"nominal":          qris.Nominal,
+			"payload":          qris.Payload,
+			"kedaluwarsa_pada": qris.KedaluwarsaPada,
+		},
+	})
+}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+	"tpq_asysyafii/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DonaturController struct {
+	db *gorm.DB
+}
+
+func NewDonaturController(db *gorm.DB) *DonaturController {
+	return &DonaturController{db: db}
+}
+
+// Request structs
+type DonaturRequest struct {
+	Nama    string `json:"nama" binding:"required"`
+	NoTelp  string `json:"no_telp"`
+	Email   string `json:"email"`
+	Alamat  string `json:"alamat"`
+	Anonim  bool   `json:"anonim"`
+	Catatan string `json:"catatan"`
+}
+
+// DonaturResponse adalah data donatur beserta ringkasan donasinya
+type DonaturResponse struct {
+	models.Donatur
+	Ringkasan *services.RingkasanDonatur `json:"ringkasan"`
+}
+
+// GetAllDonatur mendapatkan daftar donatur beserta ringkasan donasinya.
+// Query: search (nama / no. telp), rutin=true untuk donatur rutin saja.
+func (ctrl *DonaturController) GetAllDonatur(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := ctrl.db.Model(&models.Donatur{})
+	if search := c.Query("search"); search != "" {
+		searchPattern := "%" + search + "%"
+		query = query.Where("nama LIKE ? OR no_telp LIKE ?", searchPattern, searchPattern)
+	}
+	if c.Query("rutin") == "true" {
+		query = query.Where("id_donatur IN (?)", services.QueryDonaturRutin(ctrl.db))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	var donatur []models.Donatur
+	if err := query.Order("nama ASC").Offset((page - 1) * limit).Limit(limit).Find(&donatur).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data donatur: " + err.Error()})
+		return
+	}
+
+	ids := make([]string, len(donatur))
+	for i, d := range donatur {
+		ids[i] = d.IDDonatur
+	}
+	ringkasan, err := services.HitungRingkasanDonatur(ctrl.db, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung ringkasan donatur: " + err.Error()})
+		return
+	}
+
+	data := make([]DonaturResponse, len(donatur))
+	for i, d := range donatur {
+		data[i] = DonaturResponse{Donatur: d, Ringkasan: ringkasan[d.IDDonatur]}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// GetDonaturByID mendapatkan donatur beserta ringkasan dan riwayat donasinya
+func (ctrl *DonaturController) GetDonaturByID(c *gin.Context) {
+	var donatur models.Donatur
+	if err := ctrl.db.Where("id_donatur = ?", c.Param("id")).First(&donatur).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Donatur tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data donatur: " + err.Error()})
+		return
+	}
+
+	ringkasan, err := services.HitungRingkasanDonatur(ctrl.db, []string{donatur.IDDonatur})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung ringkasan donatur: " + err.Error()})
+		return
+	}
+
+	var donasi []models.Donasi
+	if err := ctrl.db.Preload("Kampanye").Where("id_donatur = ?", donatur.IDDonatur).Order("waktu_catat DESC").Find(&donasi).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil riwayat donasi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"donatur":   donatur,
+			"ringkasan": ringkasan[donatur.IDDonatur],
+			"donasi":    donasi,
+		},
+	})
+}
+
+// CreateDonatur mendaftarkan donatur baru. Donasi berikutnya dengan nomor telepon yang sama ditautkan otomatis.
+func (ctrl *DonaturController) CreateDonatur(c *gin.Context) {
+	var req DonaturRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	donatur := models.Donatur{
+		IDDonatur: uuid.New().String(),
+		Nama:      strings.TrimSpace(req.Nama),
+		NoTelp:    utils.NormalisasiTelp(req.NoTelp),
+		Email:     req.Email,
+		Alamat:    req.Alamat,
+		Anonim:    req.Anonim,
+		Catatan:   req.Catatan,
+	}
+	if pesan := ctrl.cekTelpDipakai(donatur.NoTelp, ""); pesan != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": pesan})
+		return
+	}
+
+	if err := ctrl.db.Create(&donatur).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat donatur: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Donatur berhasil dibuat",
+		"data":    donatur,
+	})
+}
+
+// UpdateDonatur mengubah data donatur. Perubahan anonim ikut diterapkan ke seluruh donasinya.
+func (ctrl *DonaturController) UpdateDonatur(c *gin.Context) {
+	var req DonaturRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var donatur models.Donatur
+	if err := ctrl.db.Where("id_donatur = ?", c.Param("id")).First(&donatur).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Donatur tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data donatur: " + err.Error()})
+		return
+	}
+
+	donatur.Nama = strings.TrimSpace(req.Nama)
+	donatur.NoTelp = utils.NormalisasiTelp(req.NoTelp)
+	donatur.Email = req.Email
+	donatur.Alamat = req.Alamat
+	donatur.Anonim = req.Anonim
+	donatur.Catatan = req.Catatan
+	if pesan := ctrl.cekTelpDipakai(donatur.NoTelp, donatur.IDDonatur); pesan != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": pesan})
+		return
+	}
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&donatur).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Donasi{}).Where("id_donatur = ?", donatur.IDDonatur).Update("anonim", donatur.Anonim).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate donatur: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Donatur berhasil diupdate",
+		"data":    donatur,
+	})
+}
+
+// DeleteDonatur menghapus donatur. Donasinya tetap ada, hanya dilepas dari donatur ini.
+func (ctrl *DonaturController) DeleteDonatur(c *gin.Context) {
+	id := c.Param("id")
+	var donatur models.Donatur
+	if err := ctrl.db.Where("id_donatur = ?", id).First(&donatur).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Donatur tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data donatur: " + err.Error()})
+		return
+	}
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Donasi{}).Where("id_donatur = ?", id).Update("id_donatur", nil).Error; err != nil {
+			return err
+		}
+		return tx.Where("id_donatur = ?", id).Delete(&models.Donatur{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus donatur: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Donatur berhasil dihapus",
+	})
+}
+
+// TautkanDonasiLama menautkan donasi yang belum punya donatur berdasarkan nomor teleponnya
+func (ctrl *DonaturController) TautkanDonasiLama(c *gin.Context) {
+	jumlah, err := services.TautkanDonasiLama(ctrl.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menautkan donasi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": strconv.Itoa(jumlah) + " donasi berhasil ditautkan ke donatur",
+		"jumlah":  jumlah,
+	})
+}
+
+// cekTelpDipakai memastikan nomor telepon belum dipakai donatur lain, karena
+// nomor telepon dipakai untuk menautkan donasi secara otomatis
+func (ctrl *DonaturController) cekTelpDipakai(noTelp, kecuali string) string {
+	if noTelp == "" {
+		return ""
+	}
+	var lain models.Donatur
+	if err := ctrl.db.Where("no_telp = ? AND id_donatur <> ?", noTelp, kecuali).First(&lain).Error; err == nil {
+		return "Nomor telepon sudah dipakai donatur " + lain.Nama
+	}
+	return ""
+}
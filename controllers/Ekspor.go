@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"tpq_asysyafii/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Jumlah baris yang ditulis sebelum dikirim ke klien
+	barisPerFlushEkspor = 500
+	// Tenggat tulis diperpanjang setiap flush, sehingga ekspor besar tidak terpotong
+	// WriteTimeout server tetapi klien yang macet tetap diputus
+	tenggatTulisEkspor = 30 * time.Second
+)
+
+// kirimEkspor mengalirkan hasil query ke klien sebagai file CSV atau XLSX (query format, default xlsx).
+// Baris dibaca satu per satu dari database dan diubah menjadi kolom oleh fungsi baris,
+// sehingga data satu tahun penuh tidak perlu dimuat ke memori.
+func kirimEkspor(c *gin.Context, namaFile string, header []interface{}, query *gorm.DB, baris func(rows *sql.Rows) ([]interface{}, error)) {
+	format := c.DefaultQuery("format", utils.FormatXLSX)
+	if format != utils.FormatXLSX && format != utils.FormatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format tidak valid. Gunakan 'xlsx' atau 'csv'"})
+		return
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data ekspor: " + err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	rc := http.NewResponseController(c.Writer)
+	rc.SetWriteDeadline(time.Now().Add(tenggatTulisEkspor))
+
+	c.Header("Content-Type", utils.ContentTypeEkspor(format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.%s", namaFile, time.Now().Format("20060102"), format))
+	c.Status(http.StatusOK)
+
+	penulis, err := utils.NewPenulisTabel(format, c.Writer, namaFile)
+	if err != nil {
+		log.Printf("ekspor %s gagal: %v", namaFile, err)
+		return
+	}
+	if err := penulis.TulisBaris(header...); err != nil {
+		log.Printf("ekspor %s gagal: %v", namaFile, err)
+		return
+	}
+
+	// Header sudah terkirim, kesalahan di tengah jalan hanya bisa dicatat
+	jumlah := 0
+	for rows.Next() {
+		kolom, err := baris(rows)
+		if err != nil {
+			log.Printf("ekspor %s gagal membaca baris: %v", namaFile, err)
+			return
+		}
+		if err := penulis.TulisBaris(kolom...); err != nil {
+			log.Printf("ekspor %s gagal: %v", namaFile, err)
+			return
+		}
+
+		jumlah++
+		if jumlah%barisPerFlushEkspor == 0 {
+			if err := penulis.Flush(); err != nil {
+				log.Printf("ekspor %s gagal: %v", namaFile, err)
+				return
+			}
+			c.Writer.Flush()
+			rc.SetWriteDeadline(time.Now().Add(tenggatTulisEkspor))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("ekspor %s gagal membaca data: %v", namaFile, err)
+		return
+	}
+
+	if err := penulis.Tutup(); err != nil {
+		log.Printf("ekspor %s gagal: %v", namaFile, err)
+		return
+	}
+	c.Writer.Flush()
+}
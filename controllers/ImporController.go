@@ -0,0 +1,462 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+	"tpq_asysyafii/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Batas ukuran file impor (2 MB cukup untuk ribuan baris)
+const batasFileImpor = 2 << 20
+
+// Kolom file impor santri. Satu baris = satu santri; baris dengan no_telp_wali yang sama
+// dianggap satu keluarga sehingga wali dan keluarganya hanya dibuat sekali.
+// Isi id_wali untuk menautkan santri ke wali yang sudah terdaftar.
+var kolomImporSantri = []string{
+	"id_wali", "nama_wali", "no_telp_wali", "email_wali", "password_wali",
+	"alamat", "rt_rw", "kelurahan", "kecamatan", "kota", "provinsi", "kode_pos",
+	"nama_santri", "jenis_kelamin", "tempat_lahir", "tanggal_lahir", "program", "kelas", "tanggal_masuk",
+}
+
+type ImporController struct {
+	db *gorm.DB
+}
+
+func NewImporController(db *gorm.DB) *ImporController {
+	return &ImporController{db: db}
+}
+
+// KesalahanImpor adalah kesalahan validasi pada satu baris file (nomor baris sesuai spreadsheet, header = baris 1)
+type KesalahanImpor struct {
+	Baris int    `json:"baris"`
+	Kolom string `json:"kolom,omitempty"`
+	Pesan string `json:"pesan"`
+}
+
+// BarisImporSantri adalah ringkasan satu baris yang lolos validasi
+type BarisImporSantri struct {
+	Baris      int    `json:"baris"`
+	NamaSantri string `json:"nama_santri"`
+	NamaWali   string `json:"nama_wali"`
+	IDWali     string `json:"id_wali,omitempty"` // terisi untuk wali lama, atau setelah commit
+	WaliBaru   bool   `json:"wali_baru"`
+}
+
+// AkunWaliImpor adalah akun wali yang dibuat saat impor.
+// Password hanya dikembalikan jika dibuat otomatis, agar bisa dibagikan ke wali.
+type AkunWaliImpor struct {
+	IDUser      string `json:"id_user"`
+	NamaLengkap string `json:"nama_lengkap"`
+	NoTelp      string `json:"no_telp"`
+	Password    string `json:"password,omitempty"`
+}
+
+type HasilImporSantri struct {
+	DryRun       bool               `json:"dry_run"`
+	TotalBaris   int                `json:"total_baris"`
+	WaliBaru     int                `json:"wali_baru"`
+	KeluargaBaru int                `json:"keluarga_baru"`
+	SantriBaru   int                `json:"santri_baru"`
+	Kesalahan    []KesalahanImpor   `json:"kesalahan"`
+	Baris        []BarisImporSantri `json:"baris"`
+	AkunWali     []AkunWaliImpor    `json:"akun_wali,omitempty"`
+}
+
+// waliImpor menampung wali baru beserta keluarganya, dikelompokkan dari no_telp_wali
+type waliImpor struct {
+	user     models.User
+	keluarga models.Keluarga
+	password string
+	otomatis bool
+	santri   []*models.Santri
+}
+
+// ImporSantri mengimpor wali, keluarga dan santri dari file CSV/XLSX (form field "file").
+// dry_run=true hanya memvalidasi dan melaporkan kesalahan per baris tanpa menyimpan apa pun.
+// Tanpa dry_run, semua data disimpan dalam satu transaksi, atau tidak sama sekali jika ada baris yang salah.
+func (ctrl *ImporController) ImporSantri(c *gin.Context) {
+	adminID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", c.PostForm("dry_run")))
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File impor diperlukan (field 'file')"})
+		return
+	}
+	f, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal membuka file: " + err.Error()})
+		return
+	}
+	defer f.Close()
+
+	data, err := utils.BacaUnggahan(f, batasFileImpor)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tabel, err := utils.BacaTabel(fileHeader.Filename, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal membaca file: " + err.Error()})
+		return
+	}
+	if len(tabel) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File tidak berisi data (baris pertama harus header kolom)"})
+		return
+	}
+
+	hasil, daftarWali, daftarSantri, err := ctrl.validasiSantri(tabel)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hasil.DryRun = dryRun
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"message": "Validasi impor selesai", "data": hasil})
+		return
+	}
+	if len(hasil.Kesalahan) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Impor dibatalkan, perbaiki baris yang salah terlebih dahulu", "data": hasil})
+		return
+	}
+
+	err = ctrl.db.Transaction(func(tx *gorm.DB) error {
+		for _, w := range daftarWali {
+			id, err := generateCustomIDTx(tx, models.RoleWali)
+			if err != nil {
+				return err
+			}
+			hash, err := bcrypt.GenerateFromPassword([]byte(w.password), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			w.user.IDUser = id
+			w.user.Password = string(hash)
+			if err := tx.Create(&w.user).Error; err != nil {
+				return fmt.Errorf("gagal menyimpan wali %s: %w", w.user.NamaLengkap, err)
+			}
+
+			w.keluarga.IDWali = id
+			if err := tx.Create(&w.keluarga).Error; err != nil {
+				return fmt.Errorf("gagal menyimpan keluarga %s: %w", w.user.NamaLengkap, err)
+			}
+			for _, s := range w.santri {
+				s.IDWali = id
+			}
+
+			akun := AkunWaliImpor{IDUser: id, NamaLengkap: w.user.NamaLengkap, NoTelp: w.user.NoTelp}
+			if w.otomatis {
+				akun.Password = w.password
+			}
+			hasil.AkunWali = append(hasil.AkunWali, akun)
+		}
+
+		for _, s := range daftarSantri {
+			if err := tx.Create(s).Error; err != nil {
+				return fmt.Errorf("gagal menyimpan santri %s: %w", s.NamaLengkap, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengimpor data: " + err.Error()})
+		return
+	}
+
+	for i := range hasil.Baris {
+		hasil.Baris[i].IDWali = daftarSantri[i].IDWali
+	}
+
+	services.NewLogService(ctrl.db).LogAktivitas(adminID.(string), services.AksiImpor, services.TargetSantri, "",
+		fmt.Sprintf("Impor %s: %d wali, %d keluarga, %d santri", fileHeader.Filename, hasil.WaliBaru, hasil.KeluargaBaru, hasil.SantriBaru))
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Impor santri berhasil", "data": hasil})
+}
+
+// validasiSantri memeriksa setiap baris dan menyusun data yang akan dibuat.
+// Error hanya dikembalikan untuk kesalahan yang membuat file tidak bisa diproses sama sekali.
+func (ctrl *ImporController) validasiSantri(tabel [][]string) (HasilImporSantri, []*waliImpor, []*models.Santri, error) {
+	hasil := HasilImporSantri{Kesalahan: []KesalahanImpor{}, Baris: []BarisImporSantri{}}
+
+	indeks := map[string]int{}
+	for i, h := range tabel[0] {
+		nama := strings.ToLower(strings.TrimSpace(h))
+		nama = strings.NewReplacer(" ", "_", ".", "", "-", "_").Replace(nama)
+		indeks[nama] = i
+	}
+	for _, wajib := range []string{"nama_santri", "jenis_kelamin"} {
+		if _, ok := indeks[wajib]; !ok {
+			return hasil, nil, nil, fmt.Errorf("kolom '%s' tidak ditemukan di header", wajib)
+		}
+	}
+	if _, ok := indeks["id_wali"]; !ok {
+		if _, ok := indeks["no_telp_wali"]; !ok {
+			return hasil, nil, nil, fmt.Errorf("header harus memiliki kolom 'id_wali' atau 'no_telp_wali'")
+		}
+	}
+
+	var daftarWali []*waliImpor
+	var daftarSantri []*models.Santri
+	waliPerTelp := map[string]*waliImpor{}
+	barisWali := map[string]int{}
+	emailDipakai := map[string]int{}
+	waliLama := map[string]*models.User{}
+	santriDipakai := map[string]int{}
+
+	for i, row := range tabel[1:] {
+		nomor := i + 2
+		if len(row) == 0 || strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		hasil.TotalBaris++
+
+		nilai := func(kolom string) string {
+			j, ok := indeks[kolom]
+			if !ok || j >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[j])
+		}
+		jumlahKesalahan := len(hasil.Kesalahan)
+		salah := func(kolom, pesan string) {
+			hasil.Kesalahan = append(hasil.Kesalahan, KesalahanImpor{Baris: nomor, Kolom: kolom, Pesan: pesan})
+		}
+
+		// Data santri
+		namaSantri := nilai("nama_santri")
+		if namaSantri == "" {
+			salah("nama_santri", "Nama santri wajib diisi")
+		}
+		jenisKelamin := models.JenisKelamin(strings.ToUpper(nilai("jenis_kelamin")))
+		if jenisKelamin != models.LakiLaki && jenisKelamin != models.Perempuan {
+			salah("jenis_kelamin", "Jenis kelamin harus 'L' atau 'P'")
+		}
+		var tanggalLahir time.Time
+		if s := nilai("tanggal_lahir"); s != "" {
+			t, err := parseDate(s)
+			if err != nil {
+				salah("tanggal_lahir", "Format tanggal_lahir tidak valid, gunakan format YYYY-MM-DD")
+			}
+			tanggalLahir = t
+		}
+		tanggalMasuk := time.Now()
+		if s := nilai("tanggal_masuk"); s != "" {
+			t, err := parseDate(s)
+			if err != nil {
+				salah("tanggal_masuk", "Format tanggal_masuk tidak valid, gunakan format YYYY-MM-DD")
+			}
+			tanggalMasuk = t
+		}
+
+		santri := &models.Santri{
+			IDSantri:     uuid.New().String(),
+			NamaLengkap:  namaSantri,
+			JenisKelamin: jenisKelamin,
+			TempatLahir:  nilai("tempat_lahir"),
+			TanggalLahir: tanggalLahir,
+			Alamat:       nilai("alamat"),
+			Status:       models.StatusAktifSantri,
+			Program:      nilai("program"),
+			Kelas:        nilai("kelas"),
+			TanggalMasuk: tanggalMasuk,
+		}
+		ringkasan := BarisImporSantri{Baris: nomor, NamaSantri: namaSantri}
+
+		// Data wali: wali lama lewat id_wali, atau wali baru dikelompokkan per no_telp_wali
+		var wali *waliImpor
+		kunciWali := ""
+		if idWali := nilai("id_wali"); idWali != "" {
+			user, ok := waliLama[idWali]
+			if !ok {
+				var u models.User
+				if err := ctrl.db.Where("id_user = ?", idWali).First(&u).Error; err == nil {
+					user = &u
+				}
+				waliLama[idWali] = user
+			}
+			if user == nil {
+				salah("id_wali", "Wali dengan ID "+idWali+" tidak ditemukan")
+			} else if user.Role != models.RoleWali {
+				salah("id_wali", "User "+idWali+" bukan wali")
+			} else {
+				santri.IDWali = user.IDUser
+				ringkasan.IDWali = user.IDUser
+				ringkasan.NamaWali = user.NamaLengkap
+			}
+			kunciWali = "id:" + idWali
+		} else {
+			namaWali := nilai("nama_wali")
+			noTelp := utils.NormalisasiTelp(nilai("no_telp_wali"))
+			if namaWali == "" {
+				salah("nama_wali", "Nama wali wajib diisi jika id_wali kosong")
+			}
+			if noTelp == "" {
+				salah("no_telp_wali", "No. telp wali wajib diisi jika id_wali kosong")
+			}
+			ringkasan.NamaWali = namaWali
+			ringkasan.WaliBaru = true
+			kunciWali = "telp:" + noTelp
+
+			if w, ok := waliPerTelp[noTelp]; ok && noTelp != "" {
+				// Baris berikutnya dari keluarga yang sama
+				if !strings.EqualFold(w.user.NamaLengkap, namaWali) {
+					salah("no_telp_wali", fmt.Sprintf("No. telp %s sudah dipakai wali '%s' di baris %d", noTelp, w.user.NamaLengkap, barisWali[noTelp]))
+				}
+				wali = w
+			} else if noTelp != "" {
+				var terdaftar models.User
+				if err := ctrl.db.Where("no_telp = ?", noTelp).First(&terdaftar).Error; err == nil {
+					salah("no_telp_wali", fmt.Sprintf("No. telp %s sudah terdaftar atas nama %s (%s), isi id_wali untuk menautkan santri ke wali tersebut", noTelp, terdaftar.NamaLengkap, terdaftar.IDUser))
+				}
+
+				var email *string
+				if e := strings.ToLower(nilai("email_wali")); e != "" {
+					if baris, ok := emailDipakai[e]; ok {
+						salah("email_wali", fmt.Sprintf("Email %s sudah dipakai di baris %d", e, baris))
+					} else {
+						var jumlah int64
+						ctrl.db.Model(&models.User{}).Where("email = ?", e).Count(&jumlah)
+						if jumlah > 0 {
+							salah("email_wali", "Email "+e+" sudah terdaftar")
+						}
+					}
+					emailDipakai[e] = nomor
+					email = &e
+				}
+
+				alamat := nilai("alamat")
+				if alamat == "" {
+					salah("alamat", "Alamat keluarga wajib diisi untuk wali baru")
+				}
+
+				password := nilai("password_wali")
+				otomatis := password == ""
+				if otomatis {
+					password = passwordAcak(8)
+				} else if len(password) < 6 {
+					salah("password_wali", "Password minimal 6 karakter")
+				}
+
+				wali = &waliImpor{
+					user: models.User{
+						NamaLengkap:    namaWali,
+						Email:          email,
+						NoTelp:         noTelp,
+						Role:           models.RoleWali,
+						StatusAktif:    true,
+						DibuatPada:     time.Now(),
+						DiperbaruiPada: time.Now(),
+					},
+					keluarga: models.Keluarga{
+						IDKeluarga: uuid.New().String(),
+						Alamat:     alamat,
+						RTRW:       nilai("rt_rw"),
+						Kelurahan:  nilai("kelurahan"),
+						Kecamatan:  nilai("kecamatan"),
+						Kota:       nilai("kota"),
+						Provinsi:   nilai("provinsi"),
+						KodePos:    nilai("kode_pos"),
+					},
+					password: password,
+					otomatis: otomatis,
+				}
+				waliPerTelp[noTelp] = wali
+				barisWali[noTelp] = nomor
+			}
+		}
+
+		if santri.Alamat == "" && wali != nil {
+			santri.Alamat = wali.keluarga.Alamat
+		}
+
+		// Santri yang sama tidak boleh muncul dua kali untuk wali yang sama
+		kunciSantri := kunciWali + "|" + strings.ToLower(namaSantri) + "|" + nilai("tanggal_lahir")
+		if baris, ok := santriDipakai[kunciSantri]; ok && namaSantri != "" {
+			salah("nama_santri", fmt.Sprintf("Santri %s sudah ada di baris %d", namaSantri, baris))
+		}
+		santriDipakai[kunciSantri] = nomor
+		if santri.IDWali != "" && namaSantri != "" {
+			var jumlah int64
+			ctrl.db.Model(&models.Santri{}).Where("id_wali = ? AND nama_lengkap = ?", santri.IDWali, namaSantri).Count(&jumlah)
+			if jumlah > 0 {
+				salah("nama_santri", "Santri "+namaSantri+" sudah terdaftar untuk wali ini")
+			}
+		}
+
+		if len(hasil.Kesalahan) > jumlahKesalahan {
+			continue
+		}
+		if wali != nil {
+			if len(wali.santri) == 0 {
+				daftarWali = append(daftarWali, wali)
+			}
+			wali.santri = append(wali.santri, santri)
+		}
+		daftarSantri = append(daftarSantri, santri)
+		hasil.Baris = append(hasil.Baris, ringkasan)
+	}
+
+	hasil.WaliBaru = len(daftarWali)
+	hasil.KeluargaBaru = len(daftarWali)
+	hasil.SantriBaru = len(daftarSantri)
+	return hasil, daftarWali, daftarSantri, nil
+}
+
+// GetTemplateImporSantri mengunduh template file impor (format=xlsx|csv) berisi header dan satu contoh baris
+func (ctrl *ImporController) GetTemplateImporSantri(c *gin.Context) {
+	format := c.DefaultQuery("format", utils.FormatXLSX)
+	if format != utils.FormatXLSX && format != utils.FormatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format tidak valid. Gunakan 'xlsx' atau 'csv'"})
+		return
+	}
+
+	c.Header("Content-Type", utils.ContentTypeEkspor(format))
+	c.Header("Content-Disposition", "attachment; filename=template-impor-santri."+format)
+
+	header := make([]interface{}, len(kolomImporSantri))
+	for i, k := range kolomImporSantri {
+		header[i] = k
+	}
+	penulis, err := utils.NewPenulisTabel(format, c.Writer, "Impor Santri")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	penulis.TulisBaris(header...)
+	penulis.TulisBaris("", "Ahmad Fauzi", "081234567890", "", "",
+		"Jl. Melati No. 5", "001/002", "Sukamaju", "Sukajadi", "Bandung", "Jawa Barat", "40162",
+		"Muhammad Rizki", "L", "Bandung", "2017-03-21", "", "", time.Now().Format("2006-01-02"))
+	penulis.Tutup()
+}
+
+// passwordAcak membuat password awal untuk wali yang diimpor tanpa password
+func passwordAcak(panjang int) string {
+	const huruf = "abcdefghjkmnpqrstuvwxyz23456789"
+	b := make([]byte, panjang)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(huruf))))
+		if err != nil {
+			n = big.NewInt(int64(time.Now().UnixNano() % int64(len(huruf))))
+		}
+		b[i] = huruf[n.Int64()]
+	}
+	return string(b)
+}
@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"tpq_asysyafii/models"
+	"tpq_asysyafii/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -119,6 +121,7 @@ func (ctrl *InformasiTPQController) CreateInformasiTPQ(c *gin.Context) {
 	// Manual parsing form data
 	namaTPQ := c.PostForm("nama_tpq")
 	tempat := c.PostForm("tempat")
+	namaKepala := c.PostForm("nama_kepala")
 	visi := c.PostForm("visi")
 	misi := c.PostForm("misi")
 	deskripsi := c.PostForm("deskripsi")
@@ -127,12 +130,19 @@ func (ctrl *InformasiTPQController) CreateInformasiTPQ(c *gin.Context) {
 	alamat := c.PostForm("alamat")
 	linkAlamat := c.PostForm("link_alamat")
 	hariJamBelajar := c.PostForm("hari_jam_belajar")
+	qrisStatis := strings.TrimSpace(c.PostForm("qris_statis"))
 
 	// Validasi field required
 	if namaTPQ == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Nama TPQ harus diisi"})
 		return
 	}
+	if qrisStatis != "" {
+		if err := utils.ValidasiQRIS(qrisStatis); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	// Upload logo jika ada
 	var logo *string
@@ -152,6 +162,7 @@ func (ctrl *InformasiTPQController) CreateInformasiTPQ(c *gin.Context) {
 		IDTPQ:          uuid.New().String(),
 		NamaTPQ:        namaTPQ,
 		Tempat:         &tempat,
+		NamaKepala:     &namaKepala,
 		Logo:           logo,
 		Visi:           &visi,
 		Misi:           &misi,
@@ -163,6 +174,9 @@ func (ctrl *InformasiTPQController) CreateInformasiTPQ(c *gin.Context) {
 		HariJamBelajar: &hariJamBelajar,
 		DiupdateOlehID: &adminID,
 	}
+	if qrisStatis != "" {
+		informasiTPQ.QRISStatis = &qrisStatis
+	}
 
 	// Simpan ke database
 	if err := ctrl.db.Create(&informasiTPQ).Error; err != nil {
@@ -239,6 +253,7 @@ func (ctrl *InformasiTPQController) UpdateInformasiTPQ(c *gin.Context) {
 	// Manual parsing form data
 	namaTPQ := c.PostForm("nama_tpq")
 	tempat := c.PostForm("tempat")
+	namaKepala := c.PostForm("nama_kepala")
 	visi := c.PostForm("visi")
 	misi := c.PostForm("misi")
 	deskripsi := c.PostForm("deskripsi")
@@ -247,6 +262,14 @@ func (ctrl *InformasiTPQController) UpdateInformasiTPQ(c *gin.Context) {
 	alamat := c.PostForm("alamat")
 	linkAlamat := c.PostForm("link_alamat")
 	hariJamBelajar := c.PostForm("hari_jam_belajar")
+	qrisStatis := strings.TrimSpace(c.PostForm("qris_statis"))
+
+	if qrisStatis != "" {
+		if err := utils.ValidasiQRIS(qrisStatis); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	// Upload logo baru jika ada
 	var newLogo *string
@@ -268,6 +291,9 @@ func (ctrl *InformasiTPQController) UpdateInformasiTPQ(c *gin.Context) {
 	if tempat != "" {
 		existingTPQ.Tempat = &tempat
 	}
+	if namaKepala != "" {
+		existingTPQ.NamaKepala = &namaKepala
+	}
 	if visi != "" {
 		existingTPQ.Visi = &visi
 	}
@@ -292,6 +318,9 @@ func (ctrl *InformasiTPQController) UpdateInformasiTPQ(c *gin.Context) {
 	if hariJamBelajar != "" {
 		existingTPQ.HariJamBelajar = &hariJamBelajar
 	}
+	if qrisStatis != "" {
+		existingTPQ.QRISStatis = &qrisStatis
+	}
 	if newLogo != nil {
 		existingTPQ.Logo = newLogo
 	}
@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type JurnalController struct {
+	db *gorm.DB
+}
+
+func NewJurnalController(db *gorm.DB) *JurnalController {
+	return &JurnalController{db: db}
+}
+
+// Request structs
+type CreateSaldoAwalRequest struct {
+	Periode       string  `json:"periode" binding:"required"` // format YYYY-MM
+	SaldoSyahriah float64 `json:"saldo_syahriah"`
+	SaldoDonasi   float64 `json:"saldo_donasi"`
+	// SaldoDana berisi saldo awal per kode dana, termasuk dana selain syahriah dan donasi
+	SaldoDana  map[string]float64 `json:"saldo_dana"`
+	IDRekening *string            `json:"id_rekening"` // rekening tempat saldo awal berada, kosong = rekening utama
+	Keterangan string             `json:"keterangan"`
+}
+
+type NeracaSaldoItem struct {
+	KodeAkun string          `json:"kode_akun"`
+	NamaAkun string          `json:"nama_akun"`
+	Tipe     models.TipeAkun `json:"tipe"`
+	Debit    float64         `json:"debit"`
+	Kredit   float64         `json:"kredit"`
+	Saldo    float64         `json:"saldo"`
+}
+
+// Helper function untuk check role admin
+func (ctrl *JurnalController) isAdmin(c *gin.Context) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		return false
+	}
+	role := userRole.(string)
+	return role == "admin" || role == "super_admin"
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *JurnalController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// GetAllAkun mendapatkan daftar akun
+func (ctrl *JurnalController) GetAllAkun(c *gin.Context) {
+	var akun []models.Akun
+	if err := ctrl.db.Order("tipe ASC, kode_akun ASC").Find(&akun).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data akun: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": akun,
+	})
+}
+
+// GetAllJurnal mendapatkan semua jurnal dengan filter
+func (ctrl *JurnalController) GetAllJurnal(c *gin.Context) {
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	periode := c.Query("periode")
+	jenis := c.Query("jenis")
+	tipeSumber := c.Query("tipe_sumber")
+	idSumber := c.Query("id_sumber")
+	kodeAkun := c.Query("kode_akun")
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var jurnal []models.Jurnal
+	var total int64
+
+	// Build query
+	query := ctrl.db.Preload("Detail").Preload("Detail.Akun").Preload("Admin")
+
+	// Apply filters
+	if periode != "" {
+		query = query.Where("periode = ?", periode)
+	}
+	if jenis != "" {
+		query = query.Where("jenis = ?", jenis)
+	}
+	if tipeSumber != "" {
+		query = query.Where("tipe_sumber = ?", tipeSumber)
+	}
+	if idSumber != "" {
+		query = query.Where("id_sumber = ?", idSumber)
+	}
+	if kodeAkun != "" {
+		query = query.Where("id_jurnal IN (?)", ctrl.db.Model(&models.JurnalDetail{}).
+			Select("id_jurnal").
+			Where("kode_akun = ?", kodeAkun))
+	}
+
+	// Hitung total records
+	if err := query.Model(&models.Jurnal{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	// Apply pagination
+	offset := (page - 1) * limit
+	err := query.Order("waktu_catat DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&jurnal).Error
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data jurnal: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": jurnal,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// GetJurnalByID mendapatkan jurnal berdasarkan ID
+func (ctrl *JurnalController) GetJurnalByID(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID jurnal diperlukan"})
+		return
+	}
+
+	var jurnal models.Jurnal
+	err := ctrl.db.Preload("Detail").Preload("Detail.Akun").Preload("Admin").
+		Where("id_jurnal = ?", id).
+		First(&jurnal).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data jurnal tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data jurnal: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": jurnal,
+	})
+}
+
+// GetNeracaSaldo mendapatkan neraca saldo (trial balance) sampai periode tertentu
+func (ctrl *JurnalController) GetNeracaSaldo(c *gin.Context) {
+	periode := c.DefaultQuery("periode", time.Now().Format("2006-01"))
+	if _, err := time.Parse("2006-01", periode); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format periode tidak valid. Gunakan format YYYY-MM"})
+		return
+	}
+
+	var items []NeracaSaldoItem
+	err := ctrl.db.Table("akun").
+		Select("akun.kode_akun, akun.nama_akun, akun.tipe, COALESCE(SUM(jd.debit), 0) AS debit, COALESCE(SUM(jd.kredit), 0) AS kredit").
+		Joins("LEFT JOIN (SELECT jurnal_detail.* FROM jurnal_detail JOIN jurnal ON jurnal.id_jurnal = jurnal_detail.id_jurnal WHERE jurnal.periode <= ?) jd ON jd.kode_akun = akun.kode_akun", periode).
+		Group("akun.kode_akun, akun.nama_akun, akun.tipe").
+		Order("akun.tipe ASC, akun.kode_akun ASC").
+		Scan(&items).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung neraca saldo: " + err.Error()})
+		return
+	}
+
+	var totalDebit, totalKredit float64
+	for i := range items {
+		items[i].Saldo = items[i].Debit - items[i].Kredit
+		totalDebit += items[i].Debit
+		totalKredit += items[i].Kredit
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": items,
+		"meta": gin.H{
+			"periode":      periode,
+			"total_debit":  totalDebit,
+			"total_kredit": totalKredit,
+			"seimbang":     totalDebit == totalKredit,
+		},
+	})
+}
+
+// CreateSaldoAwal mencatat saldo awal kas sebagai jurnal pembuka
+func (ctrl *JurnalController) CreateSaldoAwal(c *gin.Context) {
+	if !ctrl.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya admin yang dapat mencatat saldo awal"})
+		return
+	}
+
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req CreateSaldoAwalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// saldo_syahriah dan saldo_donasi tetap diterima untuk klien lama
+	saldo := map[string]float64{}
+	for kodeDana, nominal := range req.SaldoDana {
+		saldo[kodeDana] += nominal
+	}
+	saldo[models.KodeDanaSyahriah] += req.SaldoSyahriah
+	saldo[models.KodeDanaDonasi] += req.SaldoDonasi
+	for kodeDana, nominal := range saldo {
+		if nominal < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Saldo awal tidak boleh negatif"})
+			return
+		}
+		if nominal == 0 {
+			delete(saldo, kodeDana)
+		}
+	}
+
+	// Posting saldo awal dan hitung ulang rekap periode tersebut sampai sekarang dalam satu transaksi
+	var jurnal *models.Jurnal
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		jurnal, err = services.NewJurnalService(tx).PostingSaldoAwal(req.Periode, saldo, req.IDRekening, adminID, req.Keterangan)
+		if err != nil {
+			return err
+		}
+		rekapService := services.NewRekapService(tx)
+		_, perubahan, err := rekapService.HitungBerantai(req.Periode)
+		if err != nil {
+			return err
+		}
+		return rekapService.SimpanPerubahan(perubahan, services.SumberSaldoAwal, jurnal.IDJurnal, adminID, "Saldo awal periode "+req.Periode)
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal mencatat saldo awal: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Saldo awal berhasil dicatat",
+		"data":    jurnal,
+	})
+}
+
+// BackfillJurnal memposting ulang jurnal transaksi lama yang belum dijurnal. Backfill sudah
+// dijalankan otomatis saat migrasi; endpoint ini untuk mengulanginya jika migrasi gagal.
+func (ctrl *JurnalController) BackfillJurnal(c *gin.Context) {
+	if !ctrl.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya admin yang dapat menjalankan backfill jurnal"})
+		return
+	}
+
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	jumlah, periods, perubahan, err := services.BackfillJurnal(ctrl.db, adminID, "Backfill jurnal transaksi lama")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal backfill jurnal: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Backfill jurnal berhasil",
+		"data": gin.H{
+			"transaksi_dijurnal": jumlah,
+			"periods":            periods,
+			"perubahan_rekap":    perubahan,
+		},
+	})
+}
@@ -0,0 +1,401 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type KampanyeController struct {
+	db *gorm.DB
+}
+
+func NewKampanyeController(db *gorm.DB) *KampanyeController {
+	return &KampanyeController{db: db}
+}
+
+// Request structs
+type KampanyeRequest struct {
+	Judul      string                `json:"judul" binding:"required"`
+	Deskripsi  string                `json:"deskripsi"`
+	Target     float64               `json:"target" binding:"required,gt=0"`
+	BatasWaktu *string               `json:"batas_waktu"` // format YYYY-MM-DD, opsional
+	Status     models.StatusKampanye `json:"status"`      // hanya untuk update
+}
+
+// KampanyeResponse adalah kampanye beserta dana yang terkumpul dan terpakai
+type KampanyeResponse struct {
+	models.Kampanye
+	Dana *services.DanaKampanye `json:"dana"`
+}
+
+// KampanyePublicResponse adalah progres kampanye untuk halaman publik
+type KampanyePublicResponse struct {
+	Judul         string                        `json:"judul"`
+	Slug          string                        `json:"slug"`
+	Deskripsi     string                        `json:"deskripsi"`
+	Target        float64                       `json:"target"`
+	Terkumpul     float64                       `json:"terkumpul"`
+	Persen        float64                       `json:"persen"`
+	JumlahDonasi  int64                         `json:"jumlah_donasi"`
+	BatasWaktu    *time.Time                    `json:"batas_waktu"`
+	SisaHari      *int                          `json:"sisa_hari"`
+	Status        string                        `json:"status"`
+	DonasiTerbaru []DonasiTerbaruPublicResponse `json:"donasi_terbaru,omitempty"`
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *KampanyeController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// slugKampanye membuat slug unik dari judul, ditambah nomor jika sudah dipakai kampanye lain
+func (ctrl *KampanyeController) slugKampanye(judul, kecuali string) string {
+	dasar := generateSlug(judul)
+	if dasar == "" {
+		dasar = "kampanye"
+	}
+	slug := dasar
+	for i := 2; ; i++ {
+		var jumlah int64
+		ctrl.db.Model(&models.Kampanye{}).Where("slug = ? AND id_kampanye <> ?", slug, kecuali).Count(&jumlah)
+		if jumlah == 0 {
+			return slug
+		}
+		slug = dasar + "-" + strconv.Itoa(i)
+	}
+}
+
+// denganDana melengkapi daftar kampanye dengan dana terkumpul, terpakai dan sisanya
+func (ctrl *KampanyeController) denganDana(kampanye []models.Kampanye) ([]KampanyeResponse, error) {
+	ids := make([]string, len(kampanye))
+	for i, k := range kampanye {
+		ids[i] = k.IDKampanye
+	}
+	var dana map[string]*services.DanaKampanye
+	if len(ids) > 0 {
+		var err error
+		if dana, err = services.HitungDanaKampanye(ctrl.db, ids, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	hasil := make([]KampanyeResponse, len(kampanye))
+	for i, k := range kampanye {
+		d := dana[k.IDKampanye]
+		if d == nil {
+			d = &services.DanaKampanye{IDKampanye: k.IDKampanye}
+		}
+		hasil[i] = KampanyeResponse{Kampanye: k, Dana: d}
+	}
+	return hasil, nil
+}
+
+// formatKampanyePublic menghitung persentase capaian dan sisa hari kampanye
+func (ctrl *KampanyeController) formatKampanyePublic(k KampanyeResponse) KampanyePublicResponse {
+	hasil := KampanyePublicResponse{
+		Judul:        k.Judul,
+		Slug:         k.Slug,
+		Deskripsi:    k.Deskripsi,
+		Target:       k.Target,
+		Terkumpul:    k.Dana.Terkumpul,
+		JumlahDonasi: k.Dana.JumlahDonasi,
+		BatasWaktu:   k.BatasWaktu,
+		Status:       string(k.Status),
+	}
+	if k.Target > 0 {
+		hasil.Persen = math.Round(k.Dana.Terkumpul/k.Target*1000) / 10
+	}
+	if k.BatasWaktu != nil {
+		sekarang := time.Now()
+		hariIni := time.Date(sekarang.Year(), sekarang.Month(), sekarang.Day(), 0, 0, 0, 0, time.UTC)
+		batas := time.Date(k.BatasWaktu.Year(), k.BatasWaktu.Month(), k.BatasWaktu.Day(), 0, 0, 0, 0, time.UTC)
+		sisa := int(batas.Sub(hariIni).Hours() / 24)
+		if sisa < 0 {
+			sisa = 0
+		}
+		hasil.SisaHari = &sisa
+	}
+	return hasil
+}
+
+// parseKampanye memvalidasi request dan mengisi field kampanye
+func (ctrl *KampanyeController) parseKampanye(req KampanyeRequest, kampanye *models.Kampanye) string {
+	if strings.TrimSpace(req.Judul) == "" {
+		return "Judul kampanye wajib diisi"
+	}
+	kampanye.Judul = strings.TrimSpace(req.Judul)
+	kampanye.Deskripsi = req.Deskripsi
+	kampanye.Target = req.Target
+
+	kampanye.BatasWaktu = nil
+	if req.BatasWaktu != nil && *req.BatasWaktu != "" {
+		batas, err := time.Parse("2006-01-02", *req.BatasWaktu)
+		if err != nil {
+			return "Format batas_waktu tidak valid. Gunakan format YYYY-MM-DD"
+		}
+		kampanye.BatasWaktu = &batas
+	}
+
+	switch req.Status {
+	case "":
+	case models.KampanyeAktif, models.KampanyeSelesai, models.KampanyeDibatalkan:
+		kampanye.Status = req.Status
+	default:
+		return "Status tidak valid. Gunakan 'aktif', 'selesai', atau 'dibatalkan'"
+	}
+	return ""
+}
+
+// GetAllKampanye mendapatkan semua kampanye beserta dana terkumpul, terpakai dan sisanya (admin)
+func (ctrl *KampanyeController) GetAllKampanye(c *gin.Context) {
+	query := ctrl.db.Preload("Pembuat")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var kampanye []models.Kampanye
+	if err := query.Order("dibuat_pada DESC").Find(&kampanye).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kampanye: " + err.Error()})
+		return
+	}
+
+	data, err := ctrl.denganDana(kampanye)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung dana kampanye: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+	})
+}
+
+// GetKampanyeByID mendapatkan kampanye beserta donasi dan pemakaian dananya (admin)
+func (ctrl *KampanyeController) GetKampanyeByID(c *gin.Context) {
+	var kampanye models.Kampanye
+	if err := ctrl.db.Preload("Pembuat").Where("id_kampanye = ?", c.Param("id")).First(&kampanye).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kampanye tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kampanye: " + err.Error()})
+		return
+	}
+
+	data, err := ctrl.denganDana([]models.Kampanye{kampanye})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung dana kampanye: " + err.Error()})
+		return
+	}
+
+	var donasi []models.Donasi
+	if err := ctrl.db.Preload("Donatur").Where("id_kampanye = ?", kampanye.IDKampanye).Order("waktu_catat DESC").Find(&donasi).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil donasi kampanye: " + err.Error()})
+		return
+	}
+	var pemakaian []models.PemakaianSaldo
+	if err := ctrl.db.Preload("Pengaju").Where("id_kampanye = ?", kampanye.IDKampanye).Order("created_at DESC").Find(&pemakaian).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil pemakaian kampanye: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"kampanye":  data[0],
+			"donasi":    donasi,
+			"pemakaian": pemakaian,
+		},
+	})
+}
+
+// CreateKampanye membuat kampanye donasi baru
+func (ctrl *KampanyeController) CreateKampanye(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req KampanyeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	kampanye := models.Kampanye{
+		IDKampanye: uuid.New().String(),
+		Status:     models.KampanyeAktif,
+		DibuatOleh: adminID,
+	}
+	if pesan := ctrl.parseKampanye(req, &kampanye); pesan != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": pesan})
+		return
+	}
+	kampanye.Slug = ctrl.slugKampanye(kampanye.Judul, kampanye.IDKampanye)
+
+	if err := ctrl.db.Create(&kampanye).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat kampanye: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Kampanye berhasil dibuat",
+		"data":    kampanye,
+	})
+}
+
+// UpdateKampanye mengubah kampanye, termasuk menutupnya (status selesai/dibatalkan).
+// Dana yang sudah terkumpul tetap terikat dan bisa dipakai setelah kampanye ditutup.
+func (ctrl *KampanyeController) UpdateKampanye(c *gin.Context) {
+	var req KampanyeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var kampanye models.Kampanye
+	if err := ctrl.db.Where("id_kampanye = ?", c.Param("id")).First(&kampanye).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kampanye tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kampanye: " + err.Error()})
+		return
+	}
+
+	judulLama := kampanye.Judul
+	if pesan := ctrl.parseKampanye(req, &kampanye); pesan != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": pesan})
+		return
+	}
+	if kampanye.Judul != judulLama {
+		kampanye.Slug = ctrl.slugKampanye(kampanye.Judul, kampanye.IDKampanye)
+	}
+
+	if err := ctrl.db.Save(&kampanye).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate kampanye: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Kampanye berhasil diupdate",
+		"data":    kampanye,
+	})
+}
+
+// DeleteKampanye menghapus kampanye yang belum punya donasi maupun pemakaian.
+// Kampanye yang sudah berjalan cukup dibatalkan atau diselesaikan.
+func (ctrl *KampanyeController) DeleteKampanye(c *gin.Context) {
+	id := c.Param("id")
+	var kampanye models.Kampanye
+	if err := ctrl.db.Where("id_kampanye = ?", id).First(&kampanye).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kampanye tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kampanye: " + err.Error()})
+		return
+	}
+
+	var jumlahDonasi, jumlahPemakaian int64
+	ctrl.db.Model(&models.Donasi{}).Where("id_kampanye = ?", id).Count(&jumlahDonasi)
+	ctrl.db.Model(&models.PemakaianSaldo{}).Where("id_kampanye = ?", id).Count(&jumlahPemakaian)
+	if jumlahDonasi > 0 || jumlahPemakaian > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kampanye sudah memiliki donasi atau pemakaian, ubah statusnya menjadi selesai atau dibatalkan"})
+		return
+	}
+
+	if err := ctrl.db.Where("id_kampanye = ?", id).Delete(&models.Kampanye{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus kampanye: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Kampanye berhasil dihapus",
+	})
+}
+
+// GetKampanyePublic mendapatkan progres kampanye untuk publik (tanpa auth).
+// Default hanya kampanye aktif; status=semua untuk menampilkan kampanye selesai juga.
+func (ctrl *KampanyeController) GetKampanyePublic(c *gin.Context) {
+	query := ctrl.db.Where("status <> ?", models.KampanyeDibatalkan)
+	if c.Query("status") != "semua" {
+		query = query.Where("status = ?", models.KampanyeAktif)
+	}
+
+	var kampanye []models.Kampanye
+	if err := query.Order("dibuat_pada DESC").Find(&kampanye).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kampanye: " + err.Error()})
+		return
+	}
+
+	data, err := ctrl.denganDana(kampanye)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung dana kampanye: " + err.Error()})
+		return
+	}
+
+	hasil := make([]KampanyePublicResponse, len(data))
+	for i, k := range data {
+		hasil[i] = ctrl.formatKampanyePublic(k)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": hasil,
+	})
+}
+
+// GetKampanyeBySlugPublic mendapatkan progres satu kampanye beserta donasi terbarunya (tanpa auth)
+func (ctrl *KampanyeController) GetKampanyeBySlugPublic(c *gin.Context) {
+	var kampanye models.Kampanye
+	err := ctrl.db.Where("slug = ? AND status <> ?", c.Param("slug"), models.KampanyeDibatalkan).First(&kampanye).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kampanye tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kampanye: " + err.Error()})
+		return
+	}
+
+	data, err := ctrl.denganDana([]models.Kampanye{kampanye})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung dana kampanye: " + err.Error()})
+		return
+	}
+	hasil := ctrl.formatKampanyePublic(data[0])
+
+	var donasi []models.Donasi
+	ctrl.db.Select("nama_donatur, nominal, anonim, waktu_catat").
+		Where("id_kampanye = ?", kampanye.IDKampanye).
+		Order("waktu_catat DESC").
+		Limit(10).
+		Find(&donasi)
+	donasiCtrl := &DonasiController{db: ctrl.db}
+	hasil.DonasiTerbaru = make([]DonasiTerbaruPublicResponse, len(donasi))
+	for i, d := range donasi {
+		hasil.DonasiTerbaru[i] = donasiCtrl.formatDonasiTerbaruPublic(d)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"kampanye":    hasil,
+			"id_kampanye": kampanye.IDKampanye, // dipakai untuk QRIS donasi kampanye
+		},
+	})
+}
@@ -0,0 +1,667 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type KelasController struct {
+	db *gorm.DB
+}
+
+func NewKelasController(db *gorm.DB) *KelasController {
+	return &KelasController{db: db}
+}
+
+// Request structs
+type JadwalKelasRequest struct {
+	Hari       string  `json:"hari" binding:"required"`        // senin..ahad
+	JamMulai   string  `json:"jam_mulai" binding:"required"`   // format HH:MM
+	JamSelesai string  `json:"jam_selesai" binding:"required"` // format HH:MM
+	IDPengajar *string `json:"id_pengajar"`                    // kosong = wali kelas
+	Materi     string  `json:"materi"`
+}
+
+type CreateKelasRequest struct {
+	NamaKelas   string               `json:"nama_kelas" binding:"required"`
+	Tingkat     string               `json:"tingkat"`
+	TahunAjaran string               `json:"tahun_ajaran"` // format 2025/2026, kosong = tahun ajaran berjalan
+	IDWaliKelas *string              `json:"id_wali_kelas"`
+	Kapasitas   int                  `json:"kapasitas"`
+	Keterangan  string               `json:"keterangan"`
+	Jadwal      []JadwalKelasRequest `json:"jadwal"`
+}
+
+type UpdateKelasRequest struct {
+	NamaKelas   *string               `json:"nama_kelas"`
+	Tingkat     *string               `json:"tingkat"`
+	IDWaliKelas *string               `json:"id_wali_kelas"` // string kosong = hapus wali kelas
+	Kapasitas   *int                  `json:"kapasitas"`
+	Keterangan  *string               `json:"keterangan"`
+	Aktif       *bool                 `json:"aktif"`
+	Jadwal      *[]JadwalKelasRequest `json:"jadwal"` // jika diisi, menggantikan seluruh jadwal
+}
+
+type TambahAnggotaKelasRequest struct {
+	IDSantri     []string `json:"id_santri" binding:"required,min=1"`
+	TanggalMasuk string   `json:"tanggal_masuk"` // format YYYY-MM-DD, kosong = hari ini
+}
+
+type KeluarkanAnggotaKelasRequest struct {
+	Status        string `json:"status"`         // naik, pindah, keluar, lulus (default keluar)
+	TanggalKeluar string `json:"tanggal_keluar"` // format YYYY-MM-DD, kosong = hari ini
+	Keterangan    string `json:"keterangan"`
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *KelasController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// cekUstadz memastikan user yang dipilih sebagai wali kelas/pengajar adalah ustadz aktif
+func (ctrl *KelasController) cekUstadz(id string) error {
+	var user models.User
+	if err := ctrl.db.Where("id_user = ? AND role = ?", id, models.RoleUstadz).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("Ustadz dengan ID " + id + " tidak ditemukan")
+		}
+		return err
+	}
+	return nil
+}
+
+// buatJadwal memvalidasi jadwal dari request
+func (ctrl *KelasController) buatJadwal(idKelas string, req []JadwalKelasRequest) ([]models.JadwalKelas, error) {
+	jadwal := make([]models.JadwalKelas, 0, len(req))
+	for _, j := range req {
+		hari := models.HariJadwal(j.Hari)
+		switch hari {
+		case models.HariSenin, models.HariSelasa, models.HariRabu, models.HariKamis, models.HariJumat, models.HariSabtu, models.HariAhad:
+		default:
+			return nil, errors.New("Hari tidak valid. Gunakan senin, selasa, rabu, kamis, jumat, sabtu atau ahad")
+		}
+		mulai, err1 := time.Parse("15:04", j.JamMulai)
+		selesai, err2 := time.Parse("15:04", j.JamSelesai)
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("Format jam tidak valid. Gunakan format HH:MM")
+		}
+		if !selesai.After(mulai) {
+			return nil, errors.New("Jam selesai harus setelah jam mulai")
+		}
+		if j.IDPengajar != nil && *j.IDPengajar == "" {
+			j.IDPengajar = nil
+		}
+		if j.IDPengajar != nil {
+			if err := ctrl.cekUstadz(*j.IDPengajar); err != nil {
+				return nil, err
+			}
+		}
+		jadwal = append(jadwal, models.JadwalKelas{
+			IDJadwal:   uuid.New().String(),
+			IDKelas:    idKelas,
+			Hari:       hari,
+			JamMulai:   j.JamMulai,
+			JamSelesai: j.JamSelesai,
+			IDPengajar: j.IDPengajar,
+			Materi:     j.Materi,
+		})
+	}
+	return jadwal, nil
+}
+
+// parseTanggal membaca tanggal YYYY-MM-DD, kosong berarti hari ini
+func (ctrl *KelasController) parseTanggal(s string) (time.Time, error) {
+	if s == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local), nil
+	}
+	tanggal, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("Format tanggal tidak valid. Gunakan format YYYY-MM-DD")
+	}
+	return tanggal, nil
+}
+
+// preloadKelas memuat wali kelas dan jadwal beserta pengajarnya
+func (ctrl *KelasController) preloadKelas(query *gorm.DB) *gorm.DB {
+	return query.Preload("WaliKelas").
+		Preload("Jadwal", func(db *gorm.DB) *gorm.DB {
+			return db.Order("FIELD(hari, 'senin','selasa','rabu','kamis','jumat','sabtu','ahad'), jam_mulai")
+		}).
+		Preload("Jadwal.Pengajar")
+}
+
+// jumlahAnggotaAktif menghitung anggota aktif setiap kelas
+func (ctrl *KelasController) jumlahAnggotaAktif(idKelas []string) (map[string]int64, error) {
+	var rows []struct {
+		IDKelas string
+		Jumlah  int64
+	}
+	hasil := make(map[string]int64)
+	if len(idKelas) == 0 {
+		return hasil, nil
+	}
+	if err := ctrl.db.Model(&models.AnggotaKelas{}).
+		Select("id_kelas, COUNT(*) AS jumlah").
+		Where("id_kelas IN ? AND status = ?", idKelas, models.AnggotaAktif).
+		Group("id_kelas").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		hasil[r.IDKelas] = r.Jumlah
+	}
+	return hasil, nil
+}
+
+// daftarKelas menyusun daftar kelas beserta jumlah santri aktifnya
+func (ctrl *KelasController) daftarKelas(query *gorm.DB) ([]gin.H, error) {
+	var kelas []models.Kelas
+	if err := ctrl.preloadKelas(query).Order("tahun_ajaran DESC, nama_kelas ASC").Find(&kelas).Error; err != nil {
+		return nil, err
+	}
+	idKelas := make([]string, len(kelas))
+	for i, k := range kelas {
+		idKelas[i] = k.IDKelas
+	}
+	jumlah, err := ctrl.jumlahAnggotaAktif(idKelas)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]gin.H, len(kelas))
+	for i, k := range kelas {
+		data[i] = gin.H{
+			"kelas":         k,
+			"jumlah_santri": jumlah[k.IDKelas],
+		}
+	}
+	return data, nil
+}
+
+// GetAllKelas mendapatkan daftar kelas. Query: tahun_ajaran, aktif, id_wali_kelas
+func (ctrl *KelasController) GetAllKelas(c *gin.Context) {
+	query := ctrl.db.Model(&models.Kelas{})
+	if tahunAjaran := c.Query("tahun_ajaran"); tahunAjaran != "" {
+		query = query.Where("tahun_ajaran = ?", tahunAjaran)
+	}
+	if aktif := c.Query("aktif"); aktif != "" {
+		query = query.Where("aktif = ?", aktif == "true")
+	}
+	if idWali := c.Query("id_wali_kelas"); idWali != "" {
+		query = query.Where("id_wali_kelas = ?", idWali)
+	}
+
+	data, err := ctrl.daftarKelas(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":                  data,
+		"tahun_ajaran_berjalan": services.TahunAjaranBerjalan(time.Now()),
+	})
+}
+
+// GetKelasByID mendapatkan detail kelas beserta santri aktifnya
+func (ctrl *KelasController) GetKelasByID(c *gin.Context) {
+	var kelas models.Kelas
+	if err := ctrl.preloadKelas(ctrl.db).Where("id_kelas = ?", c.Param("id")).First(&kelas).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kelas tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+
+	var anggota []models.AnggotaKelas
+	query := ctrl.db.Preload("Santri").Where("id_kelas = ?", kelas.IDKelas)
+	if c.Query("semua") != "true" {
+		query = query.Where("status = ?", models.AnggotaAktif)
+	}
+	if err := query.Order("tanggal_masuk ASC").Find(&anggota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil anggota kelas: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    kelas,
+		"anggota": anggota,
+	})
+}
+
+// CreateKelas membuat kelas baru beserta jadwalnya
+func (ctrl *KelasController) CreateKelas(c *gin.Context) {
+	var req CreateKelasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.TahunAjaran == "" {
+		req.TahunAjaran = services.TahunAjaranBerjalan(time.Now())
+	}
+	if !services.ValidTahunAjaran(req.TahunAjaran) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format tahun_ajaran tidak valid. Gunakan format 2025/2026"})
+		return
+	}
+	if req.Kapasitas < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kapasitas tidak boleh negatif"})
+		return
+	}
+	if req.IDWaliKelas != nil && *req.IDWaliKelas == "" {
+		req.IDWaliKelas = nil
+	}
+	if req.IDWaliKelas != nil {
+		if err := ctrl.cekUstadz(*req.IDWaliKelas); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var jumlah int64
+	if err := ctrl.db.Model(&models.Kelas{}).
+		Where("nama_kelas = ? AND tahun_ajaran = ?", req.NamaKelas, req.TahunAjaran).
+		Count(&jumlah).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek nama kelas: " + err.Error()})
+		return
+	}
+	if jumlah > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Nama kelas sudah digunakan pada tahun ajaran " + req.TahunAjaran})
+		return
+	}
+
+	kelas := models.Kelas{
+		IDKelas:     uuid.New().String(),
+		NamaKelas:   req.NamaKelas,
+		Tingkat:     req.Tingkat,
+		TahunAjaran: req.TahunAjaran,
+		IDWaliKelas: req.IDWaliKelas,
+		Kapasitas:   req.Kapasitas,
+		Keterangan:  req.Keterangan,
+		Aktif:       true,
+	}
+	jadwal, err := ctrl.buatJadwal(kelas.IDKelas, req.Jadwal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = ctrl.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Jadwal").Create(&kelas).Error; err != nil {
+			return err
+		}
+		if len(jadwal) == 0 {
+			return nil
+		}
+		return tx.Create(&jadwal).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat kelas: " + err.Error()})
+		return
+	}
+
+	ctrl.preloadKelas(ctrl.db).First(&kelas, "id_kelas = ?", kelas.IDKelas)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Kelas berhasil dibuat",
+		"data":    kelas,
+	})
+}
+
+// UpdateKelas mengubah data kelas. Jika jadwal dikirim, seluruh jadwal lama diganti.
+func (ctrl *KelasController) UpdateKelas(c *gin.Context) {
+	var req UpdateKelasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var kelas models.Kelas
+	if err := ctrl.db.Where("id_kelas = ?", c.Param("id")).First(&kelas).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kelas tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+	namaLama := kelas.NamaKelas
+
+	if req.NamaKelas != nil && *req.NamaKelas != kelas.NamaKelas {
+		if *req.NamaKelas == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nama kelas tidak boleh kosong"})
+			return
+		}
+		var jumlah int64
+		if err := ctrl.db.Model(&models.Kelas{}).
+			Where("nama_kelas = ? AND tahun_ajaran = ? AND id_kelas <> ?", *req.NamaKelas, kelas.TahunAjaran, kelas.IDKelas).
+			Count(&jumlah).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek nama kelas: " + err.Error()})
+			return
+		}
+		if jumlah > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Nama kelas sudah digunakan pada tahun ajaran " + kelas.TahunAjaran})
+			return
+		}
+		kelas.NamaKelas = *req.NamaKelas
+	}
+	if req.Tingkat != nil {
+		kelas.Tingkat = *req.Tingkat
+	}
+	if req.IDWaliKelas != nil {
+		if *req.IDWaliKelas == "" {
+			kelas.IDWaliKelas = nil
+		} else {
+			if err := ctrl.cekUstadz(*req.IDWaliKelas); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			kelas.IDWaliKelas = req.IDWaliKelas
+		}
+	}
+	if req.Kapasitas != nil {
+		if *req.Kapasitas < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Kapasitas tidak boleh negatif"})
+			return
+		}
+		kelas.Kapasitas = *req.Kapasitas
+	}
+	if req.Keterangan != nil {
+		kelas.Keterangan = *req.Keterangan
+	}
+	if req.Aktif != nil {
+		kelas.Aktif = *req.Aktif
+	}
+
+	var jadwal []models.JadwalKelas
+	if req.Jadwal != nil {
+		var err error
+		jadwal, err = ctrl.buatJadwal(kelas.IDKelas, *req.Jadwal)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("WaliKelas", "Jadwal").Save(&kelas).Error; err != nil {
+			return err
+		}
+		// Kolom kelas pada santri mengikuti nama kelas yang sedang diikuti
+		if kelas.NamaKelas != namaLama {
+			if err := tx.Model(&models.Santri{}).
+				Where("kelas = ? AND id_santri IN (?)", namaLama,
+					tx.Model(&models.AnggotaKelas{}).Select("id_santri").Where("id_kelas = ? AND status = ?", kelas.IDKelas, models.AnggotaAktif)).
+				Update("kelas", kelas.NamaKelas).Error; err != nil {
+				return err
+			}
+		}
+		if req.Jadwal == nil {
+			return nil
+		}
+		if err := tx.Where("id_kelas = ?", kelas.IDKelas).Delete(&models.JadwalKelas{}).Error; err != nil {
+			return err
+		}
+		if len(jadwal) == 0 {
+			return nil
+		}
+		return tx.Create(&jadwal).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate kelas: " + err.Error()})
+		return
+	}
+
+	ctrl.preloadKelas(ctrl.db).First(&kelas, "id_kelas = ?", kelas.IDKelas)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Kelas berhasil diupdate",
+		"data":    kelas,
+	})
+}
+
+// DeleteKelas menghapus kelas yang belum pernah punya anggota. Kelas dengan riwayat santri cukup dinonaktifkan.
+func (ctrl *KelasController) DeleteKelas(c *gin.Context) {
+	var kelas models.Kelas
+	if err := ctrl.db.Where("id_kelas = ?", c.Param("id")).First(&kelas).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kelas tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+
+	var jumlah int64
+	if err := ctrl.db.Model(&models.AnggotaKelas{}).Where("id_kelas = ?", kelas.IDKelas).Count(&jumlah).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek anggota kelas: " + err.Error()})
+		return
+	}
+	if jumlah > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kelas sudah memiliki riwayat santri, nonaktifkan kelas ini sebagai gantinya"})
+		return
+	}
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id_kelas = ?", kelas.IDKelas).Delete(&models.JadwalKelas{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&kelas).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus kelas: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Kelas berhasil dihapus",
+	})
+}
+
+// TambahAnggotaKelas mendaftarkan santri ke kelas. Santri yang masih aktif di kelas lain pada
+// tahun ajaran yang sama otomatis dipindahkan.
+func (ctrl *KelasController) TambahAnggotaKelas(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req TambahAnggotaKelasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tanggalMasuk, err := ctrl.parseTanggal(req.TanggalMasuk)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var kelas models.Kelas
+	if err := ctrl.db.Where("id_kelas = ?", c.Param("id")).First(&kelas).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kelas tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+	if !kelas.Aktif {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kelas sudah tidak aktif"})
+		return
+	}
+
+	baru, err := services.MasukkanKeKelas(ctrl.db, kelas, req.IDSantri, tanggalMasuk, adminID)
+	if err != nil {
+		if errors.Is(err, services.ErrKelasPenuh) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal menambah anggota kelas: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": strconv.Itoa(len(baru)) + " santri berhasil dimasukkan ke kelas " + kelas.NamaKelas,
+		"data":    baru,
+	})
+}
+
+// KeluarkanAnggotaKelas menutup keanggotaan santri di kelas (naik, pindah, keluar atau lulus)
+func (ctrl *KelasController) KeluarkanAnggotaKelas(c *gin.Context) {
+	var req KeluarkanAnggotaKelasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := models.AnggotaKeluar
+	if req.Status != "" {
+		status = models.StatusAnggotaKelas(req.Status)
+		switch status {
+		case models.AnggotaNaik, models.AnggotaPindah, models.AnggotaKeluar, models.AnggotaLulus:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status tidak valid. Gunakan 'naik', 'pindah', 'keluar' atau 'lulus'"})
+			return
+		}
+	}
+	tanggalKeluar, err := ctrl.parseTanggal(req.TanggalKeluar)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var anggota models.AnggotaKelas
+	if err := ctrl.db.Where("id_anggota = ?", c.Param("id")).First(&anggota).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data anggota kelas tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data anggota kelas: " + err.Error()})
+		return
+	}
+
+	if err := services.KeluarkanDariKelas(ctrl.db, &anggota, status, tanggalKeluar, req.Keterangan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal mengeluarkan santri dari kelas: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Santri berhasil dikeluarkan dari kelas",
+		"data":    anggota,
+	})
+}
+
+// GetRiwayatKelasSantri mendapatkan riwayat kelas seorang santri dari tahun ke tahun
+func (ctrl *KelasController) GetRiwayatKelasSantri(c *gin.Context) {
+	var riwayat []models.AnggotaKelas
+	if err := ctrl.db.Preload("Kelas").Preload("Kelas.WaliKelas").
+		Where("id_santri = ?", c.Param("id")).
+		Order("tanggal_masuk DESC").
+		Find(&riwayat).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil riwayat kelas: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": riwayat,
+	})
+}
+
+// GetKelasSaya mendapatkan kelas aktif yang diajar ustadz yang sedang login
+func (ctrl *KelasController) GetKelasSaya(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	idKelas, err := services.KelasUstadz(ctrl.db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+
+	if len(idKelas) == 0 {
+		c.JSON(http.StatusOK, gin.H{"data": []gin.H{}})
+		return
+	}
+
+	data, err := ctrl.daftarKelas(ctrl.db.Model(&models.Kelas{}).Where("id_kelas IN ?", idKelas))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+	})
+}
+
+// GetSantriSaya mendapatkan santri aktif di kelas-kelas yang diajar ustadz yang sedang login.
+// Query: id_kelas untuk membatasi satu kelas, search untuk nama santri.
+func (ctrl *KelasController) GetSantriSaya(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	idKelas, err := services.KelasUstadz(ctrl.db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+	if filter := c.Query("id_kelas"); filter != "" {
+		diajar := false
+		for _, id := range idKelas {
+			if id == filter {
+				diajar = true
+				break
+			}
+		}
+		if !diajar {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak mengajar di kelas ini"})
+			return
+		}
+		idKelas = []string{filter}
+	}
+	if len(idKelas) == 0 {
+		c.JSON(http.StatusOK, gin.H{"data": []models.AnggotaKelas{}, "total": 0})
+		return
+	}
+
+	var anggota []models.AnggotaKelas
+	query := ctrl.db.Preload("Kelas").Preload("Santri").Preload("Santri.Wali").
+		Joins("JOIN santri ON santri.id_santri = anggota_kelas.id_santri").
+		Where("anggota_kelas.id_kelas IN ? AND anggota_kelas.status = ?", idKelas, models.AnggotaAktif)
+	if search := c.Query("search"); search != "" {
+		query = query.Where("santri.nama_lengkap LIKE ?", "%"+search+"%")
+	}
+	if err := query.Order("santri.nama_lengkap ASC").Find(&anggota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data santri: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  anggota,
+		"total": len(anggota),
+	})
+}
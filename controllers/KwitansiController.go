@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type KwitansiController struct {
+	db *gorm.DB
+}
+
+func NewKwitansiController(db *gorm.DB) *KwitansiController {
+	return &KwitansiController{db: db}
+}
+
+// Helper function untuk check role admin
+func (ctrl *KwitansiController) isAdmin(c *gin.Context) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		return false
+	}
+	role := userRole.(string)
+	return role == "admin" || role == "super_admin"
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *KwitansiController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// bolehAkses memeriksa apakah user boleh mengunduh kwitansi: admin, atau wali/santri pemilik kwitansi syahriah
+func (ctrl *KwitansiController) bolehAkses(c *gin.Context, idWali, idSantri *string) bool {
+	if ctrl.isAdmin(c) {
+		return true
+	}
+	userID, _ := ctrl.getUserID(c)
+	return (idWali != nil && *idWali == userID) ||
+		(idSantri != nil && *idSantri == userID)
+}
+
+// pemilikSumber mengambil wali dan santri pemilik pembayaran sumber kwitansi. Donasi tidak punya
+// pemilik sehingga hanya admin yang boleh mengaksesnya.
+func (ctrl *KwitansiController) pemilikSumber(tipeSumber, idSumber string) (*string, *string, error) {
+	switch tipeSumber {
+	case services.TargetPembayaranSyahriah:
+		var pembayaran models.PembayaranSyahriah
+		if err := ctrl.db.Where("id_pembayaran = ?", idSumber).First(&pembayaran).Error; err != nil {
+			return nil, nil, err
+		}
+		var syahriah models.Syahriah
+		if err := ctrl.db.Preload("Santri").Where("id_syahriah = ?", pembayaran.IDSyahriah).First(&syahriah).Error; err != nil {
+			return nil, nil, err
+		}
+		return &syahriah.Santri.IDWali, &syahriah.Santri.IDSantri, nil
+	case services.TargetDonasi:
+		var donasi models.Donasi
+		if err := ctrl.db.Where("id_donasi = ?", idSumber).First(&donasi).Error; err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, nil
+	}
+	return nil, nil, fmt.Errorf("tipe sumber kwitansi tidak dikenal: %s", tipeSumber)
+}
+
+// kirimPDF mencetak kwitansi dan mengirimkannya sebagai file PDF
+func (ctrl *KwitansiController) kirimPDF(c *gin.Context, kwitansi models.Kwitansi) {
+	pdf, err := services.NewKwitansiService(ctrl.db).PDF(kwitansi)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat PDF kwitansi: " + err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", kwitansi.Nomor))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// GetAllKwitansi mendapatkan daftar kwitansi (hanya admin)
+func (ctrl *KwitansiController) GetAllKwitansi(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := ctrl.db.Model(&models.Kwitansi{})
+	if tahun := c.Query("tahun"); tahun != "" {
+		query = query.Where("tahun = ?", tahun)
+	}
+	if tipeSumber := c.Query("tipe_sumber"); tipeSumber != "" {
+		query = query.Where("tipe_sumber = ?", tipeSumber)
+	}
+	if idSumber := c.Query("id_sumber"); idSumber != "" {
+		query = query.Where("id_sumber = ?", idSumber)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("nomor LIKE ? OR nama_pembayar LIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	var kwitansi []models.Kwitansi
+	if err := query.Preload("Penerima").
+		Order("tahun DESC, urutan DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&kwitansi).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kwitansi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": kwitansi,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// GetKwitansiPDF mengunduh kwitansi berdasarkan ID kwitansi
+func (ctrl *KwitansiController) GetKwitansiPDF(c *gin.Context) {
+	var kwitansi models.Kwitansi
+	if err := ctrl.db.Preload("Penerima").Where("id_kwitansi = ?", c.Param("id")).First(&kwitansi).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kwitansi tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil kwitansi: " + err.Error()})
+		return
+	}
+
+	if !ctrl.bolehAkses(c, kwitansi.IDWali, kwitansi.IDSantri) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak memiliki akses ke kwitansi ini"})
+		return
+	}
+	ctrl.kirimPDF(c, kwitansi)
+}
+
+// GetKwitansiPembayaranSyahriah mengunduh kwitansi satu pembayaran syahriah
+func (ctrl *KwitansiController) GetKwitansiPembayaranSyahriah(c *gin.Context) {
+	ctrl.kwitansiSumber(c, services.TargetPembayaranSyahriah, c.Param("id"))
+}
+
+// GetKwitansiDonasi mengunduh kwitansi donasi (hanya admin)
+func (ctrl *KwitansiController) GetKwitansiDonasi(c *gin.Context) {
+	ctrl.kwitansiSumber(c, services.TargetDonasi, c.Param("id"))
+}
+
+// kwitansiSumber memeriksa akses ke pembayaran sumber lebih dulu, baru mengambil kwitansinya.
+// Kwitansi hanya diterbitkan saat pembayaran dicatat atau oleh migrasi, tidak saat diunduh.
+func (ctrl *KwitansiController) kwitansiSumber(c *gin.Context, tipeSumber, idSumber string) {
+	idWali, idSantri, err := ctrl.pemilikSumber(tipeSumber, idSumber)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data pembayaran tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pembayaran: " + err.Error()})
+		return
+	}
+	if !ctrl.bolehAkses(c, idWali, idSantri) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak memiliki akses ke kwitansi ini"})
+		return
+	}
+
+	kwitansi, err := services.NewKwitansiService(ctrl.db).KwitansiSumber(tipeSumber, idSumber)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kwitansi untuk pembayaran ini belum diterbitkan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil kwitansi: " + err.Error()})
+		return
+	}
+	ctrl.kirimPDF(c, *kwitansi)
+}
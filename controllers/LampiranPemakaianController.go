@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lampiran disimpan di luar direktori static agar hanya bisa diunduh lewat endpoint terautentikasi
+const (
+	dirLampiranPemakaian = "./lampiran/pemakaian/"
+	maksUkuranLampiran   = 5 << 20
+	maksLampiran         = 10 // per pemakaian
+	lebarThumbnail       = 240
+	blokThumbnail        = 6
+)
+
+// Tipe berkas lampiran yang diizinkan beserta ekstensi simpannya
+var tipeLampiran = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+type LampiranPemakaianController struct {
+	db *gorm.DB
+}
+
+func NewLampiranPemakaianController(db *gorm.DB) *LampiranPemakaianController {
+	return &LampiranPemakaianController{db: db}
+}
+
+type UpdateLampiranRequest struct {
+	TampilPublik *bool `json:"tampil_publik" binding:"required"`
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *LampiranPemakaianController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// ambilPemakaian memastikan pemakaian pada parameter :id ada
+func (ctrl *LampiranPemakaianController) ambilPemakaian(c *gin.Context) (*models.PemakaianSaldo, bool) {
+	var pemakaian models.PemakaianSaldo
+	if err := ctrl.db.Where("id_pemakaian = ?", c.Param("id")).First(&pemakaian).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data pemakaian saldo tidak ditemukan"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pemakaian saldo: " + err.Error()})
+		return nil, false
+	}
+	return &pemakaian, true
+}
+
+// ambilLampiran mengambil lampiran :id_lampiran milik pemakaian :id
+func (ctrl *LampiranPemakaianController) ambilLampiran(c *gin.Context) (*models.LampiranPemakaian, bool) {
+	var lampiran models.LampiranPemakaian
+	err := ctrl.db.Where("id_lampiran = ? AND id_pemakaian = ?", c.Param("id_lampiran"), c.Param("id")).First(&lampiran).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Lampiran tidak ditemukan"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil lampiran: " + err.Error()})
+		return nil, false
+	}
+	return &lampiran, true
+}
+
+// simpanLampiran memvalidasi tipe (dari isi berkas, bukan ekstensi) dan ukuran berkas, menyimpannya,
+// lalu membuat thumbnail tersamar untuk gambar yang bisa didekode
+func (ctrl *LampiranPemakaianController) simpanLampiran(file *multipart.FileHeader, idPemakaian, adminID string, publik bool) (*models.LampiranPemakaian, error) {
+	if file.Size > maksUkuranLampiran {
+		return nil, fmt.Errorf("%s: ukuran file terlalu besar. Maksimal 5MB", file.Filename)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: gagal membaca file: %v", file.Filename, err)
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, maksUkuranLampiran+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: gagal membaca file: %v", file.Filename, err)
+	}
+	if len(data) > maksUkuranLampiran {
+		return nil, fmt.Errorf("%s: ukuran file terlalu besar. Maksimal 5MB", file.Filename)
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := tipeLampiran[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%s: tipe file tidak diizinkan. Gunakan JPEG, PNG, GIF, WebP, atau PDF", file.Filename)
+	}
+
+	if err := os.MkdirAll(dirLampiranPemakaian, 0750); err != nil {
+		return nil, fmt.Errorf("gagal membuat folder: %v", err)
+	}
+
+	id := uuid.New().String()
+	lampiran := &models.LampiranPemakaian{
+		IDLampiran:   id,
+		IDPemakaian:  idPemakaian,
+		NamaAsli:     filepath.Base(file.Filename),
+		NamaBerkas:   id + ext,
+		TipeBerkas:   contentType,
+		Ukuran:       int64(len(data)),
+		TampilPublik: publik,
+		DiunggahOleh: adminID,
+	}
+	if err := os.WriteFile(filepath.Join(dirLampiranPemakaian, lampiran.NamaBerkas), data, 0640); err != nil {
+		return nil, fmt.Errorf("%s: gagal menyimpan file: %v", file.Filename, err)
+	}
+
+	// PDF dan WebP tidak punya thumbnail; lampirannya tetap tersimpan
+	if thumb, err := utils.ThumbnailSamar(data, lebarThumbnail, blokThumbnail); err == nil {
+		nama := id + "_thumb.jpg"
+		if err := os.WriteFile(filepath.Join(dirLampiranPemakaian, nama), thumb, 0640); err == nil {
+			lampiran.Thumbnail = &nama
+		}
+	}
+	return lampiran, nil
+}
+
+// hapusBerkasLampiran menghapus berkas lampiran beserta thumbnailnya dari disk
+func hapusBerkasLampiran(lampiran []models.LampiranPemakaian) {
+	for _, l := range lampiran {
+		os.Remove(filepath.Join(dirLampiranPemakaian, l.NamaBerkas))
+		if l.Thumbnail != nil {
+			os.Remove(filepath.Join(dirLampiranPemakaian, *l.Thumbnail))
+		}
+	}
+}
+
+// hitungLampiran mengembalikan jumlah lampiran per id pemakaian
+func hitungLampiran(db *gorm.DB, ids []string) (map[string]int64, error) {
+	hasil := map[string]int64{}
+	if len(ids) == 0 {
+		return hasil, nil
+	}
+	var baris []struct {
+		IDPemakaian string
+		Jumlah      int64
+	}
+	err := db.Model(&models.LampiranPemakaian{}).
+		Select("id_pemakaian, COUNT(*) AS jumlah").
+		Where("id_pemakaian IN ?", ids).
+		Group("id_pemakaian").
+		Scan(&baris).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range baris {
+		hasil[b.IDPemakaian] = b.Jumlah
+	}
+	return hasil, nil
+}
+
+// UploadLampiran mengunggah satu atau beberapa lampiran (form field "lampiran") untuk satu pemakaian.
+// Form field "tampil_publik" menandai thumbnail tersamarnya boleh tampil di halaman transparansi.
+func (ctrl *LampiranPemakaianController) UploadLampiran(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+	pemakaian, ok := ctrl.ambilPemakaian(c)
+	if !ok {
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["lampiran"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File lampiran wajib diunggah (field 'lampiran')"})
+		return
+	}
+	files := form.File["lampiran"]
+	publik, _ := strconv.ParseBool(c.PostForm("tampil_publik"))
+
+	// Berkas divalidasi dan disimpan dulu; jika satu gagal, berkas yang sudah tersimpan dibuang
+	var lampiran []models.LampiranPemakaian
+	for _, file := range files {
+		l, err := ctrl.simpanLampiran(file, pemakaian.IDPemakaian, adminID, publik)
+		if err != nil {
+			hapusBerkasLampiran(lampiran)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		lampiran = append(lampiran, *l)
+	}
+
+	// Baris pemakaian dikunci agar unggahan bersamaan tidak sama-sama lolos batas maksLampiran
+	var jumlah int64
+	errBatas := errors.New("batas lampiran terlampaui")
+	err = ctrl.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id_pemakaian = ?", pemakaian.IDPemakaian).First(&models.PemakaianSaldo{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.LampiranPemakaian{}).Where("id_pemakaian = ?", pemakaian.IDPemakaian).Count(&jumlah).Error; err != nil {
+			return err
+		}
+		if int(jumlah)+len(lampiran) > maksLampiran {
+			return errBatas
+		}
+		return tx.Create(&lampiran).Error
+	})
+	if err != nil {
+		hapusBerkasLampiran(lampiran)
+		if errors.Is(err, errBatas) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Maksimal %d lampiran per pemakaian, sudah ada %d", maksLampiran, jumlah)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan lampiran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": fmt.Sprintf("%d lampiran berhasil diunggah", len(lampiran)),
+		"data":    lampiran,
+	})
+}
+
+// GetLampiran mendapatkan daftar lampiran satu pemakaian
+func (ctrl *LampiranPemakaianController) GetLampiran(c *gin.Context) {
+	pemakaian, ok := ctrl.ambilPemakaian(c)
+	if !ok {
+		return
+	}
+
+	var lampiran []models.LampiranPemakaian
+	if err := ctrl.db.Where("id_pemakaian = ?", pemakaian.IDPemakaian).Order("diunggah_pada ASC").Find(&lampiran).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil lampiran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": lampiran,
+	})
+}
+
+// UnduhLampiran mengirim berkas asli lampiran. Gambar dan PDF ditampilkan inline di browser.
+func (ctrl *LampiranPemakaianController) UnduhLampiran(c *gin.Context) {
+	lampiran, ok := ctrl.ambilLampiran(c)
+	if !ok {
+		return
+	}
+
+	path := filepath.Join(dirLampiranPemakaian, lampiran.NamaBerkas)
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Berkas lampiran tidak ditemukan"})
+		return
+	}
+
+	c.Header("Content-Type", lampiran.TipeBerkas)
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", lampiran.NamaAsli))
+	c.Header("Cache-Control", "private, no-store")
+	c.File(path)
+}
+
+// UpdateLampiran mengubah apakah thumbnail tersamar lampiran boleh tampil di halaman transparansi
+func (ctrl *LampiranPemakaianController) UpdateLampiran(c *gin.Context) {
+	var req UpdateLampiranRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	lampiran, ok := ctrl.ambilLampiran(c)
+	if !ok {
+		return
+	}
+
+	lampiran.TampilPublik = *req.TampilPublik
+	if err := ctrl.db.Model(lampiran).Update("tampil_publik", lampiran.TampilPublik).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate lampiran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Lampiran berhasil diupdate",
+		"data":    lampiran,
+	})
+}
+
+// DeleteLampiran menghapus satu lampiran beserta berkasnya
+func (ctrl *LampiranPemakaianController) DeleteLampiran(c *gin.Context) {
+	lampiran, ok := ctrl.ambilLampiran(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.db.Delete(lampiran).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus lampiran: " + err.Error()})
+		return
+	}
+	hapusBerkasLampiran([]models.LampiranPemakaian{*lampiran})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Lampiran berhasil dihapus",
+	})
+}
+
+// GetThumbnailPublik mengirim thumbnail tersamar lampiran yang ditandai tampil publik,
+// hanya untuk pemakaian yang sudah dicairkan
+func (ctrl *LampiranPemakaianController) GetThumbnailPublik(c *gin.Context) {
+	var lampiran models.LampiranPemakaian
+	err := ctrl.db.Joins("JOIN pemakaian_saldo ON pemakaian_saldo.id_pemakaian = lampiran_pemakaian.id_pemakaian").
+		Where("lampiran_pemakaian.id_lampiran = ? AND lampiran_pemakaian.id_pemakaian = ?", c.Param("id_lampiran"), c.Param("id")).
+		Where("lampiran_pemakaian.tampil_publik = ? AND lampiran_pemakaian.thumbnail IS NOT NULL", true).
+		Where("pemakaian_saldo.status = ?", models.PemakaianDicairkan).
+		First(&lampiran).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil thumbnail: " + err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.File(filepath.Join(dirLampiranPemakaian, *lampiran.Thumbnail))
+}
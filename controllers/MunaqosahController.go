@@ -0,0 +1,353 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MunaqosahController struct {
+	db *gorm.DB
+}
+
+func NewMunaqosahController(db *gorm.DB) *MunaqosahController {
+	return &MunaqosahController{db: db}
+}
+
+// Request structs
+type CreateMunaqosahRequest struct {
+	IDSantri    string `json:"id_santri" binding:"required"`
+	Jilid       int    `json:"jilid" binding:"required"`
+	JadwalUjian string `json:"jadwal_ujian" binding:"required"` // format YYYY-MM-DD HH:MM
+	IDPenguji   string `json:"id_penguji" binding:"required"`
+}
+
+type UpdateMunaqosahRequest struct {
+	JadwalUjian *string `json:"jadwal_ujian"` // format YYYY-MM-DD HH:MM
+	IDPenguji   *string `json:"id_penguji"`
+}
+
+type NilaiMunaqosahRequest struct {
+	NilaiMakhraj    *int   `json:"nilai_makhraj" binding:"required"`
+	NilaiTajwid     *int   `json:"nilai_tajwid" binding:"required"`
+	NilaiKelancaran *int   `json:"nilai_kelancaran" binding:"required"`
+	Catatan         string `json:"catatan"`
+	TanggalUjian    string `json:"tanggal_ujian"` // format YYYY-MM-DD, kosong = hari ini
+}
+
+// Helper function untuk check role admin
+func (ctrl *MunaqosahController) isAdmin(c *gin.Context) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		return false
+	}
+	role := userRole.(string)
+	return role == "admin" || role == "super_admin"
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *MunaqosahController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// parseJadwal membaca jadwal ujian dalam format YYYY-MM-DD HH:MM waktu lokal
+func (ctrl *MunaqosahController) parseJadwal(s string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+}
+
+// ambilMunaqosah mengambil munaqosah berdasarkan ID di URL. Menulis response error jika gagal.
+func (ctrl *MunaqosahController) ambilMunaqosah(c *gin.Context) (*models.Munaqosah, bool) {
+	var munaqosah models.Munaqosah
+	if err := ctrl.db.Where("id_munaqosah = ?", c.Param("id")).First(&munaqosah).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data munaqosah tidak ditemukan"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data munaqosah: " + err.Error()})
+		return nil, false
+	}
+	return &munaqosah, true
+}
+
+// errorMunaqosah memetakan error service munaqosah ke response HTTP
+func (ctrl *MunaqosahController) errorMunaqosah(c *gin.Context, pesan string, err error) {
+	switch {
+	case errors.Is(err, services.ErrMunaqosahTidakValid), errors.Is(err, services.ErrSantriTidakAktif):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMunaqosahSelesai):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": pesan + ": " + err.Error()})
+	}
+}
+
+// GetRubrikMunaqosah mendapatkan rubrik penilaian dan batas kelulusan munaqosah
+func (ctrl *MunaqosahController) GetRubrikMunaqosah(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data":                services.RubrikMunaqosah,
+		"nilai_lulus":         services.NilaiLulusMunaqosah,
+		"nilai_minimal_aspek": services.NilaiMinimalAspek,
+	})
+}
+
+// GetAllMunaqosah mendapatkan daftar munaqosah. Admin melihat semua, ustadz hanya yang ia uji.
+// Query: status, jilid, id_santri, id_penguji, dari, sampai (tanggal jadwal YYYY-MM-DD)
+func (ctrl *MunaqosahController) GetAllMunaqosah(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := ctrl.db.Model(&models.Munaqosah{})
+	if !ctrl.isAdmin(c) {
+		userID, _ := ctrl.getUserID(c)
+		query = query.Where("id_penguji = ?", userID)
+	} else if idPenguji := c.Query("id_penguji"); idPenguji != "" {
+		query = query.Where("id_penguji = ?", idPenguji)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jilid := c.Query("jilid"); jilid != "" {
+		query = query.Where("jilid = ?", jilid)
+	}
+	if idSantri := c.Query("id_santri"); idSantri != "" {
+		query = query.Where("id_santri = ?", idSantri)
+	}
+	if dari := c.Query("dari"); dari != "" {
+		t, err := parseDate(dari)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format tanggal dari tidak valid. Gunakan format YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("jadwal_ujian >= ?", t)
+	}
+	if sampai := c.Query("sampai"); sampai != "" {
+		t, err := parseDate(sampai)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format tanggal sampai tidak valid. Gunakan format YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("jadwal_ujian < ?", t.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	var munaqosah []models.Munaqosah
+	offset := (page - 1) * limit
+	if err := query.Preload("Santri").Preload("Penguji").
+		Order("jadwal_ujian DESC").Offset(offset).Limit(limit).Find(&munaqosah).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data munaqosah: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": munaqosah,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// CreateMunaqosah menjadwalkan munaqosah kenaikan jilid untuk santri (admin only)
+func (ctrl *MunaqosahController) CreateMunaqosah(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req CreateMunaqosahRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jadwal, err := ctrl.parseJadwal(req.JadwalUjian)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format jadwal ujian tidak valid. Gunakan format YYYY-MM-DD HH:MM"})
+		return
+	}
+
+	munaqosah := models.Munaqosah{
+		IDSantri:        req.IDSantri,
+		Jilid:           req.Jilid,
+		JadwalUjian:     jadwal,
+		IDPenguji:       req.IDPenguji,
+		DijadwalkanOleh: adminID,
+	}
+	if err := services.JadwalkanMunaqosah(ctrl.db, &munaqosah); err != nil {
+		ctrl.errorMunaqosah(c, "Gagal menjadwalkan munaqosah", err)
+		return
+	}
+
+	ctrl.db.Preload("Santri").Preload("Penguji").First(&munaqosah, "id_munaqosah = ?", munaqosah.IDMunaqosah)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Munaqosah berhasil dijadwalkan",
+		"data":    munaqosah,
+	})
+}
+
+// UpdateMunaqosah mengubah jadwal atau penguji munaqosah yang belum dinilai (admin only)
+func (ctrl *MunaqosahController) UpdateMunaqosah(c *gin.Context) {
+	munaqosah, ok := ctrl.ambilMunaqosah(c)
+	if !ok {
+		return
+	}
+	if munaqosah.Status != models.MunaqosahDijadwalkan {
+		c.JSON(http.StatusConflict, gin.H{"error": services.ErrMunaqosahSelesai.Error()})
+		return
+	}
+
+	var req UpdateMunaqosahRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updateData := make(map[string]interface{})
+	if req.JadwalUjian != nil {
+		jadwal, err := ctrl.parseJadwal(*req.JadwalUjian)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format jadwal ujian tidak valid. Gunakan format YYYY-MM-DD HH:MM"})
+			return
+		}
+		updateData["jadwal_ujian"] = jadwal
+	}
+	if req.IDPenguji != nil {
+		var penguji models.User
+		if err := ctrl.db.Where("id_user = ? AND role IN ?", *req.IDPenguji,
+			[]models.UserRole{models.RoleUstadz, models.RoleAdmin, models.RoleSuperAdmin}).
+			First(&penguji).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Penguji harus ustadz atau admin yang terdaftar"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data penguji: " + err.Error()})
+			return
+		}
+		updateData["id_penguji"] = penguji.IDUser
+	}
+	if len(updateData) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak ada data yang diubah"})
+		return
+	}
+
+	if err := ctrl.db.Model(munaqosah).Updates(updateData).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate munaqosah: " + err.Error()})
+		return
+	}
+
+	ctrl.db.Preload("Santri").Preload("Penguji").First(munaqosah, "id_munaqosah = ?", munaqosah.IDMunaqosah)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Munaqosah berhasil diupdate",
+		"data":    munaqosah,
+	})
+}
+
+// BatalkanMunaqosah membatalkan munaqosah yang belum dinilai (admin only). Percobaan yang batal
+// tidak dihitung sebagai percobaan ujian.
+func (ctrl *MunaqosahController) BatalkanMunaqosah(c *gin.Context) {
+	munaqosah, ok := ctrl.ambilMunaqosah(c)
+	if !ok {
+		return
+	}
+
+	hasil := ctrl.db.Model(&models.Munaqosah{}).
+		Where("id_munaqosah = ? AND status = ?", munaqosah.IDMunaqosah, models.MunaqosahDijadwalkan).
+		Update("status", models.MunaqosahBatal)
+	if hasil.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membatalkan munaqosah: " + hasil.Error.Error()})
+		return
+	}
+	if hasil.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": services.ErrMunaqosahSelesai.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Munaqosah berhasil dibatalkan",
+	})
+}
+
+// NilaiMunaqosah memasukkan nilai rubrik munaqosah (admin atau penguji yang ditunjuk).
+// Santri yang lulus otomatis naik jilid pada perkembangan belajarnya.
+func (ctrl *MunaqosahController) NilaiMunaqosah(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	munaqosah, ok := ctrl.ambilMunaqosah(c)
+	if !ok {
+		return
+	}
+	if !ctrl.isAdmin(c) && munaqosah.IDPenguji != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya penguji yang ditunjuk atau admin yang dapat menilai"})
+		return
+	}
+
+	var req NilaiMunaqosahRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tanggal := time.Now()
+	tanggal = time.Date(tanggal.Year(), tanggal.Month(), tanggal.Day(), 0, 0, 0, 0, time.Local)
+	if req.TanggalUjian != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.TanggalUjian, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format tanggal ujian tidak valid. Gunakan format YYYY-MM-DD"})
+			return
+		}
+		if t.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tanggal ujian tidak boleh di masa depan"})
+			return
+		}
+		tanggal = t
+	}
+
+	if err := services.NilaiMunaqosah(ctrl.db, munaqosah, *req.NilaiMakhraj, *req.NilaiTajwid, *req.NilaiKelancaran,
+		req.Catatan, tanggal, userID); err != nil {
+		ctrl.errorMunaqosah(c, "Gagal menyimpan nilai munaqosah", err)
+		return
+	}
+
+	ctrl.db.Preload("Santri").Preload("Penguji").First(munaqosah, "id_munaqosah = ?", munaqosah.IDMunaqosah)
+
+	message := "Santri belum lulus munaqosah dan perlu mengulang"
+	if munaqosah.Status == models.MunaqosahLulus {
+		message = "Santri lulus munaqosah"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data":    munaqosah,
+	})
+}
@@ -1,12 +1,16 @@
 package controllers
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -27,9 +31,13 @@ type CreatePemakaianRequest struct {
 	Deskripsi        string                `json:"deskripsi" binding:"required"`
 	NominalSyahriah  float64               `json:"nominal_syahriah"`
 	NominalDonasi    float64               `json:"nominal_donasi"`
+	RincianDana      []RincianDanaRequest  `json:"rincian_dana"` // jika diisi, menggantikan nominal_syahriah dan nominal_donasi
 	TipePemakaian    models.TipePemakaian  `json:"tipe_pemakaian" binding:"required"`
 	TanggalPemakaian *string               `json:"tanggal_pemakaian"`
 	Keterangan       *string               `json:"keterangan"`
+	IDKampanye       *string               `json:"id_kampanye"` // memakai dana terikat kampanye
+	IDAnggaran       *string               `json:"id_anggaran"` // pos RAPB yang dibebani
+	IDRekening       *string               `json:"id_rekening"` // rekening sumber uang, kosong = rekening utama
 }
 
 type UpdatePemakaianRequest struct {
@@ -37,9 +45,24 @@ type UpdatePemakaianRequest struct {
 	Deskripsi        *string               `json:"deskripsi"`
 	NominalSyahriah  *float64              `json:"nominal_syahriah"`
 	NominalDonasi    *float64              `json:"nominal_donasi"`
+	RincianDana      []RincianDanaRequest  `json:"rincian_dana"` // jika diisi, menggantikan seluruh rincian dana
 	TipePemakaian    *models.TipePemakaian `json:"tipe_pemakaian"`
 	TanggalPemakaian *string               `json:"tanggal_pemakaian"`
 	Keterangan       *string               `json:"keterangan"`
+	IDKampanye       *string               `json:"id_kampanye"` // string kosong = lepas dari kampanye
+	IDAnggaran       *string               `json:"id_anggaran"` // string kosong = lepas dari pos anggaran
+	IDRekening       *string               `json:"id_rekening"`
+}
+
+type RincianDanaRequest struct {
+	KodeDana string  `json:"kode_dana" binding:"required"`
+	Nominal  float64 `json:"nominal"`
+}
+
+type AksiPemakaianRequest struct {
+	Komentar         string  `json:"komentar"`
+	TanggalPemakaian *string `json:"tanggal_pemakaian"` // hanya untuk pencairan, format YYYY-MM-DD
+	IDRekening       *string `json:"id_rekening"`       // hanya untuk pencairan, rekening sumber uang
 }
 
 type PemakaianSummary struct {
@@ -68,7 +91,95 @@ func (ctrl *PemakaianSaldoController) getUserID(c *gin.Context) (string, bool) {
 	return userID.(string), true
 }
 
-// CreatePemakaian membuat data pemakaian saldo baru
+// Helper function untuk get role dari context
+func (ctrl *PemakaianSaldoController) getRole(c *gin.Context) models.UserRole {
+	role, _ := c.Get("role")
+	peran, _ := role.(string)
+	return models.UserRole(peran)
+}
+
+// statusErrorPemakaian memetakan error layanan pemakaian ke status HTTP
+func (ctrl *PemakaianSaldoController) statusErrorPemakaian(c *gin.Context, err error, pesan string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Data pemakaian saldo tidak ditemukan"})
+	case errors.Is(err, services.ErrPeriodeDitutup), errors.Is(err, services.ErrBukanPenyetuju):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSaldoTidakCukup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Saldo tidak mencukupi: " + err.Error()})
+	case errors.Is(err, services.ErrDanaTerikat), errors.Is(err, services.ErrAnggaranTidakSesuai),
+		errors.Is(err, services.ErrDanaDibatasi), errors.Is(err, services.ErrDanaTidakValid),
+		errors.Is(err, services.ErrRekeningTidakValid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrStatusPemakaian):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": pesan + ": " + err.Error()})
+	}
+}
+
+// totalDana adalah total nominal satu dana pada ringkasan
+type totalDana struct {
+	KodeDana string  `json:"kode_dana"`
+	NamaDana string  `json:"nama_dana"`
+	Total    float64 `json:"total"`
+}
+
+// rincianDanaPublik meringkas rincian dana pemakaian untuk endpoint public
+func rincianDanaPublik(rincian []models.PemakaianDana) []gin.H {
+	hasil := make([]gin.H, len(rincian))
+	for i, r := range rincian {
+		namaDana := r.KodeDana
+		if r.Dana != nil {
+			namaDana = r.Dana.NamaDana
+		}
+		hasil[i] = gin.H{"kode_dana": r.KodeDana, "nama_dana": namaDana, "nominal": r.Nominal}
+	}
+	return hasil
+}
+
+// rincianDana menyusun rincian dana pemakaian dari request. Nominal per dana tidak boleh negatif.
+func (ctrl *PemakaianSaldoController) rincianDana(rincian []RincianDanaRequest) ([]models.PemakaianDana, error) {
+	hasil := make([]models.PemakaianDana, 0, len(rincian))
+	for _, r := range rincian {
+		if r.KodeDana == "" {
+			return nil, errors.New("kode_dana wajib diisi pada rincian dana")
+		}
+		if r.Nominal < 0 {
+			return nil, fmt.Errorf("nominal dana %s tidak boleh negatif", r.KodeDana)
+		}
+		hasil = append(hasil, models.PemakaianDana{KodeDana: r.KodeDana, Nominal: r.Nominal})
+	}
+	return hasil, nil
+}
+
+// cekKampanye memastikan kampanye yang dananya dipakai ada. String kosong berarti tanpa kampanye.
+func (ctrl *PemakaianSaldoController) cekKampanye(idKampanye *string) (*string, error) {
+	if idKampanye == nil || *idKampanye == "" {
+		return nil, nil
+	}
+	var kampanye models.Kampanye
+	if err := ctrl.db.Where("id_kampanye = ?", *idKampanye).First(&kampanye).Error; err != nil {
+		return nil, err
+	}
+	return &kampanye.IDKampanye, nil
+}
+
+// peringatanAnggaran mengembalikan posisi pos anggaran jika pemakaian membuatnya terlampaui.
+// Pemakaian tetap disimpan; peringatan ini hanya ditampilkan ke admin.
+func (ctrl *PemakaianSaldoController) peringatanAnggaran(pemakaian models.PemakaianSaldo) *services.StatusAnggaran {
+	if pemakaian.IDAnggaran == nil {
+		return nil
+	}
+	status, err := services.CekAnggaran(ctrl.db, *pemakaian.IDAnggaran, pemakaian.NominalTotal, pemakaian.IDPemakaian)
+	if err != nil || !status.Melebihi {
+		return nil
+	}
+	return status
+}
+
+// CreatePemakaian mengajukan pemakaian saldo baru. Saldo baru berkurang setelah pengajuan
+// disetujui dan dicairkan.
 func (ctrl *PemakaianSaldoController) CreatePemakaian(c *gin.Context) {
 	// Hanya admin yang bisa create
 	if !ctrl.isAdmin(c) {
@@ -97,7 +208,7 @@ func (ctrl *PemakaianSaldoController) CreatePemakaian(c *gin.Context) {
 		return
 	}
 
-	// Validasi nominal
+	// Validasi nominal. Tanpa rincian_dana, nominal_syahriah dan nominal_donasi tetap dipakai untuk klien lama.
 	if req.NominalSyahriah < 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Nominal syahriah tidak boleh negatif"})
 		return
@@ -106,10 +217,15 @@ func (ctrl *PemakaianSaldoController) CreatePemakaian(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Nominal donasi tidak boleh negatif"})
 		return
 	}
-
-	nominalTotal := req.NominalSyahriah + req.NominalDonasi
-	if nominalTotal <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Total nominal harus lebih besar dari 0"})
+	if len(req.RincianDana) == 0 {
+		req.RincianDana = []RincianDanaRequest{
+			{KodeDana: models.KodeDanaSyahriah, Nominal: req.NominalSyahriah},
+			{KodeDana: models.KodeDanaDonasi, Nominal: req.NominalDonasi},
+		}
+	}
+	rincian, err := ctrl.rincianDana(req.RincianDana)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -128,9 +244,9 @@ func (ctrl *PemakaianSaldoController) CreatePemakaian(c *gin.Context) {
 		tanggalPemakaian = &today
 	}
 
-	// Cek saldo tersedia
-	if !ctrl.cekSaldoTersedia(req.NominalSyahriah, req.NominalDonasi) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Saldo tidak mencukupi"})
+	idKampanye, err := ctrl.cekKampanye(req.IDKampanye)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kampanye tidak ditemukan"})
 		return
 	}
 
@@ -139,33 +255,37 @@ func (ctrl *PemakaianSaldoController) CreatePemakaian(c *gin.Context) {
 		IDPemakaian:      uuid.New().String(),
 		JudulPemakaian:   req.JudulPemakaian,
 		Deskripsi:        req.Deskripsi,
-		NominalSyahriah:  req.NominalSyahriah,
-		NominalDonasi:    req.NominalDonasi,
-		NominalTotal:     nominalTotal,
+		Rincian:          rincian,
 		TipePemakaian:    req.TipePemakaian,
 		TanggalPemakaian: tanggalPemakaian,
 		DiajukanOleh:     adminID,
 		Keterangan:       req.Keterangan,
+		IDKampanye:       idKampanye,
+		IDRekening:       req.IDRekening,
+	}
+	if req.IDAnggaran != nil && *req.IDAnggaran != "" {
+		pemakaian.IDAnggaran = req.IDAnggaran
 	}
 
-	// Simpan ke database
-	if err := ctrl.db.Create(&pemakaian).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat data pemakaian saldo: " + err.Error()})
+	services.RapikanRincian(&pemakaian)
+	if pemakaian.NominalTotal <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Total nominal harus lebih besar dari 0"})
 		return
 	}
 
-	// Preload relations untuk response
-	ctrl.db.Preload("Pengaju").First(&pemakaian, "id_pemakaian = ?", pemakaian.IDPemakaian)
-
-	// Update rekap saldo (kurangi saldo)
-	if err := ctrl.updateRekapSaldoSetelahPemakaian(pemakaian); err != nil {
-		// Log error tapi jangan gagalkan create
-		fmt.Printf("Gagal update rekap saldo: %v\n", err)
+	// Simpan pengajuan beserta riwayatnya; jurnal menunggu pencairan
+	if _, err := services.NewKeuanganService(ctrl.db).CreatePemakaian(&pemakaian, adminID); err != nil {
+		ctrl.statusErrorPemakaian(c, err, "Gagal membuat data pemakaian saldo")
+		return
 	}
 
+	// Preload relations untuk response
+	ctrl.db.Preload("Pengaju").Preload("Kampanye").Preload("Anggaran").Preload("Rincian.Dana").Preload("Rekening").First(&pemakaian, "id_pemakaian = ?", pemakaian.IDPemakaian)
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Data pemakaian saldo berhasil dibuat",
-		"data":    pemakaian,
+		"message":             "Pengajuan pemakaian saldo berhasil dibuat",
+		"data":                pemakaian,
+		"peringatan_anggaran": ctrl.peringatanAnggaran(pemakaian),
 	})
 }
 
@@ -174,12 +294,6 @@ func (ctrl *PemakaianSaldoController) GetAllPemakaian(c *gin.Context) {
 	// Parse query parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	tipePemakaian := c.Query("tipe_pemakaian")
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
-	search := c.Query("search")
-	sortBy := c.DefaultQuery("sort_by", "created_at")
-	sortOrder := c.DefaultQuery("sort_order", "desc")
 
 	if page < 1 {
 		page = 1
@@ -192,30 +306,89 @@ func (ctrl *PemakaianSaldoController) GetAllPemakaian(c *gin.Context) {
 	var total int64
 
 	// Build query
-	query := ctrl.db.Preload("Pengaju")
+	query := ctrl.filterPemakaian(c, ctrl.db.Preload("Pengaju").Preload("Kampanye").Preload("Anggaran").Preload("Rincian.Dana").Preload("Rekening"))
+	orderClause := ctrl.urutanPemakaian(c)
 
-	// Apply filters
-	if tipePemakaian != "" {
-		query = query.Where("tipe_pemakaian = ?", tipePemakaian)
+	// Hitung total records
+	if err := query.Model(&models.PemakaianSaldo{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
 	}
-	if startDate != "" {
+
+	// Apply pagination
+	offset := (page - 1) * limit
+	err := query.Order(orderClause).
+		Offset(offset).
+		Limit(limit).
+		Find(&pemakaian).Error
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pemakaian saldo: " + err.Error()})
+		return
+	}
+
+	ids := make([]string, len(pemakaian))
+	for i, p := range pemakaian {
+		ids[i] = p.IDPemakaian
+	}
+	jumlahLampiran, err := hitungLampiran(ctrl.db, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung lampiran: " + err.Error()})
+		return
+	}
+	for i := range pemakaian {
+		pemakaian[i].JumlahLampiran = jumlahLampiran[pemakaian[i].IDPemakaian]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": pemakaian,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// filterPemakaian menerapkan filter GetAllPemakaian: tipe_pemakaian, start_date, end_date, id_kampanye, id_anggaran, id_rekening dan search
+func (ctrl *PemakaianSaldoController) filterPemakaian(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if tipePemakaian := c.Query("tipe_pemakaian"); tipePemakaian != "" {
+		query = query.Where("pemakaian_saldo.tipe_pemakaian = ?", tipePemakaian)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
 		start, err := time.Parse("2006-01-02", startDate)
 		if err == nil {
-			query = query.Where("DATE(created_at) >= ?", start.Format("2006-01-02"))
+			query = query.Where("DATE(pemakaian_saldo.created_at) >= ?", start.Format("2006-01-02"))
 		}
 	}
-	if endDate != "" {
+	if endDate := c.Query("end_date"); endDate != "" {
 		end, err := time.Parse("2006-01-02", endDate)
 		if err == nil {
-			query = query.Where("DATE(created_at) <= ?", end.Format("2006-01-02"))
+			query = query.Where("DATE(pemakaian_saldo.created_at) <= ?", end.Format("2006-01-02"))
 		}
 	}
-	if search != "" {
+	if idKampanye := c.Query("id_kampanye"); idKampanye != "" {
+		query = query.Where("pemakaian_saldo.id_kampanye = ?", idKampanye)
+	}
+	if idAnggaran := c.Query("id_anggaran"); idAnggaran != "" {
+		query = query.Where("pemakaian_saldo.id_anggaran = ?", idAnggaran)
+	}
+	if idRekening := c.Query("id_rekening"); idRekening != "" {
+		query = query.Where("pemakaian_saldo.id_rekening = ?", idRekening)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("pemakaian_saldo.status = ?", status)
+	}
+	if search := c.Query("search"); search != "" {
 		searchPattern := "%" + search + "%"
-		query = query.Where("judul_pemakaian LIKE ? OR deskripsi LIKE ?", searchPattern, searchPattern)
+		query = query.Where("pemakaian_saldo.judul_pemakaian LIKE ? OR pemakaian_saldo.deskripsi LIKE ?", searchPattern, searchPattern)
 	}
+	return query
+}
 
-	// Validate sort parameters
+// urutanPemakaian membaca sort_by dan sort_order yang diizinkan, default created_at DESC
+func (ctrl *PemakaianSaldoController) urutanPemakaian(c *gin.Context) string {
 	allowedSortFields := map[string]bool{
 		"created_at":        true,
 		"updated_at":        true,
@@ -224,44 +397,53 @@ func (ctrl *PemakaianSaldoController) GetAllPemakaian(c *gin.Context) {
 		"tanggal_pemakaian": true,
 	}
 
-	sortField := sortBy
+	sortField := c.DefaultQuery("sort_by", "created_at")
 	if !allowedSortFields[sortField] {
 		sortField = "created_at"
 	}
 
 	sortDirection := "DESC"
-	if sortOrder == "asc" {
+	if c.DefaultQuery("sort_order", "desc") == "asc" {
 		sortDirection = "ASC"
 	}
 
-	orderClause := sortField + " " + sortDirection
-
-	// Hitung total records
-	if err := query.Model(&models.PemakaianSaldo{}).Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
-		return
-	}
-
-	// Apply pagination
-	offset := (page - 1) * limit
-	err := query.Order(orderClause).
-		Offset(offset).
-		Limit(limit).
-		Find(&pemakaian).Error
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pemakaian saldo: " + err.Error()})
-		return
-	}
+	return "pemakaian_saldo." + sortField + " " + sortDirection
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": pemakaian,
-		"meta": gin.H{
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"total_page": (int(total) + limit - 1) / limit,
-		},
+// ExportPemakaian mengunduh data pemakaian saldo sebagai XLSX atau CSV dengan filter yang sama seperti GetAllPemakaian
+func (ctrl *PemakaianSaldoController) ExportPemakaian(c *gin.Context) {
+	type barisPemakaian struct {
+		JudulPemakaian   string
+		Deskripsi        string
+		TipePemakaian    string
+		Status           string
+		NominalSyahriah  float64
+		NominalDonasi    float64
+		NominalTotal     float64
+		TanggalPemakaian *time.Time
+		NamaPengaju      string
+		Keterangan       *string
+		CreatedAt        time.Time
+	}
+
+	query := ctrl.db.Table("pemakaian_saldo").
+		Select("pemakaian_saldo.judul_pemakaian, pemakaian_saldo.deskripsi, pemakaian_saldo.tipe_pemakaian, pemakaian_saldo.status, " +
+			"pemakaian_saldo.nominal_syahriah, pemakaian_saldo.nominal_donasi, pemakaian_saldo.nominal_total, " +
+			"pemakaian_saldo.tanggal_pemakaian, users.nama_lengkap AS nama_pengaju, pemakaian_saldo.keterangan, pemakaian_saldo.created_at").
+		Joins("LEFT JOIN users ON users.id_user = pemakaian_saldo.diajukan_oleh")
+	query = ctrl.filterPemakaian(c, query).Order(ctrl.urutanPemakaian(c))
+
+	header := []interface{}{"Judul", "Deskripsi", "Tipe", "Status", "Dari Syahriah", "Dari Donasi", "Total", "Tanggal Pemakaian", "Diajukan Oleh", "Keterangan", "Dibuat"}
+	kirimEkspor(c, "pemakaian", header, query, func(rows *sql.Rows) ([]interface{}, error) {
+		var b barisPemakaian
+		if err := ctrl.db.ScanRows(rows, &b); err != nil {
+			return nil, err
+		}
+		var tanggal interface{}
+		if b.TanggalPemakaian != nil {
+			tanggal = *b.TanggalPemakaian
+		}
+		return []interface{}{b.JudulPemakaian, b.Deskripsi, b.TipePemakaian, b.Status, b.NominalSyahriah, b.NominalDonasi, b.NominalTotal, tanggal, b.NamaPengaju, b.Keterangan, b.CreatedAt}, nil
 	})
 }
 
@@ -274,7 +456,11 @@ func (ctrl *PemakaianSaldoController) GetPemakaianByID(c *gin.Context) {
 	}
 
 	var pemakaian models.PemakaianSaldo
-	err := ctrl.db.Preload("Pengaju").Where("id_pemakaian = ?", id).First(&pemakaian).Error
+	err := ctrl.db.Preload("Pengaju").Preload("Kampanye").Preload("Anggaran").Preload("Rincian.Dana").Preload("Rekening").
+		Preload("Riwayat", func(db *gorm.DB) *gorm.DB { return db.Order("waktu ASC") }).
+		Preload("Riwayat.Pelaku").
+		Preload("Lampiran", func(db *gorm.DB) *gorm.DB { return db.Order("diunggah_pada ASC") }).
+		Where("id_pemakaian = ?", id).First(&pemakaian).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Data pemakaian saldo tidak ditemukan"})
@@ -283,6 +469,7 @@ func (ctrl *PemakaianSaldoController) GetPemakaianByID(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pemakaian saldo: " + err.Error()})
 		return
 	}
+	pemakaian.JumlahLampiran = int64(len(pemakaian.Lampiran))
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": pemakaian,
@@ -303,6 +490,12 @@ func (ctrl *PemakaianSaldoController) UpdatePemakaian(c *gin.Context) {
 		return
 	}
 
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
 	var req UpdatePemakaianRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -311,7 +504,7 @@ func (ctrl *PemakaianSaldoController) UpdatePemakaian(c *gin.Context) {
 
 	// Cek apakah pemakaian exists
 	var existingPemakaian models.PemakaianSaldo
-	err := ctrl.db.Where("id_pemakaian = ?", id).First(&existingPemakaian).Error
+	err := ctrl.db.Preload("Rincian").Where("id_pemakaian = ?", id).First(&existingPemakaian).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Data pemakaian saldo tidak ditemukan"})
@@ -321,9 +514,7 @@ func (ctrl *PemakaianSaldoController) UpdatePemakaian(c *gin.Context) {
 		return
 	}
 
-	// Simpan nominal lama untuk update rekap
-	nominalSyahriahLama := existingPemakaian.NominalSyahriah
-	nominalDonasiLama := existingPemakaian.NominalDonasi
+	nominalLama := existingPemakaian.NominalTotal
 
 	// Update fields
 	if req.JudulPemakaian != nil {
@@ -332,24 +523,36 @@ func (ctrl *PemakaianSaldoController) UpdatePemakaian(c *gin.Context) {
 	if req.Deskripsi != nil {
 		existingPemakaian.Deskripsi = *req.Deskripsi
 	}
-	if req.NominalSyahriah != nil {
-		if *req.NominalSyahriah < 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Nominal syahriah tidak boleh negatif"})
+	if len(req.RincianDana) > 0 {
+		rincian, err := ctrl.rincianDana(req.RincianDana)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		existingPemakaian.NominalSyahriah = *req.NominalSyahriah
-	}
-	if req.NominalDonasi != nil {
-		if *req.NominalDonasi < 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Nominal donasi tidak boleh negatif"})
-			return
+		existingPemakaian.Rincian = rincian
+	} else {
+		// nominal_syahriah dan nominal_donasi hanya mengganti porsi dana tersebut, porsi dana lain tetap
+		perubahan := map[string]*float64{models.KodeDanaSyahriah: req.NominalSyahriah, models.KodeDanaDonasi: req.NominalDonasi}
+		for kodeDana, nominal := range perubahan {
+			if nominal == nil {
+				continue
+			}
+			if *nominal < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Nominal " + kodeDana + " tidak boleh negatif"})
+				return
+			}
+			rincian := existingPemakaian.Rincian[:0]
+			for _, r := range existingPemakaian.Rincian {
+				if r.KodeDana != kodeDana {
+					rincian = append(rincian, r)
+				}
+			}
+			existingPemakaian.Rincian = append(rincian, models.PemakaianDana{KodeDana: kodeDana, Nominal: *nominal})
 		}
-		existingPemakaian.NominalDonasi = *req.NominalDonasi
 	}
-	
-	// Hitung ulang total
-	existingPemakaian.NominalTotal = existingPemakaian.NominalSyahriah + existingPemakaian.NominalDonasi
-	
+	// Hitung ulang total dari rincian dana
+	services.RapikanRincian(&existingPemakaian)
+
 	if existingPemakaian.NominalTotal <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Total nominal harus lebih besar dari 0"})
 		return
@@ -376,36 +579,46 @@ func (ctrl *PemakaianSaldoController) UpdatePemakaian(c *gin.Context) {
 	if req.Keterangan != nil {
 		existingPemakaian.Keterangan = req.Keterangan
 	}
-
-	// Cek saldo tersedia jika nominal berubah
-	if (req.NominalSyahriah != nil && *req.NominalSyahriah != nominalSyahriahLama) || 
-	   (req.NominalDonasi != nil && *req.NominalDonasi != nominalDonasiLama) {
-		if !ctrl.cekSaldoTersedia(existingPemakaian.NominalSyahriah, existingPemakaian.NominalDonasi) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Saldo tidak mencukupi"})
+	if req.IDKampanye != nil {
+		idKampanye, err := ctrl.cekKampanye(req.IDKampanye)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Kampanye tidak ditemukan"})
 			return
 		}
+		existingPemakaian.IDKampanye = idKampanye
+		existingPemakaian.Kampanye = nil
+	}
+	if req.IDAnggaran != nil {
+		existingPemakaian.IDAnggaran = nil
+		if *req.IDAnggaran != "" {
+			existingPemakaian.IDAnggaran = req.IDAnggaran
+		}
+		existingPemakaian.Anggaran = nil
+	}
+	if req.IDRekening != nil && *req.IDRekening != "" {
+		existingPemakaian.IDRekening = req.IDRekening
+		existingPemakaian.Rekening = nil
 	}
 
-	// Simpan perubahan
-	if err := ctrl.db.Save(&existingPemakaian).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate data pemakaian saldo: " + err.Error()})
+	// Kenaikan nominal pemakaian yang sudah dicairkan tidak melewati persetujuan ulang
+	if err := services.CekKenaikanPemakaian(ctrl.db, existingPemakaian.Status, nominalLama, existingPemakaian.NominalTotal, ctrl.getRole(c)); err != nil {
+		ctrl.statusErrorPemakaian(c, err, "Gagal mengecek ambang persetujuan")
 		return
 	}
 
-	// Preload relations untuk response
-	ctrl.db.Preload("Pengaju").First(&existingPemakaian, "id_pemakaian = ?", existingPemakaian.IDPemakaian)
-
-	// Update rekap saldo jika nominal berubah
-	if (req.NominalSyahriah != nil && *req.NominalSyahriah != nominalSyahriahLama) || 
-	   (req.NominalDonasi != nil && *req.NominalDonasi != nominalDonasiLama) {
-		if err := ctrl.updateRekapSaldoSetelahUpdate(existingPemakaian, nominalSyahriahLama, nominalDonasiLama); err != nil {
-			fmt.Printf("Gagal update rekap saldo: %v\n", err)
-		}
+	// Cek tambahan saldo, simpan perubahan, jurnal dan rekap periode lama/baru dalam satu transaksi
+	if _, err := services.NewKeuanganService(ctrl.db).UpdatePemakaian(&existingPemakaian, adminID); err != nil {
+		ctrl.statusErrorPemakaian(c, err, "Gagal mengupdate data pemakaian saldo")
+		return
 	}
 
+	// Preload relations untuk response
+	ctrl.db.Preload("Pengaju").Preload("Kampanye").Preload("Anggaran").Preload("Rincian.Dana").Preload("Rekening").First(&existingPemakaian, "id_pemakaian = ?", existingPemakaian.IDPemakaian)
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Data pemakaian saldo berhasil diupdate",
-		"data":    existingPemakaian,
+		"message":             "Data pemakaian saldo berhasil diupdate",
+		"data":                existingPemakaian,
+		"peringatan_anggaran": ctrl.peringatanAnggaran(existingPemakaian),
 	})
 }
 
@@ -423,9 +636,15 @@ func (ctrl *PemakaianSaldoController) DeletePemakaian(c *gin.Context) {
 		return
 	}
 
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
 	// Cek apakah pemakaian exists
 	var pemakaian models.PemakaianSaldo
-	err := ctrl.db.Where("id_pemakaian = ?", id).First(&pemakaian).Error
+	err := ctrl.db.Preload("Lampiran").Where("id_pemakaian = ?", id).First(&pemakaian).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Data pemakaian saldo tidak ditemukan"})
@@ -435,19 +654,113 @@ func (ctrl *PemakaianSaldoController) DeletePemakaian(c *gin.Context) {
 		return
 	}
 
-	// Hapus pemakaian
-	if err := ctrl.db.Where("id_pemakaian = ?", id).Delete(&models.PemakaianSaldo{}).Error; err != nil {
+	// Hapus pemakaian, balik jurnalnya dan update rekap dalam satu transaksi
+	if _, err := services.NewKeuanganService(ctrl.db).DeletePemakaian(id, adminID); err != nil {
+		if errors.Is(err, services.ErrPeriodeDitutup) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus data pemakaian saldo: " + err.Error()})
 		return
 	}
+	hapusBerkasLampiran(pemakaian.Lampiran)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Data pemakaian saldo berhasil dihapus",
+	})
+}
+
+// SetujuiPemakaian menyetujui pengajuan pemakaian saldo sesuai ambang persetujuan
+func (ctrl *PemakaianSaldoController) SetujuiPemakaian(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User tidak terautentikasi"})
+		return
+	}
+
+	var req AksiPemakaianRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
 
-	// Update rekap saldo (tambahkan kembali saldo yang dihapus)
-	if err := ctrl.updateRekapSaldoSetelahHapus(pemakaian); err != nil {
-		fmt.Printf("Gagal update rekap saldo: %v\n", err)
+	pemakaian, err := services.NewKeuanganService(ctrl.db).SetujuiPemakaian(c.Param("id"), adminID, ctrl.getRole(c), strings.TrimSpace(req.Komentar))
+	if err != nil {
+		ctrl.statusErrorPemakaian(c, err, "Gagal menyetujui pemakaian saldo")
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Data pemakaian saldo berhasil dihapus",
+		"message": "Pengajuan pemakaian saldo disetujui",
+		"data":    pemakaian,
+	})
+}
+
+// TolakPemakaian menolak pengajuan pemakaian saldo. Alasan penolakan wajib diisi.
+func (ctrl *PemakaianSaldoController) TolakPemakaian(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User tidak terautentikasi"})
+		return
+	}
+
+	var req AksiPemakaianRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+	komentar := strings.TrimSpace(req.Komentar)
+	if komentar == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Alasan penolakan (komentar) wajib diisi"})
+		return
+	}
+
+	pemakaian, err := services.NewKeuanganService(ctrl.db).TolakPemakaian(c.Param("id"), adminID, ctrl.getRole(c), komentar)
+	if err != nil {
+		ctrl.statusErrorPemakaian(c, err, "Gagal menolak pemakaian saldo")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pengajuan pemakaian saldo ditolak",
+		"data":    pemakaian,
+	})
+}
+
+// CairkanPemakaian mencairkan pengajuan yang sudah disetujui; saldo baru berkurang pada tahap ini
+func (ctrl *PemakaianSaldoController) CairkanPemakaian(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User tidak terautentikasi"})
+		return
+	}
+
+	var req AksiPemakaianRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	var tanggal *time.Time
+	if req.TanggalPemakaian != nil && *req.TanggalPemakaian != "" {
+		t, err := time.Parse("2006-01-02", *req.TanggalPemakaian)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format tanggal tidak valid, gunakan YYYY-MM-DD"})
+			return
+		}
+		tanggal = &t
+	}
+
+	pemakaian, _, err := services.NewKeuanganService(ctrl.db).CairkanPemakaian(c.Param("id"), adminID, strings.TrimSpace(req.Komentar), tanggal, req.IDRekening)
+	if err != nil {
+		ctrl.statusErrorPemakaian(c, err, "Gagal mencairkan pemakaian saldo")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Pemakaian saldo berhasil dicairkan",
+		"data":                pemakaian,
+		"peringatan_anggaran": ctrl.peringatanAnggaran(*pemakaian),
 	})
 }
 
@@ -457,11 +770,13 @@ func (ctrl *PemakaianSaldoController) GetPemakaianSummary(c *gin.Context) {
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
 	tipePemakaian := c.Query("tipe_pemakaian")
+	// Default hanya pemakaian yang sudah dicairkan, karena hanya itu yang mengurangi saldo
+	status := c.DefaultQuery("status", string(models.PemakaianDicairkan))
 
 	var summary PemakaianSummary
 
 	// Build query
-	query := ctrl.db.Model(&models.PemakaianSaldo{})
+	query := ctrl.db.Model(&models.PemakaianSaldo{}).Where("status = ?", status)
 
 	// Apply filters
 	if startDate != "" {
@@ -511,108 +826,11 @@ func (ctrl *PemakaianSaldoController) GetPemakaianSummary(c *gin.Context) {
 			"start_date":     startDate,
 			"end_date":       endDate,
 			"tipe_pemakaian": tipePemakaian,
+			"status":         status,
 		},
 	})
 }
 
-// ========== HELPER FUNCTIONS ==========
-
-// cekSaldoTersedia - Cek apakah saldo mencukupi untuk pemakaian
-func (ctrl *PemakaianSaldoController) cekSaldoTersedia(nominalSyahriah, nominalDonasi float64) bool {
-    // Get latest rekap saldo
-    var rekap models.RekapSaldo
-    err := ctrl.db.Order("periode DESC").First(&rekap).Error
-    
-    if err != nil {
-        fmt.Printf("Gagal mendapatkan saldo: %v\n", err)
-        return false
-    }
-    
-    // Cek masing-masing saldo
-    if nominalSyahriah > 0 && nominalSyahriah > rekap.SaldoAkhirSyahriah {
-        return false
-    }
-    
-    if nominalDonasi > 0 && nominalDonasi > rekap.SaldoAkhirDonasi {
-        return false
-    }
-    
-    return true
-}
-
-// updateRekapSaldoSetelahPemakaian - Update rekap saldo setelah pemakaian (SANGAT SEDERHANA SEKARANG)
-func (ctrl *PemakaianSaldoController) updateRekapSaldoSetelahPemakaian(pemakaian models.PemakaianSaldo) error {
-    rekapController := NewRekapController(ctrl.db)
-    
-    // Get periode from tanggal pemakaian
-    var periode string
-    if pemakaian.TanggalPemakaian != nil {
-        periode = pemakaian.TanggalPemakaian.Format("2006-01")
-    } else {
-        periode = time.Now().Format("2006-01")
-    }
-    
-    // Langsung gunakan nominal yang sudah ditentukan
-    return rekapController.updateRekapSaldoDenganPengeluaran(
-        periode, 
-        pemakaian.NominalSyahriah, 
-        pemakaian.NominalDonasi, 
-        pemakaian.NominalTotal,
-    )
-}
-
-// updateRekapSaldoSetelahUpdate - Update rekap saldo setelah update pemakaian (SANGAT SEDERHANA SEKARANG)
-func (ctrl *PemakaianSaldoController) updateRekapSaldoSetelahUpdate(pemakaian models.PemakaianSaldo, nominalSyahriahLama, nominalDonasiLama float64) error {
-    rekapController := NewRekapController(ctrl.db)
-    
-    // Get periode from tanggal pemakaian
-    var periode string
-    if pemakaian.TanggalPemakaian != nil {
-        periode = pemakaian.TanggalPemakaian.Format("2006-01")
-    } else {
-        periode = time.Now().Format("2006-01")
-    }
-    
-    // 1. Kembalikan saldo lama
-    if err := rekapController.updateRekapSaldoDenganPemasukan(
-        periode,
-        nominalSyahriahLama,
-        nominalDonasiLama,
-        nominalSyahriahLama + nominalDonasiLama,
-    ); err != nil {
-        return err
-    }
-    
-    // 2. Kurangi saldo baru
-    return rekapController.updateRekapSaldoDenganPengeluaran(
-        periode,
-        pemakaian.NominalSyahriah,
-        pemakaian.NominalDonasi,
-        pemakaian.NominalTotal,
-    )
-}
-
-// updateRekapSaldoSetelahHapus - Update rekap saldo setelah hapus pemakaian (SANGAT SEDERHANA SEKARANG)
-func (ctrl *PemakaianSaldoController) updateRekapSaldoSetelahHapus(pemakaian models.PemakaianSaldo) error {
-    rekapController := NewRekapController(ctrl.db)
-    
-    // Get periode from tanggal pemakaian
-    var periode string
-    if pemakaian.TanggalPemakaian != nil {
-        periode = pemakaian.TanggalPemakaian.Format("2006-01")
-    } else {
-        periode = time.Now().Format("2006-01")
-    }
-    
-    // Kembalikan saldo yang dihapus
-    return rekapController.updateRekapSaldoDenganPemasukan(
-        periode,
-        pemakaian.NominalSyahriah,
-        pemakaian.NominalDonasi,
-        pemakaian.NominalTotal,
-    )
-}
-
 func (ctrl *PemakaianSaldoController) GetAllPemakaianPublic(c *gin.Context) {
 	// Parse query parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -636,7 +854,9 @@ func (ctrl *PemakaianSaldoController) GetAllPemakaianPublic(c *gin.Context) {
 	// Build query - hanya ambil data yang diperlukan untuk public
 	query := ctrl.db.Select("id_pemakaian", "judul_pemakaian", "deskripsi", "nominal_syahriah", 
 		"nominal_donasi", "nominal_total", "tipe_pemakaian", "tanggal_pemakaian", "keterangan", 
-		"created_at", "updated_at")
+		"created_at", "updated_at").
+		Preload("Rincian.Dana").
+		Where("status = ?", models.PemakaianDicairkan) // pengajuan yang belum dicairkan tidak ditampilkan
 
 	// Apply filters
 	if tipePemakaian != "" {
@@ -705,6 +925,7 @@ func (ctrl *PemakaianSaldoController) GetAllPemakaianPublic(c *gin.Context) {
 		TipePemakaian    models.TipePemakaian  `json:"tipe_pemakaian"`
 		TanggalPemakaian *string               `json:"tanggal_pemakaian,omitempty"`
 		Keterangan       *string               `json:"keterangan,omitempty"`
+		RincianDana      []gin.H               `json:"rincian_dana"`
 		CreatedAt        time.Time             `json:"created_at"`
 		UpdatedAt        time.Time             `json:"updated_at"`
 	}
@@ -727,6 +948,7 @@ func (ctrl *PemakaianSaldoController) GetAllPemakaianPublic(c *gin.Context) {
 			TipePemakaian:    p.TipePemakaian,
 			TanggalPemakaian: tanggalStr,
 			Keterangan:       p.Keterangan,
+			RincianDana:      rincianDanaPublik(p.Rincian),
 			CreatedAt:        p.CreatedAt,
 			UpdatedAt:        p.UpdatedAt,
 		}
@@ -757,10 +979,11 @@ func (ctrl *PemakaianSaldoController) GetPemakaianSummaryPublic(c *gin.Context)
 		PemakaianTerbanyak float64 `json:"pemakaian_terbanyak"`
 		TotalSyahriah     float64 `json:"total_syahriah"`
 		TotalDonasi       float64 `json:"total_donasi"`
+		PerDana           []totalDana `json:"per_dana"`
 	}
 
 	// Build query
-	query := ctrl.db.Model(&models.PemakaianSaldo{})
+	query := ctrl.db.Model(&models.PemakaianSaldo{}).Where("status = ?", models.PemakaianDicairkan)
 
 	// Apply filters
 	if startDate != "" {
@@ -820,6 +1043,32 @@ func (ctrl *PemakaianSaldoController) GetPemakaianSummaryPublic(c *gin.Context)
 	}
 	summary.TotalDonasi = totalDonasi
 
+	// Total per dana dari rincian, termasuk dana selain syahriah dan donasi
+	queryDana := ctrl.db.Table("pemakaian_dana").
+		Select("pemakaian_dana.kode_dana, dana.nama_dana, COALESCE(SUM(pemakaian_dana.nominal), 0) AS total").
+		Joins("JOIN pemakaian_saldo ON pemakaian_saldo.id_pemakaian = pemakaian_dana.id_pemakaian").
+		Joins("JOIN dana ON dana.kode_dana = pemakaian_dana.kode_dana").
+		Where("pemakaian_saldo.status = ?", models.PemakaianDicairkan)
+	if startDate != "" {
+		if start, err := time.Parse("2006-01-02", startDate); err == nil {
+			queryDana = queryDana.Where("DATE(pemakaian_saldo.created_at) >= ?", start.Format("2006-01-02"))
+		}
+	}
+	if endDate != "" {
+		if end, err := time.Parse("2006-01-02", endDate); err == nil {
+			queryDana = queryDana.Where("DATE(pemakaian_saldo.created_at) <= ?", end.Format("2006-01-02"))
+		}
+	}
+	if tipePemakaian != "" {
+		queryDana = queryDana.Where("pemakaian_saldo.tipe_pemakaian = ?", tipePemakaian)
+	}
+	summary.PerDana = []totalDana{}
+	if err := queryDana.Group("pemakaian_dana.kode_dana, dana.nama_dana, dana.urutan").
+		Order("dana.urutan ASC").Scan(&summary.PerDana).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total per dana: " + err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data": summary,
 		"filter": gin.H{
@@ -842,7 +1091,8 @@ func (ctrl *PemakaianSaldoController) GetPemakaianByIDPublic(c *gin.Context) {
 	err := ctrl.db.Select("id_pemakaian", "judul_pemakaian", "deskripsi", "nominal_syahriah", 
 		"nominal_donasi", "nominal_total", "tipe_pemakaian", "tanggal_pemakaian", "keterangan", 
 		"created_at", "updated_at").
-		Where("id_pemakaian = ?", id).
+		Preload("Rincian.Dana").
+		Where("id_pemakaian = ? AND status = ?", id, models.PemakaianDicairkan).
 		First(&pemakaian).Error
 
 	if err != nil {
@@ -865,8 +1115,25 @@ func (ctrl *PemakaianSaldoController) GetPemakaianByIDPublic(c *gin.Context) {
 		TipePemakaian    models.TipePemakaian  `json:"tipe_pemakaian"`
 		TanggalPemakaian *string               `json:"tanggal_pemakaian,omitempty"`
 		Keterangan       *string               `json:"keterangan,omitempty"`
+		RincianDana      []gin.H               `json:"rincian_dana"`
 		CreatedAt        time.Time             `json:"created_at"`
 		UpdatedAt        time.Time             `json:"updated_at"`
+		Lampiran         []gin.H               `json:"lampiran,omitempty"`
+	}
+
+	// Hanya thumbnail tersamar dari lampiran yang ditandai tampil publik
+	var lampiran []models.LampiranPemakaian
+	if err := ctrl.db.Where("id_pemakaian = ? AND tampil_publik = ? AND thumbnail IS NOT NULL", id, true).
+		Order("diunggah_pada ASC").Find(&lampiran).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil lampiran: " + err.Error()})
+		return
+	}
+	thumbnail := make([]gin.H, len(lampiran))
+	for i, l := range lampiran {
+		thumbnail[i] = gin.H{
+			"id_lampiran":   l.IDLampiran,
+			"thumbnail_url": "/api/pengeluaran-public/" + id + "/lampiran/" + l.IDLampiran + "/thumbnail",
+		}
 	}
 
 	var tanggalStr *string
@@ -885,8 +1152,10 @@ func (ctrl *PemakaianSaldoController) GetPemakaianByIDPublic(c *gin.Context) {
 		TipePemakaian:    pemakaian.TipePemakaian,
 		TanggalPemakaian: tanggalStr,
 		Keterangan:       pemakaian.Keterangan,
+		RincianDana:      rincianDanaPublik(pemakaian.Rincian),
 		CreatedAt:        pemakaian.CreatedAt,
 		UpdatedAt:        pemakaian.UpdatedAt,
+		Lampiran:         thumbnail,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -912,7 +1181,7 @@ func (ctrl *PemakaianSaldoController) GetPemakaianStatsPublic(c *gin.Context) {
 	}
 
 	// Build base query
-	baseQuery := ctrl.db.Model(&models.PemakaianSaldo{})
+	baseQuery := ctrl.db.Model(&models.PemakaianSaldo{}).Where("status = ?", models.PemakaianDicairkan)
 	
 	// Apply date filters
 	if startDate != "" {
@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PembayaranOnlineController struct {
+	db *gorm.DB
+}
+
+func NewPembayaranOnlineController(db *gorm.DB) *PembayaranOnlineController {
+	return &PembayaranOnlineController{db: db}
+}
+
+// Request structs
+type BuatPembayaranOnlineRequest struct {
+	IDSyahriah []string `json:"id_syahriah" binding:"required"`
+}
+
+type SimulasiPembayaranOnlineRequest struct {
+	Status string `json:"status"` // default dibayar
+}
+
+// Helper function untuk check role admin
+func (ctrl *PembayaranOnlineController) isAdmin(c *gin.Context) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		return false
+	}
+	role := userRole.(string)
+	return role == "admin" || role == "super_admin"
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *PembayaranOnlineController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// BuatPembayaranOnline membuat invoice pembayaran online untuk tagihan syahriah santri milik wali yang login
+func (ctrl *PembayaranOnlineController) BuatPembayaranOnline(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req BuatPembayaranOnlineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tagihan, err := services.NewPembayaranOnlineService(ctrl.db).Buat(c.Request.Context(), userID, req.IDSyahriah)
+	if err != nil {
+		c.JSON(statusErrorPembayaranOnline(err), gin.H{"error": "Gagal membuat pembayaran online: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Invoice pembayaran online berhasil dibuat",
+		"data":    tagihan,
+	})
+}
+
+// GetMyPembayaranOnline mendapatkan invoice pembayaran online milik wali yang login
+func (ctrl *PembayaranOnlineController) GetMyPembayaranOnline(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	ctrl.daftarPembayaranOnline(c, ctrl.db.Model(&models.TagihanOnline{}).Where("id_wali = ?", userID))
+}
+
+// GetAllPembayaranOnline mendapatkan semua invoice pembayaran online (admin).
+// Query: status, penyedia, id_wali, start_date, end_date
+func (ctrl *PembayaranOnlineController) GetAllPembayaranOnline(c *gin.Context) {
+	query := ctrl.db.Model(&models.TagihanOnline{}).Preload("Wali")
+	if penyedia := c.Query("penyedia"); penyedia != "" {
+		query = query.Where("penyedia = ?", penyedia)
+	}
+	if idWali := c.Query("id_wali"); idWali != "" {
+		query = query.Where("id_wali = ?", idWali)
+	}
+	if start, err := parseDate(c.Query("start_date")); err == nil {
+		query = query.Where("waktu_buat >= ?", start)
+	}
+	if end, err := parseDate(c.Query("end_date")); err == nil {
+		query = query.Where("waktu_buat < ?", end.AddDate(0, 0, 1))
+	}
+
+	ctrl.daftarPembayaranOnline(c, query)
+}
+
+func (ctrl *PembayaranOnlineController) daftarPembayaranOnline(c *gin.Context, query *gorm.DB) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	// Invoice yang lewat batas waktu ditampilkan sebagai kedaluwarsa
+	if err := services.NewPembayaranOnlineService(ctrl.db).KedaluwarsakanLama(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui status invoice: " + err.Error()})
+		return
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var tagihan []models.TagihanOnline
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Preload("Item.Syahriah.Santri").
+		Order("waktu_buat DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&tagihan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pembayaran online: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": tagihan,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// GetPembayaranOnlineByID mendapatkan detail invoice beserta riwayat statusnya.
+// Wali hanya dapat melihat invoice miliknya sendiri.
+func (ctrl *PembayaranOnlineController) GetPembayaranOnlineByID(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	if err := services.NewPembayaranOnlineService(ctrl.db).KedaluwarsakanLama(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui status invoice: " + err.Error()})
+		return
+	}
+
+	tagihan, ok := ctrl.ambilTagihan(c, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tagihan})
+}
+
+// ambilTagihan memuat invoice dari parameter id (id_tagihan atau kode) dan memeriksa aksesnya
+func (ctrl *PembayaranOnlineController) ambilTagihan(c *gin.Context, userID string) (*models.TagihanOnline, bool) {
+	var tagihan models.TagihanOnline
+	err := ctrl.db.Preload("Wali").
+		Preload("Item.Syahriah.Santri").
+		Preload("Riwayat", func(db *gorm.DB) *gorm.DB { return db.Order("waktu ASC") }).
+		Where("id_tagihan = ? OR kode = ?", c.Param("id"), c.Param("id")).
+		First(&tagihan).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pembayaran online tidak ditemukan"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pembayaran online: " + err.Error()})
+		return nil, false
+	}
+
+	if !ctrl.isAdmin(c) && tagihan.IDWali != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak memiliki akses ke pembayaran ini"})
+		return nil, false
+	}
+	return &tagihan, true
+}
+
+// WebhookPembayaranOnline menerima notifikasi dari payment gateway (tanpa login).
+// Keaslian notifikasi dijamin oleh tanda tangan penyedia, dan notifikasi berulang tidak mencatat ulang pembayaran.
+func (ctrl *PembayaranOnlineController) WebhookPembayaranOnline(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal membaca notifikasi: " + err.Error()})
+		return
+	}
+
+	tagihan, err := services.NewPembayaranOnlineService(ctrl.db).ProsesWebhook(c.Param("penyedia"), c.Request.Header, body)
+	if err != nil {
+		c.JSON(statusErrorPembayaranOnline(err), gin.H{"error": "Notifikasi ditolak: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notifikasi diterima",
+		"kode":    tagihan.Kode,
+		"status":  tagihan.Status,
+	})
+}
+
+// SimulasiPembayaranOnline mengirim notifikasi bertanda tangan untuk invoice penyedia palsu,
+// melewati jalur webhook yang sama (admin only). Hanya untuk pengembangan: route didaftarkan dan
+// dilayani hanya jika GERBANG_BAYAR_SIMULASI=true.
+func (ctrl *PembayaranOnlineController) SimulasiPembayaranOnline(c *gin.Context) {
+	if !services.SimulasiPembayaranAktif() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Simulasi pembayaran tidak aktif"})
+		return
+	}
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req SimulasiPembayaranOnlineRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status == "" {
+		req.Status = string(models.TagihanOnlineDibayar)
+	}
+
+	tagihan, ok := ctrl.ambilTagihan(c, userID)
+	if !ok {
+		return
+	}
+	if tagihan.Penyedia != services.PenyediaPalsu {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Simulasi hanya untuk invoice penyedia palsu"})
+		return
+	}
+	rahasia := os.Getenv("GERBANG_BAYAR_RAHASIA")
+	if rahasia == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "GERBANG_BAYAR_RAHASIA belum diatur"})
+		return
+	}
+
+	body, _ := json.Marshal(services.NotifikasiPalsu{
+		Kode:        tagihan.Kode,
+		Status:      req.Status,
+		Nominal:     tagihan.Nominal,
+		IDEksternal: tagihan.IDEksternal,
+		Metode:      "simulasi",
+	})
+	header := http.Header{}
+	header.Set(services.HeaderTandaTanganPalsu, services.NewPenyediaPalsu(rahasia).TandaTangan(body))
+
+	hasil, err := services.NewPembayaranOnlineService(ctrl.db).ProsesWebhook(services.PenyediaPalsu, header, body)
+	if err != nil {
+		c.JSON(statusErrorPembayaranOnline(err), gin.H{"error": "Simulasi gagal: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Simulasi pembayaran berhasil",
+		"data":    hasil,
+	})
+}
+
+// statusErrorPembayaranOnline memetakan error layanan pembayaran online ke status HTTP
+func statusErrorPembayaranOnline(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, services.ErrPenyediaTidakDikenal):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrPenyediaGagal):
+		return http.StatusBadGateway
+	case errors.Is(err, services.ErrGerbangBayarNonaktif):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrTandaTanganTidakValid):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrTagihanSedangDiproses):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrNominalTidakSesuai):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrPeriodeDitutup):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,431 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PerkembanganController struct {
+	db *gorm.DB
+}
+
+func NewPerkembanganController(db *gorm.DB) *PerkembanganController {
+	return &PerkembanganController{db: db}
+}
+
+// Request structs
+type CreateSetoranRequest struct {
+	IDSantri      string `json:"id_santri" binding:"required"`
+	Tanggal       string `json:"tanggal"`                  // format YYYY-MM-DD, kosong = hari ini
+	Jenis         string `json:"jenis" binding:"required"` // iqro, tilawah, hafalan
+	Jilid         int    `json:"jilid"`
+	Halaman       int    `json:"halaman"`
+	Surah         int    `json:"surah"`
+	AyatMulai     int    `json:"ayat_mulai"`
+	AyatSelesai   int    `json:"ayat_selesai"` // kosong = sampai akhir surah
+	Nilai         *int   `json:"nilai" binding:"required"`
+	CatatanTajwid string `json:"catatan_tajwid"`
+	Catatan       string `json:"catatan"`
+}
+
+type SetoranResponse struct {
+	models.SetoranSantri
+	Predikat  string `json:"predikat"`
+	NamaSurah string `json:"nama_surah,omitempty"`
+}
+
+// Helper function untuk check role admin
+func (ctrl *PerkembanganController) isAdmin(c *gin.Context) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		return false
+	}
+	role := userRole.(string)
+	return role == "admin" || role == "super_admin"
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *PerkembanganController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// santriDiajar mengecek apakah santri sedang aktif di salah satu kelas yang diajar ustadz
+func (ctrl *PerkembanganController) santriDiajar(idUstadz, idSantri string) (bool, error) {
+	idKelas, err := services.KelasUstadz(ctrl.db, idUstadz)
+	if err != nil || len(idKelas) == 0 {
+		return false, err
+	}
+	var jumlah int64
+	err = ctrl.db.Model(&models.AnggotaKelas{}).
+		Where("id_santri = ? AND id_kelas IN ? AND status = ?", idSantri, idKelas, models.AnggotaAktif).
+		Count(&jumlah).Error
+	return jumlah > 0, err
+}
+
+// ambilSantri mengambil santri dan memastikan user boleh melihatnya: admin, wali santri,
+// atau ustadz yang mengajar santri. Menulis response error jika tidak boleh.
+func (ctrl *PerkembanganController) ambilSantri(c *gin.Context, idSantri string) (*models.Santri, bool) {
+	var santri models.Santri
+	if err := ctrl.db.Where("id_santri = ?", idSantri).First(&santri).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Santri tidak ditemukan"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data santri: " + err.Error()})
+		return nil, false
+	}
+	if ctrl.isAdmin(c) {
+		return &santri, true
+	}
+
+	userID, _ := ctrl.getUserID(c)
+	if santri.IDWali == userID {
+		return &santri, true
+	}
+	if role, _ := c.Get("role"); role == string(models.RoleUstadz) {
+		diajar, err := ctrl.santriDiajar(userID, santri.IDSantri)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek akses santri: " + err.Error()})
+			return nil, false
+		}
+		if diajar {
+			return &santri, true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak memiliki akses ke data santri ini"})
+	return nil, false
+}
+
+// setoranResponse menambahkan predikat dan nama surah ke setoran
+func (ctrl *PerkembanganController) setoranResponse(setoran models.SetoranSantri) SetoranResponse {
+	res := SetoranResponse{SetoranSantri: setoran, Predikat: services.PredikatNilai(setoran.Nilai)}
+	if surah, ok := services.CariSurah(setoran.Surah); ok {
+		res.NamaSurah = surah.Nama
+	}
+	return res
+}
+
+// CreateSetoran mencatat setoran santri (admin atau ustadz yang mengajar santri)
+func (ctrl *PerkembanganController) CreateSetoran(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req CreateSetoranRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Setoran hanya dicatat admin atau ustadz yang mengajar santri; wali cukup melihat
+	if !ctrl.isAdmin(c) {
+		diajar, err := ctrl.santriDiajar(userID, req.IDSantri)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek akses santri: " + err.Error()})
+			return
+		}
+		if !diajar {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak mengajar santri ini"})
+			return
+		}
+	}
+	santri, ok := ctrl.ambilSantri(c, req.IDSantri)
+	if !ok {
+		return
+	}
+
+	tanggal := time.Now()
+	tanggal = time.Date(tanggal.Year(), tanggal.Month(), tanggal.Day(), 0, 0, 0, 0, time.Local)
+	if req.Tanggal != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.Tanggal, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format tanggal tidak valid. Gunakan format YYYY-MM-DD"})
+			return
+		}
+		if t.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tanggal setoran tidak boleh di masa depan"})
+			return
+		}
+		tanggal = t
+	}
+
+	setoran := models.SetoranSantri{
+		IDSantri:      santri.IDSantri,
+		Tanggal:       tanggal,
+		Jenis:         models.JenisSetoran(req.Jenis),
+		Jilid:         req.Jilid,
+		Halaman:       req.Halaman,
+		Surah:         req.Surah,
+		AyatMulai:     req.AyatMulai,
+		AyatSelesai:   req.AyatSelesai,
+		Nilai:         *req.Nilai,
+		CatatanTajwid: req.CatatanTajwid,
+		Catatan:       req.Catatan,
+		DicatatOleh:   userID,
+	}
+	if err := services.CatatSetoran(ctrl.db, &setoran); err != nil {
+		if errors.Is(err, services.ErrSetoranTidakValid) || errors.Is(err, services.ErrBelumLulusMunaqosah) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencatat setoran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Setoran berhasil dicatat",
+		"data":    ctrl.setoranResponse(setoran),
+	})
+}
+
+// DeleteSetoran menghapus setoran yang salah catat (admin atau ustadz yang mencatatnya)
+func (ctrl *PerkembanganController) DeleteSetoran(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var setoran models.SetoranSantri
+	if err := ctrl.db.Where("id_setoran = ?", c.Param("id")).First(&setoran).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data setoran tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data setoran: " + err.Error()})
+		return
+	}
+	if !ctrl.isAdmin(c) && setoran.DicatatOleh != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya pencatat setoran atau admin yang dapat menghapus"})
+		return
+	}
+
+	if err := ctrl.db.Delete(&setoran).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus setoran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Setoran berhasil dihapus",
+	})
+}
+
+// GetAllSetoranSantri mendapatkan jurnal setoran seorang santri. Query: jenis
+func (ctrl *PerkembanganController) GetAllSetoranSantri(c *gin.Context) {
+	santri, ok := ctrl.ambilSantri(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var setoran []models.SetoranSantri
+	var total int64
+
+	query := ctrl.db.Model(&models.SetoranSantri{}).Where("id_santri = ?", santri.IDSantri)
+	if jenis := c.Query("jenis"); jenis != "" {
+		query = query.Where("jenis = ?", jenis)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Preload("Pengajar").Preload("Kelas").
+		Order("tanggal DESC, waktu_catat DESC").Offset(offset).Limit(limit).Find(&setoran).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data setoran: " + err.Error()})
+		return
+	}
+
+	data := make([]SetoranResponse, len(setoran))
+	for i, s := range setoran {
+		data[i] = ctrl.setoranResponse(s)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// GetPerkembanganSantri mendapatkan posisi belajar santri saat ini, proyeksi dan linimasanya
+func (ctrl *PerkembanganController) GetPerkembanganSantri(c *gin.Context) {
+	santri, ok := ctrl.ambilSantri(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	setoran, err := services.MuatSetoran(ctrl.db, []string{santri.IDSantri})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data setoran: " + err.Error()})
+		return
+	}
+	munaqosah, err := services.MuatMunaqosahLulus(ctrl.db, []string{santri.IDSantri})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data munaqosah: " + err.Error()})
+		return
+	}
+	perkembangan, peristiwa := services.HitungPerkembangan(santri.IDSantri, setoran, munaqosah, time.Now())
+
+	// Linimasa ditampilkan dari peristiwa terbaru
+	sort.SliceStable(peristiwa, func(i, j int) bool {
+		return peristiwa[i].Tanggal.After(peristiwa[j].Tanggal)
+	})
+	if peristiwa == nil {
+		peristiwa = []services.PeristiwaPerkembangan{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"santri":   santri,
+		"data":     perkembangan,
+		"linimasa": peristiwa,
+	})
+}
+
+// GetMunaqosahSantri mendapatkan riwayat seluruh percobaan munaqosah santri beserta jadwal yang akan datang
+func (ctrl *PerkembanganController) GetMunaqosahSantri(c *gin.Context) {
+	santri, ok := ctrl.ambilSantri(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var munaqosah []models.Munaqosah
+	if err := ctrl.db.Preload("Penguji").
+		Where("id_santri = ?", santri.IDSantri).
+		Order("jadwal_ujian DESC").
+		Find(&munaqosah).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data munaqosah: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"santri": santri,
+		"data":   munaqosah,
+		"rubrik": services.RubrikMunaqosah,
+	})
+}
+
+// GetDistribusiPerkembangan mendapatkan sebaran tingkat bacaan dan hafalan juz 30 santri aktif
+// per kelas. Query: id_kelas (opsional, ustadz hanya kelas yang diajarnya)
+func (ctrl *PerkembanganController) GetDistribusiPerkembangan(c *gin.Context) {
+	query := ctrl.db.Model(&models.Kelas{}).Where("aktif = ?", true)
+	if !ctrl.isAdmin(c) {
+		userID, _ := ctrl.getUserID(c)
+		idKelas, err := services.KelasUstadz(ctrl.db, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+			return
+		}
+		if len(idKelas) == 0 {
+			c.JSON(http.StatusOK, gin.H{"data": []gin.H{}})
+			return
+		}
+		query = query.Where("id_kelas IN ?", idKelas)
+	}
+	if idKelas := c.Query("id_kelas"); idKelas != "" {
+		query = query.Where("id_kelas = ?", idKelas)
+	}
+
+	var kelas []models.Kelas
+	if err := query.Order("nama_kelas ASC").Find(&kelas).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+
+	kategoriTingkat := []string{"belum_ada", "iqro_1", "iqro_2", "iqro_3", "iqro_4", "iqro_5", "iqro_6", "al_quran"}
+	data := make([]gin.H, 0, len(kelas))
+	for _, k := range kelas {
+		var anggota []models.AnggotaKelas
+		if err := ctrl.db.Preload("Santri").
+			Where("id_kelas = ? AND status = ?", k.IDKelas, models.AnggotaAktif).
+			Find(&anggota).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil anggota kelas: " + err.Error()})
+			return
+		}
+		idSantri := make([]string, len(anggota))
+		for i, a := range anggota {
+			idSantri[i] = a.IDSantri
+		}
+		perkembangan, err := services.MuatPerkembangan(ctrl.db, idSantri)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung perkembangan: " + err.Error()})
+			return
+		}
+
+		tingkat := make(map[string][]gin.H, len(kategoriTingkat))
+		for _, kategori := range kategoriTingkat {
+			tingkat[kategori] = []gin.H{}
+		}
+		hafalan := map[string]int{"0": 0, "1-9": 0, "10-19": 0, "20-36": 0, "37": 0}
+		for _, a := range anggota {
+			p := perkembangan[a.IDSantri]
+			nama := ""
+			if a.Santri != nil {
+				nama = a.Santri.NamaLengkap
+			}
+			tingkat[p.Kategori] = append(tingkat[p.Kategori], gin.H{
+				"id_santri":   a.IDSantri,
+				"nama_santri": nama,
+				"tingkat":     p.Tingkat,
+			})
+			switch n := p.Hafalan.JumlahJuz30; {
+			case n == 0:
+				hafalan["0"]++
+			case n < 10:
+				hafalan["1-9"]++
+			case n < 20:
+				hafalan["10-19"]++
+			case n < p.Hafalan.TotalJuz30:
+				hafalan["20-36"]++
+			default:
+				hafalan["37"]++
+			}
+		}
+
+		jumlahTingkat := make(map[string]int, len(kategoriTingkat))
+		for kategori, santri := range tingkat {
+			jumlahTingkat[kategori] = len(santri)
+		}
+		data = append(data, gin.H{
+			"kelas":          k,
+			"jumlah_santri":  len(anggota),
+			"jumlah_tingkat": jumlahTingkat,
+			"santri_tingkat": tingkat,
+			"hafalan_juz30":  hafalan,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     data,
+		"kategori": kategoriTingkat,
+	})
+}
@@ -0,0 +1,349 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RaportController struct {
+	db *gorm.DB
+}
+
+func NewRaportController(db *gorm.DB) *RaportController {
+	return &RaportController{db: db}
+}
+
+// Request structs
+type SimpanRaportRequest struct {
+	IDSantri      string                    `json:"id_santri" binding:"required"`
+	IDKelas       string                    `json:"id_kelas" binding:"required"`
+	TahunAjaran   string                    `json:"tahun_ajaran" binding:"required"`
+	Semester      string                    `json:"semester" binding:"required"` // ganjil, genap
+	Nilai         []services.IsiNilaiRaport `json:"nilai" binding:"dive"`
+	CatatanUstadz *string                   `json:"catatan_ustadz"`
+}
+
+// Helper function untuk check role admin
+func (ctrl *RaportController) isAdmin(c *gin.Context) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		return false
+	}
+	role := userRole.(string)
+	return role == "admin" || role == "super_admin"
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *RaportController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// cekAksesKelas memastikan user adalah admin atau ustadz yang mengajar kelas tersebut.
+// Menulis response error jika tidak boleh.
+func (ctrl *RaportController) cekAksesKelas(c *gin.Context, idKelas string) bool {
+	if ctrl.isAdmin(c) {
+		return true
+	}
+	userID, _ := ctrl.getUserID(c)
+	idKelasDiajar, err := services.KelasUstadz(ctrl.db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek akses kelas: " + err.Error()})
+		return false
+	}
+	for _, id := range idKelasDiajar {
+		if id == idKelas {
+			return true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak mengajar di kelas ini"})
+	return false
+}
+
+// periode membaca query tahun_ajaran dan semester, kosong berarti semester berjalan.
+// Menulis response error jika tidak valid.
+func (ctrl *RaportController) periode(c *gin.Context) (string, models.Semester, bool) {
+	tahunAjaran, semester := services.SemesterBerjalan(time.Now())
+	if t := c.Query("tahun_ajaran"); t != "" {
+		tahunAjaran = t
+	}
+	if s := c.Query("semester"); s != "" {
+		semester = models.Semester(s)
+	}
+	if _, _, err := services.PeriodeSemester(tahunAjaran, semester); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", "", false
+	}
+	return tahunAjaran, semester, true
+}
+
+// ambilSantri mengambil santri dan memastikan user boleh melihat raportnya: admin, wali santri,
+// atau ustadz yang mengajar santri. Menulis response error jika tidak boleh.
+func (ctrl *RaportController) ambilSantri(c *gin.Context, idSantri string) (*models.Santri, bool) {
+	var santri models.Santri
+	if err := ctrl.db.Where("id_santri = ?", idSantri).First(&santri).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Santri tidak ditemukan"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data santri: " + err.Error()})
+		return nil, false
+	}
+	if ctrl.isAdmin(c) {
+		return &santri, true
+	}
+
+	userID, _ := ctrl.getUserID(c)
+	if santri.IDWali == userID {
+		return &santri, true
+	}
+	if role, _ := c.Get("role"); role == string(models.RoleUstadz) {
+		idKelas, err := services.KelasUstadz(ctrl.db, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek akses santri: " + err.Error()})
+			return nil, false
+		}
+		if len(idKelas) > 0 {
+			var jumlah int64
+			if err := ctrl.db.Model(&models.AnggotaKelas{}).
+				Where("id_santri = ? AND id_kelas IN ? AND status = ?", santri.IDSantri, idKelas, models.AnggotaAktif).
+				Count(&jumlah).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek akses santri: " + err.Error()})
+				return nil, false
+			}
+			if jumlah > 0 {
+				return &santri, true
+			}
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak memiliki akses ke raport santri ini"})
+	return nil, false
+}
+
+// GetRaportKelas mendapatkan isian raport seluruh anggota kelas pada satu semester.
+// Query: id_kelas (wajib), tahun_ajaran, semester
+func (ctrl *RaportController) GetRaportKelas(c *gin.Context) {
+	idKelas := c.Query("id_kelas")
+	if idKelas == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id_kelas wajib diisi"})
+		return
+	}
+	if !ctrl.cekAksesKelas(c, idKelas) {
+		return
+	}
+	tahunAjaran, semester, ok := ctrl.periode(c)
+	if !ok {
+		return
+	}
+
+	idSantri, err := services.AnggotaRaportKelas(ctrl.db, idKelas, tahunAjaran, semester)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil anggota kelas: " + err.Error()})
+		return
+	}
+
+	var santri []models.Santri
+	var raport []models.Raport
+	if len(idSantri) > 0 {
+		if err := ctrl.db.Where("id_santri IN ?", idSantri).Order("nama_lengkap ASC").Find(&santri).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data santri: " + err.Error()})
+			return
+		}
+		if err := ctrl.db.Preload("Nilai").
+			Where("id_santri IN ? AND tahun_ajaran = ? AND semester = ?", idSantri, tahunAjaran, semester).
+			Find(&raport).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data raport: " + err.Error()})
+			return
+		}
+	}
+	raportSantri := make(map[string]models.Raport, len(raport))
+	for _, r := range raport {
+		raportSantri[r.IDSantri] = r
+	}
+
+	data := make([]gin.H, 0, len(santri))
+	for _, s := range santri {
+		item := gin.H{"santri": s, "raport": nil, "lengkap": false}
+		if r, ok := raportSantri[s.IDSantri]; ok {
+			item["raport"] = r
+			item["lengkap"] = len(r.Nilai) == len(services.MapelRaport) && strings.TrimSpace(r.CatatanUstadz) != ""
+		}
+		data = append(data, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         data,
+		"mapel":        services.MapelRaport,
+		"tahun_ajaran": tahunAjaran,
+		"semester":     semester,
+	})
+}
+
+// SimpanRaport mengisi nilai mata pelajaran dan catatan ustadz untuk raport santri
+// (admin atau ustadz yang mengajar kelas)
+func (ctrl *RaportController) SimpanRaport(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req SimpanRaportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !ctrl.cekAksesKelas(c, req.IDKelas) {
+		return
+	}
+
+	semester := models.Semester(req.Semester)
+	idSantri, err := services.AnggotaRaportKelas(ctrl.db, req.IDKelas, req.TahunAjaran, semester)
+	if err != nil {
+		if errors.Is(err, services.ErrRaportTidakValid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil anggota kelas: " + err.Error()})
+		return
+	}
+	anggota := false
+	for _, id := range idSantri {
+		if id == req.IDSantri {
+			anggota = true
+			break
+		}
+	}
+	if !anggota {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Santri bukan anggota kelas ini pada akhir semester"})
+		return
+	}
+
+	raport := models.Raport{
+		IDSantri:    req.IDSantri,
+		IDKelas:     req.IDKelas,
+		TahunAjaran: req.TahunAjaran,
+		Semester:    semester,
+		DiisiOleh:   userID,
+	}
+	if err := services.SimpanRaport(ctrl.db, &raport, req.Nilai, req.CatatanUstadz); err != nil {
+		if errors.Is(err, services.ErrRaportTidakValid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan raport: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Raport berhasil disimpan",
+		"data":    raport,
+	})
+}
+
+// GetRaportSantri mendapatkan daftar raport yang sudah diisi untuk seorang santri
+func (ctrl *RaportController) GetRaportSantri(c *gin.Context) {
+	santri, ok := ctrl.ambilSantri(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var raport []models.Raport
+	if err := ctrl.db.Preload("Kelas").Preload("Nilai").
+		Where("id_santri = ?", santri.IDSantri).
+		Order("tahun_ajaran DESC, semester DESC").
+		Find(&raport).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data raport: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"santri": santri,
+		"data":   raport,
+	})
+}
+
+// DownloadRaportSantri mengunduh raport santri dalam format PDF. Query: tahun_ajaran, semester.
+// Wali hanya bisa mengunduh raport anaknya yang sudah diisi.
+func (ctrl *RaportController) DownloadRaportSantri(c *gin.Context) {
+	santri, ok := ctrl.ambilSantri(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	tahunAjaran, semester, ok := ctrl.periode(c)
+	if !ok {
+		return
+	}
+
+	data, err := services.MuatDataRaport(ctrl.db, santri.IDSantri, tahunAjaran, semester)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyusun raport: " + err.Error()})
+		return
+	}
+	if data.Raport == nil {
+		if role, _ := c.Get("role"); role == string(models.RoleWali) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Raport semester ini belum tersedia"})
+			return
+		}
+	}
+
+	pdf, err := services.PDFRaport(ctrl.db, data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat PDF raport: " + err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=raport-%s-%s.pdf",
+		generateSlug(santri.NamaLengkap), generateSlug(string(semester)+" "+strings.ReplaceAll(tahunAjaran, "/", "-"))))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// DownloadRaportKelas mengunduh raport seluruh anggota kelas sebagai satu berkas ZIP.
+// Query: tahun_ajaran, semester
+func (ctrl *RaportController) DownloadRaportKelas(c *gin.Context) {
+	var kelas models.Kelas
+	if err := ctrl.db.Preload("WaliKelas").Where("id_kelas = ?", c.Param("id")).First(&kelas).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kelas tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data kelas: " + err.Error()})
+		return
+	}
+	if !ctrl.cekAksesKelas(c, kelas.IDKelas) {
+		return
+	}
+	tahunAjaran, semester, ok := ctrl.periode(c)
+	if !ok {
+		return
+	}
+
+	// Arsip disusun di memori dulu agar kegagalan di tengah tetap bisa dilaporkan sebagai JSON
+	var buf bytes.Buffer
+	jumlah, err := services.ZIPRaportKelas(ctrl.db, kelas, tahunAjaran, semester, &buf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat raport kelas: " + err.Error()})
+		return
+	}
+	if jumlah == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tidak ada santri di kelas ini pada semester tersebut"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=raport-%s-%s.zip",
+		generateSlug(kelas.NamaKelas), generateSlug(string(semester)+" "+strings.ReplaceAll(tahunAjaran, "/", "-"))))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
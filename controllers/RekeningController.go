@@ -0,0 +1,576 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RekeningController struct {
+	db *gorm.DB
+}
+
+func NewRekeningController(db *gorm.DB) *RekeningController {
+	return &RekeningController{db: db}
+}
+
+// Request structs
+type CreateRekeningRequest struct {
+	NamaRekening     string  `json:"nama_rekening" binding:"required"`
+	Jenis            string  `json:"jenis" binding:"required"` // tunai, bank, dompet_digital
+	NamaBank         string  `json:"nama_bank"`
+	NomorRekening    string  `json:"nomor_rekening"`
+	AtasNama         string  `json:"atas_nama"`
+	Utama            bool    `json:"utama"`
+	Keterangan       string  `json:"keterangan"`
+	SaldoAwal        float64 `json:"saldo_awal"`         // dipindahkan dari rekening utama
+	TanggalSaldoAwal string  `json:"tanggal_saldo_awal"` // format YYYY-MM-DD, kosong = hari ini
+}
+
+type UpdateRekeningRequest struct {
+	NamaRekening  *string `json:"nama_rekening"`
+	Jenis         *string `json:"jenis"`
+	NamaBank      *string `json:"nama_bank"`
+	NomorRekening *string `json:"nomor_rekening"`
+	AtasNama      *string `json:"atas_nama"`
+	Utama         *bool   `json:"utama"`
+	Aktif         *bool   `json:"aktif"`
+	Keterangan    *string `json:"keterangan"`
+}
+
+type CreateTransferRequest struct {
+	DariRekening string  `json:"dari_rekening" binding:"required"`
+	KeRekening   string  `json:"ke_rekening" binding:"required"`
+	Nominal      float64 `json:"nominal" binding:"required"`
+	Tanggal      string  `json:"tanggal"` // format YYYY-MM-DD, kosong = hari ini
+	Keterangan   string  `json:"keterangan"`
+}
+
+type CreateOpnameRequest struct {
+	IDRekening string   `json:"id_rekening" binding:"required"`
+	SaldoFisik *float64 `json:"saldo_fisik" binding:"required"`
+	Tanggal    string   `json:"tanggal"` // format YYYY-MM-DD, kosong = hari ini
+	Keterangan string   `json:"keterangan"`
+}
+
+type RekeningResponse struct {
+	models.Rekening
+	Saldo float64 `json:"saldo"`
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *RekeningController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// validJenisRekening mengecek jenis rekening dari request
+func (ctrl *RekeningController) validJenisRekening(jenis string) bool {
+	switch models.JenisRekening(jenis) {
+	case models.RekeningTunai, models.RekeningBank, models.RekeningDompetDigital:
+		return true
+	}
+	return false
+}
+
+// parseTanggal membaca tanggal YYYY-MM-DD, kosong berarti hari ini
+func (ctrl *RekeningController) parseTanggal(s string) (time.Time, error) {
+	if s == "" {
+		return time.Now(), nil
+	}
+	tanggal, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("Format tanggal tidak valid. Gunakan format YYYY-MM-DD")
+	}
+	if tanggal.After(time.Now()) {
+		return time.Time{}, errors.New("Tanggal tidak boleh di masa depan")
+	}
+	return tanggal, nil
+}
+
+// errorKeuangan memetakan error layanan keuangan ke status HTTP
+func (ctrl *RekeningController) errorKeuangan(c *gin.Context, pesan string, err error) {
+	switch {
+	case errors.Is(err, services.ErrPeriodeDitutup):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSaldoTidakCukup), errors.Is(err, services.ErrRekeningTidakValid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": pesan + ": data tidak ditemukan"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": pesan + ": " + err.Error()})
+	}
+}
+
+// GetAllRekening mendapatkan semua rekening beserta saldonya. Query: aktif=true untuk rekening aktif saja
+func (ctrl *RekeningController) GetAllRekening(c *gin.Context) {
+	query := ctrl.db.Model(&models.Rekening{})
+	if c.Query("aktif") == "true" {
+		query = query.Where("aktif = ?", true)
+	}
+
+	var rekening []models.Rekening
+	if err := query.Order("utama DESC, nama_rekening ASC").Find(&rekening).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data rekening: " + err.Error()})
+		return
+	}
+	saldo, err := services.SaldoRekening(ctrl.db, time.Time{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung saldo rekening: " + err.Error()})
+		return
+	}
+
+	hasil := make([]RekeningResponse, len(rekening))
+	var total float64
+	for i, r := range rekening {
+		hasil[i] = RekeningResponse{Rekening: r, Saldo: saldo[r.IDRekening]}
+		total += hasil[i].Saldo
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        hasil,
+		"total_saldo": total,
+	})
+}
+
+// CreateRekening menambah rekening baru. Saldo awal dipindahkan dari rekening utama.
+func (ctrl *RekeningController) CreateRekening(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req CreateRekeningRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !ctrl.validJenisRekening(req.Jenis) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Jenis tidak valid. Gunakan 'tunai', 'bank', atau 'dompet_digital'"})
+		return
+	}
+	if req.SaldoAwal < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Saldo awal tidak boleh negatif"})
+		return
+	}
+	tanggal, err := ctrl.parseTanggal(req.TanggalSaldoAwal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rekening := models.Rekening{
+		IDRekening:    uuid.New().String(),
+		NamaRekening:  req.NamaRekening,
+		Jenis:         models.JenisRekening(req.Jenis),
+		NamaBank:      req.NamaBank,
+		NomorRekening: req.NomorRekening,
+		AtasNama:      req.AtasNama,
+		Utama:         req.Utama,
+		Aktif:         true,
+		Keterangan:    req.Keterangan,
+	}
+	if err := services.NewKeuanganService(ctrl.db).CreateRekening(&rekening, req.SaldoAwal, tanggal, adminID); err != nil {
+		ctrl.errorKeuangan(c, "Gagal membuat rekening", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Rekening berhasil dibuat",
+		"data":    rekening,
+	})
+}
+
+// UpdateRekening mengubah data rekening. Rekening utama tidak bisa dinonaktifkan; pilih rekening
+// lain sebagai utama terlebih dahulu.
+func (ctrl *RekeningController) UpdateRekening(c *gin.Context) {
+	var req UpdateRekeningRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rekening models.Rekening
+	if err := ctrl.db.Where("id_rekening = ?", c.Param("id")).First(&rekening).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Rekening tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data rekening: " + err.Error()})
+		return
+	}
+
+	if req.NamaRekening != nil {
+		if *req.NamaRekening == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nama rekening tidak boleh kosong"})
+			return
+		}
+		rekening.NamaRekening = *req.NamaRekening
+	}
+	if req.Jenis != nil {
+		if !ctrl.validJenisRekening(*req.Jenis) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Jenis tidak valid. Gunakan 'tunai', 'bank', atau 'dompet_digital'"})
+			return
+		}
+		rekening.Jenis = models.JenisRekening(*req.Jenis)
+	}
+	if req.NamaBank != nil {
+		rekening.NamaBank = *req.NamaBank
+	}
+	if req.NomorRekening != nil {
+		rekening.NomorRekening = *req.NomorRekening
+	}
+	if req.AtasNama != nil {
+		rekening.AtasNama = *req.AtasNama
+	}
+	if req.Keterangan != nil {
+		rekening.Keterangan = *req.Keterangan
+	}
+	if req.Utama != nil {
+		if !*req.Utama && rekening.Utama {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Pilih rekening lain sebagai rekening utama terlebih dahulu"})
+			return
+		}
+		rekening.Utama = *req.Utama
+	}
+	if req.Aktif != nil {
+		rekening.Aktif = *req.Aktif
+	}
+	if rekening.Utama && !rekening.Aktif {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rekening utama tidak dapat dinonaktifkan"})
+		return
+	}
+
+	err := ctrl.db.Transaction(func(tx *gorm.DB) error {
+		if rekening.Utama {
+			if err := tx.Model(&models.Rekening{}).
+				Where("utama = ? AND id_rekening <> ?", true, rekening.IDRekening).
+				Update("utama", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&rekening).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate rekening: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Rekening berhasil diupdate",
+		"data":    rekening,
+	})
+}
+
+// DeleteRekening menghapus rekening yang belum pernah dipakai. Rekening yang sudah punya transaksi cukup dinonaktifkan.
+func (ctrl *RekeningController) DeleteRekening(c *gin.Context) {
+	var rekening models.Rekening
+	if err := ctrl.db.Where("id_rekening = ?", c.Param("id")).First(&rekening).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Rekening tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data rekening: " + err.Error()})
+		return
+	}
+	if rekening.Utama {
+		c.JSON(http.StatusConflict, gin.H{"error": "Rekening utama tidak dapat dihapus"})
+		return
+	}
+
+	id := rekening.IDRekening
+	var dipakai int64
+	cek := []*gorm.DB{
+		ctrl.db.Model(&models.JurnalDetail{}).Where("id_rekening = ?", id),
+		ctrl.db.Model(&models.TransferRekening{}).Where("dari_rekening = ? OR ke_rekening = ?", id, id),
+		ctrl.db.Model(&models.OpnameKas{}).Where("id_rekening = ?", id),
+		ctrl.db.Model(&models.PembayaranSyahriah{}).Where("id_rekening = ?", id),
+		ctrl.db.Model(&models.Donasi{}).Where("id_rekening = ?", id),
+		ctrl.db.Model(&models.PemakaianSaldo{}).Where("id_rekening = ?", id),
+		ctrl.db.Model(&models.ImporMutasiBank{}).Where("id_rekening = ?", id),
+	}
+	for _, query := range cek {
+		var jumlah int64
+		if err := query.Count(&jumlah).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengecek pemakaian rekening: " + err.Error()})
+			return
+		}
+		dipakai += jumlah
+	}
+	if dipakai > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Rekening sudah memiliki transaksi, nonaktifkan rekening ini sebagai gantinya"})
+		return
+	}
+
+	if err := ctrl.db.Delete(&rekening).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus rekening: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Rekening berhasil dihapus",
+	})
+}
+
+// GetAllTransfer mendapatkan riwayat transfer antar rekening. Query: id_rekening, start_date, end_date
+func (ctrl *RekeningController) GetAllTransfer(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var transfer []models.TransferRekening
+	var total int64
+
+	query := ctrl.db.Model(&models.TransferRekening{})
+	if id := c.Query("id_rekening"); id != "" {
+		query = query.Where("dari_rekening = ? OR ke_rekening = ?", id, id)
+	}
+	if start, err := parseDate(c.Query("start_date")); err == nil {
+		query = query.Where("tanggal >= ?", start)
+	}
+	if end, err := parseDate(c.Query("end_date")); err == nil {
+		query = query.Where("tanggal < ?", end.AddDate(0, 0, 1))
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Preload("Dari").Preload("Ke").Preload("Admin").
+		Order("tanggal DESC, waktu_catat DESC").Offset(offset).Limit(limit).Find(&transfer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data transfer: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": transfer,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// CreateTransfer mencatat pemindahan uang antar rekening, misalnya setor kas tunai ke bank
+func (ctrl *RekeningController) CreateTransfer(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req CreateTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Nominal <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nominal transfer harus lebih dari 0"})
+		return
+	}
+	if req.DariRekening == req.KeRekening {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rekening asal dan tujuan tidak boleh sama"})
+		return
+	}
+	tanggal, err := ctrl.parseTanggal(req.Tanggal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer := models.TransferRekening{
+		IDTransfer:   uuid.New().String(),
+		DariRekening: req.DariRekening,
+		KeRekening:   req.KeRekening,
+		Nominal:      req.Nominal,
+		Tanggal:      tanggal,
+		Keterangan:   req.Keterangan,
+		DicatatOleh:  adminID,
+	}
+	if err := services.NewKeuanganService(ctrl.db).CreateTransfer(&transfer, adminID); err != nil {
+		ctrl.errorKeuangan(c, "Gagal mencatat transfer", err)
+		return
+	}
+
+	ctrl.db.Preload("Dari").Preload("Ke").Preload("Admin").First(&transfer, "id_transfer = ?", transfer.IDTransfer)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Transfer antar rekening berhasil dicatat",
+		"data":    transfer,
+	})
+}
+
+// DeleteTransfer membatalkan transfer antar rekening
+func (ctrl *RekeningController) DeleteTransfer(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	if err := services.NewKeuanganService(ctrl.db).DeleteTransfer(c.Param("id"), adminID); err != nil {
+		ctrl.errorKeuangan(c, "Gagal menghapus transfer", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Transfer antar rekening berhasil dibatalkan",
+	})
+}
+
+// GetPosisiKas menampilkan layar rekonsiliasi kas: saldo sistem setiap rekening aktif
+// dibandingkan dengan hasil hitung fisik terakhirnya
+func (ctrl *RekeningController) GetPosisiKas(c *gin.Context) {
+	var rekening []models.Rekening
+	if err := ctrl.db.Where("aktif = ?", true).Order("utama DESC, nama_rekening ASC").Find(&rekening).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data rekening: " + err.Error()})
+		return
+	}
+	saldo, err := services.SaldoRekening(ctrl.db, time.Time{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung saldo rekening: " + err.Error()})
+		return
+	}
+
+	data := make([]gin.H, len(rekening))
+	var totalSistem float64
+	for i, r := range rekening {
+		item := gin.H{
+			"rekening":        r,
+			"saldo_sistem":    saldo[r.IDRekening],
+			"opname_terakhir": nil,
+		}
+		var opname models.OpnameKas
+		err := ctrl.db.Preload("Admin").Where("id_rekening = ?", r.IDRekening).
+			Order("tanggal DESC, waktu_catat DESC").First(&opname).Error
+		if err == nil {
+			item["opname_terakhir"] = opname
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data opname: " + err.Error()})
+			return
+		}
+		data[i] = item
+		totalSistem += saldo[r.IDRekening]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":               data,
+		"total_saldo_sistem": totalSistem,
+	})
+}
+
+// GetAllOpname mendapatkan riwayat hitung fisik kas. Query: id_rekening
+func (ctrl *RekeningController) GetAllOpname(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var opname []models.OpnameKas
+	var total int64
+
+	query := ctrl.db.Model(&models.OpnameKas{})
+	if id := c.Query("id_rekening"); id != "" {
+		query = query.Where("id_rekening = ?", id)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Preload("Rekening").Preload("Admin").
+		Order("tanggal DESC, waktu_catat DESC").Offset(offset).Limit(limit).Find(&opname).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data opname: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": opname,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// CreateOpname mencatat hasil hitung fisik satu rekening dan selisihnya terhadap saldo sistem.
+// Selisih hanya dicatat; koreksinya dilakukan admin lewat transaksi pemasukan atau pengeluaran.
+func (ctrl *RekeningController) CreateOpname(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req CreateOpnameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if *req.SaldoFisik < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Saldo fisik tidak boleh negatif"})
+		return
+	}
+	tanggal, err := ctrl.parseTanggal(req.Tanggal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opname := models.OpnameKas{
+		IDRekening:  req.IDRekening,
+		Tanggal:     tanggal,
+		SaldoFisik:  *req.SaldoFisik,
+		Keterangan:  req.Keterangan,
+		DicatatOleh: adminID,
+	}
+	if err := services.BuatOpname(ctrl.db, &opname); err != nil {
+		ctrl.errorKeuangan(c, "Gagal mencatat opname kas", err)
+		return
+	}
+
+	ctrl.db.Preload("Rekening").Preload("Admin").First(&opname, "id_opname = ?", opname.IDOpname)
+
+	pesan := "Opname kas berhasil dicatat, saldo fisik sesuai dengan sistem"
+	if opname.Selisih > 0.005 || opname.Selisih < -0.005 {
+		pesan = "Opname kas berhasil dicatat, terdapat selisih dengan saldo sistem"
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message": pesan,
+		"data":    opname,
+	})
+}
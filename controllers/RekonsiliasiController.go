@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+	"tpq_asysyafii/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RekonsiliasiController struct {
+	db *gorm.DB
+}
+
+func NewRekonsiliasiController(db *gorm.DB) *RekonsiliasiController {
+	return &RekonsiliasiController{db: db}
+}
+
+// Request structs
+type KonfirmasiMutasiRequest struct {
+	Tipe        string `json:"tipe"`         // syahriah, syahriah_santri, donasi, donasi_baru, qris; kosong = pakai usulan
+	IDReferensi string `json:"id_referensi"` // id_syahriah, id_santri, id_donasi atau id_permintaan (qris) sesuai tipe
+	NamaDonatur string `json:"nama_donatur"` // untuk donasi_baru
+}
+
+type KonfirmasiMassalRequest struct {
+	IDMutasi []string `json:"id_mutasi" binding:"required"`
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *RekonsiliasiController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// ImporMutasi mengunggah file mutasi rekening (CSV/XLSX, form field "file") lalu mengusulkan
+// pasangan untuk setiap uang masuk. Form/query "tahun" dipakai untuk file tanpa tahun di tanggalnya,
+// form "id_rekening" menentukan rekening yang menerima uang masuk tersebut.
+func (ctrl *RekonsiliasiController) ImporMutasi(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	tahun := time.Now().Year()
+	if s := c.DefaultQuery("tahun", c.PostForm("tahun")); s != "" {
+		t, err := strconv.Atoi(s)
+		if err != nil || t < 2000 || t > 2100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tahun tidak valid"})
+			return
+		}
+		tahun = t
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File mutasi diperlukan (field 'file')"})
+		return
+	}
+	f, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal membuka file: " + err.Error()})
+		return
+	}
+	defer f.Close()
+
+	data, err := utils.BacaUnggahan(f, batasFileImpor)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tabel, err := utils.BacaTabel(fileHeader.Filename, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal membaca file: " + err.Error()})
+		return
+	}
+	hasil, err := services.BacaMutasiBank(tabel, tahun)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var idRekening *string
+	if s := c.PostForm("id_rekening"); s != "" {
+		idRekening = &s
+	}
+	impor, mutasi, err := services.NewRekonsiliasiService(ctrl.db).Impor(fileHeader.Filename, hasil, idRekening, adminID)
+	if err != nil {
+		if errors.Is(err, services.ErrRekeningTidakValid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan mutasi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Mutasi berhasil diimpor",
+		"data":    impor,
+		"mutasi":  mutasi,
+	})
+}
+
+// GetAllImporMutasi mendapatkan riwayat unggah file mutasi
+func (ctrl *RekonsiliasiController) GetAllImporMutasi(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var impor []models.ImporMutasiBank
+	var total int64
+
+	query := ctrl.db.Model(&models.ImporMutasiBank{})
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Preload("Admin").Order("waktu_impor DESC").Offset(offset).Limit(limit).Find(&impor).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil riwayat impor: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": impor,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// GetAllMutasi mendapatkan baris mutasi. Query: id_impor, status, start_date, end_date
+func (ctrl *RekonsiliasiController) GetAllMutasi(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var mutasi []models.MutasiBank
+	var total int64
+
+	query := ctrl.db.Model(&models.MutasiBank{})
+	if idImpor := c.Query("id_impor"); idImpor != "" {
+		query = query.Where("id_impor = ?", idImpor)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if start, err := parseDate(c.Query("start_date")); err == nil {
+		query = query.Where("tanggal >= ?", start)
+	}
+	if end, err := parseDate(c.Query("end_date")); err == nil {
+		query = query.Where("tanggal <= ?", end)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	// Ringkasan per status untuk filter yang sama
+	var ringkasan []struct {
+		Status  string  `json:"status"`
+		Jumlah  int64   `json:"jumlah"`
+		Nominal float64 `json:"nominal"`
+	}
+	query.Session(&gorm.Session{}).Select("status, COUNT(*) AS jumlah, COALESCE(SUM(nominal), 0) AS nominal").Group("status").Scan(&ringkasan)
+
+	offset := (page - 1) * limit
+	if err := query.Order("tanggal DESC, baris ASC").Offset(offset).Limit(limit).Find(&mutasi).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data mutasi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      mutasi,
+		"ringkasan": ringkasan,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// GetKandidatMutasi mendapatkan semua calon pasangan untuk satu mutasi beserta skornya
+func (ctrl *RekonsiliasiController) GetKandidatMutasi(c *gin.Context) {
+	var mutasi models.MutasiBank
+	if err := ctrl.db.Where("id_mutasi = ?", c.Param("id")).First(&mutasi).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Mutasi tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil mutasi: " + err.Error()})
+		return
+	}
+
+	kandidat, err := services.NewRekonsiliasiService(ctrl.db).Kandidat(mutasi)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencari kandidat: " + err.Error()})
+		return
+	}
+	if kandidat == nil {
+		kandidat = []services.KandidatCocok{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     mutasi,
+		"kandidat": kandidat,
+	})
+}
+
+// KonfirmasiMutasi mencatat pembayaran untuk satu mutasi. Body kosong = menerima usulan sistem.
+func (ctrl *RekonsiliasiController) KonfirmasiMutasi(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req KonfirmasiMutasiRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mutasi, err := services.NewRekonsiliasiService(ctrl.db).Konfirmasi(c.Param("id"), services.PilihanCocok{
+		Tipe:        req.Tipe,
+		IDReferensi: req.IDReferensi,
+		NamaDonatur: req.NamaDonatur,
+	}, adminID)
+	if err != nil {
+		c.JSON(statusErrorRekonsiliasi(err), gin.H{"error": "Gagal mengonfirmasi mutasi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Mutasi berhasil dikonfirmasi",
+		"data":    mutasi,
+	})
+}
+
+// KonfirmasiMassal menerima usulan sistem untuk beberapa mutasi sekaligus.
+// Setiap mutasi diproses dalam transaksinya sendiri; kegagalan satu baris tidak membatalkan yang lain.
+func (ctrl *RekonsiliasiController) KonfirmasiMassal(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req KonfirmasiMassalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewRekonsiliasiService(ctrl.db)
+	var berhasil []models.MutasiBank
+	gagal := []gin.H{}
+	for _, id := range req.IDMutasi {
+		mutasi, err := service.Konfirmasi(id, services.PilihanCocok{}, adminID)
+		if err != nil {
+			gagal = append(gagal, gin.H{"id_mutasi": id, "error": err.Error()})
+			continue
+		}
+		berhasil = append(berhasil, *mutasi)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Konfirmasi selesai",
+		"berhasil": berhasil,
+		"gagal":    gagal,
+	})
+}
+
+// AbaikanMutasi menandai mutasi bukan penerimaan syahriah/donasi
+func (ctrl *RekonsiliasiController) AbaikanMutasi(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	mutasi, err := services.NewRekonsiliasiService(ctrl.db).Abaikan(c.Param("id"), adminID)
+	if err != nil {
+		c.JSON(statusErrorRekonsiliasi(err), gin.H{"error": "Gagal mengabaikan mutasi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Mutasi ditandai diabaikan",
+		"data":    mutasi,
+	})
+}
+
+// statusErrorRekonsiliasi memetakan error layanan rekonsiliasi ke status HTTP
+func statusErrorRekonsiliasi(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrPeriodeDitutup):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrMutasiSudahDikonfirmasi):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -29,6 +30,8 @@ type CreateSantriRequest struct {
 	Alamat       string        `json:"alamat"`
 	Foto         string        `json:"foto"`
 	Status       models.StatusSantri `json:"status"`
+	Program      string        `json:"program"`
+	Kelas        string        `json:"kelas"`
 	TanggalMasuk string        `json:"tanggal_masuk"` // Format: YYYY-MM-DD
 }
 
@@ -40,6 +43,8 @@ type UpdateSantriRequest struct {
 	Alamat       string        `json:"alamat"`
 	Foto         string        `json:"foto"`
 	Status       models.StatusSantri `json:"status"`
+	Program      *string       `json:"program"`
+	Kelas        *string       `json:"kelas"`
 	TanggalMasuk string        `json:"tanggal_masuk"` // Format: YYYY-MM-DD
 	TanggalKeluar *string      `json:"tanggal_keluar"` // Format: YYYY-MM-DD, bisa null
 }
@@ -118,6 +123,8 @@ func (ctrl *SantriController) CreateSantri(c *gin.Context) {
 		Alamat:       req.Alamat,
 		Foto:         req.Foto,
 		Status:       req.Status,
+		Program:      req.Program,
+		Kelas:        req.Kelas,
 		TanggalMasuk: tanggalMasuk,
 	}
 
@@ -250,6 +257,74 @@ func (ctrl *SantriController) GetMySantri(c *gin.Context) {
 	})
 }
 
+// GetMyAbsensiSantri mendapatkan absensi anak-anak wali yang sedang login dalam satu bulan.
+// Query: bulan (format YYYY-MM, default bulan ini)
+func (ctrl *SantriController) GetMyAbsensiSantri(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	bulan := c.DefaultQuery("bulan", time.Now().Format("2006-01"))
+	dari, err := time.ParseInLocation("2006-01", bulan, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format bulan tidak valid, gunakan format YYYY-MM"})
+		return
+	}
+	sampai := dari.AddDate(0, 1, 0)
+
+	var santri []models.Santri
+	if err := ctrl.db.Where("id_wali = ?", userID).Order("nama_lengkap ASC").Find(&santri).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data santri: " + err.Error()})
+		return
+	}
+	idSantri := make([]string, len(santri))
+	for i, s := range santri {
+		idSantri[i] = s.IDSantri
+	}
+
+	rekap, err := services.HitungRekapAbsensi(ctrl.db, "", idSantri, dari, sampai)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung rekap absensi: " + err.Error()})
+		return
+	}
+
+	var absensi []models.Absensi
+	if len(idSantri) > 0 {
+		if err := ctrl.db.Preload("Sesi").Preload("Sesi.Kelas").
+			Joins("JOIN sesi_absensi ON sesi_absensi.id_sesi = absensi.id_sesi").
+			Where("absensi.id_santri IN ? AND sesi_absensi.tanggal >= ? AND sesi_absensi.tanggal < ?", idSantri, dari, sampai).
+			Order("sesi_absensi.tanggal ASC").
+			Find(&absensi).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data absensi: " + err.Error()})
+			return
+		}
+	}
+	perSantri := make(map[string][]models.Absensi)
+	for _, a := range absensi {
+		perSantri[a.IDSantri] = append(perSantri[a.IDSantri], a)
+	}
+
+	data := make([]gin.H, len(santri))
+	for i, s := range santri {
+		detail := perSantri[s.IDSantri]
+		if detail == nil {
+			detail = []models.Absensi{}
+		}
+		data[i] = gin.H{
+			"santri":  s,
+			"rekap":   rekap[s.IDSantri],
+			"absensi": detail,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  data,
+		"bulan": dari.Format("2006-01"),
+	})
+}
+
 // GetAllSantri mendapatkan semua data santri (untuk admin)
 func (ctrl *SantriController) GetAllSantri(c *gin.Context) {
 	var santri []models.Santri
@@ -336,6 +411,12 @@ func (ctrl *SantriController) UpdateSantri(c *gin.Context) {
 	if req.Status != "" {
 		existingSantri.Status = req.Status
 	}
+	if req.Program != nil {
+		existingSantri.Program = *req.Program
+	}
+	if req.Kelas != nil {
+		existingSantri.Kelas = *req.Kelas
+	}
 	if req.TanggalMasuk != "" {
 		tanggalMasuk, err := parseDate(req.TanggalMasuk)
 		if err != nil {
@@ -1,11 +1,15 @@
 package controllers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -24,7 +28,7 @@ func NewSyahriahController(db *gorm.DB) *SyahriahController {
 type CreateSyahriahRequest struct {
 	ID_Santri string  `json:"id_santri" binding:"required"`
 	Bulan    string  `json:"bulan" binding:"required"` // format YYYY-MM
-	Nominal  float64 `json:"nominal"`
+	Nominal  float64 `json:"nominal"` // kosong = dihitung dari tarif dan keringanan santri
 	Status   string  `json:"status"`
 }
 
@@ -34,7 +38,20 @@ type UpdateSyahriahRequest struct {
 }
 
 type BayarSyahriahRequest struct {
-	Status string `json:"status" binding:"required"` // hanya untuk update status menjadi lunas
+	Nominal      float64 `json:"nominal"`       // kosong = melunasi sisa tagihan
+	Metode       string  `json:"metode"`        // tunai, transfer, qris, lainnya
+	TanggalBayar string  `json:"tanggal_bayar"` // format YYYY-MM-DD, kosong = hari ini
+	Keterangan   string  `json:"keterangan"`
+	IDRekening   *string `json:"id_rekening"` // kosong = rekening utama
+}
+
+type BayarTagihanSantriRequest struct {
+	ID_Santri    string  `json:"id_santri" binding:"required"`
+	Nominal      float64 `json:"nominal" binding:"required"`
+	Metode       string  `json:"metode"`
+	TanggalBayar string  `json:"tanggal_bayar"`
+	Keterangan   string  `json:"keterangan"`
+	IDRekening   *string `json:"id_rekening"`
 }
 
 // Helper function untuk check role admin
@@ -56,22 +73,6 @@ func (ctrl *SyahriahController) getUserID(c *gin.Context) (string, bool) {
 	return userID.(string), true
 }
 
-func (ctrl *SyahriahController) updateRekapOtomatis(syahriah models.Syahriah) {
-	rekapController := NewRekapController(ctrl.db)
-	
-	// Parse bulan untuk dapat time.Time
-	bulanTime, err := time.Parse("2006-01", syahriah.Bulan)
-	if err != nil {
-		fmt.Printf("Gagal parse bulan: %v\n", err)
-		return
-	}
-	
-	if err := rekapController.UpdateRekapOtomatis(bulanTime); err != nil {
-		// Log error tapi jangan gagalkan operasi utama
-		fmt.Printf("Gagal update rekap: %v\n", err)
-	}
-}
-
 // CreateSyahriah membuat data syahriah baru (hanya admin)
 func (ctrl *SyahriahController) CreateSyahriah(c *gin.Context) {
 	// Hanya admin yang bisa create
@@ -114,11 +115,6 @@ func (ctrl *SyahriahController) CreateSyahriah(c *gin.Context) {
 		return
 	}
 
-	// Set default nominal jika tidak diisi
-	if req.Nominal == 0 {
-		req.Nominal = 110000 // default value
-	}
-
 	// Validasi status
 	var status models.StatusSyahriah
 	if req.Status != "" {
@@ -136,14 +132,45 @@ func (ctrl *SyahriahController) CreateSyahriah(c *gin.Context) {
 		IDSyahriah:  uuid.New().String(),
 		ID_Santri:    req.ID_Santri,
 		Bulan:       req.Bulan,
-		Nominal:     req.Nominal,
 		Status:      status,
 		DicatatOleh: adminID,
 		WaktuCatat:  time.Now(),
 	}
 
-	// Simpan ke database
-	if err := ctrl.db.Create(&syahriah).Error; err != nil {
+	// Nominal manual dari admin, atau dihitung dari tarif yang berlaku
+	if req.Nominal > 0 {
+		syahriah.Nominal = req.Nominal
+		syahriah.NominalDasar = req.Nominal
+		syahriah.KeteranganTarif = "Nominal manual"
+	} else {
+		tarifService, err := services.NewTarifService(ctrl.db, req.Bulan)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat tarif syahriah: " + err.Error()})
+			return
+		}
+		rincian, err := tarifService.Hitung(santri)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rincian.TerapkanKe(&syahriah)
+	}
+
+	// Santri yang dibebaskan tidak memiliki tagihan
+	if syahriah.Nominal == 0 {
+		syahriah.Status = models.StatusLunas
+	}
+
+	// Simpan syahriah, jurnal dan rekap dalam satu transaksi
+	if _, err := services.NewKeuanganService(ctrl.db).CreateSyahriah(&syahriah, adminID); err != nil {
+		if errors.Is(err, services.ErrPeriodeDitutup) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrSyahriahSudahAda) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Syahriah untuk bulan ini sudah ada"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat data syahriah: " + err.Error()})
 		return
 	}
@@ -151,8 +178,6 @@ func (ctrl *SyahriahController) CreateSyahriah(c *gin.Context) {
 	// Preload relations untuk response
 	ctrl.db.Preload("Santri").Preload("Santri.Wali").Preload("Admin").First(&syahriah, "id_syahriah = ?", syahriah.IDSyahriah)
 
-	ctrl.updateRekapOtomatis(syahriah)
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Data syahriah berhasil dibuat",
 		"data":    syahriah,
@@ -170,9 +195,6 @@ func (ctrl *SyahriahController) GetAllSyahriah(c *gin.Context) {
 	// Parse query parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	bulan := c.Query("bulan")
-	status := c.Query("status")
-	idSantri := c.Query("id_santri") // untuk filter oleh admin
 
 	if page < 1 {
 		page = 1
@@ -185,23 +207,7 @@ func (ctrl *SyahriahController) GetAllSyahriah(c *gin.Context) {
 	var total int64
 
 	// Build query dengan preload yang benar
-	query := ctrl.db.Preload("Santri").Preload("Santri.Wali").Preload("Admin")
-
-	// Jika user adalah santri, hanya tampilkan data miliknya
-	if !ctrl.isAdmin(c) {
-		query = query.Where("id_santri = ?", userID)
-	} else if idSantri != "" {
-		// Jika admin dan filter by id_santri
-		query = query.Where("id_santri = ?", idSantri)
-	}
-
-	// Apply filters
-	if bulan != "" {
-		query = query.Where("bulan = ?", bulan)
-	}
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
+	query := ctrl.filterSyahriah(c, ctrl.db.Preload("Santri").Preload("Santri.Wali").Preload("Admin"), userID)
 
 	// Hitung total records
 	if err := query.Model(&models.Syahriah{}).Count(&total).Error; err != nil {
@@ -211,7 +217,7 @@ func (ctrl *SyahriahController) GetAllSyahriah(c *gin.Context) {
 
 	// Apply pagination
 	offset := (page - 1) * limit
-	err := query.Order("bulan DESC, waktu_catat DESC").
+	err := query.Order("syahriah.bulan DESC, syahriah.waktu_catat DESC").
 		Offset(offset).
 		Limit(limit).
 		Find(&syahriah).Error
@@ -232,6 +238,66 @@ func (ctrl *SyahriahController) GetAllSyahriah(c *gin.Context) {
 	})
 }
 
+// filterSyahriah menerapkan filter GetAllSyahriah (bulan, status, id_santri).
+// Kolom ditulis lengkap dengan nama tabel agar query bisa di-join dengan santri dan users.
+func (ctrl *SyahriahController) filterSyahriah(c *gin.Context, query *gorm.DB, userID string) *gorm.DB {
+	// Jika user adalah santri, hanya tampilkan data miliknya
+	if !ctrl.isAdmin(c) {
+		query = query.Where("syahriah.id_santri = ?", userID)
+	} else if idSantri := c.Query("id_santri"); idSantri != "" {
+		// Jika admin dan filter by id_santri
+		query = query.Where("syahriah.id_santri = ?", idSantri)
+	}
+
+	if bulan := c.Query("bulan"); bulan != "" {
+		query = query.Where("syahriah.bulan = ?", bulan)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("syahriah.status = ?", status)
+	}
+	return query
+}
+
+// ExportSyahriah mengunduh data syahriah sebagai XLSX atau CSV dengan filter yang sama seperti GetAllSyahriah
+func (ctrl *SyahriahController) ExportSyahriah(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	type barisSyahriah struct {
+		Bulan           string
+		NamaSantri      string
+		Kelas           string
+		NamaWali        string
+		NominalDasar    float64
+		Potongan        float64
+		Nominal         float64
+		Terbayar        float64
+		Status          string
+		KeteranganTarif string
+		WaktuCatat      time.Time
+	}
+
+	query := ctrl.db.Table("syahriah").
+		Select("syahriah.bulan, santri.nama_lengkap AS nama_santri, santri.kelas, users.nama_lengkap AS nama_wali, " +
+			"syahriah.nominal_dasar, syahriah.potongan, syahriah.nominal, syahriah.terbayar, syahriah.status, " +
+			"syahriah.keterangan_tarif, syahriah.waktu_catat").
+		Joins("LEFT JOIN santri ON santri.id_santri = syahriah.id_santri").
+		Joins("LEFT JOIN users ON users.id_user = santri.id_wali")
+	query = ctrl.filterSyahriah(c, query, userID).Order("syahriah.bulan DESC, syahriah.waktu_catat DESC")
+
+	header := []interface{}{"Bulan", "Nama Santri", "Kelas", "Nama Wali", "Nominal Dasar", "Potongan", "Nominal", "Terbayar", "Sisa", "Status", "Keterangan Tarif", "Waktu Catat"}
+	kirimEkspor(c, "syahriah", header, query, func(rows *sql.Rows) ([]interface{}, error) {
+		var b barisSyahriah
+		if err := ctrl.db.ScanRows(rows, &b); err != nil {
+			return nil, err
+		}
+		return []interface{}{b.Bulan, b.NamaSantri, b.Kelas, b.NamaWali, b.NominalDasar, b.Potongan, b.Nominal, b.Terbayar, b.Nominal - b.Terbayar, b.Status, b.KeteranganTarif, b.WaktuCatat}, nil
+	})
+}
+
 // GetSyahriahByID mendapatkan syahriah berdasarkan ID
 func (ctrl *SyahriahController) GetSyahriahByID(c *gin.Context) {
 	id := c.Param("id")
@@ -247,7 +313,10 @@ func (ctrl *SyahriahController) GetSyahriahByID(c *gin.Context) {
 	}
 
 	var syahriah models.Syahriah
-	err := ctrl.db.Preload("Santri").Preload("Santri.Wali").Preload("Admin").Where("id_syahriah = ?", id).First(&syahriah).Error
+	err := ctrl.db.Preload("Santri").Preload("Santri.Wali").Preload("Admin").
+		Preload("Pembayaran", func(db *gorm.DB) *gorm.DB { return db.Order("tanggal_bayar ASC") }).
+		Preload("Pembayaran.Penerima").
+		Where("id_syahriah = ?", id).First(&syahriah).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Data syahriah tidak ditemukan"})
@@ -268,6 +337,51 @@ func (ctrl *SyahriahController) GetSyahriahByID(c *gin.Context) {
 	})
 }
 
+// CreateQRISSyahriah menerbitkan QRIS dinamis sebesar sisa tagihan dengan referensi yang akan
+// dipasangkan otomatis saat mutasi rekening diimpor. QRIS yang masih berlaku untuk sisa tagihan
+// yang sama dikembalikan lagi. Wali hanya untuk tagihan santrinya sendiri.
+func (ctrl *SyahriahController) CreateQRISSyahriah(c *gin.Context) {
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var syahriah models.Syahriah
+	err := ctrl.db.Preload("Santri").Where("id_syahriah = ?", c.Param("id")).First(&syahriah).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data syahriah tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data syahriah: " + err.Error()})
+		return
+	}
+
+	if !ctrl.isAdmin(c) && syahriah.Santri.IDWali != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak memiliki akses ke data ini"})
+		return
+	}
+	if syahriah.Status == models.StatusLunas {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Syahriah sudah lunas"})
+		return
+	}
+
+	qris, err := services.NewQRISService(ctrl.db).UntukSyahriah(syahriah)
+	if err != nil {
+		if errors.Is(err, services.ErrQRISBelumDiatur) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat QRIS: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": qris,
+	})
+}
+
 // GetMySyahriah mendapatkan data syahriah milik user yang login (untuk santri)
 func (ctrl *SyahriahController) GetMySyahriah(c *gin.Context) {
 	userID, exists := ctrl.getUserID(c)
@@ -346,6 +460,12 @@ func (ctrl *SyahriahController) UpdateSyahriah(c *gin.Context) {
 		return
 	}
 
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
 	var req UpdateSyahriahRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -366,19 +486,29 @@ func (ctrl *SyahriahController) UpdateSyahriah(c *gin.Context) {
 
 	// Update fields
 	if req.Nominal > 0 {
+		if req.Nominal < existingSyahriah.Terbayar {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Nominal tidak boleh lebih kecil dari yang sudah dibayar (Rp %.0f)", existingSyahriah.Terbayar)})
+			return
+		}
 		existingSyahriah.Nominal = req.Nominal
 	}
+
+	// Status sebagian diturunkan dari pembayaran, admin hanya bisa melunasi atau membatalkan pembayaran
+	var statusDiminta models.StatusSyahriah
 	if req.Status != "" {
-		status := models.StatusSyahriah(req.Status)
-		if status != models.StatusBelum && status != models.StatusLunas {
+		statusDiminta = models.StatusSyahriah(req.Status)
+		if statusDiminta != models.StatusBelum && statusDiminta != models.StatusLunas {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Status tidak valid. Gunakan 'belum' atau 'lunas'"})
 			return
 		}
-		existingSyahriah.Status = status
 	}
 
-	// Simpan perubahan
-	if err := ctrl.db.Save(&existingSyahriah).Error; err != nil {
+	// Simpan perubahan, pembayaran, jurnal dan rekap dalam satu transaksi
+	if _, err := services.NewKeuanganService(ctrl.db).UpdateSyahriah(&existingSyahriah, statusDiminta, adminID); err != nil {
+		if errors.Is(err, services.ErrPeriodeDitutup) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate data syahriah: " + err.Error()})
 		return
 	}
@@ -386,15 +516,13 @@ func (ctrl *SyahriahController) UpdateSyahriah(c *gin.Context) {
 	// Preload relations untuk response
 	ctrl.db.Preload("Santri").Preload("Santri.Wali").Preload("Admin").First(&existingSyahriah, "id_syahriah = ?", existingSyahriah.IDSyahriah)
 
-	ctrl.updateRekapOtomatis(existingSyahriah)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Data syahriah berhasil diupdate",
 		"data":    existingSyahriah,
 	})
 }
 
-// BayarSyahriah update status menjadi lunas (bisa oleh santri untuk bayar)
+// BayarSyahriah mencatat pembayaran (cicilan atau pelunasan) untuk satu tagihan syahriah
 func (ctrl *SyahriahController) BayarSyahriah(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -408,18 +536,13 @@ func (ctrl *SyahriahController) BayarSyahriah(c *gin.Context) {
 		return
 	}
 
+	// Body boleh kosong untuk melunasi sisa tagihan hari ini secara tunai
 	var req BayarSyahriahRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Validasi status
-	if req.Status != string(models.StatusLunas) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Status harus 'lunas' untuk pembayaran"})
-		return
-	}
-
 	// Cek apakah syahriah exists
 	var existingSyahriah models.Syahriah
 	err := ctrl.db.Where("id_syahriah = ?", id).First(&existingSyahriah).Error
@@ -438,27 +561,204 @@ func (ctrl *SyahriahController) BayarSyahriah(c *gin.Context) {
 		return
 	}
 
-	// Update status menjadi lunas
-	existingSyahriah.Status = models.StatusLunas
-	existingSyahriah.WaktuCatat = time.Now() // Update waktu catat saat pembayaran
+	if existingSyahriah.Status == models.StatusLunas {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Syahriah sudah lunas"})
+		return
+	}
+
+	pembayaran, err := ctrl.buatPembayaran(req.Nominal, req.Metode, req.TanggalBayar, req.Keterangan, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pembayaran.IDRekening = req.IDRekening
+	pembayaran.IDSyahriah = existingSyahriah.IDSyahriah
+	if pembayaran.Nominal == 0 {
+		pembayaran.Nominal = services.SisaTagihan(existingSyahriah)
+	}
 
-	// Simpan perubahan
-	if err := ctrl.db.Save(&existingSyahriah).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal melakukan pembayaran: " + err.Error()})
+	// Simpan pembayaran, jurnal pemasukan dan rekap dalam satu transaksi
+	if _, err := services.NewKeuanganService(ctrl.db).TambahPembayaran(pembayaran, userID); err != nil {
+		ctrl.errorPembayaran(c, "Gagal melakukan pembayaran", err)
 		return
 	}
 
 	// Preload relations untuk response
-	ctrl.db.Preload("Santri").Preload("Santri.Wali").Preload("Admin").First(&existingSyahriah, "id_syahriah = ?", existingSyahriah.IDSyahriah)
+	ctrl.db.Preload("Santri").Preload("Santri.Wali").Preload("Admin").Preload("Pembayaran.Penerima").
+		First(&existingSyahriah, "id_syahriah = ?", existingSyahriah.IDSyahriah)
 
-	ctrl.updateRekapOtomatis(existingSyahriah)
+	var kwitansi models.Kwitansi
+	ctrl.db.Where("tipe_sumber = ? AND id_sumber = ?", services.TargetPembayaranSyahriah, pembayaran.IDPembayaran).First(&kwitansi)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Pembayaran syahriah berhasil",
-		"data":    existingSyahriah,
+		"message":  "Pembayaran syahriah berhasil",
+		"data":     existingSyahriah,
+		"kwitansi": kwitansi,
+	})
+}
+
+// BayarTagihanSantri mencatat satu pembayaran untuk beberapa bulan sekaligus, dialokasikan ke tagihan terlama
+func (ctrl *SyahriahController) BayarTagihanSantri(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req BayarTagihanSantriRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var santri models.Santri
+	if err := ctrl.db.Where("id_santri = ?", req.ID_Santri).First(&santri).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Santri tidak ditemukan"})
+		return
+	}
+
+	pembayaran, err := ctrl.buatPembayaran(req.Nominal, req.Metode, req.TanggalBayar, req.Keterangan, adminID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pembayaran.IDRekening = req.IDRekening
+
+	tercatat, _, err := services.NewKeuanganService(ctrl.db).BayarTagihanSantri(santri.IDSantri, *pembayaran, adminID)
+	if err != nil {
+		ctrl.errorPembayaran(c, "Gagal melakukan pembayaran", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": fmt.Sprintf("Pembayaran Rp %.0f berhasil dialokasikan ke %d tagihan", req.Nominal, len(tercatat)),
+		"data":    tercatat,
 	})
 }
 
+// GetPembayaranSyahriah mendapatkan riwayat pembayaran satu tagihan syahriah
+func (ctrl *SyahriahController) GetPembayaranSyahriah(c *gin.Context) {
+	id := c.Param("id")
+
+	userID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var syahriah models.Syahriah
+	if err := ctrl.db.Preload("Santri").Where("id_syahriah = ?", id).First(&syahriah).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data syahriah tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data syahriah: " + err.Error()})
+		return
+	}
+
+	// Authorization: admin, santri yang bersangkutan, atau walinya
+	if !ctrl.isAdmin(c) && syahriah.ID_Santri != userID && syahriah.Santri.IDWali != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Anda tidak memiliki akses ke data ini"})
+		return
+	}
+
+	var pembayaran []models.PembayaranSyahriah
+	if err := ctrl.db.Preload("Penerima").
+		Where("id_syahriah = ?", id).
+		Order("tanggal_bayar ASC").
+		Find(&pembayaran).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pembayaran: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": pembayaran,
+		"tagihan": gin.H{
+			"nominal":  syahriah.Nominal,
+			"terbayar": syahriah.Terbayar,
+			"sisa":     services.SisaTagihan(syahriah),
+			"status":   syahriah.Status,
+		},
+	})
+}
+
+// DeletePembayaranSyahriah membatalkan satu pembayaran dan menghitung ulang status tagihannya (hanya admin)
+func (ctrl *SyahriahController) DeletePembayaranSyahriah(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	id := c.Param("id")
+	var pembayaran models.PembayaranSyahriah
+	if err := ctrl.db.Where("id_pembayaran = ?", id).First(&pembayaran).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data pembayaran tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data pembayaran: " + err.Error()})
+		return
+	}
+
+	if _, err := services.NewKeuanganService(ctrl.db).HapusPembayaran(id, adminID); err != nil {
+		ctrl.errorPembayaran(c, "Gagal menghapus pembayaran", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pembayaran syahriah berhasil dibatalkan",
+	})
+}
+
+// buatPembayaran memvalidasi input pembayaran dari request
+func (ctrl *SyahriahController) buatPembayaran(nominal float64, metode, tanggalBayar, keterangan, userID string) (*models.PembayaranSyahriah, error) {
+	if nominal < 0 {
+		return nil, errors.New("Nominal pembayaran tidak boleh negatif")
+	}
+
+	pembayaran := &models.PembayaranSyahriah{
+		Nominal:      nominal,
+		Metode:       models.MetodeTunai,
+		TanggalBayar: time.Now(),
+		DiterimaOleh: userID,
+		Keterangan:   keterangan,
+	}
+	if metode != "" {
+		pembayaran.Metode = models.MetodeBayar(metode)
+		switch pembayaran.Metode {
+		case models.MetodeTunai, models.MetodeTransfer, models.MetodeQRIS, models.MetodeLainnya:
+		default:
+			return nil, errors.New("Metode tidak valid. Gunakan 'tunai', 'transfer', 'qris' atau 'lainnya'")
+		}
+	}
+	if tanggalBayar != "" {
+		tanggal, err := time.ParseInLocation("2006-01-02", tanggalBayar, time.Local)
+		if err != nil {
+			return nil, errors.New("Format tanggal_bayar tidak valid. Gunakan format YYYY-MM-DD")
+		}
+		if tanggal.After(time.Now()) {
+			return nil, errors.New("Tanggal bayar tidak boleh di masa depan")
+		}
+		pembayaran.TanggalBayar = tanggal
+	}
+	return pembayaran, nil
+}
+
+// errorPembayaran memetakan error layanan pembayaran ke status HTTP
+func (ctrl *SyahriahController) errorPembayaran(c *gin.Context, pesan string, err error) {
+	switch {
+	case errors.Is(err, services.ErrPeriodeDitutup):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMelebihiSisaTagihan), errors.Is(err, services.ErrRekeningTidakValid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": pesan + ": data tidak ditemukan"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": pesan + ": " + err.Error()})
+	}
+}
+
 // DeleteSyahriah menghapus data syahriah (hanya admin)
 func (ctrl *SyahriahController) DeleteSyahriah(c *gin.Context) {
 	// Hanya admin yang bisa delete
@@ -473,6 +773,12 @@ func (ctrl *SyahriahController) DeleteSyahriah(c *gin.Context) {
 		return
 	}
 
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
 	// Cek apakah syahriah exists
 	var syahriah models.Syahriah
 	err := ctrl.db.Where("id_syahriah = ?", id).First(&syahriah).Error
@@ -485,19 +791,16 @@ func (ctrl *SyahriahController) DeleteSyahriah(c *gin.Context) {
 		return
 	}
 
-	bulan := syahriah.Bulan
-
-	// Hapus syahriah
-	if err := ctrl.db.Where("id_syahriah = ?", id).Delete(&models.Syahriah{}).Error; err != nil {
+	// Hapus syahriah, balik jurnalnya dan update rekap dalam satu transaksi
+	if _, err := services.NewKeuanganService(ctrl.db).DeleteSyahriah(id, adminID); err != nil {
+		if errors.Is(err, services.ErrPeriodeDitutup) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus data syahriah: " + err.Error()})
 		return
 	}
 
-	rekapController := NewRekapController(ctrl.db)
-	if err := rekapController.UpdateRekapByBulan(bulan); err != nil {
-		fmt.Printf("Gagal update rekap: %v\n", err)
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Data syahriah berhasil dihapus",
 	})
@@ -517,44 +820,53 @@ func (ctrl *SyahriahController) GetSyahriahSummary(c *gin.Context) {
 		query = query.Where("id_santri = ?", userID)
 	}
 
-	// Hitung total
-	var total int64
-	if err := query.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total syahriah: " + err.Error()})
+	ringkasan, err := ctrl.ringkasanSyahriah(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung summary syahriah: " + err.Error()})
 		return
 	}
 
-	// Hitung lunas
-	var lunas int64
-	if err := query.Where("status = ?", models.StatusLunas).Count(&lunas).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung syahriah lunas: " + err.Error()})
-		return
-	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": ringkasan,
+	})
+}
 
-	// Hitung belum lunas
-	var belum int64
-	if err := query.Where("status = ?", models.StatusBelum).Count(&belum).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung syahriah belum lunas: " + err.Error()})
-		return
+// ringkasanSyahriah menghitung jumlah tagihan per status dan total uang yang sudah diterima.
+// Setiap hitungan memakai sesi baru agar kondisi status tidak menumpuk di query dasar.
+func (ctrl *SyahriahController) ringkasanSyahriah(query *gorm.DB) (gin.H, error) {
+	var total, lunas, sebagian, belum int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	if err := query.Session(&gorm.Session{}).Where("status = ?", models.StatusLunas).Count(&lunas).Error; err != nil {
+		return nil, err
+	}
+	if err := query.Session(&gorm.Session{}).Where("status = ?", models.StatusSebagian).Count(&sebagian).Error; err != nil {
+		return nil, err
+	}
+	if err := query.Session(&gorm.Session{}).Where("status = ?", models.StatusBelum).Count(&belum).Error; err != nil {
+		return nil, err
 	}
 
-	// Total nominal yang sudah dibayar (hanya yang status lunas)
-	var totalNominal float64
-	if err := query.Where("status = ?", models.StatusLunas).
-		Select("COALESCE(SUM(nominal), 0)").
-		Scan(&totalNominal).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total nominal: " + err.Error()})
-		return
+	// Total nominal dihitung dari uang yang benar-benar diterima, termasuk cicilan
+	var nominal struct {
+		TotalNominal float64
+		TotalSisa    float64
+	}
+	if err := query.Session(&gorm.Session{}).
+		Select("COALESCE(SUM(terbayar), 0) AS total_nominal, COALESCE(SUM(GREATEST(nominal - terbayar, 0)), 0) AS total_sisa").
+		Scan(&nominal).Error; err != nil {
+		return nil, err
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": gin.H{
-			"total":         total,
-			"lunas":         lunas,
-			"belum_lunas":   belum,
-			"total_nominal": totalNominal,
-		},
-	})
+	return gin.H{
+		"total":         total,
+		"lunas":         lunas,
+		"sebagian":      sebagian,
+		"belum_lunas":   belum,
+		"total_nominal": nominal.TotalNominal,
+		"total_sisa":    nominal.TotalSisa,
+	}, nil
 }
 
 // BatchCreateSyahriah membuat data syahriah untuk semua santri yang belum memiliki data di bulan tertentu
@@ -574,7 +886,7 @@ func (ctrl *SyahriahController) BatchCreateSyahriah(c *gin.Context) {
 
 	var req struct {
 		Bulan   string  `json:"bulan" binding:"required"` // format YYYY-MM
-		Nominal float64 `json:"nominal"`
+		Nominal float64 `json:"nominal"`                  // kosong = dihitung per santri dari tarif
 		Status  string  `json:"status"`
 	}
 
@@ -590,11 +902,6 @@ func (ctrl *SyahriahController) BatchCreateSyahriah(c *gin.Context) {
 		return
 	}
 
-	// Set default nominal jika tidak diisi
-	if req.Nominal == 0 {
-		req.Nominal = 110000 // default value
-	}
-
 	// Validasi status
 	var status models.StatusSyahriah
 	if req.Status != "" {
@@ -607,88 +914,126 @@ func (ctrl *SyahriahController) BatchCreateSyahriah(c *gin.Context) {
 		status = models.StatusBelum // default
 	}
 
-	// Dapatkan hanya santri dengan status aktif
-	var santriList []models.Santri
-	if err := ctrl.db.Where("status = ?", "aktif").Find(&santriList).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data santri aktif: " + err.Error()})
+	// Santri yang sudah memiliki syahriah di bulan ini dilewati, jadi aman dipanggil berulang
+	hasil, err := services.NewTagihanService(ctrl.db).GenerateBulan(req.Bulan, req.Nominal, status, adminID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTidakAdaSantriAktif), errors.Is(err, services.ErrTarifBelumDiatur):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPeriodeDitutup):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat data syahriah batch: " + err.Error()})
+		}
 		return
 	}
 
-	if len(santriList) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak ada data santri aktif yang tersedia"})
+	if hasil.Dibuat == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Semua santri aktif sudah memiliki data syahriah untuk bulan ini",
+			"data": gin.H{
+				"created":      0,
+				"total_santri_aktif": hasil.TotalSantriAktif,
+			},
+		})
 		return
 	}
 
-	// Dapatkan santri aktif yang sudah memiliki syahriah di bulan ini
-	var existingSantri []string
-	if err := ctrl.db.Model(&models.Syahriah{}).
-		Joins("JOIN santri ON syahriah.id_santri = santri.id_santri").
-		Where("syahriah.bulan = ? AND santri.status = ?", req.Bulan, "aktif").
-		Pluck("syahriah.id_santri", &existingSantri).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa data syahriah yang sudah ada: " + err.Error()})
-		return
+	c.JSON(http.StatusCreated, gin.H{
+		"message": fmt.Sprintf("Berhasil membuat data syahriah untuk %d santri aktif", hasil.Dibuat),
+		"data": gin.H{
+			"created":      hasil.Dibuat,
+			"total_santri_aktif": hasil.TotalSantriAktif,
+			"skipped":      hasil.Dilewati,
+			"bulan":        req.Bulan,
+		},
+	})
+}
+
+// GetLaporanTagihanOtomatis mendapatkan riwayat pembuatan tagihan bulanan oleh penjadwal (hanya admin)
+func (ctrl *SyahriahController) GetLaporanTagihanOtomatis(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
 	}
 
-	// Buat map untuk pengecekan cepat
-	existingMap := make(map[string]bool)
-	for _, id := range existingSantri {
-		existingMap[id] = true
+	query := ctrl.db.Model(&models.LaporanTagihanOtomatis{})
+	if bulan := c.Query("bulan"); bulan != "" {
+		query = query.Where("bulan = ?", bulan)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
 	}
 
-	var syahriahList []models.Syahriah
-	var createdCount int
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
 
-	for _, santri := range santriList {
-		// Skip jika santri sudah memiliki data syahriah untuk bulan ini
-		if existingMap[santri.IDSantri] {
-			continue
-		}
+	var laporan []models.LaporanTagihanOtomatis
+	if err := query.Order("waktu_mulai DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&laporan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil laporan tagihan otomatis: " + err.Error()})
+		return
+	}
 
-		syahriah := models.Syahriah{
-			IDSyahriah:  uuid.New().String(),
-			ID_Santri:    santri.IDSantri,
-			Bulan:       req.Bulan,
-			Nominal:     req.Nominal,
-			Status:      status,
-			DicatatOleh: adminID,
-			WaktuCatat:  time.Now(),
-		}
-		syahriahList = append(syahriahList, syahriah)
-		createdCount++
+	penjadwal := services.NewPenjadwalTagihan(ctrl.db)
+	bulanTertunda, err := penjadwal.BulanTertunda(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung bulan tertunda: " + err.Error()})
+		return
 	}
 
-	if len(syahriahList) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Semua santri aktif sudah memiliki data syahriah untuk bulan ini",
-			"data": gin.H{
-				"created":      0,
-				"total_santri_aktif": len(santriList),
-			},
-		})
+	c.JSON(http.StatusOK, gin.H{
+		"data": laporan,
+		"penjadwal": gin.H{
+			"tanggal":        penjadwal.Tanggal(),
+			"bulan_tertunda": bulanTertunda,
+		},
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// JalankanTagihanOtomatis menjalankan penjadwal tagihan sekarang untuk bulan yang tertunda (hanya admin)
+func (ctrl *SyahriahController) JalankanTagihanOtomatis(c *gin.Context) {
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
 		return
 	}
 
-	// Simpan ke database dalam batch
-	if err := ctrl.db.CreateInBatches(&syahriahList, 100).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat data syahriah batch: " + err.Error()})
+	laporan, err := services.NewPenjadwalTagihan(ctrl.db).Jalankan(models.PemicuManual, adminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+			"data":  laporan,
+		})
 		return
 	}
 
-	// Update rekap untuk bulan ini
-	rekapController := NewRekapController(ctrl.db)
-	bulanTime, _ := time.Parse("2006-01", req.Bulan)
-	if err := rekapController.UpdateRekapOtomatis(bulanTime); err != nil {
-		fmt.Printf("Gagal update rekap: %v\n", err)
+	if len(laporan) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Tidak ada bulan yang tertunda",
+			"data":    laporan,
+		})
+		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": fmt.Sprintf("Berhasil membuat data syahriah untuk %d santri aktif", createdCount),
-		"data": gin.H{
-			"created":      createdCount,
-			"total_santri_aktif": len(santriList),
-			"skipped":      len(santriList) - createdCount,
-			"bulan":        req.Bulan,
-		},
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Tagihan %d bulan berhasil diproses", len(laporan)),
+		"data":    laporan,
 	})
 }
 
@@ -803,8 +1148,10 @@ func (ctrl *SyahriahController) GetSyahriahSummaryForWali(c *gin.Context) {
             "data": gin.H{
                 "total":         0,
                 "lunas":         0,
+                "sebagian":      0,
                 "belum_lunas":   0,
                 "total_nominal": 0,
+                "total_sisa":    0,
             },
         })
         return
@@ -819,42 +1166,13 @@ func (ctrl *SyahriahController) GetSyahriahSummaryForWali(c *gin.Context) {
     // Build query
     query := ctrl.db.Model(&models.Syahriah{}).Where("id_santri IN ?", santriIDs)
 
-    // Hitung total
-    var total int64
-    if err := query.Count(&total).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total syahriah: " + err.Error()})
-        return
-    }
-
-    // Hitung lunas
-    var lunas int64
-    if err := query.Where("status = ?", models.StatusLunas).Count(&lunas).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung syahriah lunas: " + err.Error()})
-        return
-    }
-
-    // Hitung belum lunas
-    var belum int64
-    if err := query.Where("status = ?", models.StatusBelum).Count(&belum).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung syahriah belum lunas: " + err.Error()})
-        return
-    }
-
-    // Total nominal yang sudah dibayar (hanya yang status lunas)
-    var totalNominal float64
-    if err := query.Where("status = ?", models.StatusLunas).
-        Select("COALESCE(SUM(nominal), 0)").
-        Scan(&totalNominal).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total nominal: " + err.Error()})
+    ringkasan, err := ctrl.ringkasanSyahriah(query)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung summary syahriah: " + err.Error()})
         return
     }
 
     c.JSON(http.StatusOK, gin.H{
-        "data": gin.H{
-            "total":         total,
-            "lunas":         lunas,
-            "belum_lunas":   belum,
-            "total_nominal": totalNominal,
-        },
+        "data": ringkasan,
     })
 }
\ No newline at end of file
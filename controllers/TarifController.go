@@ -0,0 +1,423 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TarifController struct {
+	db *gorm.DB
+}
+
+func NewTarifController(db *gorm.DB) *TarifController {
+	return &TarifController{db: db}
+}
+
+// Request structs
+type TarifRequest struct {
+	NamaTarif             string  `json:"nama_tarif" binding:"required"`
+	BerlakuMulai          string  `json:"berlaku_mulai" binding:"required"` // format YYYY-MM
+	Program               string  `json:"program"`
+	Kelas                 string  `json:"kelas"`
+	Nominal               float64 `json:"nominal" binding:"required"`
+	PotonganSaudaraPersen float64 `json:"potongan_saudara_persen"`
+	Keterangan            string  `json:"keterangan"`
+}
+
+type KeringananRequest struct {
+	IDSantri      string                 `json:"id_santri" binding:"required"`
+	Jenis         models.JenisKeringanan `json:"jenis" binding:"required"`
+	TipePotongan  models.TipePotongan    `json:"tipe_potongan" binding:"required"`
+	Nilai         float64                `json:"nilai"`
+	BerlakuMulai  string                 `json:"berlaku_mulai" binding:"required"` // format YYYY-MM
+	BerlakuSampai *string                `json:"berlaku_sampai"`                   // format YYYY-MM, opsional
+	Keterangan    string                 `json:"keterangan"`
+}
+
+// Helper function untuk check role admin
+func (ctrl *TarifController) isAdmin(c *gin.Context) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		return false
+	}
+	role := userRole.(string)
+	return role == "admin" || role == "super_admin"
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *TarifController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// validasiTarif memeriksa isi request tarif
+func (ctrl *TarifController) validasiTarif(req TarifRequest) string {
+	if _, err := time.Parse("2006-01", req.BerlakuMulai); err != nil {
+		return "Format berlaku_mulai tidak valid. Gunakan format YYYY-MM"
+	}
+	if req.Nominal <= 0 {
+		return "Nominal harus lebih besar dari 0"
+	}
+	if req.PotonganSaudaraPersen < 0 || req.PotonganSaudaraPersen > 100 {
+		return "Potongan saudara harus di antara 0 dan 100 persen"
+	}
+	return ""
+}
+
+// validasiKeringanan memeriksa isi request keringanan
+func (ctrl *TarifController) validasiKeringanan(req KeringananRequest) string {
+	switch req.Jenis {
+	case models.KeringananSaudara, models.KeringananYatim, models.KeringananDhuafa, models.KeringananBeasiswa:
+	default:
+		return "Jenis keringanan tidak valid. Gunakan 'saudara', 'yatim', 'dhuafa', atau 'beasiswa'"
+	}
+	switch req.TipePotongan {
+	case models.PotonganBebas:
+	case models.PotonganPersen:
+		if req.Nilai <= 0 || req.Nilai > 100 {
+			return "Nilai potongan persen harus di antara 0 dan 100"
+		}
+	case models.PotonganNominal:
+		if req.Nilai <= 0 {
+			return "Nilai potongan nominal harus lebih besar dari 0"
+		}
+	default:
+		return "Tipe potongan tidak valid. Gunakan 'persen', 'nominal', atau 'bebas'"
+	}
+	if _, err := time.Parse("2006-01", req.BerlakuMulai); err != nil {
+		return "Format berlaku_mulai tidak valid. Gunakan format YYYY-MM"
+	}
+	if req.BerlakuSampai != nil && *req.BerlakuSampai != "" {
+		if _, err := time.Parse("2006-01", *req.BerlakuSampai); err != nil {
+			return "Format berlaku_sampai tidak valid. Gunakan format YYYY-MM"
+		}
+		if *req.BerlakuSampai < req.BerlakuMulai {
+			return "berlaku_sampai tidak boleh sebelum berlaku_mulai"
+		}
+	}
+	return ""
+}
+
+// GetAllTarif mendapatkan semua versi tarif syahriah
+func (ctrl *TarifController) GetAllTarif(c *gin.Context) {
+	query := ctrl.db.Model(&models.TarifSyahriah{})
+	if program := c.Query("program"); program != "" {
+		query = query.Where("program = ?", program)
+	}
+	if kelas := c.Query("kelas"); kelas != "" {
+		query = query.Where("kelas = ?", kelas)
+	}
+
+	var tarif []models.TarifSyahriah
+	if err := query.Order("berlaku_mulai DESC, program ASC, kelas ASC").Find(&tarif).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data tarif: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": tarif,
+	})
+}
+
+// CreateTarif membuat versi tarif baru
+func (ctrl *TarifController) CreateTarif(c *gin.Context) {
+	if !ctrl.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya admin yang dapat membuat tarif"})
+		return
+	}
+
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req TarifRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := ctrl.validasiTarif(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tarif := models.TarifSyahriah{
+		IDTarif:               uuid.New().String(),
+		NamaTarif:             req.NamaTarif,
+		BerlakuMulai:          req.BerlakuMulai,
+		Program:               req.Program,
+		Kelas:                 req.Kelas,
+		Nominal:               req.Nominal,
+		PotonganSaudaraPersen: req.PotonganSaudaraPersen,
+		Keterangan:            req.Keterangan,
+		DibuatOleh:            &adminID,
+	}
+
+	if err := ctrl.db.Create(&tarif).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat tarif: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Tarif berhasil dibuat",
+		"data":    tarif,
+	})
+}
+
+// UpdateTarif mengubah versi tarif. Tagihan yang sudah dibuat tidak ikut berubah.
+func (ctrl *TarifController) UpdateTarif(c *gin.Context) {
+	if !ctrl.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya admin yang dapat mengubah tarif"})
+		return
+	}
+
+	id := c.Param("id")
+	var tarif models.TarifSyahriah
+	if err := ctrl.db.Where("id_tarif = ?", id).First(&tarif).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tarif tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data tarif: " + err.Error()})
+		return
+	}
+
+	var req TarifRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := ctrl.validasiTarif(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tarif.NamaTarif = req.NamaTarif
+	tarif.BerlakuMulai = req.BerlakuMulai
+	tarif.Program = req.Program
+	tarif.Kelas = req.Kelas
+	tarif.Nominal = req.Nominal
+	tarif.PotonganSaudaraPersen = req.PotonganSaudaraPersen
+	tarif.Keterangan = req.Keterangan
+
+	if err := ctrl.db.Save(&tarif).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengubah tarif: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tarif berhasil diupdate",
+		"data":    tarif,
+	})
+}
+
+// DeleteTarif menghapus versi tarif
+func (ctrl *TarifController) DeleteTarif(c *gin.Context) {
+	if !ctrl.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya admin yang dapat menghapus tarif"})
+		return
+	}
+
+	id := c.Param("id")
+	result := ctrl.db.Where("id_tarif = ?", id).Delete(&models.TarifSyahriah{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus tarif: " + result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tarif tidak ditemukan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tarif berhasil dihapus",
+	})
+}
+
+// GetAllKeringanan mendapatkan keringanan syahriah, bisa difilter per santri
+func (ctrl *TarifController) GetAllKeringanan(c *gin.Context) {
+	query := ctrl.db.Preload("Santri")
+	if idSantri := c.Query("id_santri"); idSantri != "" {
+		query = query.Where("id_santri = ?", idSantri)
+	}
+	if jenis := c.Query("jenis"); jenis != "" {
+		query = query.Where("jenis = ?", jenis)
+	}
+
+	var keringanan []models.KeringananSyahriah
+	if err := query.Order("berlaku_mulai DESC").Find(&keringanan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data keringanan: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": keringanan,
+	})
+}
+
+// CreateKeringanan menambahkan potongan/pembebasan syahriah untuk santri
+func (ctrl *TarifController) CreateKeringanan(c *gin.Context) {
+	if !ctrl.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya admin yang dapat membuat keringanan"})
+		return
+	}
+
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req KeringananRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := ctrl.validasiKeringanan(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var santri models.Santri
+	if err := ctrl.db.Where("id_santri = ?", req.IDSantri).First(&santri).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Santri tidak ditemukan"})
+		return
+	}
+
+	keringanan := models.KeringananSyahriah{
+		IDKeringanan:  uuid.New().String(),
+		IDSantri:      req.IDSantri,
+		Jenis:         req.Jenis,
+		TipePotongan:  req.TipePotongan,
+		Nilai:         req.Nilai,
+		BerlakuMulai:  req.BerlakuMulai,
+		BerlakuSampai: req.BerlakuSampai,
+		Keterangan:    req.Keterangan,
+		DibuatOleh:    adminID,
+	}
+
+	if err := ctrl.db.Create(&keringanan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat keringanan: " + err.Error()})
+		return
+	}
+
+	ctrl.db.Preload("Santri").First(&keringanan, "id_keringanan = ?", keringanan.IDKeringanan)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Keringanan berhasil dibuat",
+		"data":    keringanan,
+	})
+}
+
+// UpdateKeringanan mengubah keringanan santri
+func (ctrl *TarifController) UpdateKeringanan(c *gin.Context) {
+	if !ctrl.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya admin yang dapat mengubah keringanan"})
+		return
+	}
+
+	id := c.Param("id")
+	var keringanan models.KeringananSyahriah
+	if err := ctrl.db.Where("id_keringanan = ?", id).First(&keringanan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Keringanan tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data keringanan: " + err.Error()})
+		return
+	}
+
+	var req KeringananRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := ctrl.validasiKeringanan(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	keringanan.Jenis = req.Jenis
+	keringanan.TipePotongan = req.TipePotongan
+	keringanan.Nilai = req.Nilai
+	keringanan.BerlakuMulai = req.BerlakuMulai
+	keringanan.BerlakuSampai = req.BerlakuSampai
+	keringanan.Keterangan = req.Keterangan
+
+	if err := ctrl.db.Save(&keringanan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengubah keringanan: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Keringanan berhasil diupdate",
+		"data":    keringanan,
+	})
+}
+
+// DeleteKeringanan menghapus keringanan santri
+func (ctrl *TarifController) DeleteKeringanan(c *gin.Context) {
+	if !ctrl.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya admin yang dapat menghapus keringanan"})
+		return
+	}
+
+	id := c.Param("id")
+	result := ctrl.db.Where("id_keringanan = ?", id).Delete(&models.KeringananSyahriah{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus keringanan: " + result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Keringanan tidak ditemukan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Keringanan berhasil dihapus",
+	})
+}
+
+// HitungTarif menampilkan nominal syahriah yang akan dikenakan ke santri pada bulan tertentu
+func (ctrl *TarifController) HitungTarif(c *gin.Context) {
+	idSantri := c.Query("id_santri")
+	bulan := c.DefaultQuery("bulan", time.Now().Format("2006-01"))
+	if idSantri == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id_santri diperlukan"})
+		return
+	}
+
+	var santri models.Santri
+	if err := ctrl.db.Where("id_santri = ?", idSantri).First(&santri).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Santri tidak ditemukan"})
+		return
+	}
+
+	tarifService, err := services.NewTarifService(ctrl.db, bulan)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rincian, err := tarifService.Hitung(santri)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  rincian,
+		"bulan": bulan,
+	})
+}
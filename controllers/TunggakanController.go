@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"time"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TunggakanController struct {
+	db *gorm.DB
+}
+
+func NewTunggakanController(db *gorm.DB) *TunggakanController {
+	return &TunggakanController{db: db}
+}
+
+// GetLaporanTunggakan mendapatkan umur tunggakan syahriah per keluarga (wali).
+// Query: acuan (YYYY-MM), kelompok (1_bulan, 2_3_bulan, lebih_3_bulan), search, format=csv
+func (ctrl *TunggakanController) GetLaporanTunggakan(c *gin.Context) {
+	kelompok := c.Query("kelompok")
+	if kelompok != "" && kelompok != services.Umur1Bulan && kelompok != services.Umur2Sampai3 && kelompok != services.UmurLebih3Bulan {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kelompok tidak valid. Gunakan '1_bulan', '2_3_bulan' atau 'lebih_3_bulan'"})
+		return
+	}
+
+	laporan, ringkasan, err := services.NewTunggakanService(ctrl.db).LaporanUmur(services.FilterTunggakan{
+		Acuan:    c.Query("acuan"),
+		Kelompok: kelompok,
+		Search:   c.Query("search"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil laporan tunggakan: " + err.Error()})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		ctrl.tulisCSV(c, laporan, ringkasan)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      laporan,
+		"ringkasan": ringkasan,
+	})
+}
+
+// tulisCSV mengirim laporan tunggakan sebagai file CSV, satu baris per keluarga
+func (ctrl *TunggakanController) tulisCSV(c *gin.Context, laporan []services.TunggakanWali, ringkasan services.RingkasanTunggakan) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=tunggakan-%s.csv", ringkasan.Acuan))
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"Nama Wali", "No. Telp", "Alamat", "Jumlah Tagihan", "1 Bulan", "2-3 Bulan", "> 3 Bulan", "Total Tunggakan", "Bulan Tertua", "Pembayaran Terakhir"})
+	for _, wali := range laporan {
+		pembayaranTerakhir := "-"
+		if wali.PembayaranTerakhir != nil {
+			pembayaranTerakhir = wali.PembayaranTerakhir.Format("2006-01-02")
+		}
+		w.Write([]string{
+			wali.NamaWali,
+			wali.NoTelp,
+			wali.Alamat,
+			fmt.Sprint(wali.JumlahTagihan),
+			fmt.Sprintf("%.0f", wali.Umur1Bulan),
+			fmt.Sprintf("%.0f", wali.Umur2Sampai3Bulan),
+			fmt.Sprintf("%.0f", wali.UmurLebih3Bulan),
+			fmt.Sprintf("%.0f", wali.TotalTunggakan),
+			wali.BulanTertua,
+			pembayaranTerakhir,
+		})
+	}
+	w.Write([]string{
+		"TOTAL", "", "",
+		fmt.Sprint(ringkasan.JumlahTagihan),
+		fmt.Sprintf("%.0f", ringkasan.Umur1Bulan),
+		fmt.Sprintf("%.0f", ringkasan.Umur2Sampai3Bulan),
+		fmt.Sprintf("%.0f", ringkasan.UmurLebih3Bulan),
+		fmt.Sprintf("%.0f", ringkasan.TotalTunggakan),
+		"", "Dibuat " + time.Now().Format("2006-01-02 15:04"),
+	})
+	w.Flush()
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TutupBukuController struct {
+	db *gorm.DB
+}
+
+func NewTutupBukuController(db *gorm.DB) *TutupBukuController {
+	return &TutupBukuController{db: db}
+}
+
+// Request structs
+type TutupBukuRequest struct {
+	Periode string `json:"periode" binding:"required"` // format YYYY-MM
+	Catatan string `json:"catatan"`
+}
+
+type BukaBukuRequest struct {
+	Alasan string `json:"alasan" binding:"required"`
+}
+
+// Helper function untuk check role admin
+func (ctrl *TutupBukuController) isAdmin(c *gin.Context) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		return false
+	}
+	role := userRole.(string)
+	return role == "admin" || role == "super_admin"
+}
+
+// Helper function untuk check role super admin
+func (ctrl *TutupBukuController) isSuperAdmin(c *gin.Context) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		return false
+	}
+	return userRole.(string) == "super_admin"
+}
+
+// Helper function untuk get user ID dari context
+func (ctrl *TutupBukuController) getUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", false
+	}
+	return userID.(string), true
+}
+
+// GetAllTutupBuku mendapatkan daftar periode yang pernah tutup buku
+func (ctrl *TutupBukuController) GetAllTutupBuku(c *gin.Context) {
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "12"))
+	status := c.Query("status")
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 12
+	}
+
+	var tutupBuku []models.TutupBuku
+	var total int64
+
+	// Build query
+	query := ctrl.db.Model(&models.TutupBuku{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// Hitung total records
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung total data: " + err.Error()})
+		return
+	}
+
+	// Apply pagination
+	offset := (page - 1) * limit
+	err := query.Preload("PenutupBuku").Preload("PembukaBuku").
+		Order("periode DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&tutupBuku).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data tutup buku: " + err.Error()})
+		return
+	}
+
+	tutupTerakhir, err := services.NewTutupBukuService(ctrl.db).PeriodeTutupTerakhir()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil periode tutup buku terakhir: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":                    tutupBuku,
+		"terkunci_sampai_periode": tutupTerakhir,
+		"meta": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"total_page": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// TutupBuku mengunci periode sehingga transaksi di periode tersebut tidak bisa diubah
+func (ctrl *TutupBukuController) TutupBuku(c *gin.Context) {
+	if !ctrl.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya admin yang dapat tutup buku"})
+		return
+	}
+
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	var req TutupBukuRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tutupBuku, err := services.NewTutupBukuService(ctrl.db).Tutup(req.Periode, adminID, req.Catatan)
+	if err != nil {
+		if errors.Is(err, services.ErrPeriodeDitutup) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal tutup buku: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tutup buku periode " + tutupBuku.Periode + " berhasil",
+		"data":    tutupBuku,
+	})
+}
+
+// BukaBuku membuka kembali periode yang sudah ditutup (hanya super admin, alasan wajib)
+func (ctrl *TutupBukuController) BukaBuku(c *gin.Context) {
+	if !ctrl.isSuperAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: hanya super admin yang dapat membuka kembali periode"})
+		return
+	}
+
+	adminID, exists := ctrl.getUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user ID tidak ditemukan"})
+		return
+	}
+
+	periode := c.Param("periode")
+	if periode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Periode diperlukan"})
+		return
+	}
+
+	var req BukaBukuRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tutupBuku, err := services.NewTutupBukuService(ctrl.db).Buka(periode, adminID, req.Alasan)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal membuka periode: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Periode " + tutupBuku.Periode + " berhasil dibuka kembali",
+		"data":    tutupBuku,
+	})
+}
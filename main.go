@@ -14,6 +14,7 @@ import (
 
 	"tpq_asysyafii/config"
 	"tpq_asysyafii/routes"
+	"tpq_asysyafii/services"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -118,6 +119,20 @@ func main() {
 	// ✅ REGISTER ROUTES - bahkan jika DB gagal
 	routes.SetupRoutes(r)
 
+	// Penjadwal tagihan syahriah bulanan, dihentikan saat server shutdown
+	ctxPenjadwal, stopPenjadwal := context.WithCancel(context.Background())
+	defer stopPenjadwal()
+	if db := config.GetDB(); db != nil {
+		// Migrasi berjalan di goroutine sendiri; penjadwal baru mulai setelah skema dan backfill siap
+		go func() {
+			select {
+			case <-config.MigrasiSelesai():
+				services.NewPenjadwalTagihan(db).Mulai(ctxPenjadwal)
+			case <-ctxPenjadwal.Done():
+			}
+		}()
+	}
+
 	// Port setup
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -151,6 +166,7 @@ func main() {
 	<-quit
 	
 	log.Println("🛑 Shutting down server gracefully...")
+	stopPenjadwal()
 	
 	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelShutdown()
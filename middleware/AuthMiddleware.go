@@ -79,4 +79,16 @@ func AdminOrSuperAdminMiddleware() gin.HandlerFunc {
         }
         c.Next()
     }
-}
\ No newline at end of file
+}
+// Middleware hanya untuk ustadz/ustadzah
+func UstadzMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists || role != "ustadz" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Hanya ustadz/ustadzah yang bisa mengakses"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+type StatusAbsensi string
+
+const (
+	AbsensiHadir StatusAbsensi = "hadir"
+	AbsensiIzin  StatusAbsensi = "izin"
+	AbsensiSakit StatusAbsensi = "sakit"
+	AbsensiAlpa  StatusAbsensi = "alpa"
+)
+
+// SesiAbsensi adalah satu pertemuan kelas pada satu tanggal
+type SesiAbsensi struct {
+	IDSesi         string    `json:"id_sesi" gorm:"type:char(36);primaryKey"`
+	IDKelas        string    `json:"id_kelas" gorm:"type:char(36);not null;uniqueIndex:idx_sesi_kelas_tanggal"`
+	Tanggal        time.Time `json:"tanggal" gorm:"type:date;not null;uniqueIndex:idx_sesi_kelas_tanggal"`
+	Catatan        string    `json:"catatan" gorm:"type:text"` // materi atau catatan pertemuan
+	DicatatOleh    string    `json:"dicatat_oleh" gorm:"type:char(36);not null"`
+	WaktuCatat     time.Time `json:"waktu_catat" gorm:"autoCreateTime"`
+	DiperbaruiPada time.Time `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+
+	Kelas   *Kelas    `json:"kelas,omitempty" gorm:"foreignKey:IDKelas;references:IDKelas"`
+	Absensi []Absensi `json:"absensi,omitempty" gorm:"foreignKey:IDSesi;references:IDSesi"`
+}
+
+func (SesiAbsensi) TableName() string {
+	return "sesi_absensi"
+}
+
+// Absensi adalah kehadiran satu santri pada satu sesi
+type Absensi struct {
+	IDAbsensi      string        `json:"id_absensi" gorm:"type:char(36);primaryKey"`
+	IDSesi         string        `json:"id_sesi" gorm:"type:char(36);not null;uniqueIndex:idx_absensi_sesi_santri"`
+	IDSantri       string        `json:"id_santri" gorm:"type:char(36);not null;uniqueIndex:idx_absensi_sesi_santri;index"`
+	Status         StatusAbsensi `json:"status" gorm:"type:enum('hadir','izin','sakit','alpa');not null"`
+	Keterangan     string        `json:"keterangan" gorm:"type:varchar(255)"`
+	DicatatOleh    string        `json:"dicatat_oleh" gorm:"type:char(36);not null"`
+	WaktuCatat     time.Time     `json:"waktu_catat" gorm:"autoCreateTime"`
+	DiperbaruiPada time.Time     `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+
+	Sesi   *SesiAbsensi `json:"sesi,omitempty" gorm:"foreignKey:IDSesi;references:IDSesi"`
+	Santri *Santri      `json:"santri,omitempty" gorm:"foreignKey:IDSantri;references:IDSantri"`
+}
+
+func (Absensi) TableName() string {
+	return "absensi"
+}
+
+// KoreksiAbsensi mencatat setiap perubahan absensi yang sudah tersimpan
+type KoreksiAbsensi struct {
+	IDKoreksi      string        `json:"id_koreksi" gorm:"type:char(36);primaryKey"`
+	IDAbsensi      string        `json:"id_absensi" gorm:"type:char(36);not null;index"`
+	StatusLama     StatusAbsensi `json:"status_lama" gorm:"type:enum('hadir','izin','sakit','alpa');not null"`
+	StatusBaru     StatusAbsensi `json:"status_baru" gorm:"type:enum('hadir','izin','sakit','alpa');not null"`
+	KeteranganLama string        `json:"keterangan_lama" gorm:"type:varchar(255)"`
+	KeteranganBaru string        `json:"keterangan_baru" gorm:"type:varchar(255)"`
+	Alasan         string        `json:"alasan" gorm:"type:text"`
+	DikoreksiOleh  string        `json:"dikoreksi_oleh" gorm:"type:char(36);not null"`
+	WaktuKoreksi   time.Time     `json:"waktu_koreksi" gorm:"autoCreateTime"`
+
+	Pengoreksi *User `json:"pengoreksi,omitempty" gorm:"foreignKey:DikoreksiOleh;references:IDUser"`
+}
+
+func (KoreksiAbsensi) TableName() string {
+	return "koreksi_absensi"
+}
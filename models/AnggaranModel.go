@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Anggaran adalah satu pos RAPB (Rencana Anggaran Pendapatan dan Belanja) untuk kategori
+// TipePemakaian dan sub-kategori tertentu, dianggarkan per bulan atau untuk setahun penuh.
+// Pemakaian saldo ditautkan ke pos anggaran agar realisasinya bisa dibandingkan.
+type Anggaran struct {
+	IDAnggaran     string        `json:"id_anggaran" gorm:"type:char(36);primaryKey"`
+	Tahun          int           `json:"tahun" gorm:"not null;uniqueIndex:idx_pos_anggaran,priority:1"`
+	Bulan          int           `json:"bulan" gorm:"not null;default:0;uniqueIndex:idx_pos_anggaran,priority:2"` // 1-12, 0 = anggaran tahunan
+	TipePemakaian  TipePemakaian `json:"tipe_pemakaian" gorm:"type:enum('operasional','investasi','lainnya');not null;uniqueIndex:idx_pos_anggaran,priority:3"`
+	SubKategori    string        `json:"sub_kategori" gorm:"type:varchar(100);not null;uniqueIndex:idx_pos_anggaran,priority:4"` // misalnya "Listrik & Air", "Honor Ustadz"
+	Nominal        float64       `json:"nominal" gorm:"type:decimal(14,2);not null"`
+	Keterangan     string        `json:"keterangan" gorm:"type:text"`
+	DibuatOleh     *string       `json:"dibuat_oleh" gorm:"type:char(36);null"`
+	DibuatPada     time.Time     `json:"dibuat_pada" gorm:"autoCreateTime"`
+	DiperbaruiPada time.Time     `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+}
+
+func (Anggaran) TableName() string {
+	return "anggaran"
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Kode dana bawaan yang dipakai posting otomatis syahriah dan donasi
+const (
+	KodeDanaSyahriah = "syahriah"
+	KodeDanaDonasi   = "donasi"
+)
+
+// Dana adalah sumber dana yang pembukuannya dipisahkan, misalnya syahriah, donasi umum, infaq Jumat,
+// zakat, wakaf atau hibah BOP. Setiap dana punya akun kas dan akun pendapatan sendiri di jurnal,
+// sehingga pemasukan, pengeluaran dan saldonya bisa dilaporkan terpisah.
+type Dana struct {
+	KodeDana           string    `json:"kode_dana" gorm:"type:varchar(30);primaryKey"`
+	NamaDana           string    `json:"nama_dana" gorm:"type:varchar(100);not null"`
+	KodeAkunKas        string    `json:"kode_akun_kas" gorm:"type:varchar(50);not null"`
+	KodeAkunPendapatan string    `json:"kode_akun_pendapatan" gorm:"type:varchar(50);not null"`
+	PemakaianDiizinkan string    `json:"pemakaian_diizinkan" gorm:"type:varchar(100)"` // TipePemakaian dipisah koma, kosong = semua tipe
+	Keterangan         string    `json:"keterangan" gorm:"type:text"`
+	Aktif              bool      `json:"aktif" gorm:"default:true"`
+	Urutan             int       `json:"urutan" gorm:"default:0"`
+	DibuatPada         time.Time `json:"dibuat_pada" gorm:"autoCreateTime"`
+	DiperbaruiPada     time.Time `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+}
+
+func (Dana) TableName() string {
+	return "dana"
+}
+
+// DaftarDanaDefault di-seed saat migrasi. Syahriah dan donasi memakai akun kas yang sudah ada.
+var DaftarDanaDefault = []Dana{
+	{KodeDana: KodeDanaSyahriah, NamaDana: "Syahriah", KodeAkunKas: KodeKasSyahriah, KodeAkunPendapatan: KodePendapatanSyahriah, Aktif: true, Urutan: 1},
+	{KodeDana: KodeDanaDonasi, NamaDana: "Donasi", KodeAkunKas: KodeKasDonasi, KodeAkunPendapatan: KodePendapatanDonasi, Aktif: true, Urutan: 2},
+	{KodeDana: "infaq_jumat", NamaDana: "Infaq Jumat", KodeAkunKas: "kas_infaq_jumat", KodeAkunPendapatan: "pendapatan_infaq_jumat", Aktif: true, Urutan: 3},
+	{KodeDana: "zakat", NamaDana: "Zakat", KodeAkunKas: "kas_zakat", KodeAkunPendapatan: "pendapatan_zakat", PemakaianDiizinkan: "lainnya", Keterangan: "Hanya untuk penyaluran kepada mustahik", Aktif: true, Urutan: 4},
+	{KodeDana: "wakaf", NamaDana: "Wakaf", KodeAkunKas: "kas_wakaf", KodeAkunPendapatan: "pendapatan_wakaf", PemakaianDiizinkan: "investasi", Keterangan: "Hanya untuk aset wakaf", Aktif: true, Urutan: 5},
+	{KodeDana: "bop", NamaDana: "Hibah BOP", KodeAkunKas: "kas_bop", KodeAkunPendapatan: "pendapatan_bop", PemakaianDiizinkan: "operasional,investasi", Keterangan: "Bantuan Operasional Pendidikan dari pemerintah", Aktif: true, Urutan: 6},
+}
+
+// PemakaianDana adalah porsi satu pemakaian saldo yang diambil dari satu dana
+type PemakaianDana struct {
+	IDPemakaian string  `json:"id_pemakaian" gorm:"type:char(36);primaryKey"`
+	KodeDana    string  `json:"kode_dana" gorm:"type:varchar(30);primaryKey"`
+	Nominal     float64 `json:"nominal" gorm:"type:decimal(14,2);not null"`
+
+	Dana *Dana `json:"dana,omitempty" gorm:"foreignKey:KodeDana;references:KodeDana"`
+}
+
+func (PemakaianDana) TableName() string {
+	return "pemakaian_dana"
+}
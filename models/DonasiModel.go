@@ -7,10 +7,18 @@ type Donasi struct {
 	NamaDonatur string    `json:"nama_donatur" gorm:"type:varchar(100)"`
 	NoTelp      string    `json:"no_telp"`
 	Nominal     float64   `json:"nominal" gorm:"type:decimal(12,2);not null;check:nominal > 0"`
+	IDDonatur   *string   `json:"id_donatur" gorm:"type:char(36);null;index"`
+	IDKampanye  *string   `json:"id_kampanye" gorm:"type:char(36);null;index"` // donasi terikat untuk kampanye ini
+	Anonim      bool      `json:"anonim" gorm:"default:false"`
+	KodeDana    string    `json:"kode_dana" gorm:"type:varchar(30);not null;default:'donasi';index"` // dana penerima, misalnya infaq_jumat, zakat atau wakaf
+	IDRekening  *string   `json:"id_rekening" gorm:"type:char(36);null;index"`                       // rekening penerima uang, kosong = rekening utama
 	DicatatOleh string    `json:"dicatat_oleh" gorm:"type:char(36);not null"`
 	WaktuCatat  time.Time `json:"waktu_catat" gorm:"autoCreateTime"`
 
-	Admin User `json:"admin" gorm:"foreignKey:DicatatOleh;references:IDUser"`
+	Admin    User      `json:"admin" gorm:"foreignKey:DicatatOleh;references:IDUser"`
+	Donatur  *Donatur  `json:"donatur,omitempty" gorm:"foreignKey:IDDonatur;references:IDDonatur"`
+	Kampanye *Kampanye `json:"kampanye,omitempty" gorm:"foreignKey:IDKampanye;references:IDKampanye"`
+	Rekening *Rekening `json:"rekening,omitempty" gorm:"foreignKey:IDRekening;references:IDRekening"`
 }
 
 func (Donasi) TableName() string {
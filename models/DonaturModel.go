@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Donatur adalah orang atau lembaga yang berdonasi. Donasi ditautkan ke donatur agar riwayat
+// donasi rutin seseorang terlihat, walaupun nama yang ditulis di tiap donasi berbeda-beda.
+type Donatur struct {
+	IDDonatur      string    `json:"id_donatur" gorm:"type:char(36);primaryKey"`
+	Nama           string    `json:"nama" gorm:"type:varchar(100);not null"`
+	NoTelp         string    `json:"no_telp" gorm:"type:varchar(20);index"`
+	Email          string    `json:"email" gorm:"type:varchar(100)"`
+	Alamat         string    `json:"alamat" gorm:"type:text"`
+	Anonim         bool      `json:"anonim" gorm:"default:false"` // donasinya tampil sebagai "Hamba Allah" di halaman publik
+	Catatan        string    `json:"catatan" gorm:"type:text"`
+	DibuatPada     time.Time `json:"dibuat_pada" gorm:"autoCreateTime"`
+	DiperbaruiPada time.Time `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+}
+
+func (Donatur) TableName() string {
+	return "donatur"
+}
@@ -6,6 +6,7 @@ type InformasiTPQ struct {
 	IDTPQ           string     `json:"id_tpq" gorm:"column:id_tpq;primaryKey;type:char(36)"`
 	NamaTPQ         string     `json:"nama_tpq" gorm:"type:varchar(200);not null"`
 	Tempat          *string    `json:"tempat,omitempty" gorm:"type:varchar(200)"`
+	NamaKepala      *string    `json:"nama_kepala,omitempty" gorm:"type:varchar(150)"` // kepala TPQ, untuk tanda tangan raport
 	Logo            *string    `json:"logo,omitempty" gorm:"type:varchar(255)"`
 	Visi            *string    `json:"visi,omitempty" gorm:"type:text"`
 	Misi            *string    `json:"misi,omitempty" gorm:"type:text"`
@@ -15,6 +16,7 @@ type InformasiTPQ struct {
 	Alamat          *string    `json:"alamat,omitempty" gorm:"type:text"`
 	LinkAlamat      *string    `json:"link_alamat,omitempty" gorm:"type:varchar(500)"`
 	HariJamBelajar  *string    `json:"hari_jam_belajar,omitempty" gorm:"type:text"`
+	QRISStatis      *string    `json:"qris_statis,omitempty" gorm:"type:text"` // payload QRIS statis merchant, dasar QRIS dinamis
 	DiupdateOlehID  *string    `json:"diupdate_oleh_id,omitempty" gorm:"column:diupdate_oleh_id;type:char(36)"`
 	DibuatPada      time.Time  `json:"dibuat_pada" gorm:"autoCreateTime"`
 	DiperbaruiPada  time.Time  `json:"diperbarui_pada" gorm:"autoUpdateTime"`
@@ -0,0 +1,97 @@
+package models
+
+import "time"
+
+type TipeAkun string
+
+const (
+	AkunAset       TipeAkun = "aset"
+	AkunPendapatan TipeAkun = "pendapatan"
+	AkunBeban      TipeAkun = "beban"
+	AkunEkuitas    TipeAkun = "ekuitas"
+)
+
+// Kode akun standar yang dipakai oleh posting otomatis
+const (
+	KodeKasSyahriah        = "kas_syahriah"
+	KodeKasDonasi          = "kas_donasi"
+	KodePendapatanSyahriah = "pendapatan_syahriah"
+	KodePendapatanDonasi   = "pendapatan_donasi"
+	KodeBebanOperasional   = "beban_operasional"
+	KodeBebanInvestasi     = "beban_investasi"
+	KodeBebanLainnya       = "beban_lainnya"
+	KodeSaldoAwal          = "saldo_awal"
+	// KodePindahBuku menampung transfer antar rekening. Debit dan kreditnya selalu sama besar
+	// sehingga saldonya nol dan saldo dana tidak berubah.
+	KodePindahBuku = "pindah_buku"
+)
+
+type JenisJurnal string
+
+const (
+	JurnalPemasukan   JenisJurnal = "pemasukan"
+	JurnalPengeluaran JenisJurnal = "pengeluaran"
+	JurnalSaldoAwal   JenisJurnal = "saldo_awal"
+	JurnalTransfer    JenisJurnal = "transfer" // pindah buku antar rekening, bukan pemasukan maupun pengeluaran
+)
+
+type Akun struct {
+	KodeAkun   string    `json:"kode_akun" gorm:"type:varchar(50);primaryKey"`
+	NamaAkun   string    `json:"nama_akun" gorm:"type:varchar(100);not null"`
+	Tipe       TipeAkun  `json:"tipe" gorm:"type:enum('aset','pendapatan','beban','ekuitas');not null"`
+	DibuatPada time.Time `json:"dibuat_pada" gorm:"autoCreateTime"`
+}
+
+func (Akun) TableName() string {
+	return "akun"
+}
+
+// DaftarAkunDefault di-seed saat migrasi
+var DaftarAkunDefault = []Akun{
+	{KodeAkun: KodeKasSyahriah, NamaAkun: "Kas Syahriah", Tipe: AkunAset},
+	{KodeAkun: KodeKasDonasi, NamaAkun: "Kas Donasi", Tipe: AkunAset},
+	{KodeAkun: KodePendapatanSyahriah, NamaAkun: "Pendapatan Syahriah", Tipe: AkunPendapatan},
+	{KodeAkun: KodePendapatanDonasi, NamaAkun: "Pendapatan Donasi", Tipe: AkunPendapatan},
+	{KodeAkun: KodeBebanOperasional, NamaAkun: "Beban Operasional", Tipe: AkunBeban},
+	{KodeAkun: KodeBebanInvestasi, NamaAkun: "Beban Investasi", Tipe: AkunBeban},
+	{KodeAkun: KodeBebanLainnya, NamaAkun: "Beban Lainnya", Tipe: AkunBeban},
+	{KodeAkun: KodeSaldoAwal, NamaAkun: "Saldo Awal", Tipe: AkunEkuitas},
+	{KodeAkun: KodePindahBuku, NamaAkun: "Pindah Buku Antar Rekening", Tipe: AkunAset},
+}
+
+// Jurnal bersifat append-only: koreksi dilakukan dengan jurnal pembalik (IDJurnalAsal)
+type Jurnal struct {
+	IDJurnal     string      `json:"id_jurnal" gorm:"type:char(36);primaryKey"`
+	Periode      string      `json:"periode" gorm:"type:varchar(7);not null;index"` // format YYYY-MM
+	Tanggal      time.Time   `json:"tanggal" gorm:"not null"`
+	Jenis        JenisJurnal `json:"jenis" gorm:"type:enum('pemasukan','pengeluaran','saldo_awal','transfer');not null"`
+	TipeSumber   string      `json:"tipe_sumber" gorm:"type:varchar(50);index:idx_jurnal_sumber"`
+	IDSumber     string      `json:"id_sumber" gorm:"type:char(36);index:idx_jurnal_sumber"`
+	IDJurnalAsal *string     `json:"id_jurnal_asal,omitempty" gorm:"type:char(36);index"`
+	Keterangan   string      `json:"keterangan" gorm:"type:text"`
+	DicatatOleh  string      `json:"dicatat_oleh" gorm:"type:char(36);not null"`
+	WaktuCatat   time.Time   `json:"waktu_catat" gorm:"autoCreateTime"`
+
+	Detail []JurnalDetail `json:"detail" gorm:"foreignKey:IDJurnal;references:IDJurnal"`
+	Admin  User           `json:"admin" gorm:"foreignKey:DicatatOleh;references:IDUser"`
+}
+
+func (Jurnal) TableName() string {
+	return "jurnal"
+}
+
+type JurnalDetail struct {
+	IDDetail string  `json:"id_detail" gorm:"type:char(36);primaryKey"`
+	IDJurnal string  `json:"id_jurnal" gorm:"type:char(36);not null;index"`
+	KodeAkun string  `json:"kode_akun" gorm:"type:varchar(50);not null;index"`
+	Debit    float64 `json:"debit" gorm:"type:decimal(14,2);not null;default:0"`
+	Kredit   float64 `json:"kredit" gorm:"type:decimal(14,2);not null;default:0"`
+	// IDRekening diisi pada baris kas dan pindah buku, menunjukkan rekening tempat uang bergerak
+	IDRekening *string `json:"id_rekening,omitempty" gorm:"type:char(36);null;index"`
+
+	Akun Akun `json:"akun" gorm:"foreignKey:KodeAkun;references:KodeAkun"`
+}
+
+func (JurnalDetail) TableName() string {
+	return "jurnal_detail"
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+type StatusKampanye string
+
+const (
+	KampanyeAktif      StatusKampanye = "aktif"
+	KampanyeSelesai    StatusKampanye = "selesai"
+	KampanyeDibatalkan StatusKampanye = "dibatalkan"
+)
+
+// Kampanye adalah penggalangan donasi untuk keperluan tertentu, misalnya renovasi musholla.
+// Donasi yang masuk ke kampanye terikat: hanya bisa dipakai oleh pemakaian saldo kampanye yang sama.
+type Kampanye struct {
+	IDKampanye     string         `json:"id_kampanye" gorm:"type:char(36);primaryKey"`
+	Judul          string         `json:"judul" gorm:"type:varchar(200);not null"`
+	Slug           string         `json:"slug" gorm:"type:varchar(220);not null;uniqueIndex"`
+	Deskripsi      string         `json:"deskripsi" gorm:"type:text"`
+	Target         float64        `json:"target" gorm:"type:decimal(14,2);not null"`
+	BatasWaktu     *time.Time     `json:"batas_waktu" gorm:"type:date;null"`
+	Status         StatusKampanye `json:"status" gorm:"type:enum('aktif','selesai','dibatalkan');default:'aktif';index"`
+	DibuatOleh     string         `json:"dibuat_oleh" gorm:"type:char(36);not null"`
+	DibuatPada     time.Time      `json:"dibuat_pada" gorm:"autoCreateTime"`
+	DiperbaruiPada time.Time      `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+
+	Pembuat User `json:"pembuat" gorm:"foreignKey:DibuatOleh;references:IDUser"`
+}
+
+func (Kampanye) TableName() string {
+	return "kampanye"
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+type HariJadwal string
+
+const (
+	HariSenin  HariJadwal = "senin"
+	HariSelasa HariJadwal = "selasa"
+	HariRabu   HariJadwal = "rabu"
+	HariKamis  HariJadwal = "kamis"
+	HariJumat  HariJadwal = "jumat"
+	HariSabtu  HariJadwal = "sabtu"
+	HariAhad   HariJadwal = "ahad"
+)
+
+type StatusAnggotaKelas string
+
+const (
+	AnggotaAktif  StatusAnggotaKelas = "aktif"
+	AnggotaNaik   StatusAnggotaKelas = "naik"   // naik ke kelas/tingkat berikutnya
+	AnggotaPindah StatusAnggotaKelas = "pindah" // pindah ke kelas lain pada tahun ajaran yang sama
+	AnggotaKeluar StatusAnggotaKelas = "keluar"
+	AnggotaLulus  StatusAnggotaKelas = "lulus"
+)
+
+// Kelas adalah kelompok belajar (kelas/halaqah) santri pada satu tahun ajaran
+type Kelas struct {
+	IDKelas        string    `json:"id_kelas" gorm:"type:char(36);primaryKey"`
+	NamaKelas      string    `json:"nama_kelas" gorm:"type:varchar(50);not null;uniqueIndex:idx_kelas_tahun_ajaran"`
+	Tingkat        string    `json:"tingkat" gorm:"type:varchar(50)"`                                                 // misalnya Iqro 1, Iqro 2, Al-Qur'an
+	TahunAjaran    string    `json:"tahun_ajaran" gorm:"type:varchar(9);not null;uniqueIndex:idx_kelas_tahun_ajaran"` // format 2025/2026
+	IDWaliKelas    *string   `json:"id_wali_kelas" gorm:"type:char(36);null;index"`                                   // ustadz/ustadzah wali kelas
+	Kapasitas      int       `json:"kapasitas" gorm:"default:0"`                                                      // 0 = tidak dibatasi
+	Keterangan     string    `json:"keterangan" gorm:"type:text"`
+	Aktif          bool      `json:"aktif" gorm:"default:true"`
+	DibuatPada     time.Time `json:"dibuat_pada" gorm:"autoCreateTime"`
+	DiperbaruiPada time.Time `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+
+	WaliKelas *User         `json:"wali_kelas,omitempty" gorm:"foreignKey:IDWaliKelas;references:IDUser"`
+	Jadwal    []JadwalKelas `json:"jadwal,omitempty" gorm:"foreignKey:IDKelas;references:IDKelas"`
+}
+
+func (Kelas) TableName() string {
+	return "kelas"
+}
+
+// JadwalKelas adalah satu sesi mengajar mingguan sebuah kelas
+type JadwalKelas struct {
+	IDJadwal   string     `json:"id_jadwal" gorm:"type:char(36);primaryKey"`
+	IDKelas    string     `json:"id_kelas" gorm:"type:char(36);not null;index"`
+	Hari       HariJadwal `json:"hari" gorm:"type:enum('senin','selasa','rabu','kamis','jumat','sabtu','ahad');not null"`
+	JamMulai   string     `json:"jam_mulai" gorm:"type:varchar(5);not null"`   // format HH:MM
+	JamSelesai string     `json:"jam_selesai" gorm:"type:varchar(5);not null"` // format HH:MM
+	IDPengajar *string    `json:"id_pengajar" gorm:"type:char(36);null;index"` // kosong = diajar wali kelas
+	Materi     string     `json:"materi" gorm:"type:varchar(100)"`
+
+	Pengajar *User `json:"pengajar,omitempty" gorm:"foreignKey:IDPengajar;references:IDUser"`
+}
+
+func (JadwalKelas) TableName() string {
+	return "jadwal_kelas"
+}
+
+// AnggotaKelas mencatat riwayat santri di sebuah kelas. Santri hanya boleh aktif di satu kelas
+// pada satu tahun ajaran; baris lama ditutup dengan tanggal keluar dan statusnya.
+type AnggotaKelas struct {
+	IDAnggota     string             `json:"id_anggota" gorm:"type:char(36);primaryKey"`
+	IDKelas       string             `json:"id_kelas" gorm:"type:char(36);not null;index"`
+	IDSantri      string             `json:"id_santri" gorm:"type:char(36);not null;index"`
+	TahunAjaran   string             `json:"tahun_ajaran" gorm:"type:varchar(9);not null;index"` // salinan dari kelas untuk riwayat
+	TanggalMasuk  time.Time          `json:"tanggal_masuk" gorm:"type:date;not null"`
+	TanggalKeluar *time.Time         `json:"tanggal_keluar,omitempty" gorm:"type:date"`
+	Status        StatusAnggotaKelas `json:"status" gorm:"type:enum('aktif','naik','pindah','keluar','lulus');default:'aktif';index"`
+	Keterangan    string             `json:"keterangan" gorm:"type:text"`
+	DicatatOleh   string             `json:"dicatat_oleh" gorm:"type:char(36);not null"`
+	WaktuCatat    time.Time          `json:"waktu_catat" gorm:"autoCreateTime"`
+
+	Kelas  *Kelas  `json:"kelas,omitempty" gorm:"foreignKey:IDKelas;references:IDKelas"`
+	Santri *Santri `json:"santri,omitempty" gorm:"foreignKey:IDSantri;references:IDSantri"`
+}
+
+func (AnggotaKelas) TableName() string {
+	return "anggota_kelas"
+}
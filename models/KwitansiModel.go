@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type StatusKwitansi string
+
+const (
+	KwitansiBerlaku StatusKwitansi = "berlaku"
+	KwitansiBatal   StatusKwitansi = "batal"
+)
+
+// Kwitansi adalah bukti penerimaan uang bernomor urut per tahun.
+// Isinya disalin saat terbit sehingga kwitansi tetap bisa dicetak walaupun sumbernya dihapus;
+// kwitansi yang sumbernya dihapus ditandai batal dan nomornya tidak dipakai ulang. Jika isi sumbernya
+// diubah, kwitansi lama dibatalkan dan sumber tersebut mendapat kwitansi baru.
+type Kwitansi struct {
+	IDKwitansi   string         `json:"id_kwitansi" gorm:"type:char(36);primaryKey"`
+	Nomor        string         `json:"nomor" gorm:"type:varchar(30);not null;uniqueIndex"`
+	Tahun        int            `json:"tahun" gorm:"not null;uniqueIndex:idx_kwitansi_urutan"`
+	Urutan       int            `json:"urutan" gorm:"not null;uniqueIndex:idx_kwitansi_urutan"`
+	TipeSumber   string         `json:"tipe_sumber" gorm:"type:varchar(30);not null;index:idx_kwitansi_sumber_terbit"`
+	IDSumber     string         `json:"id_sumber" gorm:"type:char(36);not null;index:idx_kwitansi_sumber_terbit"`
+	IDSantri     *string        `json:"id_santri" gorm:"type:char(36);null;index"`
+	IDWali       *string        `json:"id_wali" gorm:"type:char(36);null;index"`
+	NamaPembayar string         `json:"nama_pembayar" gorm:"type:varchar(100)"`
+	Untuk        string         `json:"untuk" gorm:"type:varchar(255)"` // keterangan "untuk pembayaran"
+	Nominal      float64        `json:"nominal" gorm:"type:decimal(12,2);not null"`
+	Metode       string         `json:"metode" gorm:"type:varchar(20)"`
+	Rekening     string         `json:"rekening" gorm:"type:varchar(200)"` // rekening penerima uang
+	TanggalBayar time.Time      `json:"tanggal_bayar"`
+	DiterimaOleh string         `json:"diterima_oleh" gorm:"type:char(36);not null"`
+	Status       StatusKwitansi `json:"status" gorm:"type:enum('berlaku','batal');default:'berlaku'"`
+	WaktuTerbit  time.Time      `json:"waktu_terbit" gorm:"autoCreateTime"`
+	WaktuBatal   *time.Time     `json:"waktu_batal"`
+
+	Penerima User `json:"penerima" gorm:"foreignKey:DiterimaOleh;references:IDUser"`
+}
+
+func (Kwitansi) TableName() string {
+	return "kwitansi"
+}
+
+// NomorKwitansi menyimpan nomor urut terakhir kwitansi per tahun
+type NomorKwitansi struct {
+	Tahun    int `json:"tahun" gorm:"primaryKey;autoIncrement:false"`
+	Terakhir int `json:"terakhir" gorm:"not null;default:0"`
+}
+
+func (NomorKwitansi) TableName() string {
+	return "nomor_kwitansi"
+}
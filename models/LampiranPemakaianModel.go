@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// LampiranPemakaian adalah bukti pengeluaran (foto nota, kuitansi atau PDF) untuk satu pemakaian saldo.
+// Berkasnya disimpan di direktori privat dan hanya bisa diunduh lewat endpoint yang terautentikasi.
+type LampiranPemakaian struct {
+	IDLampiran   string    `json:"id_lampiran" gorm:"type:char(36);primaryKey"`
+	IDPemakaian  string    `json:"id_pemakaian" gorm:"type:char(36);not null;index"`
+	NamaAsli     string    `json:"nama_asli" gorm:"type:varchar(255);not null"`
+	NamaBerkas   string    `json:"-" gorm:"type:varchar(100);not null"` // nama berkas di direktori lampiran
+	Thumbnail    *string   `json:"-" gorm:"type:varchar(100);null"`     // thumbnail tersamar, hanya untuk gambar
+	TipeBerkas   string    `json:"tipe_berkas" gorm:"type:varchar(50);not null"`
+	Ukuran       int64     `json:"ukuran" gorm:"not null"`
+	TampilPublik bool      `json:"tampil_publik" gorm:"default:false"` // thumbnail tersamar boleh ditampilkan di halaman transparansi
+	DiunggahOleh string    `json:"diunggah_oleh" gorm:"type:char(36);not null"`
+	DiunggahPada time.Time `json:"diunggah_pada" gorm:"autoCreateTime"`
+}
+
+func (LampiranPemakaian) TableName() string {
+	return "lampiran_pemakaian"
+}
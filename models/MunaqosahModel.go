@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+type StatusMunaqosah string
+
+const (
+	MunaqosahDijadwalkan StatusMunaqosah = "dijadwalkan"
+	MunaqosahLulus       StatusMunaqosah = "lulus"
+	MunaqosahTidakLulus  StatusMunaqosah = "tidak_lulus"
+	MunaqosahBatal       StatusMunaqosah = "batal"
+)
+
+// Munaqosah adalah ujian kenaikan jilid Iqro. Setiap percobaan disimpan sebagai baris sendiri
+// sehingga riwayat ujian santri yang mengulang tetap utuh. Lulus munaqosah jilid 6 berarti santri
+// siap naik ke Al-Qur'an.
+type Munaqosah struct {
+	IDMunaqosah     string          `json:"id_munaqosah" gorm:"type:char(36);primaryKey"`
+	IDSantri        string          `json:"id_santri" gorm:"type:char(36);not null;index"`
+	Jilid           int             `json:"jilid" gorm:"not null"`     // jilid Iqro yang diujikan
+	Percobaan       int             `json:"percobaan" gorm:"not null"` // ujian ke-n untuk jilid ini
+	JadwalUjian     time.Time       `json:"jadwal_ujian" gorm:"not null;index"`
+	IDPenguji       string          `json:"id_penguji" gorm:"type:char(36);not null;index"`
+	Status          StatusMunaqosah `json:"status" gorm:"type:enum('dijadwalkan','lulus','tidak_lulus','batal');default:'dijadwalkan';index"`
+	NilaiMakhraj    *int            `json:"nilai_makhraj"`
+	NilaiTajwid     *int            `json:"nilai_tajwid"`
+	NilaiKelancaran *int            `json:"nilai_kelancaran"`
+	NilaiAkhir      *float64        `json:"nilai_akhir" gorm:"type:decimal(5,2)"`
+	Catatan         string          `json:"catatan" gorm:"type:text"`
+	TanggalUjian    *time.Time      `json:"tanggal_ujian" gorm:"type:date"` // diisi saat nilai dimasukkan
+	DijadwalkanOleh string          `json:"dijadwalkan_oleh" gorm:"type:char(36);not null"`
+	DinilaiOleh     *string         `json:"dinilai_oleh" gorm:"type:char(36);null"`
+	DibuatPada      time.Time       `json:"dibuat_pada" gorm:"autoCreateTime"`
+	DiperbaruiPada  time.Time       `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+
+	Santri  *Santri `json:"santri,omitempty" gorm:"foreignKey:IDSantri;references:IDSantri"`
+	Penguji *User   `json:"penguji,omitempty" gorm:"foreignKey:IDPenguji;references:IDUser"`
+}
+
+func (Munaqosah) TableName() string {
+	return "munaqosah"
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+type StatusMutasi string
+
+const (
+	MutasiBelumCocok   StatusMutasi = "belum_cocok"  // tidak ada kandidat yang cukup meyakinkan
+	MutasiDiusulkan    StatusMutasi = "diusulkan"    // ada usulan pasangan, menunggu konfirmasi admin
+	MutasiDikonfirmasi StatusMutasi = "dikonfirmasi" // pembayaran/donasi sudah dicatat
+	MutasiDiabaikan    StatusMutasi = "diabaikan"    // bukan penerimaan syahriah maupun donasi
+)
+
+// Jenis pasangan untuk satu mutasi kredit
+const (
+	CocokSyahriah       = "syahriah"        // satu tagihan, IDReferensi = id_syahriah
+	CocokSyahriahSantri = "syahriah_santri" // dibagi ke tagihan santri mulai bulan terlama, IDReferensi = id_santri
+	CocokDonasi         = "donasi"          // donasi yang sudah tercatat, IDReferensi = id_donasi
+	CocokDonasiBaru     = "donasi_baru"     // dicatat sebagai donasi baru saat dikonfirmasi
+	CocokQRIS           = "qris"            // referensi QRIS dinamis ada di keterangan, IDReferensi = id_permintaan
+)
+
+// ImporMutasiBank adalah satu kali unggah file mutasi rekening
+type ImporMutasiBank struct {
+	IDImpor        string    `json:"id_impor" gorm:"type:char(36);primaryKey"`
+	NamaFile       string    `json:"nama_file" gorm:"type:varchar(255)"`
+	Format         string    `json:"format" gorm:"type:varchar(20)"` // bca, mandiri, bri, bni atau umum
+	JumlahBaris    int       `json:"jumlah_baris"`
+	JumlahKredit   int       `json:"jumlah_kredit"`
+	JumlahDuplikat int       `json:"jumlah_duplikat"`
+	JumlahCocok    int       `json:"jumlah_cocok"`
+	IDRekening     *string   `json:"id_rekening" gorm:"type:char(36);null;index"` // rekening bank pemilik mutasi, penerimaan yang dikonfirmasi masuk ke sini
+	DiimporOleh    string    `json:"diimpor_oleh" gorm:"type:char(36);not null"`
+	WaktuImpor     time.Time `json:"waktu_impor" gorm:"autoCreateTime"`
+
+	Admin User `json:"admin" gorm:"foreignKey:DiimporOleh;references:IDUser"`
+}
+
+func (ImporMutasiBank) TableName() string {
+	return "impor_mutasi_bank"
+}
+
+// MutasiBank adalah satu baris kredit (uang masuk) dari file mutasi rekening.
+// SidikJari mencegah baris yang sama tersimpan dua kali saat file dengan periode bertumpuk diunggah ulang.
+type MutasiBank struct {
+	IDMutasi         string       `json:"id_mutasi" gorm:"type:char(36);primaryKey"`
+	IDImpor          string       `json:"id_impor" gorm:"type:char(36);not null;index"`
+	Baris            int          `json:"baris"` // nomor baris di file
+	Tanggal          time.Time    `json:"tanggal" gorm:"type:date;not null;index"`
+	Keterangan       string       `json:"keterangan" gorm:"type:text"`
+	Nominal          float64      `json:"nominal" gorm:"type:decimal(14,2);not null"`
+	SidikJari        string       `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	Status           StatusMutasi `json:"status" gorm:"type:enum('belum_cocok','diusulkan','dikonfirmasi','diabaikan');default:'belum_cocok';index"`
+	TipeCocok        string       `json:"tipe_cocok" gorm:"type:varchar(20)"`
+	IDReferensi      *string      `json:"id_referensi" gorm:"type:char(36);null;index"`
+	Skor             int          `json:"skor"`
+	AlasanCocok      string       `json:"alasan_cocok" gorm:"type:varchar(255)"`
+	DikonfirmasiOleh *string      `json:"dikonfirmasi_oleh" gorm:"type:char(36);null"`
+	WaktuKonfirmasi  *time.Time   `json:"waktu_konfirmasi"`
+}
+
+func (MutasiBank) TableName() string {
+	return "mutasi_bank"
+}
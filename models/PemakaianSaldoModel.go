@@ -12,23 +12,83 @@ const (
 	PemakaianLainnya     TipePemakaian = "lainnya"
 )
 
+// Status pengajuan pemakaian. Saldo baru berkurang (dijurnal) saat pemakaian dicairkan.
+type StatusPemakaian string
+
+const (
+	PemakaianDiajukan  StatusPemakaian = "diajukan"
+	PemakaianDisetujui StatusPemakaian = "disetujui"
+	PemakaianDitolak   StatusPemakaian = "ditolak"
+	PemakaianDicairkan StatusPemakaian = "dicairkan"
+)
+
 type PemakaianSaldo struct {
 	IDPemakaian          string        `json:"id_pemakaian" gorm:"type:char(36);primaryKey"`
 	JudulPemakaian       string        `json:"judul_pemakaian" gorm:"type:varchar(255);not null"`
 	Deskripsi            string        `json:"deskripsi" gorm:"type:text"`
-	NominalSyahriah      float64       `json:"nominal_syahriah" gorm:"type:decimal(14,2);not null;default:0"`
-	NominalDonasi        float64       `json:"nominal_donasi" gorm:"type:decimal(14,2);not null;default:0"`
+	NominalSyahriah      float64       `json:"nominal_syahriah" gorm:"type:decimal(14,2);not null;default:0"` // salinan porsi dana syahriah dari Rincian
+	NominalDonasi        float64       `json:"nominal_donasi" gorm:"type:decimal(14,2);not null;default:0"`   // salinan porsi dana donasi dari Rincian
 	NominalTotal         float64       `json:"nominal_total" gorm:"type:decimal(14,2);not null;check:nominal_total > 0"`
 	TipePemakaian        TipePemakaian `json:"tipe_pemakaian" gorm:"type:enum('operasional','investasi','lainnya');not null"`
 	TanggalPemakaian     *time.Time    `json:"tanggal_pemakaian" gorm:"null"`
 	DiajukanOleh         string        `json:"diajukan_oleh" gorm:"type:char(36);not null"`
 	Keterangan           *string       `json:"keterangan" gorm:"type:text;null"`
+	IDKampanye           *string       `json:"id_kampanye" gorm:"type:char(36);null;index"` // memakai dana terikat kampanye ini
+	IDAnggaran           *string       `json:"id_anggaran" gorm:"type:char(36);null;index"` // pos RAPB yang dibebani
+	IDRekening           *string       `json:"id_rekening" gorm:"type:char(36);null;index"` // rekening sumber uang, kosong = rekening utama
+	// Data lama sebelum ada alur persetujuan diisi dicairkan saat migrasi (services.MigrasiStatusPemakaian)
+	Status               StatusPemakaian `json:"status" gorm:"type:enum('diajukan','disetujui','ditolak','dicairkan');default:'diajukan';index"`
+	PeranPenyetuju       UserRole        `json:"peran_penyetuju" gorm:"type:varchar(20)"` // peran minimal yang boleh menyetujui, sesuai ambang nominal
+	DisetujuiOleh        *string         `json:"disetujui_oleh" gorm:"type:char(36);null"`
+	WaktuDisetujui       *time.Time      `json:"waktu_disetujui" gorm:"null"`
+	DicairkanOleh        *string         `json:"dicairkan_oleh" gorm:"type:char(36);null"`
+	WaktuDicairkan       *time.Time      `json:"waktu_dicairkan" gorm:"null"`
 	CreatedAt            time.Time     `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt            time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
 
-	Pengaju  User `json:"pengaju" gorm:"foreignKey:DiajukanOleh;references:IDUser"`
+	Pengaju  User      `json:"pengaju" gorm:"foreignKey:DiajukanOleh;references:IDUser"`
+	Kampanye *Kampanye `json:"kampanye,omitempty" gorm:"foreignKey:IDKampanye;references:IDKampanye"`
+	Anggaran *Anggaran `json:"anggaran,omitempty" gorm:"foreignKey:IDAnggaran;references:IDAnggaran"`
+	Rekening *Rekening `json:"rekening,omitempty" gorm:"foreignKey:IDRekening;references:IDRekening"`
+	Riwayat  []RiwayatPemakaian `json:"riwayat,omitempty" gorm:"foreignKey:IDPemakaian;references:IDPemakaian"`
+	Lampiran []LampiranPemakaian `json:"lampiran,omitempty" gorm:"foreignKey:IDPemakaian;references:IDPemakaian"`
+	Rincian  []PemakaianDana     `json:"rincian_dana,omitempty" gorm:"foreignKey:IDPemakaian;references:IDPemakaian"`
+
+	JumlahLampiran int64 `json:"jumlah_lampiran" gorm:"-"`
 }
 
 func (PemakaianSaldo) TableName() string {
 	return "pemakaian_saldo"
-}
\ No newline at end of file
+}
+// RiwayatPemakaian mencatat setiap perubahan status pengajuan pemakaian beserta pelaku dan komentarnya
+type RiwayatPemakaian struct {
+	IDRiwayat   string          `json:"id_riwayat" gorm:"type:char(36);primaryKey"`
+	IDPemakaian string          `json:"id_pemakaian" gorm:"type:char(36);not null;index"`
+	StatusDari  StatusPemakaian `json:"status_dari" gorm:"type:varchar(20)"` // kosong untuk pengajuan baru
+	StatusKe    StatusPemakaian `json:"status_ke" gorm:"type:varchar(20);not null"`
+	Oleh        string          `json:"oleh" gorm:"type:char(36);not null"`
+	Komentar    string          `json:"komentar" gorm:"type:text"`
+	Waktu       time.Time       `json:"waktu" gorm:"autoCreateTime"`
+
+	Pelaku User `json:"pelaku" gorm:"foreignKey:Oleh;references:IDUser"`
+}
+
+func (RiwayatPemakaian) TableName() string {
+	return "riwayat_pemakaian"
+}
+
+// AmbangPersetujuan menentukan peran yang wajib menyetujui pemakaian di atas nominal tertentu,
+// misalnya pemakaian di atas Rp 1.000.000 harus disetujui super_admin. Pemakaian yang tidak
+// melewati ambang mana pun cukup disetujui admin.
+type AmbangPersetujuan struct {
+	IDAmbang       string    `json:"id_ambang" gorm:"type:char(36);primaryKey"`
+	NominalMinimal float64   `json:"nominal_minimal" gorm:"type:decimal(14,2);not null;uniqueIndex"` // berlaku untuk nominal total di atas nilai ini
+	PeranPenyetuju UserRole  `json:"peran_penyetuju" gorm:"type:enum('admin','super_admin');not null"`
+	Keterangan     string    `json:"keterangan" gorm:"type:text"`
+	DibuatPada     time.Time `json:"dibuat_pada" gorm:"autoCreateTime"`
+	DiperbaruiPada time.Time `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+}
+
+func (AmbangPersetujuan) TableName() string {
+	return "ambang_persetujuan"
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+type StatusTagihanOnline string
+
+const (
+	TagihanOnlineMenunggu    StatusTagihanOnline = "menunggu"
+	TagihanOnlineDibayar     StatusTagihanOnline = "dibayar"
+	TagihanOnlineKedaluwarsa StatusTagihanOnline = "kedaluwarsa"
+	TagihanOnlineGagal       StatusTagihanOnline = "gagal"
+)
+
+// TagihanOnline adalah invoice pembayaran online untuk satu atau beberapa tagihan syahriah.
+// Kode dipakai sebagai order id di payment gateway.
+type TagihanOnline struct {
+	IDTagihan       string              `json:"id_tagihan" gorm:"type:char(36);primaryKey"`
+	Kode            string              `json:"kode" gorm:"type:varchar(50);not null;uniqueIndex"`
+	Penyedia        string              `json:"penyedia" gorm:"type:varchar(20);not null"`
+	IDEksternal     string              `json:"id_eksternal" gorm:"type:varchar(100)"`
+	URLPembayaran   string              `json:"url_pembayaran" gorm:"type:varchar(500)"`
+	IDWali          string              `json:"id_wali" gorm:"type:char(36);not null;index"`
+	Nominal         float64             `json:"nominal" gorm:"type:decimal(12,2);not null"`
+	Status          StatusTagihanOnline `json:"status" gorm:"type:enum('menunggu','dibayar','kedaluwarsa','gagal');default:'menunggu';index"`
+	MetodePenyedia  string              `json:"metode_penyedia" gorm:"type:varchar(50)"` // misalnya bank_transfer, gopay, qris
+	KedaluwarsaPada time.Time           `json:"kedaluwarsa_pada"`
+	DibayarPada     *time.Time          `json:"dibayar_pada"`
+	WaktuBuat       time.Time           `json:"waktu_buat" gorm:"autoCreateTime"`
+
+	Wali    User                   `json:"wali" gorm:"foreignKey:IDWali;references:IDUser"`
+	Item    []ItemTagihanOnline    `json:"item,omitempty" gorm:"foreignKey:IDTagihan;references:IDTagihan"`
+	Riwayat []RiwayatTagihanOnline `json:"riwayat,omitempty" gorm:"foreignKey:IDTagihan;references:IDTagihan"`
+}
+
+func (TagihanOnline) TableName() string {
+	return "tagihan_online"
+}
+
+// ItemTagihanOnline adalah satu tagihan syahriah di dalam invoice online
+type ItemTagihanOnline struct {
+	IDItem       string  `json:"id_item" gorm:"type:char(36);primaryKey"`
+	IDTagihan    string  `json:"id_tagihan" gorm:"type:char(36);not null;index"`
+	IDSyahriah   string  `json:"id_syahriah" gorm:"type:char(36);not null;index"`
+	Nominal      float64 `json:"nominal" gorm:"type:decimal(12,2);not null"` // sisa tagihan saat invoice dibuat
+	IDPembayaran *string `json:"id_pembayaran" gorm:"type:char(36);null"`    // terisi setelah lunas
+
+	Syahriah Syahriah `json:"syahriah" gorm:"foreignKey:IDSyahriah;references:IDSyahriah"`
+}
+
+func (ItemTagihanOnline) TableName() string {
+	return "item_tagihan_online"
+}
+
+// RiwayatTagihanOnline mencatat setiap perubahan status invoice beserta sumbernya,
+// termasuk notifikasi ulang dari gateway yang tidak mengubah status
+type RiwayatTagihanOnline struct {
+	IDRiwayat  string    `json:"id_riwayat" gorm:"type:char(36);primaryKey"`
+	IDTagihan  string    `json:"id_tagihan" gorm:"type:char(36);not null;index"`
+	StatusDari string    `json:"status_dari" gorm:"type:varchar(20)"`
+	StatusKe   string    `json:"status_ke" gorm:"type:varchar(20);not null"`
+	Sumber     string    `json:"sumber" gorm:"type:varchar(20);not null"` // sistem, webhook, wali
+	Keterangan string    `json:"keterangan" gorm:"type:text"`
+	Payload    string    `json:"payload,omitempty" gorm:"type:text"`
+	Waktu      time.Time `json:"waktu" gorm:"autoCreateTime"`
+}
+
+func (RiwayatTagihanOnline) TableName() string {
+	return "riwayat_tagihan_online"
+}
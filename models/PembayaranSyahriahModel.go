@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type MetodeBayar string
+
+const (
+	MetodeTunai    MetodeBayar = "tunai"
+	MetodeTransfer MetodeBayar = "transfer"
+	MetodeQRIS     MetodeBayar = "qris"
+	MetodeLainnya  MetodeBayar = "lainnya"
+	MetodeOnline   MetodeBayar = "online" // lewat payment gateway, dicatat otomatis dari webhook
+)
+
+// PembayaranSyahriah adalah satu kali penerimaan uang untuk sebuah tagihan syahriah.
+// Satu tagihan bisa dicicil beberapa kali.
+type PembayaranSyahriah struct {
+	IDPembayaran string      `json:"id_pembayaran" gorm:"type:char(36);primaryKey"`
+	IDSyahriah   string      `json:"id_syahriah" gorm:"type:char(36);not null;index"`
+	Nominal      float64     `json:"nominal" gorm:"type:decimal(12,2);not null;check:nominal > 0"`
+	TanggalBayar time.Time   `json:"tanggal_bayar" gorm:"not null"`
+	Metode       MetodeBayar `json:"metode" gorm:"type:enum('tunai','transfer','qris','lainnya','online');default:'tunai'"`
+	DiterimaOleh string      `json:"diterima_oleh" gorm:"type:char(36);not null"`
+	Keterangan   string      `json:"keterangan" gorm:"type:text"`
+	IDMutasi     *string     `json:"id_mutasi,omitempty" gorm:"type:char(36);null;index"` // baris mutasi bank asal transfer
+	IDRekening   *string     `json:"id_rekening" gorm:"type:char(36);null;index"`         // rekening penerima uang, kosong = rekening utama
+	WaktuCatat   time.Time   `json:"waktu_catat" gorm:"autoCreateTime"`
+
+	Penerima User      `json:"penerima" gorm:"foreignKey:DiterimaOleh;references:IDUser"`
+	Rekening *Rekening `json:"rekening,omitempty" gorm:"foreignKey:IDRekening;references:IDRekening"`
+}
+
+func (PembayaranSyahriah) TableName() string {
+	return "pembayaran_syahriah"
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+type StatusPermintaanQRIS string
+
+const (
+	QRISMenunggu StatusPermintaanQRIS = "menunggu"
+	QRISDibayar  StatusPermintaanQRIS = "dibayar"
+)
+
+// Tujuan pembayaran QRIS dinamis
+const (
+	QRISUntukSyahriah = "syahriah"
+	QRISUntukDonasi   = "donasi"
+)
+
+// PermintaanQRIS adalah QRIS dinamis yang pernah diterbitkan. Referensi ikut tertulis di payload
+// sehingga uang masuk di mutasi rekening bisa dipasangkan kembali ke tagihan atau donatur asalnya.
+type PermintaanQRIS struct {
+	IDPermintaan    string               `json:"id_permintaan" gorm:"type:char(36);primaryKey"`
+	Referensi       string               `json:"referensi" gorm:"type:varchar(25);not null;uniqueIndex"`
+	Tujuan          string               `json:"tujuan" gorm:"type:varchar(20);not null"`
+	IDSyahriah      *string              `json:"id_syahriah,omitempty" gorm:"type:char(36);null;index"`
+	NamaDonatur     string               `json:"nama_donatur,omitempty" gorm:"type:varchar(100)"`
+	NoTelp          string               `json:"no_telp,omitempty" gorm:"type:varchar(20)"`
+	IDKampanye      *string              `json:"id_kampanye,omitempty" gorm:"type:char(36);null"` // donasi untuk kampanye ini
+	Nominal         float64              `json:"nominal" gorm:"type:decimal(12,2);not null"`
+	Payload         string               `json:"payload" gorm:"type:text;not null"`
+	Status          StatusPermintaanQRIS `json:"status" gorm:"type:enum('menunggu','dibayar');default:'menunggu';index"`
+	IDMutasi        *string              `json:"id_mutasi,omitempty" gorm:"type:char(36);null"`
+	IDDonasi        *string              `json:"id_donasi,omitempty" gorm:"type:char(36);null;index"` // donasi yang tercatat saat dibayar
+	KedaluwarsaPada time.Time            `json:"kedaluwarsa_pada"`
+	WaktuBuat       time.Time            `json:"waktu_buat" gorm:"autoCreateTime"`
+}
+
+func (PermintaanQRIS) TableName() string {
+	return "permintaan_qris"
+}
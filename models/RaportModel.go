@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+type Semester string
+
+const (
+	SemesterGanjil Semester = "ganjil" // Juli - Desember
+	SemesterGenap  Semester = "genap"  // Januari - Juni
+)
+
+// Raport adalah isian raport satu santri untuk satu semester: nilai mata pelajaran dan catatan
+// ustadz. Kehadiran dan perkembangan Qur'an dihitung dari absensi dan setoran saat raport dicetak.
+type Raport struct {
+	IDRaport       string    `json:"id_raport" gorm:"type:char(36);primaryKey"`
+	IDSantri       string    `json:"id_santri" gorm:"type:char(36);not null;uniqueIndex:idx_raport_santri_semester"`
+	IDKelas        string    `json:"id_kelas" gorm:"type:char(36);not null;index"`
+	TahunAjaran    string    `json:"tahun_ajaran" gorm:"type:varchar(9);not null;uniqueIndex:idx_raport_santri_semester"`
+	Semester       Semester  `json:"semester" gorm:"type:enum('ganjil','genap');not null;uniqueIndex:idx_raport_santri_semester"`
+	CatatanUstadz  string    `json:"catatan_ustadz" gorm:"type:text"`
+	DiisiOleh      string    `json:"diisi_oleh" gorm:"type:char(36);not null"`
+	DibuatPada     time.Time `json:"dibuat_pada" gorm:"autoCreateTime"`
+	DiperbaruiPada time.Time `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+
+	Santri *Santri       `json:"santri,omitempty" gorm:"foreignKey:IDSantri;references:IDSantri"`
+	Kelas  *Kelas        `json:"kelas,omitempty" gorm:"foreignKey:IDKelas;references:IDKelas"`
+	Nilai  []NilaiRaport `json:"nilai,omitempty" gorm:"foreignKey:IDRaport;references:IDRaport"`
+}
+
+func (Raport) TableName() string {
+	return "raport"
+}
+
+// NilaiRaport adalah nilai satu mata pelajaran pada raport
+type NilaiRaport struct {
+	IDNilai   string `json:"id_nilai" gorm:"type:char(36);primaryKey"`
+	IDRaport  string `json:"id_raport" gorm:"type:char(36);not null;uniqueIndex:idx_nilai_raport_mapel"`
+	Mapel     string `json:"mapel" gorm:"type:varchar(50);not null;uniqueIndex:idx_nilai_raport_mapel"` // kode mapel, lihat services.MapelRaport
+	Nilai     int    `json:"nilai" gorm:"not null"`                                                     // 0-100
+	Deskripsi string `json:"deskripsi" gorm:"type:text"`
+}
+
+func (NilaiRaport) TableName() string {
+	return "nilai_raport"
+}
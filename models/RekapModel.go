@@ -22,8 +22,51 @@ type RekapSaldo struct {
 	SaldoAkhirTotal    float64   `json:"saldo_akhir_total" gorm:"type:decimal(14,2);default:0"`
 	
 	TerakhirUpdate     time.Time `json:"terakhir_update" gorm:"autoUpdateTime"`
+
+	// Rincian semua dana; kolom syahriah dan donasi di atas dipertahankan untuk klien lama
+	PerDana []RekapDana `json:"per_dana,omitempty" gorm:"foreignKey:Periode;references:Periode;constraint:-"`
 }
 
 func (RekapSaldo) TableName() string {
 	return "rekap_saldo"
-}
\ No newline at end of file
+}
+
+// RekapDana adalah rincian RekapSaldo untuk satu dana pada satu periode
+type RekapDana struct {
+	Periode        string    `json:"periode" gorm:"type:varchar(7);primaryKey"` // format YYYY-MM
+	KodeDana       string    `json:"kode_dana" gorm:"type:varchar(30);primaryKey"`
+	Pemasukan      float64   `json:"pemasukan" gorm:"type:decimal(14,2);default:0"`
+	Pengeluaran    float64   `json:"pengeluaran" gorm:"type:decimal(14,2);default:0"`
+	SaldoAkhir     float64   `json:"saldo_akhir" gorm:"type:decimal(14,2);default:0"`
+	TerakhirUpdate time.Time `json:"terakhir_update" gorm:"autoUpdateTime"`
+
+	Dana *Dana `json:"dana,omitempty" gorm:"foreignKey:KodeDana;references:KodeDana"`
+}
+
+func (RekapDana) TableName() string {
+	return "rekap_dana"
+}
+// PerubahanRekap mencatat periode rekap yang berubah akibat transaksi (termasuk transaksi mundur)
+type PerubahanRekap struct {
+	IDPerubahan          string    `json:"id_perubahan" gorm:"type:char(36);primaryKey"`
+	Periode              string    `json:"periode" gorm:"type:varchar(7);not null;index"` // format YYYY-MM
+	TipeSumber           string    `json:"tipe_sumber" gorm:"type:varchar(50);index:idx_perubahan_sumber"`
+	IDSumber             string    `json:"id_sumber" gorm:"type:char(36);index:idx_perubahan_sumber"`
+
+	SelisihPemasukan     float64   `json:"selisih_pemasukan" gorm:"type:decimal(14,2);default:0"`
+	SelisihPengeluaran   float64   `json:"selisih_pengeluaran" gorm:"type:decimal(14,2);default:0"`
+	SelisihSaldoSyahriah float64   `json:"selisih_saldo_syahriah" gorm:"type:decimal(14,2);default:0"`
+	SelisihSaldoDonasi   float64   `json:"selisih_saldo_donasi" gorm:"type:decimal(14,2);default:0"`
+	SaldoAkhirLama       float64   `json:"saldo_akhir_lama" gorm:"type:decimal(14,2);default:0"`
+	SaldoAkhirBaru       float64   `json:"saldo_akhir_baru" gorm:"type:decimal(14,2);default:0"`
+
+	Keterangan           string    `json:"keterangan" gorm:"type:text"`
+	DipicuOleh           string    `json:"dipicu_oleh" gorm:"type:char(36)"`
+	WaktuPerubahan       time.Time `json:"waktu_perubahan" gorm:"autoCreateTime"`
+
+	Admin User `json:"admin" gorm:"foreignKey:DipicuOleh;references:IDUser"`
+}
+
+func (PerubahanRekap) TableName() string {
+	return "perubahan_rekap"
+}
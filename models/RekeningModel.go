@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+type JenisRekening string
+
+const (
+	RekeningTunai         JenisRekening = "tunai"          // kotak kas / kas kecil
+	RekeningBank          JenisRekening = "bank"           // rekening bank, misalnya BSI
+	RekeningDompetDigital JenisRekening = "dompet_digital" // e-wallet bendahara
+)
+
+// Rekening adalah tempat uang disimpan secara fisik. Berbeda dengan Dana yang memisahkan
+// peruntukan uang, satu rekening bisa berisi uang dari beberapa dana. Baris jurnal kas
+// mencatat rekening yang dipakai sehingga saldo setiap rekening bisa dihitung dari jurnal.
+type Rekening struct {
+	IDRekening     string        `json:"id_rekening" gorm:"type:char(36);primaryKey"`
+	NamaRekening   string        `json:"nama_rekening" gorm:"type:varchar(100);not null"`
+	Jenis          JenisRekening `json:"jenis" gorm:"type:enum('tunai','bank','dompet_digital');not null"`
+	NamaBank       string        `json:"nama_bank" gorm:"type:varchar(100)"` // bank atau penyedia e-wallet
+	NomorRekening  string        `json:"nomor_rekening" gorm:"type:varchar(50)"`
+	AtasNama       string        `json:"atas_nama" gorm:"type:varchar(100)"`
+	Utama          bool          `json:"utama" gorm:"default:false"` // dipakai jika transaksi tidak menyebut rekening
+	Aktif          bool          `json:"aktif" gorm:"default:true"`
+	Keterangan     string        `json:"keterangan" gorm:"type:text"`
+	DibuatPada     time.Time     `json:"dibuat_pada" gorm:"autoCreateTime"`
+	DiperbaruiPada time.Time     `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+}
+
+func (Rekening) TableName() string {
+	return "rekening"
+}
+
+// TransferRekening adalah pemindahan uang antar rekening, misalnya setor kas tunai ke bank.
+// Transfer tidak dihitung sebagai pemasukan maupun pengeluaran dana.
+type TransferRekening struct {
+	IDTransfer   string    `json:"id_transfer" gorm:"type:char(36);primaryKey"`
+	DariRekening string    `json:"dari_rekening" gorm:"type:char(36);not null;index"`
+	KeRekening   string    `json:"ke_rekening" gorm:"type:char(36);not null;index"`
+	Nominal      float64   `json:"nominal" gorm:"type:decimal(14,2);not null"`
+	Tanggal      time.Time `json:"tanggal" gorm:"not null;index"`
+	Keterangan   string    `json:"keterangan" gorm:"type:text"`
+	DicatatOleh  string    `json:"dicatat_oleh" gorm:"type:char(36);not null"`
+	WaktuCatat   time.Time `json:"waktu_catat" gorm:"autoCreateTime"`
+
+	Dari  *Rekening `json:"dari,omitempty" gorm:"foreignKey:DariRekening;references:IDRekening"`
+	Ke    *Rekening `json:"ke,omitempty" gorm:"foreignKey:KeRekening;references:IDRekening"`
+	Admin *User     `json:"admin,omitempty" gorm:"foreignKey:DicatatOleh;references:IDUser"`
+}
+
+func (TransferRekening) TableName() string {
+	return "transfer_rekening"
+}
+
+// OpnameKas adalah hasil hitung fisik saldo satu rekening yang dibandingkan dengan saldo sistem
+type OpnameKas struct {
+	IDOpname    string    `json:"id_opname" gorm:"type:char(36);primaryKey"`
+	IDRekening  string    `json:"id_rekening" gorm:"type:char(36);not null;index"`
+	Tanggal     time.Time `json:"tanggal" gorm:"not null;index"`
+	SaldoSistem float64   `json:"saldo_sistem" gorm:"type:decimal(14,2);not null"`
+	SaldoFisik  float64   `json:"saldo_fisik" gorm:"type:decimal(14,2);not null"`
+	Selisih     float64   `json:"selisih" gorm:"type:decimal(14,2);not null"` // saldo fisik - saldo sistem
+	Keterangan  string    `json:"keterangan" gorm:"type:text"`
+	DicatatOleh string    `json:"dicatat_oleh" gorm:"type:char(36);not null"`
+	WaktuCatat  time.Time `json:"waktu_catat" gorm:"autoCreateTime"`
+
+	Rekening *Rekening `json:"rekening,omitempty" gorm:"foreignKey:IDRekening;references:IDRekening"`
+	Admin    *User     `json:"admin,omitempty" gorm:"foreignKey:DicatatOleh;references:IDUser"`
+}
+
+func (OpnameKas) TableName() string {
+	return "opname_kas"
+}
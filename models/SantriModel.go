@@ -28,6 +28,8 @@ type Santri struct {
 	Alamat          string        `json:"alamat" gorm:"type:text"`
 	Foto            string        `json:"foto" gorm:"type:varchar(255)"`
 	Status          StatusSantri  `json:"status" gorm:"type:enum('aktif','lulus','pindah','berhenti');default:'aktif'"`
+	Program         string        `json:"program" gorm:"type:varchar(50);default:''"`
+	Kelas           string        `json:"kelas" gorm:"type:varchar(50);default:''"`
 	TanggalMasuk    time.Time     `json:"tanggal_masuk" gorm:"type:date"`
 	TanggalKeluar   *time.Time    `json:"tanggal_keluar,omitempty" gorm:"type:date"`
 	DibuatPada      time.Time     `json:"dibuat_pada" gorm:"autoCreateTime"`
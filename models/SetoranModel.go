@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+type JenisSetoran string
+
+const (
+	SetoranIqro    JenisSetoran = "iqro"    // Iqro jilid 1-6
+	SetoranTilawah JenisSetoran = "tilawah" // membaca Al-Qur'an
+	SetoranHafalan JenisSetoran = "hafalan" // hafalan surah, terutama juz 30
+)
+
+// SetoranSantri adalah satu catatan setoran santri kepada ustadz/ustadzah. Iqro memakai jilid dan
+// halaman, tilawah dan hafalan memakai surah dan rentang ayat.
+type SetoranSantri struct {
+	IDSetoran     string       `json:"id_setoran" gorm:"type:char(36);primaryKey"`
+	IDSantri      string       `json:"id_santri" gorm:"type:char(36);not null;index"`
+	IDKelas       *string      `json:"id_kelas" gorm:"type:char(36);null;index"` // kelas santri saat setoran
+	Tanggal       time.Time    `json:"tanggal" gorm:"type:date;not null;index"`
+	Jenis         JenisSetoran `json:"jenis" gorm:"type:enum('iqro','tilawah','hafalan');not null;index"`
+	Jilid         int          `json:"jilid,omitempty"`
+	Halaman       int          `json:"halaman,omitempty"`
+	Surah         int          `json:"surah,omitempty"` // nomor surah 1-114
+	AyatMulai     int          `json:"ayat_mulai,omitempty"`
+	AyatSelesai   int          `json:"ayat_selesai,omitempty"`
+	Nilai         int          `json:"nilai" gorm:"not null"` // 0-100
+	Lulus         bool         `json:"lulus"`                 // setoran yang tidak lulus harus diulang dan tidak menaikkan posisi
+	CatatanTajwid string       `json:"catatan_tajwid" gorm:"type:text"`
+	Catatan       string       `json:"catatan" gorm:"type:text"`
+	DicatatOleh   string       `json:"dicatat_oleh" gorm:"type:char(36);not null"`
+	WaktuCatat    time.Time    `json:"waktu_catat" gorm:"autoCreateTime"`
+
+	Santri   *Santri `json:"santri,omitempty" gorm:"foreignKey:IDSantri;references:IDSantri"`
+	Kelas    *Kelas  `json:"kelas,omitempty" gorm:"foreignKey:IDKelas;references:IDKelas"`
+	Pengajar *User   `json:"pengajar,omitempty" gorm:"foreignKey:DicatatOleh;references:IDUser"`
+}
+
+func (SetoranSantri) TableName() string {
+	return "setoran_santri"
+}
@@ -5,21 +5,29 @@ import "time"
 type StatusSyahriah string
 
 const (
-	StatusBelum StatusSyahriah = "belum"
-	StatusLunas StatusSyahriah = "lunas"
+	StatusBelum    StatusSyahriah = "belum"
+	StatusSebagian StatusSyahriah = "sebagian"
+	StatusLunas    StatusSyahriah = "lunas"
 )
 
 type Syahriah struct {
-	IDSyahriah  string         `json:"id_syahriah" gorm:"type:char(36);primaryKey"`
-	ID_Santri    string         `json:"id_santri" gorm:"type:char(36);not null"`
-	Bulan       string         `json:"bulan" gorm:"type:varchar(7);not null"` // format YYYY-MM
-	Nominal     float64        `json:"nominal" gorm:"type:decimal(12,2);not null;default:110000"`
-	Status      StatusSyahriah `json:"status" gorm:"type:enum('belum','lunas');default:'belum'"`
-	DicatatOleh string         `json:"dicatat_oleh" gorm:"type:char(36);not null"`
-	WaktuCatat  time.Time      `json:"waktu_catat" gorm:"autoCreateTime"`
+	IDSyahriah      string         `json:"id_syahriah" gorm:"type:char(36);primaryKey"`
+	ID_Santri       string         `json:"id_santri" gorm:"type:char(36);not null"` // unik bersama bulan, lihat services.PastikanIndeksSyahriah
+	Bulan           string         `json:"bulan" gorm:"type:varchar(7);not null"`   // format YYYY-MM
+	Nominal         float64        `json:"nominal" gorm:"type:decimal(12,2);not null"`
+	IDTarif         *string        `json:"id_tarif" gorm:"type:char(36);null"`
+	NominalDasar    float64        `json:"nominal_dasar" gorm:"type:decimal(12,2);default:0"` // nominal tarif sebelum potongan
+	Potongan        float64        `json:"potongan" gorm:"type:decimal(12,2);default:0"`
+	KeteranganTarif string         `json:"keterangan_tarif" gorm:"type:varchar(255)"`
+	Terbayar        float64        `json:"terbayar" gorm:"type:decimal(12,2);default:0"` // total dari pembayaran_syahriah
+	Status          StatusSyahriah `json:"status" gorm:"type:enum('belum','sebagian','lunas');default:'belum'"`
+	DicatatOleh     string         `json:"dicatat_oleh" gorm:"type:char(36);not null"`
+	WaktuCatat      time.Time      `json:"waktu_catat" gorm:"autoCreateTime"`
 
 	Santri Santri `json:"santri" gorm:"foreignKey:ID_Santri;references:IDSantri"`
 	Admin  User   `json:"admin" gorm:"foreignKey:DicatatOleh;references:IDUser"`
+
+	Pembayaran []PembayaranSyahriah `json:"pembayaran,omitempty" gorm:"foreignKey:IDSyahriah;references:IDSyahriah"`
 }
 
 func (Syahriah) TableName() string {
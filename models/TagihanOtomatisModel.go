@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type PemicuTagihan string
+
+const (
+	PemicuOtomatis PemicuTagihan = "otomatis"
+	PemicuManual   PemicuTagihan = "manual"
+)
+
+type StatusJalanTagihan string
+
+const (
+	JalanBerhasil StatusJalanTagihan = "berhasil"
+	JalanGagal    StatusJalanTagihan = "gagal"
+)
+
+// LaporanTagihanOtomatis mencatat hasil setiap kali tagihan syahriah bulanan dibuat oleh penjadwal
+type LaporanTagihanOtomatis struct {
+	IDLaporan        string             `json:"id_laporan" gorm:"type:char(36);primaryKey"`
+	Bulan            string             `json:"bulan" gorm:"type:varchar(7);not null;index"` // format YYYY-MM
+	Pemicu           PemicuTagihan      `json:"pemicu" gorm:"type:enum('otomatis','manual');default:'otomatis'"`
+	Status           StatusJalanTagihan `json:"status" gorm:"type:enum('berhasil','gagal');not null"`
+	JumlahDibuat     int                `json:"jumlah_dibuat" gorm:"default:0"`
+	JumlahDilewati   int                `json:"jumlah_dilewati" gorm:"default:0"` // santri yang sudah punya tagihan bulan ini
+	TotalSantriAktif int                `json:"total_santri_aktif" gorm:"default:0"`
+	Pesan            string             `json:"pesan" gorm:"type:text"`
+	JumlahPercobaan  int                `json:"jumlah_percobaan" gorm:"default:1"` // kegagalan berulang di bulan yang sama memperbarui laporan ini
+	DijalankanOleh   *string            `json:"dijalankan_oleh" gorm:"type:char(36);null"`
+	WaktuMulai       time.Time          `json:"waktu_mulai"`
+	WaktuSelesai     time.Time          `json:"waktu_selesai"`
+}
+
+func (LaporanTagihanOtomatis) TableName() string {
+	return "laporan_tagihan_otomatis"
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// TarifSyahriah adalah tarif syahriah yang berlaku mulai bulan tertentu.
+// Program/Kelas kosong berarti berlaku untuk semua program/kelas.
+type TarifSyahriah struct {
+	IDTarif               string    `json:"id_tarif" gorm:"type:char(36);primaryKey"`
+	NamaTarif             string    `json:"nama_tarif" gorm:"type:varchar(100);not null"`
+	BerlakuMulai          string    `json:"berlaku_mulai" gorm:"type:varchar(7);not null;index"` // format YYYY-MM
+	Program               string    `json:"program" gorm:"type:varchar(50);default:''"`
+	Kelas                 string    `json:"kelas" gorm:"type:varchar(50);default:''"`
+	Nominal               float64   `json:"nominal" gorm:"type:decimal(12,2);not null"`
+	PotonganSaudaraPersen float64   `json:"potongan_saudara_persen" gorm:"type:decimal(5,2);default:0"` // untuk anak kedua dst dalam satu keluarga
+	Keterangan            string    `json:"keterangan" gorm:"type:text"`
+	DibuatOleh            *string   `json:"dibuat_oleh" gorm:"type:char(36);null"`
+	DibuatPada            time.Time `json:"dibuat_pada" gorm:"autoCreateTime"`
+	DiperbaruiPada        time.Time `json:"diperbarui_pada" gorm:"autoUpdateTime"`
+}
+
+func (TarifSyahriah) TableName() string {
+	return "tarif_syahriah"
+}
+
+type JenisKeringanan string
+
+const (
+	KeringananSaudara  JenisKeringanan = "saudara"
+	KeringananYatim    JenisKeringanan = "yatim"
+	KeringananDhuafa   JenisKeringanan = "dhuafa"
+	KeringananBeasiswa JenisKeringanan = "beasiswa"
+)
+
+type TipePotongan string
+
+const (
+	PotonganPersen  TipePotongan = "persen"
+	PotonganNominal TipePotongan = "nominal"
+	PotonganBebas   TipePotongan = "bebas"
+)
+
+// KeringananSyahriah adalah potongan atau pembebasan syahriah khusus untuk satu santri
+type KeringananSyahriah struct {
+	IDKeringanan  string          `json:"id_keringanan" gorm:"type:char(36);primaryKey"`
+	IDSantri      string          `json:"id_santri" gorm:"type:char(36);not null;index"`
+	Jenis         JenisKeringanan `json:"jenis" gorm:"type:enum('saudara','yatim','dhuafa','beasiswa');not null"`
+	TipePotongan  TipePotongan    `json:"tipe_potongan" gorm:"type:enum('persen','nominal','bebas');not null"`
+	Nilai         float64         `json:"nilai" gorm:"type:decimal(12,2);default:0"`
+	BerlakuMulai  string          `json:"berlaku_mulai" gorm:"type:varchar(7);not null"` // format YYYY-MM
+	BerlakuSampai *string         `json:"berlaku_sampai" gorm:"type:varchar(7);null"`    // kosong berarti tanpa batas
+	Keterangan    string          `json:"keterangan" gorm:"type:text"`
+	DibuatOleh    string          `json:"dibuat_oleh" gorm:"type:char(36);not null"`
+	DibuatPada    time.Time       `json:"dibuat_pada" gorm:"autoCreateTime"`
+
+	Santri Santri `json:"santri" gorm:"foreignKey:IDSantri;references:IDSantri"`
+}
+
+func (KeringananSyahriah) TableName() string {
+	return "keringanan_syahriah"
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type StatusBuku string
+
+const (
+	BukuDitutup StatusBuku = "ditutup"
+	BukuDibuka  StatusBuku = "dibuka"
+)
+
+// TutupBuku menyimpan status tutup buku per periode beserta snapshot saldo saat ditutup
+type TutupBuku struct {
+	IDTutupBuku string     `json:"id_tutup_buku" gorm:"type:char(36);primaryKey"`
+	Periode     string     `json:"periode" gorm:"type:varchar(7);not null;uniqueIndex"` // format YYYY-MM
+	Status      StatusBuku `json:"status" gorm:"type:enum('ditutup','dibuka');default:'ditutup'"`
+	Catatan     string     `json:"catatan" gorm:"type:text"`
+
+	// Snapshot rekap saat tutup buku
+	SaldoAkhirSyahriah float64 `json:"saldo_akhir_syahriah" gorm:"type:decimal(14,2);default:0"`
+	SaldoAkhirDonasi   float64 `json:"saldo_akhir_donasi" gorm:"type:decimal(14,2);default:0"`
+	SaldoAkhirTotal    float64 `json:"saldo_akhir_total" gorm:"type:decimal(14,2);default:0"`
+
+	DitutupOleh string     `json:"ditutup_oleh" gorm:"type:char(36);not null"`
+	WaktuTutup  time.Time  `json:"waktu_tutup"`
+	DibukaOleh  *string    `json:"dibuka_oleh" gorm:"type:char(36);null"`
+	WaktuBuka   *time.Time `json:"waktu_buka" gorm:"null"`
+	AlasanBuka  *string    `json:"alasan_buka" gorm:"type:text;null"`
+
+	PenutupBuku User  `json:"penutup_buku" gorm:"foreignKey:DitutupOleh;references:IDUser"`
+	PembukaBuku *User `json:"pembuka_buku,omitempty" gorm:"foreignKey:DibukaOleh;references:IDUser"`
+}
+
+func (TutupBuku) TableName() string {
+	return "tutup_buku"
+}
@@ -8,6 +8,7 @@ const (
 	RoleSuperAdmin UserRole = "super_admin"
 	RoleAdmin      UserRole = "admin"
 	RoleWali       UserRole = "wali"
+	RoleUstadz     UserRole = "ustadz" // ustadz/ustadzah pengajar kelas
 )
 
 type User struct {
@@ -16,7 +17,7 @@ type User struct {
 	Email          *string   `json:"email,omitempty" gorm:"type:varchar(100);unique"`
 	NoTelp         string    `json:"no_telp,omitempty" gorm:"type:varchar(20)"`
 	Password       string    `json:"password" gorm:"type:varchar(255);not null"`
-	Role           UserRole  `json:"role" gorm:"type:enum('super_admin','admin','wali');default:'wali'"`
+	Role           UserRole  `json:"role" gorm:"type:enum('super_admin','admin','wali','ustadz');default:'wali'"`
 	StatusAktif    bool      `json:"status_aktif" gorm:"default:false"`
 	DibuatPada     time.Time `json:"dibuat_pada" gorm:"autoCreateTime"`
 	DiperbaruiPada time.Time `json:"diperbarui_pada" gorm:"autoUpdateTime"`
@@ -4,6 +4,7 @@ import (
 	"tpq_asysyafii/config"
 	"tpq_asysyafii/controllers"
 	"tpq_asysyafii/middleware"
+	"tpq_asysyafii/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,12 +18,22 @@ func SetupRoutes(r *gin.Engine) {
 		donasiController := controllers.NewDonasiController(config.GetDB())
 		api.GET("/donasi-public", donasiController.GetDonasiPublic)
     	api.GET("/donasi-public/summary", donasiController.GetDonasiSummaryPublic)
+		api.POST("/donasi-public/qris", donasiController.CreateQRISDonasiPublic)
+
+		kampanyeController := controllers.NewKampanyeController(config.DB)
+		api.GET("/kampanye", kampanyeController.GetKampanyePublic)
+		api.GET("/kampanye/:slug", kampanyeController.GetKampanyeBySlugPublic)
 
 		pemakaianController := controllers.NewPemakaianSaldoController(config.DB)
 		api.GET("/pengeluaran-public", pemakaianController.GetAllPemakaianPublic)
 		api.GET("/pengeluaran-public/summary", pemakaianController.GetPemakaianSummaryPublic)
 		api.GET("/pengeluaran-public/stats", pemakaianController.GetPemakaianStatsPublic)
 		api.GET("/pengeluaran-public/:id", pemakaianController.GetPemakaianByIDPublic)
+		lampiranPemakaianController := controllers.NewLampiranPemakaianController(config.DB)
+		api.GET("/pengeluaran-public/:id/lampiran/:id_lampiran/thumbnail", lampiranPemakaianController.GetThumbnailPublik)
+
+		danaController := controllers.NewDanaController(config.DB)
+		api.GET("/dana", danaController.GetDanaPublic)
 
 		rekapController := controllers.NewRekapController(config.DB)
 		api.GET("/rekap-public", rekapController.GetRekapPublic)
@@ -48,6 +59,10 @@ func SetupRoutes(r *gin.Engine) {
 		informasiTPQController := controllers.NewInformasiTPQController(config.DB)
 		api.GET("/informasi-tpq", informasiTPQController.GetInformasiTPQ)
 
+		// Notifikasi payment gateway, keasliannya diverifikasi dari tanda tangan penyedia
+		pembayaranOnlineController := controllers.NewPembayaranOnlineController(config.DB)
+		api.POST("/pembayaran-online/webhook/:penyedia", pembayaranOnlineController.WebhookPembayaranOnline)
+
 		sosialMediaController := controllers.NewSosialMediaController(config.DB)
 		api.GET("/sosial-media", sosialMediaController.GetAllSosialMedia)
 
@@ -74,13 +89,38 @@ func SetupRoutes(r *gin.Engine) {
 
 			santriController := controllers.NewSantriController(config.DB)
 			protected.GET("/santri/my", santriController.GetMySantri)
+			protected.GET("/santri/my/absensi", santriController.GetMyAbsensiSantri)
 			protected.GET("/wali/santri", santriController.GetSantriByWali) 
 
+			// Perkembangan belajar santri untuk wali, ustadz pengajar dan admin
+			perkembanganController := controllers.NewPerkembanganController(config.DB)
+			protected.GET("/santri/:id/perkembangan", perkembanganController.GetPerkembanganSantri)
+			protected.GET("/santri/:id/setoran", perkembanganController.GetAllSetoranSantri)
+			protected.GET("/santri/:id/munaqosah", perkembanganController.GetMunaqosahSantri)
+
+			munaqosahController := controllers.NewMunaqosahController(config.DB)
+			protected.GET("/munaqosah/rubrik", munaqosahController.GetRubrikMunaqosah)
+
+			// Raport semester; wali hanya bisa melihat raport anaknya sendiri
+			raportController := controllers.NewRaportController(config.DB)
+			protected.GET("/santri/:id/raport", raportController.GetRaportSantri)
+			protected.GET("/santri/:id/raport/pdf", raportController.DownloadRaportSantri)
+
 			syahriahController := controllers.NewSyahriahController(config.DB)
 			protected.GET("/syahriah", syahriahController.GetSyahriahForWali)
 			protected.GET("/syahriah/my", syahriahController.GetMySyahriah)	
 			protected.GET("/syahriah/summary", syahriahController.GetSyahriahSummaryForWali)
 			protected.GET("/syahriah/:id", syahriahController.GetSyahriahByID)
+			protected.GET("/syahriah/:id/pembayaran", syahriahController.GetPembayaranSyahriah)
+			protected.POST("/syahriah/:id/qris", syahriahController.CreateQRISSyahriah)
+
+			protected.POST("/pembayaran-online", pembayaranOnlineController.BuatPembayaranOnline)
+			protected.GET("/pembayaran-online", pembayaranOnlineController.GetMyPembayaranOnline)
+			protected.GET("/pembayaran-online/:id", pembayaranOnlineController.GetPembayaranOnlineByID)
+
+			kwitansiController := controllers.NewKwitansiController(config.DB)
+			protected.GET("/kwitansi/:id/pdf", kwitansiController.GetKwitansiPDF)
+			protected.GET("/syahriah/pembayaran/:id/kwitansi", kwitansiController.GetKwitansiPembayaranSyahriah)
 
 			donasiController := controllers.NewDonasiController(config.GetDB())
 			protected.GET("/donasi", donasiController.GetAllDonasi)
@@ -112,29 +152,131 @@ func SetupRoutes(r *gin.Engine) {
 			protected.DELETE("/testimoni/:id", testimoniController.DeleteTestimoni)
 		}
 
+		// Group untuk ustadz/ustadzah
+		ustadz := api.Group("/ustadz")
+		ustadz.Use(middlewares.AuthMiddleware(), middlewares.UstadzMiddleware())
+		{
+			kelasController := controllers.NewKelasController(config.DB)
+			ustadz.GET("/kelas", kelasController.GetKelasSaya)
+			ustadz.GET("/santri", kelasController.GetSantriSaya)
+
+			absensiController := controllers.NewAbsensiController(config.DB)
+			ustadz.GET("/absensi", absensiController.GetSesiAbsensi)
+			ustadz.GET("/absensi/sesi", absensiController.GetAllSesiAbsensi)
+			ustadz.GET("/absensi/rekap", absensiController.GetRekapAbsensi)
+			ustadz.POST("/absensi", absensiController.SimpanAbsensi)
+			ustadz.PUT("/absensi/:id", absensiController.UbahAbsensi)
+			ustadz.GET("/absensi/:id/koreksi", absensiController.GetKoreksiAbsensi)
+
+			perkembanganController := controllers.NewPerkembanganController(config.DB)
+			ustadz.POST("/setoran", perkembanganController.CreateSetoran)
+			ustadz.DELETE("/setoran/:id", perkembanganController.DeleteSetoran)
+			ustadz.GET("/perkembangan/distribusi", perkembanganController.GetDistribusiPerkembangan)
+
+			// Munaqosah yang diuji oleh ustadz
+			munaqosahController := controllers.NewMunaqosahController(config.DB)
+			ustadz.GET("/munaqosah", munaqosahController.GetAllMunaqosah)
+			ustadz.PUT("/munaqosah/:id/nilai", munaqosahController.NilaiMunaqosah)
+
+			raportController := controllers.NewRaportController(config.DB)
+			ustadz.GET("/raport", raportController.GetRaportKelas)
+			ustadz.PUT("/raport", raportController.SimpanRaport)
+			ustadz.GET("/raport/kelas/:id/zip", raportController.DownloadRaportKelas)
+		}
+
 		// Group untuk admin DAN super-admin
 		admin := api.Group("/admin")
 		admin.Use(middlewares.AuthMiddleware(), middlewares.AdminOrSuperAdminMiddleware())
 		{
 			admin.GET("/users", controllers.GetUsers)
 			admin.GET("/wali",controllers.GetWali)
+			admin.GET("/ustadz", controllers.GetUstadz)
 			admin.POST("/users", controllers.RegisterUser)
 
 			santriController := controllers.NewSantriController(config.DB)
 			admin.GET("/santri", santriController.GetAllSantri)
 
+			// Kelas/halaqah, jadwal dan riwayat kelas santri
+			kelasController := controllers.NewKelasController(config.DB)
+			admin.GET("/kelas", kelasController.GetAllKelas)
+			admin.POST("/kelas", kelasController.CreateKelas)
+			admin.GET("/kelas/:id", kelasController.GetKelasByID)
+			admin.PUT("/kelas/:id", kelasController.UpdateKelas)
+			admin.DELETE("/kelas/:id", kelasController.DeleteKelas)
+			admin.POST("/kelas/:id/santri", kelasController.TambahAnggotaKelas)
+			admin.PUT("/anggota-kelas/:id/keluar", kelasController.KeluarkanAnggotaKelas)
+			admin.GET("/santri/:id/riwayat-kelas", kelasController.GetRiwayatKelasSantri)
+
+			// Absensi santri per sesi kelas
+			absensiController := controllers.NewAbsensiController(config.DB)
+			admin.GET("/absensi", absensiController.GetSesiAbsensi)
+			admin.GET("/absensi/sesi", absensiController.GetAllSesiAbsensi)
+			admin.GET("/absensi/rekap", absensiController.GetRekapAbsensi)
+			admin.GET("/absensi/peringatan-alpa", absensiController.GetPeringatanAlpa)
+			admin.POST("/absensi", absensiController.SimpanAbsensi)
+			admin.PUT("/absensi/:id", absensiController.UbahAbsensi)
+			admin.GET("/absensi/:id/koreksi", absensiController.GetKoreksiAbsensi)
+			admin.GET("/santri/:id/absensi", absensiController.GetRekapAbsensiSantri)
+
+			// Jurnal setoran dan perkembangan belajar santri
+			perkembanganController := controllers.NewPerkembanganController(config.DB)
+			admin.POST("/setoran", perkembanganController.CreateSetoran)
+			admin.DELETE("/setoran/:id", perkembanganController.DeleteSetoran)
+			admin.GET("/santri/:id/perkembangan", perkembanganController.GetPerkembanganSantri)
+			admin.GET("/perkembangan/distribusi", perkembanganController.GetDistribusiPerkembangan)
+
+			// Munaqosah kenaikan jilid
+			munaqosahController := controllers.NewMunaqosahController(config.DB)
+			admin.GET("/munaqosah", munaqosahController.GetAllMunaqosah)
+			admin.POST("/munaqosah", munaqosahController.CreateMunaqosah)
+			admin.PUT("/munaqosah/:id", munaqosahController.UpdateMunaqosah)
+			admin.PUT("/munaqosah/:id/batal", munaqosahController.BatalkanMunaqosah)
+			admin.PUT("/munaqosah/:id/nilai", munaqosahController.NilaiMunaqosah)
+			admin.GET("/santri/:id/munaqosah", perkembanganController.GetMunaqosahSantri)
+
+			// Raport semester per kelas
+			raportController := controllers.NewRaportController(config.DB)
+			admin.GET("/raport", raportController.GetRaportKelas)
+			admin.PUT("/raport", raportController.SimpanRaport)
+			admin.GET("/raport/kelas/:id/zip", raportController.DownloadRaportKelas)
+
+			imporController := controllers.NewImporController(config.DB)
+			admin.POST("/impor/santri", imporController.ImporSantri)
+			admin.GET("/impor/santri/template", imporController.GetTemplateImporSantri)
+
 			donasiController := controllers.NewDonasiController(config.GetDB())
 			admin.POST("/donasi", donasiController.CreateDonasi)
 			admin.GET("/donasi", donasiController.GetAllDonasi)
 			admin.GET("/donasi/summary", donasiController.GetDonasiSummary)
 			admin.GET("/donasi/by-date", donasiController.GetDonasiByDateRange)
+			admin.GET("/donasi/export", donasiController.ExportDonasi)
 			admin.GET("/donasi/:id", donasiController.GetDonasiByID)
 			admin.PUT("/donasi/:id", donasiController.UpdateDonasi)
 			admin.DELETE("/donasi/:id", donasiController.DeleteDonasi)
 
+			donaturController := controllers.NewDonaturController(config.DB)
+			admin.GET("/donatur", donaturController.GetAllDonatur)
+			admin.POST("/donatur", donaturController.CreateDonatur)
+			admin.POST("/donatur/tautkan", donaturController.TautkanDonasiLama)
+			admin.GET("/donatur/:id", donaturController.GetDonaturByID)
+			admin.PUT("/donatur/:id", donaturController.UpdateDonatur)
+			admin.DELETE("/donatur/:id", donaturController.DeleteDonatur)
+
+			kampanyeController := controllers.NewKampanyeController(config.DB)
+			admin.GET("/kampanye", kampanyeController.GetAllKampanye)
+			admin.POST("/kampanye", kampanyeController.CreateKampanye)
+			admin.GET("/kampanye/:id", kampanyeController.GetKampanyeByID)
+			admin.PUT("/kampanye/:id", kampanyeController.UpdateKampanye)
+			admin.DELETE("/kampanye/:id", kampanyeController.DeleteKampanye)
+
 			syahriahController := controllers.NewSyahriahController(config.DB)
+			tunggakanController := controllers.NewTunggakanController(config.DB)
 			admin.POST("/syahriah", syahriahController.CreateSyahriah)
 			admin.POST("/syahriah/batch", syahriahController.BatchCreateSyahriah)
+			admin.GET("/syahriah/tunggakan", tunggakanController.GetLaporanTunggakan)
+			admin.GET("/syahriah/export", syahriahController.ExportSyahriah)
+			admin.GET("/syahriah/otomatis", syahriahController.GetLaporanTagihanOtomatis)
+			admin.POST("/syahriah/otomatis/jalankan", syahriahController.JalankanTagihanOtomatis)
         	admin.PUT("/syahriah/:id", syahriahController.UpdateSyahriah)
         	admin.DELETE("/syahriah/:id", syahriahController.DeleteSyahriah)
 			admin.GET("/syahriah", syahriahController.GetAllSyahriah)
@@ -142,6 +284,23 @@ func SetupRoutes(r *gin.Engine) {
 			admin.GET("/syahriah/summary", syahriahController.GetSyahriahSummary)
 			admin.GET("/syahriah/:id", syahriahController.GetSyahriahByID)
 			admin.PUT("/syahriah/:id/bayar", syahriahController.BayarSyahriah)
+			admin.POST("/syahriah/:id/pembayaran", syahriahController.BayarSyahriah)
+			admin.GET("/syahriah/:id/pembayaran", syahriahController.GetPembayaranSyahriah)
+			admin.POST("/syahriah/:id/qris", syahriahController.CreateQRISSyahriah)
+			admin.POST("/syahriah/pembayaran-santri", syahriahController.BayarTagihanSantri)
+			admin.GET("/pembayaran-online", pembayaranOnlineController.GetAllPembayaranOnline)
+			admin.GET("/pembayaran-online/:id", pembayaranOnlineController.GetPembayaranOnlineByID)
+			// Simulasi pelunasan invoice penyedia palsu, hanya jika diaktifkan eksplisit untuk pengembangan
+			if services.SimulasiPembayaranAktif() {
+				admin.POST("/pembayaran-online/:id/simulasi", pembayaranOnlineController.SimulasiPembayaranOnline)
+			}
+			admin.DELETE("/syahriah/pembayaran/:id", syahriahController.DeletePembayaranSyahriah)
+
+			kwitansiController := controllers.NewKwitansiController(config.DB)
+			admin.GET("/kwitansi", kwitansiController.GetAllKwitansi)
+			admin.GET("/kwitansi/:id/pdf", kwitansiController.GetKwitansiPDF)
+			admin.GET("/syahriah/pembayaran/:id/kwitansi", kwitansiController.GetKwitansiPembayaranSyahriah)
+			admin.GET("/donasi/:id/kwitansi", kwitansiController.GetKwitansiDonasi)
 
 			pengumumanController := controllers.NewPengumumanController(config.DB)
 			admin.POST("/pengumuman", pengumumanController.CreatePengumuman)
@@ -155,10 +314,10 @@ func SetupRoutes(r *gin.Engine) {
 			admin.GET("/logs/:id", logController.GetLogAktivitasByID)
 
 			rekapController := controllers.NewRekapController(config.DB)
-			admin.POST("/rekap", rekapController.CreateRekap)
-			admin.PUT("/rekap/:id", rekapController.UpdateRekap)
-			admin.DELETE("/rekap/:id", rekapController.DeleteRekap)
 			admin.POST("/rekap/generate", rekapController.GenerateRekapOtomatis)
+			admin.POST("/rekap/rebuild", rekapController.RebuildRekap)
+			admin.GET("/rekap/perubahan", rekapController.GetPerubahanRekap)
+			admin.GET("/rekap/export", rekapController.ExportRekap)
 			admin.GET("/rekap", rekapController.GetAllRekap)
 			admin.GET("/rekap/summary", rekapController.GetRekapSummary)
 			admin.GET("/rekap/latest", rekapController.GetLatestRekap)
@@ -171,7 +330,79 @@ func SetupRoutes(r *gin.Engine) {
 			admin.PUT("/pemakaian/:id", pemakaianController.UpdatePemakaian)
 			admin.DELETE("/pemakaian/:id", pemakaianController.DeletePemakaian)
 			admin.GET("/pemakaian/summary", pemakaianController.GetPemakaianSummary)
+			admin.GET("/pemakaian/export", pemakaianController.ExportPemakaian)
 			admin.GET("/pemakaian/:id", pemakaianController.GetPemakaianByID)
+			admin.POST("/pemakaian/:id/setujui", pemakaianController.SetujuiPemakaian)
+			admin.POST("/pemakaian/:id/tolak", pemakaianController.TolakPemakaian)
+			admin.POST("/pemakaian/:id/cairkan", pemakaianController.CairkanPemakaian)
+
+			lampiranPemakaianController := controllers.NewLampiranPemakaianController(config.DB)
+			admin.GET("/pemakaian/:id/lampiran", lampiranPemakaianController.GetLampiran)
+			admin.POST("/pemakaian/:id/lampiran", lampiranPemakaianController.UploadLampiran)
+			admin.GET("/pemakaian/:id/lampiran/:id_lampiran", lampiranPemakaianController.UnduhLampiran)
+			admin.PUT("/pemakaian/:id/lampiran/:id_lampiran", lampiranPemakaianController.UpdateLampiran)
+			admin.DELETE("/pemakaian/:id/lampiran/:id_lampiran", lampiranPemakaianController.DeleteLampiran)
+
+			ambangPersetujuanController := controllers.NewAmbangPersetujuanController(config.DB)
+			admin.GET("/ambang-persetujuan", ambangPersetujuanController.GetAllAmbang)
+
+			// Dana (sumber dana terpisah), pengaturannya oleh super_admin
+			danaController := controllers.NewDanaController(config.DB)
+			admin.GET("/dana", danaController.GetAllDana)
+
+			// Rekening kas/bank, transfer antar rekening dan opname kas
+			rekeningController := controllers.NewRekeningController(config.DB)
+			admin.GET("/rekening", rekeningController.GetAllRekening)
+			admin.POST("/rekening", rekeningController.CreateRekening)
+			admin.PUT("/rekening/:id", rekeningController.UpdateRekening)
+			admin.DELETE("/rekening/:id", rekeningController.DeleteRekening)
+			admin.GET("/transfer-rekening", rekeningController.GetAllTransfer)
+			admin.POST("/transfer-rekening", rekeningController.CreateTransfer)
+			admin.DELETE("/transfer-rekening/:id", rekeningController.DeleteTransfer)
+			admin.GET("/posisi-kas", rekeningController.GetPosisiKas)
+			admin.GET("/opname-kas", rekeningController.GetAllOpname)
+			admin.POST("/opname-kas", rekeningController.CreateOpname)
+
+			anggaranController := controllers.NewAnggaranController(config.DB)
+			admin.GET("/anggaran", anggaranController.GetAllAnggaran)
+			admin.POST("/anggaran", anggaranController.CreateAnggaran)
+			admin.POST("/anggaran/salin", anggaranController.SalinAnggaran)
+			admin.GET("/anggaran/laporan", anggaranController.GetLaporanAnggaran)
+			admin.PUT("/anggaran/:id", anggaranController.UpdateAnggaran)
+			admin.DELETE("/anggaran/:id", anggaranController.DeleteAnggaran)
+			admin.GET("/anggaran/:id/cek", anggaranController.CekAnggaran)
+
+			jurnalController := controllers.NewJurnalController(config.DB)
+			admin.GET("/jurnal", jurnalController.GetAllJurnal)
+			admin.GET("/jurnal/akun", jurnalController.GetAllAkun)
+			admin.GET("/jurnal/neraca-saldo", jurnalController.GetNeracaSaldo)
+			admin.GET("/jurnal/:id", jurnalController.GetJurnalByID)
+			admin.POST("/jurnal/saldo-awal", jurnalController.CreateSaldoAwal)
+			admin.POST("/jurnal/backfill", jurnalController.BackfillJurnal)
+
+			rekonsiliasiController := controllers.NewRekonsiliasiController(config.DB)
+			admin.POST("/rekonsiliasi/impor", rekonsiliasiController.ImporMutasi)
+			admin.GET("/rekonsiliasi/impor", rekonsiliasiController.GetAllImporMutasi)
+			admin.GET("/rekonsiliasi/mutasi", rekonsiliasiController.GetAllMutasi)
+			admin.POST("/rekonsiliasi/mutasi/konfirmasi", rekonsiliasiController.KonfirmasiMassal)
+			admin.GET("/rekonsiliasi/mutasi/:id/kandidat", rekonsiliasiController.GetKandidatMutasi)
+			admin.POST("/rekonsiliasi/mutasi/:id/konfirmasi", rekonsiliasiController.KonfirmasiMutasi)
+			admin.POST("/rekonsiliasi/mutasi/:id/abaikan", rekonsiliasiController.AbaikanMutasi)
+
+			tutupBukuController := controllers.NewTutupBukuController(config.DB)
+			admin.GET("/tutup-buku", tutupBukuController.GetAllTutupBuku)
+			admin.POST("/tutup-buku", tutupBukuController.TutupBuku)
+
+			tarifController := controllers.NewTarifController(config.DB)
+			admin.GET("/tarif", tarifController.GetAllTarif)
+			admin.POST("/tarif", tarifController.CreateTarif)
+			admin.GET("/tarif/hitung", tarifController.HitungTarif)
+			admin.PUT("/tarif/:id", tarifController.UpdateTarif)
+			admin.DELETE("/tarif/:id", tarifController.DeleteTarif)
+			admin.GET("/keringanan", tarifController.GetAllKeringanan)
+			admin.POST("/keringanan", tarifController.CreateKeringanan)
+			admin.PUT("/keringanan/:id", tarifController.UpdateKeringanan)
+			admin.DELETE("/keringanan/:id", tarifController.DeleteKeringanan)
 		}
 
 		// Hanya untuk super-admin
@@ -230,6 +461,40 @@ func SetupRoutes(r *gin.Engine) {
 			superAdmin.PUT("/testimoni/:id/show", testimoniController.ShowTestimoni)
 			superAdmin.PUT("/testimoni/:id/hide", testimoniController.HideTestimoni)
 			superAdmin.DELETE("/testimoni/:id", testimoniController.DeleteTestimoni)
+
+			tutupBukuController := controllers.NewTutupBukuController(config.DB)
+			superAdmin.GET("/tutup-buku", tutupBukuController.GetAllTutupBuku)
+			superAdmin.PUT("/tutup-buku/:periode/buka", tutupBukuController.BukaBuku)
+
+			// Persetujuan pemakaian saldo di atas ambang super_admin
+			pemakaianController := controllers.NewPemakaianSaldoController(config.DB)
+			superAdmin.GET("/pemakaian", pemakaianController.GetAllPemakaian)
+			superAdmin.GET("/pemakaian/:id", pemakaianController.GetPemakaianByID)
+			superAdmin.POST("/pemakaian/:id/setujui", pemakaianController.SetujuiPemakaian)
+			superAdmin.POST("/pemakaian/:id/tolak", pemakaianController.TolakPemakaian)
+
+			lampiranPemakaianController := controllers.NewLampiranPemakaianController(config.DB)
+			superAdmin.GET("/pemakaian/:id/lampiran", lampiranPemakaianController.GetLampiran)
+			superAdmin.GET("/pemakaian/:id/lampiran/:id_lampiran", lampiranPemakaianController.UnduhLampiran)
+
+			ambangPersetujuanController := controllers.NewAmbangPersetujuanController(config.DB)
+			superAdmin.GET("/ambang-persetujuan", ambangPersetujuanController.GetAllAmbang)
+			superAdmin.POST("/ambang-persetujuan", ambangPersetujuanController.CreateAmbang)
+			superAdmin.PUT("/ambang-persetujuan/:id", ambangPersetujuanController.UpdateAmbang)
+			superAdmin.DELETE("/ambang-persetujuan/:id", ambangPersetujuanController.DeleteAmbang)
+
+			// Pengaturan dana
+			danaController := controllers.NewDanaController(config.DB)
+			superAdmin.GET("/dana", danaController.GetAllDana)
+			superAdmin.POST("/dana", danaController.CreateDana)
+			superAdmin.PUT("/dana/:kode", danaController.UpdateDana)
+			superAdmin.DELETE("/dana/:kode", danaController.DeleteDana)
+
+			// Pemantauan rekening dan posisi kas
+			rekeningController := controllers.NewRekeningController(config.DB)
+			superAdmin.GET("/rekening", rekeningController.GetAllRekening)
+			superAdmin.GET("/posisi-kas", rekeningController.GetPosisiKas)
+			superAdmin.GET("/opname-kas", rekeningController.GetAllOpname)
 		}
 	}
 }
\ No newline at end of file
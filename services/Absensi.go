@@ -0,0 +1,332 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrBukanAnggotaKelas dikembalikan jika absensi dicatat untuk santri yang bukan anggota kelas pada tanggal tersebut
+var ErrBukanAnggotaKelas = errors.New("santri bukan anggota kelas pada tanggal tersebut")
+
+// IsiAbsensi adalah status kehadiran satu santri yang akan disimpan
+type IsiAbsensi struct {
+	IDSantri   string
+	Status     models.StatusAbsensi
+	Keterangan string
+}
+
+// RekapAbsensi adalah jumlah kehadiran satu santri dalam satu rentang waktu
+type RekapAbsensi struct {
+	IDSantri    string  `json:"id_santri"`
+	Periode     string  `json:"periode,omitempty"`
+	Hadir       int     `json:"hadir"`
+	Izin        int     `json:"izin"`
+	Sakit       int     `json:"sakit"`
+	Alpa        int     `json:"alpa"`
+	Total       int     `json:"total"`
+	PersenHadir float64 `json:"persen_hadir"`
+}
+
+// PeringatanAlpa adalah santri aktif yang sering alpa dalam rentang waktu tertentu
+type PeringatanAlpa struct {
+	Santri       models.Santri `json:"santri"`
+	JumlahAlpa   int           `json:"jumlah_alpa"`
+	AlpaBeruntun int           `json:"alpa_beruntun"` // alpa berturut-turut di sesi terakhir
+	AlpaTerakhir time.Time     `json:"alpa_terakhir"`
+	JumlahSesi   int           `json:"jumlah_sesi"`
+}
+
+// ValidStatusAbsensi mengecek status absensi
+func ValidStatusAbsensi(status models.StatusAbsensi) bool {
+	switch status {
+	case models.AbsensiHadir, models.AbsensiIzin, models.AbsensiSakit, models.AbsensiAlpa:
+		return true
+	}
+	return false
+}
+
+// AnggotaKelasPada mengambil ID santri yang menjadi anggota kelas pada tanggal tertentu
+func AnggotaKelasPada(db *gorm.DB, idKelas string, tanggal time.Time) ([]string, error) {
+	var idSantri []string
+	err := db.Model(&models.AnggotaKelas{}).
+		Where("id_kelas = ? AND tanggal_masuk <= ? AND (tanggal_keluar IS NULL OR tanggal_keluar >= ?)", idKelas, tanggal, tanggal).
+		Distinct().
+		Pluck("id_santri", &idSantri).Error
+	return idSantri, err
+}
+
+// SimpanAbsensi menyimpan absensi satu sesi kelas sekaligus. Sesi dibuat jika belum ada.
+// statusDefault (jika diisi) dipakai untuk anggota kelas yang tidak disebut dan belum punya absensi,
+// sehingga satu kelas bisa diisi "hadir semua" lalu hanya pengecualiannya yang dikirim.
+// Absensi yang sudah tersimpan dan berubah dicatat sebagai koreksi dengan alasan yang diberikan.
+func SimpanAbsensi(db *gorm.DB, idKelas string, tanggal time.Time, catatan *string, isi []IsiAbsensi, statusDefault models.StatusAbsensi, userID, alasan string) (*models.SesiAbsensi, int, error) {
+	var sesi models.SesiAbsensi
+	jumlahKoreksi := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		anggota, err := AnggotaKelasPada(tx, idKelas, tanggal)
+		if err != nil {
+			return err
+		}
+		anggotaKelas := make(map[string]bool, len(anggota))
+		for _, id := range anggota {
+			anggotaKelas[id] = true
+		}
+
+		err = tx.Where("id_kelas = ? AND tanggal = ?", idKelas, tanggal).First(&sesi).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sesi = models.SesiAbsensi{
+				IDSesi:      uuid.New().String(),
+				IDKelas:     idKelas,
+				Tanggal:     tanggal,
+				DicatatOleh: userID,
+			}
+			if catatan != nil {
+				sesi.Catatan = *catatan
+			}
+			if err := tx.Create(&sesi).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else if catatan != nil && *catatan != sesi.Catatan {
+			if err := tx.Model(&sesi).Update("catatan", *catatan).Error; err != nil {
+				return err
+			}
+		}
+
+		var lama []models.Absensi
+		if err := tx.Where("id_sesi = ?", sesi.IDSesi).Find(&lama).Error; err != nil {
+			return err
+		}
+		tersimpan := make(map[string]*models.Absensi, len(lama))
+		for i := range lama {
+			tersimpan[lama[i].IDSantri] = &lama[i]
+		}
+
+		disebut := make(map[string]bool, len(isi))
+		for _, a := range isi {
+			if !anggotaKelas[a.IDSantri] {
+				return fmt.Errorf("%w: %s", ErrBukanAnggotaKelas, a.IDSantri)
+			}
+			disebut[a.IDSantri] = true
+		}
+		if statusDefault != "" {
+			for _, id := range anggota {
+				if !disebut[id] && tersimpan[id] == nil {
+					isi = append(isi, IsiAbsensi{IDSantri: id, Status: statusDefault})
+				}
+			}
+		}
+
+		for _, a := range isi {
+			if lama := tersimpan[a.IDSantri]; lama != nil {
+				berubah, err := ubahAbsensi(tx, lama, a.Status, a.Keterangan, alasan, userID)
+				if err != nil {
+					return err
+				}
+				if berubah {
+					jumlahKoreksi++
+				}
+				continue
+			}
+			absensi := models.Absensi{
+				IDAbsensi:   uuid.New().String(),
+				IDSesi:      sesi.IDSesi,
+				IDSantri:    a.IDSantri,
+				Status:      a.Status,
+				Keterangan:  a.Keterangan,
+				DicatatOleh: userID,
+			}
+			if err := tx.Create(&absensi).Error; err != nil {
+				return err
+			}
+			tersimpan[a.IDSantri] = &absensi
+		}
+		return nil
+	})
+	return &sesi, jumlahKoreksi, err
+}
+
+// UbahAbsensi mengoreksi satu absensi dan mencatat riwayat koreksinya
+func UbahAbsensi(db *gorm.DB, absensi *models.Absensi, status models.StatusAbsensi, keterangan, alasan, userID string) (bool, error) {
+	var berubah bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		berubah, err = ubahAbsensi(tx, absensi, status, keterangan, alasan, userID)
+		return err
+	})
+	return berubah, err
+}
+
+func ubahAbsensi(tx *gorm.DB, absensi *models.Absensi, status models.StatusAbsensi, keterangan, alasan, userID string) (bool, error) {
+	if absensi.Status == status && absensi.Keterangan == keterangan {
+		return false, nil
+	}
+	koreksi := models.KoreksiAbsensi{
+		IDKoreksi:      uuid.New().String(),
+		IDAbsensi:      absensi.IDAbsensi,
+		StatusLama:     absensi.Status,
+		StatusBaru:     status,
+		KeteranganLama: absensi.Keterangan,
+		KeteranganBaru: keterangan,
+		Alasan:         alasan,
+		DikoreksiOleh:  userID,
+	}
+	if err := tx.Create(&koreksi).Error; err != nil {
+		return false, err
+	}
+	absensi.Status = status
+	absensi.Keterangan = keterangan
+	return true, tx.Model(absensi).Updates(map[string]interface{}{
+		"status":     status,
+		"keterangan": keterangan,
+	}).Error
+}
+
+type barisAbsensi struct {
+	IDSantri string
+	Tanggal  time.Time
+	Status   models.StatusAbsensi
+}
+
+// muatAbsensi mengambil absensi santri dalam rentang [dari, sampai), urut dari tanggal terbaru.
+// idKelas kosong berarti semua kelas, idSantri nil berarti semua santri.
+func muatAbsensi(db *gorm.DB, idKelas string, idSantri []string, dari, sampai time.Time) ([]barisAbsensi, error) {
+	var rows []barisAbsensi
+	query := db.Table("absensi").
+		Select("absensi.id_santri, sesi_absensi.tanggal, absensi.status").
+		Joins("JOIN sesi_absensi ON sesi_absensi.id_sesi = absensi.id_sesi").
+		Where("sesi_absensi.tanggal >= ? AND sesi_absensi.tanggal < ?", dari, sampai)
+	if idKelas != "" {
+		query = query.Where("sesi_absensi.id_kelas = ?", idKelas)
+	}
+	if idSantri != nil {
+		if len(idSantri) == 0 {
+			return rows, nil
+		}
+		query = query.Where("absensi.id_santri IN ?", idSantri)
+	}
+	err := query.Order("absensi.id_santri, sesi_absensi.tanggal DESC").Scan(&rows).Error
+	return rows, err
+}
+
+func (r *RekapAbsensi) tambah(status models.StatusAbsensi) {
+	switch status {
+	case models.AbsensiHadir:
+		r.Hadir++
+	case models.AbsensiIzin:
+		r.Izin++
+	case models.AbsensiSakit:
+		r.Sakit++
+	case models.AbsensiAlpa:
+		r.Alpa++
+	}
+	r.Total++
+	r.PersenHadir = float64(r.Hadir) / float64(r.Total) * 100
+}
+
+// HitungRekapAbsensi menghitung rekap kehadiran setiap santri dalam rentang [dari, sampai).
+// idKelas kosong berarti sesi semua kelas, idSantri nil berarti semua santri yang punya absensi.
+func HitungRekapAbsensi(db *gorm.DB, idKelas string, idSantri []string, dari, sampai time.Time) (map[string]*RekapAbsensi, error) {
+	rows, err := muatAbsensi(db, idKelas, idSantri, dari, sampai)
+	if err != nil {
+		return nil, err
+	}
+	hasil := make(map[string]*RekapAbsensi)
+	for _, id := range idSantri {
+		hasil[id] = &RekapAbsensi{IDSantri: id}
+	}
+	for _, r := range rows {
+		if hasil[r.IDSantri] == nil {
+			hasil[r.IDSantri] = &RekapAbsensi{IDSantri: r.IDSantri}
+		}
+		hasil[r.IDSantri].tambah(r.Status)
+	}
+	return hasil, nil
+}
+
+// RekapAbsensiBulanan menghitung rekap kehadiran satu santri untuk setiap bulan dalam satu tahun
+func RekapAbsensiBulanan(db *gorm.DB, idSantri string, tahun int) ([]RekapAbsensi, error) {
+	dari := time.Date(tahun, time.January, 1, 0, 0, 0, 0, time.Local)
+	rows, err := muatAbsensi(db, "", []string{idSantri}, dari, dari.AddDate(1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+	hasil := make([]RekapAbsensi, 12)
+	for i := range hasil {
+		hasil[i] = RekapAbsensi{IDSantri: idSantri, Periode: dari.AddDate(0, i, 0).Format("2006-01")}
+	}
+	for _, r := range rows {
+		hasil[r.Tanggal.Month()-1].tambah(r.Status)
+	}
+	return hasil, nil
+}
+
+// DaftarPeringatanAlpa mencari santri aktif dengan alpa minimal ambang kali sejak tanggal tertentu,
+// diurutkan dari alpa berturut-turut terbanyak
+func DaftarPeringatanAlpa(db *gorm.DB, sejak time.Time, ambang int) ([]PeringatanAlpa, error) {
+	var aktif []string
+	if err := db.Model(&models.Santri{}).Where("status = ?", models.StatusAktifSantri).Pluck("id_santri", &aktif).Error; err != nil {
+		return nil, err
+	}
+	rows, err := muatAbsensi(db, "", aktif, sejak, time.Now().AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	perSantri := make(map[string]*PeringatanAlpa)
+	beruntunSelesai := make(map[string]bool)
+	for _, r := range rows {
+		p := perSantri[r.IDSantri]
+		if p == nil {
+			p = &PeringatanAlpa{}
+			perSantri[r.IDSantri] = p
+		}
+		p.JumlahSesi++
+		if r.Status != models.AbsensiAlpa {
+			beruntunSelesai[r.IDSantri] = true
+			continue
+		}
+		p.JumlahAlpa++
+		if p.AlpaTerakhir.IsZero() {
+			p.AlpaTerakhir = r.Tanggal
+		}
+		if !beruntunSelesai[r.IDSantri] {
+			p.AlpaBeruntun++
+		}
+	}
+
+	var idPeringatan []string
+	for id, p := range perSantri {
+		if p.JumlahAlpa >= ambang {
+			idPeringatan = append(idPeringatan, id)
+		}
+	}
+	if len(idPeringatan) == 0 {
+		return []PeringatanAlpa{}, nil
+	}
+	var santri []models.Santri
+	if err := db.Preload("Wali").Where("id_santri IN ?", idPeringatan).Find(&santri).Error; err != nil {
+		return nil, err
+	}
+
+	hasil := make([]PeringatanAlpa, len(santri))
+	for i, s := range santri {
+		hasil[i] = *perSantri[s.IDSantri]
+		hasil[i].Santri = s
+	}
+	sort.Slice(hasil, func(i, j int) bool {
+		if hasil[i].AlpaBeruntun != hasil[j].AlpaBeruntun {
+			return hasil[i].AlpaBeruntun > hasil[j].AlpaBeruntun
+		}
+		return hasil[i].JumlahAlpa > hasil[j].JumlahAlpa
+	})
+	return hasil, nil
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"tpq_asysyafii/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrAnggaranTidakSesuai dikembalikan jika pemakaian ditautkan ke pos anggaran dengan tipe atau periode yang berbeda
+var ErrAnggaranTidakSesuai = errors.New("pos anggaran tidak sesuai dengan pemakaian")
+
+// Status realisasi pos anggaran
+const (
+	AnggaranAman     = "aman"
+	AnggaranHampir   = "hampir_habis" // realisasi minimal persenHampirHabis dari anggaran
+	AnggaranMelebihi = "melebihi"
+
+	persenHampirHabis = 90
+)
+
+// StatusAnggaran adalah posisi satu pos anggaran jika pengajuan pemakaian baru ikut dibebankan
+type StatusAnggaran struct {
+	IDAnggaran  string  `json:"id_anggaran"`
+	SubKategori string  `json:"sub_kategori"`
+	Anggaran    float64 `json:"anggaran"`
+	Realisasi   float64 `json:"realisasi"` // sebelum pengajuan
+	Pengajuan   float64 `json:"pengajuan"`
+	Sisa        float64 `json:"sisa"` // anggaran - realisasi - pengajuan, negatif jika melebihi
+	Melebihi    bool    `json:"melebihi"`
+	Pesan       string  `json:"pesan,omitempty"`
+}
+
+// BarisAnggaran adalah perbandingan anggaran dan realisasi satu pos
+type BarisAnggaran struct {
+	models.Anggaran
+	Realisasi float64 `json:"realisasi"`
+	Selisih   float64 `json:"selisih"` // anggaran - realisasi, negatif jika melebihi
+	Persen    float64 `json:"persen"`
+	Status    string  `json:"status"`
+}
+
+// RingkasanTipeAnggaran merangkum anggaran dan realisasi per TipePemakaian.
+// TanpaAnggaran adalah pemakaian tipe tersebut yang tidak ditautkan ke pos mana pun.
+type RingkasanTipeAnggaran struct {
+	TipePemakaian models.TipePemakaian `json:"tipe_pemakaian"`
+	Anggaran      float64              `json:"anggaran"`
+	Realisasi     float64              `json:"realisasi"`
+	TanpaAnggaran float64              `json:"tanpa_anggaran"`
+	Selisih       float64              `json:"selisih"` // anggaran - (realisasi + tanpa anggaran)
+}
+
+// LaporanAnggaran adalah laporan anggaran vs realisasi satu tahun atau satu bulan
+type LaporanAnggaran struct {
+	Tahun          int                     `json:"tahun"`
+	Bulan          int                     `json:"bulan"`
+	Pos            []BarisAnggaran         `json:"pos"`
+	PerTipe        []RingkasanTipeAnggaran `json:"per_tipe"`
+	TotalAnggaran  float64                 `json:"total_anggaran"`
+	TotalRealisasi float64                 `json:"total_realisasi"` // termasuk pemakaian tanpa anggaran
+	TotalSelisih   float64                 `json:"total_selisih"`
+}
+
+// cekPosAnggaran memastikan pos anggaran pemakaian ada, bertipe sama dan periodenya mencakup tanggal pemakaian
+func cekPosAnggaran(tx *gorm.DB, pemakaian models.PemakaianSaldo) error {
+	if pemakaian.IDAnggaran == nil {
+		return nil
+	}
+	var anggaran models.Anggaran
+	if err := tx.Where("id_anggaran = ?", *pemakaian.IDAnggaran).First(&anggaran).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: pos anggaran tidak ditemukan", ErrAnggaranTidakSesuai)
+		}
+		return err
+	}
+	if anggaran.TipePemakaian != pemakaian.TipePemakaian {
+		return fmt.Errorf("%w: pos %s untuk pemakaian %s", ErrAnggaranTidakSesuai, anggaran.SubKategori, anggaran.TipePemakaian)
+	}
+	tanggal := tanggalPemakaian(pemakaian)
+	if tanggal.Year() != anggaran.Tahun || (anggaran.Bulan > 0 && int(tanggal.Month()) != anggaran.Bulan) {
+		return fmt.Errorf("%w: pos %s tidak berlaku pada %s", ErrAnggaranTidakSesuai, anggaran.SubKategori, tanggal.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// RealisasiAnggaran menjumlahkan pemakaian yang sudah dicairkan pada setiap pos anggaran.
+// Pemakaian dengan id kecualiPemakaian tidak dihitung.
+func RealisasiAnggaran(db *gorm.DB, ids []string, kecualiPemakaian string) (map[string]float64, error) {
+	hasil := map[string]float64{}
+	if len(ids) == 0 {
+		return hasil, nil
+	}
+	var baris []struct {
+		IDAnggaran string
+		Total      float64
+	}
+	query := db.Model(&models.PemakaianSaldo{}).
+		Select("id_anggaran, COALESCE(SUM(nominal_total), 0) AS total").
+		Where("id_anggaran IN ? AND status = ?", ids, models.PemakaianDicairkan)
+	if kecualiPemakaian != "" {
+		query = query.Where("id_pemakaian <> ?", kecualiPemakaian)
+	}
+	if err := query.Group("id_anggaran").Scan(&baris).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range baris {
+		hasil[b.IDAnggaran] = b.Total
+	}
+	return hasil, nil
+}
+
+// CekAnggaran menghitung posisi pos anggaran jika pengajuan sebesar nominal ikut dibebankan.
+// kecualiPemakaian diisi saat mengubah pemakaian agar nominal lamanya tidak terhitung dua kali.
+func CekAnggaran(db *gorm.DB, idAnggaran string, nominal float64, kecualiPemakaian string) (*StatusAnggaran, error) {
+	var anggaran models.Anggaran
+	if err := db.Where("id_anggaran = ?", idAnggaran).First(&anggaran).Error; err != nil {
+		return nil, err
+	}
+	realisasi, err := RealisasiAnggaran(db, []string{idAnggaran}, kecualiPemakaian)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &StatusAnggaran{
+		IDAnggaran:  anggaran.IDAnggaran,
+		SubKategori: anggaran.SubKategori,
+		Anggaran:    anggaran.Nominal,
+		Realisasi:   realisasi[idAnggaran],
+		Pengajuan:   nominal,
+	}
+	status.Sisa = status.Anggaran - status.Realisasi - status.Pengajuan
+	if status.Sisa < -0.005 {
+		status.Melebihi = true
+		status.Pesan = fmt.Sprintf("Pemakaian melebihi anggaran %s sebesar Rp %.0f", anggaran.SubKategori, -status.Sisa)
+	}
+	return status, nil
+}
+
+// HitungLaporanAnggaran membandingkan anggaran dan realisasi. bulan 0 = seluruh pos tahun itu
+// (bulanan dan tahunan); bulan 1-12 = hanya pos bulan tersebut beserta pemakaian bulan itu.
+func HitungLaporanAnggaran(db *gorm.DB, tahun, bulan int) (*LaporanAnggaran, error) {
+	var pos []models.Anggaran
+	query := db.Where("tahun = ?", tahun)
+	if bulan > 0 {
+		query = query.Where("bulan = ?", bulan)
+	}
+	if err := query.Order("tipe_pemakaian ASC, sub_kategori ASC, bulan ASC").Find(&pos).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(pos))
+	for i, p := range pos {
+		ids[i] = p.IDAnggaran
+	}
+	realisasi, err := RealisasiAnggaran(db, ids, "")
+	if err != nil {
+		return nil, err
+	}
+
+	laporan := &LaporanAnggaran{Tahun: tahun, Bulan: bulan, Pos: make([]BarisAnggaran, len(pos))}
+	perTipe := map[models.TipePemakaian]*RingkasanTipeAnggaran{}
+	urutanTipe := []models.TipePemakaian{models.PemakaianOperasional, models.PemakaianInvestasi, models.PemakaianLainnya}
+	for _, tipe := range urutanTipe {
+		perTipe[tipe] = &RingkasanTipeAnggaran{TipePemakaian: tipe}
+	}
+
+	for i, p := range pos {
+		baris := BarisAnggaran{Anggaran: p, Realisasi: realisasi[p.IDAnggaran]}
+		baris.Selisih = p.Nominal - baris.Realisasi
+		baris.Status = AnggaranAman
+		if p.Nominal > 0 {
+			baris.Persen = math.Round(baris.Realisasi/p.Nominal*1000) / 10
+		}
+		switch {
+		case baris.Selisih < -0.005:
+			baris.Status = AnggaranMelebihi
+		case baris.Persen >= persenHampirHabis:
+			baris.Status = AnggaranHampir
+		}
+		laporan.Pos[i] = baris
+
+		if t := perTipe[p.TipePemakaian]; t != nil {
+			t.Anggaran += p.Nominal
+			t.Realisasi += baris.Realisasi
+		}
+	}
+
+	// Pemakaian pada periode laporan yang belum ditautkan ke pos anggaran
+	var tanpa []struct {
+		TipePemakaian models.TipePemakaian
+		Total         float64
+	}
+	tanpaQuery := db.Model(&models.PemakaianSaldo{}).
+		Select("tipe_pemakaian, COALESCE(SUM(nominal_total), 0) AS total").
+		Where("id_anggaran IS NULL AND status = ? AND YEAR(COALESCE(tanggal_pemakaian, created_at)) = ?", models.PemakaianDicairkan, tahun)
+	if bulan > 0 {
+		tanpaQuery = tanpaQuery.Where("MONTH(COALESCE(tanggal_pemakaian, created_at)) = ?", bulan)
+	}
+	if err := tanpaQuery.Group("tipe_pemakaian").Scan(&tanpa).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range tanpa {
+		if r := perTipe[t.TipePemakaian]; r != nil {
+			r.TanpaAnggaran = t.Total
+		}
+	}
+
+	for _, tipe := range urutanTipe {
+		r := perTipe[tipe]
+		r.Selisih = r.Anggaran - r.Realisasi - r.TanpaAnggaran
+		laporan.PerTipe = append(laporan.PerTipe, *r)
+		laporan.TotalAnggaran += r.Anggaran
+		laporan.TotalRealisasi += r.Realisasi + r.TanpaAnggaran
+	}
+	laporan.TotalSelisih = laporan.TotalAnggaran - laporan.TotalRealisasi
+	return laporan, nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"tpq_asysyafii/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrDanaTidakValid dikembalikan jika kode dana tidak dikenal atau dana sudah dinonaktifkan
+	ErrDanaTidakValid = errors.New("dana tidak ditemukan atau tidak aktif")
+	// ErrDanaDibatasi dikembalikan jika dana dipakai untuk tipe pemakaian yang tidak diizinkan, misalnya zakat untuk operasional
+	ErrDanaDibatasi = errors.New("dana tidak boleh dipakai untuk tipe pemakaian ini")
+)
+
+// DaftarDana mengambil semua dana urut tampilan. aktifSaja false juga mengembalikan dana nonaktif,
+// yang tetap perlu dilaporkan selama masih punya saldo atau riwayat.
+func DaftarDana(db *gorm.DB, aktifSaja bool) ([]models.Dana, error) {
+	var dana []models.Dana
+	query := db.Order("urutan ASC, kode_dana ASC")
+	if aktifSaja {
+		query = query.Where("aktif = ?", true)
+	}
+	return dana, query.Find(&dana).Error
+}
+
+// ambilDana mengambil satu dana. aktifSaja dipakai untuk transaksi baru.
+func ambilDana(db *gorm.DB, kodeDana string, aktifSaja bool) (*models.Dana, error) {
+	var dana models.Dana
+	query := db.Where("kode_dana = ?", kodeDana)
+	if aktifSaja {
+		query = query.Where("aktif = ?", true)
+	}
+	if err := query.First(&dana).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrDanaTidakValid, kodeDana)
+		}
+		return nil, err
+	}
+	return &dana, nil
+}
+
+// CekDanaPenerimaan memastikan dana boleh menerima donasi. Dana syahriah hanya diisi dari pembayaran syahriah.
+func CekDanaPenerimaan(db *gorm.DB, kodeDana string) error {
+	if kodeDana == models.KodeDanaSyahriah {
+		return fmt.Errorf("%w: dana syahriah hanya menerima pembayaran syahriah", ErrDanaTidakValid)
+	}
+	_, err := ambilDana(db, kodeDana, true)
+	return err
+}
+
+// BuatDana menyimpan dana baru beserta akun kas dan akun pendapatannya
+func BuatDana(tx *gorm.DB, dana *models.Dana) error {
+	if dana.KodeAkunKas == "" {
+		dana.KodeAkunKas = "kas_" + dana.KodeDana
+	}
+	if dana.KodeAkunPendapatan == "" {
+		dana.KodeAkunPendapatan = "pendapatan_" + dana.KodeDana
+	}
+	akun := []models.Akun{
+		{KodeAkun: dana.KodeAkunKas, NamaAkun: "Kas " + dana.NamaDana, Tipe: models.AkunAset},
+		{KodeAkun: dana.KodeAkunPendapatan, NamaAkun: "Pendapatan " + dana.NamaDana, Tipe: models.AkunPendapatan},
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&akun).Error; err != nil {
+		return err
+	}
+	return tx.Create(dana).Error
+}
+
+// SeedDanaDefault membuat dana bawaan yang belum ada. Dana yang sudah ada tidak diubah
+// agar pengaturan pembatasan dari admin tidak tertimpa. Aman dijalankan berulang.
+func SeedDanaDefault(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, d := range models.DaftarDanaDefault {
+			var jumlah int64
+			if err := tx.Model(&models.Dana{}).Where("kode_dana = ?", d.KodeDana).Count(&jumlah).Error; err != nil {
+				return err
+			}
+			if jumlah > 0 {
+				continue
+			}
+			dana := d
+			if err := BuatDana(tx, &dana); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TipeDiizinkan mengurai PemakaianDiizinkan. Hasil kosong berarti semua tipe pemakaian diizinkan.
+func TipeDiizinkan(dana models.Dana) []models.TipePemakaian {
+	var tipe []models.TipePemakaian
+	for _, t := range strings.Split(dana.PemakaianDiizinkan, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tipe = append(tipe, models.TipePemakaian(t))
+		}
+	}
+	return tipe
+}
+
+// DanaMengizinkan mengecek apakah dana boleh dipakai untuk tipe pemakaian tersebut
+func DanaMengizinkan(dana models.Dana, tipe models.TipePemakaian) bool {
+	diizinkan := TipeDiizinkan(dana)
+	if len(diizinkan) == 0 {
+		return true
+	}
+	for _, t := range diizinkan {
+		if t == tipe {
+			return true
+		}
+	}
+	return false
+}
+
+// RapikanRincian menggabungkan porsi dana yang sama, membuang porsi nol, lalu mengisi
+// NominalSyahriah, NominalDonasi dan NominalTotal dari rincian
+func RapikanRincian(pemakaian *models.PemakaianSaldo) {
+	perDana := map[string]float64{}
+	for _, r := range pemakaian.Rincian {
+		perDana[r.KodeDana] += r.Nominal
+	}
+
+	rincian := make([]models.PemakaianDana, 0, len(perDana))
+	pemakaian.NominalSyahriah, pemakaian.NominalDonasi, pemakaian.NominalTotal = 0, 0, 0
+	for kode, nominal := range perDana {
+		if math.Abs(nominal) < 0.005 {
+			continue
+		}
+		rincian = append(rincian, models.PemakaianDana{IDPemakaian: pemakaian.IDPemakaian, KodeDana: kode, Nominal: nominal})
+		pemakaian.NominalTotal += nominal
+		switch kode {
+		case models.KodeDanaSyahriah:
+			pemakaian.NominalSyahriah = nominal
+		case models.KodeDanaDonasi:
+			pemakaian.NominalDonasi = nominal
+		}
+	}
+	sort.Slice(rincian, func(i, j int) bool { return rincian[i].KodeDana < rincian[j].KodeDana })
+	pemakaian.Rincian = rincian
+}
+
+// nominalPerDana mengubah rincian pemakaian menjadi peta kode dana -> nominal
+func nominalPerDana(pemakaian models.PemakaianSaldo) map[string]float64 {
+	hasil := map[string]float64{}
+	for _, r := range pemakaian.Rincian {
+		hasil[r.KodeDana] += r.Nominal
+	}
+	return hasil
+}
+
+// muatRincian mengisi rincian dana pemakaian dari database
+func muatRincian(tx *gorm.DB, pemakaian *models.PemakaianSaldo) error {
+	return tx.Where("id_pemakaian = ?", pemakaian.IDPemakaian).Order("kode_dana ASC").Find(&pemakaian.Rincian).Error
+}
+
+// simpanRincian mengganti rincian dana pemakaian di database dengan rincian saat ini
+func simpanRincian(tx *gorm.DB, pemakaian models.PemakaianSaldo) error {
+	if err := tx.Where("id_pemakaian = ?", pemakaian.IDPemakaian).Delete(&models.PemakaianDana{}).Error; err != nil {
+		return err
+	}
+	if len(pemakaian.Rincian) == 0 {
+		return nil
+	}
+	return tx.Omit("Dana").Create(&pemakaian.Rincian).Error
+}
+
+// cekPembatasanDana memastikan setiap dana pada rincian masih aktif dan boleh dipakai untuk tipe pemakaiannya
+func cekPembatasanDana(tx *gorm.DB, pemakaian models.PemakaianSaldo) error {
+	for _, r := range pemakaian.Rincian {
+		dana, err := ambilDana(tx, r.KodeDana, true)
+		if err != nil {
+			return err
+		}
+		if !DanaMengizinkan(*dana, pemakaian.TipePemakaian) {
+			return fmt.Errorf("%w: dana %s hanya untuk pemakaian %s", ErrDanaDibatasi, dana.NamaDana, strings.ReplaceAll(dana.PemakaianDiizinkan, ",", ", "))
+		}
+	}
+	return nil
+}
+
+// MigrasiRincianPemakaian membuat rincian dana untuk pemakaian dari sebelum adanya multi-dana,
+// berdasarkan kolom NominalSyahriah dan NominalDonasi. Jurnal tidak berubah. Aman dijalankan berulang.
+func MigrasiRincianPemakaian(db *gorm.DB) (int, error) {
+	var lama []models.PemakaianSaldo
+	if err := db.Where("NOT EXISTS (SELECT 1 FROM pemakaian_dana WHERE pemakaian_dana.id_pemakaian = pemakaian_saldo.id_pemakaian)").
+		Find(&lama).Error; err != nil {
+		return 0, err
+	}
+
+	var rincian []models.PemakaianDana
+	for _, p := range lama {
+		if p.NominalSyahriah > 0 {
+			rincian = append(rincian, models.PemakaianDana{IDPemakaian: p.IDPemakaian, KodeDana: models.KodeDanaSyahriah, Nominal: p.NominalSyahriah})
+		}
+		if p.NominalDonasi > 0 {
+			rincian = append(rincian, models.PemakaianDana{IDPemakaian: p.IDPemakaian, KodeDana: models.KodeDanaDonasi, Nominal: p.NominalDonasi})
+		}
+	}
+	if len(rincian) == 0 {
+		return 0, nil
+	}
+	if err := db.Omit("Dana").CreateInBatches(&rincian, 200).Error; err != nil {
+		return 0, err
+	}
+	return len(lama), nil
+}
+
+// SaldoDana menghitung saldo kas berjalan setiap dana dari jurnal
+func SaldoDana(db *gorm.DB) (map[string]float64, error) {
+	dana, err := DaftarDana(db, false)
+	if err != nil {
+		return nil, err
+	}
+	akunKas := make([]string, len(dana))
+	kodeDana := make(map[string]string, len(dana))
+	for i, d := range dana {
+		akunKas[i] = d.KodeAkunKas
+		kodeDana[d.KodeAkunKas] = d.KodeDana
+	}
+
+	var saldo []saldoKas
+	if err := db.Model(&models.JurnalDetail{}).
+		Select("kode_akun, COALESCE(SUM(debit - kredit), 0) AS saldo").
+		Where("kode_akun IN ?", akunKas).
+		Group("kode_akun").
+		Scan(&saldo).Error; err != nil {
+		return nil, err
+	}
+
+	hasil := make(map[string]float64, len(dana))
+	for _, d := range dana {
+		hasil[d.KodeDana] = 0
+	}
+	for _, s := range saldo {
+		hasil[kodeDana[s.KodeAkun]] = s.Saldo
+	}
+	return hasil, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Donatur dianggap rutin jika berdonasi minimal di sekian bulan berbeda dalam jendela bulan terakhir
+const (
+	bulanMinimalRutin = 3
+	jendelaBulanRutin = 6
+)
+
+// NamaAnonim adalah nama yang ditampilkan untuk donatur tanpa nama atau yang memilih anonim
+const NamaAnonim = "Hamba Allah"
+
+// RingkasanDonatur merangkum riwayat donasi satu donatur
+type RingkasanDonatur struct {
+	IDDonatur      string     `json:"id_donatur"`
+	JumlahDonasi   int64      `json:"jumlah_donasi"`
+	TotalDonasi    float64    `json:"total_donasi"`
+	DonasiPertama  *time.Time `json:"donasi_pertama"`
+	DonasiTerakhir *time.Time `json:"donasi_terakhir"`
+	BulanAktif     int        `json:"bulan_aktif"` // bulan berbeda dengan donasi dalam jendela rutin
+	Rutin          bool       `json:"rutin"`
+}
+
+// tautkanDonatur menautkan donasi ke donatur berdasarkan nomor telepon: dipakai donatur yang sudah
+// terdaftar dengan nomor yang sama, atau dibuatkan donatur baru. Donasi tanpa nomor telepon tidak ditautkan.
+func tautkanDonatur(tx *gorm.DB, donasi *models.Donasi) error {
+	if donasi.IDDonatur != nil {
+		var donatur models.Donatur
+		if err := tx.Where("id_donatur = ?", *donasi.IDDonatur).First(&donatur).Error; err != nil {
+			return err
+		}
+		if donatur.Anonim {
+			donasi.Anonim = true
+		}
+		return nil
+	}
+
+	telp := utils.NormalisasiTelp(donasi.NoTelp)
+	if len(telp) < 8 {
+		return nil
+	}
+
+	var donatur models.Donatur
+	err := tx.Where("no_telp = ?", telp).Order("dibuat_pada ASC").First(&donatur).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		nama := donasi.NamaDonatur
+		if nama == "" || nama == NamaAnonim || nama == "Anonim" {
+			nama = NamaAnonim
+		}
+		donatur = models.Donatur{
+			IDDonatur: uuid.New().String(),
+			Nama:      nama,
+			NoTelp:    telp,
+			Anonim:    donasi.Anonim || nama == NamaAnonim,
+		}
+		err = tx.Create(&donatur).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	donasi.IDDonatur = &donatur.IDDonatur
+	if donatur.Anonim {
+		donasi.Anonim = true
+	}
+	return nil
+}
+
+// TautkanDonasiLama menautkan donasi lama yang belum punya donatur berdasarkan nomor teleponnya
+func TautkanDonasiLama(db *gorm.DB) (int, error) {
+	var donasi []models.Donasi
+	if err := db.Where("id_donatur IS NULL AND no_telp <> ''").Order("waktu_catat ASC").Find(&donasi).Error; err != nil {
+		return 0, err
+	}
+
+	jumlah := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range donasi {
+			if err := tautkanDonatur(tx, &donasi[i]); err != nil {
+				return err
+			}
+			if donasi[i].IDDonatur == nil {
+				continue
+			}
+			if err := tx.Model(&models.Donasi{}).Where("id_donasi = ?", donasi[i].IDDonasi).
+				Updates(map[string]interface{}{"id_donatur": donasi[i].IDDonatur, "anonim": donasi[i].Anonim}).Error; err != nil {
+				return err
+			}
+			jumlah++
+		}
+		return nil
+	})
+	return jumlah, err
+}
+
+// HitungRingkasanDonatur menghitung ringkasan donasi untuk daftar donatur
+func HitungRingkasanDonatur(db *gorm.DB, ids []string) (map[string]*RingkasanDonatur, error) {
+	hasil := map[string]*RingkasanDonatur{}
+	if len(ids) == 0 {
+		return hasil, nil
+	}
+	for _, id := range ids {
+		hasil[id] = &RingkasanDonatur{IDDonatur: id}
+	}
+
+	var total []struct {
+		IDDonatur string
+		Jumlah    int64
+		Total     float64
+		Pertama   time.Time
+		Terakhir  time.Time
+	}
+	if err := db.Model(&models.Donasi{}).
+		Select("id_donatur, COUNT(*) AS jumlah, COALESCE(SUM(nominal), 0) AS total, MIN(waktu_catat) AS pertama, MAX(waktu_catat) AS terakhir").
+		Where("id_donatur IN ?", ids).
+		Group("id_donatur").
+		Scan(&total).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range total {
+		r := hasil[t.IDDonatur]
+		pertama, terakhir := t.Pertama, t.Terakhir
+		r.JumlahDonasi, r.TotalDonasi = t.Jumlah, t.Total
+		r.DonasiPertama, r.DonasiTerakhir = &pertama, &terakhir
+	}
+
+	var bulan []struct {
+		IDDonatur string
+		Bulan     int64
+	}
+	if err := db.Model(&models.Donasi{}).
+		Select("id_donatur, COUNT(DISTINCT DATE_FORMAT(waktu_catat, '%Y-%m')) AS bulan").
+		Where("id_donatur IN ? AND waktu_catat >= ?", ids, awalJendelaRutin()).
+		Group("id_donatur").
+		Scan(&bulan).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range bulan {
+		r := hasil[b.IDDonatur]
+		r.BulanAktif = int(b.Bulan)
+		r.Rutin = r.BulanAktif >= bulanMinimalRutin
+	}
+	return hasil, nil
+}
+
+// QueryDonaturRutin mengembalikan subquery id_donatur yang tergolong donatur rutin
+func QueryDonaturRutin(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Donasi{}).
+		Select("id_donatur").
+		Where("id_donatur IS NOT NULL AND waktu_catat >= ?", awalJendelaRutin()).
+		Group("id_donatur").
+		Having("COUNT(DISTINCT DATE_FORMAT(waktu_catat, '%Y-%m')) >= ?", bulanMinimalRutin)
+}
+
+// awalJendelaRutin adalah awal bulan pertama jendela donatur rutin, termasuk bulan berjalan
+func awalJendelaRutin() time.Time {
+	sekarang := time.Now()
+	return time.Date(sekarang.Year(), sekarang.Month()-jendelaBulanRutin+1, 1, 0, 0, 0, 0, time.Local)
+}
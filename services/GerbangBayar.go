@@ -0,0 +1,352 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"tpq_asysyafii/models"
+)
+
+var (
+	// ErrGerbangBayarNonaktif dikembalikan jika GERBANG_BAYAR tidak diatur
+	ErrGerbangBayarNonaktif = errors.New("pembayaran online belum diaktifkan")
+	// ErrPenyediaTidakDikenal dikembalikan untuk nama penyedia yang tidak terdaftar atau belum dikonfigurasi
+	ErrPenyediaTidakDikenal = errors.New("penyedia pembayaran tidak dikenal")
+	// ErrTandaTanganTidakValid dikembalikan jika tanda tangan webhook tidak cocok
+	ErrTandaTanganTidakValid = errors.New("tanda tangan notifikasi tidak valid")
+	// ErrPenyediaGagal dikembalikan jika penyedia tidak dapat dihubungi atau menolak invoice
+	ErrPenyediaGagal = errors.New("penyedia pembayaran gagal memproses invoice")
+)
+
+// PermintaanTagihan adalah data invoice yang dikirim ke penyedia pembayaran
+type PermintaanTagihan struct {
+	Kode            string
+	Nominal         float64
+	NamaPembayar    string
+	Email           string
+	NoTelp          string
+	Item            []ItemPermintaanTagihan
+	KedaluwarsaPada time.Time
+}
+
+type ItemPermintaanTagihan struct {
+	ID      string
+	Nama    string
+	Nominal float64
+}
+
+// HasilTagihan adalah balasan penyedia setelah invoice dibuat
+type HasilTagihan struct {
+	IDEksternal   string
+	URLPembayaran string
+}
+
+// NotifikasiPembayaran adalah isi webhook yang sudah diverifikasi dan dipetakan ke status lokal
+type NotifikasiPembayaran struct {
+	Kode        string
+	Status      models.StatusTagihanOnline
+	Nominal     float64
+	IDEksternal string
+	Metode      string
+	Waktu       time.Time
+}
+
+// PenyediaPembayaran adalah adapter untuk satu payment gateway. Adapter hanya menerjemahkan
+// format penyedia; pencatatan pembayaran tetap dilakukan PembayaranOnlineService.
+type PenyediaPembayaran interface {
+	Nama() string
+	BuatTagihan(ctx context.Context, p PermintaanTagihan) (*HasilTagihan, error)
+	VerifikasiWebhook(header http.Header, body []byte) (*NotifikasiPembayaran, error)
+}
+
+// daftarPenyedia memetakan nama penyedia ke konstruktornya yang membaca konfigurasi dari environment
+var daftarPenyedia = map[string]func() (PenyediaPembayaran, error){
+	PenyediaMidtrans: penyediaMidtransDariEnv,
+	PenyediaPalsu:    penyediaPalsuDariEnv,
+}
+
+// PenyediaAktif mengembalikan penyedia yang dipilih lewat GERBANG_BAYAR untuk membuat invoice baru
+func PenyediaAktif() (PenyediaPembayaran, error) {
+	nama := strings.ToLower(strings.TrimSpace(os.Getenv("GERBANG_BAYAR")))
+	if nama == "" {
+		return nil, ErrGerbangBayarNonaktif
+	}
+	return PenyediaDariNama(nama)
+}
+
+// PenyediaDariNama membuat penyedia berdasarkan namanya. Dipakai webhook agar invoice yang dibuat
+// sebelum GERBANG_BAYAR diganti tetap bisa dilunasi selama kuncinya masih dikonfigurasi.
+func PenyediaDariNama(nama string) (PenyediaPembayaran, error) {
+	buat, ok := daftarPenyedia[nama]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPenyediaTidakDikenal, nama)
+	}
+	return buat()
+}
+
+// rupiahBulat membulatkan nominal ke rupiah penuh karena gateway tidak menerima pecahan
+func rupiahBulat(nominal float64) int64 {
+	return int64(math.Round(nominal))
+}
+
+// ===================== Midtrans =====================
+
+const PenyediaMidtrans = "midtrans"
+
+// penyediaMidtrans memakai Midtrans Snap. Konfigurasi lewat environment:
+//   - MIDTRANS_SERVER_KEY: server key dari dashboard Midtrans (wajib)
+//   - MIDTRANS_PRODUCTION: "true" untuk akun produksi (default sandbox)
+type penyediaMidtrans struct {
+	serverKey string
+	urlSnap   string
+	client    *http.Client
+}
+
+func penyediaMidtransDariEnv() (PenyediaPembayaran, error) {
+	serverKey := os.Getenv("MIDTRANS_SERVER_KEY")
+	if serverKey == "" {
+		return nil, fmt.Errorf("%w: MIDTRANS_SERVER_KEY belum diatur", ErrPenyediaTidakDikenal)
+	}
+	urlSnap := "https://app.sandbox.midtrans.com/snap/v1/transactions"
+	if strings.EqualFold(os.Getenv("MIDTRANS_PRODUCTION"), "true") {
+		urlSnap = "https://app.midtrans.com/snap/v1/transactions"
+	}
+	return &penyediaMidtrans{
+		serverKey: serverKey,
+		urlSnap:   urlSnap,
+		client:    &http.Client{Timeout: 20 * time.Second},
+	}, nil
+}
+
+func (m *penyediaMidtrans) Nama() string {
+	return PenyediaMidtrans
+}
+
+func (m *penyediaMidtrans) BuatTagihan(ctx context.Context, p PermintaanTagihan) (*HasilTagihan, error) {
+	type item struct {
+		ID       string `json:"id"`
+		Price    int64  `json:"price"`
+		Quantity int    `json:"quantity"`
+		Name     string `json:"name"`
+	}
+	// gross_amount harus sama dengan jumlah item, jadi dihitung dari item yang sudah dibulatkan
+	var total int64
+	items := make([]item, 0, len(p.Item))
+	for _, it := range p.Item {
+		nama := []rune(it.Nama)
+		if len(nama) > 50 {
+			nama = nama[:50]
+		}
+		harga := rupiahBulat(it.Nominal)
+		total += harga
+		items = append(items, item{ID: it.ID, Price: harga, Quantity: 1, Name: string(nama)})
+	}
+	durasi := int(math.Ceil(time.Until(p.KedaluwarsaPada).Minutes()))
+	if durasi < 1 {
+		durasi = 1
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"transaction_details": map[string]interface{}{"order_id": p.Kode, "gross_amount": total},
+		"item_details":        items,
+		"customer_details":    map[string]interface{}{"first_name": p.NamaPembayar, "email": p.Email, "phone": p.NoTelp},
+		"expiry":              map[string]interface{}{"unit": "minutes", "duration": durasi},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.urlSnap, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(m.serverKey, "")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("midtrans tidak dapat dihubungi: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var hasil struct {
+		Token         string   `json:"token"`
+		RedirectURL   string   `json:"redirect_url"`
+		ErrorMessages []string `json:"error_messages"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&hasil); err != nil {
+		return nil, fmt.Errorf("balasan midtrans tidak valid (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("midtrans menolak transaksi (HTTP %d): %s", resp.StatusCode, strings.Join(hasil.ErrorMessages, "; "))
+	}
+	return &HasilTagihan{IDEksternal: hasil.Token, URLPembayaran: hasil.RedirectURL}, nil
+}
+
+// VerifikasiWebhook memeriksa signature_key = SHA512(order_id + status_code + gross_amount + server key)
+func (m *penyediaMidtrans) VerifikasiWebhook(header http.Header, body []byte) (*NotifikasiPembayaran, error) {
+	var n struct {
+		OrderID           string `json:"order_id"`
+		StatusCode        string `json:"status_code"`
+		GrossAmount       string `json:"gross_amount"`
+		SignatureKey      string `json:"signature_key"`
+		TransactionStatus string `json:"transaction_status"`
+		FraudStatus       string `json:"fraud_status"`
+		TransactionID     string `json:"transaction_id"`
+		PaymentType       string `json:"payment_type"`
+		TransactionTime   string `json:"transaction_time"`
+		SettlementTime    string `json:"settlement_time"`
+	}
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTandaTanganTidakValid, err)
+	}
+
+	jejak := sha512.Sum512([]byte(n.OrderID + n.StatusCode + n.GrossAmount + m.serverKey))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(jejak[:])), []byte(strings.ToLower(n.SignatureKey))) != 1 {
+		return nil, ErrTandaTanganTidakValid
+	}
+
+	nominal, err := strconv.ParseFloat(n.GrossAmount, 64)
+	if err != nil {
+		return nil, fmt.Errorf("gross_amount tidak valid: %s", n.GrossAmount)
+	}
+
+	var status models.StatusTagihanOnline
+	switch n.TransactionStatus {
+	case "settlement":
+		status = models.TagihanOnlineDibayar
+	case "capture":
+		// Pembayaran kartu yang ditandai challenge masih menunggu keputusan di dashboard
+		status = models.TagihanOnlineDibayar
+		if n.FraudStatus == "challenge" {
+			status = models.TagihanOnlineMenunggu
+		}
+	case "pending":
+		status = models.TagihanOnlineMenunggu
+	case "expire":
+		status = models.TagihanOnlineKedaluwarsa
+	case "cancel", "deny", "failure":
+		status = models.TagihanOnlineGagal
+	default:
+		return nil, fmt.Errorf("transaction_status tidak dikenal: %s", n.TransactionStatus)
+	}
+
+	// Waktu dari Midtrans selalu dalam WIB
+	waktu := time.Now()
+	wib := time.FixedZone("WIB", 7*3600)
+	for _, s := range []string{n.SettlementTime, n.TransactionTime} {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", s, wib); err == nil {
+			waktu = t
+			break
+		}
+	}
+
+	return &NotifikasiPembayaran{
+		Kode:        n.OrderID,
+		Status:      status,
+		Nominal:     nominal,
+		IDEksternal: n.TransactionID,
+		Metode:      n.PaymentType,
+		Waktu:       waktu,
+	}, nil
+}
+
+// ===================== Penyedia palsu =====================
+
+const PenyediaPalsu = "palsu"
+
+// HeaderTandaTanganPalsu berisi HMAC-SHA256 (hex) dari body notifikasi penyedia palsu
+const HeaderTandaTanganPalsu = "X-Signature"
+
+// PenyediaPalsuPembayaran mensimulasikan payment gateway untuk pengembangan lokal dan pengujian.
+// Invoice langsung dibuat tanpa memanggil layanan luar, dan notifikasinya ditandatangani dengan
+// GERBANG_BAYAR_RAHASIA sehingga melewati jalur verifikasi yang sama dengan gateway sungguhan.
+type PenyediaPalsuPembayaran struct {
+	rahasia []byte
+}
+
+// NotifikasiPalsu adalah format body webhook penyedia palsu
+type NotifikasiPalsu struct {
+	Kode        string  `json:"kode"`
+	Status      string  `json:"status"` // menunggu, dibayar, kedaluwarsa, gagal
+	Nominal     float64 `json:"nominal"`
+	IDEksternal string  `json:"id_eksternal"`
+	Metode      string  `json:"metode"`
+}
+
+func NewPenyediaPalsu(rahasia string) *PenyediaPalsuPembayaran {
+	return &PenyediaPalsuPembayaran{rahasia: []byte(rahasia)}
+}
+
+// SimulasiPembayaranAktif menandakan endpoint simulasi pembayaran boleh didaftarkan. Harus diaktifkan
+// secara eksplisit lewat GERBANG_BAYAR_SIMULASI=true dan hanya untuk lingkungan pengembangan.
+func SimulasiPembayaranAktif() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("GERBANG_BAYAR_SIMULASI")), "true")
+}
+
+func penyediaPalsuDariEnv() (PenyediaPembayaran, error) {
+	rahasia := os.Getenv("GERBANG_BAYAR_RAHASIA")
+	if rahasia == "" {
+		return nil, fmt.Errorf("%w: GERBANG_BAYAR_RAHASIA belum diatur", ErrPenyediaTidakDikenal)
+	}
+	return NewPenyediaPalsu(rahasia), nil
+}
+
+func (p *PenyediaPalsuPembayaran) Nama() string {
+	return PenyediaPalsu
+}
+
+func (p *PenyediaPalsuPembayaran) BuatTagihan(ctx context.Context, t PermintaanTagihan) (*HasilTagihan, error) {
+	return &HasilTagihan{
+		IDEksternal:   "PALSU-" + t.Kode,
+		URLPembayaran: "/api/pembayaran-online/" + t.Kode + "/simulasi",
+	}, nil
+}
+
+// TandaTangan menghitung nilai header X-Signature untuk body notifikasi
+func (p *PenyediaPalsuPembayaran) TandaTangan(body []byte) string {
+	mac := hmac.New(sha256.New, p.rahasia)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *PenyediaPalsuPembayaran) VerifikasiWebhook(header http.Header, body []byte) (*NotifikasiPembayaran, error) {
+	if !hmac.Equal([]byte(p.TandaTangan(body)), []byte(strings.ToLower(header.Get(HeaderTandaTanganPalsu)))) {
+		return nil, ErrTandaTanganTidakValid
+	}
+
+	var n NotifikasiPalsu
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("body notifikasi tidak valid: %w", err)
+	}
+	status := models.StatusTagihanOnline(n.Status)
+	switch status {
+	case models.TagihanOnlineMenunggu, models.TagihanOnlineDibayar, models.TagihanOnlineKedaluwarsa, models.TagihanOnlineGagal:
+	default:
+		return nil, fmt.Errorf("status tidak dikenal: %s", n.Status)
+	}
+	return &NotifikasiPembayaran{
+		Kode:        n.Kode,
+		Status:      status,
+		Nominal:     n.Nominal,
+		IDEksternal: n.IDEksternal,
+		Metode:      n.Metode,
+		Waktu:       time.Now(),
+	}, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"tpq_asysyafii/models"
+)
+
+func TestMidtransVerifikasiWebhook(t *testing.T) {
+	midtrans := &penyediaMidtrans{serverKey: "VT-server-HJMpl9HLr_ntOKt5mRONdmKj"}
+	// SHA512("Postman-1578568851" + "200" + "10000.00" + server key)
+	const signature = "e78e2223638cb60dbdbc88d23deb9b927ac41be7263ab38758605bac834dc25425705543707504bfef0802914cfa3f5f538fa308d1f9086211c420e7892ba2ba"
+
+	notifikasi := func(orderID, grossAmount, signature string) []byte {
+		return []byte(fmt.Sprintf(`{"order_id":%q,"status_code":"200","gross_amount":%q,"signature_key":%q,`+
+			`"transaction_status":"settlement","transaction_id":"abc-123","payment_type":"qris","settlement_time":"2020-01-09 18:21:07"}`,
+			orderID, grossAmount, signature))
+	}
+
+	tests := []struct {
+		nama  string
+		body  []byte
+		valid bool
+	}{
+		{"signature sah", notifikasi("Postman-1578568851", "10000.00", signature), true},
+		{"signature huruf besar", notifikasi("Postman-1578568851", "10000.00", strings.ToUpper(signature)), true},
+		{"nominal diubah", notifikasi("Postman-1578568851", "1000000.00", signature), false},
+		{"order_id diubah", notifikasi("Postman-1578568852", "10000.00", signature), false},
+		{"signature kosong", notifikasi("Postman-1578568851", "10000.00", ""), false},
+		{"body bukan JSON", []byte("order_id=Postman-1578568851"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			notif, err := midtrans.VerifikasiWebhook(http.Header{}, tt.body)
+			if !tt.valid {
+				if !errors.Is(err, ErrTandaTanganTidakValid) {
+					t.Fatalf("VerifikasiWebhook() error = %v, want ErrTandaTanganTidakValid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifikasiWebhook() error = %v", err)
+			}
+			if notif.Kode != "Postman-1578568851" || notif.Status != models.TagihanOnlineDibayar || notif.Nominal != 10000 {
+				t.Errorf("VerifikasiWebhook() = %+v", notif)
+			}
+		})
+	}
+}
+
+func TestPenyediaPalsuVerifikasiWebhook(t *testing.T) {
+	palsu := NewPenyediaPalsu("rahasia-uji")
+	body := []byte(`{"kode":"TPQ-ONL-1","status":"dibayar","nominal":150000}`)
+	// HMAC-SHA256(body, "rahasia-uji")
+	const signature = "5575e6df27be022a324d859a4abb846354339ea017501736b586d0a7be31cdd7"
+
+	if got := palsu.TandaTangan(body); got != signature {
+		t.Fatalf("TandaTangan() = %s, want %s", got, signature)
+	}
+
+	tests := []struct {
+		nama      string
+		body      []byte
+		signature string
+		valid     bool
+	}{
+		{"signature sah", body, signature, true},
+		{"nominal diubah", []byte(strings.Replace(string(body), "150000", "1500", 1)), signature, false},
+		{"rahasia lain", body, NewPenyediaPalsu("rahasia-lain").TandaTangan(body), false},
+		{"tanpa header", body, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(HeaderTandaTanganPalsu, tt.signature)
+			notif, err := palsu.VerifikasiWebhook(header, tt.body)
+			if !tt.valid {
+				if !errors.Is(err, ErrTandaTanganTidakValid) {
+					t.Fatalf("VerifikasiWebhook() error = %v, want ErrTandaTanganTidakValid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifikasiWebhook() error = %v", err)
+			}
+			if notif.Kode != "TPQ-ONL-1" || notif.Status != models.TagihanOnlineDibayar || notif.Nominal != 150000 {
+				t.Errorf("VerifikasiWebhook() = %+v", notif)
+			}
+		})
+	}
+}
@@ -0,0 +1,521 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tipe sumber untuk jurnal dan perubahan rekap yang tidak berasal dari transaksi
+const (
+	SumberSaldoAwal    = "SALDO_AWAL"
+	SumberBackfill     = "BACKFILL_JURNAL"
+	SumberRebuildRekap = "REBUILD_REKAP"
+)
+
+type JurnalService struct {
+	db *gorm.DB
+}
+
+func NewJurnalService(db *gorm.DB) *JurnalService {
+	return &JurnalService{db: db}
+}
+
+// Posting menyimpan jurnal beserta detailnya. Jurnal harus seimbang (total debit = total kredit)
+func (s *JurnalService) Posting(jurnal *models.Jurnal) error {
+	if jurnal.IDJurnal == "" {
+		jurnal.IDJurnal = uuid.New().String()
+	}
+	if jurnal.Tanggal.IsZero() {
+		jurnal.Tanggal = time.Now()
+	}
+	if jurnal.Periode == "" {
+		jurnal.Periode = jurnal.Tanggal.Format("2006-01")
+	}
+	if _, err := time.Parse("2006-01", jurnal.Periode); err != nil {
+		return fmt.Errorf("periode jurnal tidak valid: %s", jurnal.Periode)
+	}
+	if err := NewTutupBukuService(s.db).CekPeriodeTerbuka(jurnal.Periode); err != nil {
+		return err
+	}
+
+	// Buang baris bernilai nol agar jurnal tetap ringkas
+	detail := make([]models.JurnalDetail, 0, len(jurnal.Detail))
+	var totalDebit, totalKredit float64
+	for _, d := range jurnal.Detail {
+		if d.Debit < 0 || d.Kredit < 0 {
+			return errors.New("nominal jurnal tidak boleh negatif")
+		}
+		if d.Debit == 0 && d.Kredit == 0 {
+			continue
+		}
+		d.IDDetail = uuid.New().String()
+		d.IDJurnal = jurnal.IDJurnal
+		totalDebit += d.Debit
+		totalKredit += d.Kredit
+		detail = append(detail, d)
+	}
+
+	if len(detail) < 2 {
+		return errors.New("jurnal minimal memiliki satu baris debit dan satu baris kredit")
+	}
+	if math.Abs(totalDebit-totalKredit) > 0.005 {
+		return fmt.Errorf("jurnal tidak seimbang: debit %.2f, kredit %.2f", totalDebit, totalKredit)
+	}
+
+	jurnal.Detail = detail
+	return s.db.Create(jurnal).Error
+}
+
+// JurnalAktif mengembalikan jurnal milik suatu sumber yang belum dibalik
+func (s *JurnalService) JurnalAktif(tipeSumber, idSumber string) ([]models.Jurnal, error) {
+	var jurnal []models.Jurnal
+	dibalik := s.db.Model(&models.Jurnal{}).
+		Select("id_jurnal_asal").
+		Where("id_jurnal_asal IS NOT NULL")
+
+	err := s.db.Preload("Detail").
+		Where("tipe_sumber = ? AND id_sumber = ? AND id_jurnal_asal IS NULL", tipeSumber, idSumber).
+		Where("id_jurnal NOT IN (?)", dibalik).
+		Order("waktu_catat ASC").
+		Find(&jurnal).Error
+	return jurnal, err
+}
+
+// balikJurnal membuat jurnal pembalik pada periode yang sama dengan jurnal asal
+func (s *JurnalService) balikJurnal(asal models.Jurnal, adminID string) error {
+	pembalik := jurnalPembalik(asal, adminID)
+	return s.Posting(&pembalik)
+}
+
+// jurnalPembalik menyusun jurnal di periode yang sama dengan debit dan kredit asal ditukar
+func jurnalPembalik(asal models.Jurnal, adminID string) models.Jurnal {
+	idAsal := asal.IDJurnal
+	pembalik := models.Jurnal{
+		Periode:      asal.Periode,
+		Tanggal:      time.Now(),
+		Jenis:        asal.Jenis,
+		TipeSumber:   asal.TipeSumber,
+		IDSumber:     asal.IDSumber,
+		IDJurnalAsal: &idAsal,
+		Keterangan:   "Pembalik: " + asal.Keterangan,
+		DicatatOleh:  adminID,
+	}
+	for _, d := range asal.Detail {
+		pembalik.Detail = append(pembalik.Detail, models.JurnalDetail{
+			KodeAkun:   d.KodeAkun,
+			Debit:      d.Kredit,
+			Kredit:     d.Debit,
+			IDRekening: d.IDRekening,
+		})
+	}
+	return pembalik
+}
+
+// sinkronSumber menyamakan jurnal aktif suatu sumber dengan jurnal target.
+// Jurnal lama dibalik lalu target diposting; jika target nil sumber dianggap batal.
+// Mengembalikan daftar periode yang terdampak.
+func (s *JurnalService) sinkronSumber(tipeSumber, idSumber string, target *models.Jurnal, adminID string) ([]string, error) {
+	aktif, err := s.JurnalAktif(tipeSumber, idSumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if target != nil && len(aktif) == 1 && jurnalSama(aktif[0], *target) {
+		return nil, nil
+	}
+
+	periodeMap := make(map[string]bool)
+	for _, j := range aktif {
+		if err := s.balikJurnal(j, adminID); err != nil {
+			return nil, err
+		}
+		periodeMap[j.Periode] = true
+	}
+
+	if target != nil {
+		target.TipeSumber = tipeSumber
+		target.IDSumber = idSumber
+		target.DicatatOleh = adminID
+		if err := s.Posting(target); err != nil {
+			return nil, err
+		}
+		periodeMap[target.Periode] = true
+	}
+
+	return urutkanPeriode(periodeMap), nil
+}
+
+// jurnalSama membandingkan periode, jenis dan saldo per akun dan rekening dari dua jurnal
+func jurnalSama(a, b models.Jurnal) bool {
+	if a.Periode != b.Periode || a.Jenis != b.Jenis {
+		return false
+	}
+	kunci := func(d models.JurnalDetail) string {
+		if d.IDRekening == nil {
+			return d.KodeAkun
+		}
+		return d.KodeAkun + "@" + *d.IDRekening
+	}
+	saldo := make(map[string]float64)
+	for _, d := range a.Detail {
+		saldo[kunci(d)] += d.Debit - d.Kredit
+	}
+	for _, d := range b.Detail {
+		saldo[kunci(d)] -= d.Debit - d.Kredit
+	}
+	for _, v := range saldo {
+		if math.Abs(v) > 0.005 {
+			return false
+		}
+	}
+	return true
+}
+
+// SinkronPembayaranSyahriah memposting pemasukan dari satu pembayaran syahriah.
+// Periode jurnal mengikuti tanggal uang diterima, bukan bulan tagihan.
+func (s *JurnalService) SinkronPembayaranSyahriah(pembayaran models.PembayaranSyahriah, bulanTagihan, adminID string) ([]string, error) {
+	rekening, err := rekeningJurnal(s.db, pembayaran.IDRekening)
+	if err != nil {
+		return nil, err
+	}
+	target := &models.Jurnal{
+		Periode:    pembayaran.TanggalBayar.Format("2006-01"),
+		Tanggal:    pembayaran.TanggalBayar,
+		Jenis:      models.JurnalPemasukan,
+		Keterangan: fmt.Sprintf("Pembayaran syahriah bulan %s (%s)", bulanTagihan, pembayaran.Metode),
+		Detail: []models.JurnalDetail{
+			{KodeAkun: models.KodeKasSyahriah, Debit: pembayaran.Nominal, IDRekening: rekening},
+			{KodeAkun: models.KodePendapatanSyahriah, Kredit: pembayaran.Nominal},
+		},
+	}
+	return s.sinkronSumber(TargetPembayaranSyahriah, pembayaran.IDPembayaran, target, adminID)
+}
+
+// SinkronDonasi memposting pemasukan donasi ke kas dana penerimanya (default dana donasi)
+func (s *JurnalService) SinkronDonasi(donasi models.Donasi, adminID string) ([]string, error) {
+	kodeDana := donasi.KodeDana
+	if kodeDana == "" {
+		kodeDana = models.KodeDanaDonasi
+	}
+	dana, err := ambilDana(s.db, kodeDana, false)
+	if err != nil {
+		return nil, err
+	}
+	rekening, err := rekeningJurnal(s.db, donasi.IDRekening)
+	if err != nil {
+		return nil, err
+	}
+	keterangan := "Donasi dari " + donasi.NamaDonatur
+	if kodeDana != models.KodeDanaDonasi {
+		keterangan = dana.NamaDana + " dari " + donasi.NamaDonatur
+	}
+
+	target := &models.Jurnal{
+		Periode:    donasi.WaktuCatat.Format("2006-01"),
+		Tanggal:    donasi.WaktuCatat,
+		Jenis:      models.JurnalPemasukan,
+		Keterangan: keterangan,
+		Detail: []models.JurnalDetail{
+			{KodeAkun: dana.KodeAkunKas, Debit: donasi.Nominal, IDRekening: rekening},
+			{KodeAkun: dana.KodeAkunPendapatan, Kredit: donasi.Nominal},
+		},
+	}
+	return s.sinkronSumber(TargetDonasi, donasi.IDDonasi, target, adminID)
+}
+
+// SinkronPemakaian memposting pengeluaran dari kas setiap dana pada rincian pemakaian
+func (s *JurnalService) SinkronPemakaian(pemakaian models.PemakaianSaldo, adminID string) ([]string, error) {
+	if len(pemakaian.Rincian) == 0 {
+		if err := muatRincian(s.db, &pemakaian); err != nil {
+			return nil, err
+		}
+	}
+	akunKas, err := akunKasDana(s.db)
+	if err != nil {
+		return nil, err
+	}
+	rekening, err := rekeningJurnal(s.db, pemakaian.IDRekening)
+	if err != nil {
+		return nil, err
+	}
+
+	tanggal := tanggalPemakaian(pemakaian)
+	target := &models.Jurnal{
+		Periode:    tanggal.Format("2006-01"),
+		Tanggal:    tanggal,
+		Jenis:      models.JurnalPengeluaran,
+		Keterangan: pemakaian.JudulPemakaian,
+		Detail: []models.JurnalDetail{
+			{KodeAkun: KodeBebanPemakaian(pemakaian.TipePemakaian), Debit: pemakaian.NominalTotal},
+		},
+	}
+	for _, r := range pemakaian.Rincian {
+		kodeAkun, ok := akunKas[r.KodeDana]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrDanaTidakValid, r.KodeDana)
+		}
+		target.Detail = append(target.Detail, models.JurnalDetail{KodeAkun: kodeAkun, Kredit: r.Nominal, IDRekening: rekening})
+	}
+	return s.sinkronSumber(TargetPemakaian, pemakaian.IDPemakaian, target, adminID)
+}
+
+// akunKasDana memetakan kode dana ke akun kasnya, termasuk dana nonaktif
+func akunKasDana(db *gorm.DB) (map[string]string, error) {
+	dana, err := DaftarDana(db, false)
+	if err != nil {
+		return nil, err
+	}
+	hasil := make(map[string]string, len(dana))
+	for _, d := range dana {
+		hasil[d.KodeDana] = d.KodeAkunKas
+	}
+	return hasil, nil
+}
+
+// SinkronTransfer memposting pindah buku antar rekening. Kedua baris memakai akun pindah buku
+// sehingga saldo dana tidak berubah, hanya saldo rekening asal dan tujuan.
+func (s *JurnalService) SinkronTransfer(transfer models.TransferRekening, adminID string) ([]string, error) {
+	dari, ke := transfer.DariRekening, transfer.KeRekening
+	target := &models.Jurnal{
+		Periode:    transfer.Tanggal.Format("2006-01"),
+		Tanggal:    transfer.Tanggal,
+		Jenis:      models.JurnalTransfer,
+		Keterangan: "Transfer antar rekening",
+		Detail: []models.JurnalDetail{
+			{KodeAkun: models.KodePindahBuku, Debit: transfer.Nominal, IDRekening: &ke},
+			{KodeAkun: models.KodePindahBuku, Kredit: transfer.Nominal, IDRekening: &dari},
+		},
+	}
+	if transfer.Keterangan != "" {
+		target.Keterangan += ": " + transfer.Keterangan
+	}
+	return s.sinkronSumber(TargetTransferRekening, transfer.IDTransfer, target, adminID)
+}
+
+// BatalkanSumber membalik semua jurnal aktif milik sumber yang dihapus
+func (s *JurnalService) BatalkanSumber(tipeSumber, idSumber, adminID string) ([]string, error) {
+	return s.sinkronSumber(tipeSumber, idSumber, nil, adminID)
+}
+
+// PostingSaldoAwal mencatat saldo awal kas setiap dana (kode dana -> saldo) di satu rekening.
+// idRekening kosong berarti rekening utama.
+func (s *JurnalService) PostingSaldoAwal(periode string, saldo map[string]float64, idRekening *string, adminID, keterangan string) (*models.Jurnal, error) {
+	tanggal, err := time.Parse("2006-01", periode)
+	if err != nil {
+		return nil, fmt.Errorf("format periode tidak valid. Gunakan format YYYY-MM")
+	}
+	if keterangan == "" {
+		keterangan = "Saldo awal periode " + periode
+	}
+
+	jurnal := &models.Jurnal{
+		IDJurnal:    uuid.New().String(),
+		Periode:     periode,
+		Tanggal:     tanggal,
+		Jenis:       models.JurnalSaldoAwal,
+		TipeSumber:  SumberSaldoAwal,
+		Keterangan:  keterangan,
+		DicatatOleh: adminID,
+	}
+	jurnal.IDSumber = jurnal.IDJurnal
+
+	akunKas, err := akunKasDana(s.db)
+	if err != nil {
+		return nil, err
+	}
+	rekening, err := tentukanRekening(s.db, idRekening)
+	if err != nil {
+		return nil, err
+	}
+	kodeDana := make([]string, 0, len(saldo))
+	for kode := range saldo {
+		kodeDana = append(kodeDana, kode)
+	}
+	sort.Strings(kodeDana)
+	var total float64
+	for _, kode := range kodeDana {
+		kodeAkun, ok := akunKas[kode]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrDanaTidakValid, kode)
+		}
+		jurnal.Detail = append(jurnal.Detail, models.JurnalDetail{KodeAkun: kodeAkun, Debit: saldo[kode], IDRekening: rekening})
+		total += saldo[kode]
+	}
+	jurnal.Detail = append(jurnal.Detail, models.JurnalDetail{KodeAkun: models.KodeSaldoAwal, Kredit: total})
+
+	if err := s.Posting(jurnal); err != nil {
+		return nil, err
+	}
+	return jurnal, nil
+}
+
+// SaldoAkun menghitung saldo berjalan (debit - kredit) suatu akun dari seluruh jurnal
+func (s *JurnalService) SaldoAkun(kodeAkun string) (float64, error) {
+	var saldo float64
+	err := s.db.Model(&models.JurnalDetail{}).
+		Where("kode_akun = ?", kodeAkun).
+		Select("COALESCE(SUM(debit - kredit), 0)").
+		Scan(&saldo).Error
+	return saldo, err
+}
+
+// ErrJurnalLamaBelumDiposting dikembalikan saat rekap akan dihitung ulang padahal masih ada
+// transaksi lama tanpa jurnal, karena rekap dari jurnal yang belum lengkap menghapus angka lama
+var ErrJurnalLamaBelumDiposting = errors.New("transaksi lama belum dijurnal, jalankan backfill jurnal terlebih dahulu")
+
+// jurnalLamaLengkap menandai bahwa semua transaksi lama sudah dijurnal sehingga pemeriksaannya
+// tidak diulang pada setiap transaksi keuangan
+var jurnalLamaLengkap atomic.Bool
+
+// belumDijurnal membatasi query pada baris sumber yang belum memiliki jurnal
+func belumDijurnal(db *gorm.DB, tipeSumber, kolomID string) *gorm.DB {
+	return db.Where("NOT EXISTS (SELECT 1 FROM jurnal WHERE jurnal.tipe_sumber = ? AND jurnal.id_sumber = "+kolomID+")", tipeSumber)
+}
+
+// cekJurnalLamaLengkap memastikan tidak ada pembayaran, donasi atau pemakaian dicairkan yang
+// belum dijurnal sebelum rekap dihitung ulang dari jurnal
+func cekJurnalLamaLengkap(db *gorm.DB) error {
+	if jurnalLamaLengkap.Load() {
+		return nil
+	}
+	for _, q := range []*gorm.DB{
+		belumDijurnal(db.Model(&models.PembayaranSyahriah{}), TargetPembayaranSyahriah, "pembayaran_syahriah.id_pembayaran"),
+		belumDijurnal(db.Model(&models.Donasi{}), TargetDonasi, "donasi.id_donasi"),
+		belumDijurnal(db.Model(&models.PemakaianSaldo{}), TargetPemakaian, "pemakaian_saldo.id_pemakaian").Where("status = ?", models.PemakaianDicairkan),
+	} {
+		var jumlah int64
+		if err := q.Count(&jumlah).Error; err != nil {
+			return err
+		}
+		if jumlah > 0 {
+			return ErrJurnalLamaBelumDiposting
+		}
+	}
+	jurnalLamaLengkap.Store(true)
+	return nil
+}
+
+// BackfillJurnal memposting jurnal transaksi lama lalu membangun ulang seluruh rekap dalam satu
+// transaksi. Dijalankan otomatis saat migrasi dan bisa diulang admin lewat endpoint backfill.
+// Jika tidak ada transaksi yang perlu dijurnal, rekap dibiarkan apa adanya.
+func BackfillJurnal(db *gorm.DB, adminID, keterangan string) (int, []string, []models.PerubahanRekap, error) {
+	var jumlah int
+	var periodes []string
+	var perubahan []models.PerubahanRekap
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		jumlah, periodes, err = NewJurnalService(tx).BackfillTransaksi()
+		if err != nil || jumlah == 0 {
+			return err
+		}
+		rekapService := NewRekapService(tx)
+		_, perubahan, err = rekapService.RebuildSemua()
+		if err != nil {
+			return err
+		}
+		return rekapService.SimpanPerubahan(perubahan, SumberBackfill, "", adminID, keterangan)
+	})
+	if err != nil {
+		jurnalLamaLengkap.Store(false)
+		return 0, nil, nil, err
+	}
+	return jumlah, periodes, perubahan, nil
+}
+
+// BackfillTransaksi memposting jurnal untuk transaksi lama yang belum pernah dijurnal
+func (s *JurnalService) BackfillTransaksi() (int, []string, error) {
+
+	periodeMap := make(map[string]bool)
+	jumlah := 0
+	catat := func(periodes []string) {
+		jumlah++
+		for _, p := range periodes {
+			periodeMap[p] = true
+		}
+	}
+
+	// Syahriah lunas lama sudah diubah menjadi pembayaran oleh MigrasiPembayaranLama
+	var pembayaran []models.PembayaranSyahriah
+	if err := belumDijurnal(s.db, TargetPembayaranSyahriah, "pembayaran_syahriah.id_pembayaran").Find(&pembayaran).Error; err != nil {
+		return 0, nil, err
+	}
+	for _, p := range pembayaran {
+		var bulan string
+		if err := s.db.Model(&models.Syahriah{}).Where("id_syahriah = ?", p.IDSyahriah).Select("bulan").Scan(&bulan).Error; err != nil {
+			return 0, nil, err
+		}
+		periodes, err := s.SinkronPembayaranSyahriah(p, bulan, p.DiterimaOleh)
+		if err != nil {
+			return 0, nil, fmt.Errorf("gagal jurnal pembayaran syahriah %s: %v", p.IDPembayaran, err)
+		}
+		catat(periodes)
+	}
+
+	var donasi []models.Donasi
+	if err := belumDijurnal(s.db, TargetDonasi, "donasi.id_donasi").Find(&donasi).Error; err != nil {
+		return 0, nil, err
+	}
+	for _, d := range donasi {
+		periodes, err := s.SinkronDonasi(d, d.DicatatOleh)
+		if err != nil {
+			return 0, nil, fmt.Errorf("gagal jurnal donasi %s: %v", d.IDDonasi, err)
+		}
+		catat(periodes)
+	}
+
+	var pemakaian []models.PemakaianSaldo
+	if err := belumDijurnal(s.db, TargetPemakaian, "pemakaian_saldo.id_pemakaian").Where("status = ?", models.PemakaianDicairkan).Find(&pemakaian).Error; err != nil {
+		return 0, nil, err
+	}
+	for _, p := range pemakaian {
+		periodes, err := s.SinkronPemakaian(p, p.DiajukanOleh)
+		if err != nil {
+			return 0, nil, fmt.Errorf("gagal jurnal pemakaian %s: %v", p.IDPemakaian, err)
+		}
+		catat(periodes)
+	}
+
+	return jumlah, urutkanPeriode(periodeMap), nil
+}
+
+// urutkanPeriode mengubah himpunan periode menjadi slice terurut
+func urutkanPeriode(periodeMap map[string]bool) []string {
+	periodes := make([]string, 0, len(periodeMap))
+	for p := range periodeMap {
+		periodes = append(periodes, p)
+	}
+	sort.Strings(periodes)
+	return periodes
+}
+
+// tanggalPemakaian mengambil tanggal efektif pemakaian, data lama tanpa tanggal memakai waktu dibuat
+func tanggalPemakaian(pemakaian models.PemakaianSaldo) time.Time {
+	if pemakaian.TanggalPemakaian != nil {
+		return *pemakaian.TanggalPemakaian
+	}
+	if !pemakaian.CreatedAt.IsZero() {
+		return pemakaian.CreatedAt
+	}
+	return time.Now()
+}
+
+// KodeBebanPemakaian memetakan tipe pemakaian ke akun beban
+func KodeBebanPemakaian(tipe models.TipePemakaian) string {
+	switch tipe {
+	case models.PemakaianOperasional:
+		return models.KodeBebanOperasional
+	case models.PemakaianInvestasi:
+		return models.KodeBebanInvestasi
+	default:
+		return models.KodeBebanLainnya
+	}
+}
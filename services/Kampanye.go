@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"tpq_asysyafii/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrDanaTerikat dikembalikan jika pemakaian saldo memakai dana yang terikat kampanye lain
+var ErrDanaTerikat = errors.New("dana terikat kampanye")
+
+// ErrKampanyeTidakAktif dikembalikan jika donasi ditujukan ke kampanye yang sudah selesai atau dibatalkan
+var ErrKampanyeTidakAktif = errors.New("kampanye tidak ditemukan atau sudah tidak menerima donasi")
+
+// DanaKampanye merangkum donasi yang terkumpul dan pemakaian dana satu kampanye
+type DanaKampanye struct {
+	IDKampanye   string             `json:"id_kampanye"`
+	Terkumpul    float64            `json:"terkumpul"`
+	JumlahDonasi int64              `json:"jumlah_donasi"`
+	Terpakai     float64            `json:"terpakai"`
+	Sisa         float64            `json:"sisa"`
+	SisaPerDana  map[string]float64 `json:"sisa_per_dana,omitempty"` // kode dana -> sisa dana kampanye di dana tersebut
+}
+
+// CekKampanyeAktif memastikan kampanye ada dan masih menerima donasi
+func CekKampanyeAktif(db *gorm.DB, idKampanye string) error {
+	var jumlah int64
+	if err := db.Model(&models.Kampanye{}).Where("id_kampanye = ? AND status = ?", idKampanye, models.KampanyeAktif).Count(&jumlah).Error; err != nil {
+		return err
+	}
+	if jumlah == 0 {
+		return ErrKampanyeTidakAktif
+	}
+	return nil
+}
+
+// HitungDanaKampanye menghitung dana kampanye per dana penerimanya. ids kosong = semua kampanye.
+// Dana terpakai hanya dari pemakaian yang sudah dicairkan, dan di setiap dana paling banyak sebesar
+// donasi kampanye di dana itu (kelebihannya berasal dari saldo bebas dana tersebut).
+// Pemakaian dengan id kecualiPemakaian tidak dihitung (dipakai saat pemakaian itu sedang diubah).
+func HitungDanaKampanye(db *gorm.DB, ids []string, kecualiPemakaian string) (map[string]*DanaKampanye, error) {
+	hasil := map[string]*DanaKampanye{}
+	terkumpul := map[string]map[string]float64{}
+	terpakai := map[string]map[string]float64{}
+	ambil := func(id string) *DanaKampanye {
+		if hasil[id] == nil {
+			hasil[id] = &DanaKampanye{IDKampanye: id, SisaPerDana: map[string]float64{}}
+			terkumpul[id] = map[string]float64{}
+			terpakai[id] = map[string]float64{}
+		}
+		return hasil[id]
+	}
+
+	var donasi []struct {
+		IDKampanye string
+		KodeDana   string
+		Total      float64
+		Jumlah     int64
+	}
+	query := db.Model(&models.Donasi{}).Select("id_kampanye, kode_dana, COALESCE(SUM(nominal), 0) AS total, COUNT(*) AS jumlah").
+		Where("id_kampanye IS NOT NULL")
+	if len(ids) > 0 {
+		query = query.Where("id_kampanye IN ?", ids)
+	}
+	if err := query.Group("id_kampanye, kode_dana").Scan(&donasi).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range donasi {
+		dana := ambil(d.IDKampanye)
+		dana.Terkumpul += d.Total
+		dana.JumlahDonasi += d.Jumlah
+		terkumpul[d.IDKampanye][d.KodeDana] += d.Total
+	}
+
+	var pemakaian []struct {
+		IDKampanye string
+		KodeDana   string
+		Total      float64
+	}
+	query = db.Table("pemakaian_dana").
+		Select("pemakaian_saldo.id_kampanye, pemakaian_dana.kode_dana, COALESCE(SUM(pemakaian_dana.nominal), 0) AS total").
+		Joins("JOIN pemakaian_saldo ON pemakaian_saldo.id_pemakaian = pemakaian_dana.id_pemakaian").
+		Where("pemakaian_saldo.id_kampanye IS NOT NULL AND pemakaian_saldo.status = ?", models.PemakaianDicairkan)
+	if len(ids) > 0 {
+		query = query.Where("pemakaian_saldo.id_kampanye IN ?", ids)
+	}
+	if kecualiPemakaian != "" {
+		query = query.Where("pemakaian_saldo.id_pemakaian <> ?", kecualiPemakaian)
+	}
+	if err := query.Group("pemakaian_saldo.id_kampanye, pemakaian_dana.kode_dana").Scan(&pemakaian).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range pemakaian {
+		ambil(p.IDKampanye)
+		terpakai[p.IDKampanye][p.KodeDana] += p.Total
+	}
+
+	for id, dana := range hasil {
+		for kode, total := range terkumpul[id] {
+			pakai := math.Min(terpakai[id][kode], total)
+			dana.Terpakai += pakai
+			if sisa := total - pakai; sisa > 0.005 {
+				dana.SisaPerDana[kode] = sisa
+			}
+		}
+		dana.Sisa = math.Max(dana.Terkumpul-dana.Terpakai, 0)
+	}
+	return hasil, nil
+}
+
+// cekDanaTerikat memastikan pemakaian tidak memakai dana yang terikat kampanye lain. Di setiap dana,
+// pemakaian hanya boleh mengambil saldo yang tidak terikat kampanye ditambah sisa dana kampanyenya
+// sendiri. lama diisi saat mengubah pemakaian yang sudah dicairkan (nominalnya sudah mengurangi saldo),
+// nil untuk pemakaian yang baru dicairkan.
+func cekDanaTerikat(tx *gorm.DB, jurnal *JurnalService, pemakaian models.PemakaianSaldo, lama *models.PemakaianSaldo) error {
+	lamaPerDana := map[string]float64{}
+	lamaKampanye := ""
+	if lama != nil {
+		lamaPerDana = nominalPerDana(*lama)
+		if lama.IDKampanye != nil {
+			lamaKampanye = *lama.IDKampanye
+		}
+	}
+	kampanye := ""
+	if pemakaian.IDKampanye != nil {
+		kampanye = *pemakaian.IDKampanye
+	}
+
+	var dana map[string]*DanaKampanye
+	var akunKas map[string]string
+	for kodeDana, nominal := range nominalPerDana(pemakaian) {
+		// Perubahan yang tidak menambah pemakaian dana ini tidak perlu dicek ulang
+		if nominal <= 0 || (kampanye == lamaKampanye && nominal <= lamaPerDana[kodeDana]+0.005) {
+			continue
+		}
+		if dana == nil {
+			var err error
+			if dana, err = HitungDanaKampanye(tx, nil, pemakaian.IDPemakaian); err != nil {
+				return err
+			}
+			if akunKas, err = akunKasDana(tx); err != nil {
+				return err
+			}
+		}
+
+		// Saldo dana sebelum pemakaian ini dikurangi dana yang masih terikat kampanye lain
+		saldo, err := jurnal.SaldoAkun(akunKas[kodeDana])
+		if err != nil {
+			return err
+		}
+		saldo += lamaPerDana[kodeDana]
+		var terikat float64
+		for id, d := range dana {
+			if id != kampanye {
+				terikat += d.SisaPerDana[kodeDana]
+			}
+		}
+		if bebas := saldo - terikat; nominal > bebas+0.005 {
+			return fmt.Errorf("%w: saldo dana %s yang bisa dipakai Rp %.0f (Rp %.0f terikat kampanye lain)", ErrDanaTerikat, kodeDana, math.Max(bebas, 0), terikat)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrKelasPenuh dikembalikan jika jumlah anggota aktif sudah mencapai kapasitas kelas
+	ErrKelasPenuh = errors.New("kapasitas kelas sudah penuh")
+	// ErrSantriTidakAktif dikembalikan jika santri yang didaftarkan ke kelas sudah tidak aktif
+	ErrSantriTidakAktif = errors.New("santri tidak aktif")
+)
+
+// TahunAjaranBerjalan mengembalikan tahun ajaran (format 2025/2026) untuk tanggal t.
+// Tahun ajaran dimulai bulan Juli.
+func TahunAjaranBerjalan(t time.Time) string {
+	tahun := t.Year()
+	if t.Month() < time.July {
+		tahun--
+	}
+	return fmt.Sprintf("%d/%d", tahun, tahun+1)
+}
+
+// ValidTahunAjaran mengecek format tahun ajaran YYYY/YYYY dengan tahun kedua = tahun pertama + 1
+func ValidTahunAjaran(s string) bool {
+	if len(s) != 9 || s[4] != '/' {
+		return false
+	}
+	awal, err1 := strconv.Atoi(s[:4])
+	akhir, err2 := strconv.Atoi(s[5:])
+	return err1 == nil && err2 == nil && akhir == awal+1
+}
+
+// KelasUstadz mengambil ID kelas aktif yang diajar seorang ustadz, baik sebagai wali kelas
+// maupun sebagai pengajar di jadwalnya
+func KelasUstadz(db *gorm.DB, idUstadz string) ([]string, error) {
+	var idKelas []string
+	err := db.Model(&models.Kelas{}).
+		Where("aktif = ?", true).
+		Where("id_wali_kelas = ? OR id_kelas IN (?)", idUstadz,
+			db.Model(&models.JadwalKelas{}).Select("id_kelas").Where("id_pengajar = ?", idUstadz)).
+		Pluck("id_kelas", &idKelas).Error
+	return idKelas, err
+}
+
+// MasukkanKeKelas mendaftarkan santri ke kelas mulai tanggal masuk. Keanggotaan aktif santri di kelas
+// lain pada tahun ajaran yang sama ditutup dengan status pindah, dan kolom kelas pada santri ikut
+// diperbarui. Santri yang sudah menjadi anggota aktif kelas ini dilewati.
+func MasukkanKeKelas(db *gorm.DB, kelas models.Kelas, idSantri []string, tanggalMasuk time.Time, adminID string) ([]models.AnggotaKelas, error) {
+	var baru []models.AnggotaKelas
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var jumlahAktif int64
+		if err := tx.Model(&models.AnggotaKelas{}).
+			Where("id_kelas = ? AND status = ?", kelas.IDKelas, models.AnggotaAktif).
+			Count(&jumlahAktif).Error; err != nil {
+			return err
+		}
+
+		for _, id := range idSantri {
+			var santri models.Santri
+			if err := tx.Where("id_santri = ?", id).First(&santri).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("santri %s tidak ditemukan", id)
+				}
+				return err
+			}
+			if santri.Status != models.StatusAktifSantri {
+				return fmt.Errorf("%w: %s", ErrSantriTidakAktif, santri.NamaLengkap)
+			}
+
+			var aktif []models.AnggotaKelas
+			if err := tx.Where("id_santri = ? AND tahun_ajaran = ? AND status = ?", id, kelas.TahunAjaran, models.AnggotaAktif).
+				Find(&aktif).Error; err != nil {
+				return err
+			}
+			sudahAnggota := false
+			for _, a := range aktif {
+				if a.IDKelas == kelas.IDKelas {
+					sudahAnggota = true
+					continue
+				}
+				if err := tutupKeanggotaan(tx, &a, models.AnggotaPindah, tanggalMasuk, "Pindah ke kelas "+kelas.NamaKelas); err != nil {
+					return err
+				}
+			}
+			if sudahAnggota {
+				continue
+			}
+
+			if kelas.Kapasitas > 0 && jumlahAktif >= int64(kelas.Kapasitas) {
+				return fmt.Errorf("%w: %s (%d santri)", ErrKelasPenuh, kelas.NamaKelas, kelas.Kapasitas)
+			}
+			anggota := models.AnggotaKelas{
+				IDAnggota:    uuid.New().String(),
+				IDKelas:      kelas.IDKelas,
+				IDSantri:     id,
+				TahunAjaran:  kelas.TahunAjaran,
+				TanggalMasuk: tanggalMasuk,
+				Status:       models.AnggotaAktif,
+				DicatatOleh:  adminID,
+			}
+			if err := tx.Create(&anggota).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Santri{}).Where("id_santri = ?", id).Update("kelas", kelas.NamaKelas).Error; err != nil {
+				return err
+			}
+			jumlahAktif++
+			baru = append(baru, anggota)
+		}
+		return nil
+	})
+	return baru, err
+}
+
+// KeluarkanDariKelas menutup keanggotaan aktif dengan status dan tanggal keluar
+func KeluarkanDariKelas(db *gorm.DB, anggota *models.AnggotaKelas, status models.StatusAnggotaKelas, tanggalKeluar time.Time, keterangan string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return tutupKeanggotaan(tx, anggota, status, tanggalKeluar, keterangan)
+	})
+}
+
+// tutupKeanggotaan menutup satu baris keanggotaan dan mengosongkan kolom kelas santri
+// jika masih menunjuk kelas tersebut
+func tutupKeanggotaan(tx *gorm.DB, anggota *models.AnggotaKelas, status models.StatusAnggotaKelas, tanggalKeluar time.Time, keterangan string) error {
+	if anggota.Status != models.AnggotaAktif {
+		return errors.New("santri sudah tidak aktif di kelas ini")
+	}
+	if tanggalKeluar.Before(anggota.TanggalMasuk) {
+		tanggalKeluar = anggota.TanggalMasuk
+	}
+	anggota.Status = status
+	anggota.TanggalKeluar = &tanggalKeluar
+	if keterangan != "" {
+		anggota.Keterangan = keterangan
+	}
+	if err := tx.Omit("Kelas", "Santri").Save(anggota).Error; err != nil {
+		return err
+	}
+
+	var kelas models.Kelas
+	if err := tx.Where("id_kelas = ?", anggota.IDKelas).First(&kelas).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Santri{}).
+		Where("id_santri = ? AND kelas = ?", anggota.IDSantri, kelas.NamaKelas).
+		Update("kelas", "").Error
+}
@@ -0,0 +1,519 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrSaldoTidakCukup dikembalikan jika saldo kas tidak mencukupi untuk pemakaian
+var ErrSaldoTidakCukup = errors.New("saldo tidak mencukupi")
+
+// KeuanganService menjalankan penulisan data keuangan, posting jurnal dan update rekap
+// dalam satu transaksi sehingga saldo tidak pernah tertinggal dari datanya
+type KeuanganService struct {
+	db *gorm.DB
+}
+
+func NewKeuanganService(db *gorm.DB) *KeuanganService {
+	return &KeuanganService{db: db}
+}
+
+// transaksi menjalankan fn lalu menghitung ulang rekap mulai dari periode paling awal yang
+// dikembalikan fn sampai periode terakhir, dan mencatat periode yang berubah.
+// Jika salah satu langkah gagal seluruh perubahan di-rollback.
+func (s *KeuanganService) transaksi(tipeSumber, idSumber, adminID, keterangan string, fn func(tx *gorm.DB, jurnal *JurnalService) ([]string, error)) ([]models.PerubahanRekap, error) {
+	var perubahan []models.PerubahanRekap
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		periodes, err := fn(tx, NewJurnalService(tx))
+		if err != nil {
+			return err
+		}
+		if len(periodes) == 0 {
+			return nil
+		}
+
+		// periodes sudah terurut, jadi periodes[0] adalah periode paling awal yang terdampak
+		rekapService := NewRekapService(tx)
+		_, perubahan, err = rekapService.HitungBerantai(periodes[0])
+		if err != nil {
+			return err
+		}
+		return rekapService.SimpanPerubahan(perubahan, tipeSumber, idSumber, adminID, keterangan)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return perubahan, nil
+}
+
+// CreateSyahriah menyimpan tagihan syahriah baru. Tagihan tidak dijurnal; jika dibuat dengan
+// status lunas, pelunasannya dicatat sebagai pembayaran beserta jurnal dan rekapnya.
+func (s *KeuanganService) CreateSyahriah(syahriah *models.Syahriah, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetSyahriah, syahriah.IDSyahriah, adminID, "Tambah syahriah bulan "+syahriah.Bulan, func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(syahriah.Bulan); err != nil {
+			return nil, err
+		}
+		return buatTagihan(tx, jurnal, syahriah, adminID)
+	})
+}
+
+// UpdateSyahriah menyimpan perubahan tagihan syahriah. statusDiminta lunas mencatat pelunasan sisa
+// tagihan, belum membatalkan semua pembayarannya, dan kosong hanya menghitung ulang status.
+func (s *KeuanganService) UpdateSyahriah(syahriah *models.Syahriah, statusDiminta models.StatusSyahriah, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetSyahriah, syahriah.IDSyahriah, adminID, "Ubah syahriah bulan "+syahriah.Bulan, func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		var lama models.Syahriah
+		if err := tx.Where("id_syahriah = ?", syahriah.IDSyahriah).First(&lama).Error; err != nil {
+			return nil, err
+		}
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(lama.Bulan, syahriah.Bulan); err != nil {
+			return nil, err
+		}
+
+		periodeMap := make(map[string]bool)
+		if statusDiminta == models.StatusBelum && lama.Terbayar > 0 {
+			if err := hapusSemuaPembayaran(tx, jurnal, syahriah.IDSyahriah, adminID, periodeMap); err != nil {
+				return nil, err
+			}
+			lama.Terbayar = 0
+		}
+		if syahriah.Nominal < lama.Terbayar-0.005 {
+			return nil, fmt.Errorf("nominal tagihan tidak boleh lebih kecil dari yang sudah dibayar (Rp %.0f)", lama.Terbayar)
+		}
+
+		syahriah.Terbayar = lama.Terbayar
+		syahriah.Status = StatusPembayaran(syahriah.Nominal, syahriah.Terbayar)
+		if err := tx.Omit("Pembayaran").Save(syahriah).Error; err != nil {
+			return nil, err
+		}
+
+		if statusDiminta == models.StatusLunas && syahriah.Status != models.StatusLunas {
+			pelunasan := &models.PembayaranSyahriah{Nominal: SisaTagihan(*syahriah), Keterangan: "Pelunasan sisa tagihan"}
+			periodes, err := catatPembayaran(tx, jurnal, syahriah, pelunasan, adminID)
+			if err != nil {
+				return nil, err
+			}
+			tandaiPeriode(periodeMap, periodes)
+		}
+		return urutkanPeriode(periodeMap), nil
+	})
+}
+
+// DeleteSyahriah menghapus tagihan beserta pembayarannya dan membalik jurnalnya
+func (s *KeuanganService) DeleteSyahriah(id, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetSyahriah, id, adminID, "Hapus syahriah", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		var lama models.Syahriah
+		if err := tx.Where("id_syahriah = ?", id).First(&lama).Error; err != nil {
+			return nil, err
+		}
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(lama.Bulan); err != nil {
+			return nil, err
+		}
+
+		periodeMap := make(map[string]bool)
+		if err := hapusSemuaPembayaran(tx, jurnal, id, adminID, periodeMap); err != nil {
+			return nil, err
+		}
+		if err := tx.Where("id_syahriah = ?", id).Delete(&models.Syahriah{}).Error; err != nil {
+			return nil, err
+		}
+
+		// Jurnal syahriah lama yang belum dimigrasi ke pembayaran
+		periodes, err := jurnal.BatalkanSumber(TargetSyahriah, id, adminID)
+		if err != nil {
+			return nil, err
+		}
+		tandaiPeriode(periodeMap, periodes)
+		return urutkanPeriode(periodeMap), nil
+	})
+}
+
+// BatchCreateSyahriah menyimpan banyak tagihan sekaligus, yang berstatus lunas dicatat pembayarannya
+func (s *KeuanganService) BatchCreateSyahriah(syahriahList []models.Syahriah, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetSyahriah, "", adminID, "Batch syahriah", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		bulan := make([]string, 0, len(syahriahList))
+		for _, syahriah := range syahriahList {
+			bulan = append(bulan, syahriah.Bulan)
+		}
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(bulan...); err != nil {
+			return nil, err
+		}
+
+		periodeMap := make(map[string]bool)
+		for i := range syahriahList {
+			periodes, err := buatTagihan(tx, jurnal, &syahriahList[i], adminID)
+			if err != nil {
+				return nil, err
+			}
+			tandaiPeriode(periodeMap, periodes)
+		}
+		return urutkanPeriode(periodeMap), nil
+	})
+}
+
+// buatTagihan menyimpan tagihan dengan status yang diturunkan dari pembayarannya
+func buatTagihan(tx *gorm.DB, jurnal *JurnalService, syahriah *models.Syahriah, adminID string) ([]string, error) {
+	lunas := syahriah.Status == models.StatusLunas
+	syahriah.Terbayar = 0
+	syahriah.Status = StatusPembayaran(syahriah.Nominal, 0)
+	if err := tx.Create(syahriah).Error; err != nil {
+		if duplikatKunci(err) {
+			return nil, fmt.Errorf("%w: santri %s bulan %s", ErrSyahriahSudahAda, syahriah.ID_Santri, syahriah.Bulan)
+		}
+		return nil, err
+	}
+	if !lunas || syahriah.Status == models.StatusLunas {
+		return nil, nil
+	}
+	pelunasan := &models.PembayaranSyahriah{Nominal: syahriah.Nominal, Keterangan: "Dilunasi saat tagihan dibuat"}
+	return catatPembayaran(tx, jurnal, syahriah, pelunasan, adminID)
+}
+
+// TambahPembayaran mencatat pembayaran (cicilan atau pelunasan) untuk satu tagihan syahriah
+func (s *KeuanganService) TambahPembayaran(pembayaran *models.PembayaranSyahriah, adminID string) ([]models.PerubahanRekap, error) {
+	if pembayaran.IDPembayaran == "" {
+		pembayaran.IDPembayaran = uuid.New().String()
+	}
+	return s.transaksi(TargetPembayaranSyahriah, pembayaran.IDPembayaran, adminID, "Pembayaran syahriah", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		var syahriah models.Syahriah
+		if err := tx.Where("id_syahriah = ?", pembayaran.IDSyahriah).First(&syahriah).Error; err != nil {
+			return nil, err
+		}
+		return catatPembayaran(tx, jurnal, &syahriah, pembayaran, adminID)
+	})
+}
+
+// BayarTagihanSantri membagi satu pembayaran ke tagihan santri yang belum lunas,
+// mulai dari bulan paling lama. Mengembalikan pembayaran yang tercatat per tagihan.
+func (s *KeuanganService) BayarTagihanSantri(idSantri string, pembayaran models.PembayaranSyahriah, adminID string) ([]models.PembayaranSyahriah, []models.PerubahanRekap, error) {
+	var tercatat []models.PembayaranSyahriah
+	perubahan, err := s.transaksi(TargetPembayaranSyahriah, idSantri, adminID, "Pembayaran syahriah beberapa bulan", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		if pembayaran.Nominal <= 0 {
+			return nil, errors.New("nominal pembayaran harus lebih dari 0")
+		}
+
+		var tagihan []models.Syahriah
+		if err := tx.Where("id_santri = ? AND status <> ?", idSantri, models.StatusLunas).
+			Order("bulan ASC").
+			Find(&tagihan).Error; err != nil {
+			return nil, err
+		}
+
+		alokasi, err := bagiPembayaran(tagihan, pembayaran.Nominal)
+		if err != nil {
+			return nil, err
+		}
+
+		periodeMap := make(map[string]bool)
+		for i := range tagihan {
+			if alokasi[i] <= 0 {
+				continue
+			}
+			bagian := pembayaran
+			bagian.IDPembayaran = ""
+			bagian.Nominal = alokasi[i]
+			periodes, err := catatPembayaran(tx, jurnal, &tagihan[i], &bagian, adminID)
+			if err != nil {
+				return nil, err
+			}
+			tandaiPeriode(periodeMap, periodes)
+			tercatat = append(tercatat, bagian)
+		}
+		return urutkanPeriode(periodeMap), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return tercatat, perubahan, nil
+}
+
+// HapusPembayaran membatalkan satu pembayaran syahriah, membalik jurnalnya dan menghitung ulang status tagihan
+func (s *KeuanganService) HapusPembayaran(id, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetPembayaranSyahriah, id, adminID, "Hapus pembayaran syahriah", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		var pembayaran models.PembayaranSyahriah
+		if err := tx.Where("id_pembayaran = ?", id).First(&pembayaran).Error; err != nil {
+			return nil, err
+		}
+		var syahriah models.Syahriah
+		if err := tx.Where("id_syahriah = ?", pembayaran.IDSyahriah).First(&syahriah).Error; err != nil {
+			return nil, err
+		}
+
+		periodes, err := hapusPembayaran(tx, jurnal, pembayaran, adminID)
+		if err != nil {
+			return nil, err
+		}
+		return periodes, perbaruiTerbayar(tx, &syahriah)
+	})
+}
+
+// CreateDonasi menyimpan donasi baru beserta jurnal dan rekapnya
+func (s *KeuanganService) CreateDonasi(donasi *models.Donasi, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetDonasi, donasi.IDDonasi, adminID, "Tambah donasi", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(donasi.WaktuCatat.Format("2006-01")); err != nil {
+			return nil, err
+		}
+		if err := cekDanaDonasi(tx, donasi); err != nil {
+			return nil, err
+		}
+		var err error
+		if donasi.IDRekening, err = tentukanRekening(tx, donasi.IDRekening); err != nil {
+			return nil, err
+		}
+		if err := tautkanDonatur(tx, donasi); err != nil {
+			return nil, err
+		}
+		if err := tx.Create(donasi).Error; err != nil {
+			return nil, err
+		}
+		if _, err := NewKwitansiService(tx).TerbitkanDonasi(*donasi); err != nil {
+			return nil, err
+		}
+		return jurnal.SinkronDonasi(*donasi, adminID)
+	})
+}
+
+// samaRekening membandingkan dua rekening opsional
+func samaRekening(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// cekDanaDonasi mengisi dana bawaan donasi dan memastikan dana tujuannya boleh menerima donasi
+func cekDanaDonasi(tx *gorm.DB, donasi *models.Donasi) error {
+	if donasi.KodeDana == "" {
+		donasi.KodeDana = models.KodeDanaDonasi
+	}
+	return CekDanaPenerimaan(tx, donasi.KodeDana)
+}
+
+// UpdateDonasi menyimpan perubahan donasi beserta jurnal dan rekapnya
+func (s *KeuanganService) UpdateDonasi(donasi *models.Donasi, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetDonasi, donasi.IDDonasi, adminID, "Ubah donasi", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		var lama models.Donasi
+		if err := tx.Where("id_donasi = ?", donasi.IDDonasi).First(&lama).Error; err != nil {
+			return nil, err
+		}
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(lama.WaktuCatat.Format("2006-01"), donasi.WaktuCatat.Format("2006-01")); err != nil {
+			return nil, err
+		}
+		if donasi.KodeDana != lama.KodeDana {
+			if err := cekDanaDonasi(tx, donasi); err != nil {
+				return nil, err
+			}
+		}
+		if !samaRekening(donasi.IDRekening, lama.IDRekening) {
+			var err error
+			if donasi.IDRekening, err = tentukanRekening(tx, donasi.IDRekening); err != nil {
+				return nil, err
+			}
+		}
+		if err := tautkanDonatur(tx, donasi); err != nil {
+			return nil, err
+		}
+		if err := tx.Save(donasi).Error; err != nil {
+			return nil, err
+		}
+		if _, err := NewKwitansiService(tx).TerbitkanDonasi(*donasi); err != nil {
+			return nil, err
+		}
+		return jurnal.SinkronDonasi(*donasi, adminID)
+	})
+}
+
+// DeleteDonasi menghapus donasi dan membalik jurnalnya
+func (s *KeuanganService) DeleteDonasi(id, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetDonasi, id, adminID, "Hapus donasi", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		var lama models.Donasi
+		if err := tx.Where("id_donasi = ?", id).First(&lama).Error; err != nil {
+			return nil, err
+		}
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(lama.WaktuCatat.Format("2006-01")); err != nil {
+			return nil, err
+		}
+		if err := tx.Where("id_donasi = ?", id).Delete(&models.Donasi{}).Error; err != nil {
+			return nil, err
+		}
+		if err := NewKwitansiService(tx).Batalkan(TargetDonasi, id); err != nil {
+			return nil, err
+		}
+		return jurnal.BatalkanSumber(TargetDonasi, id, adminID)
+	})
+}
+
+// CreatePemakaian mencatat pengajuan pemakaian baru. Saldo belum berkurang dan jurnal belum
+// diposting sampai pengajuan disetujui lalu dicairkan (lihat CairkanPemakaian), tetapi tanggalnya
+// tetap harus berada di periode yang belum ditutup.
+func (s *KeuanganService) CreatePemakaian(pemakaian *models.PemakaianSaldo, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetPemakaian, pemakaian.IDPemakaian, adminID, "Ajukan pemakaian saldo", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(tanggalPemakaian(*pemakaian).Format("2006-01")); err != nil {
+			return nil, err
+		}
+		RapikanRincian(pemakaian)
+		if err := cekPembatasanDana(tx, *pemakaian); err != nil {
+			return nil, err
+		}
+		if err := cekPosAnggaran(tx, *pemakaian); err != nil {
+			return nil, err
+		}
+		var err error
+		if pemakaian.IDRekening, err = tentukanRekening(tx, pemakaian.IDRekening); err != nil {
+			return nil, err
+		}
+		peran, err := PeranPenyetuju(tx, pemakaian.NominalTotal)
+		if err != nil {
+			return nil, err
+		}
+		pemakaian.Status = models.PemakaianDiajukan
+		pemakaian.PeranPenyetuju = peran
+		if err := tx.Omit("Rincian").Create(pemakaian).Error; err != nil {
+			return nil, err
+		}
+		if err := simpanRincian(tx, *pemakaian); err != nil {
+			return nil, err
+		}
+		komentar := ""
+		if pemakaian.Keterangan != nil {
+			komentar = *pemakaian.Keterangan
+		}
+		return nil, catatRiwayatPemakaian(tx, pemakaian.IDPemakaian, "", models.PemakaianDiajukan, adminID, komentar)
+	})
+}
+
+// UpdatePemakaian menyimpan perubahan pemakaian. Periode tanggal lama dan baru harus masih terbuka
+// apa pun statusnya. Pengajuan yang belum dicairkan disimpan tanpa jurnal,
+// dan jika sudah disetujui atau ditolak dikembalikan ke status diajukan agar diperiksa ulang.
+// Untuk pemakaian yang sudah dicairkan saldo hanya dicek untuk tambahan nominal,
+// karena saldo saat ini sudah dikurangi nominal lama.
+func (s *KeuanganService) UpdatePemakaian(pemakaian *models.PemakaianSaldo, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetPemakaian, pemakaian.IDPemakaian, adminID, "Ubah pemakaian saldo", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		var lama models.PemakaianSaldo
+		if err := tx.Where("id_pemakaian = ?", pemakaian.IDPemakaian).First(&lama).Error; err != nil {
+			return nil, err
+		}
+		if err := muatRincian(tx, &lama); err != nil {
+			return nil, err
+		}
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(tanggalPemakaian(lama).Format("2006-01"), tanggalPemakaian(*pemakaian).Format("2006-01")); err != nil {
+			return nil, err
+		}
+		RapikanRincian(pemakaian)
+		if err := cekPembatasanDana(tx, *pemakaian); err != nil {
+			return nil, err
+		}
+		if err := cekPosAnggaran(tx, *pemakaian); err != nil {
+			return nil, err
+		}
+		if !samaRekening(pemakaian.IDRekening, lama.IDRekening) {
+			var err error
+			if pemakaian.IDRekening, err = tentukanRekening(tx, pemakaian.IDRekening); err != nil {
+				return nil, err
+			}
+		}
+
+		if lama.Status != models.PemakaianDicairkan {
+			peran, err := PeranPenyetuju(tx, pemakaian.NominalTotal)
+			if err != nil {
+				return nil, err
+			}
+			pemakaian.PeranPenyetuju = peran
+			pemakaian.Status = models.PemakaianDiajukan
+			if lama.Status != models.PemakaianDiajukan {
+				pemakaian.DisetujuiOleh = nil
+				pemakaian.WaktuDisetujui = nil
+				if err := catatRiwayatPemakaian(tx, pemakaian.IDPemakaian, lama.Status, models.PemakaianDiajukan, adminID, "Pengajuan diubah, perlu persetujuan ulang"); err != nil {
+					return nil, err
+				}
+			}
+			if err := tx.Omit("Rincian").Save(pemakaian).Error; err != nil {
+				return nil, err
+			}
+			return nil, simpanRincian(tx, *pemakaian)
+		}
+
+		tambahan := nominalPerDana(*pemakaian)
+		for kodeDana, nominal := range nominalPerDana(lama) {
+			tambahan[kodeDana] -= nominal
+		}
+		if err := cekSaldoKas(jurnal, tambahan); err != nil {
+			return nil, err
+		}
+		// Pindah rekening berarti seluruh nominal keluar dari rekening baru
+		tambahanRekening := pemakaian.NominalTotal
+		if samaRekening(pemakaian.IDRekening, lama.IDRekening) {
+			tambahanRekening -= lama.NominalTotal
+		}
+		if err := cekSaldoRekening(tx, pemakaian.IDRekening, tambahanRekening); err != nil {
+			return nil, err
+		}
+		if err := cekDanaTerikat(tx, jurnal, *pemakaian, &lama); err != nil {
+			return nil, err
+		}
+		if err := tx.Omit("Rincian").Save(pemakaian).Error; err != nil {
+			return nil, err
+		}
+		if err := simpanRincian(tx, *pemakaian); err != nil {
+			return nil, err
+		}
+		return jurnal.SinkronPemakaian(*pemakaian, adminID)
+	})
+}
+
+// DeletePemakaian menghapus pemakaian beserta riwayatnya dan membalik jurnalnya jika sudah dicairkan.
+// Pemakaian di periode yang sudah ditutup tidak bisa dihapus apa pun statusnya.
+func (s *KeuanganService) DeletePemakaian(id, adminID string) ([]models.PerubahanRekap, error) {
+	return s.transaksi(TargetPemakaian, id, adminID, "Hapus pemakaian saldo", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		var lama models.PemakaianSaldo
+		if err := tx.Where("id_pemakaian = ?", id).First(&lama).Error; err != nil {
+			return nil, err
+		}
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(tanggalPemakaian(lama).Format("2006-01")); err != nil {
+			return nil, err
+		}
+		if err := tx.Where("id_pemakaian = ?", id).Delete(&models.RiwayatPemakaian{}).Error; err != nil {
+			return nil, err
+		}
+		// Berkas lampiran dihapus oleh controller setelah transaksi berhasil
+		if err := tx.Where("id_pemakaian = ?", id).Delete(&models.LampiranPemakaian{}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("id_pemakaian = ?", id).Delete(&models.PemakaianDana{}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("id_pemakaian = ?", id).Delete(&models.PemakaianSaldo{}).Error; err != nil {
+			return nil, err
+		}
+		return jurnal.BatalkanSumber(TargetPemakaian, id, adminID)
+	})
+}
+
+// cekSaldoKas memastikan saldo kas setiap dana cukup untuk nominal tambahannya (kode dana -> nominal)
+func cekSaldoKas(jurnal *JurnalService, tambahan map[string]float64) error {
+	akunKas, err := akunKasDana(jurnal.db)
+	if err != nil {
+		return err
+	}
+	for kodeDana, nominal := range tambahan {
+		if nominal <= 0 {
+			continue
+		}
+		kodeAkun, ok := akunKas[kodeDana]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrDanaTidakValid, kodeDana)
+		}
+		saldo, err := jurnal.SaldoAkun(kodeAkun)
+		if err != nil {
+			return err
+		}
+		if nominal > saldo+0.005 {
+			return fmt.Errorf("%w: saldo dana %s Rp %.0f", ErrSaldoTidakCukup, kodeDana, saldo)
+		}
+	}
+	return nil
+}
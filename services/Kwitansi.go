@@ -0,0 +1,419 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// penerimaPengganti dicetak di tanda tangan jika pengguna penerima tidak ditemukan
+const penerimaPengganti = "Pengurus TPQ"
+
+// KwitansiService menerbitkan dan mencetak kwitansi penerimaan uang.
+// Penerbitan dipanggil di dalam transaksi pembayaran agar nomor hanya terpakai jika pembayaran tersimpan.
+type KwitansiService struct {
+	db *gorm.DB
+}
+
+func NewKwitansiService(db *gorm.DB) *KwitansiService {
+	return &KwitansiService{db: db}
+}
+
+// nomorBerikutnya mengambil nomor urut berikutnya untuk tahun tertentu dengan mengunci baris penghitung
+func (s *KwitansiService) nomorBerikutnya(tahun int) (int, error) {
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.NomorKwitansi{Tahun: tahun}).Error; err != nil {
+		return 0, err
+	}
+
+	var nomor models.NomorKwitansi
+	if err := s.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("tahun = ?", tahun).First(&nomor).Error; err != nil {
+		return 0, err
+	}
+	nomor.Terakhir++
+	if err := s.db.Model(&models.NomorKwitansi{}).Where("tahun = ?", tahun).Update("terakhir", nomor.Terakhir).Error; err != nil {
+		return 0, err
+	}
+	return nomor.Terakhir, nil
+}
+
+// indeksKwitansiSumberLama adalah indeks unik per sumber sebelum kwitansi bisa diterbitkan ulang
+const indeksKwitansiSumberLama = "idx_kwitansi_sumber"
+
+// MigrasiIndeksKwitansi menghapus indeks unik lama kwitansi per sumber, karena satu sumber kini bisa
+// memiliki kwitansi batal dan penggantinya. Aman dijalankan berulang.
+func MigrasiIndeksKwitansi(db *gorm.DB) error {
+	if !db.Migrator().HasIndex(&models.Kwitansi{}, indeksKwitansiSumberLama) {
+		return nil
+	}
+	return db.Migrator().DropIndex(&models.Kwitansi{}, indeksKwitansiSumberLama)
+}
+
+// simpan menerbitkan kwitansi untuk sumbernya. Kwitansi berlaku yang isinya sama dipakai apa adanya;
+// jika isinya berubah, kwitansi lama dibatalkan dan diterbitkan kwitansi baru dengan nomor baru
+// agar lembar yang sudah diserahkan tidak berbeda dengan catatan di sistem.
+func (s *KwitansiService) simpan(kwitansi *models.Kwitansi) error {
+	var lama models.Kwitansi
+	err := s.db.Where("tipe_sumber = ? AND id_sumber = ? AND status = ?", kwitansi.TipeSumber, kwitansi.IDSumber, models.KwitansiBerlaku).
+		Order("waktu_terbit DESC").
+		First(&lama).Error
+	if err == nil {
+		if samaIsiKwitansi(lama, *kwitansi) {
+			*kwitansi = lama
+			return nil
+		}
+		if err := s.Batalkan(kwitansi.TipeSumber, kwitansi.IDSumber); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	sekarang := time.Now()
+	urutan, err := s.nomorBerikutnya(sekarang.Year())
+	if err != nil {
+		return err
+	}
+	kwitansi.IDKwitansi = uuid.New().String()
+	kwitansi.Tahun = sekarang.Year()
+	kwitansi.Urutan = urutan
+	kwitansi.Nomor = fmt.Sprintf("KW-%d-%05d", sekarang.Year(), urutan)
+	kwitansi.Status = models.KwitansiBerlaku
+	kwitansi.WaktuTerbit = sekarang
+	return s.db.Omit("Penerima").Create(kwitansi).Error
+}
+
+// samaIsiKwitansi membandingkan isi yang tercetak di kwitansi
+func samaIsiKwitansi(a, b models.Kwitansi) bool {
+	return a.NamaPembayar == b.NamaPembayar &&
+		a.Untuk == b.Untuk &&
+		rupiahBulat(a.Nominal) == rupiahBulat(b.Nominal) &&
+		a.Metode == b.Metode &&
+		a.Rekening == b.Rekening &&
+		a.TanggalBayar.Equal(b.TanggalBayar) &&
+		a.DiterimaOleh == b.DiterimaOleh
+}
+
+// rekeningKwitansi menyusun keterangan rekening penerima untuk kwitansi beserta jenisnya.
+// Transaksi tanpa rekening dianggap masuk ke rekening utama.
+func (s *KwitansiService) rekeningKwitansi(idRekening *string) (string, models.JenisRekening, error) {
+	id, err := rekeningJurnal(s.db, idRekening)
+	if err != nil || id == nil {
+		return "", "", err
+	}
+	var rekening models.Rekening
+	if err := s.db.Where("id_rekening = ?", *id).First(&rekening).Error; err != nil {
+		return "", "", err
+	}
+	if rekening.Jenis == models.RekeningTunai || rekening.NomorRekening == "" {
+		return rekening.NamaRekening, rekening.Jenis, nil
+	}
+	label := strings.TrimSpace(rekening.NamaBank + " " + rekening.NomorRekening)
+	if rekening.AtasNama != "" {
+		label += " a.n. " + rekening.AtasNama
+	}
+	return label, rekening.Jenis, nil
+}
+
+// TerbitkanPembayaranSyahriah menerbitkan kwitansi untuk satu pembayaran syahriah atas nama wali santri
+func (s *KwitansiService) TerbitkanPembayaranSyahriah(pembayaran models.PembayaranSyahriah) (*models.Kwitansi, error) {
+	var syahriah models.Syahriah
+	if err := s.db.Preload("Santri").Preload("Santri.Wali").
+		Where("id_syahriah = ?", pembayaran.IDSyahriah).
+		First(&syahriah).Error; err != nil {
+		return nil, err
+	}
+
+	namaPembayar := syahriah.Santri.Wali.NamaLengkap
+	if namaPembayar == "" {
+		namaPembayar = "Wali " + syahriah.Santri.NamaLengkap
+	}
+	idSantri := syahriah.Santri.IDSantri
+	idWali := syahriah.Santri.IDWali
+	rekening, _, err := s.rekeningKwitansi(pembayaran.IDRekening)
+	if err != nil {
+		return nil, err
+	}
+
+	kwitansi := &models.Kwitansi{
+		TipeSumber:   TargetPembayaranSyahriah,
+		IDSumber:     pembayaran.IDPembayaran,
+		IDSantri:     &idSantri,
+		IDWali:       &idWali,
+		NamaPembayar: namaPembayar,
+		Untuk:        fmt.Sprintf("Syahriah %s a.n. %s", utils.BulanIndonesia(syahriah.Bulan), syahriah.Santri.NamaLengkap),
+		Nominal:      pembayaran.Nominal,
+		Metode:       string(pembayaran.Metode),
+		Rekening:     rekening,
+		TanggalBayar: pembayaran.TanggalBayar,
+		DiterimaOleh: pembayaran.DiterimaOleh,
+	}
+	if err := s.simpan(kwitansi); err != nil {
+		return nil, err
+	}
+	return kwitansi, nil
+}
+
+// TerbitkanDonasi menerbitkan kwitansi donasi atas nama donatur, atau NamaAnonim jika donatur memilih
+// anonim. Metode diambil dari QRIS asal donasi atau jenis rekening penerimanya. Jika donasi diubah,
+// kwitansi lama dibatalkan dan diganti kwitansi baru.
+func (s *KwitansiService) TerbitkanDonasi(donasi models.Donasi) (*models.Kwitansi, error) {
+	namaPembayar := strings.TrimSpace(donasi.NamaDonatur)
+	if donasi.Anonim || namaPembayar == "" {
+		namaPembayar = NamaAnonim
+	}
+
+	rekening, jenis, err := s.rekeningKwitansi(donasi.IDRekening)
+	if err != nil {
+		return nil, err
+	}
+	metode := models.MetodeTransfer
+	if jenis == models.RekeningTunai {
+		metode = models.MetodeTunai
+	}
+	var lewatQRIS int64
+	if err := s.db.Model(&models.PermintaanQRIS{}).Where("id_donasi = ?", donasi.IDDonasi).Count(&lewatQRIS).Error; err != nil {
+		return nil, err
+	}
+	if lewatQRIS > 0 {
+		metode = models.MetodeQRIS
+	}
+
+	kwitansi := &models.Kwitansi{
+		TipeSumber:   TargetDonasi,
+		IDSumber:     donasi.IDDonasi,
+		NamaPembayar: namaPembayar,
+		Untuk:        "Donasi / infaq TPQ",
+		Nominal:      donasi.Nominal,
+		Metode:       string(metode),
+		Rekening:     rekening,
+		TanggalBayar: donasi.WaktuCatat,
+		DiterimaOleh: donasi.DicatatOleh,
+	}
+	if err := s.simpan(kwitansi); err != nil {
+		return nil, err
+	}
+	return kwitansi, nil
+}
+
+// Batalkan menandai kwitansi milik sumber yang dihapus sebagai batal, nomornya tetap tercatat
+func (s *KwitansiService) Batalkan(tipeSumber, idSumber string) error {
+	sekarang := time.Now()
+	return s.db.Model(&models.Kwitansi{}).
+		Where("tipe_sumber = ? AND id_sumber = ? AND status = ?", tipeSumber, idSumber, models.KwitansiBerlaku).
+		Updates(map[string]interface{}{"status": models.KwitansiBatal, "waktu_batal": sekarang}).Error
+}
+
+// KwitansiSumber mengambil kwitansi terbaru milik sumber tanpa menerbitkan yang baru. Pembayaran
+// lama sebelum kwitansi diterapkan sudah diberi nomor oleh MigrasiKwitansiLama.
+func (s *KwitansiService) KwitansiSumber(tipeSumber, idSumber string) (*models.Kwitansi, error) {
+	return s.kwitansiTerbaru(tipeSumber, idSumber)
+}
+
+// MigrasiKwitansiLama menerbitkan kwitansi untuk pembayaran syahriah dan donasi yang dicatat sebelum
+// kwitansi diterapkan, urut tanggal agar nomornya mengikuti urutan penerimaan. Sumber yang sudah
+// punya kwitansi (berlaku maupun batal) dilewati sehingga aman dijalankan berulang.
+func MigrasiKwitansiLama(db *gorm.DB) (int, error) {
+	jumlah := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		kwitansiService := NewKwitansiService(tx)
+
+		var pembayaran []models.PembayaranSyahriah
+		if err := tanpaKwitansi(tx, TargetPembayaranSyahriah, "pembayaran_syahriah.id_pembayaran").
+			Order("tanggal_bayar ASC").
+			Find(&pembayaran).Error; err != nil {
+			return err
+		}
+		for _, p := range pembayaran {
+			if _, err := kwitansiService.TerbitkanPembayaranSyahriah(p); err != nil {
+				return fmt.Errorf("pembayaran %s: %w", p.IDPembayaran, err)
+			}
+			jumlah++
+		}
+
+		var donasi []models.Donasi
+		if err := tanpaKwitansi(tx, TargetDonasi, "donasi.id_donasi").
+			Order("waktu_catat ASC").
+			Find(&donasi).Error; err != nil {
+			return err
+		}
+		for _, d := range donasi {
+			if _, err := kwitansiService.TerbitkanDonasi(d); err != nil {
+				return fmt.Errorf("donasi %s: %w", d.IDDonasi, err)
+			}
+			jumlah++
+		}
+		return nil
+	})
+	return jumlah, err
+}
+
+// tanpaKwitansi membatasi query pada baris sumber yang belum pernah diberi kwitansi
+func tanpaKwitansi(db *gorm.DB, tipeSumber, kolomID string) *gorm.DB {
+	return db.Where("NOT EXISTS (SELECT 1 FROM kwitansi WHERE kwitansi.tipe_sumber = ? AND kwitansi.id_sumber = "+kolomID+")", tipeSumber)
+}
+
+// kwitansiTerbaru mengambil kwitansi terakhir yang diterbitkan untuk sumber, berlaku maupun batal
+func (s *KwitansiService) kwitansiTerbaru(tipeSumber, idSumber string) (*models.Kwitansi, error) {
+	var kwitansi models.Kwitansi
+	err := s.db.Where("tipe_sumber = ? AND id_sumber = ?", tipeSumber, idSumber).
+		Order("waktu_terbit DESC").
+		First(&kwitansi).Error
+	if err != nil {
+		return nil, err
+	}
+	return &kwitansi, nil
+}
+
+// PDF mencetak kwitansi berukuran A5 mendatar dengan identitas TPQ dari InformasiTPQ
+func (s *KwitansiService) PDF(kwitansi models.Kwitansi) ([]byte, error) {
+	// Penerima yang akunnya sudah dihapus, atau donasi yang tercatat otomatis, ditandatangani atas nama pengurus
+	if kwitansi.Penerima.IDUser == "" && kwitansi.DiterimaOleh != "" {
+		err := s.db.Where("id_user = ?", kwitansi.DiterimaOleh).First(&kwitansi.Penerima).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if kwitansi.Penerima.NamaLengkap == "" {
+		kwitansi.Penerima.NamaLengkap = penerimaPengganti
+	}
+
+	pdf := utils.NewPDF(utils.A5Tinggi, utils.A5Lebar)
+	kiri, kanan := 36.0, pdf.Lebar()-36
+
+	tpq := NewKopTPQ(s.db)
+	y := tpq.Gambar(pdf, kiri, kanan, 30)
+
+	pdf.Teks(pdf.Lebar()/2, y+28, 18, true, utils.RataTengah, "KWITANSI")
+	pdf.Teks(pdf.Lebar()/2, y+44, 10, false, utils.RataTengah, "No. "+kwitansi.Nomor)
+
+	y += 74
+	baris := func(label, isi string, tebal bool) {
+		pdf.Teks(kiri, y, 10, false, utils.RataKiri, label)
+		pdf.Teks(kiri+120, y, 10, false, utils.RataKiri, ":")
+		y = pdf.Paragraf(kiri+130, y, kanan-kiri-130, 10, tebal, isi) + 6
+	}
+	baris("Telah terima dari", kwitansi.NamaPembayar, true)
+
+	terbilang := utils.Terbilang(int64(kwitansi.Nominal + 0.5))
+	terbilang = strings.ToUpper(terbilang[:1]) + terbilang[1:] + " rupiah"
+	pdf.Kotak(kiri+128, y-12, kanan-kiri-128, 18, 0, 0.92)
+	baris("Uang sejumlah", terbilang, false)
+	baris("Untuk pembayaran", kwitansi.Untuk, false)
+	if kwitansi.Metode != "" {
+		baris("Metode", strings.ToUpper(kwitansi.Metode[:1])+kwitansi.Metode[1:], false)
+	}
+	if kwitansi.Rekening != "" && kwitansi.Metode != string(models.MetodeTunai) {
+		baris("Rekening penerima", kwitansi.Rekening, false)
+	}
+
+	// Nominal angka di kiri bawah, tanda tangan penerima di kanan bawah
+	y += 14
+	pdf.Kotak(kiri, y, 170, 30, 1.2, 0)
+	pdf.Teks(kiri+85, y+20, 14, true, utils.RataTengah, utils.FormatRupiah(kwitansi.Nominal))
+
+	tempat := tpq.Tempat
+	if tempat != "" {
+		tempat += ", "
+	}
+	xTtd := kanan - 90
+	pdf.Teks(xTtd, y, 10, false, utils.RataTengah, tempat+utils.TanggalIndonesia(kwitansi.TanggalBayar))
+	pdf.Teks(xTtd, y+14, 10, false, utils.RataTengah, "Penerima,")
+	pdf.Teks(xTtd, y+64, 10, true, utils.RataTengah, kwitansi.Penerima.NamaLengkap)
+	pdf.Garis(xTtd-70, y+67, xTtd+70, y+67, 0.5)
+
+	if kwitansi.Status == models.KwitansiBatal {
+		pdf.Teks(kiri, y+64, 16, true, utils.RataKiri, "DIBATALKAN")
+	}
+
+	pdf.Teks(kiri, pdf.Tinggi()-20, 7, false, utils.RataKiri,
+		fmt.Sprintf("Dicetak %s - kwitansi ini sah tanpa stempel apabila nomor tercatat di sistem", time.Now().Format("02-01-2006 15:04")))
+	return pdf.Bytes(), nil
+}
+
+// KopTPQ adalah identitas TPQ untuk kop dokumen cetak
+type KopTPQ struct {
+	Nama   string
+	Alamat string
+	Kontak string
+	Tempat string
+	Kepala string // nama kepala TPQ
+	logo   string
+}
+
+// NewKopTPQ memuat identitas TPQ dari InformasiTPQ, memakai nama default jika belum diisi
+func NewKopTPQ(db *gorm.DB) KopTPQ {
+	kop := KopTPQ{Nama: "TPQ Asy-Syafi'i"}
+
+	var info models.InformasiTPQ
+	if err := db.Order("dibuat_pada ASC").First(&info).Error; err != nil {
+		return kop
+	}
+	kop.Nama = info.NamaTPQ
+	if info.Alamat != nil {
+		kop.Alamat = *info.Alamat
+	}
+	if info.Tempat != nil {
+		kop.Tempat = *info.Tempat
+	}
+	if info.NamaKepala != nil {
+		kop.Kepala = *info.NamaKepala
+	}
+
+	var kontak []string
+	if info.NoTelp != nil && *info.NoTelp != "" {
+		kontak = append(kontak, "Telp. "+*info.NoTelp)
+	}
+	if info.Email != nil && *info.Email != "" {
+		kontak = append(kontak, *info.Email)
+	}
+	kop.Kontak = strings.Join(kontak, " | ")
+
+	if info.Logo != nil && *info.Logo != "" {
+		kop.logo = filepath.Join(".", "image", "tpq", filepath.Base(*info.Logo))
+	}
+	return kop
+}
+
+// Gambar menulis kop (logo, nama, alamat dan garis) mulai dari y, lalu mengembalikan y di bawah garis kop
+func (k KopTPQ) Gambar(pdf *utils.PDF, kiri, kanan, y float64) float64 {
+	xTeks := kiri
+	if k.logo != "" {
+		if f, err := os.Open(k.logo); err == nil {
+			if pdf.Gambar(f, kiri, y, 48, 48) == nil {
+				xTeks = kiri + 60
+			}
+			f.Close()
+		}
+	}
+
+	pdf.Teks(xTeks, y+16, 15, true, utils.RataKiri, k.Nama)
+	baris := y + 30
+	if k.Alamat != "" {
+		for _, b := range utils.BungkusTeks(k.Alamat, kanan-xTeks, 8.5, false) {
+			pdf.Teks(xTeks, baris, 8.5, false, utils.RataKiri, b)
+			baris += 11
+		}
+	}
+	if k.Kontak != "" {
+		pdf.Teks(xTeks, baris, 8.5, false, utils.RataKiri, k.Kontak)
+		baris += 11
+	}
+
+	bawah := baris
+	if y+52 > bawah {
+		bawah = y + 52
+	}
+	pdf.Garis(kiri, bawah, kanan, bawah, 1.5)
+	pdf.Garis(kiri, bawah+2.5, kanan, bawah+2.5, 0.5)
+	return bawah + 2.5
+}
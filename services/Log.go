@@ -37,11 +37,20 @@ const (
 	AksiUpdate = "UPDATE" 
 	AksiDelete = "DELETE"
 	AksiLogin  = "LOGIN"
+	AksiTutupBuku = "TUTUP_BUKU"
+	AksiBukaBuku  = "BUKA_BUKU"
+	AksiGenerateTagihan = "GENERATE_TAGIHAN"
+	AksiImpor = "IMPOR"
 )
 
 // Constants untuk tipe target
 const (
-	TargetDonasi   = "DONASI"
-	TargetUser     = "USER"
-	TargetSyahriah = "SYAHRIAH"
+	TargetDonasi             = "DONASI"
+	TargetUser               = "USER"
+	TargetSyahriah           = "SYAHRIAH"
+	TargetPemakaian          = "PEMAKAIAN"
+	TargetRekap              = "REKAP"
+	TargetPembayaranSyahriah = "PEMBAYARAN_SYAHRIAH"
+	TargetSantri             = "SANTRI"
+	TargetTransferRekening   = "TRANSFER_REKENING"
 )	
\ No newline at end of file
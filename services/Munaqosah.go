@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// NilaiLulusMunaqosah adalah nilai akhir minimal agar santri naik jilid
+	NilaiLulusMunaqosah = 70
+	// NilaiMinimalAspek adalah nilai minimal setiap aspek rubrik; satu aspek di bawahnya berarti tidak lulus
+	NilaiMinimalAspek = 60
+)
+
+var (
+	// ErrMunaqosahTidakValid dikembalikan jika jadwal atau nilai munaqosah tidak sesuai aturan
+	ErrMunaqosahTidakValid = errors.New("data munaqosah tidak valid")
+	// ErrMunaqosahSelesai dikembalikan jika munaqosah sudah dinilai atau dibatalkan
+	ErrMunaqosahSelesai = errors.New("munaqosah sudah dinilai atau dibatalkan")
+	// ErrBelumLulusMunaqosah dikembalikan jika setoran Iqro melompati jilid yang belum diujikan
+	ErrBelumLulusMunaqosah = errors.New("santri belum lulus munaqosah untuk naik ke jilid ini")
+)
+
+// AspekMunaqosah adalah satu aspek penilaian pada rubrik munaqosah
+type AspekMunaqosah struct {
+	Kode       string `json:"kode"`
+	Nama       string `json:"nama"`
+	Bobot      int    `json:"bobot"` // persen dari nilai akhir
+	Keterangan string `json:"keterangan"`
+}
+
+// RubrikMunaqosah adalah rubrik penilaian ujian kenaikan jilid. Jumlah bobot 100.
+var RubrikMunaqosah = []AspekMunaqosah{
+	{"makhraj", "Makhraj", 40, "Ketepatan tempat keluar huruf dan sifat huruf"},
+	{"tajwid", "Tajwid", 30, "Penerapan hukum bacaan dan panjang pendek sesuai materi jilid"},
+	{"kelancaran", "Kelancaran", 30, "Membaca lancar tanpa mengeja dan tanpa banyak berhenti"},
+}
+
+// HitungNilaiMunaqosah menghitung nilai akhir berbobot dan status lulusnya
+func HitungNilaiMunaqosah(makhraj, tajwid, kelancaran int) (float64, bool) {
+	nilai := []int{makhraj, tajwid, kelancaran}
+	total := 0
+	lulus := true
+	for i, aspek := range RubrikMunaqosah {
+		total += nilai[i] * aspek.Bobot
+		if nilai[i] < NilaiMinimalAspek {
+			lulus = false
+		}
+	}
+	akhir := float64(total) / 100
+	return akhir, lulus && akhir >= NilaiLulusMunaqosah
+}
+
+// jilidIqroTertinggi mengembalikan jilid tertinggi yang sudah dicapai santri, baik dari setoran
+// Iqro yang lulus maupun dari munaqosah yang lulus. 0 berarti santri belum punya riwayat Iqro.
+func jilidIqroTertinggi(db *gorm.DB, idSantri string) (int, error) {
+	var dariSetoran, dariMunaqosah int
+	if err := db.Model(&models.SetoranSantri{}).
+		Where("id_santri = ? AND jenis = ? AND lulus = ?", idSantri, models.SetoranIqro, true).
+		Select("COALESCE(MAX(jilid), 0)").Scan(&dariSetoran).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Model(&models.Munaqosah{}).
+		Where("id_santri = ? AND status = ?", idSantri, models.MunaqosahLulus).
+		Select("COALESCE(MAX(jilid), 0)").Scan(&dariMunaqosah).Error; err != nil {
+		return 0, err
+	}
+	if dariMunaqosah > 0 {
+		dariMunaqosah = min(dariMunaqosah+1, JumlahJilidIqro)
+	}
+	return max(dariSetoran, dariMunaqosah), nil
+}
+
+// JadwalkanMunaqosah menjadwalkan ujian kenaikan jilid. Santri harus sedang berada di jilid yang
+// diujikan, belum lulus jilid itu dan tidak punya jadwal munaqosah lain yang belum dinilai.
+// Percobaan dihitung dari ujian jilid yang sama yang tidak dibatalkan.
+func JadwalkanMunaqosah(db *gorm.DB, m *models.Munaqosah) error {
+	if m.Jilid < 1 || m.Jilid > JumlahJilidIqro {
+		return fmt.Errorf("%w: jilid harus 1-%d", ErrMunaqosahTidakValid, JumlahJilidIqro)
+	}
+
+	var penguji models.User
+	if err := db.Where("id_user = ?", m.IDPenguji).First(&penguji).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: penguji tidak ditemukan", ErrMunaqosahTidakValid)
+		}
+		return err
+	}
+	if penguji.Role != models.RoleUstadz && penguji.Role != models.RoleAdmin && penguji.Role != models.RoleSuperAdmin {
+		return fmt.Errorf("%w: penguji harus ustadz atau admin", ErrMunaqosahTidakValid)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var santri models.Santri
+		if err := tx.Where("id_santri = ?", m.IDSantri).First(&santri).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: santri tidak ditemukan", ErrMunaqosahTidakValid)
+			}
+			return err
+		}
+		if santri.Status != models.StatusAktifSantri {
+			return fmt.Errorf("%w: %s", ErrSantriTidakAktif, santri.NamaLengkap)
+		}
+
+		var tertunda int64
+		if err := tx.Model(&models.Munaqosah{}).
+			Where("id_santri = ? AND status = ?", m.IDSantri, models.MunaqosahDijadwalkan).
+			Count(&tertunda).Error; err != nil {
+			return err
+		}
+		if tertunda > 0 {
+			return fmt.Errorf("%w: santri masih punya jadwal munaqosah yang belum dinilai", ErrMunaqosahTidakValid)
+		}
+
+		var riwayat []models.Munaqosah
+		if err := tx.Where("id_santri = ? AND jilid = ? AND status <> ?", m.IDSantri, m.Jilid, models.MunaqosahBatal).
+			Find(&riwayat).Error; err != nil {
+			return err
+		}
+		for _, r := range riwayat {
+			if r.Status == models.MunaqosahLulus {
+				return fmt.Errorf("%w: santri sudah lulus munaqosah jilid %d", ErrMunaqosahTidakValid, m.Jilid)
+			}
+		}
+
+		jilid, err := jilidIqroTertinggi(tx, m.IDSantri)
+		if err != nil {
+			return err
+		}
+		if jilid != m.Jilid {
+			return fmt.Errorf("%w: posisi santri saat ini Iqro jilid %d", ErrMunaqosahTidakValid, jilid)
+		}
+
+		if m.IDMunaqosah == "" {
+			m.IDMunaqosah = uuid.New().String()
+		}
+		m.Percobaan = len(riwayat) + 1
+		m.Status = models.MunaqosahDijadwalkan
+		return tx.Create(m).Error
+	})
+}
+
+// NilaiMunaqosah menyimpan nilai rubrik dan menentukan lulus atau tidaknya munaqosah. Kelulusan
+// langsung menaikkan jilid santri pada perhitungan perkembangan.
+func NilaiMunaqosah(db *gorm.DB, m *models.Munaqosah, makhraj, tajwid, kelancaran int, catatan string, tanggal time.Time, penilaiID string) error {
+	if m.Status != models.MunaqosahDijadwalkan {
+		return ErrMunaqosahSelesai
+	}
+	for _, n := range []int{makhraj, tajwid, kelancaran} {
+		if n < 0 || n > 100 {
+			return fmt.Errorf("%w: nilai setiap aspek harus 0-100", ErrMunaqosahTidakValid)
+		}
+	}
+
+	akhir, lulus := HitungNilaiMunaqosah(makhraj, tajwid, kelancaran)
+	m.NilaiMakhraj, m.NilaiTajwid, m.NilaiKelancaran = &makhraj, &tajwid, &kelancaran
+	m.NilaiAkhir = &akhir
+	m.Catatan = catatan
+	m.TanggalUjian = &tanggal
+	m.DinilaiOleh = &penilaiID
+	m.Status = models.MunaqosahTidakLulus
+	if lulus {
+		m.Status = models.MunaqosahLulus
+	}
+
+	// Status dicek ulang di WHERE agar dua penilaian bersamaan tidak saling menimpa
+	hasil := db.Model(&models.Munaqosah{}).
+		Where("id_munaqosah = ? AND status = ?", m.IDMunaqosah, models.MunaqosahDijadwalkan).
+		Updates(map[string]interface{}{
+			"nilai_makhraj":    makhraj,
+			"nilai_tajwid":     tajwid,
+			"nilai_kelancaran": kelancaran,
+			"nilai_akhir":      akhir,
+			"catatan":          catatan,
+			"tanggal_ujian":    tanggal,
+			"dinilai_oleh":     penilaiID,
+			"status":           m.Status,
+		})
+	if hasil.Error != nil {
+		return hasil.Error
+	}
+	if hasil.RowsAffected == 0 {
+		return ErrMunaqosahSelesai
+	}
+	return nil
+}
+
+// MuatMunaqosahLulus mengambil munaqosah yang lulus urut dari tanggal ujian paling lama
+func MuatMunaqosahLulus(db *gorm.DB, idSantri []string) ([]models.Munaqosah, error) {
+	var munaqosah []models.Munaqosah
+	if len(idSantri) == 0 {
+		return munaqosah, nil
+	}
+	err := db.Where("id_santri IN ? AND status = ?", idSantri, models.MunaqosahLulus).
+		Order("tanggal_ujian ASC, jilid ASC").
+		Find(&munaqosah).Error
+	return munaqosah, err
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrFormatMutasi dikembalikan jika header kolom tanggal dan nominal tidak ditemukan di file mutasi
+var ErrFormatMutasi = errors.New("format file mutasi tidak dikenali: kolom tanggal dan kredit/jumlah tidak ditemukan")
+
+// BarisMutasi adalah satu transaksi kredit hasil pembacaan file mutasi rekening
+type BarisMutasi struct {
+	Baris      int
+	Tanggal    time.Time
+	Keterangan string
+	Nominal    float64
+	SidikJari  string
+}
+
+// HasilBacaMutasi merangkum pembacaan file mutasi
+type HasilBacaMutasi struct {
+	Format      string
+	JumlahBaris int // baris transaksi (kredit dan debit)
+	Kredit      []BarisMutasi
+}
+
+// kolomMutasi menyimpan indeks kolom yang dikenali dari header, -1 jika tidak ada
+type kolomMutasi struct {
+	tanggal, keterangan, kredit, debit, jumlah, dbcr int
+}
+
+var polaTanggalLengkap = regexp.MustCompile(`\b\d{1,2}[/-]\d{1,2}[/-](\d{4})\b`)
+
+// BacaMutasiBank membaca tabel hasil utils.BacaTabel dari ekspor mutasi rekening bank.
+// Header dikenali dari nama kolom sehingga layout umum KlikBCA, Livin' Mandiri, BRI dan BNI
+// dapat dibaca tanpa konfigurasi: kolom kredit/debet terpisah, atau satu kolom jumlah dengan
+// penanda CR/DB. Tanggal tanpa tahun (KlikBCA) memakai tahun dari baris periode di atas header,
+// atau tahunDefault jika tidak ada. Hanya baris kredit yang dikembalikan.
+func BacaMutasiBank(tabel [][]string, tahunDefault int) (*HasilBacaMutasi, error) {
+	barisHeader := -1
+	var kolom kolomMutasi
+	for i, row := range tabel {
+		if i > 40 {
+			break
+		}
+		k := kenaliKolomMutasi(row)
+		if k.tanggal >= 0 && (k.kredit >= 0 || k.jumlah >= 0) {
+			barisHeader, kolom = i, k
+			break
+		}
+	}
+	if barisHeader < 0 {
+		return nil, ErrFormatMutasi
+	}
+
+	// Tahun dari baris periode, misalnya "Periode : 01/02/2025 - 28/02/2025"
+	tahun := tahunDefault
+	for _, row := range tabel[:barisHeader] {
+		if m := polaTanggalLengkap.FindStringSubmatch(strings.Join(row, " ")); m != nil {
+			tahun, _ = strconv.Atoi(m[1])
+			break
+		}
+	}
+
+	hasil := &HasilBacaMutasi{Format: tebakFormatMutasi(tabel[barisHeader])}
+	kembar := map[string]int{}
+	for i, row := range tabel[barisHeader+1:] {
+		sel := func(j int) string {
+			if j < 0 || j >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[j])
+		}
+
+		// Baris tanpa tanggal valid adalah ringkasan atau catatan kaki (saldo awal, total mutasi, dll)
+		tanggal, ok := parseTanggalMutasi(sel(kolom.tanggal), tahun)
+		if !ok {
+			continue
+		}
+
+		var nominal float64
+		kredit := false
+		if kolom.kredit >= 0 {
+			nominal, _ = parseNominalMutasi(sel(kolom.kredit))
+			kredit = nominal > 0
+		} else {
+			var penanda string
+			nominal, penanda = parseNominalMutasi(sel(kolom.jumlah))
+			if kolom.dbcr >= 0 && penanda == "" {
+				penanda = strings.ToUpper(sel(kolom.dbcr))
+			}
+			// KlikBCA menaruh penanda CR/DB di kolom tanpa judul setelah jumlah
+			if setelah := strings.ToUpper(sel(kolom.jumlah + 1)); penanda == "" && (setelah == "CR" || setelah == "DB") {
+				penanda = setelah
+			}
+			switch {
+			case strings.HasPrefix(penanda, "C"), penanda == "K":
+				kredit = true
+			case strings.HasPrefix(penanda, "D"):
+				kredit = false
+			default:
+				kredit = nominal > 0
+			}
+			if nominal < 0 {
+				nominal = -nominal
+			}
+		}
+
+		hasil.JumlahBaris++
+		if !kredit || nominal <= 0 {
+			continue
+		}
+
+		keterangan := strings.Join(strings.Fields(sel(kolom.keterangan)), " ")
+		// Transaksi identik di hari yang sama dibedakan dengan urutan kemunculannya
+		kunci := fmt.Sprintf("%s|%.2f|%s", tanggal.Format("2006-01-02"), nominal, strings.ToUpper(keterangan))
+		kembar[kunci]++
+		jejak := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", kunci, kembar[kunci])))
+
+		hasil.Kredit = append(hasil.Kredit, BarisMutasi{
+			Baris:      barisHeader + i + 2,
+			Tanggal:    tanggal,
+			Keterangan: keterangan,
+			Nominal:    nominal,
+			SidikJari:  hex.EncodeToString(jejak[:]),
+		})
+	}
+	return hasil, nil
+}
+
+func kenaliKolomMutasi(row []string) kolomMutasi {
+	k := kolomMutasi{-1, -1, -1, -1, -1, -1}
+	for i, h := range row {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		mengandung := func(kata ...string) bool {
+			for _, s := range kata {
+				if strings.Contains(h, s) {
+					return true
+				}
+			}
+			return false
+		}
+		switch {
+		case k.tanggal < 0 && (mengandung("tanggal", "tgl", "date") && !mengandung("val", "efektif")):
+			k.tanggal = i
+		case k.dbcr < 0 && (h == "db/cr" || h == "cr/db" || h == "d/k" || h == "k/d" || h == "dk" || h == "jenis" || h == "tipe"):
+			k.dbcr = i
+		case k.kredit < 0 && (mengandung("kredit", "credit") || h == "cr" || h == "k"):
+			k.kredit = i
+		case k.debit < 0 && (mengandung("debet", "debit") || h == "db" || h == "d"):
+			k.debit = i
+		case k.jumlah < 0 && mengandung("jumlah", "mutasi", "amount", "nominal", "nilai"):
+			k.jumlah = i
+		case k.keterangan < 0 && mengandung("keterangan", "deskripsi", "description", "uraian", "desk", "remark", "berita", "transaksi"):
+			k.keterangan = i
+		}
+	}
+	return k
+}
+
+// tebakFormatMutasi memberi label layout bank dari nama kolomnya (hanya informasi, tidak mempengaruhi pembacaan)
+func tebakFormatMutasi(header []string) string {
+	gabung := strings.ToLower(strings.Join(header, "|"))
+	switch {
+	case strings.Contains(gabung, "desk_tran") || strings.Contains(gabung, "mutasi_kredit"):
+		return "bri"
+	case strings.Contains(gabung, "val. date") || strings.Contains(gabung, "reference no"):
+		return "mandiri"
+	case strings.Contains(gabung, "cabang"):
+		return "bca"
+	case strings.Contains(gabung, "journal no") || strings.Contains(gabung, "db/cr"):
+		return "bni"
+	}
+	return "umum"
+}
+
+var bulanSingkatIndonesia = strings.NewReplacer("Mei", "May", "Agu", "Aug", "Agt", "Aug", "Okt", "Oct", "Des", "Dec",
+	"MEI", "MAY", "AGU", "AUG", "AGT", "AUG", "OKT", "OCT", "DES", "DEC")
+
+// parseTanggalMutasi membaca tanggal dalam format yang umum dipakai ekspor bank (hari lebih dulu)
+func parseTanggalMutasi(s string, tahun int) (time.Time, bool) {
+	s = strings.TrimSpace(strings.TrimPrefix(s, "'"))
+	if s == "" {
+		return time.Time{}, false
+	}
+	s = bulanSingkatIndonesia.Replace(s)
+	for _, layout := range []string{
+		"02/01/2006", "2/1/2006", "02/01/06", "2/1/06", "02-01-2006", "2-1-2006", "02-01-06",
+		"2006-01-02", "2006/01/02", "02 Jan 2006", "2 Jan 2006", "02-Jan-2006", "02-Jan-06", "02 Jan 06",
+		"02/01/2006 15:04:05", "02/01/2006 15:04", "2006-01-02 15:04:05", "02/01/06 15:04:05", "02-01-2006 15:04:05",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local), true
+		}
+	}
+	// KlikBCA hanya menulis tanggal dan bulan
+	if t, err := time.Parse("02/01", s); err == nil {
+		return time.Date(tahun, t.Month(), t.Day(), 0, 0, 0, 0, time.Local), true
+	}
+	return time.Time{}, false
+}
+
+// parseNominalMutasi membaca nominal seperti "1.500.000,00", "1,500,000.00 CR" atau "(250000)".
+// Mengembalikan nilai dan penanda CR/DB jika ada.
+func parseNominalMutasi(s string) (float64, string) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	penanda := ""
+	for _, p := range []string{"CR", "DB", "K", "D"} {
+		if strings.HasSuffix(s, p) {
+			penanda = p
+			s = strings.TrimSpace(strings.TrimSuffix(s, p))
+			break
+		}
+	}
+	negatif := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negatif = true
+		s = strings.Trim(s, "()")
+	}
+	s = strings.NewReplacer("RP", "", "IDR", "", " ", "", "\u00a0", "").Replace(s)
+	if strings.HasPrefix(s, "-") {
+		negatif = true
+		s = s[1:]
+	}
+	s = strings.TrimPrefix(s, "+")
+	if s == "" {
+		return 0, penanda
+	}
+
+	titik, koma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	switch {
+	case titik >= 0 && koma >= 0:
+		// Pemisah yang muncul terakhir adalah pemisah desimal
+		if koma > titik {
+			s = strings.ReplaceAll(s, ".", "")
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case koma >= 0:
+		if strings.Count(s, ",") == 1 && len(s)-koma-1 <= 2 {
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case titik >= 0:
+		if strings.Count(s, ".") > 1 || len(s)-titik-1 == 3 {
+			s = strings.ReplaceAll(s, ".", "")
+		}
+	}
+
+	nilai, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, penanda
+	}
+	if negatif {
+		nilai = -nilai
+	}
+	return nilai, penanda
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTagihanSedangDiproses dikembalikan jika salah satu tagihan masih ada di invoice online yang menunggu pembayaran
+	ErrTagihanSedangDiproses = errors.New("tagihan sedang menunggu pembayaran online")
+	// ErrNominalTidakSesuai dikembalikan jika nominal notifikasi berbeda dengan nominal invoice atau
+	// melebihi sisa tagihan yang dibayar
+	ErrNominalTidakSesuai = errors.New("nominal notifikasi tidak sesuai dengan invoice")
+)
+
+// masaBerlakuTagihanOnline adalah batas waktu pembayaran invoice online
+const masaBerlakuTagihanOnline = 24 * time.Hour
+
+// Sumber perubahan status invoice online
+const (
+	SumberSistem  = "sistem"
+	SumberWebhook = "webhook"
+)
+
+// PembayaranOnlineService membuat invoice pembayaran online untuk tagihan syahriah wali dan
+// mencatat pelunasannya dari webhook penyedia. Setiap perubahan status dicatat di RiwayatTagihanOnline.
+//
+// Konfigurasi lewat environment:
+//   - GERBANG_BAYAR: penyedia untuk invoice baru, "midtrans" atau "palsu" (kosong = nonaktif)
+//   - GERBANG_BAYAR_ADMIN_ID: user yang dicatat sebagai penerima pembayaran (default super admin pertama)
+type PembayaranOnlineService struct {
+	db *gorm.DB
+}
+
+func NewPembayaranOnlineService(db *gorm.DB) *PembayaranOnlineService {
+	return &PembayaranOnlineService{db: db}
+}
+
+// Buat membuat invoice untuk satu atau beberapa tagihan syahriah milik santri wali yang belum lunas.
+// Invoice disimpan lebih dulu agar kegagalan di penyedia tetap tercatat di riwayat.
+func (s *PembayaranOnlineService) Buat(ctx context.Context, idWali string, idSyahriah []string) (*models.TagihanOnline, error) {
+	penyedia, err := PenyediaAktif()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.KedaluwarsakanLama(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(idSyahriah))
+	unik := make(map[string]bool)
+	for _, id := range idSyahriah {
+		if id != "" && !unik[id] {
+			unik[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("pilih minimal satu tagihan syahriah")
+	}
+
+	var wali models.User
+	if err := s.db.Where("id_user = ?", idWali).First(&wali).Error; err != nil {
+		return nil, err
+	}
+
+	sekarang := time.Now()
+	tagihan := models.TagihanOnline{
+		IDTagihan:       uuid.New().String(),
+		Kode:            "SYH-" + sekarang.Format("20060102") + "-" + strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", "")[:8]),
+		Penyedia:        penyedia.Nama(),
+		IDWali:          idWali,
+		Status:          models.TagihanOnlineMenunggu,
+		KedaluwarsaPada: sekarang.Add(masaBerlakuTagihanOnline),
+	}
+	permintaan := PermintaanTagihan{
+		Kode:            tagihan.Kode,
+		NamaPembayar:    wali.NamaLengkap,
+		NoTelp:          wali.NoTelp,
+		KedaluwarsaPada: tagihan.KedaluwarsaPada,
+	}
+	if wali.Email != nil {
+		permintaan.Email = *wali.Email
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Kunci tagihan agar dua permintaan bersamaan tidak membuat invoice ganda
+		var daftar []models.Syahriah
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Santri").
+			Joins("JOIN santri ON santri.id_santri = syahriah.id_santri").
+			Where("syahriah.id_syahriah IN ? AND santri.id_wali = ?", ids, idWali).
+			Order("syahriah.bulan ASC").
+			Find(&daftar).Error; err != nil {
+			return err
+		}
+		if len(daftar) != len(ids) {
+			return fmt.Errorf("%w: tagihan syahriah tidak ditemukan", gorm.ErrRecordNotFound)
+		}
+
+		var aktif int64
+		if err := tx.Model(&models.ItemTagihanOnline{}).
+			Joins("JOIN tagihan_online ON tagihan_online.id_tagihan = item_tagihan_online.id_tagihan").
+			Where("item_tagihan_online.id_syahriah IN ? AND tagihan_online.status = ?", ids, models.TagihanOnlineMenunggu).
+			Count(&aktif).Error; err != nil {
+			return err
+		}
+		if aktif > 0 {
+			return ErrTagihanSedangDiproses
+		}
+
+		for _, syahriah := range daftar {
+			sisa := SisaTagihan(syahriah)
+			if sisa <= 0.005 {
+				return fmt.Errorf("tagihan bulan %s santri %s sudah lunas", syahriah.Bulan, syahriah.Santri.NamaLengkap)
+			}
+			tagihan.Item = append(tagihan.Item, models.ItemTagihanOnline{
+				IDItem:     uuid.New().String(),
+				IDTagihan:  tagihan.IDTagihan,
+				IDSyahriah: syahriah.IDSyahriah,
+				Nominal:    sisa,
+			})
+			tagihan.Nominal += sisa
+			permintaan.Item = append(permintaan.Item, ItemPermintaanTagihan{
+				ID:      syahriah.IDSyahriah,
+				Nama:    "Syahriah " + syahriah.Bulan + " " + syahriah.Santri.NamaLengkap,
+				Nominal: sisa,
+			})
+		}
+		permintaan.Nominal = tagihan.Nominal
+
+		if err := tx.Create(&tagihan).Error; err != nil {
+			return err
+		}
+		return catatRiwayatTagihanOnline(tx, tagihan.IDTagihan, "", models.TagihanOnlineMenunggu, SumberSistem, "Invoice dibuat untuk "+fmt.Sprint(len(tagihan.Item))+" tagihan", "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hasil, err := penyedia.BuatTagihan(ctx, permintaan)
+	if err != nil {
+		tagihan.Status = models.TagihanOnlineGagal
+		s.db.Model(&tagihan).Update("status", tagihan.Status)
+		catatRiwayatTagihanOnline(s.db, tagihan.IDTagihan, models.TagihanOnlineMenunggu, models.TagihanOnlineGagal, SumberSistem, "Gagal membuat invoice di penyedia: "+err.Error(), "")
+		return nil, fmt.Errorf("%w: %s: %v", ErrPenyediaGagal, penyedia.Nama(), err)
+	}
+
+	tagihan.IDEksternal = hasil.IDEksternal
+	tagihan.URLPembayaran = hasil.URLPembayaran
+	if err := s.db.Model(&tagihan).Updates(map[string]interface{}{
+		"id_eksternal":   tagihan.IDEksternal,
+		"url_pembayaran": tagihan.URLPembayaran,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &tagihan, nil
+}
+
+// KedaluwarsakanLama menandai invoice menunggu yang sudah lewat batas waktunya sebagai kedaluwarsa,
+// sehingga tagihannya bisa dibayar lewat invoice baru
+func (s *PembayaranOnlineService) KedaluwarsakanLama() error {
+	var lama []models.TagihanOnline
+	if err := s.db.Where("status = ? AND kedaluwarsa_pada < ?", models.TagihanOnlineMenunggu, time.Now()).
+		Find(&lama).Error; err != nil {
+		return err
+	}
+	for _, t := range lama {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			// Status dicek ulang karena webhook bisa tiba bersamaan
+			res := tx.Model(&models.TagihanOnline{}).
+				Where("id_tagihan = ? AND status = ?", t.IDTagihan, models.TagihanOnlineMenunggu).
+				Update("status", models.TagihanOnlineKedaluwarsa)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			return catatRiwayatTagihanOnline(tx, t.IDTagihan, models.TagihanOnlineMenunggu, models.TagihanOnlineKedaluwarsa, SumberSistem, "Melewati batas waktu pembayaran", "")
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProsesWebhook memverifikasi notifikasi dari penyedia lalu menerapkannya ke invoice.
+// Aman dipanggil berulang kali: invoice yang sudah dibayar tidak dicatat ulang, notifikasinya
+// hanya ditambahkan ke riwayat. Notifikasi yang ditolak juga dicatat di riwayat.
+func (s *PembayaranOnlineService) ProsesWebhook(namaPenyedia string, header http.Header, body []byte) (*models.TagihanOnline, error) {
+	penyedia, err := PenyediaDariNama(namaPenyedia)
+	if err != nil {
+		return nil, err
+	}
+	notif, err := penyedia.VerifikasiWebhook(header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var tagihan models.TagihanOnline
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Item").
+			Where("kode = ? AND penyedia = ?", notif.Kode, namaPenyedia).
+			First(&tagihan).Error; err != nil {
+			return err
+		}
+		return s.terapkan(tx, &tagihan, notif, string(body))
+	})
+	if err != nil {
+		if tagihan.IDTagihan != "" {
+			catatRiwayatTagihanOnline(s.db, tagihan.IDTagihan, tagihan.Status, notif.Status, SumberWebhook, "Notifikasi ditolak: "+err.Error(), string(body))
+		}
+		return nil, err
+	}
+	return &tagihan, nil
+}
+
+// terapkan mengubah status invoice sesuai notifikasi. Pelunasan mencatat pembayaran per tagihan
+// lewat KeuanganService sehingga jurnal, kwitansi dan rekap ikut diperbarui dalam transaksi yang sama.
+func (s *PembayaranOnlineService) terapkan(tx *gorm.DB, tagihan *models.TagihanOnline, notif *NotifikasiPembayaran, payload string) error {
+	statusLama := tagihan.Status
+	if statusLama == models.TagihanOnlineDibayar {
+		return catatRiwayatTagihanOnline(tx, tagihan.IDTagihan, statusLama, notif.Status, SumberWebhook, "Invoice sudah dibayar, notifikasi diabaikan", payload)
+	}
+	if notif.Status == statusLama {
+		return catatRiwayatTagihanOnline(tx, tagihan.IDTagihan, statusLama, notif.Status, SumberWebhook, "Status tidak berubah", payload)
+	}
+
+	perubahan := map[string]interface{}{"status": notif.Status}
+	if notif.Metode != "" {
+		tagihan.MetodePenyedia = notif.Metode
+		perubahan["metode_penyedia"] = notif.Metode
+	}
+	if notif.IDEksternal != "" {
+		tagihan.IDEksternal = notif.IDEksternal
+		perubahan["id_eksternal"] = notif.IDEksternal
+	}
+	keterangan := "Status dari penyedia"
+
+	if notif.Status == models.TagihanOnlineDibayar {
+		var diharapkan int64
+		for _, item := range tagihan.Item {
+			diharapkan += rupiahBulat(item.Nominal)
+		}
+		if rupiahBulat(notif.Nominal) != diharapkan {
+			return fmt.Errorf("%w: Rp %.0f, seharusnya Rp %d", ErrNominalTidakSesuai, notif.Nominal, diharapkan)
+		}
+
+		adminID, err := adminSistem(tx, os.Getenv("GERBANG_BAYAR_ADMIN_ID"), "GERBANG_BAYAR_ADMIN_ID")
+		if err != nil {
+			return err
+		}
+
+		keuangan := NewKeuanganService(tx)
+		for i := range tagihan.Item {
+			item := &tagihan.Item[i]
+			var syahriah models.Syahriah
+			if err := tx.Where("id_syahriah = ?", item.IDSyahriah).First(&syahriah).Error; err != nil {
+				return err
+			}
+			// Tagihan bisa saja sudah dibayar lewat jalur lain selama invoice menunggu. Pelunasan yang
+			// melebihi sisa tagihan ditolak seluruhnya agar admin meninjau dan mengembalikan kelebihannya.
+			if sisa := SisaTagihan(syahriah); rupiahBulat(item.Nominal) > rupiahBulat(sisa) {
+				return fmt.Errorf("%w: tagihan bulan %s tinggal Rp %.0f, invoice membayar Rp %.0f",
+					ErrNominalTidakSesuai, syahriah.Bulan, sisa, item.Nominal)
+			}
+			pembayaran := models.PembayaranSyahriah{
+				IDSyahriah:   item.IDSyahriah,
+				Nominal:      item.Nominal,
+				TanggalBayar: notif.Waktu,
+				Metode:       models.MetodeOnline,
+				Keterangan:   fmt.Sprintf("Pembayaran online %s via %s", tagihan.Kode, tagihan.Penyedia),
+			}
+			if notif.Metode != "" {
+				pembayaran.Keterangan += " (" + notif.Metode + ")"
+			}
+			if _, err := keuangan.TambahPembayaran(&pembayaran, adminID); err != nil {
+				return fmt.Errorf("gagal mencatat pembayaran bulan %s: %w", syahriah.Bulan, err)
+			}
+			item.IDPembayaran = &pembayaran.IDPembayaran
+			if err := tx.Model(item).Update("id_pembayaran", pembayaran.IDPembayaran).Error; err != nil {
+				return err
+			}
+		}
+
+		tagihan.DibayarPada = &notif.Waktu
+		perubahan["dibayar_pada"] = notif.Waktu
+		keterangan = "Pembayaran diterima"
+	}
+
+	if err := tx.Model(&models.TagihanOnline{}).Where("id_tagihan = ?", tagihan.IDTagihan).Updates(perubahan).Error; err != nil {
+		return err
+	}
+	tagihan.Status = notif.Status
+	return catatRiwayatTagihanOnline(tx, tagihan.IDTagihan, statusLama, notif.Status, SumberWebhook, keterangan, payload)
+}
+
+func catatRiwayatTagihanOnline(db *gorm.DB, idTagihan string, dari, ke models.StatusTagihanOnline, sumber, keterangan, payload string) error {
+	return db.Create(&models.RiwayatTagihanOnline{
+		IDRiwayat:  uuid.New().String(),
+		IDTagihan:  idTagihan,
+		StatusDari: string(dari),
+		StatusKe:   string(ke),
+		Sumber:     sumber,
+		Keterangan: keterangan,
+		Payload:    payload,
+	}).Error
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrMelebihiSisaTagihan dikembalikan jika nominal pembayaran lebih besar dari sisa tagihan
+var ErrMelebihiSisaTagihan = errors.New("nominal pembayaran melebihi sisa tagihan")
+
+// StatusPembayaran menurunkan status tagihan dari total yang sudah dibayar.
+// Tagihan bernominal 0 (dibebaskan) selalu lunas.
+func StatusPembayaran(nominal, terbayar float64) models.StatusSyahriah {
+	switch {
+	case terbayar >= nominal-0.005:
+		return models.StatusLunas
+	case terbayar > 0:
+		return models.StatusSebagian
+	default:
+		return models.StatusBelum
+	}
+}
+
+// SisaTagihan mengembalikan nominal yang masih harus dibayar untuk satu tagihan
+func SisaTagihan(syahriah models.Syahriah) float64 {
+	return math.Max(syahriah.Nominal-syahriah.Terbayar, 0)
+}
+
+// bagiPembayaran membagi satu pembayaran ke beberapa tagihan, bulan tertua lebih dulu, dan
+// mengembalikan nominal untuk tiap tagihan sesuai urutan masukan. Pembayaran yang melebihi
+// total sisa tagihan ditolak.
+func bagiPembayaran(tagihan []models.Syahriah, nominal float64) ([]float64, error) {
+	urutan := make([]int, len(tagihan))
+	var totalSisa float64
+	for i, t := range tagihan {
+		urutan[i] = i
+		totalSisa += SisaTagihan(t)
+	}
+	if nominal > totalSisa+0.005 {
+		return nil, fmt.Errorf("%w: total sisa tagihan santri Rp %.0f", ErrMelebihiSisaTagihan, totalSisa)
+	}
+	sort.SliceStable(urutan, func(a, b int) bool { return tagihan[urutan[a]].Bulan < tagihan[urutan[b]].Bulan })
+
+	alokasi := make([]float64, len(tagihan))
+	sisaBayar := nominal
+	for _, i := range urutan {
+		if sisaBayar <= 0.005 {
+			break
+		}
+		alokasi[i] = math.Min(sisaBayar, SisaTagihan(tagihan[i]))
+		sisaBayar -= alokasi[i]
+	}
+	return alokasi, nil
+}
+
+// perbaruiTerbayar menghitung ulang total terbayar dan status syahriah dari pembayarannya
+func perbaruiTerbayar(tx *gorm.DB, syahriah *models.Syahriah) error {
+	var terbayar float64
+	if err := tx.Model(&models.PembayaranSyahriah{}).
+		Where("id_syahriah = ?", syahriah.IDSyahriah).
+		Select("COALESCE(SUM(nominal), 0)").
+		Scan(&terbayar).Error; err != nil {
+		return err
+	}
+
+	syahriah.Terbayar = terbayar
+	syahriah.Status = StatusPembayaran(syahriah.Nominal, terbayar)
+	return tx.Model(&models.Syahriah{}).
+		Where("id_syahriah = ?", syahriah.IDSyahriah).
+		Updates(map[string]interface{}{"terbayar": syahriah.Terbayar, "status": syahriah.Status}).Error
+}
+
+// catatPembayaran menyimpan satu pembayaran untuk tagihan, memposting jurnal pemasukannya
+// lalu memperbarui status tagihan
+func catatPembayaran(tx *gorm.DB, jurnal *JurnalService, syahriah *models.Syahriah, pembayaran *models.PembayaranSyahriah, adminID string) ([]string, error) {
+	if pembayaran.Nominal <= 0 {
+		return nil, errors.New("nominal pembayaran harus lebih dari 0")
+	}
+	if sisa := SisaTagihan(*syahriah); pembayaran.Nominal > sisa+0.005 {
+		return nil, fmt.Errorf("%w: sisa tagihan bulan %s Rp %.0f", ErrMelebihiSisaTagihan, syahriah.Bulan, sisa)
+	}
+
+	if pembayaran.IDPembayaran == "" {
+		pembayaran.IDPembayaran = uuid.New().String()
+	}
+	if pembayaran.TanggalBayar.IsZero() {
+		pembayaran.TanggalBayar = time.Now()
+	}
+	if pembayaran.Metode == "" {
+		pembayaran.Metode = models.MetodeTunai
+	}
+	if pembayaran.DiterimaOleh == "" {
+		pembayaran.DiterimaOleh = adminID
+	}
+	pembayaran.IDSyahriah = syahriah.IDSyahriah
+
+	if err := NewTutupBukuService(tx).CekPeriodeTerbuka(pembayaran.TanggalBayar.Format("2006-01")); err != nil {
+		return nil, err
+	}
+	var err error
+	if pembayaran.IDRekening, err = tentukanRekening(tx, pembayaran.IDRekening); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(pembayaran).Error; err != nil {
+		return nil, err
+	}
+	periodes, err := jurnal.SinkronPembayaranSyahriah(*pembayaran, syahriah.Bulan, adminID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := NewKwitansiService(tx).TerbitkanPembayaranSyahriah(*pembayaran); err != nil {
+		return nil, err
+	}
+	return periodes, perbaruiTerbayar(tx, syahriah)
+}
+
+// hapusPembayaran menghapus pembayaran dan membalik jurnal pemasukannya.
+// Status tagihan diperbarui oleh pemanggil.
+func hapusPembayaran(tx *gorm.DB, jurnal *JurnalService, pembayaran models.PembayaranSyahriah, adminID string) ([]string, error) {
+	if err := NewTutupBukuService(tx).CekPeriodeTerbuka(pembayaran.TanggalBayar.Format("2006-01")); err != nil {
+		return nil, err
+	}
+	if err := tx.Where("id_pembayaran = ?", pembayaran.IDPembayaran).Delete(&models.PembayaranSyahriah{}).Error; err != nil {
+		return nil, err
+	}
+	if err := NewKwitansiService(tx).Batalkan(TargetPembayaranSyahriah, pembayaran.IDPembayaran); err != nil {
+		return nil, err
+	}
+	return jurnal.BatalkanSumber(TargetPembayaranSyahriah, pembayaran.IDPembayaran, adminID)
+}
+
+// hapusSemuaPembayaran menghapus seluruh pembayaran sebuah tagihan
+func hapusSemuaPembayaran(tx *gorm.DB, jurnal *JurnalService, idSyahriah, adminID string, periodeMap map[string]bool) error {
+	var daftar []models.PembayaranSyahriah
+	if err := tx.Where("id_syahriah = ?", idSyahriah).Find(&daftar).Error; err != nil {
+		return err
+	}
+	for _, p := range daftar {
+		periodes, err := hapusPembayaran(tx, jurnal, p, adminID)
+		if err != nil {
+			return err
+		}
+		tandaiPeriode(periodeMap, periodes)
+	}
+	return nil
+}
+
+// tandaiPeriode menambahkan periode terdampak ke himpunan periode
+func tandaiPeriode(periodeMap map[string]bool, periodes []string) {
+	for _, p := range periodes {
+		periodeMap[p] = true
+	}
+}
+
+// MigrasiPembayaranLama mengubah syahriah lunas dari sebelum adanya pencatatan cicilan menjadi
+// satu pembayaran penuh. Jurnal lamanya dipindahkan ke pembayaran tersebut tanpa posting ulang,
+// sehingga rekap periode lama (termasuk yang sudah tutup buku) tidak berubah. Aman dijalankan berulang.
+func (s *KeuanganService) MigrasiPembayaranLama() (int, error) {
+	jumlah := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var lama []models.Syahriah
+		if err := tx.Where("status = ? AND nominal > 0", models.StatusLunas).
+			Where("NOT EXISTS (SELECT 1 FROM pembayaran_syahriah WHERE pembayaran_syahriah.id_syahriah = syahriah.id_syahriah)").
+			Find(&lama).Error; err != nil {
+			return err
+		}
+
+		for _, syahriah := range lama {
+			pembayaran := models.PembayaranSyahriah{
+				IDPembayaran: uuid.New().String(),
+				IDSyahriah:   syahriah.IDSyahriah,
+				Nominal:      syahriah.Nominal,
+				TanggalBayar: syahriah.WaktuCatat,
+				Metode:       models.MetodeTunai,
+				DiterimaOleh: syahriah.DicatatOleh,
+				Keterangan:   "Migrasi pelunasan sebelum pencatatan cicilan",
+			}
+			if err := tx.Create(&pembayaran).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Jurnal{}).
+				Where("tipe_sumber = ? AND id_sumber = ?", TargetSyahriah, syahriah.IDSyahriah).
+				Updates(map[string]interface{}{"tipe_sumber": TargetPembayaranSyahriah, "id_sumber": pembayaran.IDPembayaran}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Syahriah{}).
+				Where("id_syahriah = ?", syahriah.IDSyahriah).
+				Update("terbayar", syahriah.Nominal).Error; err != nil {
+				return err
+			}
+			jumlah++
+		}
+		return nil
+	})
+	return jumlah, err
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"tpq_asysyafii/models"
+)
+
+func TestBagiPembayaran(t *testing.T) {
+	// Urutan masukan sengaja tidak urut bulan; alokasi tetap mulai dari bulan tertua
+	tagihan := []models.Syahriah{
+		{Bulan: "2025-03", Nominal: 110000},
+		{Bulan: "2025-01", Nominal: 110000, Terbayar: 50000},
+		{Bulan: "2025-02", Nominal: 100000},
+	}
+
+	tests := []struct {
+		nama    string
+		nominal float64
+		want    []float64
+		lebih   bool
+	}{
+		{"sebagian bulan tertua", 20000, []float64{0, 20000, 0}, false},
+		{"tepat melunasi bulan tertua", 60000, []float64{0, 60000, 0}, false},
+		{"berlanjut ke bulan berikutnya", 100000, []float64{0, 60000, 40000}, false},
+		{"tiga bulan sekaligus", 270000, []float64{110000, 60000, 100000}, false},
+		{"selisih pembulatan diabaikan", 270000.004, []float64{110000, 60000, 100000}, false},
+		{"melebihi total sisa", 270001, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			got, err := bagiPembayaran(tagihan, tt.nominal)
+			if tt.lebih {
+				if !errors.Is(err, ErrMelebihiSisaTagihan) {
+					t.Fatalf("bagiPembayaran() error = %v, want ErrMelebihiSisaTagihan", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bagiPembayaran() error = %v", err)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("bagiPembayaran() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusPembayaran(t *testing.T) {
+	tests := []struct {
+		nama     string
+		nominal  float64
+		terbayar float64
+		want     models.StatusSyahriah
+	}{
+		{"belum dibayar", 110000, 0, models.StatusBelum},
+		{"dibayar sebagian", 110000, 50000, models.StatusSebagian},
+		{"kurang seribu", 110000, 109000, models.StatusSebagian},
+		{"lunas", 110000, 110000, models.StatusLunas},
+		{"selisih pembulatan", 110000, 109999.996, models.StatusLunas},
+		{"dibebaskan", 0, 0, models.StatusLunas},
+	}
+	for _, tt := range tests {
+		if got := StatusPembayaran(tt.nominal, tt.terbayar); got != tt.want {
+			t.Errorf("%s: StatusPembayaran(%.0f, %.3f) = %s, want %s", tt.nama, tt.nominal, tt.terbayar, got, tt.want)
+		}
+	}
+}
+
+// TestCicilanSyahriah mengikuti satu tagihan dari belum, sebagian, lunas lalu kembali sebagian
+// saat satu pembayaran dihapus, seperti yang dilakukan perbaruiTerbayar setelah tiap perubahan
+func TestCicilanSyahriah(t *testing.T) {
+	syahriah := models.Syahriah{Nominal: 110000, Status: models.StatusBelum}
+	langkah := []struct {
+		nama   string
+		mutasi float64
+		status models.StatusSyahriah
+		sisa   float64
+	}{
+		{"cicilan pertama", 50000, models.StatusSebagian, 60000},
+		{"cicilan kedua", 40000, models.StatusSebagian, 20000},
+		{"pelunasan", 20000, models.StatusLunas, 0},
+		{"pembayaran kedua dihapus", -40000, models.StatusSebagian, 40000},
+	}
+	for _, l := range langkah {
+		if l.mutasi > 0 {
+			alokasi, err := bagiPembayaran([]models.Syahriah{syahriah}, l.mutasi)
+			if err != nil {
+				t.Fatalf("%s: bagiPembayaran() error = %v", l.nama, err)
+			}
+			l.mutasi = alokasi[0]
+		}
+		syahriah.Terbayar += l.mutasi
+		syahriah.Status = StatusPembayaran(syahriah.Nominal, syahriah.Terbayar)
+		if syahriah.Status != l.status || SisaTagihan(syahriah) != l.sisa {
+			t.Errorf("%s: status, sisa = %s, %.0f, want %s, %.0f", l.nama, syahriah.Status, SisaTagihan(syahriah), l.status, l.sisa)
+		}
+	}
+
+	if _, err := bagiPembayaran([]models.Syahriah{syahriah}, 40001); !errors.Is(err, ErrMelebihiSisaTagihan) {
+		t.Errorf("bayar melebihi sisa: error = %v, want ErrMelebihiSisaTagihan", err)
+	}
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// kunciPenjadwal mencegah penjadwal dan pemicu manual berjalan bersamaan dalam satu proses.
+// Antarproses, tagihan ganda dicegah indeks unik syahriah(id_santri, bulan).
+var kunciPenjadwal sync.Mutex
+
+// jedaCobaUlangMaks membatasi jeda penjadwal sebelum mencoba lagi bulan yang gagal
+const jedaCobaUlangMaks = 24 * time.Hour
+
+// PenjadwalTagihan membuat tagihan syahriah bulanan secara otomatis pada tanggal yang diatur.
+// Bulan yang terlewat (misalnya karena server mati) dikejar pada pengecekan berikutnya.
+//
+// Konfigurasi lewat environment:
+//   - TAGIHAN_OTOMATIS_AKTIF: "false" untuk mematikan penjadwal (default aktif)
+//   - TAGIHAN_OTOMATIS_TANGGAL: tanggal pembuatan tagihan 1-28 (default 1)
+//   - TAGIHAN_OTOMATIS_ADMIN_ID: user yang dicatat sebagai pembuat (default super admin pertama)
+type PenjadwalTagihan struct {
+	db       *gorm.DB
+	aktif    bool
+	tanggal  int
+	adminID  string
+	interval time.Duration
+}
+
+func NewPenjadwalTagihan(db *gorm.DB) *PenjadwalTagihan {
+	p := &PenjadwalTagihan{
+		db:       db,
+		aktif:    !strings.EqualFold(os.Getenv("TAGIHAN_OTOMATIS_AKTIF"), "false"),
+		tanggal:  1,
+		adminID:  os.Getenv("TAGIHAN_OTOMATIS_ADMIN_ID"),
+		interval: time.Hour,
+	}
+	if tanggal, err := strconv.Atoi(os.Getenv("TAGIHAN_OTOMATIS_TANGGAL")); err == nil && tanggal >= 1 && tanggal <= 28 {
+		p.tanggal = tanggal
+	}
+	return p
+}
+
+// Tanggal mengembalikan tanggal pembuatan tagihan setiap bulan
+func (p *PenjadwalTagihan) Tanggal() int {
+	return p.tanggal
+}
+
+// Mulai menjalankan pengecekan saat start lalu setiap interval sampai ctx dibatalkan
+func (p *PenjadwalTagihan) Mulai(ctx context.Context) {
+	if !p.aktif {
+		log.Printf("⏸️ Penjadwal tagihan syahriah dimatikan")
+		return
+	}
+	log.Printf("🗓️ Penjadwal tagihan syahriah aktif setiap tanggal %d", p.tanggal)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.Jalankan(models.PemicuOtomatis, ""); err != nil {
+			log.Printf("⚠️ Penjadwal tagihan: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// BulanTertunda mengembalikan bulan (YYYY-MM) yang tagihannya belum berhasil dibuat, mulai dari
+// bulan setelah laporan berhasil terakhir sampai bulan target. Bulan target adalah bulan berjalan
+// jika tanggal pembuatan sudah lewat, atau bulan sebelumnya jika belum. Tanpa laporan berhasil,
+// hanya bulan target yang dikembalikan agar data lama tidak ikut ditagih. Hasilnya kosong jika
+// bulan target sudah berhasil dibuat.
+func (p *PenjadwalTagihan) BulanTertunda(sekarang time.Time) ([]string, error) {
+	target := time.Date(sekarang.Year(), sekarang.Month(), 1, 0, 0, 0, 0, sekarang.Location())
+	if sekarang.Day() < p.tanggal {
+		target = target.AddDate(0, -1, 0)
+	}
+
+	var terakhir string
+	if err := p.db.Model(&models.LaporanTagihanOtomatis{}).
+		Where("status = ?", models.JalanBerhasil).
+		Select("COALESCE(MAX(bulan), '')").
+		Scan(&terakhir).Error; err != nil {
+		return nil, err
+	}
+
+	mulai := target
+	if terakhir != "" {
+		t, err := time.ParseInLocation("2006-01", terakhir, sekarang.Location())
+		if err != nil {
+			return nil, err
+		}
+		mulai = t.AddDate(0, 1, 0)
+	}
+
+	var bulan []string
+	for b := mulai; !b.After(target); b = b.AddDate(0, 1, 0) {
+		bulan = append(bulan, b.Format("2006-01"))
+	}
+	return bulan, nil
+}
+
+// Jalankan membuat tagihan untuk setiap bulan tertunda secara berurutan dan mencatat laporannya.
+// Berhenti pada bulan pertama yang gagal agar bulan berikutnya tidak mendahului. Penjadwal
+// menunggu jeda yang makin panjang sebelum mencoba lagi bulan yang gagal; pemicu manual
+// selalu langsung dijalankan.
+func (p *PenjadwalTagihan) Jalankan(pemicu models.PemicuTagihan, adminID string) ([]models.LaporanTagihanOtomatis, error) {
+	kunciPenjadwal.Lock()
+	defer kunciPenjadwal.Unlock()
+
+	bulanTertunda, err := p.BulanTertunda(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(bulanTertunda) == 0 {
+		return nil, nil
+	}
+
+	var errAdmin error
+	if adminID == "" {
+		adminID, errAdmin = p.adminPenjadwal()
+	}
+
+	var laporan []models.LaporanTagihanOtomatis
+	for _, bulan := range bulanTertunda {
+		gagal, err := p.laporanGagal(bulan)
+		if err != nil {
+			return laporan, err
+		}
+		if pemicu == models.PemicuOtomatis && gagal != nil && time.Now().Before(p.cobaLagiPada(*gagal)) {
+			return laporan, nil
+		}
+
+		hasil := p.jalankanBulan(bulan, pemicu, adminID, errAdmin, gagal)
+		laporan = append(laporan, hasil)
+		if hasil.Status != models.JalanBerhasil {
+			return laporan, fmt.Errorf("gagal membuat tagihan bulan %s: %s", bulan, hasil.Pesan)
+		}
+	}
+	return laporan, nil
+}
+
+// laporanGagal mengambil laporan gagal terakhir untuk bulan yang belum berhasil dibuat
+func (p *PenjadwalTagihan) laporanGagal(bulan string) (*models.LaporanTagihanOtomatis, error) {
+	var laporan models.LaporanTagihanOtomatis
+	err := p.db.Where("bulan = ? AND status = ?", bulan, models.JalanGagal).
+		Order("waktu_selesai DESC").
+		First(&laporan).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &laporan, nil
+}
+
+// cobaLagiPada menghitung kapan penjadwal mencoba lagi bulan yang gagal. Jeda berlipat dua
+// setiap percobaan mulai dari satu interval, paling lama jedaCobaUlangMaks.
+func (p *PenjadwalTagihan) cobaLagiPada(gagal models.LaporanTagihanOtomatis) time.Time {
+	jeda := p.interval
+	for i := 1; i < gagal.JumlahPercobaan && jeda < jedaCobaUlangMaks; i++ {
+		jeda *= 2
+	}
+	return gagal.WaktuSelesai.Add(min(jeda, jedaCobaUlangMaks))
+}
+
+// jalankanBulan membuat tagihan satu bulan lalu menyimpan laporan dan log aktivitasnya. Jika
+// bulan ini sudah pernah gagal, laporan gagal tersebut diperbarui dan jumlah percobaannya
+// ditambah sehingga kegagalan yang sama hanya tercatat sekali.
+func (p *PenjadwalTagihan) jalankanBulan(bulan string, pemicu models.PemicuTagihan, adminID string, errAdmin error, gagal *models.LaporanTagihanOtomatis) models.LaporanTagihanOtomatis {
+	laporan := models.LaporanTagihanOtomatis{
+		IDLaporan:       uuid.New().String(),
+		Bulan:           bulan,
+		Pemicu:          pemicu,
+		JumlahPercobaan: 1,
+		WaktuMulai:      time.Now(),
+	}
+	if adminID != "" {
+		laporan.DijalankanOleh = &adminID
+	}
+
+	err := errAdmin
+	if err == nil {
+		var hasil *HasilGenerateTagihan
+		hasil, err = NewTagihanService(p.db).GenerateBulan(bulan, 0, models.StatusBelum, adminID)
+		if hasil != nil {
+			laporan.JumlahDibuat = hasil.Dibuat
+			laporan.JumlahDilewati = hasil.Dilewati
+			laporan.TotalSantriAktif = hasil.TotalSantriAktif
+		}
+	}
+
+	switch {
+	case err == nil:
+		laporan.Status = models.JalanBerhasil
+		laporan.Pesan = fmt.Sprintf("%d tagihan dibuat, %d santri sudah memiliki tagihan", laporan.JumlahDibuat, laporan.JumlahDilewati)
+	case errors.Is(err, ErrTidakAdaSantriAktif):
+		// Tidak ada yang perlu ditagih, bulan ini dianggap selesai
+		laporan.Status = models.JalanBerhasil
+		laporan.Pesan = err.Error()
+	default:
+		laporan.Status = models.JalanGagal
+		laporan.Pesan = err.Error()
+	}
+	laporan.WaktuSelesai = time.Now()
+
+	ulang := laporan.Status == models.JalanGagal && gagal != nil
+	var errSimpan error
+	if ulang {
+		laporan.IDLaporan = gagal.IDLaporan
+		laporan.JumlahPercobaan = gagal.JumlahPercobaan + 1
+		errSimpan = p.db.Save(&laporan).Error
+	} else {
+		errSimpan = p.db.Create(&laporan).Error
+	}
+	if errSimpan != nil {
+		log.Printf("⚠️ Gagal menyimpan laporan tagihan bulan %s: %v", bulan, errSimpan)
+	}
+	if adminID != "" && !ulang {
+		keterangan := fmt.Sprintf("Tagihan syahriah bulan %s (%s, %s): %s", bulan, pemicu, laporan.Status, laporan.Pesan)
+		if errLog := NewLogService(p.db).LogAktivitas(adminID, AksiGenerateTagihan, TargetSyahriah, laporan.IDLaporan, keterangan); errLog != nil {
+			log.Printf("⚠️ Gagal mencatat log tagihan bulan %s: %v", bulan, errLog)
+		}
+	}
+	return laporan
+}
+
+// adminPenjadwal menentukan user yang dicatat sebagai pembuat tagihan otomatis
+func (p *PenjadwalTagihan) adminPenjadwal() (string, error) {
+	return adminSistem(p.db, p.adminID, "TAGIHAN_OTOMATIS_ADMIN_ID")
+}
+
+// adminSistem menentukan user pencatat untuk proses tanpa login (penjadwal, webhook):
+// adminID dari environment jika diatur, atau super admin aktif pertama
+func adminSistem(db *gorm.DB, adminID, namaEnv string) (string, error) {
+	if adminID != "" {
+		return adminID, nil
+	}
+
+	var admin models.User
+	err := db.Where("role = ? AND status_aktif = ?", models.RoleSuperAdmin, true).
+		Order("dibuat_pada ASC").
+		First(&admin).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("tidak ada super admin aktif untuk dicatat sebagai pencatat, atur %s", namaEnv)
+		}
+		return "", err
+	}
+	return admin.IDUser, nil
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	JumlahJilidIqro = 6
+	// HalamanPerJilidIqro dipakai untuk menghitung kecepatan belajar Iqro. Halaman di atas angka
+	// ini dianggap sudah di akhir jilid.
+	HalamanPerJilidIqro = 32
+	// NilaiLulusSetoran adalah nilai minimal agar setoran dianggap lulus dan posisi santri naik
+	NilaiLulusSetoran = 60
+	// jendelaLaju adalah rentang waktu ke belakang untuk menghitung kecepatan belajar
+	jendelaLaju = 12 * 7 * 24 * time.Hour
+)
+
+// ErrSetoranTidakValid dikembalikan jika isi setoran tidak sesuai jenisnya
+var ErrSetoranTidakValid = errors.New("data setoran tidak valid")
+
+// PosisiIqro adalah jilid dan halaman Iqro terakhir yang lulus
+type PosisiIqro struct {
+	Jilid   int     `json:"jilid"`
+	Halaman int     `json:"halaman"`
+	Persen  float64 `json:"persen"` // dari seluruh Iqro jilid 1-6
+}
+
+// PosisiTilawah adalah ayat terakhir yang lulus dibaca
+type PosisiTilawah struct {
+	Surah     int     `json:"surah"`
+	NamaSurah string  `json:"nama_surah"`
+	Ayat      int     `json:"ayat"`
+	Persen    float64 `json:"persen"` // menuju khatam
+}
+
+// PosisiHafalan adalah surah yang sudah dihafal penuh
+type PosisiHafalan struct {
+	SurahHafal  []Surah `json:"surah_hafal"`
+	JumlahJuz30 int     `json:"jumlah_juz30"` // jumlah surah juz 30 yang sudah hafal
+	TotalJuz30  int     `json:"total_juz30"`
+	Persen      float64 `json:"persen"` // ayat juz 30 yang sudah hafal
+}
+
+// Proyeksi adalah perkiraan waktu mencapai target berdasarkan kecepatan 12 minggu terakhir
+type Proyeksi struct {
+	Target           string     `json:"target"`
+	LajuPerMinggu    float64    `json:"laju_per_minggu"`
+	Satuan           string     `json:"satuan"`
+	Sisa             float64    `json:"sisa"`
+	PerkiraanSelesai *time.Time `json:"perkiraan_selesai"` // nil jika belum ada kemajuan
+}
+
+// Perkembangan adalah ringkasan posisi belajar santri saat ini
+type Perkembangan struct {
+	IDSantri        string         `json:"id_santri"`
+	Tingkat         string         `json:"tingkat"`
+	Kategori        string         `json:"kategori"` // iqro_1..iqro_6, al_quran, belum_ada
+	Iqro            *PosisiIqro    `json:"iqro"`
+	Tilawah         *PosisiTilawah `json:"tilawah"`
+	Hafalan         PosisiHafalan  `json:"hafalan"`
+	ProyeksiIqro    *Proyeksi      `json:"proyeksi_iqro"`
+	ProyeksiTilawah *Proyeksi      `json:"proyeksi_tilawah"`
+	ProyeksiHafalan *Proyeksi      `json:"proyeksi_hafalan"`
+	JumlahSetoran   int            `json:"jumlah_setoran"`
+	SetoranTerakhir *time.Time     `json:"setoran_terakhir"`
+}
+
+// PeristiwaPerkembangan adalah tonggak penting di linimasa santri, misalnya naik jilid atau hafal surah
+type PeristiwaPerkembangan struct {
+	Tanggal     time.Time `json:"tanggal"`
+	Jenis       string    `json:"jenis"`
+	Keterangan  string    `json:"keterangan"`
+	IDSetoran   string    `json:"id_setoran,omitempty"`
+	IDMunaqosah string    `json:"id_munaqosah,omitempty"`
+}
+
+// PredikatNilai mengubah nilai setoran menjadi predikat
+func PredikatNilai(nilai int) string {
+	switch {
+	case nilai >= 90:
+		return "Mumtaz"
+	case nilai >= 80:
+		return "Jayyid Jiddan"
+	case nilai >= 70:
+		return "Jayyid"
+	case nilai >= NilaiLulusSetoran:
+		return "Maqbul"
+	default:
+		return "Rasib (diulang)"
+	}
+}
+
+// ValidasiSetoran memeriksa isi setoran sesuai jenisnya lalu menentukan status lulus dari nilai
+func ValidasiSetoran(s *models.SetoranSantri) error {
+	if s.Nilai < 0 || s.Nilai > 100 {
+		return fmt.Errorf("%w: nilai harus 0-100", ErrSetoranTidakValid)
+	}
+	switch s.Jenis {
+	case models.SetoranIqro:
+		if s.Jilid < 1 || s.Jilid > JumlahJilidIqro {
+			return fmt.Errorf("%w: jilid Iqro harus 1-%d", ErrSetoranTidakValid, JumlahJilidIqro)
+		}
+		if s.Halaman < 1 {
+			return fmt.Errorf("%w: halaman Iqro wajib diisi", ErrSetoranTidakValid)
+		}
+		s.Surah, s.AyatMulai, s.AyatSelesai = 0, 0, 0
+	case models.SetoranTilawah, models.SetoranHafalan:
+		surah, ok := CariSurah(s.Surah)
+		if !ok {
+			return fmt.Errorf("%w: nomor surah harus 1-114", ErrSetoranTidakValid)
+		}
+		if s.AyatMulai == 0 {
+			s.AyatMulai = 1
+		}
+		if s.AyatSelesai == 0 {
+			s.AyatSelesai = surah.JumlahAyat
+		}
+		if s.AyatMulai < 1 || s.AyatSelesai < s.AyatMulai || s.AyatSelesai > surah.JumlahAyat {
+			return fmt.Errorf("%w: ayat surah %s harus 1-%d", ErrSetoranTidakValid, surah.Nama, surah.JumlahAyat)
+		}
+		s.Jilid, s.Halaman = 0, 0
+	default:
+		return fmt.Errorf("%w: jenis harus iqro, tilawah atau hafalan", ErrSetoranTidakValid)
+	}
+	s.Lulus = s.Nilai >= NilaiLulusSetoran
+	return nil
+}
+
+// CatatSetoran menyimpan setoran baru. Kelas diisi dari kelas aktif santri jika tidak disebut.
+// Setoran Iqro tidak boleh melewati jilid santri saat ini; naik jilid harus lewat munaqosah.
+func CatatSetoran(db *gorm.DB, setoran *models.SetoranSantri) error {
+	if err := ValidasiSetoran(setoran); err != nil {
+		return err
+	}
+	if setoran.Jenis == models.SetoranIqro {
+		jilid, err := jilidIqroTertinggi(db, setoran.IDSantri)
+		if err != nil {
+			return err
+		}
+		// Santri tanpa riwayat Iqro boleh mulai dari jilid mana pun sesuai penempatan awal
+		if jilid > 0 && setoran.Jilid > jilid {
+			return fmt.Errorf("%w: posisi santri saat ini Iqro jilid %d", ErrBelumLulusMunaqosah, jilid)
+		}
+	}
+	if setoran.IDKelas == nil {
+		var anggota models.AnggotaKelas
+		err := db.Where("id_santri = ? AND status = ?", setoran.IDSantri, models.AnggotaAktif).
+			Order("tanggal_masuk DESC").First(&anggota).Error
+		if err == nil {
+			setoran.IDKelas = &anggota.IDKelas
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	if setoran.IDSetoran == "" {
+		setoran.IDSetoran = uuid.New().String()
+	}
+	return db.Create(setoran).Error
+}
+
+// MuatSetoran mengambil seluruh setoran santri urut dari yang paling lama
+func MuatSetoran(db *gorm.DB, idSantri []string) ([]models.SetoranSantri, error) {
+	var setoran []models.SetoranSantri
+	if len(idSantri) == 0 {
+		return setoran, nil
+	}
+	err := db.Where("id_santri IN ?", idSantri).
+		Order("tanggal ASC, waktu_catat ASC").
+		Find(&setoran).Error
+	return setoran, err
+}
+
+// titikLaju adalah posisi kumulatif santri setelah satu setoran lulus
+type titikLaju struct {
+	Tanggal time.Time
+	Nilai   float64
+}
+
+// HitungPerkembangan menyusun posisi, proyeksi dan linimasa dari setoran dan munaqosah lulus satu
+// santri yang sudah urut dari yang paling lama. Munaqosah lulus menaikkan santri ke awal jilid
+// berikutnya, atau ke Al-Qur'an setelah jilid terakhir.
+func HitungPerkembangan(idSantri string, setoran []models.SetoranSantri, munaqosah []models.Munaqosah, sekarang time.Time) (Perkembangan, []PeristiwaPerkembangan) {
+	p := Perkembangan{IDSantri: idSantri, JumlahSetoran: len(setoran)}
+	var peristiwa []PeristiwaPerkembangan
+	var riwayatIqro, riwayatTilawah, riwayatHafalan []titikLaju
+
+	totalAyatJuz30 := 0
+	for _, s := range DaftarSurah[SurahAwalJuz30-1:] {
+		totalAyatJuz30 += s.JumlahAyat
+	}
+	p.Hafalan.TotalJuz30 = len(DaftarSurah) - SurahAwalJuz30 + 1
+	hafal := make(map[int][]bool)
+	surahHafal := make(map[int]bool)
+	ayatJuz30 := 0
+	jilidTertinggi := 0
+	lulusIqro := false
+
+	// terapkanMunaqosah memproses munaqosah yang diujikan sampai tanggal tertentu
+	terapkanMunaqosah := func(sampai *time.Time) {
+		for len(munaqosah) > 0 && munaqosah[0].TanggalUjian != nil &&
+			(sampai == nil || !munaqosah[0].TanggalUjian.After(*sampai)) {
+			m := munaqosah[0]
+			munaqosah = munaqosah[1:]
+			tanggal := *m.TanggalUjian
+			if m.Jilid >= JumlahJilidIqro {
+				lulusIqro = true
+				jilidTertinggi = JumlahJilidIqro
+				p.Iqro = &PosisiIqro{Jilid: JumlahJilidIqro, Halaman: HalamanPerJilidIqro, Persen: 100}
+				peristiwa = append(peristiwa, PeristiwaPerkembangan{Tanggal: tanggal, Jenis: "lulus_iqro",
+					Keterangan: fmt.Sprintf("Lulus munaqosah Iqro jilid %d, siap membaca Al-Qur'an", m.Jilid), IDMunaqosah: m.IDMunaqosah})
+				riwayatIqro = append(riwayatIqro, titikLaju{tanggal, JumlahJilidIqro * HalamanPerJilidIqro})
+				continue
+			}
+			peristiwa = append(peristiwa, PeristiwaPerkembangan{Tanggal: tanggal, Jenis: "naik_jilid",
+				Keterangan: fmt.Sprintf("Lulus munaqosah, naik ke Iqro jilid %d", m.Jilid+1), IDMunaqosah: m.IDMunaqosah})
+			if m.Jilid+1 > jilidTertinggi {
+				jilidTertinggi = m.Jilid + 1
+				p.Iqro = &PosisiIqro{
+					Jilid:  m.Jilid + 1,
+					Persen: float64(m.Jilid*HalamanPerJilidIqro) / float64(JumlahJilidIqro*HalamanPerJilidIqro) * 100,
+				}
+				riwayatIqro = append(riwayatIqro, titikLaju{tanggal, float64(m.Jilid * HalamanPerJilidIqro)})
+			}
+		}
+	}
+
+	for _, s := range setoran {
+		terapkanMunaqosah(&s.Tanggal)
+		tanggal := s.Tanggal
+		p.SetoranTerakhir = &tanggal
+		if !s.Lulus {
+			continue
+		}
+		switch s.Jenis {
+		case models.SetoranIqro:
+			if s.Jilid > jilidTertinggi {
+				if jilidTertinggi > 0 {
+					peristiwa = append(peristiwa, PeristiwaPerkembangan{Tanggal: s.Tanggal, Jenis: "naik_jilid", Keterangan: fmt.Sprintf("Naik ke Iqro jilid %d", s.Jilid), IDSetoran: s.IDSetoran})
+				} else {
+					peristiwa = append(peristiwa, PeristiwaPerkembangan{Tanggal: s.Tanggal, Jenis: "mulai_iqro", Keterangan: fmt.Sprintf("Mulai Iqro jilid %d", s.Jilid), IDSetoran: s.IDSetoran})
+				}
+				jilidTertinggi = s.Jilid
+			}
+			halaman := min(s.Halaman, HalamanPerJilidIqro)
+			p.Iqro = &PosisiIqro{
+				Jilid:   s.Jilid,
+				Halaman: s.Halaman,
+				Persen:  float64((s.Jilid-1)*HalamanPerJilidIqro+halaman) / float64(JumlahJilidIqro*HalamanPerJilidIqro) * 100,
+			}
+			riwayatIqro = append(riwayatIqro, titikLaju{s.Tanggal, float64((s.Jilid-1)*HalamanPerJilidIqro + halaman)})
+		case models.SetoranTilawah:
+			surah, _ := CariSurah(s.Surah)
+			if p.Tilawah == nil {
+				peristiwa = append(peristiwa, PeristiwaPerkembangan{Tanggal: s.Tanggal, Jenis: "mulai_quran", Keterangan: "Mulai membaca Al-Qur'an", IDSetoran: s.IDSetoran})
+			}
+			urutan := urutanAyat(s.Surah, s.AyatSelesai)
+			p.Tilawah = &PosisiTilawah{
+				Surah:     s.Surah,
+				NamaSurah: surah.Nama,
+				Ayat:      s.AyatSelesai,
+				Persen:    float64(urutan) / TotalAyatQuran * 100,
+			}
+			if urutan == TotalAyatQuran {
+				peristiwa = append(peristiwa, PeristiwaPerkembangan{Tanggal: s.Tanggal, Jenis: "khatam", Keterangan: "Khatam Al-Qur'an", IDSetoran: s.IDSetoran})
+			}
+			riwayatTilawah = append(riwayatTilawah, titikLaju{s.Tanggal, float64(urutan)})
+		case models.SetoranHafalan:
+			surah, _ := CariSurah(s.Surah)
+			ayat := hafal[s.Surah]
+			if ayat == nil {
+				ayat = make([]bool, surah.JumlahAyat+1)
+				hafal[s.Surah] = ayat
+			}
+			for i := s.AyatMulai; i <= s.AyatSelesai; i++ {
+				if !ayat[i] && s.Surah >= SurahAwalJuz30 {
+					ayatJuz30++
+				}
+				ayat[i] = true
+			}
+			if !surahHafal[s.Surah] && semuaBenar(ayat[1:]) {
+				surahHafal[s.Surah] = true
+				peristiwa = append(peristiwa, PeristiwaPerkembangan{Tanggal: s.Tanggal, Jenis: "hafal_surah", Keterangan: "Hafal surah " + surah.Nama, IDSetoran: s.IDSetoran})
+			}
+			riwayatHafalan = append(riwayatHafalan, titikLaju{s.Tanggal, float64(ayatJuz30)})
+		}
+	}
+
+	terapkanMunaqosah(nil)
+
+	p.Hafalan.SurahHafal = []Surah{}
+	for _, s := range DaftarSurah {
+		if surahHafal[s.Nomor] {
+			p.Hafalan.SurahHafal = append(p.Hafalan.SurahHafal, s)
+			if s.Nomor >= SurahAwalJuz30 {
+				p.Hafalan.JumlahJuz30++
+			}
+		}
+	}
+	p.Hafalan.Persen = float64(ayatJuz30) / float64(totalAyatJuz30) * 100
+
+	switch {
+	case p.Tilawah != nil:
+		p.Tingkat = fmt.Sprintf("Al-Qur'an, surah %s ayat %d", p.Tilawah.NamaSurah, p.Tilawah.Ayat)
+		p.Kategori = "al_quran"
+	case lulusIqro:
+		p.Tingkat = "Al-Qur'an, lulus Iqro dan belum ada setoran tilawah"
+		p.Kategori = "al_quran"
+	case p.Iqro != nil && p.Iqro.Halaman == 0:
+		// baru naik jilid lewat munaqosah, belum ada setoran di jilid ini
+		p.Tingkat = fmt.Sprintf("Iqro jilid %d, awal jilid", p.Iqro.Jilid)
+		p.Kategori = fmt.Sprintf("iqro_%d", p.Iqro.Jilid)
+	case p.Iqro != nil:
+		p.Tingkat = fmt.Sprintf("Iqro jilid %d halaman %d", p.Iqro.Jilid, p.Iqro.Halaman)
+		p.Kategori = fmt.Sprintf("iqro_%d", p.Iqro.Jilid)
+	default:
+		p.Tingkat = "Belum ada setoran"
+		p.Kategori = "belum_ada"
+	}
+
+	if p.Iqro != nil && p.Tilawah == nil && !lulusIqro {
+		p.ProyeksiIqro = hitungProyeksi("Selesai Iqro jilid 6", "halaman", riwayatIqro, JumlahJilidIqro*HalamanPerJilidIqro, sekarang)
+	}
+	if p.Tilawah != nil {
+		p.ProyeksiTilawah = hitungProyeksi("Khatam Al-Qur'an", "ayat", riwayatTilawah, TotalAyatQuran, sekarang)
+	}
+	if len(riwayatHafalan) > 0 {
+		p.ProyeksiHafalan = hitungProyeksi("Hafal juz 30", "ayat", riwayatHafalan, float64(totalAyatJuz30), sekarang)
+	}
+	return p, peristiwa
+}
+
+// hitungProyeksi memperkirakan tanggal tercapainya target dari kemajuan dalam jendelaLaju terakhir
+func hitungProyeksi(target, satuan string, riwayat []titikLaju, nilaiTarget float64, sekarang time.Time) *Proyeksi {
+	if len(riwayat) == 0 {
+		return nil
+	}
+	akhir := riwayat[len(riwayat)-1].Nilai
+	proyeksi := &Proyeksi{Target: target, Satuan: satuan, Sisa: math.Max(nilaiTarget-akhir, 0)}
+	if proyeksi.Sisa == 0 {
+		return proyeksi
+	}
+
+	// Posisi awal jendela: titik terakhir sebelum jendela, atau titik pertama jika semua di dalam jendela
+	awalJendela := sekarang.Add(-jendelaLaju)
+	mulai, nilaiAwal := riwayat[0].Tanggal, riwayat[0].Nilai
+	for _, t := range riwayat {
+		if t.Tanggal.Before(awalJendela) {
+			mulai, nilaiAwal = awalJendela, t.Nilai
+		}
+	}
+	minggu := math.Max(sekarang.Sub(mulai).Hours()/(24*7), 1)
+	proyeksi.LajuPerMinggu = math.Round((akhir-nilaiAwal)/minggu*10) / 10
+	if proyeksi.LajuPerMinggu <= 0 {
+		return proyeksi
+	}
+	selesai := sekarang.Add(time.Duration(proyeksi.Sisa / proyeksi.LajuPerMinggu * float64(7*24*time.Hour)))
+	proyeksi.PerkiraanSelesai = &selesai
+	return proyeksi
+}
+
+func semuaBenar(b []bool) bool {
+	for _, v := range b {
+		if !v {
+			return false
+		}
+	}
+	return true
+}
+
+// MuatPerkembangan menghitung perkembangan beberapa santri sekaligus
+func MuatPerkembangan(db *gorm.DB, idSantri []string) (map[string]Perkembangan, error) {
+	setoran, err := MuatSetoran(db, idSantri)
+	if err != nil {
+		return nil, err
+	}
+	munaqosah, err := MuatMunaqosahLulus(db, idSantri)
+	if err != nil {
+		return nil, err
+	}
+	perSantri := make(map[string][]models.SetoranSantri)
+	for _, s := range setoran {
+		perSantri[s.IDSantri] = append(perSantri[s.IDSantri], s)
+	}
+	munaqosahSantri := make(map[string][]models.Munaqosah)
+	for _, m := range munaqosah {
+		munaqosahSantri[m.IDSantri] = append(munaqosahSantri[m.IDSantri], m)
+	}
+	sekarang := time.Now()
+	hasil := make(map[string]Perkembangan, len(idSantri))
+	for _, id := range idSantri {
+		hasil[id], _ = HitungPerkembangan(id, perSantri[id], munaqosahSantri[id], sekarang)
+	}
+	return hasil, nil
+}
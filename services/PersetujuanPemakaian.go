@@ -0,0 +1,250 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrStatusPemakaian dikembalikan jika aksi tidak sesuai status pengajuan saat ini
+	ErrStatusPemakaian = errors.New("status pemakaian tidak memungkinkan aksi ini")
+	// ErrBukanPenyetuju dikembalikan jika peran pengguna di bawah peran yang disyaratkan ambang persetujuan
+	ErrBukanPenyetuju = errors.New("tidak berwenang menyetujui pemakaian ini")
+)
+
+// SiapkanKolomStatusPemakaian menambahkan kolom status pemakaian tanpa nilai default sebelum
+// AutoMigrate, agar data lama tidak ikut terisi diajukan dan bisa dikenali oleh MigrasiStatusPemakaian
+func SiapkanKolomStatusPemakaian(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.PemakaianSaldo{}) || migrator.HasColumn(&models.PemakaianSaldo{}, "status") {
+		return nil
+	}
+	return db.Exec("ALTER TABLE pemakaian_saldo ADD COLUMN status enum('diajukan','disetujui','ditolak','dicairkan') NULL").Error
+}
+
+// MigrasiStatusPemakaian menandai pemakaian dari sebelum adanya alur persetujuan sebagai dicairkan,
+// karena saldonya sudah terpotong saat dicatat. Aman dijalankan berulang.
+func MigrasiStatusPemakaian(db *gorm.DB) (int64, error) {
+	hasil := db.Exec("UPDATE pemakaian_saldo SET status = ? WHERE status IS NULL OR status = ''", models.PemakaianDicairkan)
+	return hasil.RowsAffected, hasil.Error
+}
+
+// PeranPenyetuju menentukan peran minimal penyetuju dari ambang tertinggi yang dilewati nominal.
+// Tanpa ambang yang cocok, pemakaian cukup disetujui admin.
+func PeranPenyetuju(db *gorm.DB, nominal float64) (models.UserRole, error) {
+	var ambang []models.AmbangPersetujuan
+	if err := db.Find(&ambang).Error; err != nil {
+		return "", err
+	}
+	return peranDariAmbang(ambang, nominal), nil
+}
+
+// peranDariAmbang memilih peran dari ambang dengan nominal_minimal tertinggi yang masih di bawah nominal
+func peranDariAmbang(ambang []models.AmbangPersetujuan, nominal float64) models.UserRole {
+	peran := models.RoleAdmin
+	tertinggi := -1.0
+	for _, a := range ambang {
+		if a.NominalMinimal < nominal && a.NominalMinimal > tertinggi {
+			peran = a.PeranPenyetuju
+			tertinggi = a.NominalMinimal
+		}
+	}
+	return peran
+}
+
+// BolehMenyetujui mengecek apakah peran pengguna memenuhi peran penyetuju yang disyaratkan
+func BolehMenyetujui(peran, dibutuhkan models.UserRole) bool {
+	switch peran {
+	case models.RoleSuperAdmin:
+		return true
+	case models.RoleAdmin:
+		return dibutuhkan == "" || dibutuhkan == models.RoleAdmin
+	}
+	return false
+}
+
+// cekPenyetuju memastikan pengguna boleh menyetujui pengajuan. Admin tidak dapat menyetujui
+// pengajuannya sendiri; super_admin boleh karena berada di puncak alur persetujuan.
+func cekPenyetuju(pemakaian models.PemakaianSaldo, adminID string, peran models.UserRole) error {
+	if pemakaian.Status != models.PemakaianDiajukan {
+		return fmt.Errorf("%w: pemakaian berstatus %s", ErrStatusPemakaian, pemakaian.Status)
+	}
+	if !BolehMenyetujui(peran, pemakaian.PeranPenyetuju) {
+		return fmt.Errorf("%w: harus disetujui %s", ErrBukanPenyetuju, pemakaian.PeranPenyetuju)
+	}
+	if pemakaian.DiajukanOleh == adminID && peran != models.RoleSuperAdmin {
+		return fmt.Errorf("%w: pengajuan sendiri harus disetujui admin lain", ErrBukanPenyetuju)
+	}
+	return nil
+}
+
+// CekKenaikanPemakaian memastikan kenaikan nominal pemakaian yang sudah dicairkan dilakukan oleh
+// peran penyetuju untuk nominal baru, karena perubahan itu tidak melewati persetujuan ulang
+func CekKenaikanPemakaian(db *gorm.DB, status models.StatusPemakaian, nominalLama, nominalBaru float64, peran models.UserRole) error {
+	if status != models.PemakaianDicairkan || nominalBaru <= nominalLama {
+		return nil
+	}
+	var ambang []models.AmbangPersetujuan
+	if err := db.Find(&ambang).Error; err != nil {
+		return err
+	}
+	return cekKenaikan(ambang, status, nominalLama, nominalBaru, peran)
+}
+
+// cekKenaikan adalah aturan CekKenaikanPemakaian dengan daftar ambang yang sudah dimuat
+func cekKenaikan(ambang []models.AmbangPersetujuan, status models.StatusPemakaian, nominalLama, nominalBaru float64, peran models.UserRole) error {
+	if status != models.PemakaianDicairkan || nominalBaru <= nominalLama {
+		return nil
+	}
+	if dibutuhkan := peranDariAmbang(ambang, nominalBaru); !BolehMenyetujui(peran, dibutuhkan) {
+		return fmt.Errorf("%w: kenaikan nominal ini harus dilakukan oleh %s", ErrBukanPenyetuju, dibutuhkan)
+	}
+	return nil
+}
+
+// catatRiwayatPemakaian mencatat perubahan status pengajuan pemakaian
+func catatRiwayatPemakaian(tx *gorm.DB, idPemakaian string, dari, ke models.StatusPemakaian, oleh, komentar string) error {
+	return tx.Create(&models.RiwayatPemakaian{
+		IDRiwayat:   uuid.New().String(),
+		IDPemakaian: idPemakaian,
+		StatusDari:  dari,
+		StatusKe:    ke,
+		Oleh:        oleh,
+		Komentar:    komentar,
+	}).Error
+}
+
+// kunciPemakaian mengambil pemakaian dengan lock agar dua aksi persetujuan tidak berjalan bersamaan
+func kunciPemakaian(tx *gorm.DB, id string) (*models.PemakaianSaldo, error) {
+	var pemakaian models.PemakaianSaldo
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id_pemakaian = ?", id).First(&pemakaian).Error; err != nil {
+		return nil, err
+	}
+	return &pemakaian, nil
+}
+
+// SetujuiPemakaian menyetujui pengajuan yang lolos cekPenyetuju
+func (s *KeuanganService) SetujuiPemakaian(id, adminID string, peran models.UserRole, komentar string) (*models.PemakaianSaldo, error) {
+	var pemakaian *models.PemakaianSaldo
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if pemakaian, err = kunciPemakaian(tx, id); err != nil {
+			return err
+		}
+		if err := cekPenyetuju(*pemakaian, adminID, peran); err != nil {
+			return err
+		}
+
+		sekarang := time.Now()
+		pemakaian.Status = models.PemakaianDisetujui
+		pemakaian.DisetujuiOleh = &adminID
+		pemakaian.WaktuDisetujui = &sekarang
+		if err := tx.Save(pemakaian).Error; err != nil {
+			return err
+		}
+		return catatRiwayatPemakaian(tx, id, models.PemakaianDiajukan, models.PemakaianDisetujui, adminID, komentar)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pemakaian, nil
+}
+
+// TolakPemakaian menolak pengajuan yang belum dicairkan. Pengajuan yang ditolak bisa diubah lalu diajukan ulang.
+func (s *KeuanganService) TolakPemakaian(id, adminID string, peran models.UserRole, komentar string) (*models.PemakaianSaldo, error) {
+	var pemakaian *models.PemakaianSaldo
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if pemakaian, err = kunciPemakaian(tx, id); err != nil {
+			return err
+		}
+		dari := pemakaian.Status
+		if dari != models.PemakaianDiajukan && dari != models.PemakaianDisetujui {
+			return fmt.Errorf("%w: pemakaian berstatus %s", ErrStatusPemakaian, dari)
+		}
+		if !BolehMenyetujui(peran, pemakaian.PeranPenyetuju) {
+			return fmt.Errorf("%w: harus ditinjau %s", ErrBukanPenyetuju, pemakaian.PeranPenyetuju)
+		}
+
+		pemakaian.Status = models.PemakaianDitolak
+		if err := tx.Save(pemakaian).Error; err != nil {
+			return err
+		}
+		return catatRiwayatPemakaian(tx, id, dari, models.PemakaianDitolak, adminID, komentar)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pemakaian, nil
+}
+
+// CairkanPemakaian mencairkan pengajuan yang sudah disetujui: pembatasan dana, saldo kas setiap dana,
+// saldo rekening, dana terikat kampanye dan periode dicek, lalu pengeluaran dijurnal sehingga saldo
+// berkurang. tanggal mengganti tanggal pemakaian jika diisi, misalnya saat uang baru dikeluarkan
+// beberapa hari setelah pengajuan. idRekening mengganti rekening sumber uang jika diisi.
+func (s *KeuanganService) CairkanPemakaian(id, adminID, komentar string, tanggal *time.Time, idRekening *string) (*models.PemakaianSaldo, []models.PerubahanRekap, error) {
+	var pemakaian *models.PemakaianSaldo
+	perubahan, err := s.transaksi(TargetPemakaian, id, adminID, "Cairkan pemakaian saldo", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		var err error
+		if pemakaian, err = kunciPemakaian(tx, id); err != nil {
+			return nil, err
+		}
+		if pemakaian.Status != models.PemakaianDisetujui {
+			return nil, fmt.Errorf("%w: hanya pemakaian yang disetujui yang dapat dicairkan (status %s)", ErrStatusPemakaian, pemakaian.Status)
+		}
+		if tanggal != nil {
+			pemakaian.TanggalPemakaian = tanggal
+		}
+		if idRekening != nil && *idRekening != "" {
+			pemakaian.IDRekening = idRekening
+		}
+		if pemakaian.IDRekening, err = tentukanRekening(tx, pemakaian.IDRekening); err != nil {
+			return nil, err
+		}
+
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(tanggalPemakaian(*pemakaian).Format("2006-01")); err != nil {
+			return nil, err
+		}
+		if err := muatRincian(tx, pemakaian); err != nil {
+			return nil, err
+		}
+		if err := cekPembatasanDana(tx, *pemakaian); err != nil {
+			return nil, err
+		}
+		if err := cekSaldoKas(jurnal, nominalPerDana(*pemakaian)); err != nil {
+			return nil, err
+		}
+		if err := cekSaldoRekening(tx, pemakaian.IDRekening, pemakaian.NominalTotal); err != nil {
+			return nil, err
+		}
+		if err := cekDanaTerikat(tx, jurnal, *pemakaian, nil); err != nil {
+			return nil, err
+		}
+		if err := cekPosAnggaran(tx, *pemakaian); err != nil {
+			return nil, err
+		}
+
+		sekarang := time.Now()
+		pemakaian.Status = models.PemakaianDicairkan
+		pemakaian.DicairkanOleh = &adminID
+		pemakaian.WaktuDicairkan = &sekarang
+		if err := tx.Omit("Rincian").Save(pemakaian).Error; err != nil {
+			return nil, err
+		}
+		if err := catatRiwayatPemakaian(tx, id, models.PemakaianDisetujui, models.PemakaianDicairkan, adminID, komentar); err != nil {
+			return nil, err
+		}
+		return jurnal.SinkronPemakaian(*pemakaian, adminID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pemakaian, perubahan, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"tpq_asysyafii/models"
+)
+
+var ambangUji = []models.AmbangPersetujuan{
+	{NominalMinimal: 5000000, PeranPenyetuju: models.RoleSuperAdmin},
+	{NominalMinimal: 1000000, PeranPenyetuju: models.RoleAdmin},
+}
+
+func TestPeranDariAmbang(t *testing.T) {
+	tests := []struct {
+		nama    string
+		ambang  []models.AmbangPersetujuan
+		nominal float64
+		want    models.UserRole
+	}{
+		{"tanpa ambang", nil, 10000000, models.RoleAdmin},
+		{"di bawah semua ambang", ambangUji, 500000, models.RoleAdmin},
+		{"tepat di ambang admin", ambangUji, 1000000, models.RoleAdmin},
+		{"tepat di ambang super admin belum melewati", ambangUji, 5000000, models.RoleAdmin},
+		{"satu rupiah di atas ambang", ambangUji, 5000001, models.RoleSuperAdmin},
+		{"jauh di atas ambang", ambangUji, 25000000, models.RoleSuperAdmin},
+		{"ambang tertinggi yang dilewati dipakai", []models.AmbangPersetujuan{
+			{NominalMinimal: 0, PeranPenyetuju: models.RoleSuperAdmin},
+			{NominalMinimal: 2000000, PeranPenyetuju: models.RoleAdmin},
+		}, 3000000, models.RoleAdmin},
+	}
+	for _, tt := range tests {
+		if got := peranDariAmbang(tt.ambang, tt.nominal); got != tt.want {
+			t.Errorf("%s: peranDariAmbang(%.0f) = %s, want %s", tt.nama, tt.nominal, got, tt.want)
+		}
+	}
+}
+
+func TestBolehMenyetujui(t *testing.T) {
+	tests := []struct {
+		peran, dibutuhkan models.UserRole
+		want              bool
+	}{
+		{models.RoleSuperAdmin, models.RoleSuperAdmin, true},
+		{models.RoleSuperAdmin, models.RoleAdmin, true},
+		{models.RoleAdmin, models.RoleAdmin, true},
+		{models.RoleAdmin, "", true},
+		{models.RoleAdmin, models.RoleSuperAdmin, false},
+		{"ustadz", models.RoleAdmin, false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := BolehMenyetujui(tt.peran, tt.dibutuhkan); got != tt.want {
+			t.Errorf("BolehMenyetujui(%q, %q) = %v, want %v", tt.peran, tt.dibutuhkan, got, tt.want)
+		}
+	}
+}
+
+func TestCekPenyetuju(t *testing.T) {
+	pengajuan := func(status models.StatusPemakaian, dibutuhkan models.UserRole) models.PemakaianSaldo {
+		return models.PemakaianSaldo{Status: status, PeranPenyetuju: dibutuhkan, DiajukanOleh: "admin-a"}
+	}
+	tests := []struct {
+		nama      string
+		pemakaian models.PemakaianSaldo
+		adminID   string
+		peran     models.UserRole
+		want      error
+	}{
+		{"admin lain menyetujui", pengajuan(models.PemakaianDiajukan, models.RoleAdmin), "admin-b", models.RoleAdmin, nil},
+		{"admin menyetujui pengajuan sendiri", pengajuan(models.PemakaianDiajukan, models.RoleAdmin), "admin-a", models.RoleAdmin, ErrBukanPenyetuju},
+		{"super admin menyetujui pengajuan sendiri", pengajuan(models.PemakaianDiajukan, models.RoleSuperAdmin), "admin-a", models.RoleSuperAdmin, nil},
+		{"admin untuk ambang super admin", pengajuan(models.PemakaianDiajukan, models.RoleSuperAdmin), "admin-b", models.RoleAdmin, ErrBukanPenyetuju},
+		{"sudah disetujui", pengajuan(models.PemakaianDisetujui, models.RoleAdmin), "admin-b", models.RoleAdmin, ErrStatusPemakaian},
+		{"sudah dicairkan", pengajuan(models.PemakaianDicairkan, models.RoleAdmin), "admin-b", models.RoleSuperAdmin, ErrStatusPemakaian},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			err := cekPenyetuju(tt.pemakaian, tt.adminID, tt.peran)
+			if tt.want == nil && err != nil {
+				t.Fatalf("cekPenyetuju() error = %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("cekPenyetuju() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCekKenaikan(t *testing.T) {
+	tests := []struct {
+		nama       string
+		status     models.StatusPemakaian
+		lama, baru float64
+		peran      models.UserRole
+		ditolak    bool
+	}{
+		{"dicairkan naik melewati ambang oleh admin", models.PemakaianDicairkan, 4000000, 6000000, models.RoleAdmin, true},
+		{"dicairkan naik melewati ambang oleh super admin", models.PemakaianDicairkan, 4000000, 6000000, models.RoleSuperAdmin, false},
+		{"dicairkan naik tepat ke ambang", models.PemakaianDicairkan, 4000000, 5000000, models.RoleAdmin, false},
+		{"dicairkan naik di bawah ambang", models.PemakaianDicairkan, 200000, 900000, models.RoleAdmin, false},
+		{"dicairkan turun", models.PemakaianDicairkan, 6000000, 5500000, models.RoleAdmin, false},
+		// Pengajuan yang belum dicairkan kembali ke status diajukan dan disetujui ulang
+		{"belum dicairkan naik", models.PemakaianDisetujui, 4000000, 6000000, models.RoleAdmin, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			err := cekKenaikan(ambangUji, tt.status, tt.lama, tt.baru, tt.peran)
+			if tt.ditolak != errors.Is(err, ErrBukanPenyetuju) || (!tt.ditolak && err != nil) {
+				t.Fatalf("cekKenaikan() error = %v, ditolak = %v", err, tt.ditolak)
+			}
+		})
+	}
+}
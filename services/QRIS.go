@@ -0,0 +1,150 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQRISBelumDiatur dikembalikan jika InformasiTPQ belum menyimpan QRIS statis merchant
+var ErrQRISBelumDiatur = errors.New("QRIS statis TPQ belum diatur di informasi TPQ")
+
+// masaBerlakuQRIS adalah lama QRIS dinamis ditampilkan ulang sebelum dibuatkan referensi baru.
+// Pembayaran yang masuk setelahnya tetap dipasangkan hingga toleransiHariQRIS setelah kedaluwarsa.
+const masaBerlakuQRIS = 3 * 24 * time.Hour
+
+// Huruf referensi tanpa karakter yang mirip (0/O, 1/I/L) agar mudah dibaca dari mutasi
+const hurufReferensiQRIS = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// QRISService menerbitkan QRIS dinamis dari QRIS statis TPQ dengan nominal dan referensi tertentu
+type QRISService struct {
+	db *gorm.DB
+}
+
+func NewQRISService(db *gorm.DB) *QRISService {
+	return &QRISService{db: db}
+}
+
+// QRISStatis mengembalikan payload QRIS statis dari informasi TPQ
+func (s *QRISService) QRISStatis() (string, error) {
+	var info models.InformasiTPQ
+	err := s.db.Where("qris_statis IS NOT NULL AND qris_statis <> ''").First(&info).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrQRISBelumDiatur
+		}
+		return "", err
+	}
+	return *info.QRISStatis, nil
+}
+
+// UntukSyahriah menerbitkan QRIS sebesar sisa tagihan. QRIS yang masih berlaku untuk tagihan
+// dan nominal yang sama dipakai ulang agar referensinya tidak berganti setiap kali diminta.
+// Baris tagihan dikunci agar permintaan bersamaan tidak menerbitkan dua referensi.
+func (s *QRISService) UntukSyahriah(syahriah models.Syahriah) (*models.PermintaanQRIS, error) {
+	var hasil *models.PermintaanQRIS
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id_syahriah = ?", syahriah.IDSyahriah).First(&syahriah).Error; err != nil {
+			return err
+		}
+		sisa := SisaTagihan(syahriah)
+		if sisa <= 0.005 {
+			return errors.New("tagihan sudah lunas")
+		}
+
+		var lama models.PermintaanQRIS
+		err := tx.Where("id_syahriah = ? AND status = ? AND nominal = ? AND kedaluwarsa_pada > ?",
+			syahriah.IDSyahriah, models.QRISMenunggu, sisa, time.Now()).
+			Order("waktu_buat DESC").
+			First(&lama).Error
+		if err == nil {
+			hasil = &lama
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		hasil, err = NewQRISService(tx).terbitkan(&models.PermintaanQRIS{
+			Tujuan:     models.QRISUntukSyahriah,
+			IDSyahriah: &syahriah.IDSyahriah,
+			Nominal:    sisa,
+		})
+		return err
+	})
+	return hasil, err
+}
+
+// UntukDonasi menerbitkan QRIS donasi, opsional untuk kampanye tertentu. Donasi baru tercatat
+// setelah uangnya terlihat di mutasi rekening. QRIS yang masih berlaku untuk donatur, nomor
+// telepon, kampanye dan nominal yang sama dipakai ulang agar permintaan berulang tidak
+// menumpuk referensi baru.
+func (s *QRISService) UntukDonasi(nominal float64, namaDonatur, noTelp string, idKampanye *string) (*models.PermintaanQRIS, error) {
+	if nominal < 1000 {
+		return nil, errors.New("nominal donasi minimal Rp 1.000")
+	}
+	if namaDonatur == "" {
+		namaDonatur = NamaAnonim
+	}
+
+	query := s.db.Where("tujuan = ? AND status = ? AND nominal = ? AND nama_donatur = ? AND no_telp = ? AND kedaluwarsa_pada > ?",
+		models.QRISUntukDonasi, models.QRISMenunggu, nominal, namaDonatur, noTelp, time.Now())
+	if idKampanye != nil {
+		query = query.Where("id_kampanye = ?", *idKampanye)
+	} else {
+		query = query.Where("id_kampanye IS NULL")
+	}
+	var lama models.PermintaanQRIS
+	err := query.Order("waktu_buat DESC").First(&lama).Error
+	if err == nil {
+		return &lama, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	return s.terbitkan(&models.PermintaanQRIS{
+		Tujuan:      models.QRISUntukDonasi,
+		NamaDonatur: namaDonatur,
+		NoTelp:      noTelp,
+		IDKampanye:  idKampanye,
+		Nominal:     nominal,
+	})
+}
+
+func (s *QRISService) terbitkan(p *models.PermintaanQRIS) (*models.PermintaanQRIS, error) {
+	statis, err := s.QRISStatis()
+	if err != nil {
+		return nil, err
+	}
+
+	p.IDPermintaan = uuid.New().String()
+	p.Referensi = referensiQRIS()
+	p.Status = models.QRISMenunggu
+	p.KedaluwarsaPada = time.Now().Add(masaBerlakuQRIS)
+	if p.Payload, err = utils.QRISDinamis(statis, p.Nominal, p.Referensi); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(p).Error; err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// referensiQRIS membuat referensi acak seperti "TPQ7K3M9XQ2"
+func referensiQRIS() string {
+	acak := make([]byte, 8)
+	rand.Read(acak)
+	ref := []byte("TPQ")
+	for _, b := range acak {
+		ref = append(ref, hurufReferensiQRIS[int(b)%len(hurufReferensiQRIS)])
+	}
+	return string(ref)
+}
@@ -0,0 +1,533 @@
+package services
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"tpq_asysyafii/models"
+	"tpq_asysyafii/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrRaportTidakValid dikembalikan jika isian raport tidak sesuai aturan
+var ErrRaportTidakValid = errors.New("data raport tidak valid")
+
+// MataPelajaran adalah mata pelajaran yang dinilai di raport selain perkembangan Al-Qur'an
+type MataPelajaran struct {
+	Kode string `json:"kode"`
+	Nama string `json:"nama"`
+}
+
+// MapelRaport adalah daftar mata pelajaran raport sesuai urutan cetak
+var MapelRaport = []MataPelajaran{
+	{"doa_harian", "Doa Harian"},
+	{"fiqih", "Fiqih"},
+	{"akhlak", "Akhlak"},
+	{"praktik_sholat", "Praktik Sholat"},
+}
+
+// IsiNilaiRaport adalah isian nilai satu mata pelajaran
+type IsiNilaiRaport struct {
+	Mapel     string `json:"mapel" binding:"required"`
+	Nilai     int    `json:"nilai"`
+	Deskripsi string `json:"deskripsi"`
+}
+
+// SemesterBerjalan mengembalikan tahun ajaran dan semester untuk tanggal t
+func SemesterBerjalan(t time.Time) (string, models.Semester) {
+	if t.Month() >= time.July {
+		return TahunAjaranBerjalan(t), models.SemesterGanjil
+	}
+	return TahunAjaranBerjalan(t), models.SemesterGenap
+}
+
+// PeriodeSemester mengembalikan rentang tanggal [dari, sampai) sebuah semester.
+// Semester ganjil Juli-Desember tahun pertama, genap Januari-Juni tahun kedua.
+func PeriodeSemester(tahunAjaran string, semester models.Semester) (time.Time, time.Time, error) {
+	if !ValidTahunAjaran(tahunAjaran) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: format tahun ajaran harus YYYY/YYYY", ErrRaportTidakValid)
+	}
+	tahun, _ := strconv.Atoi(tahunAjaran[:4])
+	switch semester {
+	case models.SemesterGanjil:
+		dari := time.Date(tahun, time.July, 1, 0, 0, 0, 0, time.Local)
+		return dari, dari.AddDate(0, 6, 0), nil
+	case models.SemesterGenap:
+		dari := time.Date(tahun+1, time.January, 1, 0, 0, 0, 0, time.Local)
+		return dari, dari.AddDate(0, 6, 0), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("%w: semester harus ganjil atau genap", ErrRaportTidakValid)
+}
+
+// SimpanRaport membuat atau memperbarui raport santri pada semester tersebut. Nilai yang dikirim
+// menimpa nilai mapel yang sama; mapel yang tidak dikirim dibiarkan. catatan nil berarti catatan
+// ustadz tidak diubah.
+func SimpanRaport(db *gorm.DB, raport *models.Raport, nilai []IsiNilaiRaport, catatan *string) error {
+	if _, _, err := PeriodeSemester(raport.TahunAjaran, raport.Semester); err != nil {
+		return err
+	}
+	for _, n := range nilai {
+		if !mapelValid(n.Mapel) {
+			return fmt.Errorf("%w: mata pelajaran %s tidak dikenal", ErrRaportTidakValid, n.Mapel)
+		}
+		if n.Nilai < 0 || n.Nilai > 100 {
+			return fmt.Errorf("%w: nilai harus 0-100", ErrRaportTidakValid)
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var lama models.Raport
+		err := tx.Where("id_santri = ? AND tahun_ajaran = ? AND semester = ?", raport.IDSantri, raport.TahunAjaran, raport.Semester).
+			First(&lama).Error
+		switch {
+		case err == nil:
+			raport.IDRaport = lama.IDRaport
+			updateData := map[string]interface{}{
+				"id_kelas":   raport.IDKelas,
+				"diisi_oleh": raport.DiisiOleh,
+			}
+			if catatan != nil {
+				updateData["catatan_ustadz"] = *catatan
+			}
+			if err := tx.Model(&lama).Updates(updateData).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			raport.IDRaport = uuid.New().String()
+			if catatan != nil {
+				raport.CatatanUstadz = *catatan
+			}
+			if err := tx.Create(raport).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		for _, n := range nilai {
+			var baris models.NilaiRaport
+			err := tx.Where("id_raport = ? AND mapel = ?", raport.IDRaport, n.Mapel).First(&baris).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				baris = models.NilaiRaport{IDNilai: uuid.New().String(), IDRaport: raport.IDRaport, Mapel: n.Mapel}
+			} else if err != nil {
+				return err
+			}
+			baris.Nilai = n.Nilai
+			baris.Deskripsi = n.Deskripsi
+			if err := tx.Save(&baris).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Preload("Nilai").First(raport, "id_raport = ?", raport.IDRaport).Error
+	})
+}
+
+func mapelValid(kode string) bool {
+	for _, m := range MapelRaport {
+		if m.Kode == kode {
+			return true
+		}
+	}
+	return false
+}
+
+// DataRaport adalah seluruh isi raport satu santri yang siap dicetak
+type DataRaport struct {
+	Santri       models.Santri
+	Kelas        *models.Kelas
+	Raport       *models.Raport // nil jika nilai belum diisi
+	TahunAjaran  string
+	Semester     models.Semester
+	Dari         time.Time
+	Sampai       time.Time
+	Absensi      RekapAbsensi
+	Perkembangan Perkembangan
+	Peristiwa    []PeristiwaPerkembangan // tonggak selama semester
+	Munaqosah    []models.Munaqosah      // munaqosah yang dinilai selama semester
+	SetoranNilai float64                 // rata-rata nilai setoran selama semester
+	SetoranLulus int
+	SetoranTotal int
+}
+
+// MuatDataRaport mengumpulkan nilai, kehadiran dan perkembangan Qur'an santri untuk satu semester.
+// Perkembangan dihitung sampai akhir semester, atau hari ini untuk semester yang sedang berjalan.
+func MuatDataRaport(db *gorm.DB, idSantri, tahunAjaran string, semester models.Semester) (*DataRaport, error) {
+	dari, sampai, err := PeriodeSemester(tahunAjaran, semester)
+	if err != nil {
+		return nil, err
+	}
+	data := &DataRaport{TahunAjaran: tahunAjaran, Semester: semester, Dari: dari, Sampai: sampai}
+
+	if err := db.Preload("Wali").Where("id_santri = ?", idSantri).First(&data.Santri).Error; err != nil {
+		return nil, err
+	}
+
+	var raport models.Raport
+	err = db.Preload("Nilai").
+		Where("id_santri = ? AND tahun_ajaran = ? AND semester = ?", idSantri, tahunAjaran, semester).
+		First(&raport).Error
+	if err == nil {
+		data.Raport = &raport
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Kelas raport: kelas saat raport diisi, atau kelas terakhir santri selama semester
+	idKelas := ""
+	if data.Raport != nil {
+		idKelas = data.Raport.IDKelas
+	} else {
+		var anggota models.AnggotaKelas
+		err := db.Where("id_santri = ? AND tanggal_masuk < ? AND (tanggal_keluar IS NULL OR tanggal_keluar >= ?)", idSantri, sampai, dari).
+			Order("tanggal_masuk DESC").First(&anggota).Error
+		if err == nil {
+			idKelas = anggota.IDKelas
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if idKelas != "" {
+		var kelas models.Kelas
+		if err := db.Preload("WaliKelas").Where("id_kelas = ?", idKelas).First(&kelas).Error; err != nil {
+			return nil, err
+		}
+		data.Kelas = &kelas
+	}
+
+	rekap, err := HitungRekapAbsensi(db, "", []string{idSantri}, dari, sampai)
+	if err != nil {
+		return nil, err
+	}
+	data.Absensi = *rekap[idSantri]
+
+	setoran, err := MuatSetoran(db, []string{idSantri})
+	if err != nil {
+		return nil, err
+	}
+	lulus, err := MuatMunaqosahLulus(db, []string{idSantri})
+	if err != nil {
+		return nil, err
+	}
+	// Perkembangan dihitung dari seluruh riwayat sampai akhir semester, statistik setoran hanya semester ini
+	var setoranSampai []models.SetoranSantri
+	totalNilai := 0
+	for _, s := range setoran {
+		if !s.Tanggal.Before(sampai) {
+			break
+		}
+		setoranSampai = append(setoranSampai, s)
+		if !s.Tanggal.Before(dari) {
+			data.SetoranTotal++
+			totalNilai += s.Nilai
+			if s.Lulus {
+				data.SetoranLulus++
+			}
+		}
+	}
+	if data.SetoranTotal > 0 {
+		data.SetoranNilai = float64(totalNilai) / float64(data.SetoranTotal)
+	}
+	var lulusSampai []models.Munaqosah
+	for _, m := range lulus {
+		if m.TanggalUjian != nil && m.TanggalUjian.Before(sampai) {
+			lulusSampai = append(lulusSampai, m)
+		}
+	}
+
+	perkembangan, peristiwa := HitungPerkembangan(idSantri, setoranSampai, lulusSampai, akhirSemester(sampai))
+	data.Perkembangan = perkembangan
+	for _, p := range peristiwa {
+		if !p.Tanggal.Before(dari) {
+			data.Peristiwa = append(data.Peristiwa, p)
+		}
+	}
+
+	if err := db.Where("id_santri = ? AND status IN ? AND tanggal_ujian >= ? AND tanggal_ujian < ?", idSantri,
+		[]models.StatusMunaqosah{models.MunaqosahLulus, models.MunaqosahTidakLulus}, dari, sampai).
+		Order("tanggal_ujian ASC").Find(&data.Munaqosah).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// NamaSemester memformat semester untuk judul cetak, misalnya "Ganjil 2025/2026"
+func NamaSemester(tahunAjaran string, semester models.Semester) string {
+	nama := "Ganjil"
+	if semester == models.SemesterGenap {
+		nama = "Genap"
+	}
+	return nama + " " + tahunAjaran
+}
+
+// akhirSemester mengembalikan hari terakhir semester, atau hari ini untuk semester yang sedang berjalan
+func akhirSemester(sampai time.Time) time.Time {
+	akhir := sampai.AddDate(0, 0, -1)
+	if sekarang := time.Now(); sekarang.Before(akhir) {
+		return sekarang
+	}
+	return akhir
+}
+
+// PDFRaport mencetak raport santri berukuran A4 dengan kop dan kepala TPQ dari InformasiTPQ
+func PDFRaport(db *gorm.DB, data *DataRaport) ([]byte, error) {
+	pdf := utils.NewPDF(utils.A4Lebar, utils.A4Tinggi)
+	kiri, kanan := 42.0, pdf.Lebar()-42
+	tengah := pdf.Lebar() / 2
+
+	tpq := NewKopTPQ(db)
+	y := tpq.Gambar(pdf, kiri, kanan, 32)
+
+	pdf.Teks(tengah, y+26, 14, true, utils.RataTengah, "LAPORAN HASIL BELAJAR SANTRI")
+	pdf.Teks(tengah, y+42, 10, false, utils.RataTengah, "Semester "+NamaSemester(data.TahunAjaran, data.Semester))
+	y += 66
+
+	// Halaman baru jika sisa ruang tidak cukup untuk blok berikutnya
+	cukup := func(tinggi float64) {
+		if y+tinggi > pdf.Tinggi()-40 {
+			pdf.TambahHalaman()
+			y = 50
+		}
+	}
+
+	namaKelas, waliKelas := "-", ""
+	if data.Kelas != nil {
+		namaKelas = data.Kelas.NamaKelas
+		if data.Kelas.WaliKelas != nil {
+			waliKelas = data.Kelas.WaliKelas.NamaLengkap
+		}
+	}
+	identitas := func(x float64, label, isi string) {
+		pdf.Teks(x, y, 9.5, false, utils.RataKiri, label)
+		pdf.Teks(x+80, y, 9.5, false, utils.RataKiri, ": "+isi)
+	}
+	identitas(kiri, "Nama Santri", data.Santri.NamaLengkap)
+	identitas(tengah+20, "Kelas", namaKelas)
+	y += 14
+	identitas(kiri, "Orang Tua/Wali", data.Santri.Wali.NamaLengkap)
+	if waliKelas != "" {
+		identitas(tengah+20, "Wali Kelas", waliKelas)
+	}
+	y += 14
+	identitas(kiri, "Tingkat Bacaan", data.Perkembangan.Tingkat)
+	y += 24
+
+	judul := func(s string) {
+		cukup(60)
+		pdf.Teks(kiri, y, 10.5, true, utils.RataKiri, s)
+		y += 8
+	}
+
+	// A. Nilai mata pelajaran
+	judul("A. Nilai Mata Pelajaran")
+	kolom := []float64{kiri, kiri + 24, kiri + 150, kiri + 190, kiri + 262, kanan}
+	barisTabel := func(tebal bool, isi ...string) {
+		deskripsi := utils.BungkusTeks(isi[4], kolom[5]-kolom[4]-8, 9, false)
+		tinggi := max(18, float64(len(deskripsi))*12+6)
+		cukup(tinggi)
+		if tebal {
+			pdf.Kotak(kiri, y, kanan-kiri, tinggi, 0, 0.9)
+		}
+		pdf.Kotak(kiri, y, kanan-kiri, tinggi, 0.5, 0)
+		for _, x := range kolom[1:5] {
+			pdf.Garis(x, y, x, y+tinggi, 0.5)
+		}
+		pdf.Teks((kolom[0]+kolom[1])/2, y+12, 9, tebal, utils.RataTengah, isi[0])
+		pdf.Teks(kolom[1]+4, y+12, 9, tebal, utils.RataKiri, isi[1])
+		pdf.Teks((kolom[2]+kolom[3])/2, y+12, 9, tebal, utils.RataTengah, isi[2])
+		pdf.Teks((kolom[3]+kolom[4])/2, y+12, 9, tebal, utils.RataTengah, isi[3])
+		for i, b := range deskripsi {
+			pdf.Teks(kolom[4]+4, y+12+float64(i)*12, 9, tebal, utils.RataKiri, b)
+		}
+		y += tinggi
+	}
+	barisTabel(true, "No", "Mata Pelajaran", "Nilai", "Predikat", "Deskripsi")
+	nilai := make(map[string]models.NilaiRaport)
+	if data.Raport != nil {
+		for _, n := range data.Raport.Nilai {
+			nilai[n.Mapel] = n
+		}
+	}
+	for i, m := range MapelRaport {
+		n, ok := nilai[m.Kode]
+		if !ok {
+			barisTabel(false, fmt.Sprint(i+1), m.Nama, "-", "-", "Belum dinilai")
+			continue
+		}
+		barisTabel(false, fmt.Sprint(i+1), m.Nama, fmt.Sprint(n.Nilai), PredikatNilai(n.Nilai), n.Deskripsi)
+	}
+	y += 22
+
+	// B. Perkembangan Al-Qur'an
+	judul("B. Perkembangan Al-Qur'an")
+	y += 6
+	p := data.Perkembangan
+	baris := func(label, isi string) {
+		cukup(14)
+		pdf.Teks(kiri+10, y, 9.5, false, utils.RataKiri, label)
+		y = pdf.Paragraf(kiri+150, y, kanan-kiri-150, 9.5, false, ": "+isi) + 2
+	}
+	baris("Posisi akhir semester", p.Tingkat)
+	if p.Iqro != nil && p.Iqro.Halaman > 0 {
+		baris("Iqro", fmt.Sprintf("Jilid %d halaman %d (%.0f%% dari jilid 1-%d)", p.Iqro.Jilid, p.Iqro.Halaman, p.Iqro.Persen, JumlahJilidIqro))
+	}
+	if p.Tilawah != nil {
+		baris("Tilawah", fmt.Sprintf("Surah %s ayat %d (%.1f%% menuju khatam)", p.Tilawah.NamaSurah, p.Tilawah.Ayat, p.Tilawah.Persen))
+	}
+	baris("Hafalan juz 30", fmt.Sprintf("%d dari %d surah (%.0f%% ayat)", p.Hafalan.JumlahJuz30, p.Hafalan.TotalJuz30, p.Hafalan.Persen))
+	setoran := "Belum ada setoran semester ini"
+	if data.SetoranTotal > 0 {
+		setoran = fmt.Sprintf("%d kali, %d lulus, rata-rata nilai %.1f (%s)", data.SetoranTotal, data.SetoranLulus,
+			data.SetoranNilai, PredikatNilai(int(data.SetoranNilai+0.5)))
+	}
+	baris("Setoran", setoran)
+	for _, m := range data.Munaqosah {
+		hasil := "tidak lulus"
+		if m.Status == models.MunaqosahLulus {
+			hasil = "lulus"
+		}
+		nilaiAkhir := 0.0
+		if m.NilaiAkhir != nil {
+			nilaiAkhir = *m.NilaiAkhir
+		}
+		baris(fmt.Sprintf("Munaqosah jilid %d", m.Jilid), fmt.Sprintf("%s, %s dengan nilai %.1f", utils.TanggalIndonesia(*m.TanggalUjian), hasil, nilaiAkhir))
+	}
+	for _, t := range data.Peristiwa {
+		if t.Jenis == "naik_jilid" || t.Jenis == "lulus_iqro" {
+			continue // sudah tampil sebagai munaqosah atau posisi akhir
+		}
+		baris(utils.TanggalIndonesia(t.Tanggal), t.Keterangan)
+	}
+	y += 18
+
+	// C. Kehadiran
+	judul("C. Kehadiran")
+	y += 6
+	a := data.Absensi
+	lebarSel := (kanan - kiri) / 5
+	cukup(36)
+	for i, sel := range [][2]string{
+		{"Hadir", fmt.Sprint(a.Hadir)},
+		{"Izin", fmt.Sprint(a.Izin)},
+		{"Sakit", fmt.Sprint(a.Sakit)},
+		{"Alpa", fmt.Sprint(a.Alpa)},
+		{"Kehadiran", fmt.Sprintf("%.0f%%", a.PersenHadir)},
+	} {
+		x := kiri + float64(i)*lebarSel
+		pdf.Kotak(x, y, lebarSel, 16, 0, 0.9)
+		pdf.Kotak(x, y, lebarSel, 34, 0.5, 0)
+		pdf.Teks(x+lebarSel/2, y+11, 9, true, utils.RataTengah, sel[0])
+		pdf.Teks(x+lebarSel/2, y+28, 10, false, utils.RataTengah, sel[1])
+	}
+	y += 56
+
+	// D. Catatan ustadz
+	judul("D. Catatan Ustadz/Ustadzah")
+	catatan := "-"
+	if data.Raport != nil && strings.TrimSpace(data.Raport.CatatanUstadz) != "" {
+		catatan = data.Raport.CatatanUstadz
+	}
+	barisCatatan := utils.BungkusTeks(catatan, kanan-kiri-16, 9.5, false)
+	tinggiCatatan := float64(len(barisCatatan))*13 + 12
+	cukup(tinggiCatatan)
+	pdf.Kotak(kiri, y, kanan-kiri, tinggiCatatan, 0.5, 0)
+	pdf.Paragraf(kiri+8, y+14, kanan-kiri-16, 9.5, false, catatan)
+	y += tinggiCatatan + 26
+
+	// Tanda tangan: orang tua, wali kelas, kepala TPQ
+	cukup(110)
+	tempat := tpq.Tempat
+	if tempat != "" {
+		tempat += ", "
+	}
+	lebarTtd := (kanan - kiri) / 3
+	pdf.Teks(kiri+lebarTtd*2.5, y, 9.5, false, utils.RataTengah, tempat+utils.TanggalIndonesia(akhirSemester(data.Sampai)))
+	y += 14
+	for i, ttd := range [][2]string{
+		{"Orang Tua/Wali,", data.Santri.Wali.NamaLengkap},
+		{"Wali Kelas,", waliKelas},
+		{"Kepala " + tpq.Nama + ",", tpq.Kepala},
+	} {
+		x := kiri + lebarTtd*(float64(i)+0.5)
+		for j, b := range utils.BungkusTeks(ttd[0], lebarTtd-10, 9.5, false) {
+			pdf.Teks(x, y+float64(j)*12, 9.5, false, utils.RataTengah, b)
+		}
+		pdf.Teks(x, y+70, 9.5, true, utils.RataTengah, ttd[1])
+		pdf.Garis(x-65, y+73, x+65, y+73, 0.5)
+	}
+
+	pdf.Teks(kiri, pdf.Tinggi()-20, 7, false, utils.RataKiri,
+		fmt.Sprintf("Dicetak %s", time.Now().Format("02-01-2006 15:04")))
+	return pdf.Bytes(), nil
+}
+
+// AnggotaRaportKelas mengambil santri yang menjadi anggota kelas pada akhir semester
+func AnggotaRaportKelas(db *gorm.DB, idKelas, tahunAjaran string, semester models.Semester) ([]string, error) {
+	_, sampai, err := PeriodeSemester(tahunAjaran, semester)
+	if err != nil {
+		return nil, err
+	}
+	return AnggotaKelasPada(db, idKelas, akhirSemester(sampai))
+}
+
+// ZIPRaportKelas menulis raport seluruh anggota kelas pada akhir semester sebagai satu arsip ZIP
+// dan mengembalikan jumlah raport di dalamnya
+func ZIPRaportKelas(db *gorm.DB, kelas models.Kelas, tahunAjaran string, semester models.Semester, w io.Writer) (int, error) {
+	idSantri, err := AnggotaRaportKelas(db, kelas.IDKelas, tahunAjaran, semester)
+	if err != nil {
+		return 0, err
+	}
+
+	var daftar []models.Santri
+	if len(idSantri) > 0 {
+		if err := db.Where("id_santri IN ?", idSantri).Order("nama_lengkap ASC").Find(&daftar).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	z := zip.NewWriter(w)
+	for i, santri := range daftar {
+		data, err := MuatDataRaport(db, santri.IDSantri, tahunAjaran, semester)
+		if err != nil {
+			return i, err
+		}
+		// Raport dicetak atas nama kelas yang diminta meskipun santri pindah di tengah semester
+		if data.Raport == nil {
+			data.Kelas = &kelas
+		}
+		isi, err := PDFRaport(db, data)
+		if err != nil {
+			return i, err
+		}
+		berkas, err := z.Create(fmt.Sprintf("%02d-%s.pdf", i+1, namaBerkas(santri.NamaLengkap)))
+		if err != nil {
+			return i, err
+		}
+		if _, err := berkas.Write(isi); err != nil {
+			return i, err
+		}
+	}
+	return len(daftar), z.Close()
+}
+
+// namaBerkas mengubah teks menjadi nama berkas yang aman, huruf kecil dipisah tanda minus
+func namaBerkas(s string) string {
+	var b strings.Builder
+	minus := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			minus = false
+		} else if !minus && b.Len() > 0 {
+			b.WriteRune('-')
+			minus = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
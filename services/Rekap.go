@@ -0,0 +1,289 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RekapService membangun RekapSaldo sebagai proyeksi dari jurnal
+type RekapService struct {
+	db *gorm.DB
+}
+
+func NewRekapService(db *gorm.DB) *RekapService {
+	return &RekapService{db: db}
+}
+
+// mutasiKas adalah jumlah debit dan kredit satu akun kas per periode dan jenis jurnal
+type mutasiKas struct {
+	Periode  string
+	KodeAkun string
+	Jenis    models.JenisJurnal
+	Debit    float64
+	Kredit   float64
+}
+
+type saldoKas struct {
+	KodeAkun string
+	Saldo    float64
+}
+
+// HitungPeriode menghitung ulang baris RekapSaldo satu periode beserta RekapDana setiap dana dari jurnal
+func (s *RekapService) HitungPeriode(periode string) (models.RekapSaldo, error) {
+	var rekap models.RekapSaldo
+	if _, err := time.Parse("2006-01", periode); err != nil {
+		return rekap, fmt.Errorf("format periode tidak valid: %s", periode)
+	}
+
+	dana, err := DaftarDana(s.db, false)
+	if err != nil {
+		return rekap, err
+	}
+	akunKas := make([]string, len(dana))
+	for i, d := range dana {
+		akunKas[i] = d.KodeAkunKas
+	}
+
+	// Mutasi kas sampai periode ini per periode, akun dan jenis jurnal; saldo akhir adalah akumulasinya
+	var mutasi []mutasiKas
+	err = s.db.Table("jurnal_detail").
+		Select("jurnal.periode, jurnal_detail.kode_akun, jurnal.jenis, COALESCE(SUM(jurnal_detail.debit), 0) AS debit, COALESCE(SUM(jurnal_detail.kredit), 0) AS kredit").
+		Joins("JOIN jurnal ON jurnal.id_jurnal = jurnal_detail.id_jurnal").
+		Where("jurnal.periode <= ? AND jurnal_detail.kode_akun IN ?", periode, akunKas).
+		Group("jurnal.periode, jurnal_detail.kode_akun, jurnal.jenis").
+		Scan(&mutasi).Error
+	if err != nil {
+		return rekap, err
+	}
+
+	err = s.db.Where("periode = ?", periode).First(&rekap).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return rekap, err
+		}
+		rekap = models.RekapSaldo{
+			IDSaldo: uuid.New().String(),
+			Periode: periode,
+		}
+	}
+
+	susunRekap(&rekap, dana, mutasi)
+	rekap.TerakhirUpdate = time.Now()
+
+	if err := s.db.Omit("PerDana").Save(&rekap).Error; err != nil {
+		return rekap, err
+	}
+	if err := s.db.Where("periode = ?", periode).Delete(&models.RekapDana{}).Error; err != nil {
+		return rekap, err
+	}
+	if len(rekap.PerDana) == 0 {
+		return rekap, nil
+	}
+	return rekap, s.db.Omit("Dana").Create(&rekap.PerDana).Error
+}
+
+// susunRekap mengisi nilai rekap periode rekap.Periode dari mutasi kas sampai periode tersebut.
+// Pemasukan dan pengeluaran hanya dari mutasi periode itu, saldo akhir dari seluruh mutasi.
+func susunRekap(rekap *models.RekapSaldo, dana []models.Dana, mutasi []mutasiKas) {
+	pemasukan := make(map[string]float64)
+	pengeluaran := make(map[string]float64)
+	saldoAkhir := make(map[string]float64)
+	for _, m := range mutasi {
+		if m.Periode > rekap.Periode {
+			continue
+		}
+		saldoAkhir[m.KodeAkun] += m.Debit - m.Kredit
+		if m.Periode != rekap.Periode {
+			continue
+		}
+		switch m.Jenis {
+		case models.JurnalPemasukan:
+			pemasukan[m.KodeAkun] += m.Debit - m.Kredit
+		case models.JurnalPengeluaran:
+			pengeluaran[m.KodeAkun] += m.Kredit - m.Debit
+		}
+	}
+
+	rekap.PemasukanSyahriah = pemasukan[models.KodeKasSyahriah]
+	rekap.PengeluaranSyahriah = pengeluaran[models.KodeKasSyahriah]
+	rekap.SaldoAkhirSyahriah = saldoAkhir[models.KodeKasSyahriah]
+	rekap.PemasukanDonasi = pemasukan[models.KodeKasDonasi]
+	rekap.PengeluaranDonasi = pengeluaran[models.KodeKasDonasi]
+	rekap.SaldoAkhirDonasi = saldoAkhir[models.KodeKasDonasi]
+	rekap.PemasukanTotal, rekap.PengeluaranTotal, rekap.SaldoAkhirTotal = 0, 0, 0
+	rekap.PerDana = make([]models.RekapDana, len(dana))
+	for i, d := range dana {
+		rekap.PerDana[i] = models.RekapDana{
+			Periode:     rekap.Periode,
+			KodeDana:    d.KodeDana,
+			Pemasukan:   pemasukan[d.KodeAkunKas],
+			Pengeluaran: pengeluaran[d.KodeAkunKas],
+			SaldoAkhir:  saldoAkhir[d.KodeAkunKas],
+		}
+		rekap.PemasukanTotal += rekap.PerDana[i].Pemasukan
+		rekap.PengeluaranTotal += rekap.PerDana[i].Pengeluaran
+		rekap.SaldoAkhirTotal += rekap.PerDana[i].SaldoAkhir
+	}
+}
+
+// HitungBerantai menghitung ulang periode awal dan semua periode setelahnya, karena saldo akhir
+// setiap periode bergantung pada seluruh jurnal sebelumnya. Ditolak selama masih ada transaksi
+// lama yang belum dijurnal. Mengembalikan periode yang dihitung
+// dan perubahan (belum disimpan) untuk periode yang nilainya berubah.
+func (s *RekapService) HitungBerantai(dariPeriode string) ([]string, []models.PerubahanRekap, error) {
+	if err := cekJurnalLamaLengkap(s.db); err != nil {
+		return nil, nil, err
+	}
+	sampai := time.Now().Format("2006-01")
+
+	var jurnalTerakhir string
+	if err := s.db.Model(&models.Jurnal{}).
+		Select("COALESCE(MAX(periode), '')").
+		Scan(&jurnalTerakhir).Error; err != nil {
+		return nil, nil, err
+	}
+	if jurnalTerakhir > sampai {
+		sampai = jurnalTerakhir
+	}
+
+	tutupTerakhir, err := NewTutupBukuService(s.db).PeriodeTutupTerakhir()
+	if err != nil {
+		return nil, nil, err
+	}
+	periodes, err := periodeHitungUlang(dariPeriode, sampai, tutupTerakhir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var perubahan []models.PerubahanRekap
+	for _, periode := range periodes {
+		var lama models.RekapSaldo
+		if err := s.db.Where("periode = ?", periode).First(&lama).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, err
+		}
+
+		baru, err := s.HitungPeriode(periode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("gagal update rekap periode %s: %v", periode, err)
+		}
+
+		if p, berubah := bandingkanRekap(lama, baru); berubah {
+			perubahan = append(perubahan, p)
+		}
+	}
+	return periodes, perubahan, nil
+}
+
+// RebuildSemua membangun ulang seluruh RekapSaldo dari jurnal pertama
+func (s *RekapService) RebuildSemua() ([]string, []models.PerubahanRekap, error) {
+	var awal struct {
+		Jurnal string
+		Rekap  string
+	}
+	if err := s.db.Model(&models.Jurnal{}).
+		Select("COALESCE(MIN(periode), '')").
+		Scan(&awal.Jurnal).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := s.db.Model(&models.RekapSaldo{}).
+		Select("COALESCE(MIN(periode), '')").
+		Scan(&awal.Rekap).Error; err != nil {
+		return nil, nil, err
+	}
+
+	dari := awal.Jurnal
+	if dari == "" || (awal.Rekap != "" && awal.Rekap < dari) {
+		dari = awal.Rekap
+	}
+	if dari == "" {
+		return []string{}, nil, nil
+	}
+
+	return s.HitungBerantai(dari)
+}
+
+// periodeHitungUlang menghasilkan periode dari..sampai yang perlu dihitung ulang. Periode sampai
+// tutupTerakhir sudah tutup buku dan tidak boleh berubah lagi, sehingga dilewati.
+func periodeHitungUlang(dari, sampai, tutupTerakhir string) ([]string, error) {
+	if tutupTerakhir != "" && dari <= tutupTerakhir {
+		var err error
+		if dari, err = periodeBerikutnya(tutupTerakhir); err != nil {
+			return nil, err
+		}
+	}
+	if dari > sampai {
+		return nil, nil
+	}
+	return DaftarPeriode(dari, sampai)
+}
+
+// SimpanPerubahan mencatat perubahan rekap beserta sumber yang memicunya
+func (s *RekapService) SimpanPerubahan(perubahan []models.PerubahanRekap, tipeSumber, idSumber, adminID, keterangan string) error {
+	if len(perubahan) == 0 {
+		return nil
+	}
+	for i := range perubahan {
+		perubahan[i].IDPerubahan = uuid.New().String()
+		perubahan[i].TipeSumber = tipeSumber
+		perubahan[i].IDSumber = idSumber
+		perubahan[i].DipicuOleh = adminID
+		perubahan[i].Keterangan = keterangan
+	}
+	return s.db.Create(&perubahan).Error
+}
+
+// bandingkanRekap menghitung selisih rekap sebelum dan sesudah dihitung ulang
+func bandingkanRekap(lama, baru models.RekapSaldo) (models.PerubahanRekap, bool) {
+	p := models.PerubahanRekap{
+		Periode:              baru.Periode,
+		SelisihPemasukan:     baru.PemasukanTotal - lama.PemasukanTotal,
+		SelisihPengeluaran:   baru.PengeluaranTotal - lama.PengeluaranTotal,
+		SelisihSaldoSyahriah: baru.SaldoAkhirSyahriah - lama.SaldoAkhirSyahriah,
+		SelisihSaldoDonasi:   baru.SaldoAkhirDonasi - lama.SaldoAkhirDonasi,
+		SaldoAkhirLama:       lama.SaldoAkhirTotal,
+		SaldoAkhirBaru:       baru.SaldoAkhirTotal,
+	}
+
+	// Selisih saldo total juga menangkap perubahan dana selain syahriah dan donasi
+	selisihTotal := p.SaldoAkhirBaru - p.SaldoAkhirLama
+	for _, selisih := range []float64{p.SelisihPemasukan, p.SelisihPengeluaran, p.SelisihSaldoSyahriah, p.SelisihSaldoDonasi, selisihTotal} {
+		if math.Abs(selisih) > 0.005 {
+			return p, true
+		}
+	}
+	return p, false
+}
+
+// DaftarPeriode menghasilkan daftar periode YYYY-MM dari..sampai (inklusif)
+func DaftarPeriode(dari, sampai string) ([]string, error) {
+	start, err := time.Parse("2006-01", dari)
+	if err != nil {
+		return nil, fmt.Errorf("format periode tidak valid: %s", dari)
+	}
+	end, err := time.Parse("2006-01", sampai)
+	if err != nil {
+		return nil, fmt.Errorf("format periode tidak valid: %s", sampai)
+	}
+
+	var periodes []string
+	for p := start; !p.After(end); p = p.AddDate(0, 1, 0) {
+		periodes = append(periodes, p.Format("2006-01"))
+	}
+	return periodes, nil
+}
+
+// periodeBerikutnya mengembalikan periode YYYY-MM satu bulan setelah periode
+func periodeBerikutnya(periode string) (string, error) {
+	t, err := time.Parse("2006-01", periode)
+	if err != nil {
+		return "", fmt.Errorf("format periode tidak valid: %s", periode)
+	}
+	return t.AddDate(0, 1, 0).Format("2006-01"), nil
+}
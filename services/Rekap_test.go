@@ -0,0 +1,239 @@
+package services
+
+import (
+	"testing"
+
+	"tpq_asysyafii/models"
+)
+
+var danaUji = []models.Dana{
+	{KodeDana: models.KodeDanaSyahriah, KodeAkunKas: models.KodeKasSyahriah},
+	{KodeDana: models.KodeDanaDonasi, KodeAkunKas: models.KodeKasDonasi},
+}
+
+// jurnalKas menyusun jurnal dua baris: akun kas didebit saat pemasukan dan dikredit saat pengeluaran
+func jurnalKas(id, periode string, jenis models.JenisJurnal, akunKas, lawan string, nominal float64) models.Jurnal {
+	kas := models.JurnalDetail{KodeAkun: akunKas, Debit: nominal}
+	imbangan := models.JurnalDetail{KodeAkun: lawan, Kredit: nominal}
+	if jenis == models.JurnalPengeluaran {
+		kas.Debit, kas.Kredit = 0, nominal
+		imbangan.Debit, imbangan.Kredit = nominal, 0
+	}
+	return models.Jurnal{IDJurnal: id, Periode: periode, Jenis: jenis, Detail: []models.JurnalDetail{kas, imbangan}}
+}
+
+// mutasiDariJurnal mengelompokkan baris jurnal akun kas seperti query di HitungPeriode
+func mutasiDariJurnal(jurnal []models.Jurnal) []mutasiKas {
+	akunKas := map[string]bool{}
+	for _, d := range danaUji {
+		akunKas[d.KodeAkunKas] = true
+	}
+	indeks := map[mutasiKas]int{}
+	var hasil []mutasiKas
+	for _, j := range jurnal {
+		for _, d := range j.Detail {
+			if !akunKas[d.KodeAkun] {
+				continue
+			}
+			kunci := mutasiKas{Periode: j.Periode, KodeAkun: d.KodeAkun, Jenis: j.Jenis}
+			i, ada := indeks[kunci]
+			if !ada {
+				i = len(hasil)
+				indeks[kunci] = i
+				hasil = append(hasil, kunci)
+			}
+			hasil[i].Debit += d.Debit
+			hasil[i].Kredit += d.Kredit
+		}
+	}
+	return hasil
+}
+
+// hitungRekap meniru HitungBerantai tanpa database: periode yang dihitung ulang disusun dari jurnal,
+// periode lain tetap memakai rekap lama
+func hitungRekap(t *testing.T, lama map[string]models.RekapSaldo, jurnal []models.Jurnal, dari, sampai, tutupTerakhir string) (map[string]models.RekapSaldo, []models.PerubahanRekap) {
+	t.Helper()
+	periodes, err := periodeHitungUlang(dari, sampai, tutupTerakhir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baru := make(map[string]models.RekapSaldo, len(lama))
+	for p, r := range lama {
+		baru[p] = r
+	}
+	mutasi := mutasiDariJurnal(jurnal)
+	var perubahan []models.PerubahanRekap
+	for _, periode := range periodes {
+		rekap := models.RekapSaldo{Periode: periode}
+		susunRekap(&rekap, danaUji, mutasi)
+		if p, berubah := bandingkanRekap(lama[periode], rekap); berubah {
+			perubahan = append(perubahan, p)
+		}
+		baru[periode] = rekap
+	}
+	return baru, perubahan
+}
+
+func TestPeriodeHitungUlang(t *testing.T) {
+	tests := []struct {
+		nama          string
+		dari, sampai  string
+		tutupTerakhir string
+		want          []string
+	}{
+		{"tanpa tutup buku", "2025-01", "2025-03", "", []string{"2025-01", "2025-02", "2025-03"}},
+		{"mulai setelah periode tutup", "2025-02", "2025-03", "2025-01", []string{"2025-02", "2025-03"}},
+		{"periode tutup dilewati", "2025-01", "2025-03", "2025-01", []string{"2025-02", "2025-03"}},
+		{"lintas tahun", "2024-11", "2025-02", "2024-12", []string{"2025-01", "2025-02"}},
+		{"semua periode sudah tutup", "2025-01", "2025-03", "2025-03", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			got, err := periodeHitungUlang(tt.dari, tt.sampai, tt.tutupTerakhir)
+			if err != nil {
+				t.Fatalf("periodeHitungUlang() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("periodeHitungUlang() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("periodeHitungUlang() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestJurnalPembalikNetNol(t *testing.T) {
+	asal := jurnalKas("j1", "2025-01", models.JurnalPemasukan, models.KodeKasSyahriah, models.KodePendapatanSyahriah, 110000)
+	asal.Keterangan = "Pembayaran syahriah"
+	pembalik := jurnalPembalik(asal, "admin")
+
+	if pembalik.Periode != asal.Periode || pembalik.Jenis != asal.Jenis {
+		t.Errorf("periode, jenis = %s, %s, want %s, %s", pembalik.Periode, pembalik.Jenis, asal.Periode, asal.Jenis)
+	}
+	if pembalik.IDJurnalAsal == nil || *pembalik.IDJurnalAsal != "j1" {
+		t.Errorf("IDJurnalAsal = %v, want j1", pembalik.IDJurnalAsal)
+	}
+
+	saldo := map[string]float64{}
+	for _, j := range []models.Jurnal{asal, pembalik} {
+		for _, d := range j.Detail {
+			saldo[d.KodeAkun] += d.Debit - d.Kredit
+		}
+	}
+	for akun, nilai := range saldo {
+		if nilai != 0 {
+			t.Errorf("saldo %s setelah dibalik = %.0f, want 0", akun, nilai)
+		}
+	}
+
+	// Di rekap, jurnal asal dan pembaliknya tidak menyisakan pemasukan maupun saldo
+	rekap := models.RekapSaldo{Periode: "2025-01"}
+	susunRekap(&rekap, danaUji, mutasiDariJurnal([]models.Jurnal{asal, pembalik}))
+	if rekap.PemasukanTotal != 0 || rekap.SaldoAkhirTotal != 0 {
+		t.Errorf("PemasukanTotal, SaldoAkhirTotal = %.0f, %.0f, want 0, 0", rekap.PemasukanTotal, rekap.SaldoAkhirTotal)
+	}
+}
+
+func TestBandingkanRekap(t *testing.T) {
+	lama := models.RekapSaldo{Periode: "2025-02", PemasukanTotal: 100000, SaldoAkhirSyahriah: 80000, SaldoAkhirDonasi: 20000, SaldoAkhirTotal: 150000}
+	tests := []struct {
+		nama    string
+		ubah    func(r *models.RekapSaldo)
+		berubah bool
+	}{
+		{"tidak berubah", func(r *models.RekapSaldo) {}, false},
+		{"selisih pembulatan", func(r *models.RekapSaldo) { r.SaldoAkhirTotal += 0.004 }, false},
+		{"pemasukan bertambah", func(r *models.RekapSaldo) { r.PemasukanTotal += 10000 }, true},
+		{"pengeluaran bertambah", func(r *models.RekapSaldo) { r.PengeluaranTotal += 10000 }, true},
+		{"saldo syahriah", func(r *models.RekapSaldo) { r.SaldoAkhirSyahriah -= 5000 }, true},
+		// Dana selain syahriah dan donasi hanya terlihat di saldo total
+		{"saldo dana lain", func(r *models.RekapSaldo) { r.SaldoAkhirTotal += 25000 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			baru := lama
+			tt.ubah(&baru)
+			p, berubah := bandingkanRekap(lama, baru)
+			if berubah != tt.berubah {
+				t.Fatalf("bandingkanRekap() berubah = %v, want %v", berubah, tt.berubah)
+			}
+			if p.Periode != "2025-02" || p.SaldoAkhirLama != lama.SaldoAkhirTotal || p.SaldoAkhirBaru != baru.SaldoAkhirTotal {
+				t.Errorf("bandingkanRekap() = %+v", p)
+			}
+		})
+	}
+}
+
+func TestRekapBerantaiSetelahKoreksi(t *testing.T) {
+	syahriahJan := jurnalKas("syahriah-jan", "2025-01", models.JurnalPemasukan, models.KodeKasSyahriah, models.KodePendapatanSyahriah, 100000)
+	jurnal := []models.Jurnal{
+		syahriahJan,
+		jurnalKas("donasi-jan", "2025-01", models.JurnalPemasukan, models.KodeKasDonasi, models.KodePendapatanDonasi, 50000),
+		jurnalKas("syahriah-feb", "2025-02", models.JurnalPemasukan, models.KodeKasSyahriah, models.KodePendapatanSyahriah, 110000),
+		jurnalKas("beban-feb", "2025-02", models.JurnalPengeluaran, models.KodeKasSyahriah, models.KodeBebanOperasional, 30000),
+		jurnalKas("donasi-mar", "2025-03", models.JurnalPemasukan, models.KodeKasDonasi, models.KodePendapatanDonasi, 20000),
+	}
+	awal, _ := hitungRekap(t, nil, jurnal, "2025-01", "2025-03", "")
+
+	// Pembayaran Januari dikoreksi menjadi 120.000: jurnal lama dibalik lalu jurnal baru diposting
+	koreksi := append(jurnal,
+		jurnalPembalik(syahriahJan, "admin"),
+		jurnalKas("syahriah-jan-2", "2025-01", models.JurnalPemasukan, models.KodeKasSyahriah, models.KodePendapatanSyahriah, 120000),
+	)
+
+	tests := []struct {
+		nama          string
+		tutupTerakhir string
+		// nilai rekap per periode setelah koreksi: pemasukan, pengeluaran, saldo syahriah, saldo total
+		want map[string][4]float64
+		// selisih saldo syahriah yang tercatat di PerubahanRekap per periode
+		perubahan map[string]float64
+	}{
+		{
+			nama: "koreksi berantai ke periode berikutnya",
+			want: map[string][4]float64{
+				"2025-01": {170000, 0, 120000, 170000},
+				"2025-02": {110000, 30000, 200000, 250000},
+				"2025-03": {20000, 0, 200000, 270000},
+			},
+			perubahan: map[string]float64{"2025-01": 20000, "2025-02": 20000, "2025-03": 20000},
+		},
+		{
+			nama:          "periode tutup buku tidak dihitung ulang",
+			tutupTerakhir: "2025-01",
+			want: map[string][4]float64{
+				"2025-01": {150000, 0, 100000, 150000},
+				"2025-02": {110000, 30000, 200000, 250000},
+				"2025-03": {20000, 0, 200000, 270000},
+			},
+			perubahan: map[string]float64{"2025-02": 20000, "2025-03": 20000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			rekap, perubahan := hitungRekap(t, awal, koreksi, "2025-01", "2025-03", tt.tutupTerakhir)
+			for periode, w := range tt.want {
+				r := rekap[periode]
+				got := [4]float64{r.PemasukanTotal, r.PengeluaranTotal, r.SaldoAkhirSyahriah, r.SaldoAkhirTotal}
+				if got != w {
+					t.Errorf("%s: pemasukan, pengeluaran, saldo syahriah, saldo total = %v, want %v", periode, got, w)
+				}
+			}
+
+			if len(perubahan) != len(tt.perubahan) {
+				t.Fatalf("PerubahanRekap = %+v, want %d periode", perubahan, len(tt.perubahan))
+			}
+			for _, p := range perubahan {
+				if p.SelisihSaldoSyahriah != tt.perubahan[p.Periode] || p.SaldoAkhirBaru-p.SaldoAkhirLama != tt.perubahan[p.Periode] {
+					t.Errorf("%s: PerubahanRekap = %+v, want selisih %.0f", p.Periode, p, tt.perubahan[p.Periode])
+				}
+				if wantPemasukan := map[string]float64{"2025-01": 20000}[p.Periode]; p.SelisihPemasukan != wantPemasukan {
+					t.Errorf("%s: SelisihPemasukan = %.0f, want %.0f", p.Periode, p.SelisihPemasukan, wantPemasukan)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrRekeningTidakValid dikembalikan jika rekening tidak dikenal atau sudah dinonaktifkan
+var ErrRekeningTidakValid = errors.New("rekening tidak ditemukan atau tidak aktif")
+
+// RekeningUtama mengambil rekening utama, nil jika belum ada
+func RekeningUtama(db *gorm.DB) (*models.Rekening, error) {
+	var rekening models.Rekening
+	err := db.Where("utama = ?", true).Order("dibuat_pada ASC").First(&rekening).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rekening, nil
+}
+
+// tentukanRekening memvalidasi rekening pilihan untuk transaksi baru. Kosong berarti rekening utama.
+func tentukanRekening(db *gorm.DB, idRekening *string) (*string, error) {
+	if idRekening == nil || *idRekening == "" {
+		utama, err := RekeningUtama(db)
+		if err != nil || utama == nil {
+			return nil, err
+		}
+		return &utama.IDRekening, nil
+	}
+	var jumlah int64
+	if err := db.Model(&models.Rekening{}).Where("id_rekening = ? AND aktif = ?", *idRekening, true).Count(&jumlah).Error; err != nil {
+		return nil, err
+	}
+	if jumlah == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRekeningTidakValid, *idRekening)
+	}
+	return idRekening, nil
+}
+
+// rekeningJurnal menentukan rekening untuk baris kas jurnal. Transaksi lama tanpa rekening
+// dianggap memakai rekening utama.
+func rekeningJurnal(db *gorm.DB, idRekening *string) (*string, error) {
+	if idRekening != nil && *idRekening != "" {
+		return idRekening, nil
+	}
+	utama, err := RekeningUtama(db)
+	if err != nil || utama == nil {
+		return nil, err
+	}
+	return &utama.IDRekening, nil
+}
+
+// SeedRekeningUtama membuat rekening "Kas Tunai" sebagai rekening utama jika belum ada satu pun
+// rekening, lalu menandai baris jurnal kas lama yang belum punya rekening ke rekening utama.
+// Mengembalikan jumlah baris jurnal yang ditandai. Aman dijalankan berulang.
+func SeedRekeningUtama(db *gorm.DB) (int64, error) {
+	var jumlah int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		utama, err := RekeningUtama(tx)
+		if err != nil {
+			return err
+		}
+		if utama == nil {
+			var total int64
+			if err := tx.Model(&models.Rekening{}).Count(&total).Error; err != nil {
+				return err
+			}
+			if total > 0 {
+				// Rekening sudah diatur admin tanpa rekening utama; jurnal lama dibiarkan
+				return nil
+			}
+			utama = &models.Rekening{
+				IDRekening:   uuid.New().String(),
+				NamaRekening: "Kas Tunai",
+				Jenis:        models.RekeningTunai,
+				Utama:        true,
+				Aktif:        true,
+			}
+			if err := tx.Create(utama).Error; err != nil {
+				return err
+			}
+		}
+
+		// Transaksi lama ikut ditandai agar jurnalnya tidak pindah rekening jika rekening utama diganti
+		for _, model := range []interface{}{&models.PembayaranSyahriah{}, &models.Donasi{}, &models.PemakaianSaldo{}} {
+			if err := tx.Model(model).Where("id_rekening IS NULL").Update("id_rekening", utama.IDRekening).Error; err != nil {
+				return err
+			}
+		}
+
+		dana, err := DaftarDana(tx, false)
+		if err != nil {
+			return err
+		}
+		akunKas := make([]string, len(dana))
+		for i, d := range dana {
+			akunKas[i] = d.KodeAkunKas
+		}
+		result := tx.Model(&models.JurnalDetail{}).
+			Where("id_rekening IS NULL AND kode_akun IN ?", akunKas).
+			Update("id_rekening", utama.IDRekening)
+		jumlah = result.RowsAffected
+		return result.Error
+	})
+	return jumlah, err
+}
+
+// SaldoRekening menghitung saldo setiap rekening dari jurnal sampai akhir tanggal sampai.
+// sampai bernilai nol berarti seluruh jurnal.
+func SaldoRekening(db *gorm.DB, sampai time.Time) (map[string]float64, error) {
+	var saldo []struct {
+		IDRekening string
+		Saldo      float64
+	}
+	query := db.Table("jurnal_detail").
+		Select("jurnal_detail.id_rekening, COALESCE(SUM(jurnal_detail.debit - jurnal_detail.kredit), 0) AS saldo").
+		Where("jurnal_detail.id_rekening IS NOT NULL")
+	if !sampai.IsZero() {
+		query = query.Joins("JOIN jurnal ON jurnal.id_jurnal = jurnal_detail.id_jurnal").
+			Where("jurnal.tanggal < ?", time.Date(sampai.Year(), sampai.Month(), sampai.Day()+1, 0, 0, 0, 0, sampai.Location()))
+	}
+	if err := query.Group("jurnal_detail.id_rekening").Scan(&saldo).Error; err != nil {
+		return nil, err
+	}
+
+	hasil := make(map[string]float64, len(saldo))
+	for _, s := range saldo {
+		hasil[s.IDRekening] = s.Saldo
+	}
+	return hasil, nil
+}
+
+// cekSaldoRekening memastikan saldo rekening cukup untuk mengeluarkan nominal
+func cekSaldoRekening(db *gorm.DB, idRekening *string, nominal float64) error {
+	if idRekening == nil || nominal <= 0 {
+		return nil
+	}
+	saldo, err := SaldoRekening(db, time.Time{})
+	if err != nil {
+		return err
+	}
+	if nominal > saldo[*idRekening]+0.005 {
+		var rekening models.Rekening
+		nama := *idRekening
+		if err := db.Where("id_rekening = ?", *idRekening).First(&rekening).Error; err == nil {
+			nama = rekening.NamaRekening
+		}
+		return fmt.Errorf("%w: saldo rekening %s Rp %.0f", ErrSaldoTidakCukup, nama, saldo[*idRekening])
+	}
+	return nil
+}
+
+// CreateRekening menyimpan rekening baru. Saldo awal dicatat sebagai pindah buku dari rekening
+// utama, karena uangnya sudah termasuk dalam saldo dana yang selama ini dianggap ada di rekening utama.
+func (s *KeuanganService) CreateRekening(rekening *models.Rekening, saldoAwal float64, tanggal time.Time, adminID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		utama, err := RekeningUtama(tx)
+		if err != nil {
+			return err
+		}
+		if rekening.Utama || utama == nil {
+			// Rekening pertama otomatis menjadi rekening utama
+			if err := tx.Model(&models.Rekening{}).Where("utama = ?", true).Update("utama", false).Error; err != nil {
+				return err
+			}
+			rekening.Utama = true
+		}
+		if err := tx.Create(rekening).Error; err != nil {
+			return err
+		}
+		if saldoAwal <= 0 || utama == nil || utama.IDRekening == rekening.IDRekening {
+			return nil
+		}
+
+		transfer := models.TransferRekening{
+			IDTransfer:   uuid.New().String(),
+			DariRekening: utama.IDRekening,
+			KeRekening:   rekening.IDRekening,
+			Nominal:      saldoAwal,
+			Tanggal:      tanggal,
+			Keterangan:   "Saldo awal " + rekening.NamaRekening,
+			DicatatOleh:  adminID,
+		}
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+		_, err = NewJurnalService(tx).SinkronTransfer(transfer, adminID)
+		return err
+	})
+}
+
+// CreateTransfer mencatat pemindahan uang antar rekening. Saldo dana dan RekapSaldo tidak berubah.
+func (s *KeuanganService) CreateTransfer(transfer *models.TransferRekening, adminID string) error {
+	_, err := s.transaksi(TargetTransferRekening, transfer.IDTransfer, adminID, "Transfer antar rekening", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		if transfer.DariRekening == transfer.KeRekening {
+			return nil, errors.New("rekening asal dan tujuan tidak boleh sama")
+		}
+		// Rekening nonaktif masih boleh mengosongkan sisa saldonya, tetapi tidak boleh menerima transfer
+		var dari int64
+		if err := tx.Model(&models.Rekening{}).Where("id_rekening = ?", transfer.DariRekening).Count(&dari).Error; err != nil {
+			return nil, err
+		}
+		if dari == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrRekeningTidakValid, transfer.DariRekening)
+		}
+		if _, err := tentukanRekening(tx, &transfer.KeRekening); err != nil {
+			return nil, err
+		}
+		if err := cekSaldoRekening(tx, &transfer.DariRekening, transfer.Nominal); err != nil {
+			return nil, err
+		}
+		if err := tx.Create(transfer).Error; err != nil {
+			return nil, err
+		}
+		// Transfer tidak mengubah rekap, jadi periodenya tidak perlu dihitung ulang
+		_, err := jurnal.SinkronTransfer(*transfer, adminID)
+		return nil, err
+	})
+	return err
+}
+
+// DeleteTransfer membatalkan transfer antar rekening dan membalik jurnalnya
+func (s *KeuanganService) DeleteTransfer(id, adminID string) error {
+	_, err := s.transaksi(TargetTransferRekening, id, adminID, "Hapus transfer antar rekening", func(tx *gorm.DB, jurnal *JurnalService) ([]string, error) {
+		var transfer models.TransferRekening
+		if err := tx.Where("id_transfer = ?", id).First(&transfer).Error; err != nil {
+			return nil, err
+		}
+		// Membatalkan transfer sama dengan mengeluarkan uang dari rekening tujuan
+		if err := cekSaldoRekening(tx, &transfer.KeRekening, transfer.Nominal); err != nil {
+			return nil, err
+		}
+		if _, err := jurnal.BatalkanSumber(TargetTransferRekening, id, adminID); err != nil {
+			return nil, err
+		}
+		return nil, tx.Delete(&transfer).Error
+	})
+	return err
+}
+
+// BuatOpname membandingkan hasil hitung fisik rekening dengan saldo sistem pada tanggal tersebut
+func BuatOpname(db *gorm.DB, opname *models.OpnameKas) error {
+	var rekening models.Rekening
+	if err := db.Where("id_rekening = ?", opname.IDRekening).First(&rekening).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", ErrRekeningTidakValid, opname.IDRekening)
+		}
+		return err
+	}
+	saldo, err := SaldoRekening(db, opname.Tanggal)
+	if err != nil {
+		return err
+	}
+	if opname.IDOpname == "" {
+		opname.IDOpname = uuid.New().String()
+	}
+	opname.SaldoSistem = saldo[opname.IDRekening]
+	opname.Selisih = opname.SaldoFisik - opname.SaldoSistem
+	return db.Create(opname).Error
+}
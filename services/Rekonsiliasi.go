@@ -0,0 +1,653 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrMutasiSudahDikonfirmasi = errors.New("mutasi sudah dikonfirmasi")
+	ErrTanpaUsulan             = errors.New("mutasi belum memiliki usulan pasangan, pilih tipe dan id_referensi")
+)
+
+// Skor minimal agar kandidat diusulkan otomatis. Kecocokan nominal saja belum cukup,
+// harus didukung nama pembayar atau kata kunci di keterangan transfer.
+const skorMinimalUsulan = 50
+
+// Toleransi selisih tanggal transfer dengan waktu catat donasi
+const toleransiHariDonasi = 3
+
+// Lama QRIS yang sudah kedaluwarsa masih dipasangkan dengan transfer; yang lebih lama diabaikan
+// agar permintaan tak terbayar tidak ikut dimuat pada setiap impor
+const toleransiHariQRIS = 30
+
+// Skor kandidat dengan referensi QRIS dan nominal yang sama persis; dikonfirmasi otomatis saat impor
+const skorReferensiQRIS = 100
+
+var kataKunciSyahriah = []string{"SYAHRIAH", "SYAHRIYAH", "SYARIAH", "SPP", "IURAN", "TPQ", "TPA"}
+var kataKunciDonasi = []string{"DONASI", "INFAQ", "INFAK", "SEDEKAH", "SODAQOH", "SHODAQOH", "WAKAF", "ZAKAT"}
+
+// KandidatCocok adalah calon pasangan untuk satu mutasi kredit
+type KandidatCocok struct {
+	Tipe        string  `json:"tipe"`
+	IDReferensi string  `json:"id_referensi,omitempty"`
+	Label       string  `json:"label"`
+	Nominal     float64 `json:"nominal"`
+	Skor        int     `json:"skor"`
+	Alasan      string  `json:"alasan"`
+}
+
+// PilihanCocok adalah pasangan yang dipilih admin saat konfirmasi. Kosong = pakai usulan sistem.
+type PilihanCocok struct {
+	Tipe        string `json:"tipe"`
+	IDReferensi string `json:"id_referensi"`
+	NamaDonatur string `json:"nama_donatur"` // untuk donasi_baru
+}
+
+// RekonsiliasiService mengimpor mutasi rekening, mencocokkan uang masuk dengan tagihan syahriah
+// atau donasi, dan mencatat pembayarannya setelah dikonfirmasi admin
+type RekonsiliasiService struct {
+	db *gorm.DB
+}
+
+func NewRekonsiliasiService(db *gorm.DB) *RekonsiliasiService {
+	return &RekonsiliasiService{db: db}
+}
+
+// tagihanTerbuka adalah tagihan syahriah belum lunas beserta nama santri dan walinya
+type tagihanTerbuka struct {
+	IDSyahriah string
+	IDSantri   string
+	Bulan      string
+	Nominal    float64
+	Terbayar   float64
+	NamaSantri string
+	NamaWali   string
+}
+
+// dataCocok memuat data pembanding sekali untuk seluruh baris satu file
+type dataCocok struct {
+	tagihan      []tagihanTerbuka
+	sisaSantri   map[string]float64
+	jumlahSantri map[string]int
+	donasi       []models.Donasi
+	qris         []models.PermintaanQRIS
+}
+
+func (s *RekonsiliasiService) muatDataCocok(dari, sampai time.Time) (*dataCocok, error) {
+	data := &dataCocok{sisaSantri: map[string]float64{}, jumlahSantri: map[string]int{}}
+	err := s.db.Table("syahriah").
+		Select("syahriah.id_syahriah, syahriah.id_santri, syahriah.bulan, syahriah.nominal, syahriah.terbayar, santri.nama_lengkap AS nama_santri, users.nama_lengkap AS nama_wali").
+		Joins("JOIN santri ON santri.id_santri = syahriah.id_santri").
+		Joins("LEFT JOIN users ON users.id_user = santri.id_wali").
+		Where("syahriah.status <> ?", models.StatusLunas).
+		Order("syahriah.bulan ASC").
+		Scan(&data.tagihan).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range data.tagihan {
+		data.sisaSantri[t.IDSantri] += t.Nominal - t.Terbayar
+		data.jumlahSantri[t.IDSantri]++
+	}
+
+	// Donasi di sekitar rentang tanggal file yang belum dipasangkan dengan mutasi lain
+	err = s.db.Where("waktu_catat >= ? AND waktu_catat < ?", dari.AddDate(0, 0, -toleransiHariDonasi), sampai.AddDate(0, 0, toleransiHariDonasi+1)).
+		Where("id_donasi NOT IN (?)", s.db.Model(&models.MutasiBank{}).Select("id_referensi").
+			Where("tipe_cocok IN ? AND status = ? AND id_referensi IS NOT NULL", []string{models.CocokDonasi, models.CocokDonasiBaru}, models.MutasiDikonfirmasi)).
+		Where("id_donasi NOT IN (?)", s.db.Model(&models.PermintaanQRIS{}).Select("id_donasi").Where("id_donasi IS NOT NULL")).
+		Find(&data.donasi).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// QRIS dinamis yang belum dibayar, diterbitkan sebelum transfer terakhir di file dan belum
+	// lama kedaluwarsa saat transfer pertama
+	err = s.db.Where("status = ? AND waktu_buat < ? AND kedaluwarsa_pada >= ?",
+		models.QRISMenunggu, sampai.AddDate(0, 0, 1), dari.AddDate(0, 0, -toleransiHariQRIS)).
+		Find(&data.qris).Error
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// rekeningMutasiBank menentukan rekening asal file mutasi. Jika admin tidak memilih dan hanya
+// ada satu rekening bank aktif, rekening itu yang dipakai; selain itu rekening utama.
+func rekeningMutasiBank(db *gorm.DB, idRekening *string) (*string, error) {
+	if idRekening == nil || *idRekening == "" {
+		var bank []string
+		if err := db.Model(&models.Rekening{}).Where("jenis = ? AND aktif = ?", models.RekeningBank, true).Pluck("id_rekening", &bank).Error; err != nil {
+			return nil, err
+		}
+		if len(bank) == 1 {
+			return &bank[0], nil
+		}
+	}
+	return tentukanRekening(db, idRekening)
+}
+
+// Impor menyimpan baris kredit dari file mutasi, melewati baris yang sudah pernah diimpor,
+// lalu mengusulkan pasangan untuk setiap baris baru
+func (s *RekonsiliasiService) Impor(namaFile string, hasil *HasilBacaMutasi, idRekening *string, adminID string) (*models.ImporMutasiBank, []models.MutasiBank, error) {
+	idRekening, err := rekeningMutasiBank(s.db, idRekening)
+	if err != nil {
+		return nil, nil, err
+	}
+	impor := &models.ImporMutasiBank{
+		IDImpor:     uuid.New().String(),
+		NamaFile:    namaFile,
+		Format:      hasil.Format,
+		JumlahBaris: hasil.JumlahBaris,
+		IDRekening:  idRekening,
+		DiimporOleh: adminID,
+		WaktuImpor:  time.Now(),
+	}
+
+	var baru []models.MutasiBank
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var sidikJari []string
+		for _, b := range hasil.Kredit {
+			sidikJari = append(sidikJari, b.SidikJari)
+		}
+		sudahAda := map[string]bool{}
+		if len(sidikJari) > 0 {
+			var lama []string
+			if err := tx.Model(&models.MutasiBank{}).Where("sidik_jari IN ?", sidikJari).Pluck("sidik_jari", &lama).Error; err != nil {
+				return err
+			}
+			for _, sj := range lama {
+				sudahAda[sj] = true
+			}
+		}
+
+		for _, b := range hasil.Kredit {
+			impor.JumlahKredit++
+			if sudahAda[b.SidikJari] {
+				impor.JumlahDuplikat++
+				continue
+			}
+			baru = append(baru, models.MutasiBank{
+				IDMutasi:   uuid.New().String(),
+				IDImpor:    impor.IDImpor,
+				Baris:      b.Baris,
+				Tanggal:    b.Tanggal,
+				Keterangan: b.Keterangan,
+				Nominal:    b.Nominal,
+				SidikJari:  b.SidikJari,
+				Status:     models.MutasiBelumCocok,
+			})
+		}
+
+		if len(baru) > 0 {
+			dari, sampai := baru[0].Tanggal, baru[0].Tanggal
+			for _, m := range baru {
+				if m.Tanggal.Before(dari) {
+					dari = m.Tanggal
+				}
+				if m.Tanggal.After(sampai) {
+					sampai = m.Tanggal
+				}
+			}
+			data, err := NewRekonsiliasiService(tx).muatDataCocok(dari, sampai)
+			if err != nil {
+				return err
+			}
+			impor.JumlahCocok = usulkanPasangan(baru, data)
+		}
+
+		if err := tx.Omit("Admin").Create(impor).Error; err != nil {
+			return err
+		}
+		if len(baru) > 0 {
+			if err := tx.CreateInBatches(baru, 200).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Referensi QRIS dengan nominal yang sama tidak perlu menunggu admin
+	for i := range baru {
+		if baru[i].Status != models.MutasiDiusulkan || baru[i].TipeCocok != models.CocokQRIS || baru[i].Skor < skorReferensiQRIS {
+			continue
+		}
+		if mutasi, err := s.Konfirmasi(baru[i].IDMutasi, PilihanCocok{}, adminID); err == nil {
+			baru[i] = *mutasi
+		}
+	}
+	return impor, baru, nil
+}
+
+// usulkanPasangan memberi usulan terbaik untuk setiap mutasi. Tagihan atau donasi yang sudah
+// diusulkan untuk satu baris tidak diusulkan lagi untuk baris lain dalam file yang sama.
+func usulkanPasangan(mutasi []models.MutasiBank, data *dataCocok) int {
+	terpakai := map[string]bool{}
+	jumlah := 0
+	for i := range mutasi {
+		for _, k := range data.kandidat(mutasi[i]) {
+			if k.Skor < skorMinimalUsulan {
+				break
+			}
+			if k.IDReferensi != "" && terpakai[k.Tipe+k.IDReferensi] {
+				continue
+			}
+			ref := k.IDReferensi
+			mutasi[i].Status = models.MutasiDiusulkan
+			mutasi[i].TipeCocok = k.Tipe
+			if ref != "" {
+				mutasi[i].IDReferensi = &ref
+				terpakai[k.Tipe+ref] = true
+			}
+			mutasi[i].Skor = k.Skor
+			mutasi[i].AlasanCocok = k.Alasan
+			jumlah++
+			break
+		}
+	}
+	return jumlah
+}
+
+// Kandidat mengembalikan seluruh calon pasangan untuk satu mutasi, skor tertinggi lebih dulu
+func (s *RekonsiliasiService) Kandidat(mutasi models.MutasiBank) ([]KandidatCocok, error) {
+	data, err := s.muatDataCocok(mutasi.Tanggal, mutasi.Tanggal)
+	if err != nil {
+		return nil, err
+	}
+	return data.kandidat(mutasi), nil
+}
+
+func (d *dataCocok) kandidat(m models.MutasiBank) []KandidatCocok {
+	kata := kataKeterangan(m.Keterangan)
+	adaKataSyahriah := mengandungKata(kata, kataKunciSyahriah)
+	adaKataDonasi := mengandungKata(kata, kataKunciDonasi)
+	bulanTransfer := m.Tanggal.Format("2006-01")
+
+	var hasil []KandidatCocok
+	for _, q := range d.qris {
+		if !kata[q.Referensi] {
+			continue
+		}
+		label := "QRIS donasi " + q.NamaDonatur
+		if q.Tujuan == models.QRISUntukSyahriah {
+			label = "QRIS syahriah"
+			for _, t := range d.tagihan {
+				if q.IDSyahriah != nil && t.IDSyahriah == *q.IDSyahriah {
+					label = fmt.Sprintf("QRIS syahriah %s - %s", t.Bulan, t.NamaSantri)
+				}
+			}
+		}
+		skor, alasan := skorReferensiQRIS, "referensi QRIS "+q.Referensi+" dan nominal cocok"
+		if math.Abs(q.Nominal-m.Nominal) >= 0.5 {
+			skor, alasan = 30, fmt.Sprintf("referensi QRIS %s cocok tetapi nominal berbeda (Rp %.0f)", q.Referensi, q.Nominal)
+		}
+		hasil = append(hasil, KandidatCocok{
+			Tipe:        models.CocokQRIS,
+			IDReferensi: q.IDPermintaan,
+			Label:       label,
+			Nominal:     q.Nominal,
+			Skor:        skor,
+			Alasan:      alasan,
+		})
+	}
+
+	santriDiperiksa := map[string]bool{}
+	for _, t := range d.tagihan {
+		skorNama, alasanNama := skorNamaPembayar(kata, t.NamaSantri, t.NamaWali)
+
+		// Satu tagihan dengan sisa sama persis
+		if sisa := t.Nominal - t.Terbayar; math.Abs(sisa-m.Nominal) < 0.5 {
+			skor := 40 + skorNama
+			alasan := []string{"nominal sama dengan sisa tagihan " + t.Bulan}
+			if alasanNama != "" {
+				alasan = append(alasan, alasanNama)
+			}
+			if t.Bulan <= bulanTransfer {
+				skor += 5
+				// Tagihan bulan berjalan atau bulan sebelumnya paling mungkin dibayar
+				if selisihBulan(t.Bulan, bulanTransfer) <= 1 {
+					skor += 5
+				}
+			}
+			if adaKataSyahriah {
+				skor += 10
+				alasan = append(alasan, "keterangan menyebut syahriah")
+			}
+			hasil = append(hasil, KandidatCocok{
+				Tipe:        models.CocokSyahriah,
+				IDReferensi: t.IDSyahriah,
+				Label:       fmt.Sprintf("Syahriah %s - %s", t.Bulan, t.NamaSantri),
+				Nominal:     sisa,
+				Skor:        skor,
+				Alasan:      strings.Join(alasan, ", "),
+			})
+		}
+
+		// Beberapa bulan sekaligus: nominal sama dengan total sisa tagihan santri
+		if !santriDiperiksa[t.IDSantri] && d.jumlahSantri[t.IDSantri] > 1 && math.Abs(d.sisaSantri[t.IDSantri]-m.Nominal) < 0.5 {
+			santriDiperiksa[t.IDSantri] = true
+			skor := 35 + skorNama
+			alasan := []string{fmt.Sprintf("nominal sama dengan total %d tagihan belum lunas", d.jumlahSantri[t.IDSantri])}
+			if alasanNama != "" {
+				alasan = append(alasan, alasanNama)
+			}
+			if adaKataSyahriah {
+				skor += 10
+				alasan = append(alasan, "keterangan menyebut syahriah")
+			}
+			hasil = append(hasil, KandidatCocok{
+				Tipe:        models.CocokSyahriahSantri,
+				IDReferensi: t.IDSantri,
+				Label:       fmt.Sprintf("Seluruh tunggakan %s (%d bulan)", t.NamaSantri, d.jumlahSantri[t.IDSantri]),
+				Nominal:     d.sisaSantri[t.IDSantri],
+				Skor:        skor,
+				Alasan:      strings.Join(alasan, ", "),
+			})
+		}
+	}
+
+	for _, don := range d.donasi {
+		if math.Abs(don.Nominal-m.Nominal) >= 0.5 {
+			continue
+		}
+		selisih := math.Abs(don.WaktuCatat.Sub(m.Tanggal).Hours() / 24)
+		if selisih > toleransiHariDonasi+1 {
+			continue
+		}
+		skorNama, alasanNama := skorNamaPembayar(kata, don.NamaDonatur)
+		skor := 40 + skorNama
+		alasan := []string{"nominal sama dengan donasi tercatat"}
+		if alasanNama != "" {
+			alasan = append(alasan, alasanNama)
+		}
+		if don.WaktuCatat.Format("2006-01-02") == m.Tanggal.Format("2006-01-02") {
+			skor += 10
+			alasan = append(alasan, "tanggal sama")
+		}
+		if adaKataDonasi {
+			skor += 10
+			alasan = append(alasan, "keterangan menyebut donasi")
+		}
+		hasil = append(hasil, KandidatCocok{
+			Tipe:        models.CocokDonasi,
+			IDReferensi: don.IDDonasi,
+			Label:       fmt.Sprintf("Donasi %s %s", don.NamaDonatur, don.WaktuCatat.Format("2006-01-02")),
+			Nominal:     don.Nominal,
+			Skor:        skor,
+			Alasan:      strings.Join(alasan, ", "),
+		})
+	}
+
+	// Transfer berketerangan donasi tanpa catatan donasi diusulkan sebagai donasi baru
+	if adaKataDonasi {
+		hasil = append(hasil, KandidatCocok{
+			Tipe:    models.CocokDonasiBaru,
+			Label:   "Catat sebagai donasi baru",
+			Nominal: m.Nominal,
+			Skor:    skorMinimalUsulan,
+			Alasan:  "keterangan menyebut donasi",
+		})
+	}
+
+	sort.SliceStable(hasil, func(i, j int) bool { return hasil[i].Skor > hasil[j].Skor })
+	return hasil
+}
+
+// kataKeterangan memecah keterangan transfer menjadi kata huruf besar tanpa tanda baca
+func kataKeterangan(s string) map[string]bool {
+	kata := map[string]bool{}
+	for _, k := range strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9')
+	}) {
+		kata[k] = true
+	}
+	return kata
+}
+
+func mengandungKata(kata map[string]bool, daftar []string) bool {
+	for _, k := range daftar {
+		if kata[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// skorNamaPembayar memberi skor 0-50 sesuai banyaknya kata nama (minimal 3 huruf) yang muncul
+// di keterangan transfer, diambil dari nama yang paling cocok
+func skorNamaPembayar(kata map[string]bool, nama ...string) (int, string) {
+	terbaik, alasan := 0, ""
+	for _, n := range nama {
+		var bagian []string
+		for _, k := range strings.Fields(strings.ToUpper(n)) {
+			k = strings.Trim(k, ".,'")
+			if len(k) >= 3 {
+				bagian = append(bagian, k)
+			}
+		}
+		if len(bagian) == 0 {
+			continue
+		}
+		cocok := 0
+		for _, k := range bagian {
+			if kata[k] {
+				cocok++
+			}
+		}
+		if skor := 50 * cocok / len(bagian); skor > terbaik {
+			terbaik, alasan = skor, "nama "+n+" ada di keterangan"
+		}
+	}
+	return terbaik, alasan
+}
+
+func selisihBulan(dari, sampai string) int {
+	a, err1 := time.Parse("2006-01", dari)
+	b, err2 := time.Parse("2006-01", sampai)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return (b.Year()-a.Year())*12 + int(b.Month()) - int(a.Month())
+}
+
+// Konfirmasi mencatat pembayaran untuk satu mutasi sesuai pilihan admin (atau usulan sistem)
+// dan menandai mutasi dikonfirmasi. Pencatatan memakai KeuanganService sehingga jurnal,
+// kwitansi dan RekapSaldo ikut diperbarui dalam transaksi yang sama.
+func (s *RekonsiliasiService) Konfirmasi(idMutasi string, pilihan PilihanCocok, adminID string) (*models.MutasiBank, error) {
+	var mutasi models.MutasiBank
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id_mutasi = ?", idMutasi).First(&mutasi).Error; err != nil {
+			return err
+		}
+		if mutasi.Status == models.MutasiDikonfirmasi {
+			return ErrMutasiSudahDikonfirmasi
+		}
+
+		// Mutasi yang pernah diabaikan tetap bisa dikonfirmasi dengan pilihan admin
+		tipe, ref := pilihan.Tipe, pilihan.IDReferensi
+		if tipe == "" {
+			if mutasi.Status != models.MutasiDiusulkan {
+				return ErrTanpaUsulan
+			}
+			tipe = mutasi.TipeCocok
+			if mutasi.IDReferensi != nil {
+				ref = *mutasi.IDReferensi
+			}
+		}
+		if tipe != models.CocokDonasiBaru && ref == "" {
+			return errors.New("id_referensi wajib diisi")
+		}
+
+		// Penerimaan dicatat ke rekening asal file mutasi
+		var idRekening *string
+		if err := tx.Model(&models.ImporMutasiBank{}).Where("id_impor = ?", mutasi.IDImpor).Select("id_rekening").Scan(&idRekening).Error; err != nil {
+			return err
+		}
+
+		keuangan := NewKeuanganService(tx)
+		keterangan := "Transfer bank " + mutasi.Tanggal.Format("02/01/2006")
+		if mutasi.Keterangan != "" {
+			keterangan += ": " + mutasi.Keterangan
+		}
+		pembayaran := models.PembayaranSyahriah{
+			Nominal:      mutasi.Nominal,
+			TanggalBayar: mutasi.Tanggal,
+			Metode:       models.MetodeTransfer,
+			Keterangan:   keterangan,
+			IDMutasi:     &mutasi.IDMutasi,
+			IDRekening:   idRekening,
+		}
+
+		switch tipe {
+		case models.CocokSyahriah:
+			pembayaran.IDSyahriah = ref
+			if _, err := keuangan.TambahPembayaran(&pembayaran, adminID); err != nil {
+				return err
+			}
+		case models.CocokSyahriahSantri:
+			if _, _, err := keuangan.BayarTagihanSantri(ref, pembayaran, adminID); err != nil {
+				return err
+			}
+		case models.CocokDonasi:
+			var donasi models.Donasi
+			if err := tx.Where("id_donasi = ?", ref).First(&donasi).Error; err != nil {
+				return fmt.Errorf("donasi tidak ditemukan: %w", err)
+			}
+			if math.Abs(donasi.Nominal-mutasi.Nominal) >= 0.5 {
+				return fmt.Errorf("nominal donasi (Rp %.0f) berbeda dengan nominal transfer (Rp %.0f)", donasi.Nominal, mutasi.Nominal)
+			}
+			var dipakai int64
+			if err := tx.Model(&models.MutasiBank{}).
+				Where("tipe_cocok IN ? AND id_referensi = ? AND status = ?", []string{models.CocokDonasi, models.CocokDonasiBaru}, ref, models.MutasiDikonfirmasi).
+				Count(&dipakai).Error; err != nil {
+				return err
+			}
+			if dipakai == 0 {
+				if err := tx.Model(&models.PermintaanQRIS{}).Where("id_donasi = ?", ref).Count(&dipakai).Error; err != nil {
+					return err
+				}
+			}
+			if dipakai > 0 {
+				return errors.New("donasi sudah dipasangkan dengan mutasi lain")
+			}
+		case models.CocokDonasiBaru:
+			namaDonatur := pilihan.NamaDonatur
+			if namaDonatur == "" {
+				namaDonatur = "Hamba Allah"
+			}
+			donasi := models.Donasi{
+				IDDonasi:    uuid.New().String(),
+				NamaDonatur: namaDonatur,
+				Nominal:     mutasi.Nominal,
+				IDRekening:  idRekening,
+				DicatatOleh: adminID,
+				WaktuCatat:  mutasi.Tanggal,
+			}
+			if _, err := keuangan.CreateDonasi(&donasi, adminID); err != nil {
+				return err
+			}
+			ref = donasi.IDDonasi
+		case models.CocokQRIS:
+			if err := konfirmasiQRIS(tx, keuangan, ref, mutasi, pembayaran, adminID); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("tipe pasangan tidak valid: %s", tipe)
+		}
+
+		sekarang := time.Now()
+		mutasi.Status = models.MutasiDikonfirmasi
+		mutasi.TipeCocok = tipe
+		mutasi.IDReferensi = &ref
+		mutasi.DikonfirmasiOleh = &adminID
+		mutasi.WaktuKonfirmasi = &sekarang
+		if pilihan.Tipe != "" {
+			mutasi.AlasanCocok = "dipilih admin"
+		}
+		return tx.Save(&mutasi).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &mutasi, nil
+}
+
+// Abaikan menandai mutasi sebagai bukan penerimaan syahriah/donasi (misalnya bunga bank atau transfer antar rekening)
+func (s *RekonsiliasiService) Abaikan(idMutasi, adminID string) (*models.MutasiBank, error) {
+	var mutasi models.MutasiBank
+	if err := s.db.Where("id_mutasi = ?", idMutasi).First(&mutasi).Error; err != nil {
+		return nil, err
+	}
+	if mutasi.Status == models.MutasiDikonfirmasi {
+		return nil, ErrMutasiSudahDikonfirmasi
+	}
+	sekarang := time.Now()
+	mutasi.Status = models.MutasiDiabaikan
+	mutasi.DikonfirmasiOleh = &adminID
+	mutasi.WaktuKonfirmasi = &sekarang
+	if err := s.db.Save(&mutasi).Error; err != nil {
+		return nil, err
+	}
+	return &mutasi, nil
+}
+
+// konfirmasiQRIS mencatat pembayaran untuk QRIS dinamis yang referensinya muncul di mutasi:
+// pembayaran syahriah untuk tagihan asalnya, atau donasi baru atas nama donatur yang meminta QRIS
+func konfirmasiQRIS(tx *gorm.DB, keuangan *KeuanganService, idPermintaan string, mutasi models.MutasiBank, pembayaran models.PembayaranSyahriah, adminID string) error {
+	var permintaan models.PermintaanQRIS
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id_permintaan = ?", idPermintaan).First(&permintaan).Error; err != nil {
+		return fmt.Errorf("permintaan QRIS tidak ditemukan: %w", err)
+	}
+	if permintaan.Status == models.QRISDibayar {
+		return errors.New("QRIS " + permintaan.Referensi + " sudah dibayar")
+	}
+	if math.Abs(permintaan.Nominal-mutasi.Nominal) >= 0.5 {
+		return fmt.Errorf("nominal QRIS (Rp %.0f) berbeda dengan nominal transfer (Rp %.0f)", permintaan.Nominal, mutasi.Nominal)
+	}
+
+	perubahan := map[string]interface{}{"status": models.QRISDibayar, "id_mutasi": mutasi.IDMutasi}
+	switch permintaan.Tujuan {
+	case models.QRISUntukSyahriah:
+		pembayaran.IDSyahriah = *permintaan.IDSyahriah
+		pembayaran.Metode = models.MetodeQRIS
+		pembayaran.Keterangan = "QRIS " + permintaan.Referensi + " " + mutasi.Tanggal.Format("02/01/2006")
+		if _, err := keuangan.TambahPembayaran(&pembayaran, adminID); err != nil {
+			return err
+		}
+	case models.QRISUntukDonasi:
+		donasi := models.Donasi{
+			IDDonasi:    uuid.New().String(),
+			NamaDonatur: permintaan.NamaDonatur,
+			NoTelp:      permintaan.NoTelp,
+			IDKampanye:  permintaan.IDKampanye,
+			Nominal:     mutasi.Nominal,
+			IDRekening:  pembayaran.IDRekening,
+			DicatatOleh: adminID,
+			WaktuCatat:  mutasi.Tanggal,
+		}
+		// Ditautkan lebih dulu agar kwitansi donasi tercetak dengan metode QRIS
+		if err := tx.Model(&permintaan).Update("id_donasi", donasi.IDDonasi).Error; err != nil {
+			return err
+		}
+		if _, err := keuangan.CreateDonasi(&donasi, adminID); err != nil {
+			return err
+		}
+		perubahan["id_donasi"] = donasi.IDDonasi
+	default:
+		return fmt.Errorf("tujuan QRIS tidak valid: %s", permintaan.Tujuan)
+	}
+	return tx.Model(&permintaan).Updates(perubahan).Error
+}
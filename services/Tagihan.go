@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrTidakAdaSantriAktif dikembalikan jika tagihan dibuat saat belum ada santri aktif
+var ErrTidakAdaSantriAktif = errors.New("tidak ada data santri aktif yang tersedia")
+
+// ErrSyahriahSudahAda dikembalikan jika santri sudah memiliki tagihan di bulan yang sama
+var ErrSyahriahSudahAda = errors.New("syahriah untuk bulan ini sudah ada")
+
+// indeksSyahriahUnik menjamin satu tagihan per santri per bulan walaupun pembuatan tagihan
+// berjalan bersamaan dari beberapa proses
+const indeksSyahriahUnik = "idx_syahriah_santri_bulan"
+
+// Batas pengulangan GenerateBulan saat tagihan yang sama dibuat proses lain di tengah jalan
+const maksPercobaanGenerate = 3
+
+// HasilGenerateTagihan adalah ringkasan pembuatan tagihan satu bulan
+type HasilGenerateTagihan struct {
+	Bulan            string `json:"bulan"`
+	Dibuat           int    `json:"created"`
+	Dilewati         int    `json:"skipped"`
+	TotalSantriAktif int    `json:"total_santri_aktif"`
+}
+
+// TagihanService membuat tagihan syahriah bulanan untuk seluruh santri aktif
+type TagihanService struct {
+	db *gorm.DB
+}
+
+func NewTagihanService(db *gorm.DB) *TagihanService {
+	return &TagihanService{db: db}
+}
+
+// GenerateBulan membuat tagihan untuk santri aktif yang belum memiliki tagihan di bulan tersebut,
+// sehingga aman dipanggil berulang. nominalManual 0 berarti nominal dihitung per santri dari tarif.
+// Jika proses lain lebih dulu membuat tagihan yang sama, transaksinya dibatalkan lalu diulang
+// sehingga tagihan tersebut terhitung sebagai dilewati.
+func (s *TagihanService) GenerateBulan(bulan string, nominalManual float64, status models.StatusSyahriah, adminID string) (*HasilGenerateTagihan, error) {
+	if _, err := time.Parse("2006-01", bulan); err != nil {
+		return nil, errors.New("format bulan tidak valid. Gunakan format YYYY-MM")
+	}
+
+	for percobaan := 1; ; percobaan++ {
+		hasil, err := s.generateBulan(bulan, nominalManual, status, adminID)
+		if errors.Is(err, ErrSyahriahSudahAda) && percobaan < maksPercobaanGenerate {
+			continue
+		}
+		return hasil, err
+	}
+}
+
+func (s *TagihanService) generateBulan(bulan string, nominalManual float64, status models.StatusSyahriah, adminID string) (*HasilGenerateTagihan, error) {
+	var santriList []models.Santri
+	if err := s.db.Where("status = ?", models.StatusAktifSantri).Find(&santriList).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil data santri aktif: %v", err)
+	}
+	if len(santriList) == 0 {
+		return nil, ErrTidakAdaSantriAktif
+	}
+
+	// Santri aktif yang sudah memiliki syahriah di bulan ini dilewati
+	var existingSantri []string
+	if err := s.db.Model(&models.Syahriah{}).
+		Joins("JOIN santri ON syahriah.id_santri = santri.id_santri").
+		Where("syahriah.bulan = ? AND santri.status = ?", bulan, models.StatusAktifSantri).
+		Pluck("syahriah.id_santri", &existingSantri).Error; err != nil {
+		return nil, fmt.Errorf("gagal memeriksa data syahriah yang sudah ada: %v", err)
+	}
+	existingMap := make(map[string]bool)
+	for _, id := range existingSantri {
+		existingMap[id] = true
+	}
+
+	// Tarif dimuat sekali untuk seluruh santri, kecuali nominal diisi manual
+	var tarifService *TarifService
+	if nominalManual <= 0 {
+		var err error
+		tarifService, err = NewTarifService(s.db, bulan)
+		if err != nil {
+			return nil, fmt.Errorf("gagal memuat tarif syahriah: %v", err)
+		}
+	}
+
+	var syahriahList []models.Syahriah
+	for _, santri := range santriList {
+		if existingMap[santri.IDSantri] {
+			continue
+		}
+
+		syahriah := models.Syahriah{
+			IDSyahriah:  uuid.New().String(),
+			ID_Santri:   santri.IDSantri,
+			Bulan:       bulan,
+			Status:      status,
+			DicatatOleh: adminID,
+			WaktuCatat:  time.Now(),
+		}
+
+		if tarifService == nil {
+			syahriah.Nominal = nominalManual
+			syahriah.NominalDasar = nominalManual
+			syahriah.KeteranganTarif = "Nominal manual"
+		} else {
+			rincian, err := tarifService.Hitung(santri)
+			if err != nil {
+				return nil, fmt.Errorf("gagal menghitung tarif %s: %w", santri.NamaLengkap, err)
+			}
+			rincian.TerapkanKe(&syahriah)
+		}
+
+		syahriahList = append(syahriahList, syahriah)
+	}
+
+	hasil := &HasilGenerateTagihan{
+		Bulan:            bulan,
+		Dibuat:           len(syahriahList),
+		Dilewati:         len(santriList) - len(syahriahList),
+		TotalSantriAktif: len(santriList),
+	}
+	if len(syahriahList) == 0 {
+		return hasil, nil
+	}
+
+	// Tagihan bernominal 0 otomatis lunas, yang dibuat lunas ikut dicatat pembayarannya
+	if _, err := NewKeuanganService(s.db).BatchCreateSyahriah(syahriahList, adminID); err != nil {
+		return nil, err
+	}
+	return hasil, nil
+}
+
+// PastikanIndeksSyahriah membuat indeks unik syahriah(id_santri, bulan) jika belum ada. Indeks
+// dibuat terpisah dari AutoMigrate agar data ganda lama tidak menggagalkan migrasi tabel lain;
+// selama masih ada tagihan ganda, indeks tidak dibuat dan jumlah pasangannya dikembalikan.
+func PastikanIndeksSyahriah(db *gorm.DB) (int64, error) {
+	if db.Migrator().HasIndex(&models.Syahriah{}, indeksSyahriahUnik) {
+		return 0, nil
+	}
+
+	var ganda int64
+	if err := db.Table("(?) AS ganda", db.Model(&models.Syahriah{}).
+		Select("id_santri, bulan").
+		Group("id_santri, bulan").
+		Having("COUNT(*) > 1")).
+		Count(&ganda).Error; err != nil {
+		return 0, err
+	}
+	if ganda > 0 {
+		return ganda, nil
+	}
+	return 0, db.Exec("CREATE UNIQUE INDEX " + indeksSyahriahUnik + " ON syahriah (id_santri, bulan)").Error
+}
+
+// duplikatKunci memeriksa apakah err berasal dari pelanggaran indeks unik MySQL
+func duplikatKunci(err error) bool {
+	var errMySQL *mysql.MySQLError
+	return errors.Is(err, gorm.ErrDuplicatedKey) || (errors.As(err, &errMySQL) && errMySQL.Number == 1062)
+}
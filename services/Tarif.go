@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrTarifBelumDiatur dikembalikan jika tidak ada tarif yang berlaku untuk santri di bulan tersebut
+var ErrTarifBelumDiatur = errors.New("tarif syahriah belum diatur")
+
+// RincianTarif adalah hasil perhitungan nominal syahriah seorang santri untuk satu bulan
+type RincianTarif struct {
+	IDTarif      *string `json:"id_tarif"`
+	NamaTarif    string  `json:"nama_tarif"`
+	NominalDasar float64 `json:"nominal_dasar"`
+	Potongan     float64 `json:"potongan"`
+	Nominal      float64 `json:"nominal"`
+	Keterangan   string  `json:"keterangan"`
+}
+
+// TarifService menghitung nominal syahriah dari tarif yang berlaku dan keringanan santri.
+// Data tarif, keringanan dan urutan anak dimuat sekali per bulan agar batch tidak query per santri.
+type TarifService struct {
+	bulan      string
+	tarif      []models.TarifSyahriah
+	keringanan map[string][]models.KeringananSyahriah
+	urutanAnak map[string]int
+}
+
+// NewTarifService menyiapkan perhitungan tarif untuk bulan (YYYY-MM)
+func NewTarifService(db *gorm.DB, bulan string) (*TarifService, error) {
+	if _, err := time.Parse("2006-01", bulan); err != nil {
+		return nil, fmt.Errorf("format bulan tidak valid: %s", bulan)
+	}
+
+	s := &TarifService{
+		bulan:      bulan,
+		keringanan: make(map[string][]models.KeringananSyahriah),
+		urutanAnak: make(map[string]int),
+	}
+
+	// Versi terbaru lebih dulu, sehingga versi pertama yang cocok per kunci program/kelas yang berlaku
+	if err := db.Where("berlaku_mulai <= ?", bulan).
+		Order("berlaku_mulai DESC, dibuat_pada DESC").
+		Find(&s.tarif).Error; err != nil {
+		return nil, err
+	}
+
+	var keringanan []models.KeringananSyahriah
+	if err := db.Where("berlaku_mulai <= ? AND (berlaku_sampai IS NULL OR berlaku_sampai = '' OR berlaku_sampai >= ?)", bulan, bulan).
+		Find(&keringanan).Error; err != nil {
+		return nil, err
+	}
+	for _, k := range keringanan {
+		s.keringanan[k.IDSantri] = append(s.keringanan[k.IDSantri], k)
+	}
+
+	// Urutan anak aktif dalam satu keluarga (satu wali) berdasarkan tanggal masuk
+	var santriAktif []models.Santri
+	if err := db.Select("id_santri, id_wali").
+		Where("status = ?", models.StatusAktifSantri).
+		Order("id_wali ASC, tanggal_masuk ASC, id_santri ASC").
+		Find(&santriAktif).Error; err != nil {
+		return nil, err
+	}
+	urutanWali := make(map[string]int)
+	for _, santri := range santriAktif {
+		urutanWali[santri.IDWali]++
+		s.urutanAnak[santri.IDSantri] = urutanWali[santri.IDWali]
+	}
+
+	return s, nil
+}
+
+// tarifBerlaku memilih tarif paling spesifik (program+kelas, lalu kelas, lalu program, lalu umum)
+// dari versi terbaru yang sudah berlaku di bulan ini
+func (s *TarifService) tarifBerlaku(santri models.Santri) *models.TarifSyahriah {
+	var terpilih *models.TarifSyahriah
+	skorTerpilih := -1
+	sudahDilihat := make(map[string]bool)
+
+	for i := range s.tarif {
+		t := &s.tarif[i]
+		kunci := t.Program + "|" + t.Kelas
+		if sudahDilihat[kunci] {
+			continue // versi lama dari kunci yang sama
+		}
+		sudahDilihat[kunci] = true
+
+		if t.Program != "" && t.Program != santri.Program {
+			continue
+		}
+		if t.Kelas != "" && t.Kelas != santri.Kelas {
+			continue
+		}
+
+		skor := 0
+		if t.Kelas != "" {
+			skor += 2
+		}
+		if t.Program != "" {
+			skor++
+		}
+		if skor > skorTerpilih {
+			terpilih = t
+			skorTerpilih = skor
+		}
+	}
+	return terpilih
+}
+
+// Hitung menghitung nominal syahriah santri. Potongan tidak bertumpuk: dipilih potongan
+// saudara atau keringanan khusus yang paling meringankan.
+func (s *TarifService) Hitung(santri models.Santri) (RincianTarif, error) {
+	tarif := s.tarifBerlaku(santri)
+	if tarif == nil {
+		return RincianTarif{}, fmt.Errorf("%w untuk bulan %s", ErrTarifBelumDiatur, s.bulan)
+	}
+
+	idTarif := tarif.IDTarif
+	rincian := RincianTarif{
+		IDTarif:      &idTarif,
+		NamaTarif:    tarif.NamaTarif,
+		NominalDasar: tarif.Nominal,
+		Nominal:      tarif.Nominal,
+		Keterangan:   tarif.NamaTarif,
+	}
+
+	if tarif.PotonganSaudaraPersen > 0 && s.urutanAnak[santri.IDSantri] > 1 {
+		potongan := tarif.Nominal * tarif.PotonganSaudaraPersen / 100
+		rincian.terapkan(potongan, fmt.Sprintf("potongan saudara %.0f%% (anak ke-%d)", tarif.PotonganSaudaraPersen, s.urutanAnak[santri.IDSantri]))
+	}
+
+	for _, k := range s.keringanan[santri.IDSantri] {
+		var potongan float64
+		switch k.TipePotongan {
+		case models.PotonganBebas:
+			potongan = tarif.Nominal
+		case models.PotonganPersen:
+			potongan = tarif.Nominal * k.Nilai / 100
+		case models.PotonganNominal:
+			potongan = k.Nilai
+		}
+		rincian.terapkan(potongan, "keringanan "+string(k.Jenis))
+	}
+
+	return rincian, nil
+}
+
+// terapkan memakai potongan jika lebih besar dari potongan yang sudah ada
+func (r *RincianTarif) terapkan(potongan float64, keterangan string) {
+	potongan = math.Min(math.Max(potongan, 0), r.NominalDasar)
+	if potongan <= r.Potongan {
+		return
+	}
+	r.Potongan = potongan
+	r.Nominal = r.NominalDasar - potongan
+	r.Keterangan = r.NamaTarif + ", " + keterangan
+}
+
+// TerapkanKe mengisi nominal dan rincian tarif ke data syahriah
+func (r RincianTarif) TerapkanKe(syahriah *models.Syahriah) {
+	syahriah.IDTarif = r.IDTarif
+	syahriah.NominalDasar = r.NominalDasar
+	syahriah.Potongan = r.Potongan
+	syahriah.Nominal = r.Nominal
+	syahriah.KeteranganTarif = r.Keterangan
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"tpq_asysyafii/models"
+)
+
+func TestTarifServiceHitung(t *testing.T) {
+	// Urutan sama dengan hasil query NewTarifService: versi terbaru lebih dulu
+	s := &TarifService{
+		bulan: "2025-07",
+		tarif: []models.TarifSyahriah{
+			{IDTarif: "umum-2025", NamaTarif: "Umum 2025", Nominal: 120000, PotonganSaudaraPersen: 50},
+			{IDTarif: "tahfidz-a", NamaTarif: "Tahfidz kelas A", Program: "tahfidz", Kelas: "A", Nominal: 160000},
+			{IDTarif: "kelas-a", NamaTarif: "Kelas A", Kelas: "A", Nominal: 130000},
+			{IDTarif: "tahfidz", NamaTarif: "Tahfidz", Program: "tahfidz", Nominal: 150000},
+			{IDTarif: "umum-2024", NamaTarif: "Umum 2024", Nominal: 100000},
+		},
+		keringanan: map[string][]models.KeringananSyahriah{
+			"yatim":         {{Jenis: models.KeringananYatim, TipePotongan: models.PotonganPersen, Nilai: 25}},
+			"adik-beasiswa": {{Jenis: models.KeringananBeasiswa, TipePotongan: models.PotonganNominal, Nilai: 20000}},
+			"dhuafa":        {{Jenis: models.KeringananDhuafa, TipePotongan: models.PotonganBebas}},
+			"lebih":         {{Jenis: models.KeringananBeasiswa, TipePotongan: models.PotonganNominal, Nilai: 500000}},
+		},
+		urutanAnak: map[string]int{"kakak": 1, "adik": 2, "adik-beasiswa": 2},
+	}
+
+	tests := []struct {
+		nama     string
+		santri   models.Santri
+		idTarif  string
+		potongan float64
+		nominal  float64
+	}{
+		{"tarif umum versi terbaru", models.Santri{IDSantri: "kakak", Program: "reguler", Kelas: "B"}, "umum-2025", 0, 120000},
+		{"tarif program", models.Santri{IDSantri: "s1", Program: "tahfidz", Kelas: "B"}, "tahfidz", 0, 150000},
+		{"kelas lebih spesifik dari program", models.Santri{IDSantri: "s2", Program: "reguler", Kelas: "A"}, "kelas-a", 0, 130000},
+		{"program dan kelas", models.Santri{IDSantri: "s3", Program: "tahfidz", Kelas: "A"}, "tahfidz-a", 0, 160000},
+		{"potongan saudara anak kedua", models.Santri{IDSantri: "adik"}, "umum-2025", 60000, 60000},
+		{"keringanan persen", models.Santri{IDSantri: "yatim"}, "umum-2025", 30000, 90000},
+		{"potongan tidak bertumpuk", models.Santri{IDSantri: "adik-beasiswa"}, "umum-2025", 60000, 60000},
+		{"dibebaskan", models.Santri{IDSantri: "dhuafa"}, "umum-2025", 120000, 0},
+		{"potongan dibatasi nominal dasar", models.Santri{IDSantri: "lebih"}, "umum-2025", 120000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			rincian, err := s.Hitung(tt.santri)
+			if err != nil {
+				t.Fatalf("Hitung() error = %v", err)
+			}
+			if rincian.IDTarif == nil || *rincian.IDTarif != tt.idTarif {
+				t.Errorf("IDTarif = %v, want %s", rincian.IDTarif, tt.idTarif)
+			}
+			if rincian.Potongan != tt.potongan || rincian.Nominal != tt.nominal {
+				t.Errorf("Potongan, Nominal = %.0f, %.0f, want %.0f, %.0f", rincian.Potongan, rincian.Nominal, tt.potongan, tt.nominal)
+			}
+		})
+	}
+}
+
+func TestTarifServiceHitungTanpaTarif(t *testing.T) {
+	s := &TarifService{
+		bulan: "2025-07",
+		tarif: []models.TarifSyahriah{
+			{IDTarif: "tahfidz", NamaTarif: "Tahfidz", Program: "tahfidz", Nominal: 150000},
+		},
+	}
+	if _, err := s.Hitung(models.Santri{IDSantri: "s1", Program: "reguler"}); !errors.Is(err, ErrTarifBelumDiatur) {
+		t.Fatalf("Hitung() error = %v, want ErrTarifBelumDiatur", err)
+	}
+}
+
+func TestRincianTarifTerapkanKe(t *testing.T) {
+	s := &TarifService{
+		bulan:      "2025-07",
+		tarif:      []models.TarifSyahriah{{IDTarif: "umum", NamaTarif: "Tarif Umum", Nominal: 120000, PotonganSaudaraPersen: 50}},
+		keringanan: map[string][]models.KeringananSyahriah{"adik": {{Jenis: models.KeringananYatim, TipePotongan: models.PotonganPersen, Nilai: 25}}},
+		urutanAnak: map[string]int{"adik": 3},
+	}
+	rincian, err := s.Hitung(models.Santri{IDSantri: "adik"})
+	if err != nil {
+		t.Fatalf("Hitung() error = %v", err)
+	}
+
+	// Potongan saudara 50% lebih meringankan dari keringanan yatim 25%, jadi keterangannya yang dipakai
+	var syahriah models.Syahriah
+	rincian.TerapkanKe(&syahriah)
+	if syahriah.IDTarif == nil || *syahriah.IDTarif != "umum" {
+		t.Errorf("IDTarif = %v, want umum", syahriah.IDTarif)
+	}
+	if syahriah.NominalDasar != 120000 || syahriah.Potongan != 60000 || syahriah.Nominal != 60000 {
+		t.Errorf("NominalDasar, Potongan, Nominal = %.0f, %.0f, %.0f, want 120000, 60000, 60000", syahriah.NominalDasar, syahriah.Potongan, syahriah.Nominal)
+	}
+	if want := "Tarif Umum, potongan saudara 50% (anak ke-3)"; syahriah.KeteranganTarif != want {
+		t.Errorf("KeteranganTarif = %q, want %q", syahriah.KeteranganTarif, want)
+	}
+}
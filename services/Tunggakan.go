@@ -0,0 +1,244 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"gorm.io/gorm"
+)
+
+// Kelompok umur tunggakan
+const (
+	Umur1Bulan      = "1_bulan"
+	Umur2Sampai3    = "2_3_bulan"
+	UmurLebih3Bulan = "lebih_3_bulan"
+)
+
+// RincianTunggakan adalah satu tagihan yang belum lunas
+type RincianTunggakan struct {
+	IDSyahriah string  `json:"id_syahriah"`
+	IDSantri   string  `json:"id_santri"`
+	NamaSantri string  `json:"nama_santri"`
+	Bulan      string  `json:"bulan"`
+	Nominal    float64 `json:"nominal"`
+	Terbayar   float64 `json:"terbayar"`
+	Sisa       float64 `json:"sisa"`
+	UmurBulan  int     `json:"umur_bulan"`
+	Kelompok   string  `json:"kelompok"`
+}
+
+// TunggakanWali adalah total tunggakan satu keluarga (wali) per kelompok umur
+type TunggakanWali struct {
+	IDWali             string             `json:"id_wali"`
+	NamaWali           string             `json:"nama_wali"`
+	NoTelp             string             `json:"no_telp"`
+	Alamat             string             `json:"alamat"`
+	JumlahTagihan      int                `json:"jumlah_tagihan"`
+	Umur1Bulan         float64            `json:"umur_1_bulan"`
+	Umur2Sampai3Bulan  float64            `json:"umur_2_3_bulan"`
+	UmurLebih3Bulan    float64            `json:"umur_lebih_3_bulan"`
+	TotalTunggakan     float64            `json:"total_tunggakan"`
+	BulanTertua        string             `json:"bulan_tertua"`
+	PembayaranTerakhir *time.Time         `json:"pembayaran_terakhir"`
+	Rincian            []RincianTunggakan `json:"rincian"`
+}
+
+// RingkasanTunggakan adalah total seluruh keluarga per kelompok umur
+type RingkasanTunggakan struct {
+	Acuan             string  `json:"acuan"`
+	JumlahWali        int     `json:"jumlah_wali"`
+	JumlahTagihan     int     `json:"jumlah_tagihan"`
+	Umur1Bulan        float64 `json:"umur_1_bulan"`
+	Umur2Sampai3Bulan float64 `json:"umur_2_3_bulan"`
+	UmurLebih3Bulan   float64 `json:"umur_lebih_3_bulan"`
+	TotalTunggakan    float64 `json:"total_tunggakan"`
+}
+
+// FilterTunggakan membatasi laporan umur tunggakan
+type FilterTunggakan struct {
+	Acuan    string // bulan acuan YYYY-MM, tagihan setelahnya belum dianggap tunggakan
+	Kelompok string // hanya keluarga yang memiliki tunggakan di kelompok ini
+	Search   string // nama wali
+}
+
+type TunggakanService struct {
+	db *gorm.DB
+}
+
+func NewTunggakanService(db *gorm.DB) *TunggakanService {
+	return &TunggakanService{db: db}
+}
+
+// KelompokUmur memetakan umur tunggakan (dalam bulan) ke kelompoknya.
+// Tagihan bulan acuan berumur 1 bulan.
+func KelompokUmur(umur int) string {
+	switch {
+	case umur <= 1:
+		return Umur1Bulan
+	case umur <= 3:
+		return Umur2Sampai3
+	default:
+		return UmurLebih3Bulan
+	}
+}
+
+// LaporanUmur mengelompokkan tagihan yang belum lunas per wali berdasarkan umurnya,
+// diurutkan dari tunggakan terbesar
+func (s *TunggakanService) LaporanUmur(filter FilterTunggakan) ([]TunggakanWali, RingkasanTunggakan, error) {
+	if filter.Acuan == "" {
+		filter.Acuan = time.Now().Format("2006-01")
+	}
+	acuan, err := time.Parse("2006-01", filter.Acuan)
+	if err != nil {
+		return nil, RingkasanTunggakan{}, fmt.Errorf("format acuan tidak valid. Gunakan format YYYY-MM")
+	}
+	ringkasan := RingkasanTunggakan{Acuan: filter.Acuan}
+
+	var rows []struct {
+		IDSyahriah string
+		IDSantri   string
+		NamaSantri string
+		Bulan      string
+		Nominal    float64
+		Terbayar   float64
+		IDWali     string
+		NamaWali   string
+		NoTelp     string
+	}
+	query := s.db.Table("syahriah").
+		Select("syahriah.id_syahriah, santri.id_santri, santri.nama_lengkap AS nama_santri, syahriah.bulan, syahriah.nominal, syahriah.terbayar, "+
+			"santri.id_wali, users.nama_lengkap AS nama_wali, users.no_telp").
+		Joins("JOIN santri ON santri.id_santri = syahriah.id_santri").
+		Joins("JOIN users ON users.id_user = santri.id_wali").
+		Where("syahriah.status <> ? AND syahriah.bulan <= ?", models.StatusLunas, filter.Acuan)
+	if filter.Search != "" {
+		query = query.Where("users.nama_lengkap LIKE ?", "%"+filter.Search+"%")
+	}
+	if err := query.Order("syahriah.bulan ASC").Scan(&rows).Error; err != nil {
+		return nil, ringkasan, err
+	}
+
+	perWali := make(map[string]*TunggakanWali)
+	var urutan []*TunggakanWali
+	for _, row := range rows {
+		sisa := row.Nominal - row.Terbayar
+		if sisa <= 0 {
+			continue
+		}
+		bulan, err := time.Parse("2006-01", row.Bulan)
+		if err != nil {
+			continue
+		}
+		umur := (acuan.Year()-bulan.Year())*12 + int(acuan.Month()-bulan.Month()) + 1
+
+		wali, ok := perWali[row.IDWali]
+		if !ok {
+			wali = &TunggakanWali{IDWali: row.IDWali, NamaWali: row.NamaWali, NoTelp: row.NoTelp, BulanTertua: row.Bulan}
+			perWali[row.IDWali] = wali
+			urutan = append(urutan, wali)
+		}
+
+		rincian := RincianTunggakan{
+			IDSyahriah: row.IDSyahriah,
+			IDSantri:   row.IDSantri,
+			NamaSantri: row.NamaSantri,
+			Bulan:      row.Bulan,
+			Nominal:    row.Nominal,
+			Terbayar:   row.Terbayar,
+			Sisa:       sisa,
+			UmurBulan:  umur,
+			Kelompok:   KelompokUmur(umur),
+		}
+		switch rincian.Kelompok {
+		case Umur1Bulan:
+			wali.Umur1Bulan += sisa
+		case Umur2Sampai3:
+			wali.Umur2Sampai3Bulan += sisa
+		default:
+			wali.UmurLebih3Bulan += sisa
+		}
+		wali.TotalTunggakan += sisa
+		wali.JumlahTagihan++
+		wali.Rincian = append(wali.Rincian, rincian)
+	}
+
+	if len(urutan) == 0 {
+		return []TunggakanWali{}, ringkasan, nil
+	}
+
+	idWali := make([]string, 0, len(urutan))
+	for _, w := range urutan {
+		idWali = append(idWali, w.IDWali)
+	}
+	if err := s.lengkapiWali(idWali, perWali); err != nil {
+		return nil, ringkasan, err
+	}
+
+	hasil := make([]TunggakanWali, 0, len(urutan))
+	for _, w := range urutan {
+		if !cocokKelompok(*w, filter.Kelompok) {
+			continue
+		}
+		hasil = append(hasil, *w)
+		ringkasan.JumlahWali++
+		ringkasan.JumlahTagihan += w.JumlahTagihan
+		ringkasan.Umur1Bulan += w.Umur1Bulan
+		ringkasan.Umur2Sampai3Bulan += w.Umur2Sampai3Bulan
+		ringkasan.UmurLebih3Bulan += w.UmurLebih3Bulan
+		ringkasan.TotalTunggakan += w.TotalTunggakan
+	}
+	sort.SliceStable(hasil, func(i, j int) bool {
+		return hasil[i].TotalTunggakan > hasil[j].TotalTunggakan
+	})
+	return hasil, ringkasan, nil
+}
+
+// lengkapiWali mengisi tanggal pembayaran terakhir dan alamat keluarga
+func (s *TunggakanService) lengkapiWali(idWali []string, perWali map[string]*TunggakanWali) error {
+	var pembayaran []struct {
+		IDWali   string
+		Terakhir time.Time
+	}
+	if err := s.db.Table("pembayaran_syahriah").
+		Select("santri.id_wali, MAX(pembayaran_syahriah.tanggal_bayar) AS terakhir").
+		Joins("JOIN syahriah ON syahriah.id_syahriah = pembayaran_syahriah.id_syahriah").
+		Joins("JOIN santri ON santri.id_santri = syahriah.id_santri").
+		Where("santri.id_wali IN ?", idWali).
+		Group("santri.id_wali").
+		Scan(&pembayaran).Error; err != nil {
+		return err
+	}
+	for _, p := range pembayaran {
+		terakhir := p.Terakhir
+		perWali[p.IDWali].PembayaranTerakhir = &terakhir
+	}
+
+	var keluarga []models.Keluarga
+	if err := s.db.Where("id_wali IN ?", idWali).Find(&keluarga).Error; err != nil {
+		return err
+	}
+	for _, k := range keluarga {
+		if w := perWali[k.IDWali]; w != nil && w.Alamat == "" {
+			w.Alamat = strings.TrimSpace(k.Alamat)
+		}
+	}
+	return nil
+}
+
+// cocokKelompok memeriksa apakah wali memiliki tunggakan di kelompok umur tertentu
+func cocokKelompok(wali TunggakanWali, kelompok string) bool {
+	switch kelompok {
+	case Umur1Bulan:
+		return wali.Umur1Bulan > 0
+	case Umur2Sampai3:
+		return wali.Umur2Sampai3Bulan > 0
+	case UmurLebih3Bulan:
+		return wali.UmurLebih3Bulan > 0
+	default:
+		return true
+	}
+}
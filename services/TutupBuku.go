@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"tpq_asysyafii/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrPeriodeDitutup dikembalikan jika transaksi menyentuh periode yang sudah tutup buku
+var ErrPeriodeDitutup = errors.New("periode sudah tutup buku")
+
+// TutupBukuService mengelola tutup buku dan penguncian periode.
+// Periode P terkunci jika P atau periode setelahnya sudah ditutup, karena saldo akhir
+// periode yang ditutup bergantung pada seluruh transaksi sebelumnya.
+type TutupBukuService struct {
+	db *gorm.DB
+}
+
+func NewTutupBukuService(db *gorm.DB) *TutupBukuService {
+	return &TutupBukuService{db: db}
+}
+
+// PeriodeTutupTerakhir mengembalikan periode terakhir yang berstatus ditutup ("" jika belum ada)
+func (s *TutupBukuService) PeriodeTutupTerakhir() (string, error) {
+	var periode string
+	err := s.db.Model(&models.TutupBuku{}).
+		Where("status = ?", models.BukuDitutup).
+		Select("COALESCE(MAX(periode), '')").
+		Scan(&periode).Error
+	return periode, err
+}
+
+// CekPeriodeTerbuka memastikan semua periode yang disentuh transaksi belum terkunci
+func (s *TutupBukuService) CekPeriodeTerbuka(periodes ...string) error {
+	terakhir, err := s.PeriodeTutupTerakhir()
+	if err != nil {
+		return err
+	}
+	if terakhir == "" {
+		return nil
+	}
+
+	for _, periode := range periodes {
+		if periode != "" && periode <= terakhir {
+			return fmt.Errorf("%w: periode %s terkunci karena buku sudah ditutup sampai %s", ErrPeriodeDitutup, periode, terakhir)
+		}
+	}
+	return nil
+}
+
+// Tutup menghitung ulang rekap periode lalu menguncinya beserta snapshot saldo akhir
+func (s *TutupBukuService) Tutup(periode, adminID, catatan string) (*models.TutupBuku, error) {
+	if _, err := time.Parse("2006-01", periode); err != nil {
+		return nil, errors.New("format periode tidak valid. Gunakan format YYYY-MM")
+	}
+	if periode > time.Now().Format("2006-01") {
+		return nil, errors.New("tidak dapat menutup buku periode yang belum berjalan")
+	}
+
+	var tutupBuku models.TutupBuku
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := NewTutupBukuService(tx).CekPeriodeTerbuka(periode); err != nil {
+			return err
+		}
+
+		rekap, err := NewRekapService(tx).HitungPeriode(periode)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("periode = ?", periode).First(&tutupBuku).Error
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			tutupBuku = models.TutupBuku{
+				IDTutupBuku: uuid.New().String(),
+				Periode:     periode,
+			}
+		}
+
+		tutupBuku.Status = models.BukuDitutup
+		tutupBuku.Catatan = catatan
+		tutupBuku.SaldoAkhirSyahriah = rekap.SaldoAkhirSyahriah
+		tutupBuku.SaldoAkhirDonasi = rekap.SaldoAkhirDonasi
+		tutupBuku.SaldoAkhirTotal = rekap.SaldoAkhirTotal
+		tutupBuku.DitutupOleh = adminID
+		tutupBuku.WaktuTutup = time.Now()
+		if err := tx.Save(&tutupBuku).Error; err != nil {
+			return err
+		}
+
+		keterangan := fmt.Sprintf("Tutup buku periode %s, saldo akhir %.2f", periode, rekap.SaldoAkhirTotal)
+		return NewLogService(tx).LogAktivitas(adminID, AksiTutupBuku, TargetRekap, tutupBuku.IDTutupBuku, keterangan)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tutupBuku, nil
+}
+
+// Buka membuka kembali periode yang sudah ditutup dengan alasan yang dicatat.
+// Periode setelahnya harus dibuka lebih dulu agar urutan tutup buku tetap konsisten.
+func (s *TutupBukuService) Buka(periode, adminID, alasan string) (*models.TutupBuku, error) {
+	if alasan == "" {
+		return nil, errors.New("alasan membuka periode wajib diisi")
+	}
+
+	var tutupBuku models.TutupBuku
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("periode = ? AND status = ?", periode, models.BukuDitutup).First(&tutupBuku).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("periode %s tidak dalam status tutup buku", periode)
+			}
+			return err
+		}
+
+		var setelahnya []string
+		if err := tx.Model(&models.TutupBuku{}).
+			Where("status = ? AND periode > ?", models.BukuDitutup, periode).
+			Order("periode ASC").
+			Pluck("periode", &setelahnya).Error; err != nil {
+			return err
+		}
+		if len(setelahnya) > 0 {
+			return fmt.Errorf("buka periode %v terlebih dahulu", setelahnya)
+		}
+
+		sekarang := time.Now()
+		tutupBuku.Status = models.BukuDibuka
+		tutupBuku.DibukaOleh = &adminID
+		tutupBuku.WaktuBuka = &sekarang
+		tutupBuku.AlasanBuka = &alasan
+		if err := tx.Save(&tutupBuku).Error; err != nil {
+			return err
+		}
+
+		keterangan := fmt.Sprintf("Buka kembali periode %s: %s", periode, alasan)
+		return NewLogService(tx).LogAktivitas(adminID, AksiBukaBuku, TargetRekap, tutupBuku.IDTutupBuku, keterangan)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tutupBuku, nil
+}
@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// PenulisTabel menulis data baris demi baris ke file ekspor tanpa menampung seluruh data di memori
+type PenulisTabel interface {
+	TulisBaris(kolom ...interface{}) error
+	Flush() error
+	Tutup() error
+}
+
+// Format ekspor yang didukung
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ContentTypeEkspor mengembalikan content type untuk format ekspor
+func ContentTypeEkspor(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewPenulisTabel membuat penulis untuk format csv atau xlsx
+func NewPenulisTabel(format string, w io.Writer, namaSheet string) (PenulisTabel, error) {
+	switch format {
+	case FormatCSV:
+		// BOM agar Excel membaca CSV sebagai UTF-8
+		if _, err := w.Write([]byte("\xef\xbb\xbf")); err != nil {
+			return nil, err
+		}
+		return &penulisCSV{w: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		return newPenulisXLSX(w, namaSheet)
+	default:
+		return nil, fmt.Errorf("format ekspor tidak dikenal: %s", format)
+	}
+}
+
+type penulisCSV struct {
+	w *csv.Writer
+}
+
+func (p *penulisCSV) TulisBaris(kolom ...interface{}) error {
+	baris := make([]string, len(kolom))
+	for i, k := range kolom {
+		baris[i] = teksSel(k)
+		switch k.(type) {
+		case int, int64, float64:
+		default:
+			// Awalan petik membuat spreadsheet membaca teks seperti "=HYPERLINK(...)" apa adanya
+			if sepertiRumus(baris[i]) {
+				baris[i] = "'" + baris[i]
+			}
+		}
+	}
+	return p.w.Write(baris)
+}
+
+func (p *penulisCSV) Flush() error {
+	p.w.Flush()
+	return p.w.Error()
+}
+
+func (p *penulisCSV) Tutup() error {
+	return p.Flush()
+}
+
+// penulisXLSX menulis workbook satu sheet. Bagian statis ditulis lebih dulu dan sheet
+// ditulis terakhir sebagai entri zip yang mengalir, sehingga baris bisa ditulis satu per satu.
+type penulisXLSX struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	baris int
+}
+
+func newPenulisXLSX(w io.Writer, namaSheet string) (*penulisXLSX, error) {
+	if namaSheet == "" {
+		namaSheet = "Sheet1"
+	}
+	z := zip.NewWriter(w)
+	statis := map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`,
+		"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`,
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="` + escapeXML(namaSheet) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`,
+		// Style 1: header tebal, style 2: angka dengan pemisah ribuan, style 3: tanggal
+		"xl/styles.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm"/></numFmts><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border/></borders><cellStyleXfs count="1"><xf/></cellStyleXfs><cellXfs count="5"><xf/><xf fontId="1" applyFont="1"/><xf numFmtId="3" applyNumberFormat="1"/><xf numFmtId="164" applyNumberFormat="1"/><xf quotePrefix="1"/></cellXfs></styleSheet>`,
+	}
+	for _, nama := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		f, err := z.Create(nama)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, statis[nama]); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(f)
+	if _, err := sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+	return &penulisXLSX{zip: z, sheet: sheet}, nil
+}
+
+// TulisBaris menulis satu baris; baris pertama dianggap header dan ditulis tebal
+func (p *penulisXLSX) TulisBaris(kolom ...interface{}) error {
+	p.baris++
+	fmt.Fprintf(p.sheet, `<row r="%d">`, p.baris)
+	for i, k := range kolom {
+		ref := namaKolom(i) + fmt.Sprint(p.baris)
+		style := ""
+		if p.baris == 1 {
+			style = ` s="1"`
+		}
+		switch v := k.(type) {
+		case int, int64:
+			fmt.Fprintf(p.sheet, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+		case float64:
+			if style == "" {
+				style = ` s="2"`
+			}
+			fmt.Fprintf(p.sheet, `<c r="%s"%s><v>%.2f</v></c>`, ref, style, v)
+		case time.Time:
+			if v.IsZero() {
+				fmt.Fprintf(p.sheet, `<c r="%s"%s/>`, ref, style)
+				continue
+			}
+			fmt.Fprintf(p.sheet, `<c r="%s" s="3"><v>%.6f</v></c>`, ref, serialExcel(v))
+		default:
+			// Sel inlineStr tidak dievaluasi sebagai rumus; quotePrefix menjaganya tetap teks saat sel disunting
+			teks := teksSel(k)
+			if style == "" && sepertiRumus(teks) {
+				style = ` s="4"`
+			}
+			fmt.Fprintf(p.sheet, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, style, escapeXML(teks))
+		}
+	}
+	_, err := p.sheet.WriteString("</row>")
+	return err
+}
+
+func (p *penulisXLSX) Flush() error {
+	if err := p.sheet.Flush(); err != nil {
+		return err
+	}
+	return p.zip.Flush()
+}
+
+func (p *penulisXLSX) Tutup() error {
+	if _, err := p.sheet.WriteString("</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	if err := p.sheet.Flush(); err != nil {
+		return err
+	}
+	return p.zip.Close()
+}
+
+// teksSel mengubah nilai sel menjadi teks untuk CSV dan sel string XLSX
+func teksSel(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case *string:
+		if x == nil {
+			return ""
+		}
+		return *x
+	case float64:
+		return fmt.Sprintf("%.2f", x)
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.Format("2006-01-02 15:04:05")
+	case *time.Time:
+		if x == nil {
+			return ""
+		}
+		return x.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// sepertiRumus melaporkan teks yang akan dibaca spreadsheet sebagai rumus (CSV/formula injection)
+func sepertiRumus(s string) bool {
+	return s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0]))
+}
+
+// namaKolom mengubah indeks kolom (0-based) menjadi huruf kolom Excel: 0 -> A, 26 -> AA
+func namaKolom(i int) string {
+	nama := ""
+	for i >= 0 {
+		nama = string(rune('A'+i%26)) + nama
+		i = i/26 - 1
+	}
+	return nama
+}
+
+// serialExcel mengubah waktu menjadi nomor seri tanggal Excel (hari sejak 1899-12-30)
+func serialExcel(t time.Time) float64 {
+	awal := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	lokal := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return lokal.Sub(awal).Hours() / 24
+}
+
+var penggantiXML = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// escapeXML meng-escape teks dan membuang karakter kontrol yang tidak valid di XML
+func escapeXML(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	return penggantiXML.Replace(s)
+}
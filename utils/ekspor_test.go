@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPenulisCSVMenetralkanRumus(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := NewPenulisTabel(FormatCSV, &buf, "Uji")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nama := "Budi"
+	if err := p.TulisBaris("=HYPERLINK(\"http://x\")", "+1", "-2", "@SUM(A1)", "\tA", "\rB", &nama, -75000.0, int64(-3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Tutup(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\xef\xbb\xbf\"'=HYPERLINK(\"\"http://x\"\")\",'+1,'-2,'@SUM(A1),'\tA,\"'\rB\",Budi,-75000.00,-3\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV = %q, want %q", got, want)
+	}
+}
+
+func TestPenulisXLSXMenetralkanRumus(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := NewPenulisTabel(FormatXLSX, &buf, "Uji")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.TulisBaris("Keterangan"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.TulisBaris("=1+1", "biasa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Tutup(); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := z.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	isi, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sheet := string(isi)
+	if !strings.HasSuffix(sheet, "</sheetData></worksheet>") {
+		t.Errorf("sheet tidak ditutup: %s", sheet)
+	}
+	for _, want := range []string{
+		`<c r="A2" t="inlineStr" s="4"><is><t xml:space="preserve">=1+1</t></is></c>`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">biasa</t></is></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet tidak memuat %s:\n%s", want, sheet)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var angkaSatuan = []string{"", "satu", "dua", "tiga", "empat", "lima", "enam", "tujuh", "delapan", "sembilan", "sepuluh", "sebelas"}
+
+// Terbilang mengubah angka menjadi kata dalam bahasa Indonesia, misalnya 110000 menjadi "seratus sepuluh ribu"
+func Terbilang(n int64) string {
+	if n == 0 {
+		return "nol"
+	}
+	if n < 0 {
+		return "minus " + Terbilang(-n)
+	}
+	return strings.TrimSpace(terbilang(n))
+}
+
+func terbilang(n int64) string {
+	switch {
+	case n < 12:
+		return angkaSatuan[n]
+	case n < 20:
+		return terbilang(n-10) + " belas"
+	case n < 100:
+		return strings.TrimSpace(terbilang(n/10) + " puluh " + terbilang(n%10))
+	case n < 200:
+		return strings.TrimSpace("seratus " + terbilang(n-100))
+	case n < 1000:
+		return strings.TrimSpace(terbilang(n/100) + " ratus " + terbilang(n%100))
+	case n < 2000:
+		return strings.TrimSpace("seribu " + terbilang(n-1000))
+	case n < 1000000:
+		return strings.TrimSpace(terbilang(n/1000) + " ribu " + terbilang(n%1000))
+	case n < 1000000000:
+		return strings.TrimSpace(terbilang(n/1000000) + " juta " + terbilang(n%1000000))
+	case n < 1000000000000:
+		return strings.TrimSpace(terbilang(n/1000000000) + " miliar " + terbilang(n%1000000000))
+	default:
+		return strings.TrimSpace(terbilang(n/1000000000000) + " triliun " + terbilang(n%1000000000000))
+	}
+}
+
+// FormatRupiah memformat nominal dengan pemisah ribuan, misalnya 110000 menjadi "Rp 110.000"
+func FormatRupiah(nominal float64) string {
+	s := fmt.Sprintf("%.0f", nominal)
+	negatif := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	if negatif {
+		return "-Rp " + b.String()
+	}
+	return "Rp " + b.String()
+}
+
+var namaBulan = []string{"", "Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli", "Agustus", "September", "Oktober", "November", "Desember"}
+
+// TanggalIndonesia memformat tanggal menjadi "18 Oktober 2026"
+func TanggalIndonesia(t time.Time) string {
+	return fmt.Sprintf("%d %s %d", t.Day(), namaBulan[t.Month()], t.Year())
+}
+
+// BulanIndonesia memformat periode YYYY-MM menjadi "Oktober 2026"
+func BulanIndonesia(periode string) string {
+	t, err := time.Parse("2006-01", periode)
+	if err != nil {
+		return periode
+	}
+	return fmt.Sprintf("%s %d", namaBulan[t.Month()], t.Year())
+}
+
+// NormalisasiTelp membuang spasi dan tanda baca, dan mengembalikan angka 0 di depan
+// yang hilang ketika nomor disimpan sebagai angka di spreadsheet
+func NormalisasiTelp(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			b.WriteRune(r)
+		}
+	}
+	telp := b.String()
+	if strings.HasPrefix(telp, "8") {
+		telp = "0" + telp
+	}
+	return telp
+}
@@ -0,0 +1,56 @@
+package utils
+
+import "testing"
+
+func TestTerbilang(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "nol"},
+		{1, "satu"},
+		{10, "sepuluh"},
+		{11, "sebelas"},
+		{12, "dua belas"},
+		{19, "sembilan belas"},
+		{20, "dua puluh"},
+		{21, "dua puluh satu"},
+		{100, "seratus"},
+		{111, "seratus sebelas"},
+		{250, "dua ratus lima puluh"},
+		{1000, "seribu"},
+		{1500, "seribu lima ratus"},
+		{2000, "dua ribu"},
+		{110000, "seratus sepuluh ribu"},
+		{125750, "seratus dua puluh lima ribu tujuh ratus lima puluh"},
+		{1000000, "satu juta"},
+		{2500000, "dua juta lima ratus ribu"},
+		{1000000000, "satu miliar"},
+		{1000001000, "satu miliar seribu"},
+		{3000000000000, "tiga triliun"},
+		{-75000, "minus tujuh puluh lima ribu"},
+	}
+	for _, tt := range tests {
+		if got := Terbilang(tt.n); got != tt.want {
+			t.Errorf("Terbilang(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatRupiah(t *testing.T) {
+	tests := []struct {
+		nominal float64
+		want    string
+	}{
+		{0, "Rp 0"},
+		{500, "Rp 500"},
+		{110000, "Rp 110.000"},
+		{1250000.4, "Rp 1.250.000"},
+		{-75000, "-Rp 75.000"},
+	}
+	for _, tt := range tests {
+		if got := FormatRupiah(tt.nominal); got != tt.want {
+			t.Errorf("FormatRupiah(%v) = %q, want %q", tt.nominal, got, tt.want)
+		}
+	}
+}
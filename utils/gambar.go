@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	// Decoder format gambar yang didukung untuk thumbnail
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaksPikselGambar membatasi ukuran gambar yang didekode. Header dibaca lebih dulu agar gambar
+// kecil berdimensi raksasa (decompression bomb) tidak sempat menghabiskan memori.
+const MaksPikselGambar = 40_000_000
+
+// ErrGambarTerlaluBesar dikembalikan jika dimensi gambar melebihi MaksPikselGambar
+var ErrGambarTerlaluBesar = errors.New("dimensi gambar terlalu besar")
+
+// ThumbnailSamar membuat thumbnail JPEG selebar maksimal lebar piksel yang dipikselkan per blok
+// piksel, sehingga bentuk nota masih terlihat tetapi nomor rekening, nama dan angka tidak terbaca.
+// Format yang tidak bisa didekode (misalnya WebP) mengembalikan error.
+func ThumbnailSamar(data []byte, lebar, blok int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaksPikselGambar {
+		return nil, ErrGambarTerlaluBesar
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	b := src.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return nil, image.ErrFormat
+	}
+	if lebar <= 0 || lebar > b.Dx() {
+		lebar = b.Dx()
+	}
+	tinggi := b.Dy() * lebar / b.Dx()
+	if tinggi < 1 {
+		tinggi = 1
+	}
+	if blok < 1 {
+		blok = 1
+	}
+
+	// Setiap blok diisi rata-rata warna area sumber yang diwakilinya
+	dst := image.NewRGBA(image.Rect(0, 0, lebar, tinggi))
+	for by := 0; by < tinggi; by += blok {
+		for bx := 0; bx < lebar; bx += blok {
+			x1, y1 := min(bx+blok, lebar), min(by+blok, tinggi)
+			sx0 := b.Min.X + bx*b.Dx()/lebar
+			sy0 := b.Min.Y + by*b.Dy()/tinggi
+			sx1 := max(b.Min.X+x1*b.Dx()/lebar, sx0+1)
+			sy1 := max(b.Min.Y+y1*b.Dy()/tinggi, sy0+1)
+
+			var rr, gg, bb, n uint64
+			for y := sy0; y < sy1; y++ {
+				for x := sx0; x < sx1; x++ {
+					cr, cg, cb, _ := src.At(x, y).RGBA()
+					rr += uint64(cr)
+					gg += uint64(cg)
+					bb += uint64(cb)
+					n++
+				}
+			}
+			warna := color.RGBA{uint8(rr / n >> 8), uint8(gg / n >> 8), uint8(bb / n >> 8), 0xff}
+			for y := by; y < y1; y++ {
+				for x := bx; x < x1; x++ {
+					dst.SetRGBA(x, y, warna)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 75}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// pngDenganDimensi menulis PNG kecil lalu mengganti lebar dan tinggi di header IHDR,
+// sehingga berkasnya kecil tetapi mengaku berdimensi besar
+func pngDenganDimensi(t *testing.T, lebar, tinggi uint32) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// signature 8 byte, panjang chunk 4 byte, tipe "IHDR" 4 byte, lalu 13 byte data dan CRC
+	ihdr := data[12 : 12+4+13]
+	binary.BigEndian.PutUint32(ihdr[4:8], lebar)
+	binary.BigEndian.PutUint32(ihdr[8:12], tinggi)
+	binary.BigEndian.PutUint32(data[12+4+13:], crc32.ChecksumIEEE(ihdr))
+	return data
+}
+
+func TestThumbnailSamar(t *testing.T) {
+	thumb, err := ThumbnailSamar(pngDenganDimensi(t, 8, 8), 4, 2)
+	if err != nil {
+		t.Fatalf("ThumbnailSamar() error = %v", err)
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(thumb)); err != nil || cfg.Width != 4 || cfg.Height != 4 {
+		t.Errorf("thumbnail = %+v, %v, want 4x4", cfg, err)
+	}
+}
+
+func TestThumbnailSamarBatasPiksel(t *testing.T) {
+	tests := []struct {
+		nama         string
+		lebar        uint32
+		tinggi       uint32
+		terlaluBesar bool
+	}{
+		// Header tepat 40 MP lolos pemeriksaan, lalu gagal didekode karena datanya hanya 8x8
+		{"tepat di batas", 8000, 5000, false},
+		{"di atas batas", 8001, 5000, true},
+		{"bom dekompresi", 100000, 100000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			_, err := ThumbnailSamar(pngDenganDimensi(t, tt.lebar, tt.tinggi), 4, 2)
+			if err == nil {
+				t.Fatal("ThumbnailSamar() error = nil")
+			}
+			if errors.Is(err, ErrGambarTerlaluBesar) != tt.terlaluBesar {
+				t.Errorf("ThumbnailSamar() error = %v, terlaluBesar = %v", err, tt.terlaluBesar)
+			}
+		})
+	}
+}
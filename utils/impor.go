@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Batas isi tabel impor. XLSX adalah zip, sehingga file unggahan kecil bisa mengembang sangat besar
+// saat diekstrak; setiap bagian XML dibatasi ukuran ekstraknya, begitu pula jumlah baris dan kolom.
+const (
+	MaksUkuranXMLImpor = 32 << 20
+	MaksBarisImpor     = 20000
+	MaksKolomImpor     = 100
+)
+
+// ErrTabelTerlaluBesar dikembalikan jika isi file impor melewati batas ukuran, baris atau kolom
+var ErrTabelTerlaluBesar = errors.New("isi file impor terlalu besar")
+
+// BacaTabel membaca file CSV atau XLSX (ditentukan dari ekstensi nama file) menjadi baris-baris teks.
+// Untuk XLSX hanya sheet pertama yang dibaca, sel tanggal diubah ke format YYYY-MM-DD.
+// Baris kosong di akhir file dibuang.
+func BacaTabel(namaFile string, data []byte) ([][]string, error) {
+	var baris [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(namaFile)) {
+	case ".csv":
+		baris, err = bacaCSV(data)
+	case ".xlsx":
+		baris, err = bacaXLSX(data)
+	default:
+		return nil, fmt.Errorf("format file tidak didukung, gunakan .csv atau .xlsx")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cekUkuranTabel(baris); err != nil {
+		return nil, err
+	}
+
+	for len(baris) > 0 && barisKosong(baris[len(baris)-1]) {
+		baris = baris[:len(baris)-1]
+	}
+	return baris, nil
+}
+
+// cekUkuranTabel membatasi jumlah baris dan kolom hasil baca
+func cekUkuranTabel(baris [][]string) error {
+	if len(baris) > MaksBarisImpor {
+		return fmt.Errorf("%w: maksimal %d baris", ErrTabelTerlaluBesar, MaksBarisImpor)
+	}
+	for _, b := range baris {
+		if len(b) > MaksKolomImpor {
+			return fmt.Errorf("%w: maksimal %d kolom", ErrTabelTerlaluBesar, MaksKolomImpor)
+		}
+	}
+	return nil
+}
+
+func barisKosong(baris []string) bool {
+	for _, s := range baris {
+		if strings.TrimSpace(s) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// bacaCSV mendukung pemisah koma maupun titik koma (bawaan Excel berbahasa Indonesia)
+func bacaCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	barisPertama := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		barisPertama = data[:i]
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(barisPertama, []byte(";")) > bytes.Count(barisPertama, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	return r.ReadAll()
+}
+
+type teksXLSX struct {
+	T string `xml:"t"`
+	R []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t teksXLSX) String() string {
+	s := t.T
+	for _, r := range t.R {
+		s += r.T
+	}
+	return s
+}
+
+type selXLSX struct {
+	Ref    string   `xml:"r,attr"`
+	Tipe   string   `xml:"t,attr"`
+	Style  int      `xml:"s,attr"`
+	Nilai  string   `xml:"v"`
+	Inline teksXLSX `xml:"is"`
+}
+
+func bacaXLSX(data []byte) ([][]string, error) {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("file xlsx tidak valid: %v", err)
+	}
+	file := map[string]*zip.File{}
+	for _, f := range z.File {
+		file[f.Name] = f
+	}
+	bacaXML := func(nama string, v interface{}) error {
+		f, ok := file[nama]
+		if !ok {
+			return nil
+		}
+		if f.UncompressedSize64 > MaksUkuranXMLImpor {
+			return fmt.Errorf("%w: %s melebihi %d MB setelah diekstrak", ErrTabelTerlaluBesar, nama, MaksUkuranXMLImpor>>20)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		// archive/zip menolak isi yang mengembang melebihi ukuran di header, jadi cek di atas cukup
+		return xml.NewDecoder(rc).Decode(v)
+	}
+
+	// Cari lokasi sheet pertama lewat workbook dan relasinya
+	var workbook struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var relasi struct {
+		Rel []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := bacaXML("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if err := bacaXML("xl/_rels/workbook.xml.rels", &relasi); err != nil {
+		return nil, err
+	}
+	lokasiSheet := "xl/worksheets/sheet1.xml"
+	if len(workbook.Sheets) > 0 {
+		for _, r := range relasi.Rel {
+			if r.ID == workbook.Sheets[0].RID {
+				if strings.HasPrefix(r.Target, "/") {
+					lokasiSheet = strings.TrimPrefix(r.Target, "/")
+				} else {
+					lokasiSheet = path.Join("xl", r.Target)
+				}
+			}
+		}
+	}
+	if _, ok := file[lokasiSheet]; !ok {
+		return nil, fmt.Errorf("file xlsx tidak memiliki sheet")
+	}
+
+	var shared struct {
+		SI []teksXLSX `xml:"si"`
+	}
+	if err := bacaXML("xl/sharedStrings.xml", &shared); err != nil {
+		return nil, err
+	}
+
+	var styles struct {
+		NumFmt []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Kode string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		Xf []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := bacaXML("xl/styles.xml", &styles); err != nil {
+		return nil, err
+	}
+	formatKustom := map[int]string{}
+	for _, f := range styles.NumFmt {
+		formatKustom[f.ID] = f.Kode
+	}
+	styleTanggal := make([]bool, len(styles.Xf))
+	for i, xf := range styles.Xf {
+		styleTanggal[i] = formatTanggal(xf.NumFmtID, formatKustom[xf.NumFmtID])
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Nomor int       `xml:"r,attr"`
+			Sel   []selXLSX `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := bacaXML(lokasiSheet, &sheet); err != nil {
+		return nil, err
+	}
+
+	var hasil [][]string
+	for _, row := range sheet.Rows {
+		if row.Nomor > MaksBarisImpor || len(hasil) >= MaksBarisImpor {
+			return nil, fmt.Errorf("%w: maksimal %d baris", ErrTabelTerlaluBesar, MaksBarisImpor)
+		}
+		// Baris kosong di tengah tidak ditulis di XLSX, isi agar nomor baris tetap sesuai
+		for row.Nomor > 0 && len(hasil) < row.Nomor-1 {
+			hasil = append(hasil, nil)
+		}
+		var baris []string
+		for i, sel := range row.Sel {
+			kolom := i
+			if sel.Ref != "" {
+				kolom = indeksKolom(sel.Ref)
+			}
+			if kolom < 0 || kolom >= MaksKolomImpor {
+				return nil, fmt.Errorf("%w: maksimal %d kolom", ErrTabelTerlaluBesar, MaksKolomImpor)
+			}
+			for len(baris) <= kolom {
+				baris = append(baris, "")
+			}
+			baris[kolom] = nilaiSel(sel, shared.SI, styleTanggal)
+		}
+		hasil = append(hasil, baris)
+	}
+	return hasil, nil
+}
+
+func nilaiSel(sel selXLSX, shared []teksXLSX, styleTanggal []bool) string {
+	switch sel.Tipe {
+	case "s":
+		i, err := strconv.Atoi(sel.Nilai)
+		if err != nil || i < 0 || i >= len(shared) {
+			return ""
+		}
+		return shared[i].String()
+	case "inlineStr":
+		return sel.Inline.String()
+	case "str", "b", "e":
+		return sel.Nilai
+	}
+
+	angka, err := strconv.ParseFloat(sel.Nilai, 64)
+	if err != nil {
+		return sel.Nilai
+	}
+	if sel.Style >= 0 && sel.Style < len(styleTanggal) && styleTanggal[sel.Style] {
+		awal := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		return awal.Add(time.Duration(angka * 24 * float64(time.Hour))).Format("2006-01-02")
+	}
+	// Hindari notasi eksponen untuk angka panjang seperti nomor telepon
+	return strconv.FormatFloat(angka, 'f', -1, 64)
+}
+
+// formatTanggal menentukan apakah format angka Excel menampilkan tanggal
+func formatTanggal(id int, kode string) bool {
+	if (id >= 14 && id <= 22) || (id >= 45 && id <= 47) {
+		return true
+	}
+	if kode == "" {
+		return false
+	}
+	// Buang teks dalam kutip dan blok [..] (warna/locale) sebelum mencari kode tanggal
+	var b strings.Builder
+	dalamKutip, dalamKurung := false, false
+	for _, r := range kode {
+		switch {
+		case r == '"':
+			dalamKutip = !dalamKutip
+		case r == '[' && !dalamKutip:
+			dalamKurung = true
+		case r == ']' && !dalamKutip:
+			dalamKurung = false
+		case !dalamKutip && !dalamKurung:
+			b.WriteRune(r)
+		}
+	}
+	bersih := strings.ToLower(b.String())
+	return strings.ContainsAny(bersih, "dy")
+}
+
+// indeksKolom mengubah referensi sel seperti "AB12" menjadi indeks kolom 0-based
+func indeksKolom(ref string) int {
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+	}
+	return n - 1
+}
+
+// BacaUnggahan membaca isi file unggahan dengan batas ukuran
+func BacaUnggahan(r io.Reader, batas int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, batas+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > batas {
+		return nil, fmt.Errorf("ukuran file melebihi %d KB", batas/1024)
+	}
+	return data, nil
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+// xlsxSheet membungkus isi sheetData menjadi file XLSX minimal. Jika ukuranPalsu > 0, ukuran
+// ekstrak di header zip diisi nilai tersebut seperti pada zip bomb yang memalsukan header.
+func xlsxSheet(t *testing.T, sheetData string, ukuranPalsu uint64) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	isi := []byte(`<?xml version="1.0" encoding="UTF-8"?><worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheetData + `</sheetData></worksheet>`)
+
+	if ukuranPalsu == 0 {
+		f, err := z.Create("xl/worksheets/sheet1.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(isi); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		var padat bytes.Buffer
+		fw, _ := flate.NewWriter(&padat, flate.BestCompression)
+		fw.Write(isi)
+		fw.Close()
+		f, err := z.CreateRaw(&zip.FileHeader{
+			Name:               "xl/worksheets/sheet1.xml",
+			Method:             zip.Deflate,
+			CRC32:              crc32.ChecksumIEEE(isi),
+			CompressedSize64:   uint64(padat.Len()),
+			UncompressedSize64: ukuranPalsu,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(padat.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBacaTabelXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := NewPenulisTabel(FormatXLSX, &buf, "Uji")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.TulisBaris("Nama", "Nominal")
+	p.TulisBaris("Budi", 110000.0)
+	if err := p.Tutup(); err != nil {
+		t.Fatal(err)
+	}
+
+	tabel, err := BacaTabel("uji.xlsx", buf.Bytes())
+	if err != nil {
+		t.Fatalf("BacaTabel() error = %v", err)
+	}
+	if len(tabel) != 2 || strings.Join(tabel[1], "|") != "Budi|110000" {
+		t.Errorf("BacaTabel() = %q", tabel)
+	}
+}
+
+func TestBacaTabelBatas(t *testing.T) {
+	besar := strings.Repeat(`<row><c t="inlineStr"><is><t>xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx</t></is></c></row>`, (MaksUkuranXMLImpor/64)+1)
+
+	tests := []struct {
+		nama     string
+		namaFile string
+		data     []byte
+		want     error
+	}{
+		{"baris terakhir yang diizinkan", "uji.xlsx", xlsxSheet(t, fmt.Sprintf(`<row r="%d"><c r="A%d"><v>1</v></c></row>`, MaksBarisImpor, MaksBarisImpor), 0), nil},
+		{"nomor baris melewati batas", "uji.xlsx", xlsxSheet(t, fmt.Sprintf(`<row r="%d"><c r="A%d"><v>1</v></c></row>`, MaksBarisImpor+1, MaksBarisImpor+1), 0), ErrTabelTerlaluBesar},
+		{"kolom melewati batas", "uji.xlsx", xlsxSheet(t, `<row r="1"><c r="XFD1"><v>1</v></c></row>`, 0), ErrTabelTerlaluBesar},
+		{"referensi kolom meluap", "uji.xlsx", xlsxSheet(t, `<row r="1"><c r="ZZZZZZZZZZZZZZZ1"><v>1</v></c></row>`, 0), ErrTabelTerlaluBesar},
+		{"ukuran ekstrak melewati batas", "uji.xlsx", xlsxSheet(t, besar, 0), ErrTabelTerlaluBesar},
+		{"header ukuran dipalsukan", "uji.xlsx", xlsxSheet(t, besar, 1<<20), zip.ErrFormat},
+		{"csv terlalu banyak kolom", "uji.csv", []byte(strings.Repeat("a,", MaksKolomImpor) + "a\n"), ErrTabelTerlaluBesar},
+		{"csv terlalu banyak baris", "uji.csv", []byte(strings.Repeat("a\n", MaksBarisImpor+1)), ErrTabelTerlaluBesar},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			_, err := BacaTabel(tt.namaFile, tt.data)
+			if tt.want == nil && err != nil {
+				t.Fatalf("BacaTabel() error = %v", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("BacaTabel() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"strings"
+
+	// Decoder format logo yang didukung
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Ukuran kertas dalam point (1/72 inch)
+const (
+	A4Lebar  = 595.28
+	A4Tinggi = 841.89
+	A5Lebar  = 419.53
+	A5Tinggi = 595.28
+)
+
+// Perataan teks terhadap koordinat x
+const (
+	RataKiri = iota
+	RataTengah
+	RataKanan
+)
+
+// PDF adalah penulis dokumen PDF sederhana tanpa dependensi luar.
+// Mendukung teks Helvetica (biasa dan tebal), garis, kotak dan gambar.
+// Koordinat dihitung dari pojok kiri atas halaman agar mudah dipakai untuk tata letak dokumen.
+type PDF struct {
+	lebar   float64
+	tinggi  float64
+	halaman []*bytes.Buffer
+	gambar  []gambarPDF
+}
+
+type gambarPDF struct {
+	data   []byte
+	lebar  int
+	tinggi int
+}
+
+// NewPDF membuat dokumen kosong dengan satu halaman berukuran lebar x tinggi point
+func NewPDF(lebar, tinggi float64) *PDF {
+	p := &PDF{lebar: lebar, tinggi: tinggi}
+	p.TambahHalaman()
+	return p
+}
+
+// Lebar mengembalikan lebar halaman
+func (p *PDF) Lebar() float64 { return p.lebar }
+
+// Tinggi mengembalikan tinggi halaman
+func (p *PDF) Tinggi() float64 { return p.tinggi }
+
+// TambahHalaman memulai halaman baru, perintah gambar berikutnya ditulis ke halaman ini
+func (p *PDF) TambahHalaman() {
+	p.halaman = append(p.halaman, &bytes.Buffer{})
+}
+
+func (p *PDF) tulis(format string, args ...interface{}) {
+	fmt.Fprintf(p.halaman[len(p.halaman)-1], format, args...)
+}
+
+// Teks menulis satu baris teks dengan baseline di y
+func (p *PDF) Teks(x, y, ukuran float64, tebal bool, rata int, s string) {
+	switch rata {
+	case RataTengah:
+		x -= LebarTeks(s, ukuran, tebal) / 2
+	case RataKanan:
+		x -= LebarTeks(s, ukuran, tebal)
+	}
+	font := "F1"
+	if tebal {
+		font = "F2"
+	}
+	p.tulis("BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, ukuran, x, p.tinggi-y, escapeTeks(s))
+}
+
+// Paragraf menulis teks yang dibungkus sesuai lebar dan mengembalikan y setelah baris terakhir
+func (p *PDF) Paragraf(x, y, lebar, ukuran float64, tebal bool, s string) float64 {
+	spasi := ukuran * 1.35
+	for _, baris := range BungkusTeks(s, lebar, ukuran, tebal) {
+		p.Teks(x, y, ukuran, tebal, RataKiri, baris)
+		y += spasi
+	}
+	return y
+}
+
+// Garis menggambar garis lurus dengan ketebalan tertentu
+func (p *PDF) Garis(x1, y1, x2, y2, tebal float64) {
+	p.tulis("%.2f w %.2f %.2f m %.2f %.2f l S\n", tebal, x1, p.tinggi-y1, x2, p.tinggi-y2)
+}
+
+// Kotak menggambar persegi panjang dengan pojok kiri atas di (x, y).
+// abu > 0 mengisi kotak dengan warna abu-abu (0-1, 1 = putih) tanpa garis tepi.
+func (p *PDF) Kotak(x, y, lebar, tinggi, tebal, abu float64) {
+	if abu > 0 {
+		p.tulis("%.2f g %.2f %.2f %.2f %.2f re f 0 g\n", abu, x, p.tinggi-y-tinggi, lebar, tinggi)
+		return
+	}
+	p.tulis("%.2f w %.2f %.2f %.2f %.2f re S\n", tebal, x, p.tinggi-y-tinggi, lebar, tinggi)
+}
+
+// Gambar menempelkan gambar (JPEG, PNG atau GIF) dengan pojok kiri atas di (x, y).
+// Gambar disimpan ulang sebagai JPEG; transparansi diganti latar putih.
+func (p *PDF) Gambar(r io.Reader, x, y, lebar, tinggi float64) error {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	latar := image.NewRGBA(img.Bounds())
+	draw.Draw(latar, latar.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(latar, latar.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, latar, &jpeg.Options{Quality: 90}); err != nil {
+		return err
+	}
+
+	p.gambar = append(p.gambar, gambarPDF{data: buf.Bytes(), lebar: img.Bounds().Dx(), tinggi: img.Bounds().Dy()})
+	p.tulis("q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", lebar, tinggi, x, p.tinggi-y-tinggi, len(p.gambar))
+	return nil
+}
+
+// Bytes menyusun seluruh objek PDF beserta tabel xref
+func (p *PDF) Bytes() []byte {
+	var out bytes.Buffer
+	var offset []int
+	objek := func(isi string) {
+		offset = append(offset, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offset), isi)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1: catalog, 2: pages, 3-4: font, lalu gambar, lalu pasangan halaman + konten
+	jumlahHalaman := len(p.halaman)
+	idGambarAwal := 5
+	idHalamanAwal := idGambarAwal + len(p.gambar)
+
+	kids := make([]string, jumlahHalaman)
+	for i := range p.halaman {
+		kids[i] = fmt.Sprintf("%d 0 R", idHalamanAwal+i*2)
+	}
+
+	objek("<< /Type /Catalog /Pages 2 0 R >>")
+	objek(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), jumlahHalaman))
+	objek("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	objek("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	var xobject strings.Builder
+	for i, g := range p.gambar {
+		offset = append(offset, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n",
+			len(offset), g.lebar, g.tinggi, len(g.data))
+		out.Write(g.data)
+		out.WriteString("\nendstream\nendobj\n")
+		fmt.Fprintf(&xobject, "/Im%d %d 0 R ", i+1, idGambarAwal+i)
+	}
+
+	resources := "/Font << /F1 3 0 R /F2 4 0 R >>"
+	if xobject.Len() > 0 {
+		resources += " /XObject << " + xobject.String() + ">>"
+	}
+
+	for i, konten := range p.halaman {
+		objek(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << %s >> /Contents %d 0 R >>",
+			p.lebar, p.tinggi, resources, idHalamanAwal+i*2+1))
+		offset = append(offset, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Length %d >>\nstream\n", len(offset), konten.Len())
+		out.Write(konten.Bytes())
+		out.WriteString("endstream\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offset)+1)
+	for _, o := range offset {
+		fmt.Fprintf(&out, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offset)+1, xref)
+	return out.Bytes()
+}
+
+// escapeTeks mengubah teks ke WinAnsi dan meng-escape karakter khusus PDF.
+// Karakter di luar Latin-1 diganti tanda tanya.
+func escapeTeks(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r < 32 || r > 255:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
+
+// Lebar karakter Helvetica (per 1000 unit) untuk ASCII 32-126
+var lebarHelvetica = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var lebarHelveticaBold = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// LebarTeks menghitung lebar teks dalam point untuk ukuran font tertentu
+func LebarTeks(s string, ukuran float64, tebal bool) float64 {
+	tabel := &lebarHelvetica
+	if tebal {
+		tabel = &lebarHelveticaBold
+	}
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r <= 126 {
+			total += tabel[r-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * ukuran / 1000
+}
+
+// BungkusTeks memecah teks menjadi baris-baris yang muat dalam lebar tertentu
+func BungkusTeks(s string, lebar, ukuran float64, tebal bool) []string {
+	var hasil []string
+	for _, paragraf := range strings.Split(s, "\n") {
+		kata := strings.Fields(paragraf)
+		if len(kata) == 0 {
+			hasil = append(hasil, "")
+			continue
+		}
+		baris := kata[0]
+		for _, k := range kata[1:] {
+			if LebarTeks(baris+" "+k, ukuran, tebal) > lebar {
+				hasil = append(hasil, baris)
+				baris = k
+				continue
+			}
+			baris += " " + k
+		}
+		hasil = append(hasil, baris)
+	}
+	return hasil
+}
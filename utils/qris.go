@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tag EMVCo Merchant Presented Mode yang diubah saat membuat QRIS dinamis
+const (
+	tagQRISMetode     = "01" // 11 = statis, 12 = dinamis
+	tagQRISNominal    = "54"
+	tagQRISDataTambah = "62"
+	tagQRISCRC        = "63"
+
+	subtagQRISNomorTagihan = "01"
+	subtagQRISReferensi    = "05"
+)
+
+// ErrQRISTidakValid dikembalikan jika payload bukan QRIS yang sah (format TLV atau CRC salah)
+var ErrQRISTidakValid = errors.New("payload QRIS tidak valid")
+
+// elemenTLV adalah satu data object EMVCo: ID 2 digit, panjang 2 digit, lalu nilai
+type elemenTLV struct {
+	id    string
+	nilai string
+}
+
+func bacaTLV(s string) ([]elemenTLV, error) {
+	var hasil []elemenTLV
+	for len(s) > 0 {
+		if len(s) < 4 {
+			return nil, fmt.Errorf("%w: data terpotong", ErrQRISTidakValid)
+		}
+		panjang, err := strconv.Atoi(s[2:4])
+		if err != nil || len(s) < 4+panjang {
+			return nil, fmt.Errorf("%w: panjang tag %s salah", ErrQRISTidakValid, s[:2])
+		}
+		hasil = append(hasil, elemenTLV{id: s[:2], nilai: s[4 : 4+panjang]})
+		s = s[4+panjang:]
+	}
+	return hasil, nil
+}
+
+func tulisTLV(elemen []elemenTLV) string {
+	var b strings.Builder
+	for _, e := range elemen {
+		fmt.Fprintf(&b, "%s%02d%s", e.id, len(e.nilai), e.nilai)
+	}
+	return b.String()
+}
+
+// aturTLV mengganti nilai tag yang ada atau menambahkannya, lalu mengurutkan berdasarkan ID
+func aturTLV(elemen []elemenTLV, id, nilai string) []elemenTLV {
+	for i := range elemen {
+		if elemen[i].id == id {
+			elemen[i].nilai = nilai
+			return elemen
+		}
+	}
+	elemen = append(elemen, elemenTLV{id: id, nilai: nilai})
+	sort.SliceStable(elemen, func(i, j int) bool { return elemen[i].id < elemen[j].id })
+	return elemen
+}
+
+// CRC16QRIS menghitung CRC-16/CCITT-FALSE (polinom 0x1021, awal 0xFFFF) sesuai EMVCo
+func CRC16QRIS(data string) string {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%04X", crc)
+}
+
+// ValidasiQRIS memeriksa struktur TLV, akun merchant dan checksum CRC payload QRIS
+func ValidasiQRIS(payload string) error {
+	_, err := bacaQRIS(payload)
+	return err
+}
+
+// bacaQRIS memvalidasi payload lalu mengembalikan elemennya tanpa tag CRC
+func bacaQRIS(payload string) ([]elemenTLV, error) {
+	payload = strings.TrimSpace(payload)
+	if len(payload) < 8 || !strings.HasPrefix(payload, "000201") {
+		return nil, fmt.Errorf("%w: harus diawali 000201", ErrQRISTidakValid)
+	}
+	isi, crc := payload[:len(payload)-4], payload[len(payload)-4:]
+	if !strings.HasSuffix(isi, tagQRISCRC+"04") {
+		return nil, fmt.Errorf("%w: tag CRC harus berada di akhir", ErrQRISTidakValid)
+	}
+	if !strings.EqualFold(CRC16QRIS(isi), crc) {
+		return nil, fmt.Errorf("%w: checksum CRC tidak cocok", ErrQRISTidakValid)
+	}
+
+	elemen, err := bacaTLV(isi[:len(isi)-4])
+	if err != nil {
+		return nil, err
+	}
+	adaMerchant := false
+	for _, e := range elemen {
+		if id, _ := strconv.Atoi(e.id); id >= 26 && id <= 51 {
+			adaMerchant = true
+		}
+	}
+	if !adaMerchant {
+		return nil, fmt.Errorf("%w: informasi akun merchant tidak ditemukan", ErrQRISTidakValid)
+	}
+	return elemen, nil
+}
+
+// QRISDinamis membuat payload QRIS dinamis dari QRIS statis merchant dengan nominal tetap dan
+// referensi di data tambahan (tag 62, sub-tag nomor tagihan dan label referensi). Nominal dibulatkan
+// ke rupiah penuh. Referensi maksimal 25 karakter alfanumerik.
+func QRISDinamis(statis string, nominal float64, referensi string) (string, error) {
+	elemen, err := bacaQRIS(statis)
+	if err != nil {
+		return "", err
+	}
+	if nominal < 1 {
+		return "", errors.New("nominal QRIS minimal Rp 1")
+	}
+	if len(referensi) == 0 || len(referensi) > 25 {
+		return "", errors.New("referensi QRIS harus 1-25 karakter")
+	}
+
+	elemen = aturTLV(elemen, tagQRISMetode, "12")
+	elemen = aturTLV(elemen, tagQRISNominal, strconv.FormatInt(int64(math.Round(nominal)), 10))
+
+	// Sub-tag data tambahan lain dari QRIS statis (misalnya label terminal) dipertahankan
+	var dataTambah []elemenTLV
+	for _, e := range elemen {
+		if e.id == tagQRISDataTambah {
+			if dataTambah, err = bacaTLV(e.nilai); err != nil {
+				return "", err
+			}
+		}
+	}
+	dataTambah = aturTLV(dataTambah, subtagQRISNomorTagihan, referensi)
+	dataTambah = aturTLV(dataTambah, subtagQRISReferensi, referensi)
+	elemen = aturTLV(elemen, tagQRISDataTambah, tulisTLV(dataTambah))
+
+	isi := tulisTLV(elemen) + tagQRISCRC + "04"
+	return isi + CRC16QRIS(isi), nil
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// qrisStatisContoh menyusun QRIS statis merchant dengan CRC yang benar
+func qrisStatisContoh() string {
+	isi := tulisTLV([]elemenTLV{
+		{"00", "01"},
+		{"01", "11"},
+		{"26", tulisTLV([]elemenTLV{{"00", "ID.CO.TPQ.WWW"}, {"01", "936000000012345678"}})},
+		{"51", tulisTLV([]elemenTLV{{"00", "ID.CO.QRIS.WWW"}, {"02", "ID1020021181745"}})},
+		{"52", "8299"},
+		{"53", "360"},
+		{"58", "ID"},
+		{"59", "TPQ ASY SYAFII"},
+		{"60", "SEMARANG"},
+		{"62", tulisTLV([]elemenTLV{{"07", "A01"}})},
+	}) + tagQRISCRC + "04"
+	return isi + CRC16QRIS(isi)
+}
+
+func TestCRC16QRIS(t *testing.T) {
+	tests := []struct {
+		nama string
+		data string
+		want string
+	}{
+		{"check value CRC-16/CCITT-FALSE", "123456789", "29B1"},
+		{
+			"contoh payload spesifikasi EMVCo",
+			"00020101021229300012D156000000000510A93FO3230Q31280012D15600000001030812345678520441115802CN5914BEST TRANSPORT6007BEIJING64200002ZH0104最佳运输0202北京540523.7253031565502016233030412340603***0708A60086670902ME91320016A0112233449988770708123456786304",
+			"A13A",
+		},
+		{"data kosong", "", "FFFF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			if got := CRC16QRIS(tt.data); got != tt.want {
+				t.Errorf("CRC16QRIS() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidasiQRIS(t *testing.T) {
+	statis := qrisStatisContoh()
+	tests := []struct {
+		nama    string
+		payload string
+		valid   bool
+	}{
+		{"QRIS statis", statis, true},
+		{"CRC huruf kecil", statis[:len(statis)-4] + strings.ToLower(statis[len(statis)-4:]), true},
+		{"nama merchant diubah", strings.Replace(statis, "TPQ ASY SYAFII", "TPQ ASY SYAFIX", 1), false},
+		{"tanpa awalan 000201", statis[6:], false},
+		{"CRC terpotong", statis[:len(statis)-2], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			err := ValidasiQRIS(tt.payload)
+			if tt.valid && err != nil {
+				t.Fatalf("ValidasiQRIS() error = %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrQRISTidakValid) {
+				t.Fatalf("ValidasiQRIS() error = %v, want ErrQRISTidakValid", err)
+			}
+		})
+	}
+}
+
+func TestQRISDinamis(t *testing.T) {
+	statis := qrisStatisContoh()
+	tests := []struct {
+		nama      string
+		nominal   float64
+		referensi string
+		nilai     string // isi tag 54
+		gagal     bool
+	}{
+		{"nominal bulat", 150000, "TPQ7K3M9XQ2", "150000", false},
+		{"nominal dibulatkan", 25000.6, "TPQABCDEFGH", "25001", false},
+		{"nominal nol", 0, "TPQ7K3M9XQ2", "", true},
+		{"referensi kosong", 150000, "", "", true},
+		{"referensi terlalu panjang", 150000, strings.Repeat("A", 26), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			payload, err := QRISDinamis(statis, tt.nominal, tt.referensi)
+			if tt.gagal {
+				if err == nil {
+					t.Fatalf("QRISDinamis() = %s, want error", payload)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("QRISDinamis() error = %v", err)
+			}
+			if err := ValidasiQRIS(payload); err != nil {
+				t.Fatalf("ValidasiQRIS(QRISDinamis()) error = %v", err)
+			}
+
+			elemen, err := bacaQRIS(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tag := map[string]string{}
+			for _, e := range elemen {
+				tag[e.id] = e.nilai
+			}
+			if tag[tagQRISMetode] != "12" {
+				t.Errorf("tag 01 = %s, want 12", tag[tagQRISMetode])
+			}
+			if tag[tagQRISNominal] != tt.nilai {
+				t.Errorf("tag 54 = %s, want %s", tag[tagQRISNominal], tt.nilai)
+			}
+			want := tulisTLV([]elemenTLV{{"01", tt.referensi}, {"05", tt.referensi}, {"07", "A01"}})
+			if tag[tagQRISDataTambah] != want {
+				t.Errorf("tag 62 = %s, want %s", tag[tagQRISDataTambah], want)
+			}
+		})
+	}
+}